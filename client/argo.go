@@ -0,0 +1,97 @@
+package coverageclient
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	defaultArgoArtifactDir   = "/tmp/argo/coverage"
+	defaultArgoParametersDir = "/tmp/argo/parameters"
+)
+
+// ArgoOutputsOptions configures WriteArgoOutputs. Parameter names become files in ParametersDir,
+// referenced from the template with `valueFrom: {path: ...}`; empty names use the defaults, "-" skips one.
+type ArgoOutputsOptions struct {
+	ArtifactDir       string // Path declared as the step's output artifact (default: /tmp/argo/coverage)
+	ParametersDir     string // Directory for output parameter files (default: /tmp/argo/parameters)
+	ArtifactKey       string // Artifact repository key reported to later steps (default: coverage/<test name>)
+	CoverageParameter string // Parameter for the total coverage percent, e.g. "78.3" (default: coverage-percent)
+	KeyParameter      string // Parameter for the artifact key (default: artifact-key)
+	TestParameter     string // Parameter for the test name (default: test-name)
+}
+
+// WriteArgoOutputs copies the coverage output of a test to the step's output artifact path and writes
+// Argo output parameters (artifact key, total coverage), so Argo Workflows can pass coverage data
+// between steps without shell glue
+func (c *CoverageClient) WriteArgoOutputs(testName string, opts ArgoOutputsOptions) error {
+	if opts.ArtifactDir == "" {
+		opts.ArtifactDir = defaultArgoArtifactDir
+	}
+	if opts.ParametersDir == "" {
+		opts.ParametersDir = defaultArgoParametersDir
+	}
+	if opts.ArtifactKey == "" {
+		opts.ArtifactKey = "coverage/" + sanitizeName(testName)
+	}
+
+	testDir := filepath.Join(c.outputDir, testName)
+	files, err := copyDir(testDir, opts.ArtifactDir)
+	if err != nil {
+		return fmt.Errorf("copy coverage output: %w", err)
+	}
+	c.logf("📦 Copied %d coverage file(s) to Argo artifact path %s\n", files, opts.ArtifactDir)
+
+	parameters := map[string]string{
+		resultName(opts.KeyParameter, "artifact-key"): opts.ArtifactKey,
+		resultName(opts.TestParameter, "test-name"):   testName,
+	}
+	if name := resultName(opts.CoverageParameter, "coverage-percent"); name != "-" {
+		profile, err := c.LoadProfile(testName)
+		if err != nil {
+			c.logf("⚠️  Skipping coverage parameter, report not available: %v\n", err)
+		} else {
+			parameters[name] = strconv.FormatFloat(profile.Total().Percent(), 'f', 1, 64)
+		}
+	}
+	delete(parameters, "-")
+
+	if err := os.MkdirAll(opts.ParametersDir, 0755); err != nil {
+		return fmt.Errorf("create parameters directory: %w", err)
+	}
+	for name, value := range parameters {
+		if err := os.WriteFile(filepath.Join(opts.ParametersDir, name), []byte(value), 0644); err != nil {
+			return fmt.Errorf("write Argo parameter %s: %w", name, err)
+		}
+		c.logf("📝 Argo output parameter %s: %s\n", name, value)
+	}
+	return nil
+}
+
+// copyDir copies the regular files below src to dst, keeping the directory structure,
+// and returns the number of files copied
+func copyDir(src, dst string) (int, error) {
+	files := 0
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		files++
+		return copyFile(path, target)
+	})
+	return files, err
+}
@@ -0,0 +1,44 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteArgoOutputs(t *testing.T) {
+	outputDir := t.TempDir()
+	testDir := filepath.Join(outputDir, "e2e")
+	os.MkdirAll(filepath.Join(testDir, "pod-a"), 0755)
+	os.WriteFile(filepath.Join(testDir, "coverage.out"), []byte(testProfile), 0644)
+	os.WriteFile(filepath.Join(testDir, "pod-a", "covmeta.abc"), []byte("meta"), 0644)
+
+	artifactDir := filepath.Join(t.TempDir(), "coverage")
+	parametersDir := filepath.Join(t.TempDir(), "parameters")
+
+	client := &CoverageClient{outputDir: outputDir}
+	err := client.WriteArgoOutputs("e2e", ArgoOutputsOptions{
+		ArtifactDir:   artifactDir,
+		ParametersDir: parametersDir,
+		TestParameter: "-",
+	})
+	if err != nil {
+		t.Fatalf("WriteArgoOutputs failed: %v", err)
+	}
+
+	for _, name := range []string{"coverage.out", filepath.Join("pod-a", "covmeta.abc")} {
+		if _, err := os.Stat(filepath.Join(artifactDir, name)); err != nil {
+			t.Errorf("Expected %s in artifact directory: %v", name, err)
+		}
+	}
+
+	for name, want := range map[string]string{"coverage-percent": "66.7", "artifact-key": "coverage/e2e"} {
+		data, err := os.ReadFile(filepath.Join(parametersDir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("Parameter %s: expected %q, got %q (%v)", name, want, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parametersDir, "test-name")); err == nil {
+		t.Error("Expected skipped parameter not to be written")
+	}
+}
@@ -0,0 +1,187 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Attribution collects the coverage of each test case separately from one running application:
+// BeginTest clears the application's counters, EndTest collects and clears them in one request and
+// saves them into the test's own directory. The manifest (attribution.json in the output
+// directory) maps every test to the files it covered:
+//
+//	attribution := client.NewAttribution(coverageclient.CollectOptions{LabelSelector: "app=my-app"})
+//	attribution.BeginTest(ctx, "login")
+//	runLoginTest()
+//	attribution.EndTest(ctx)
+//
+// Tests must run one at a time against the application; code running in between (setup,
+// teardown) isn't attributed to any test. Counters can only be cleared in binaries built with
+// -covermode=atomic.
+type Attribution struct {
+	Collect  CollectOptions // Target of the collections: PodName, LabelSelector or URL; TestName and Reset are set per test
+	Process  ProcessOptions // Report generation for each test; its TestName is ignored
+	Manifest string         // Manifest file (default: <output>/attribution.json)
+
+	client   *CoverageClient
+	mu       sync.Mutex
+	current  string
+	manifest AttributionManifest
+}
+
+// AttributionManifest maps tests to the files they covered
+type AttributionManifest struct {
+	Tests []AttributedTest `json:"tests"`
+}
+
+// AttributedTest is the coverage of one test in the manifest
+type AttributedTest struct {
+	Name  string        `json:"name"`
+	Dir   string        `json:"dir"`   // Test directory, relative to the output directory
+	Files []string      `json:"files"` // Files with at least one covered statement, sorted
+	Total CoverageStats `json:"total"`
+}
+
+// NewAttribution starts attributing coverage of the application collected as configured by opts
+func (c *CoverageClient) NewAttribution(opts CollectOptions) *Attribution {
+	return &Attribution{Collect: opts, client: c}
+}
+
+// BeginTest clears the application's counters, so the next EndTest only collects what testName runs
+func (a *Attribution) BeginTest(ctx context.Context, testName string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.current != "" {
+		return fmt.Errorf("begin test %s: test %s hasn't ended", testName, a.current)
+	}
+	if testName == "" {
+		return fmt.Errorf("begin test: no test name")
+	}
+	if err := a.resetCounters(ctx); err != nil {
+		return fmt.Errorf("begin test %s: %w", testName, err)
+	}
+	a.current = testName
+	return nil
+}
+
+// resetCounters clears the counters of the collection target
+func (a *Attribution) resetCounters(ctx context.Context) error {
+	opts := a.Collect
+	switch {
+	case opts.URL != "":
+		resetURL, err := url.JoinPath(opts.URL, "reset")
+		if err != nil {
+			return fmt.Errorf("parse coverage URL: %w", err)
+		}
+		return a.client.resetCountersAt(ctx, resetURL)
+	case opts.PodName != "":
+		return a.client.ResetCounters(ctx, opts.PodName, opts.Port)
+	case opts.LabelSelector != "":
+		podName, err := a.client.GetPodNameWithContext(ctx, opts.LabelSelector)
+		if err != nil {
+			return err
+		}
+		return a.client.ResetCounters(ctx, podName, opts.Port)
+	}
+	return fmt.Errorf("attribution needs a PodName, LabelSelector or URL target")
+}
+
+// EndTest collects the coverage of the running test into its test directory, clearing the
+// application's counters in the same request, generates its report and records the files it
+// covered in the manifest. The test has ended even when EndTest fails.
+func (a *Attribution) EndTest(ctx context.Context) (*AttributedTest, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	testName := a.current
+	if testName == "" {
+		return nil, fmt.Errorf("end test: no test begun")
+	}
+	a.current = ""
+
+	opts := a.Collect
+	opts.TestName = testName
+	opts.Reset = true
+	collected, err := a.client.Collect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("end test %s: %w", testName, err)
+	}
+	processOpts := a.Process
+	processOpts.TestName = collected.TestName
+	processed, err := a.client.ProcessCoverage(ctx, processOpts)
+	if err != nil {
+		return nil, fmt.Errorf("end test %s: %w", testName, err)
+	}
+	profile, err := ParseProfileFile(processed.FilteredProfile)
+	if err != nil {
+		return nil, fmt.Errorf("end test %s: %w", testName, err)
+	}
+
+	test := AttributedTest{Name: testName, Dir: collected.TestName, Files: coveredFiles(profile), Total: processed.Total}
+	a.record(test)
+	if err := a.writeManifest(); err != nil {
+		return &test, fmt.Errorf("end test %s: %w", testName, err)
+	}
+	a.client.logf("🧩 %s covered %d files (%.1f%%)\n", testName, len(test.Files), test.Total.Percent())
+	return &test, nil
+}
+
+// record adds a test to the manifest, replacing an earlier entry of its directory (collections of
+// a test accumulate there, so the new entry covers both)
+func (a *Attribution) record(test AttributedTest) {
+	for i, recorded := range a.manifest.Tests {
+		if recorded.Dir == test.Dir {
+			a.manifest.Tests[i] = test
+			return
+		}
+	}
+	a.manifest.Tests = append(a.manifest.Tests, test)
+}
+
+// Tests returns the tests recorded in the manifest, in the order they first ended
+func (a *Attribution) Tests() []AttributedTest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AttributedTest(nil), a.manifest.Tests...)
+}
+
+// manifestPath returns the manifest file
+func (a *Attribution) manifestPath() string {
+	if a.Manifest != "" {
+		return a.Manifest
+	}
+	return filepath.Join(a.client.outputDir, "attribution.json")
+}
+
+// writeManifest saves the manifest after every test, so interrupted runs keep the tests so far
+func (a *Attribution) writeManifest() error {
+	data, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal attribution manifest: %w", err)
+	}
+	if err := os.WriteFile(a.manifestPath(), data, 0644); err != nil {
+		return fmt.Errorf("write attribution manifest: %w", err)
+	}
+	return nil
+}
+
+// coveredFiles returns the files of a profile with at least one covered statement, sorted
+func coveredFiles(profile *Profile) []string {
+	covered := make(map[string]bool)
+	for _, b := range profile.Blocks {
+		if b.Count > 0 {
+			covered[b.File] = true
+		}
+	}
+	files := make([]string, 0, len(covered))
+	for file := range covered {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
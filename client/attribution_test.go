@@ -0,0 +1,110 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stagedCoverageResponse returns the covdata files of an instrumented run in dir as a JSON
+// coverage response
+func stagedCoverageResponse(t *testing.T, dir string) CoverageResponse {
+	t.Helper()
+	var response CoverageResponse
+	metas, _ := filepath.Glob(filepath.Join(dir, "covmeta.*"))
+	counters, _ := filepath.Glob(filepath.Join(dir, "covcounters.*"))
+	if len(metas) != 1 || len(counters) != 1 {
+		t.Fatalf("Expected one meta-data and one counters file in %s, got %v %v", dir, metas, counters)
+	}
+	meta, _ := os.ReadFile(metas[0])
+	data, _ := os.ReadFile(counters[0])
+	response.MetaFilename, response.MetaData = filepath.Base(metas[0]), base64.StdEncoding.EncodeToString(meta)
+	response.CountersFilename, response.CountersData = filepath.Base(counters[0]), base64.StdEncoding.EncodeToString(data)
+	return response
+}
+
+func TestAttribution(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), counterBases: &counterBases{}, metaCache: &metaCache{}, recordDisabled: true, quiet: true}
+	runCoverProgram(t, client, ".runs-login", "login")
+	runCoverProgram(t, client, ".runs-checkout", "checkout")
+	runs := []CoverageResponse{
+		stagedCoverageResponse(t, filepath.Join(client.outputDir, ".runs-login")),
+		stagedCoverageResponse(t, filepath.Join(client.outputDir, ".runs-checkout")),
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+		w.Header().Set(coverageResetHeader, "true")
+		if r.URL.Path == "/coverage" {
+			json.NewEncoder(w).Encode(runs[0])
+			runs = runs[1:]
+		}
+	}))
+	defer server.Close()
+	client.httpClient = server.Client()
+
+	attribution := client.NewAttribution(CollectOptions{URL: server.URL + "/coverage"})
+	attribution.Process.SkipHTML = true
+	for _, test := range []string{"login", "checkout"} {
+		if err := attribution.BeginTest(context.Background(), test); err != nil {
+			t.Fatalf("BeginTest failed: %v", err)
+		}
+		result, err := attribution.EndTest(context.Background())
+		if err != nil {
+			t.Fatalf("EndTest failed: %v", err)
+		}
+		if result.Name != test || result.Dir != test || len(result.Files) != 1 || !strings.HasSuffix(result.Files[0], "main.go") {
+			t.Errorf("Unexpected attribution of %s: %+v", test, result)
+		}
+		if result.Total.Covered == 0 || result.Total.Covered == result.Total.Statements {
+			t.Errorf("Expected %s to cover one branch, got %+v", test, result.Total)
+		}
+	}
+	want := []string{"/coverage/reset?", "/coverage?reset=true", "/coverage/reset?", "/coverage?reset=true"}
+	if strings.Join(requests, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, requests)
+	}
+
+	data, err := os.ReadFile(filepath.Join(client.outputDir, "attribution.json"))
+	if err != nil {
+		t.Fatalf("Expected the manifest: %v", err)
+	}
+	var manifest AttributionManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest.Tests) != 2 || manifest.Tests[1].Name != "checkout" {
+		t.Errorf("Unexpected manifest %s (%v)", data, err)
+	}
+	if tests := attribution.Tests(); len(tests) != 2 || tests[0].Total == tests[1].Total {
+		t.Errorf("Expected distinct coverage per test, got %+v", tests)
+	}
+}
+
+func TestAttribution_Errors(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	attribution := client.NewAttribution(CollectOptions{ServiceName: "app"})
+	if err := attribution.BeginTest(context.Background(), "login"); err == nil || !strings.Contains(err.Error(), "PodName, LabelSelector or URL") {
+		t.Errorf("Expected an unsupported target error, got %v", err)
+	}
+	if _, err := attribution.EndTest(context.Background()); err == nil || !strings.Contains(err.Error(), "no test begun") {
+		t.Errorf("Expected an error without BeginTest, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(coverageResetHeader, "true")
+	}))
+	defer server.Close()
+	client.httpClient = server.Client()
+	attribution = client.NewAttribution(CollectOptions{URL: server.URL + "/coverage"})
+	if err := attribution.BeginTest(context.Background(), "login"); err != nil {
+		t.Fatalf("BeginTest failed: %v", err)
+	}
+	if err := attribution.BeginTest(context.Background(), "checkout"); err == nil || !strings.Contains(err.Error(), "login hasn't ended") {
+		t.Errorf("Expected an overlapping test error, got %v", err)
+	}
+}
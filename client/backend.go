@@ -0,0 +1,330 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collector is a coverage collection backend. Collect saves the covdata files of target (a pod
+// name, URL, container, or whatever the backend addresses) into the client's test directory
+// for testName (see TestDir), where reporting and pushing pick them up. A nil result lets the
+// caller list the files. Backends are used through CollectOptions.Backend.
+type Collector interface {
+	Collect(ctx context.Context, target, testName string) (*CollectResult, error)
+}
+
+// CollectResult describes a finished collection
+type CollectResult struct {
+	TestName   string
+	Method     CollectionMethod // Kubernetes collection method that succeeded, if any
+	Dir        string           // Test directory the files are in
+	Files      []string         // Covdata files this collection saved in the test directory
+	Sizes      map[string]int64 // Sizes of Files in bytes, by file name
+	Pod        string           // Pod collected from, for pod collections
+	Container  string           // Container running the coverage server, when known
+	ServerTime time.Time        // When the coverage server snapshotted its newest counters
+	Duration   time.Duration    // How long the collection took
+}
+
+// Paths returns the paths of Files
+func (r *CollectResult) Paths() []string {
+	paths := make([]string, len(r.Files))
+	for i, name := range r.Files {
+		paths[i] = filepath.Join(r.Dir, name)
+	}
+	return paths
+}
+
+// Bytes returns the total size of Files
+func (r *CollectResult) Bytes() int64 {
+	var total int64
+	for _, size := range r.Sizes {
+		total += size
+	}
+	return total
+}
+
+// Names of the built-in backends, see CollectOptions.Backend
+const (
+	BackendKubernetes = "kubernetes" // Pods, with the fallback collection methods
+	BackendURL        = "url"        // Coverage endpoint URLs
+	BackendExec       = "exec"       // Pods, by exec into the coverage container
+	BackendDocker     = "docker"     // Local Docker containers
+)
+
+// RegisterCollector makes a backend available to Collect (CollectOptions.Backend) under name,
+// replacing a built-in backend of the same name
+func (c *CoverageClient) RegisterCollector(name string, collector Collector) {
+	if c.collectors == nil {
+		c.collectors = make(map[string]Collector)
+	}
+	c.collectors[name] = collector
+}
+
+// collector returns the named backend: a registered one or a built-in one with default settings
+func (c *CoverageClient) collector(name string) (Collector, error) {
+	if collector, ok := c.collectors[name]; ok {
+		return collector, nil
+	}
+	switch name {
+	case BackendKubernetes:
+		return &KubernetesCollector{Client: c}, nil
+	case BackendURL:
+		return &URLCollector{Client: c}, nil
+	case BackendExec:
+		return &ExecCollector{Client: c}, nil
+	case BackendDocker:
+		return &DockerCollector{Client: c}, nil
+	}
+	return nil, fmt.Errorf("unknown collection backend: %s", name)
+}
+
+// TestDir creates the directory covdata files of testName are saved to, after checking the output
+// directory's free space (see SetMinFreeSpace). Custom backends write their files there.
+func (c *CoverageClient) TestDir(testName string) (string, error) {
+	return c.createTestDir(testName)
+}
+
+// KubernetesCollector collects from pods (target: pod name) with CollectCoverageWithFallback
+type KubernetesCollector struct {
+	Client   *CoverageClient
+	Port     int             // Coverage port (default: the client's, see SetCoveragePort)
+	Fallback FallbackOptions // Collection methods to try (default: DefaultFallbackMethods)
+}
+
+func (k *KubernetesCollector) Collect(ctx context.Context, podName, testName string) (*CollectResult, error) {
+	method, err := k.Client.CollectCoverageWithFallback(ctx, podName, testName, k.Client.coveragePort(k.Port), k.Fallback)
+	if err != nil {
+		return nil, err
+	}
+	return k.Client.collectResult(testName, method), nil
+}
+
+// URLCollector collects from coverage endpoints (target: URL, e.g. http://localhost:9095/coverage)
+type URLCollector struct {
+	Client *CoverageClient
+}
+
+func (u *URLCollector) Collect(ctx context.Context, coverageURL, testName string) (*CollectResult, error) {
+	if err := u.Client.collectCoverageFromURL(ctx, coverageURL, testName); err != nil {
+		return nil, err
+	}
+	return u.Client.collectResult(testName, ""), nil
+}
+
+// collectWithEvents collects like Collect, sending collection events
+func (u *URLCollector) collectWithEvents(ctx context.Context, coverageURL, testName string) (*CollectResult, error) {
+	return u.Client.collectWithEvents(ctx, testName, coverageURL, 1, func(ctx context.Context) (*CollectResult, error) {
+		return u.Collect(ctx, coverageURL, testName)
+	})
+}
+
+// ExecCollector collects from pods (target: pod name) by exec into the coverage container,
+// without port-forwarding or network access to the pod
+type ExecCollector struct {
+	Client    *CoverageClient
+	Container string // Container to exec into (default: the one exposing Port)
+	Port      int    // Coverage port (default: the client's, see SetCoveragePort)
+	CoverDir  bool   // Copy the whole GOCOVERDIR (MethodCoverDir) instead of calling the coverage server (MethodExec)
+}
+
+func (e *ExecCollector) Collect(ctx context.Context, podName, testName string) (*CollectResult, error) {
+	method := MethodExec
+	if e.CoverDir {
+		method = MethodCoverDir
+	}
+	collector := KubernetesCollector{Client: e.Client, Port: e.Port, Fallback: FallbackOptions{
+		Methods:       []CollectionMethod{method},
+		ContainerName: e.Container,
+	}}
+	return collector.Collect(ctx, podName, testName)
+}
+
+// DockerCollector collects from local Docker containers (target: container name or ID) by copying
+// GOCOVERDIR out with `docker exec`, so the coverage flush interval decides how recent the data is
+type DockerCollector struct {
+	Client *CoverageClient
+	Docker string // Docker CLI to run (default: "docker", e.g. "podman" works too)
+}
+
+func (d *DockerCollector) Collect(ctx context.Context, container, testName string) (*CollectResult, error) {
+	docker := d.Docker
+	if docker == "" {
+		docker = "docker"
+	}
+	c := d.Client
+	c.logf("📊 Collecting coverage from container %s for test: %s\n", container, testName)
+
+	script := fmt.Sprintf(`cd "${GOCOVERDIR:-%s}" && tar czf - $(ls | grep -E '^cov(meta|counters)\.')`, defaultCoverDir)
+	cmd := exec.CommandContext(ctx, docker, "exec", container, "sh", "-c", script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("run %s exec: %w", docker, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("run %s exec: %w", docker, err)
+	}
+
+	testDir, err := c.createTestDir(testName)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	files, extractErr := extractTarGz(stdout, testDir, c.responseLimit())
+	if extractErr != nil {
+		cmd.Process.Kill() // Don't wait on a process blocked writing the rest of the archive
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case waitErr != nil && (extractErr == nil || stderr.Len() > 0):
+		return nil, fmt.Errorf("%s exec in container %s: %w (stderr: %s)", docker, container, waitErr, strings.TrimSpace(stderr.String()))
+	case extractErr != nil:
+		return nil, fmt.Errorf("extract coverage archive: %w", extractErr)
+	}
+
+	if _, err := c.pruneCovdataDir(testDir); err != nil {
+		return nil, err
+	}
+	if err := c.separateEarlierBinaries(testDir, files); err != nil {
+		return nil, err
+	}
+	c.logf("✅ Coverage collected from container %s for test: %s (%d files)\n", container, testName, len(files))
+	result := c.collectResult(testName, "")
+	result.Container = container
+	return result, nil
+}
+
+// collectResult describes the covdata files the collection saved into the test directory, not
+// those of earlier collections. Pod collections take the pod and container from the metadata.json
+// they saved.
+func (c *CoverageClient) collectResult(testName string, method CollectionMethod) *CollectResult {
+	files, ok := c.savedFiles.take(c.testDir(testName))
+	if !ok {
+		// Custom backends write into the test directory themselves
+		files = covdataFiles(c.testDir(testName))
+	}
+	result := &CollectResult{TestName: testName, Method: method, Files: files}
+	c.describeFiles(result)
+	if metadata, err := c.readPodMetadata(testName); err == nil && method != "" && method == metadata.CollectionMethod {
+		result.Pod, result.Container = metadata.PodName, metadata.Container.Name
+	}
+	return result
+}
+
+// describeFiles fills in the directory, sizes and server time of result's files. Counters files
+// are named covcounters.<hash>.<pid>.<unix nanoseconds> by the server that wrote them.
+func (c *CoverageClient) describeFiles(result *CollectResult) {
+	result.Dir = c.testDir(result.TestName)
+	result.Sizes = make(map[string]int64, len(result.Files))
+	for _, name := range result.Files {
+		if info, err := os.Stat(filepath.Join(result.Dir, name)); err == nil {
+			result.Sizes[name] = info.Size()
+		}
+		if !strings.HasPrefix(name, "covcounters.") {
+			continue
+		}
+		nanos, err := strconv.ParseInt(name[strings.LastIndex(name, ".")+1:], 10, 64)
+		if t := time.Unix(0, nanos).UTC(); err == nil && t.After(result.ServerTime) {
+			result.ServerTime = t
+		}
+	}
+}
+
+// savedFiles records the covdata files saved into each test directory since its collection started
+type savedFiles struct {
+	mu    sync.Mutex
+	files map[string][]string // File names by test directory
+}
+
+// reset forgets the files saved into testDir, when a collection into it starts
+func (s *savedFiles) reset(testDir string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, testDir)
+}
+
+// add records the covdata files (names or paths) saved into testDir
+func (s *savedFiles) add(testDir string, files []string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string][]string)
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		if (strings.HasPrefix(name, "covmeta.") || strings.HasPrefix(name, "covcounters.")) && !slices.Contains(s.files[testDir], name) {
+			s.files[testDir] = append(s.files[testDir], name)
+		}
+	}
+}
+
+// take returns the sorted names of the files saved into testDir that are still there, and
+// forgets them. It reports false when no save was recorded.
+func (s *savedFiles) take(testDir string) ([]string, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	saved, ok := s.files[testDir]
+	delete(s.files, testDir)
+	s.mu.Unlock()
+
+	var files []string
+	for _, name := range saved {
+		// Pruned since, or moved aside as coverage of an earlier binary
+		if _, err := os.Stat(filepath.Join(testDir, name)); err == nil {
+			files = append(files, name)
+		}
+	}
+	slices.Sort(files)
+	return files, ok
+}
+
+// testDir returns the directory of testName in the output directory
+func (c *CoverageClient) testDir(testName string) string {
+	return filepath.Join(c.outputDir, testName)
+}
+
+// covdataFiles returns the covmeta/covcounters file names in dir
+func covdataFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && (strings.HasPrefix(name, "covmeta.") || strings.HasPrefix(name, "covcounters.")) {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// coveragePort returns port, or the client's coverage port when unset
+func (c *CoverageClient) coveragePort(port int) int {
+	switch {
+	case port != 0:
+		return port
+	case c.port != 0:
+		return c.port
+	}
+	return DefaultCoveragePort
+}
@@ -0,0 +1,134 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// vmCollector is a custom backend copying covdata files from a fake VM's directory
+type vmCollector struct {
+	client *CoverageClient
+	files  map[string]string
+}
+
+func (v *vmCollector) Collect(ctx context.Context, host, testName string) (*CollectResult, error) {
+	testDir, err := v.client.TestDir(testName)
+	if err != nil {
+		return nil, err
+	}
+	for name, content := range v.files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func TestCollect_CustomBackend(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	client.RegisterCollector("ssh", &vmCollector{client: client, files: map[string]string{
+		"covmeta.abc": "meta", "covcounters.abc.1.1700000000000000000": "counters", "covcounters.abc.2.1600000000000000000": "old",
+	}})
+
+	result, err := client.Collect(context.Background(), CollectOptions{Backend: "ssh", Target: "vm-1", TestName: "e2e"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if result.TestName != "e2e" || strings.Join(result.Files, ",") != "covcounters.abc.1.1700000000000000000,covcounters.abc.2.1600000000000000000,covmeta.abc" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	// Results of backends are described like the built-in ones
+	if result.Sizes["covmeta.abc"] != 4 || result.Bytes() != 15 || result.Duration <= 0 {
+		t.Errorf("Expected the file sizes and duration, got %+v", result)
+	}
+	if !result.ServerTime.Equal(time.Unix(0, 1700000000000000000)) {
+		t.Errorf("Expected the server time of the newest counters, got %v", result.ServerTime)
+	}
+
+	client.RegisterCollector("empty", &vmCollector{client: client})
+	if _, err := client.Collect(context.Background(), CollectOptions{Backend: "empty", Target: "vm-1", TestName: "other"}); err == nil || !strings.Contains(err.Error(), "no coverage data") {
+		t.Errorf("Expected a backend without covdata to fail, got %v", err)
+	}
+	if _, err := client.Collect(context.Background(), CollectOptions{Backend: "ftp", Target: "vm-1", TestName: "e2e"}); err == nil {
+		t.Error("Expected an unknown backend to fail")
+	}
+}
+
+func TestCollect_URLBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), quiet: true}
+	result, err := client.Collect(context.Background(), CollectOptions{Backend: BackendURL, Target: server.URL + "/coverage", TestName: "e2e"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(result.Files) != 2 || result.Method != "" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestCollect_ResultListsOnlySavedFiles(t *testing.T) {
+	collections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collections++
+		fmt.Fprint(w, covdataResponse(newBinaryHash, fmt.Sprintf("covcounters.%s.1.%d", newBinaryHash, collections)))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), quiet: true, savedFiles: &savedFiles{}}
+	opts := CollectOptions{Backend: BackendURL, Target: server.URL + "/coverage", TestName: "e2e"}
+	if _, err := client.Collect(context.Background(), opts); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	result, err := client.Collect(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	// The counters of the first collection stay in the test directory, but aren't this result's
+	expected := fmt.Sprintf("covcounters.%s.1.2,covmeta.%s", newBinaryHash, newBinaryHash)
+	if strings.Join(result.Files, ",") != expected || len(result.Sizes) != 2 {
+		t.Errorf("Expected only the files of the second collection %s, got %v", expected, result.Files)
+	}
+	if files := covdataFiles(result.Dir); len(files) != 3 {
+		t.Errorf("Expected both collections' counters in the test directory, got %v", files)
+	}
+}
+
+func TestDockerCollector(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker CLI is a shell script")
+	}
+	binDir := t.TempDir()
+	archive := filepath.Join(binDir, "coverage.tar.gz")
+	os.WriteFile(archive, coverageArchive(map[string]string{
+		"covmeta.abc": "meta", "covcounters.abc.1.2": "counters", "covcounters.def.1.1": "orphaned",
+	}), 0644)
+	script := fmt.Sprintf("#!/bin/sh\nif [ \"$2\" != app ]; then echo \"No such container: $2\" >&2; exit 1; fi\ncat '%s'\n", archive)
+	os.WriteFile(filepath.Join(binDir, "fake-docker"), []byte(script), 0755)
+
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	collector := &DockerCollector{Client: client, Docker: filepath.Join(binDir, "fake-docker")}
+	result, err := collector.Collect(context.Background(), "app", "e2e")
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if strings.Join(result.Files, ",") != "covcounters.abc.1.2,covmeta.abc" {
+		t.Errorf("Expected orphaned counters to be pruned, got %v", result.Files)
+	}
+
+	if _, err := collector.Collect(context.Background(), "missing", "e2e"); err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Errorf("Expected the CLI's error, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/registry"
+)
+
+// BaselineOptions locates the coverage artifacts of the base branch, as pushed by
+// PushCoverageArtifact from main-branch runs
+type BaselineOptions struct {
+	Registry   string // Registry URL (e.g., "quay.io")
+	Repository string // Repository name (e.g., "myorg/coverage")
+	// TagPattern selects baseline tags; "{test}" is replaced by the test name. Glob patterns
+	// (path.Match syntax) pick the greatest matching tag, so timestamped tags such as
+	// "{test}-main-*" resolve to the latest run (default: "{test}-main")
+	TagPattern string
+}
+
+// baselineSource is a repository baselines can be listed and pulled from
+type baselineSource interface {
+	oras.ReadOnlyTarget
+	registry.TagLister
+}
+
+// SetBaseline configures automatic baseline comparison: coverage reports that don't name a
+// BaselineProfile pull the latest matching artifact and compare against it, so deltas show up
+// in PR comments, statuses, notifications and summaries without extra pipeline steps
+func (c *CoverageClient) SetBaseline(opts BaselineOptions) {
+	if opts.TagPattern == "" {
+		opts.TagPattern = "{test}-main"
+	}
+	c.baseline = &opts
+}
+
+// PullBaseline pulls the latest baseline artifact of a test (see SetBaseline) and returns the path
+// of its coverage profile. Pulled artifacts are kept below the output directory and reused.
+func (c *CoverageClient) PullBaseline(ctx context.Context, testName string) (string, error) {
+	if c.baseline == nil {
+		return "", fmt.Errorf("no baseline configured")
+	}
+	repo, err := newRemoteRepository(c.baseline.Registry, c.baseline.Repository)
+	if err != nil {
+		return "", err
+	}
+	return c.pullBaselineFrom(ctx, repo, strings.ReplaceAll(c.baseline.TagPattern, "{test}", testName))
+}
+
+// pullBaselineFrom resolves the tag pattern in src and pulls the artifact into the output directory
+func (c *CoverageClient) pullBaselineFrom(ctx context.Context, src baselineSource, pattern string) (string, error) {
+	tag, err := resolveBaselineTag(ctx, src, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(c.outputDir, ".baseline", sanitizeName(tag))
+	if profile, ok := baselineProfile(dir); ok {
+		return profile, nil
+	}
+
+	c.logf("📥 Pulling baseline coverage %s\n", tag)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create baseline directory: %w", err)
+	}
+	fs, err := file.New(dir)
+	if err != nil {
+		return "", fmt.Errorf("create file store: %w", err)
+	}
+	defer fs.Close()
+	if _, err := oras.Copy(ctx, src, tag, fs, tag, oras.DefaultCopyOptions); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("pull baseline %s: %w", tag, err)
+	}
+
+	profile, ok := baselineProfile(dir)
+	if !ok {
+		return "", fmt.Errorf("baseline %s contains no coverage profile", tag)
+	}
+	return profile, nil
+}
+
+// resolveBaselineTag returns the pattern itself when it has no glob characters, else the greatest matching tag
+func resolveBaselineTag(ctx context.Context, src baselineSource, pattern string) (string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid baseline tag pattern %q: %w", pattern, err)
+	}
+
+	latest := ""
+	err := src.Tags(ctx, "", func(tags []string) error {
+		for _, tag := range tags {
+			if ok, _ := path.Match(pattern, tag); ok && tag > latest {
+				latest = tag
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("list baseline tags: %w", err)
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no baseline tag matches %q", pattern)
+	}
+	return latest, nil
+}
+
+// baselineProfile returns the filtered (or raw) coverage profile in a pulled artifact
+func baselineProfile(dir string) (string, bool) {
+	for _, name := range []string{"coverage_filtered.out", "coverage.out"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name), true
+		}
+	}
+	return "", false
+}
+
+// baselineProfileFor returns the configured baseline of a report, pulling it when needed.
+// A missing baseline (e.g. before the first main-branch run) only logs a warning.
+func (c *CoverageClient) baselineProfileFor(ctx context.Context, opts CoverageReportOptions) string {
+	if opts.BaselineProfile != "" || c.baseline == nil {
+		return opts.BaselineProfile
+	}
+	profile, err := c.PullBaseline(ctx, opts.TestName)
+	if err != nil {
+		c.logf("⚠️  Skipping baseline comparison: %v\n", err)
+		return ""
+	}
+	return profile
+}
@@ -0,0 +1,87 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// pushTestBaseline stores a coverage artifact like PushCoverageArtifact does
+func pushTestBaseline(t *testing.T, dst oras.Target, tag, profile string) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "coverage_filtered.out"), []byte(profile), 0644)
+
+	fs, err := file.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	desc, err := fs.Add(ctx, "coverage_filtered.out", "application/vnd.acme.rocket.docs.layer.v1+tar", "coverage_filtered.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := oras.PackManifest(ctx, fs, oras.PackManifestVersion1_1_RC4, "application/vnd.acme.rocket.config",
+		oras.PackManifestOptions{Layers: []ocispec.Descriptor{desc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Tag(ctx, manifest, tag); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oras.Copy(ctx, fs, tag, dst, tag, oras.DefaultCopyOptions); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPullBaseline(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+	ctx := context.Background()
+
+	store, err := oci.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := "mode: atomic\ngithub.com/example/app/main.go:10.2,12.3 2 0\n"
+	pushTestBaseline(t, store, "e2e-main-20250101", old)
+	pushTestBaseline(t, store, "e2e-main-20250201", testProfile)
+	pushTestBaseline(t, store, "e2e-pr-20250301", old)
+
+	outputDir := t.TempDir()
+	client := &CoverageClient{outputDir: outputDir}
+	path, err := client.pullBaselineFrom(ctx, store, "e2e-main-*")
+	if err != nil {
+		t.Fatalf("pullBaselineFrom failed: %v", err)
+	}
+	if path != filepath.Join(outputDir, ".baseline", "e2e-main-20250201", "coverage_filtered.out") {
+		t.Errorf("Unexpected baseline path: %s", path)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != testProfile {
+		t.Errorf("Expected the latest main baseline, got:\n%s", data)
+	}
+
+	if _, err := client.pullBaselineFrom(ctx, store, "e2e-release-*"); err == nil || !strings.Contains(err.Error(), "no baseline tag matches") {
+		t.Errorf("Expected no match error, got %v", err)
+	}
+
+	// Reports compare against the pulled baseline when none is given
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(old), 0644)
+	client.SetBaseline(BaselineOptions{Registry: "registry.invalid", Repository: "org/coverage", TagPattern: "{test}-main-20250201"})
+	report, err := client.BuildCoverageReport(ctx, CoverageReportOptions{TestName: "e2e"})
+	if err != nil {
+		t.Fatalf("BuildCoverageReport failed: %v", err)
+	}
+	if d, ok := report.Delta(); !ok || d > -66 {
+		t.Errorf("Expected a delta against the cached baseline, got %v (%v)", d, ok)
+	}
+}
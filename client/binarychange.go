@@ -0,0 +1,89 @@
+package coverageclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// separateEarlierBinaries keeps the coverage of a test directory mergeable when the application
+// was restarted with a different binary between collections. Binaries whose meta-data sits in
+// testDir but that the collection (which saved the written files) didn't bring are earlier builds:
+// their covdata is moved into a subdirectory named after their meta hash, where it stays available
+// without being merged with the current binary's. Collections from several binaries at once (e.g.
+// processes sharing GOCOVERDIR) bring all of them and are left alone.
+func (c *CoverageClient) separateEarlierBinaries(testDir string, written []string) error {
+	c.savedFiles.add(testDir, written)
+
+	var collected []string
+	for _, g := range GroupCovdataFiles(written) {
+		collected = append(collected, g.Hash)
+	}
+	if len(collected) == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		return fmt.Errorf("read coverage directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	for _, g := range GroupCovdataFiles(names) {
+		if g.Meta == "" || slices.Contains(collected, g.Hash) {
+			continue
+		}
+		dir := filepath.Join(testDir, g.Hash)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create directory for earlier binary: %w", err)
+		}
+		for _, name := range append([]string{g.Meta}, g.Counters...) {
+			if err := os.Rename(filepath.Join(testDir, name), filepath.Join(dir, name)); err != nil {
+				return fmt.Errorf("move coverage of earlier binary: %w", err)
+			}
+		}
+		c.logf("  ⚠️  Binary changed since the previous collection (meta %s -> %v), earlier coverage moved to %s\n", g.Hash, collected, dir)
+	}
+	return nil
+}
+
+// earlierBinaries returns the meta hashes of the earlier binaries separateEarlierBinaries moved
+// into subdirectories of testDir
+func earlierBinaries(testDir string) []string {
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		return nil
+	}
+	var hashes []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !isMetaHash(entry.Name()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(testDir, entry.Name(), "covmeta."+entry.Name())); err == nil {
+			hashes = append(hashes, entry.Name())
+		}
+	}
+	return hashes
+}
+
+// binaryChangeWarnings describes the earlier binaries of testDir for metadata.json
+func binaryChangeWarnings(testDir string) []string {
+	var warnings []string
+	for _, hash := range earlierBinaries(testDir) {
+		warnings = append(warnings, fmt.Sprintf("binary changed between collections: coverage of earlier binary %s kept apart in %s/", hash, hash))
+	}
+	return warnings
+}
+
+// isMetaHash reports whether name is a meta-data hash as used in covdata file names
+func isMetaHash(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == 16
+}
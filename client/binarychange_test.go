@@ -0,0 +1,90 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	oldBinaryHash = "0123456789abcdef0123456789abcdef"
+	newBinaryHash = "fedcba9876543210fedcba9876543210"
+)
+
+// covdataResponse is a JSON coverage response of one snapshot of the binary with the given hash
+func covdataResponse(hash, counters string) string {
+	return `{"meta_filename":"covmeta.` + hash + `","meta_data":"bWV0YQ==",` +
+		`"counters_filename":"` + counters + `","counters_data":"Y291bnRlcnM="}`
+}
+
+func TestSeparateEarlierBinaries(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir()}
+	testDir := filepath.Join(client.outputDir, "e2e")
+
+	for _, counters := range []string{"covcounters." + oldBinaryHash + ".1.1", "covcounters." + oldBinaryHash + ".1.2"} {
+		if err := client.saveCoverageResponse(strings.NewReader(covdataResponse(oldBinaryHash, counters)), "e2e"); err != nil {
+			t.Fatalf("saveCoverageResponse failed: %v", err)
+		}
+	}
+	if hashes := earlierBinaries(testDir); len(hashes) != 0 {
+		t.Fatalf("Expected snapshots of the same binary to stay together, got earlier binaries %v", hashes)
+	}
+
+	// The application restarted with a new build
+	response := covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.3")
+	if err := client.saveCoverageResponse(strings.NewReader(response), "e2e"); err != nil {
+		t.Fatalf("saveCoverageResponse failed: %v", err)
+	}
+
+	for _, name := range []string{"covmeta." + newBinaryHash, "covcounters." + newBinaryHash + ".1.3",
+		filepath.Join(oldBinaryHash, "covmeta."+oldBinaryHash),
+		filepath.Join(oldBinaryHash, "covcounters."+oldBinaryHash+".1.1"),
+		filepath.Join(oldBinaryHash, "covcounters."+oldBinaryHash+".1.2")} {
+		if _, err := os.Stat(filepath.Join(testDir, name)); err != nil {
+			t.Errorf("Expected %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "covmeta."+oldBinaryHash)); err == nil {
+		t.Error("Expected the earlier binary's meta-data to leave the test directory")
+	}
+
+	warnings := binaryChangeWarnings(testDir)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], oldBinaryHash) {
+		t.Errorf("Expected a warning about %s, got %v", oldBinaryHash, warnings)
+	}
+}
+
+func TestSeparateEarlierBinaries_SharedCoverDir(t *testing.T) {
+	testDir := t.TempDir()
+	names := []string{"covmeta." + oldBinaryHash, "covcounters." + oldBinaryHash + ".1.1",
+		"covmeta." + newBinaryHash, "covcounters." + newBinaryHash + ".2.1"}
+	for _, name := range names {
+		os.WriteFile(filepath.Join(testDir, name), []byte("data"), 0644)
+	}
+
+	// Both binaries collected again, and a collection without covdata
+	client := &CoverageClient{}
+	for _, written := range [][]string{names, {"metadata.json"}} {
+		if err := client.separateEarlierBinaries(testDir, written); err != nil {
+			t.Fatalf("separateEarlierBinaries failed: %v", err)
+		}
+	}
+
+	if entries, _ := os.ReadDir(testDir); len(entries) != len(names) {
+		t.Errorf("Expected binaries sharing GOCOVERDIR to stay in place, got %d entries", len(entries))
+	}
+}
+
+func TestIsMetaHash(t *testing.T) {
+	for name, want := range map[string]bool{
+		oldBinaryHash:                      true,
+		"e2e":                              false,
+		"abc":                              false,
+		"0123456789abcdef0123456789abcdeg": false,
+	} {
+		if got := isMetaHash(name); got != want {
+			t.Errorf("isMetaHash(%q) = %v, expected %v", name, got, want)
+		}
+	}
+}
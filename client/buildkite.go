@@ -0,0 +1,96 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BuildkiteOptions configures AnnotateBuildkite
+type BuildkiteOptions struct {
+	CoverageReportOptions
+	Title      string             // Annotation heading (default: "E2E Coverage")
+	Context    string             // Annotation context; annotations with the same context are replaced (default: coverage-<test name>)
+	Thresholds CoverageThresholds // Optional: a violated threshold turns the annotation into an error
+	Artifacts  []string           // Files of the test directory to upload and link (default: coverage.html and the profile)
+	AgentPath  string             // buildkite-agent binary (default: buildkite-agent from $PATH)
+}
+
+// AnnotateBuildkite uploads the coverage report of a processed test as build artifacts and creates
+// a Buildkite annotation with the coverage summary table and links to the uploaded files.
+// It uses the buildkite-agent of the running job and returns whether the thresholds passed.
+func (c *CoverageClient) AnnotateBuildkite(ctx context.Context, opts BuildkiteOptions) (bool, error) {
+	if opts.Title == "" {
+		opts.Title = "E2E Coverage"
+	}
+	if opts.Context == "" {
+		opts.Context = "coverage-" + sanitizeName(opts.TestName)
+	}
+	if opts.AgentPath == "" {
+		opts.AgentPath = "buildkite-agent"
+	}
+	if opts.Artifacts == nil {
+		opts.Artifacts = []string{"coverage.html", filepath.Base(c.profilePath(opts.TestName))}
+	}
+
+	report, err := c.BuildCoverageReport(ctx, opts.CoverageReportOptions)
+	if err != nil {
+		return false, err
+	}
+	failures := opts.Thresholds.Check(report)
+
+	// Artifacts are uploaded relative to the output directory, which makes <test>/<file> their artifact path
+	var links []string
+	for _, name := range opts.Artifacts {
+		artifact := path.Join(opts.TestName, filepath.ToSlash(name))
+		if _, err := os.Stat(filepath.Join(c.outputDir, filepath.FromSlash(artifact))); err != nil {
+			c.logf("⚠️  Skipping Buildkite artifact %s: %v\n", artifact, err)
+			continue
+		}
+		if err := runBuildkiteAgent(ctx, opts.AgentPath, c.outputDir, nil, "artifact", "upload", artifact); err != nil {
+			return false, fmt.Errorf("upload artifact %s: %w", artifact, err)
+		}
+		links = append(links, fmt.Sprintf("[%s](artifact://%s)", name, artifact))
+	}
+
+	style := "success"
+	body := RenderCoverageMarkdown(report, opts.Title)
+	if len(failures) > 0 {
+		style = "error"
+		body += "\n**Coverage thresholds failed:**\n\n"
+		for _, failure := range failures {
+			body += "- " + failure + "\n"
+		}
+	}
+	if len(links) > 0 {
+		body += "\n" + strings.Join(links, " · ") + "\n"
+	}
+
+	if err := runBuildkiteAgent(ctx, opts.AgentPath, c.outputDir, strings.NewReader(body),
+		"annotate", "--style", style, "--context", opts.Context); err != nil {
+		return false, fmt.Errorf("create annotation: %w", err)
+	}
+
+	c.logf("📝 Buildkite annotation %s created (%d artifact(s))\n", opts.Context, len(links))
+	return len(failures) == 0, nil
+}
+
+// runBuildkiteAgent runs a buildkite-agent subcommand in dir
+func runBuildkiteAgent(ctx context.Context, agentPath, dir string, stdin *strings.Reader, args ...string) error {
+	cmd := exec.CommandContext(ctx, agentPath, args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("buildkite-agent %s: %w\nOutput: %s", args[0], err, stderr.String())
+	}
+	return nil
+}
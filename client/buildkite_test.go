@@ -0,0 +1,58 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestAnnotateBuildkite(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake buildkite-agent is a shell script")
+	}
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	// Fake agent recording its arguments and the annotation body
+	logDir := t.TempDir()
+	agent := filepath.Join(logDir, "buildkite-agent")
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(logDir, "calls") + "\n" +
+		"if [ \"$1\" = annotate ]; then cat > " + filepath.Join(logDir, "body") + "; fi\n"
+	if err := os.WriteFile(agent, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &CoverageClient{outputDir: outputDir}
+	passed, err := client.AnnotateBuildkite(context.Background(), BuildkiteOptions{
+		CoverageReportOptions: CoverageReportOptions{TestName: "e2e"},
+		Thresholds:            CoverageThresholds{MinTotal: 80},
+		AgentPath:             agent,
+	})
+	if err != nil {
+		t.Fatalf("AnnotateBuildkite failed: %v", err)
+	}
+	if passed {
+		t.Error("Expected thresholds to fail at 66.7%")
+	}
+
+	calls, _ := os.ReadFile(filepath.Join(logDir, "calls"))
+	// coverage.html does not exist and is skipped
+	expected := "artifact upload e2e/coverage.out\nannotate --style error --context coverage-e2e\n"
+	if string(calls) != expected {
+		t.Errorf("Unexpected agent calls:\n%s", calls)
+	}
+
+	body, _ := os.ReadFile(filepath.Join(logDir, "body"))
+	for _, want := range []string{"66.7% (6/9)", "Coverage thresholds failed", "[coverage.out](artifact://e2e/coverage.out)"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Annotation missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package coverageclient
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// tektonStepDir exists in every Tekton step container
+var tektonStepDir = "/tekton"
+
+// CIMetadata describes the CI build that collected coverage
+type CIMetadata struct {
+	Provider    string `json:"provider"` // github-actions, gitlab, prow, jenkins, buildkite or tekton
+	Job         string `json:"job,omitempty"`
+	BuildID     string `json:"build_id,omitempty"`
+	BuildURL    string `json:"build_url,omitempty"`
+	Repository  string `json:"repository,omitempty"`
+	PullRequest int    `json:"pull_request,omitempty"` // PR/MR number, 0 for non-PR builds
+	Branch      string `json:"branch,omitempty"`
+	Commit      string `json:"commit,omitempty"`
+}
+
+// DetectCI detects the CI provider from its environment variables and returns the build's
+// provenance, or nil when not running in a known CI system. It is recorded in metadata.json
+// and the annotations of pushed artifacts.
+func DetectCI() *CIMetadata {
+	ci := &CIMetadata{}
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		ci.Provider = "github-actions"
+		ci.Job = os.Getenv("GITHUB_WORKFLOW")
+		server, repo, run := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+		if server != "" && repo != "" && run != "" {
+			ci.BuildURL = server + "/" + repo + "/actions/runs/" + run
+		}
+	case os.Getenv("GITLAB_CI") == "true":
+		ci.Provider = "gitlab"
+		ci.Job = os.Getenv("CI_JOB_NAME")
+		ci.BuildID = os.Getenv("CI_JOB_ID")
+		ci.BuildURL = os.Getenv("CI_JOB_URL")
+		ci.Repository = os.Getenv("CI_PROJECT_PATH")
+	case os.Getenv("PROW_JOB_ID") != "":
+		// Checked before Jenkins, as both set BUILD_ID and JOB_NAME
+		ci.Provider = "prow"
+		ci.Job = os.Getenv("JOB_NAME")
+	case os.Getenv("JENKINS_URL") != "":
+		ci.Provider = "jenkins"
+		ci.Job = os.Getenv("JOB_NAME")
+		ci.BuildURL = os.Getenv("BUILD_URL")
+	case os.Getenv("BUILDKITE") == "true":
+		ci.Provider = "buildkite"
+		ci.Job = os.Getenv("BUILDKITE_PIPELINE_SLUG")
+		ci.BuildURL = os.Getenv("BUILDKITE_BUILD_URL")
+	default:
+		if _, err := os.Stat(tektonStepDir); err != nil {
+			return nil
+		}
+		// Tekton sets no build env vars; step pods are named <taskrun>-pod
+		ci.Provider = "tekton"
+		ci.BuildID = strings.TrimSuffix(os.Getenv("HOSTNAME"), "-pod")
+	}
+
+	if ci.BuildID == "" {
+		ci.BuildID = detectBuildID()
+	}
+	if ci.Repository == "" {
+		ci.Repository = detectGitHubRepository()
+	}
+	ci.PullRequest = detectPRNumber()
+	ci.Branch = detectBranch()
+	ci.Commit = detectCommitSHA()
+	return ci
+}
+
+// annotations returns the build provenance as OCI manifest annotations
+func (ci *CIMetadata) annotations() map[string]string {
+	annotations := map[string]string{AnnotationPrefix + "ci.provider": ci.Provider}
+	set := func(key, value string) {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+	set(AnnotationPrefix+"ci.job", ci.Job)
+	set(AnnotationPrefix+"ci.build-id", ci.BuildID)
+	set(AnnotationPrefix+"ci.build-url", ci.BuildURL)
+	set(AnnotationPrefix+"ci.branch", ci.Branch)
+	set(ocispec.AnnotationRevision, ci.Commit)
+	if ci.PullRequest != 0 {
+		annotations[AnnotationPrefix+"ci.pull-request"] = strconv.Itoa(ci.PullRequest)
+	}
+	if server := os.Getenv("GITHUB_SERVER_URL"); ci.Provider == "github-actions" && server != "" && ci.Repository != "" {
+		annotations[ocispec.AnnotationSource] = server + "/" + ci.Repository
+	}
+	return annotations
+}
@@ -0,0 +1,116 @@
+package coverageclient
+
+import (
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// clearCIEnv unsets the env vars DetectCI reads, so tests behave the same inside CI
+func clearCIEnv(t *testing.T) {
+	for _, name := range []string{
+		"GITHUB_ACTIONS", "GITHUB_WORKFLOW", "GITHUB_SERVER_URL", "GITHUB_REPOSITORY", "GITHUB_RUN_ID", "GITHUB_REF",
+		"GITHUB_REF_NAME", "GITHUB_HEAD_REF", "GITHUB_SHA", "GITHUB_EVENT_PATH",
+		"GITLAB_CI", "CI_JOB_NAME", "CI_JOB_ID", "CI_JOB_URL", "CI_PROJECT_PATH", "CI_PIPELINE_ID", "CI_COMMIT_SHA",
+		"CI_COMMIT_REF_NAME", "CI_MERGE_REQUEST_IID", "CI_MERGE_REQUEST_SOURCE_BRANCH_NAME",
+		"PROW_JOB_ID", "JOB_NAME", "BUILD_ID", "REPO_OWNER", "REPO_NAME", "PULL_NUMBER", "PULL_PULL_SHA",
+		"PULL_HEAD_REF", "PULL_BASE_REF",
+		"JENKINS_URL", "BUILD_URL", "BUILD_NUMBER", "CHANGE_ID", "CHANGE_BRANCH", "BRANCH_NAME", "GIT_COMMIT",
+		"BUILDKITE", "BUILDKITE_PIPELINE_SLUG", "BUILDKITE_BUILD_URL", "BUILDKITE_BUILD_NUMBER", "BUILDKITE_BRANCH",
+		"BUILDKITE_COMMIT", "BUILDKITE_PULL_REQUEST",
+	} {
+		t.Setenv(name, "")
+	}
+	dir := tektonStepDir
+	tektonStepDir = filepath.Join(t.TempDir(), "tekton")
+	t.Cleanup(func() { tektonStepDir = dir })
+}
+
+func TestDetectCI(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		clearCIEnv(t)
+		if ci := DetectCI(); ci != nil {
+			t.Errorf("Expected no CI, got %+v", ci)
+		}
+	})
+
+	t.Run("github actions", func(t *testing.T) {
+		clearCIEnv(t)
+		t.Setenv("GITHUB_ACTIONS", "true")
+		t.Setenv("GITHUB_WORKFLOW", "e2e")
+		t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+		t.Setenv("GITHUB_REPOSITORY", "org/app")
+		t.Setenv("GITHUB_RUN_ID", "42")
+		t.Setenv("GITHUB_REF", "refs/pull/7/merge")
+		t.Setenv("GITHUB_HEAD_REF", "feature")
+		t.Setenv("GITHUB_SHA", "abc123")
+
+		ci := DetectCI()
+		expected := CIMetadata{
+			Provider: "github-actions", Job: "e2e", BuildID: "42", BuildURL: "https://github.com/org/app/actions/runs/42",
+			Repository: "org/app", PullRequest: 7, Branch: "feature", Commit: "abc123",
+		}
+		if ci == nil || *ci != expected {
+			t.Fatalf("Expected %+v, got %+v", expected, ci)
+		}
+
+		annotations := ci.annotations()
+		if annotations[ocispec.AnnotationSource] != "https://github.com/org/app" || annotations[ocispec.AnnotationRevision] != "abc123" ||
+			annotations[AnnotationPrefix+"ci.pull-request"] != "7" || annotations[AnnotationPrefix+"ci.build-url"] != expected.BuildURL {
+			t.Errorf("Unexpected annotations: %v", annotations)
+		}
+	})
+
+	t.Run("gitlab", func(t *testing.T) {
+		clearCIEnv(t)
+		t.Setenv("GITLAB_CI", "true")
+		t.Setenv("CI_JOB_NAME", "e2e")
+		t.Setenv("CI_JOB_ID", "1001")
+		t.Setenv("CI_JOB_URL", "https://gitlab.com/org/app/-/jobs/1001")
+		t.Setenv("CI_PROJECT_PATH", "org/app")
+		t.Setenv("CI_MERGE_REQUEST_IID", "12")
+		t.Setenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "feature")
+		t.Setenv("CI_COMMIT_SHA", "def456")
+
+		ci := DetectCI()
+		expected := CIMetadata{
+			Provider: "gitlab", Job: "e2e", BuildID: "1001", BuildURL: "https://gitlab.com/org/app/-/jobs/1001",
+			Repository: "org/app", PullRequest: 12, Branch: "feature", Commit: "def456",
+		}
+		if ci == nil || *ci != expected {
+			t.Fatalf("Expected %+v, got %+v", expected, ci)
+		}
+	})
+
+	t.Run("prow", func(t *testing.T) {
+		clearCIEnv(t)
+		t.Setenv("PROW_JOB_ID", "uuid")
+		t.Setenv("JOB_NAME", "pull-app-e2e")
+		t.Setenv("BUILD_ID", "1234")
+		t.Setenv("REPO_OWNER", "org")
+		t.Setenv("REPO_NAME", "app")
+		t.Setenv("PULL_NUMBER", "3")
+		t.Setenv("PULL_BASE_REF", "main")
+		t.Setenv("PULL_PULL_SHA", "fff000")
+
+		ci := DetectCI()
+		expected := CIMetadata{
+			Provider: "prow", Job: "pull-app-e2e", BuildID: "1234", Repository: "org/app", PullRequest: 3, Branch: "main", Commit: "fff000",
+		}
+		if ci == nil || *ci != expected {
+			t.Fatalf("Expected %+v, got %+v", expected, ci)
+		}
+	})
+
+	t.Run("tekton", func(t *testing.T) {
+		clearCIEnv(t)
+		tektonStepDir = t.TempDir()
+		t.Setenv("HOSTNAME", "app-e2e-run-pod")
+
+		ci := DetectCI()
+		if ci == nil || ci.Provider != "tekton" || ci.BuildID != "app-e2e-run" {
+			t.Errorf("Unexpected Tekton metadata: %+v", ci)
+		}
+	})
+}
@@ -3,15 +3,18 @@ package coverageclient
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,28 +25,63 @@ import (
 	"oras.land/oras-go/v2/registry/remote/credentials"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/tools/remotecommand"
-	"k8s.io/client-go/transport/spdy"
 )
 
 // CoverageClient handles coverage collection from Kubernetes pods
 type CoverageClient struct {
 	clientset       kubernetes.Interface
+	dynamicClient   dynamic.Interface // Used for OpenShift Routes
 	restConfig      *rest.Config
 	namespace       string
 	outputDir       string
 	httpClient      *http.Client
-	defaultFilters  []string // Default file patterns to filter out from coverage
-	sourceDir       string   // Local source directory for path remapping
-	enablePathRemap bool     // Whether to automatically remap container paths
+	retryPolicy     RetryPolicy                  // Retries of port-forward collections, see SetRetryPolicy
+	healthProbe     HealthProbeOptions           // How collections wait for the server behind a port-forward, see SetHealthProbe
+	defaultFilters  []string                     // Default file patterns to filter out from coverage
+	packages        []string                     // Package selectors applied by covdata before the text report (nil: all)
+	nativeCovdata   bool                         // Convert covdata without go tool covdata, see SetNativeCovdata
+	sourceDir       string                       // Local source directory for path remapping
+	enablePathRemap bool                         // Whether to automatically remap container paths
+	pathRules       map[string]string            // Explicit remapping rules by directory prefix, see SetPathMappings
+	disableExec     bool                         // Never exec into containers (no pods/exec RBAC)
+	portForwardOnly bool                         // Only collect via port-forwarding
+	meshHTTPClient  *http.Client                 // mTLS client for calling through a service mesh sidecar
+	recordDisabled  bool                         // Don't record Events/annotations on pods after collection
+	baseline        *BaselineOptions             // Where to pull baseline coverage from when reports don't name one
+	port            int                          // Coverage port used when collections name none (0: DefaultCoveragePort)
+	thresholds      CoverageThresholds           // Gate rules used when a GateConfig names none, see SetThresholds
+	artifact        *PushCoverageArtifactOptions // Where to push artifacts when the options name no registry, see SetArtifactDestination
+	maxResponseSize int64                        // Coverage response size guard in bytes (0: default, negative: unlimited)
+	minFreeSpace    int64                        // Free space to leave in the output directory in bytes (0: default, negative: unchecked)
+	lockDisabled    bool                         // Don't lock test directories, see SetOutputLocking
+	lockTimeout     time.Duration                // Wait for test directories locked by other processes (0: default)
+	portForward     *portForwardTransport        // TLS configuration shared by port-forward dialers
+	portForwarder   PortForwarder                // Opens port-forwards (nil: through the API server), see SetPortForwarder
+	executor        CommandExecutor              // Runs commands in containers (nil: exec through the API server), see SetCommandExecutor
+	counterBases    *counterBases                // Counters received from coverage servers, for incremental transfers
+	metaCache       *metaCache                   // Meta-data saved by earlier collections, for counters-only transfers
+	savedFiles      *savedFiles                  // Covdata files saved by the collections in progress, for their results
+	fullCounters    bool                         // Always request counters in full, see SetIncrementalTransfer
+	sources         *sourceIndex                 // Go modules of the source directory, for path remapping
+	eventHandlers   []EventHandler               // Receive workflow events, see AddEventHandler
+	collectors      map[string]Collector         // Backends registered with RegisterCollector
+	logger          Logger                       // Receives progress output (nil: stdout), see SetLogger
+	quiet           bool                         // Drop progress output, see SetQuiet
+	logLevel        Level                        // Minimum level of progress output, see SetLogLevel
 }
 
+// CoverageStreamType is the media type of streamed coverage responses (one part per coverage file),
+// requested from servers that support it instead of base64-encoded JSON
+const CoverageStreamType = "multipart/mixed"
+
 // CoverageResponse matches the server's response format
 type CoverageResponse struct {
 	MetaFilename     string `json:"meta_filename"`
@@ -54,14 +92,24 @@ type CoverageResponse struct {
 	Timestamp        int64  `json:"timestamp"`
 }
 
+// PodMetadataSchemaVersion is the schema_version of the metadata.json files this client writes. It
+// changes when fields are renamed or change meaning, not when fields are added.
+const PodMetadataSchemaVersion = 1
+
 // PodMetadata contains information about the pod from which coverage was collected
 type PodMetadata struct {
-	PodName      string            `json:"pod_name"`
-	Namespace    string            `json:"namespace"`
-	Container    ContainerMetadata `json:"container"`
-	CollectedAt  string            `json:"collected_at"`
-	TestName     string            `json:"test_name"`
-	CoveragePort int               `json:"coverage_port"`
+	SchemaVersion    int               `json:"schema_version"` // 0 for files written before versioning, read like version 1
+	PodName          string            `json:"pod_name"`
+	Namespace        string            `json:"namespace"`
+	Container        ContainerMetadata `json:"container"`
+	CollectedAt      string            `json:"collected_at"`
+	TestName         string            `json:"test_name"`
+	CoveragePort     int               `json:"coverage_port"`
+	CollectionMethod CollectionMethod  `json:"collection_method,omitempty"` // Transport used to fetch the coverage data
+	CI               *CIMetadata       `json:"ci,omitempty"`                // CI build that collected the coverage, if detected
+	Server           *ServerInfo       `json:"server,omitempty"`            // Build of the coverage server, from its /coverage/info
+	Source           *SourceMetadata   `json:"source,omitempty"`            // Git state of the test's source checkout
+	Warnings         []string          `json:"warnings,omitempty"`          // Problems with the collected data, e.g. a binary change between collections
 }
 
 // ContainerMetadata contains information about a container in the pod
@@ -70,26 +118,21 @@ type ContainerMetadata struct {
 	Image string `json:"image"`
 }
 
-// NewClient creates a new coverage client for the given namespace
+// NewClient creates a new coverage client for the given namespace, using the current kubeconfig context.
+// COVERAGE_* environment variables (see the README) override the client's defaults.
 func NewClient(namespace, outputDir string) (*CoverageClient, error) {
-	// Load kubeconfig
-	kubeconfig := os.Getenv("KUBECONFIG")
-	if kubeconfig == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("get home dir: %w", err)
-		}
-		kubeconfig = filepath.Join(home, ".kube", "config")
-	}
+	return NewClientForContext("", namespace, outputDir)
+}
 
-	// Build config from kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+// NewClientForContext creates a new coverage client for the given kubeconfig context and namespace.
+// An empty context selects $COVERAGE_KUBE_CONTEXT or the kubeconfig's current context, and an
+// empty namespace $COVERAGE_NAMESPACE.
+func NewClientForContext(kubeContext, namespace, outputDir string) (*CoverageClient, error) {
+	kubeContext = valueOrEnv(kubeContext, envKubeContext)
+	namespace = valueOrEnv(namespace, envNamespace)
+	config, err := loadRESTConfig(kubeContext)
 	if err != nil {
-		// Try in-cluster config
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			return nil, fmt.Errorf("build kubernetes config: %w", err)
-		}
+		return nil, err
 	}
 
 	// Create clientset
@@ -98,6 +141,29 @@ func NewClient(namespace, outputDir string) (*CoverageClient, error) {
 		return nil, fmt.Errorf("create kubernetes client: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamic client: %w", err)
+	}
+
+	c, err := NewLocalClient(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	c.clientset = clientset
+	c.dynamicClient = dynamicClient
+	c.restConfig = config
+	c.namespace = namespace
+	return c, nil
+}
+
+// NewLocalClient creates a coverage client without cluster access, for processing, gating and
+// pushing coverage that was already collected into outputDir (default: $COVERAGE_OUTPUT_DIR or
+// coverage-output). COVERAGE_* environment variables (see the README) override the client's defaults.
+func NewLocalClient(outputDir string) (*CoverageClient, error) {
+	if outputDir = valueOrEnv(outputDir, envOutputDir); outputDir == "" {
+		outputDir = defaultOutputDir
+	}
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return nil, fmt.Errorf("create output directory: %w", err)
@@ -109,16 +175,22 @@ func NewClient(namespace, outputDir string) (*CoverageClient, error) {
 		cwd = "."
 	}
 
-	return &CoverageClient{
-		clientset:       clientset,
-		restConfig:      config,
-		namespace:       namespace,
+	c := &CoverageClient{
 		outputDir:       outputDir,
 		httpClient:      &http.Client{Timeout: 30 * time.Second},
 		defaultFilters:  []string{"coverage_server.go"}, // Default: filter out the coverage server itself
 		sourceDir:       cwd,
 		enablePathRemap: true, // Default: enable automatic path remapping
-	}, nil
+		portForward:     &portForwardTransport{},
+		counterBases:    &counterBases{},
+		metaCache:       &metaCache{},
+		savedFiles:      &savedFiles{},
+		sources:         &sourceIndex{},
+	}
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 // SetDefaultFilters configures which files to automatically filter from coverage reports
@@ -131,6 +203,14 @@ func (c *CoverageClient) AddDefaultFilter(pattern string) {
 	c.defaultFilters = append(c.defaultFilters, pattern)
 }
 
+// SetPackageSelectors restricts coverage reports to the packages matching the selectors. Packages
+// are selected in the coverage data before the text report is generated, so totals and package
+// percentages only count selected packages. Selectors are import path patterns with "..." wildcards
+// (e.g. "github.com/org/app/..."); a selector prefixed with "!" excludes matching packages.
+func (c *CoverageClient) SetPackageSelectors(selectors []string) {
+	c.packages = selectors
+}
+
 // SetSourceDirectory sets the local source directory for path remapping
 func (c *CoverageClient) SetSourceDirectory(dir string) {
 	c.sourceDir = dir
@@ -149,7 +229,7 @@ func (c *CoverageClient) GetPodName(labelSelector string) (string, error) {
 
 // GetPodNameWithContext discovers a pod name with context support
 func (c *CoverageClient) GetPodNameWithContext(ctx context.Context, labelSelector string) (string, error) {
-	fmt.Printf("🔍 Discovering pod with label selector: %s\n", labelSelector)
+	c.logf("🔍 Discovering pod with label selector: %s\n", labelSelector)
 
 	// List pods with the label selector
 	pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{
@@ -160,65 +240,77 @@ func (c *CoverageClient) GetPodNameWithContext(ctx context.Context, labelSelecto
 	}
 
 	if len(pods.Items) == 0 {
-		return "", fmt.Errorf("no pods found with label selector '%s' in namespace '%s'", labelSelector, c.namespace)
+		return "", fmt.Errorf("%w with label selector '%s' in namespace '%s'", ErrNoPodsFound, labelSelector, c.namespace)
 	}
 
 	// Find the first running pod
 	for _, pod := range pods.Items {
 		if pod.Status.Phase == corev1.PodRunning {
-			fmt.Printf("✅ Found running pod: %s\n", pod.Name)
+			c.logf("✅ Found running pod: %s\n", pod.Name)
 			return pod.Name, nil
 		}
 	}
 
 	// If no running pod found, return first pod with its status
 	firstPod := pods.Items[0]
-	return "", fmt.Errorf("no running pod found (first pod '%s' is in phase '%s')", firstPod.Name, firstPod.Status.Phase)
+	return "", fmt.Errorf("%w (first pod '%s' is in phase '%s')", ErrPodNotRunning, firstPod.Name, firstPod.Status.Phase)
 }
 
 // CollectCoverageFromPod collects coverage data from a pod via port-forwarding
-func (c *CoverageClient) CollectCoverageFromPod(ctx context.Context, podName, testName string, targetPort int) error {
+func (c *CoverageClient) CollectCoverageFromPod(ctx context.Context, podName, testName string, targetPort int) (*CollectResult, error) {
 	return c.CollectCoverageFromPodWithContainer(ctx, podName, "", testName, targetPort)
 }
 
 // CollectCoverageFromPodWithContainer collects coverage data from a specific container in a pod via port-forwarding
-// If containerName is empty, it will try to detect the correct container automatically
-func (c *CoverageClient) CollectCoverageFromPodWithContainer(ctx context.Context, podName, containerName, testName string, targetPort int) error {
-	fmt.Printf("📊 Collecting coverage from pod %s for test: %s\n", podName, testName)
+// If containerName is empty, it will try to detect the correct container automatically.
+// Collect does the same with CollectOptions{PodName, Container, Port}, and adds label selectors,
+// retries, counter resets and output layouts.
+func (c *CoverageClient) CollectCoverageFromPodWithContainer(ctx context.Context, podName, containerName, testName string, targetPort int) (*CollectResult, error) {
+	return c.collectWithEvents(ctx, testName, podName, 1, func(ctx context.Context) (*CollectResult, error) {
+		if err := c.collectFromPodWithContainer(ctx, podName, containerName, testName, targetPort); err != nil {
+			return nil, err
+		}
+		return c.collectResult(testName, MethodPortForward), nil
+	})
+}
 
-	// Setup port forwarding
-	localPort, stopChan, err := c.setupPortForward(podName, targetPort)
-	if err != nil {
-		return fmt.Errorf("setup port forward: %w", err)
-	}
-	defer close(stopChan)
+// collectFromPodWithContainer collects like CollectCoverageFromPodWithContainer, without events
+func (c *CoverageClient) collectFromPodWithContainer(ctx context.Context, podName, containerName, testName string, targetPort int) (err error) {
+	ctx, step := startStep(ctx, "collect", attribute.String("pod", podName), attribute.String("test", testName))
+	defer func() { step.end(err) }()
+	ctx = withServerInfoSlot(ctx)
 
-	// Wait a bit for port forward to be ready
-	time.Sleep(2 * time.Second)
+	c.logf("📊 Collecting coverage from pod %s for test: %s\n", podName, testName)
 
-	// Collect coverage via HTTP
-	coverageURL := fmt.Sprintf("http://localhost:%d/coverage", localPort)
-	if err := c.collectCoverageFromURL(coverageURL, testName); err != nil {
-		return fmt.Errorf("collect coverage: %w", err)
+	// Collect coverage via HTTP through a port-forward
+	if err := c.collectViaPortForward(ctx, podName, testName, targetPort); err != nil {
+		var tunnelErr *tunnelError
+		if errors.As(err, &tunnelErr) {
+			return tunnelErr.err
+		}
+		return fmt.Errorf("collect coverage: %w", c.diagnoseMeshFailure(ctx, podName, targetPort, err))
 	}
 
 	// Get pod metadata and save it
-	if err := c.savePodMetadata(ctx, podName, containerName, testName, targetPort); err != nil {
+	if err := c.savePodMetadata(ctx, podName, containerName, testName, targetPort, MethodPortForward); err != nil {
 		// Log warning but don't fail the coverage collection
-		fmt.Printf("⚠️  Failed to save pod metadata: %v\n", err)
+		c.logf("⚠️  Failed to save pod metadata: %v\n", err)
 	}
+	c.recordCollection(ctx, podName, testName, MethodPortForward)
 
-	fmt.Printf("✅ Coverage collected successfully for test: %s\n", testName)
+	c.logf("✅ Coverage collected successfully for test: %s\n", testName)
 	return nil
 }
 
-// CollectCoverageFromURL collects coverage data from a direct URL (no port-forwarding)
+// CollectCoverageFromURL collects coverage data from a direct URL (no port-forwarding), see also
+// Collect with CollectOptions{URL}
 func (c *CoverageClient) CollectCoverageFromURL(coverageURL, testName string) error {
-	return c.collectCoverageFromURL(coverageURL, testName)
+	_, err := (&URLCollector{Client: c}).collectWithEvents(context.Background(), coverageURL, testName)
+	return err
 }
 
 // savePodMetadata retrieves pod information and saves it to metadata.json
-func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, containerName, testName string, targetPort int) error {
+func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, containerName, testName string, targetPort int, method CollectionMethod) error {
 	// Get pod details
 	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
@@ -235,7 +327,7 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 					Name:  container.Name,
 					Image: container.Image,
 				}
-				fmt.Printf("  🔍 Using specified container: %s (image: %s)\n", container.Name, container.Image)
+				c.logf("  🔍 Using specified container: %s (image: %s)\n", container.Name, container.Image)
 				break
 			}
 		}
@@ -251,7 +343,7 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 						Name:  container.Name,
 						Image: container.Image,
 					}
-					fmt.Printf("  🔍 Detected coverage container: %s (image: %s)\n", container.Name, container.Image)
+					c.logf("  🔍 Detected coverage container: %s (image: %s)\n", container.Name, container.Image)
 					break
 				}
 			}
@@ -262,8 +354,8 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 
 		// If no container explicitly exposes the port, try to detect by checking which one is listening
 		if coverageContainer == nil {
-			fmt.Printf("  🔍 Port %d not in container specs, detecting by checking listeners...\n", targetPort)
-			detectedContainer := c.detectContainerByPort(ctx, podName, pod.Spec.Containers, targetPort)
+			c.logf("  🔍 Port %d not in container specs, asking the coverage server...\n", targetPort)
+			detectedContainer := c.detectContainerByPort(ctx, pod, targetPort)
 			if detectedContainer != "" {
 				for _, container := range pod.Spec.Containers {
 					if container.Name == detectedContainer {
@@ -271,7 +363,7 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 							Name:  container.Name,
 							Image: container.Image,
 						}
-						fmt.Printf("  🔍 Detected container listening on port %d: %s (image: %s)\n", targetPort, container.Name, container.Image)
+						c.logf("  🔍 Detected container serving port %d: %s (image: %s)\n", targetPort, container.Name, container.Image)
 						break
 					}
 				}
@@ -281,7 +373,7 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 		// Final fallback: use first container
 		if coverageContainer == nil {
 			if len(pod.Spec.Containers) > 0 {
-				fmt.Printf("  ⚠️  Could not detect coverage container, using first container\n")
+				c.logf("  ⚠️  Could not detect coverage container, using first container\n")
 				coverageContainer = &ContainerMetadata{
 					Name:  pod.Spec.Containers[0].Name,
 					Image: pod.Spec.Containers[0].Image,
@@ -294,12 +386,18 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 
 	// Create metadata structure
 	metadata := PodMetadata{
-		PodName:      podName,
-		Namespace:    c.namespace,
-		Container:    *coverageContainer,
-		CollectedAt:  time.Now().Format(time.RFC3339),
-		TestName:     testName,
-		CoveragePort: targetPort,
+		SchemaVersion:    PodMetadataSchemaVersion,
+		PodName:          podName,
+		Namespace:        c.namespace,
+		Container:        *coverageContainer,
+		CollectedAt:      time.Now().Format(time.RFC3339),
+		TestName:         testName,
+		CoveragePort:     targetPort,
+		CollectionMethod: method,
+		CI:               DetectCI(),
+		Server:           collectedServerInfo(ctx),
+		Source:           c.gitSource(ctx),
+		Warnings:         binaryChangeWarnings(filepath.Join(c.outputDir, testName)),
 	}
 
 	// Marshal to JSON
@@ -316,48 +414,43 @@ func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, container
 		return fmt.Errorf("write metadata file: %w", err)
 	}
 
-	fmt.Printf("  📁 Saved: %s\n", metadataPath)
+	c.logf("  📁 Saved: %s\n", metadataPath)
 	return nil
 }
 
-// detectContainerByPort tries to detect which container is listening on the specified port
-func (c *CoverageClient) detectContainerByPort(ctx context.Context, podName string, containers []corev1.Container, targetPort int) string {
-	for _, container := range containers {
-		// Try to check if the port is listening in this container
-		// We'll use netstat or ss to check for listening ports
-		cmd := []string{"sh", "-c", fmt.Sprintf("netstat -tln 2>/dev/null | grep ':%d ' || ss -tln 2>/dev/null | grep ':%d '", targetPort, targetPort)}
-
-		req := c.clientset.CoreV1().RESTClient().
-			Post().
-			Resource("pods").
-			Name(podName).
-			Namespace(c.namespace).
-			SubResource("exec").
-			Param("container", container.Name).
-			Param("command", cmd[0]).
-			Param("command", cmd[1]).
-			Param("command", cmd[2]).
-			Param("stdout", "true").
-			Param("stderr", "true")
-
-		exec, err := c.createExecutor(req)
-		if err != nil {
-			continue
-		}
+// LoadPodMetadata reads the metadata.json saved into a test directory when its coverage was
+// collected from a pod. Files written by newer clients with an unknown schema version are rejected
+// rather than misread.
+func LoadPodMetadata(testDir string) (*PodMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(testDir, "metadata.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	var metadata PodMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("parse metadata: %w", err)
+	}
+	if metadata.SchemaVersion > PodMetadataSchemaVersion {
+		return nil, fmt.Errorf("parse metadata: schema version %d is newer than supported version %d, update the client", metadata.SchemaVersion, PodMetadataSchemaVersion)
+	}
+	return &metadata, nil
+}
 
-		var stdout, stderr bytes.Buffer
-		err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
-			Stdout: &stdout,
-			Stderr: &stderr,
-		})
+// readPodMetadata reads the metadata.json saved when collecting a test's coverage
+func (c *CoverageClient) readPodMetadata(testName string) (*PodMetadata, error) {
+	return LoadPodMetadata(c.testDir(testName))
+}
 
-		// If command succeeded and found the port, this is our container
-		if err == nil && stdout.Len() > 0 {
-			return container.Name
-		}
+// detectContainerByPort asks the coverage server on targetPort which container of the pod it runs
+// in, see servingContainer. The /health probe needs neither exec nor a shell in the image, so it
+// works for scratch and distroless images on any architecture. It returns "" when the server
+// doesn't answer or identify its container.
+func (c *CoverageClient) detectContainerByPort(ctx context.Context, pod *corev1.Pod, targetPort int) string {
+	header, ok := c.probeCoverageEndpoint(ctx, pod.Name, targetPort, defaultProbeTimeout)
+	if !ok {
+		return ""
 	}
-
-	return ""
+	return servingContainer(pod, header)
 }
 
 // createExecutor creates a remote command executor
@@ -369,240 +462,469 @@ func (c *CoverageClient) createExecutor(req *rest.Request) (remotecommand.Execut
 	return exec, nil
 }
 
-// setupPortForward sets up port forwarding to the pod
-func (c *CoverageClient) setupPortForward(podName string, targetPort int) (int, chan struct{}, error) {
-	// Use a local port (let the system choose)
-	localPort := 0 // 0 means let the system choose
+// collectCoverageFromURL collects coverage from the given URL
+func (c *CoverageClient) collectCoverageFromURL(ctx context.Context, coverageURL, testName string) error {
+	return c.collectCoverageFromURLWithClient(ctx, c.httpClient, coverageURL, testName)
+}
 
-	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward", c.namespace, podName)
-	hostIP := strings.TrimPrefix(c.restConfig.Host, "https://")
-	serverURL, err := url.Parse(fmt.Sprintf("https://%s%s", hostIP, path))
-	if err != nil {
-		return 0, nil, fmt.Errorf("parse server URL: %w", err)
+// collectCoverageFromURLWithClient collects coverage from the given URL using httpClient
+func (c *CoverageClient) collectCoverageFromURLWithClient(ctx context.Context, httpClient *http.Client, coverageURL, testName string) (err error) {
+	ctx, step := startStep(ctx, "collect-http", attribute.String("url", coverageURL), attribute.String("test", testName))
+	defer func() { step.end(err) }()
+
+	// A retry after the server reset its counters would lose them, so resets go in full
+	reset := counterReset(ctx)
+	err = c.requestCoverage(ctx, httpClient, coverageURL, testName, !c.fullCounters && !reset)
+	if errors.Is(err, errStaleCounterBase) || errors.Is(err, errMetaNotCached) {
+		c.logf("⚠️  %v, requesting full coverage data\n", err)
+		err = c.requestCoverage(ctx, httpClient, coverageURL, testName, false)
 	}
+	return err
+}
 
-	transport, upgrader, err := spdy.RoundTripperFor(c.restConfig)
+// requestCoverage requests coverage from the given URL and saves it to the test directory. With
+// incremental set, the request names the counters received before so only changes are sent.
+func (c *CoverageClient) requestCoverage(ctx context.Context, httpClient *http.Client, coverageURL, testName string, incremental bool) error {
+	// Prepare request body
+	reqBody, err := json.Marshal(map[string]string{
+		"test_name": testName,
+	})
 	if err != nil {
-		return 0, nil, fmt.Errorf("create round tripper: %w", err)
+		return fmt.Errorf("marshal request: %w", err)
 	}
 
-	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", serverURL)
+	// Send POST request to coverage endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, coverageURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("create coverage request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", CoverageStreamType+", application/json")
+	if bases := c.counterBases.header(); incremental && bases != "" {
+		req.Header.Set(coverageBaseHeader, bases)
+	}
+	if hashes := c.metaCache.hashes(); incremental && hashes != "" {
+		query := req.URL.Query()
+		query.Set(countersOnlyParam, hashes)
+		req.URL.RawQuery = query.Encode()
+	}
+	if counterReset(ctx) {
+		query := req.URL.Query()
+		query.Set(resetParam, "true")
+		req.URL.RawQuery = query.Encode()
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("send coverage request: %w", err)
+		}
+		return fmt.Errorf("send coverage request: %w: %w", ErrCoverageEndpointUnavailable, err)
+	}
+	defer resp.Body.Close()
 
-	stopChan := make(chan struct{}, 1)
-	readyChan := make(chan struct{})
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newCoverageEndpointError(resp.StatusCode, body)
+	}
+	if err := c.checkResponseSize(resp.ContentLength, testName); err != nil {
+		return err
+	}
+	if counterReset(ctx) && resp.Header.Get(coverageResetHeader) != "true" {
+		c.logf("⚠️  Coverage server didn't reset its counters (older server or -covermode=set)\n")
+	}
 
-	// Create port forward
-	ports := []string{fmt.Sprintf("%d:%d", localPort, targetPort)}
+	body := &countingReader{r: resp.Body}
+	defer func() { bytesReceived.Add(ctx, body.n, metric.WithAttributes(attribute.String("test", testName))) }()
 
-	out := io.Discard
-	errOut := io.Discard
+	// Servers without streaming support answer with JSON
+	if mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == CoverageStreamType {
+		return c.saveCoverageStream(body, params["boundary"], testName)
+	}
+	return c.saveCoverageResponse(body, testName)
+}
 
-	forwarder, err := portforward.New(dialer, ports, stopChan, readyChan, out, errOut)
+// saveCoverageStream copies the files of a streamed coverage response to the test directory
+// without holding them in memory
+func (c *CoverageClient) saveCoverageStream(body io.Reader, boundary, testName string) error {
+	testDir, err := c.createTestDir(testName)
 	if err != nil {
-		return 0, nil, fmt.Errorf("create port forwarder: %w", err)
+		return err
 	}
 
-	// Start port forwarding in background
-	go func() {
-		if err := forwarder.ForwardPorts(); err != nil {
-			fmt.Printf("⚠️  Port forward error: %v\n", err)
+	reader := multipart.NewReader(c.limitResponse(body), boundary)
+
+	var meta, counters bool
+	var hashes []string  // Meta-data hashes of the counters
+	var written []string // Saved file names
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read coverage stream: %w", err)
 		}
-	}()
 
-	// Wait for ready signal
-	select {
-	case <-readyChan:
-		// Get the actual local port that was assigned
-		forwardedPorts, err := forwarder.GetPorts()
-		if err != nil || len(forwardedPorts) == 0 {
-			close(stopChan)
-			return 0, nil, fmt.Errorf("get forwarded ports: %w", err)
+		name := part.FileName()
+		switch {
+		case strings.HasPrefix(name, "covmeta."):
+			meta = true
+		case strings.HasPrefix(name, "covcounters."):
+			counters = true
+			if hash := coverageFileHash(name); !slices.Contains(hashes, hash) {
+				hashes = append(hashes, hash)
+			}
+		default:
+			continue
 		}
-		actualLocalPort := int(forwardedPorts[0].Local)
-		fmt.Printf("✅ Port forward ready: localhost:%d -> pod:%d\n", actualLocalPort, targetPort)
-		return actualLocalPort, stopChan, nil
-	case <-time.After(30 * time.Second):
-		close(stopChan)
-		return 0, nil, fmt.Errorf("timeout waiting for port forward")
-	}
-}
 
-// collectCoverageFromURL collects coverage from the given URL
-func (c *CoverageClient) collectCoverageFromURL(coverageURL, testName string) error {
-	// Prepare request body
-	reqBody, err := json.Marshal(map[string]string{
-		"test_name": testName,
-	})
-	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
+		path := filepath.Join(testDir, name)
+		if err := c.saveCoveragePart(path, part); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		if strings.HasPrefix(name, "covmeta.") {
+			c.metaCache.remember(coverageFileHash(name), path)
+		}
+		written = append(written, name)
+		c.logf("  📁 Saved: %s\n", path)
 	}
 
-	// Send POST request to coverage endpoint
-	resp, err := c.httpClient.Post(coverageURL, "application/json", bytes.NewReader(reqBody))
-	if err != nil {
-		return fmt.Errorf("send coverage request: %w", err)
+	// Counters-only responses leave out meta-data saved by earlier collections
+	if !meta && counters {
+		for _, hash := range hashes {
+			path, err := c.metaCache.copyTo(hash, testDir)
+			if err != nil {
+				return err
+			}
+			written = append(written, path)
+			c.logf("  📁 Saved: %s (cached)\n", path)
+		}
+		meta = true
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("coverage endpoint returned %d: %s", resp.StatusCode, body)
+	if !meta || !counters {
+		return fmt.Errorf("coverage stream is missing meta-data or counters")
 	}
+	return c.separateEarlierBinaries(testDir, written)
+}
 
-	// Parse response
-	var covResp CoverageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&covResp); err != nil {
-		return fmt.Errorf("decode coverage response: %w", err)
+// saveCoveragePart writes a streamed coverage file to path. Counters named by a snapshot are
+// rebuilt from earlier counters if sent as changes, verified, and remembered as the base of the
+// next collection from their server.
+func (c *CoverageClient) saveCoveragePart(path string, part *multipart.Part) error {
+	snapshot := part.Header.Get(coverageSnapshotHeader)
+	if snapshot == "" {
+		return writeFileFrom(path, part)
 	}
 
-	// Decode and save metadata
-	metaData, err := base64.StdEncoding.DecodeString(covResp.MetaData)
+	r, release, err := c.countersReader(part, part.Header)
 	if err != nil {
-		return fmt.Errorf("decode metadata: %w", err)
+		return err
+	}
+	defer release()
+	if err := writeFileFrom(path, r); err != nil {
+		return err
 	}
+	if base := part.Header.Get(coverageBaseHeader); base != "" {
+		c.logf("  📉 Counters received as changes against snapshot %s\n", base)
+	}
+	c.counterBases.remember(snapshot, path)
+	return nil
+}
 
-	// Decode and save counters
-	counterData, err := base64.StdEncoding.DecodeString(covResp.CountersData)
+// writeFileFrom copies r to path through a temporary file, so an interrupted transfer leaves no
+// truncated file behind
+func writeFileFrom(path string, r io.Reader) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
 	if err != nil {
-		return fmt.Errorf("decode counters: %w", err)
+		return err
 	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
 
+// saveCoverageResponse decodes a coverage JSON response and writes the binary files to the test
+// directory as the base64 data arrives, so the payload is never held in memory. Besides the
+// process' own counters, responses may list counters files of other processes of the binary
+// (forked children, earlier containers) under "counters"; all of them are saved.
+func (c *CoverageClient) saveCoverageResponse(body io.Reader, testName string) error {
 	// Create test-specific subdirectory
-	testDir := filepath.Join(c.outputDir, testName)
-	if err := os.MkdirAll(testDir, 0755); err != nil {
-		return fmt.Errorf("create test directory: %w", err)
+	testDir, err := c.createTestDir(testName)
+	if err != nil {
+		return err
 	}
 
-	// Save files with proper names
-	metaPath := filepath.Join(testDir, covResp.MetaFilename)
-	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
-		return fmt.Errorf("write metadata file: %w", err)
+	// File names may follow the data, so decode into temporary files renamed afterwards
+	type decodedFile struct {
+		file *os.File
+		name string
+	}
+	var files []*decodedFile
+	defer func() {
+		for _, f := range files {
+			f.file.Close()
+			os.Remove(f.file.Name())
+		}
+	}()
+	newFile := func() (*decodedFile, error) {
+		file, err := os.CreateTemp(testDir, ".coverage-*.tmp")
+		if err != nil {
+			return nil, fmt.Errorf("create temporary file: %w", err)
+		}
+		f := &decodedFile{file: file}
+		files = append(files, f)
+		return f, nil
 	}
 
-	counterPath := filepath.Join(testDir, covResp.CountersFilename)
-	if err := os.WriteFile(counterPath, counterData, 0644); err != nil {
-		return fmt.Errorf("write counters file: %w", err)
+	meta, err := newFile()
+	if err != nil {
+		return err
+	}
+	counters, err := newFile()
+	if err != nil {
+		return err
+	}
+	decodeOthers := func(s *jsonScanner) error {
+		return s.array(func() error {
+			other, err := newFile()
+			if err != nil {
+				return err
+			}
+			fields, err := s.object(map[string]io.Writer{"data": other.file}, nil)
+			if err != nil {
+				return err
+			}
+			json.Unmarshal(fields["filename"], &other.name)
+			return nil
+		})
 	}
 
-	fmt.Printf("  📁 Saved: %s\n", metaPath)
-	fmt.Printf("  📁 Saved: %s\n", counterPath)
+	fields, err := decodeCoverageJSON(c.limitResponse(body),
+		map[string]io.Writer{"meta_data": meta.file, "counters_data": counters.file},
+		map[string]func(*jsonScanner) error{"counters": decodeOthers})
+	if err != nil {
+		return fmt.Errorf("decode coverage response: %w", err)
+	}
+	json.Unmarshal(fields["meta_filename"], &meta.name)
+	json.Unmarshal(fields["counters_filename"], &counters.name)
 
-	return nil
+	// Save files with proper names
+	var written []string
+	for _, f := range files {
+		if f.name == "" {
+			return fmt.Errorf("decode coverage response: missing file names")
+		}
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+		path := filepath.Join(testDir, filepath.Base(f.name))
+		if err := os.Rename(f.file.Name(), path); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+		written = append(written, path)
+		c.logf("  📁 Saved: %s\n", path)
+	}
+
+	return c.separateEarlierBinaries(testDir, written)
 }
 
 // GenerateCoverageReport generates a text coverage report from collected data
 func (c *CoverageClient) GenerateCoverageReport(testName string) error {
+	return c.withTestDirLock(context.Background(), testName, func(ctx context.Context) error {
+		return c.generateCoverageReport(ctx, testName)
+	})
+}
+
+// generateCoverageReport generates coverage.out, canceling `go tool covdata` with ctx
+func (c *CoverageClient) generateCoverageReport(ctx context.Context, testName string) (err error) {
 	testDir := filepath.Join(c.outputDir, testName)
 	reportPath := filepath.Join(testDir, "coverage.out")
 
-	fmt.Printf("📊 Generating coverage report for test: %s\n", testName)
+	_, step := startStep(ctx, "generate-report", attribute.String("test", testName))
+	defer func(start time.Time) {
+		step.end(err)
+		c.reportEvent(ctx, testName, ReportText, reportPath, start, err)
+	}(time.Now())
 
-	// Run go tool covdata to convert binary format to text
-	cmd := exec.Command("go", "tool", "covdata", "textfmt",
-		"-i="+testDir,
-		"-o="+reportPath)
+	c.logf("📊 Generating coverage report for test: %s\n", testName)
 
-	output, err := cmd.CombinedOutput()
+	// Name broken files instead of failing with go tool covdata's errors
+	validation, err := ValidateCoverageData(testDir)
 	if err != nil {
-		return fmt.Errorf("generate coverage report: %w\nOutput: %s", err, output)
+		return err
+	}
+	if err := validation.Err(); err != nil {
+		return fmt.Errorf("generate coverage report: %w", err)
 	}
 
-	fmt.Printf("✅ Coverage report generated: %s\n", reportPath)
+	var packages []string
+	if len(c.packages) > 0 {
+		if packages, err = c.selectCovdataPackages(testDir, c.packages); err != nil {
+			return err
+		}
+	}
+	if c.useNativeCovdata() {
+		if err := writeTextProfileFile(testDir, packages, reportPath); err != nil {
+			return fmt.Errorf("generate coverage report: %w", err)
+		}
+	} else {
+		args := []string{"tool", "covdata", "textfmt", "-i=.", "-o=coverage.out"}
+		if len(packages) > 0 {
+			args = append(args, "-pkg="+strings.Join(packages, ","))
+		}
+
+		// Run go tool covdata to convert binary format to text, in testDir since -i is
+		// comma-separated and the path may contain commas
+		cmd := exec.CommandContext(ctx, "go", args...)
+		cmd.Dir = testDir
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("generate coverage report: %w\nOutput: %s", err, output)
+		}
+	}
+
+	c.logf("✅ Coverage report generated: %s\n", reportPath)
 
 	// Apply path remapping if enabled
 	if c.enablePathRemap {
 		if err := c.remapCoveragePaths(reportPath); err != nil {
-			fmt.Printf("⚠️  Path remapping failed: %v (continuing with original paths)\n", err)
+			c.logf("⚠️  Path remapping failed: %v (continuing with original paths)\n", err)
 		}
 	}
 
 	return nil
 }
 
-// FilterCoverageReport filters out specified files from the coverage report.
-// If no patterns are provided, uses the client's default filters.
+// FilterCoverageReport filters out specified files from the coverage report, see Profile.Filter
+// for the pattern syntax. If no patterns are provided, uses the client's default filters.
 // Pass an empty slice []string{} to disable all filtering.
 func (c *CoverageClient) FilterCoverageReport(testName string, patterns ...string) error {
+	// Use default filters if no patterns provided
+	if len(patterns) == 0 {
+		patterns = c.defaultFilters
+	}
+	return c.withTestDirLock(context.Background(), testName, func(ctx context.Context) error {
+		_, _, err := c.filterCoverageReport(ctx, testName, patterns)
+		return err
+	})
+}
+
+// filterCoverageReport writes coverage_filtered.out without the files matching patterns and
+// returns the filtered profile and the statements removed
+func (c *CoverageClient) filterCoverageReport(ctx context.Context, testName string, patterns []string) (_ *Profile, removed CoverageStats, err error) {
 	testDir := filepath.Join(c.outputDir, testName)
 	reportPath := filepath.Join(testDir, "coverage.out")
 	filteredPath := filepath.Join(testDir, "coverage_filtered.out")
 
+	_, step := startStep(ctx, "filter-report", attribute.String("test", testName))
+	defer func(start time.Time) {
+		step.end(err)
+		c.reportEvent(ctx, testName, ReportFiltered, filteredPath, start, err)
+	}(time.Now())
+
 	data, err := os.ReadFile(reportPath)
 	if err != nil {
-		return fmt.Errorf("read coverage report: %w", err)
+		return nil, removed, fmt.Errorf("read coverage report: %w", err)
 	}
 
-	// Use default filters if no patterns provided
-	filterPatterns := patterns
-	if len(patterns) == 0 {
-		filterPatterns = c.defaultFilters
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, removed, fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+		}
+	}
+
+	profile, err := ParseProfile(bytes.NewReader(data))
+	if err != nil {
+		return nil, removed, fmt.Errorf("parse coverage report: %w", err)
 	}
 
 	// If no filters at all, just copy the file
-	if len(filterPatterns) == 0 {
+	if len(patterns) == 0 {
 		if err := os.WriteFile(filteredPath, data, 0644); err != nil {
-			return fmt.Errorf("write filtered report: %w", err)
+			return nil, removed, fmt.Errorf("write filtered report: %w", err)
 		}
-		fmt.Printf("✅ Coverage report (no filters applied): %s\n", filteredPath)
-		return nil
+		c.logf("✅ Coverage report (no filters applied): %s\n", filteredPath)
+		return profile, removed, nil
 	}
 
-	lines := strings.Split(string(data), "\n")
-	var filtered []string
-	filteredCount := 0
+	removed = profile.Filter(patterns)
 
-	for _, line := range lines {
-		shouldFilter := false
-		for _, pattern := range filterPatterns {
-			if pattern != "" && strings.Contains(line, pattern) {
-				shouldFilter = true
-				filteredCount++
-				break
-			}
-		}
-		if !shouldFilter {
-			filtered = append(filtered, line)
-		}
+	var buf bytes.Buffer
+	if err := profile.Write(&buf); err != nil {
+		return nil, removed, fmt.Errorf("write filtered report: %w", err)
 	}
-
-	filteredData := strings.Join(filtered, "\n")
-	if err := os.WriteFile(filteredPath, []byte(filteredData), 0644); err != nil {
-		return fmt.Errorf("write filtered report: %w", err)
+	if err := os.WriteFile(filteredPath, buf.Bytes(), 0644); err != nil {
+		return nil, removed, fmt.Errorf("write filtered report: %w", err)
 	}
 
-	fmt.Printf("✅ Filtered coverage report: %s (removed %d lines matching: %v)\n",
-		filteredPath, filteredCount, filterPatterns)
-	return nil
+	c.logf("✅ Filtered coverage report: %s (removed %d statements, %d covered, in files matching: %v)\n",
+		filteredPath, removed.Statements, removed.Covered, patterns)
+	return profile, removed, nil
 }
 
 // GenerateHTMLReport generates an HTML coverage report
 func (c *CoverageClient) GenerateHTMLReport(testName string) error {
+	return c.withTestDirLock(context.Background(), testName, func(ctx context.Context) error {
+		_, err := c.generateHTMLReport(ctx, testName)
+		return err
+	})
+}
+
+// generateHTMLReport generates coverage.html and returns its path, canceling `go tool cover` with ctx
+func (c *CoverageClient) generateHTMLReport(ctx context.Context, testName string) (_ string, err error) {
 	testDir := filepath.Join(c.outputDir, testName)
 	reportPath := filepath.Join(testDir, "coverage_filtered.out")
 	htmlPath := filepath.Join(testDir, "coverage.html")
 
+	_, step := startStep(ctx, "generate-html", attribute.String("test", testName))
+	defer func(start time.Time) {
+		step.end(err)
+		c.reportEvent(ctx, testName, ReportHTML, htmlPath, start, err)
+	}(time.Now())
+
 	// Check if filtered report exists, fallback to regular report
 	if _, err := os.Stat(reportPath); os.IsNotExist(err) {
 		reportPath = filepath.Join(testDir, "coverage.out")
 	}
 
-	fmt.Printf("📊 Generating HTML coverage report for test: %s\n", testName)
+	c.logf("📊 Generating HTML coverage report for test: %s\n", testName)
 
-	cmd := exec.Command("go", "tool", "cover",
+	cmd := exec.CommandContext(ctx, "go", "tool", "cover",
 		"-html="+reportPath,
 		"-o="+htmlPath)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("generate HTML report: %w\nOutput: %s", err, output)
+		return "", fmt.Errorf("generate HTML report: %w\nOutput: %s", err, output)
 	}
 
-	fmt.Printf("✅ HTML report generated: %s\n", htmlPath)
-	return nil
+	c.logf("✅ HTML report generated: %s\n", htmlPath)
+	return htmlPath, nil
 }
 
-// PrintCoverageSummary prints a summary of the coverage data
+// PrintCoverageSummary writes the summary of CoverageSummary to the client's logger
 func (c *CoverageClient) PrintCoverageSummary(testName string) error {
+	summary, err := c.CoverageSummary(testName)
+	if err != nil {
+		return err
+	}
+	c.logf("%s", summary)
+	return nil
+}
+
+// CoverageSummary returns a human-readable summary of the coverage data: the filtered report (or
+// the unfiltered one), plus the total and its delta to the baseline when one is configured
+func (c *CoverageClient) CoverageSummary(testName string) (string, error) {
 	testDir := filepath.Join(c.outputDir, testName)
 	reportPath := filepath.Join(testDir, "coverage_filtered.out")
 
@@ -613,37 +935,91 @@ func (c *CoverageClient) PrintCoverageSummary(testName string) error {
 
 	data, err := os.ReadFile(reportPath)
 	if err != nil {
-		return fmt.Errorf("read coverage report: %w", err)
+		return "", fmt.Errorf("read coverage report: %w", err)
 	}
 
-	fmt.Printf("\n📊 Coverage Summary for test: %s\n", testName)
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println(string(data))
-	fmt.Println(strings.Repeat("=", 60))
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n📊 Coverage Summary for test: %s\n", testName)
+	fmt.Fprintln(&b, strings.Repeat("=", 60))
+	fmt.Fprintln(&b, string(data))
+	fmt.Fprintln(&b, strings.Repeat("=", 60))
 
-	return nil
+	// With a configured baseline, show the total and the delta to the base branch
+	if c.baseline != nil {
+		if report, err := c.BuildCoverageReport(context.Background(), CoverageReportOptions{TestName: testName}); err == nil {
+			if d, ok := report.Delta(); ok {
+				fmt.Fprintf(&b, "Total: %s, %s vs. baseline\n", formatStats(report.Total), formatDelta(d))
+			}
+		}
+	}
+
+	return b.String(), nil
 }
 
 // ProcessCoverageReports is a convenience method that generates, filters, and creates HTML reports
 // all in one call. It automatically uses the client's default filters.
 func (c *CoverageClient) ProcessCoverageReports(testName string) error {
-	// Generate text report from binary coverage data
-	if err := c.GenerateCoverageReport(testName); err != nil {
-		return fmt.Errorf("generate report: %w", err)
+	_, err := c.ProcessCoverage(context.Background(), ProcessOptions{TestName: testName})
+	return err
+}
+
+// ProcessOptions configures ProcessCoverage
+type ProcessOptions struct {
+	TestName string
+	Filters  []string // File patterns filtered from the report (nil: the client's default filters, empty: none)
+	SkipHTML bool     // Don't generate coverage.html
+}
+
+// ProcessResult describes the reports generated by ProcessCoverage
+type ProcessResult struct {
+	TestName        string
+	Profile         string        // coverage.out
+	FilteredProfile string        // coverage_filtered.out
+	HTML            string        // coverage.html ("" when skipped or failed)
+	HTMLError       error         // Why the HTML report failed, e.g. source files not available locally
+	Total           CoverageStats // Coverage of the filtered profile
+	Removed         CoverageStats // Statements removed by the filters
+}
+
+// ProcessCoverage generates the text report of a test's covdata files, filters it and creates the
+// HTML report, canceling the go tool runs with ctx. A failed HTML report doesn't fail processing,
+// it's reported in the result.
+func (c *CoverageClient) ProcessCoverage(ctx context.Context, opts ProcessOptions) (*ProcessResult, error) {
+	ctx, unlock, err := c.lockTestDir(ctx, opts.TestName)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	// Filter the report (uses default filters)
-	if err := c.FilterCoverageReport(testName); err != nil {
-		return fmt.Errorf("filter report: %w", err)
+	// Generate text report from binary coverage data
+	if err := c.generateCoverageReport(ctx, opts.TestName); err != nil {
+		return nil, fmt.Errorf("generate report: %w", err)
 	}
 
-	// Generate HTML report
-	if err := c.GenerateHTMLReport(testName); err != nil {
-		// HTML generation might fail if source files aren't available, log but don't fail
-		fmt.Printf("⚠️  HTML report generation failed (source files may not be available): %v\n", err)
+	filters := opts.Filters
+	if filters == nil {
+		filters = c.defaultFilters
+	}
+	profile, removed, err := c.filterCoverageReport(ctx, opts.TestName, filters)
+	if err != nil {
+		return nil, fmt.Errorf("filter report: %w", err)
 	}
 
-	return nil
+	testDir := filepath.Join(c.outputDir, opts.TestName)
+	result := &ProcessResult{
+		TestName:        opts.TestName,
+		Profile:         filepath.Join(testDir, "coverage.out"),
+		FilteredProfile: filepath.Join(testDir, "coverage_filtered.out"),
+		Total:           profile.Total(),
+		Removed:         removed,
+	}
+	if !opts.SkipHTML {
+		if result.HTML, result.HTMLError = c.generateHTMLReport(ctx, opts.TestName); result.HTMLError != nil {
+			// HTML generation might fail if source files aren't available, log but don't fail
+			c.logf("⚠️  HTML report generation failed (source files may not be available): %v\n", result.HTMLError)
+		}
+	}
+	return result, nil
 }
 
 // PushCoverageArtifactOptions contains options for pushing coverage artifacts to OCI registry
@@ -656,27 +1032,88 @@ type PushCoverageArtifactOptions struct {
 	Annotations  map[string]string // Additional annotations
 }
 
+// PushResult identifies a pushed coverage artifact
+type PushResult struct {
+	Reference string // registry/repository:tag
+	Digest    string // Manifest digest, e.g. "sha256:..."
+}
+
+// SetArtifactDestination configures where PushCoverageArtifact pushes when its options name no
+// registry: their empty fields are taken from dest, with "{test}" in the tag replaced by the test
+// name (default: none)
+func (c *CoverageClient) SetArtifactDestination(dest PushCoverageArtifactOptions) {
+	c.artifact = &dest
+}
+
+// artifactOptions fills opts from the artifact destination when they name no registry
+func (c *CoverageClient) artifactOptions(testName string, opts PushCoverageArtifactOptions) PushCoverageArtifactOptions {
+	if opts.Registry != "" || c.artifact == nil {
+		return opts
+	}
+	dest := *c.artifact
+	opts.Registry = dest.Registry
+	if opts.Repository == "" {
+		opts.Repository = dest.Repository
+	}
+	if opts.Tag == "" {
+		opts.Tag = strings.ReplaceAll(dest.Tag, "{test}", testName)
+	}
+	if opts.ExpiresAfter == "" {
+		opts.ExpiresAfter = dest.ExpiresAfter
+	}
+	if opts.Title == "" {
+		opts.Title = dest.Title
+	}
+	if opts.Annotations == nil {
+		opts.Annotations = dest.Annotations
+	}
+	return opts
+}
+
 // PushCoverageArtifact pushes the coverage output directory as an OCI artifact to a registry
 func (c *CoverageClient) PushCoverageArtifact(ctx context.Context, testName string, opts PushCoverageArtifactOptions) error {
+	_, err := c.PushCoverageArtifactWithResult(ctx, testName, opts)
+	return err
+}
+
+// PushCoverageArtifactWithResult pushes like PushCoverageArtifact and returns the artifact's reference and
+// digest. Errors wrap ErrArtifactPush.
+func (c *CoverageClient) PushCoverageArtifactWithResult(ctx context.Context, testName string, opts PushCoverageArtifactOptions) (result *PushResult, err error) {
+	opts = c.artifactOptions(testName, opts)
+	ctx, step := startStep(ctx, "push", attribute.String("test", testName), attribute.String("repository", opts.Registry+"/"+opts.Repository))
+	c.emit(ctx, &PushStarted{TestName: testName, Reference: fmt.Sprintf("%s/%s:%s", opts.Registry, opts.Repository, opts.Tag)})
+	defer func(start time.Time) {
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrArtifactPush, err)
+		}
+		step.end(err)
+		c.emit(ctx, &PushFinished{TestName: testName, Result: result, Duration: time.Since(start), Err: err})
+	}(time.Now())
+
 	testDir := filepath.Join(c.outputDir, testName)
+	ctx, unlock, err := c.lockTestDir(ctx, testName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
-	fmt.Printf("📦 Pushing coverage artifact for test: %s\n", testName)
-	fmt.Printf("   Registry: %s/%s:%s\n", opts.Registry, opts.Repository, opts.Tag)
-	fmt.Printf("   Source directory: %s\n", testDir)
+	c.logf("📦 Pushing coverage artifact for test: %s\n", testName)
+	c.logf("   Registry: %s/%s:%s\n", opts.Registry, opts.Repository, opts.Tag)
+	c.logf("   Source directory: %s\n", testDir)
 
 	// Verify directory exists and has files
 	if _, err := os.Stat(testDir); os.IsNotExist(err) {
-		return fmt.Errorf("test directory does not exist: %s", testDir)
+		return nil, fmt.Errorf("test directory does not exist: %s", testDir)
 	}
 
 	// Create a file store for the test directory
-	fmt.Printf("   Creating file store...\n")
+	c.logf("   Creating file store...\n")
 	fs, err := file.New(testDir)
 	if err != nil {
-		return fmt.Errorf("create file store: %w", err)
+		return nil, fmt.Errorf("create file store: %w", err)
 	}
 	defer fs.Close()
-	fmt.Printf("   ✓ File store created\n")
+	c.logf("   ✓ File store created\n")
 
 	// Add all files from the test directory
 	mediaType := "application/vnd.acme.rocket.docs.layer.v1+tar"
@@ -684,7 +1121,7 @@ func (c *CoverageClient) PushCoverageArtifact(ctx context.Context, testName stri
 
 	files, err := os.ReadDir(testDir)
 	if err != nil {
-		return fmt.Errorf("read test directory: %w", err)
+		return nil, fmt.Errorf("read test directory: %w", err)
 	}
 
 	for _, file := range files {
@@ -701,14 +1138,14 @@ func (c *CoverageClient) PushCoverageArtifact(ctx context.Context, testName stri
 		// Add file to the store (file store is based at testDir, so we only need the filename)
 		desc, err := fs.Add(ctx, file.Name(), mediaType, file.Name())
 		if err != nil {
-			return fmt.Errorf("add file %s to store: %w", file.Name(), err)
+			return nil, fmt.Errorf("add file %s to store: %w", file.Name(), err)
 		}
 		fileDescriptors = append(fileDescriptors, desc)
-		fmt.Printf("   📄 Added: %s (%d bytes)\n", file.Name(), fileInfo.Size())
+		c.logf("   📄 Added: %s (%d bytes)\n", file.Name(), fileInfo.Size())
 	}
 
 	// Pack the files and tag the packed manifest
-	fmt.Printf("   Packing manifest with %d files...\n", len(fileDescriptors))
+	c.logf("   Packing manifest with %d files...\n", len(fileDescriptors))
 	artifactType := "application/vnd.acme.rocket.config"
 
 	// Initialize annotations if not already set
@@ -722,6 +1159,14 @@ func (c *CoverageClient) PushCoverageArtifact(ctx context.Context, testName stri
 	if opts.Title != "" {
 		opts.Annotations[ocispec.AnnotationTitle] = opts.Title
 	}
+	// Record build provenance, keeping annotations set by the caller
+	if ci := DetectCI(); ci != nil {
+		for key, value := range ci.annotations() {
+			if _, ok := opts.Annotations[key]; !ok {
+				opts.Annotations[key] = value
+			}
+		}
+	}
 
 	packOpts := oras.PackManifestOptions{
 		Layers:              fileDescriptors,
@@ -730,337 +1175,56 @@ func (c *CoverageClient) PushCoverageArtifact(ctx context.Context, testName stri
 
 	manifestDesc, err := oras.PackManifest(ctx, fs, oras.PackManifestVersion1_1_RC4, artifactType, packOpts)
 	if err != nil {
-		return fmt.Errorf("pack manifest: %w", err)
+		return nil, fmt.Errorf("pack manifest: %w", err)
 	}
-	fmt.Printf("   ✓ Manifest packed\n")
+	c.logf("   ✓ Manifest packed\n")
 
 	if err = fs.Tag(ctx, manifestDesc, opts.Tag); err != nil {
-		return fmt.Errorf("tag manifest: %w", err)
+		return nil, fmt.Errorf("tag manifest: %w", err)
 	}
-	fmt.Printf("   ✓ Manifest tagged: %s\n", opts.Tag)
+	c.logf("   ✓ Manifest tagged: %s\n", opts.Tag)
 
-	// Setup remote repository
-	fmt.Printf("   Connecting to registry %s/%s...\n", opts.Registry, opts.Repository)
-	repo, err := remote.NewRepository(fmt.Sprintf("%s/%s", opts.Registry, opts.Repository))
+	// Setup remote repository with Docker credentials
+	c.logf("   Connecting to registry %s/%s...\n", opts.Registry, opts.Repository)
+	repo, err := newRemoteRepository(opts.Registry, opts.Repository)
 	if err != nil {
-		return fmt.Errorf("create remote repository: %w", err)
-	}
-
-	// Setup authentication using Docker credentials
-	fmt.Printf("   Setting up authentication...\n")
-	storeOpts := credentials.StoreOptions{}
-	credStore, err := credentials.NewStoreFromDocker(storeOpts)
-	if err != nil {
-		return fmt.Errorf("create credential store: %w", err)
-	}
-
-	repo.Client = &auth.Client{
-		Client:     http.DefaultClient,
-		Cache:      auth.NewCache(),
-		Credential: credentials.Credential(credStore),
+		return nil, err
 	}
-	fmt.Printf("   ✓ Authentication configured\n")
+	c.logf("   ✓ Authentication configured\n")
 
 	// Copy from file store to remote repository
-	fmt.Printf("   Pushing to registry...\n")
-	_, err = oras.Copy(ctx, fs, opts.Tag, repo, opts.Tag, oras.DefaultCopyOptions)
-	if err != nil {
-		return fmt.Errorf("push artifact: %w", err)
-	}
-
-	fmt.Printf("✅ Coverage artifact pushed successfully\n")
-	fmt.Printf("   Location: %s/%s:%s\n", opts.Registry, opts.Repository, opts.Tag)
-
-	return nil
-}
-
-// remapCoveragePaths remaps container paths in the coverage report to local paths
-func (c *CoverageClient) remapCoveragePaths(reportPath string) error {
-	// Read the coverage report
-	data, err := os.ReadFile(reportPath)
+	c.logf("   Pushing to registry...\n")
+	manifestDesc, err = oras.Copy(ctx, fs, opts.Tag, repo, opts.Tag, oras.DefaultCopyOptions)
 	if err != nil {
-		return fmt.Errorf("read coverage report: %w", err)
-	}
-
-	lines := strings.Split(string(data), "\n")
-
-	// Detect container path mappings
-	pathMappings := c.detectContainerPaths(lines)
-
-	if len(pathMappings) == 0 {
-		fmt.Println("📍 No container paths detected, using paths as-is")
-		return nil
-	}
-
-	fmt.Printf("📍 Auto-detected path mappings:\n")
-	for containerPath, localPath := range pathMappings {
-		fmt.Printf("  [PATH] %s -> %s\n", containerPath, localPath)
-	}
-
-	// Remap paths in the coverage data
-	var remappedLines []string
-	remappedCount := 0
-
-	for _, line := range lines {
-		if line == "" || strings.HasPrefix(line, "mode:") {
-			remappedLines = append(remappedLines, line)
-			continue
-		}
-
-		// Coverage line format: path/to/file.go:line.col,line.col num count
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) < 2 {
-			remappedLines = append(remappedLines, line)
-			continue
-		}
-
-		filePath := parts[0]
-		rest := parts[1]
-
-		// Try to remap the path
-		newPath := filePath
-		for containerPrefix, localPrefix := range pathMappings {
-			if strings.HasPrefix(filePath, containerPrefix) {
-				newPath = strings.Replace(filePath, containerPrefix, localPrefix, 1)
-				remappedCount++
-				break
-			}
-		}
-
-		remappedLines = append(remappedLines, newPath+":"+rest)
+		return nil, fmt.Errorf("copy to registry: %w", err)
 	}
 
-	// Write the remapped coverage report back
-	remappedData := strings.Join(remappedLines, "\n")
-	if err := os.WriteFile(reportPath, []byte(remappedData), 0644); err != nil {
-		return fmt.Errorf("write remapped report: %w", err)
-	}
+	c.logf("✅ Coverage artifact pushed successfully\n")
+	c.logf("   Location: %s/%s:%s\n", opts.Registry, opts.Repository, opts.Tag)
 
-	fmt.Printf("✅ Path remapping complete (%d lines remapped)\n", remappedCount)
-	return nil
+	return &PushResult{
+		Reference: fmt.Sprintf("%s/%s:%s", opts.Registry, opts.Repository, opts.Tag),
+		Digest:    manifestDesc.Digest.String(),
+	}, nil
 }
 
-// detectContainerPaths analyzes coverage report lines to detect container path mappings
-func (c *CoverageClient) detectContainerPaths(lines []string) map[string]string {
-	// Collect all file paths from the coverage report
-	var coverageFiles []string
-	for _, line := range lines {
-		if line == "" || strings.HasPrefix(line, "mode:") {
-			continue
-		}
-
-		// Coverage line format: path/to/file.go:line.col,line.col num count
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) >= 1 {
-			filePath := parts[0]
-			// Only add unique paths
-			if len(coverageFiles) == 0 || coverageFiles[len(coverageFiles)-1] != filePath {
-				coverageFiles = append(coverageFiles, filePath)
-			}
-		}
-	}
-
-	// Find files that don't exist locally (container paths)
-	var containerFiles []string
-	for _, filePath := range coverageFiles {
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			containerFiles = append(containerFiles, filePath)
-		}
-	}
-
-	if len(containerFiles) == 0 {
-		// No container paths detected
-		return nil
-	}
-
-	fmt.Printf("[REMAP] Detected %d container paths to remap\n", len(containerFiles))
-
-	// Get absolute path for source directory
-	absSourceDir, err := filepath.Abs(c.sourceDir)
+// newRemoteRepository connects to registry/repository, authenticating with Docker credentials
+func newRemoteRepository(registry, repository string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(fmt.Sprintf("%s/%s", registry, repository))
 	if err != nil {
-		fmt.Printf("[REMAP] Warning: Could not get absolute path for %s: %v\n", c.sourceDir, err)
-		absSourceDir = c.sourceDir
+		return nil, fmt.Errorf("create remote repository: %w", err)
 	}
 
-	fmt.Printf("[REMAP] Searching for source files in: %s\n", absSourceDir)
-
-	// Build a map of local Go files by their relative path structure
-	localFilesByRelPath := make(map[string]string) // key: relative path parts joined, value: full path
-
-	err = filepath.Walk(absSourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-		if info.IsDir() {
-			// Skip common directories that won't have source code
-			baseName := filepath.Base(path)
-			if baseName == ".git" || baseName == "vendor" || baseName == "node_modules" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if strings.HasSuffix(path, ".go") {
-			// Store the full path indexed by filename and path structure
-			relPath, _ := filepath.Rel(absSourceDir, path)
-			localFilesByRelPath[relPath] = path
-		}
-		return nil
-	})
-
+	storeOpts := credentials.StoreOptions{}
+	credStore, err := credentials.NewStoreFromDocker(storeOpts)
 	if err != nil {
-		fmt.Printf("[REMAP] Warning: Error walking source directory: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("create credential store: %w", err)
 	}
 
-	fmt.Printf("[REMAP] Found %d Go source files\n", len(localFilesByRelPath))
-
-	// Try to match container files to local files
-	type match struct {
-		containerFile string
-		localFile     string
-		matchScore    int
-	}
-
-	var matches []match
-
-	for _, containerFile := range containerFiles {
-		containerPath := filepath.Clean(containerFile)
-		containerParts := strings.Split(containerPath, string(filepath.Separator))
-		fileName := filepath.Base(containerPath)
-
-		// Find best matching local file
-		bestMatch := ""
-		bestScore := 0
-
-		for relPath, localPath := range localFilesByRelPath {
-			localParts := strings.Split(relPath, string(filepath.Separator))
-
-			// Files must have same name
-			if filepath.Base(localPath) != fileName {
-				continue
-			}
-
-			// Count matching suffix parts (from filename backwards)
-			matchScore := 0
-			maxLen := len(containerParts)
-			if len(localParts) < maxLen {
-				maxLen = len(localParts)
-			}
-
-			for i := 1; i <= maxLen; i++ {
-				cIdx := len(containerParts) - i
-				lIdx := len(localParts) - i
-				if containerParts[cIdx] == localParts[lIdx] {
-					matchScore = i
-				} else {
-					break
-				}
-			}
-
-			// Prefer longer matches (more specific paths)
-			if matchScore > bestScore {
-				bestScore = matchScore
-				bestMatch = localPath
-			}
-		}
-
-		if bestMatch != "" && bestScore > 0 {
-			matches = append(matches, match{
-				containerFile: containerFile,
-				localFile:     bestMatch,
-				matchScore:    bestScore,
-			})
-			fmt.Printf("[REMAP] Match: %s -> %s (score: %d)\n", containerFile, bestMatch, bestScore)
-		}
-	}
-
-	if len(matches) == 0 {
-		fmt.Printf("[REMAP] No matching files found between container and local paths\n")
-		return nil
-	}
-
-	fmt.Printf("[REMAP] Found %d matches between container and local files\n", len(matches))
-
-	// Determine the most common container root prefix
-	containerRootCounts := make(map[string]int)
-
-	for _, m := range matches {
-		containerParts := strings.Split(filepath.Clean(m.containerFile), string(filepath.Separator))
-		// Extract container root (everything except the matched suffix)
-		rootPartsCount := len(containerParts) - m.matchScore
-		fmt.Printf("[REMAP] Container: %s, parts: %v, score: %d, rootPartsCount: %d\n",
-			m.containerFile, containerParts, m.matchScore, rootPartsCount)
-		if rootPartsCount > 0 {
-			rootParts := containerParts[:rootPartsCount]
-			containerRoot := string(filepath.Separator) + filepath.Join(rootParts...)
-			if !strings.HasSuffix(containerRoot, string(filepath.Separator)) {
-				containerRoot += string(filepath.Separator)
-			}
-			fmt.Printf("[REMAP] Container root candidate: %s\n", containerRoot)
-			containerRootCounts[containerRoot]++
-		}
-	}
-
-	// Find the most common container root
-	var bestContainerRoot string
-	maxCount := 0
-	for root, count := range containerRootCounts {
-		if count > maxCount {
-			maxCount = count
-			bestContainerRoot = root
-		}
-	}
-
-	if bestContainerRoot == "" {
-		fmt.Printf("[REMAP] Could not determine container root\n")
-		return nil
-	}
-
-	fmt.Printf("[REMAP] Detected container root: %s\n", bestContainerRoot)
-
-	// Calculate the local root from all matches - find the common ancestor
-	// This ensures we get the project root, not a subdirectory
-	var localRootCandidates []string
-	for _, m := range matches {
-		if strings.HasPrefix(m.containerFile, bestContainerRoot) {
-			// Get the local root by removing the matching suffix from local path
-			localPath := filepath.Clean(m.localFile)
-			localParts := strings.Split(localPath, string(filepath.Separator))
-			rootPartsCount := len(localParts) - m.matchScore
-
-			if rootPartsCount > 0 {
-				rootParts := localParts[:rootPartsCount]
-				candidateRoot := string(filepath.Separator) + filepath.Join(rootParts...)
-				if !strings.HasSuffix(candidateRoot, string(filepath.Separator)) {
-					candidateRoot += string(filepath.Separator)
-				}
-				localRootCandidates = append(localRootCandidates, candidateRoot)
-				fmt.Printf("[REMAP] Root candidate from %s: %s\n", filepath.Base(m.localFile), candidateRoot)
-			}
-		}
-	}
-
-	// Find the shortest (most common) root - the one closest to the actual source root
-	var localRoot string
-	if len(localRootCandidates) > 0 {
-		localRoot = localRootCandidates[0]
-		for _, candidate := range localRootCandidates {
-			// Shorter path means closer to the root
-			if len(candidate) < len(localRoot) {
-				localRoot = candidate
-			}
-		}
-	}
-
-	if localRoot == "" {
-		fmt.Printf("[REMAP] Could not determine local root\n")
-		return nil
-	}
-
-	fmt.Printf("[REMAP] Detected local root: %s\n", localRoot)
-
-	// Return the path mapping
-	return map[string]string{
-		bestContainerRoot: localRoot,
+	repo.Client = &auth.Client{
+		Client:     http.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: credentials.Credential(credStore),
 	}
+	return repo, nil
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"os"
 	"path/filepath"
 	"strings"
@@ -82,6 +85,7 @@ func TestGetPodNameWithContext(t *testing.T) {
 		labelSelector string
 		expectPod     string
 		expectError   bool
+		expectErr     error // Sentinel the error must wrap
 	}{
 		{
 			name: "finds running pod",
@@ -134,6 +138,7 @@ func TestGetPodNameWithContext(t *testing.T) {
 			pods:          []runtime.Object{},
 			labelSelector: "app=nonexistent",
 			expectError:   true,
+			expectErr:     ErrNoPodsFound,
 		},
 		{
 			name: "no running pods",
@@ -151,6 +156,7 @@ func TestGetPodNameWithContext(t *testing.T) {
 			},
 			labelSelector: "app=test",
 			expectError:   true,
+			expectErr:     ErrPodNotRunning,
 		},
 	}
 
@@ -169,6 +175,8 @@ func TestGetPodNameWithContext(t *testing.T) {
 			if tt.expectError {
 				if err == nil {
 					t.Error("Expected error but got none")
+				} else if !errors.Is(err, tt.expectErr) {
+					t.Errorf("Expected %v, got %v", tt.expectErr, err)
 				}
 			} else {
 				if err != nil {
@@ -318,6 +326,61 @@ func TestCollectCoverageFromURL_ServerError(t *testing.T) {
 	}
 }
 
+// streamCoverageParts writes files as a multipart coverage stream; close ends it with the final boundary
+func streamCoverageParts(w http.ResponseWriter, files [][2]string, close bool) {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", CoverageStreamType+"; boundary="+mw.Boundary())
+	for _, f := range files {
+		part, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Disposition": {`attachment; filename="` + f[0] + `"`}})
+		part.Write([]byte(f[1]))
+	}
+	if close {
+		mw.Close()
+	}
+}
+
+func TestCollectCoverageFromURL_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), CoverageStreamType) {
+			t.Errorf("Expected Accept to include %s, got %q", CoverageStreamType, r.Header.Get("Accept"))
+		}
+		streamCoverageParts(w, [][2]string{{"covmeta.abc", "meta content"}, {"covcounters.abc.1.2", "counter content"}}, true)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{outputDir: tempDir, httpClient: &http.Client{Timeout: 10 * time.Second}}
+
+	if err := client.CollectCoverageFromURL(server.URL, "test-case"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, want := range map[string]string{"covmeta.abc": "meta content", "covcounters.abc.1.2": "counter content"} {
+		content, err := os.ReadFile(filepath.Join(tempDir, "test-case", name))
+		if err != nil || string(content) != want {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", name, want, content, err)
+		}
+	}
+}
+
+func TestCollectCoverageFromURL_TruncatedStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streamCoverageParts(w, [][2]string{{"covmeta.abc", "meta content"}}, false)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{outputDir: tempDir, httpClient: &http.Client{Timeout: 10 * time.Second}}
+
+	if err := client.CollectCoverageFromURL(server.URL, "test-case"); err == nil {
+		t.Fatal("Expected error for a stream without final boundary")
+	}
+	files, _ := filepath.Glob(filepath.Join(tempDir, "test-case", "covcounters.*"))
+	if len(files) != 0 {
+		t.Errorf("Expected no counters from a truncated stream, got %v", files)
+	}
+}
+
 func TestFilterCoverageReport(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -351,6 +414,30 @@ github.com/test/pkg/file1.go:10.1,12.2 2 1
 github.com/test/pkg/file2.go:40.1,42.2 2 1`,
 			expectedFiltered: 2,
 		},
+		{
+			name: "matches file names, not substrings of lines",
+			reportContent: `mode: set
+github.com/test/server.go/file1.go:10.1,12.2 2 1
+github.com/test/pkg/coverage_server.go:20.1,22.2 2 1
+github.com/test/pkg/server.go:30.1,32.2 2 0`,
+			patterns: []string{"server.go"},
+			expectedContent: `mode: set
+github.com/test/server.go/file1.go:10.1,12.2 2 1
+github.com/test/pkg/coverage_server.go:20.1,22.2 2 1`,
+			expectedFiltered: 1,
+		},
+		{
+			name: "filters globs and directories",
+			reportContent: `mode: atomic
+github.com/test/pkg/file1.go:10.1,12.2 2 1
+github.com/test/pkg/mock_client.go:20.1,22.2 2 1
+github.com/test/internal/testutil/helper.go:30.1,32.2 2 1
+github.com/test/internal/testutil/fake/fake.go:40.1,42.2 2 1`,
+			patterns: []string{"mock_*.go", "internal/testutil/"},
+			expectedContent: `mode: atomic
+github.com/test/pkg/file1.go:10.1,12.2 2 1`,
+			expectedFiltered: 3,
+		},
 		{
 			name: "no filters - uses default",
 			reportContent: `mode: atomic
@@ -399,7 +486,7 @@ github.com/test/pkg/file1.go:10.1,12.2 2 1`,
 				t.Fatalf("Failed to read filtered report: %v", err)
 			}
 
-			if string(content) != tt.expectedContent {
+			if string(content) != tt.expectedContent+"\n" {
 				t.Errorf("Content mismatch.\nExpected:\n%s\n\nGot:\n%s", tt.expectedContent, string(content))
 			}
 		})
@@ -438,72 +525,6 @@ github.com/test/pkg/file1.go:10.1,12.2 2 1`
 	}
 }
 
-func TestDetectContainerPaths(t *testing.T) {
-	tests := []struct {
-		name             string
-		coverageLines    []string
-		sourceFiles      map[string]string // relative path -> content
-		expectedMappings map[string]string
-	}{
-		{
-			name: "detects simple container path",
-			coverageLines: []string{
-				"mode: atomic",
-				"/app/pkg/file.go:10.1,12.2 2 1",
-				"/app/pkg/other.go:20.1,22.2 2 1",
-			},
-			sourceFiles: map[string]string{
-				"pkg/file.go":  "package pkg",
-				"pkg/other.go": "package pkg",
-			},
-			expectedMappings: nil, // Will depend on temp dir path
-		},
-		{
-			name: "handles missing files",
-			coverageLines: []string{
-				"mode: atomic",
-				"./local/file.go:10.1,12.2 2 1", // Exists locally
-			},
-			sourceFiles: map[string]string{
-				"local/file.go": "package local",
-			},
-			expectedMappings: nil, // No remapping needed for local paths
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Create temp source directory
-			tempDir, err := os.MkdirTemp("", "source-test-*")
-			if err != nil {
-				t.Fatalf("Failed to create temp dir: %v", err)
-			}
-			defer os.RemoveAll(tempDir)
-
-			// Create source files
-			for relPath, content := range tt.sourceFiles {
-				fullPath := filepath.Join(tempDir, relPath)
-				os.MkdirAll(filepath.Dir(fullPath), 0755)
-				os.WriteFile(fullPath, []byte(content), 0644)
-			}
-
-			client := &CoverageClient{
-				sourceDir:       tempDir,
-				enablePathRemap: true,
-			}
-
-			mappings := client.detectContainerPaths(tt.coverageLines)
-
-			// For this test, we just verify it doesn't crash and returns a map
-			if mappings == nil && len(tt.sourceFiles) > 0 {
-				// When we have container paths that don't exist locally, we should get mappings
-				// This is hard to test precisely because it depends on path detection heuristics
-				t.Logf("No mappings detected (this may be expected depending on path structure)")
-			}
-		})
-	}
-}
-
 func TestProcessCoverageReports(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "process-test-*")
 	if err != nil {
@@ -542,6 +563,19 @@ func TestProcessCoverageReports(t *testing.T) {
 	}
 }
 
+func TestProcessCoverageReports_CommaInPath(t *testing.T) {
+	client := &CoverageClient{outputDir: filepath.Join(t.TempDir(), "runs,2025 01")}
+	os.MkdirAll(filepath.Join(client.outputDir, "test-case"), 0755)
+
+	// covdata splits -i at commas, the output directory must not reach it
+	if err := client.GenerateCoverageReport("test-case"); err != nil {
+		t.Fatalf("GenerateCoverageReport failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "test-case", "coverage.out")); err != nil {
+		t.Errorf("Coverage report was not generated: %v", err)
+	}
+}
+
 func TestRemapCoveragePaths_NoRemapping(t *testing.T) {
 	tempDir, _ := os.MkdirTemp("", "remap-test-*")
 	defer os.RemoveAll(tempDir)
@@ -608,6 +642,47 @@ func TestCoverageResponse_JSONSerialization(t *testing.T) {
 	}
 }
 
+func TestLoadPodMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    *PodMetadata
+		err     string
+	}{
+		{
+			name:    "current",
+			content: `{"schema_version":1,"pod_name":"app-7d9f","namespace":"default","container":{"name":"app","image":"quay.io/org/app:cover"},"coverage_port":9095}`,
+			want:    &PodMetadata{SchemaVersion: 1, PodName: "app-7d9f", Namespace: "default", Container: ContainerMetadata{Name: "app", Image: "quay.io/org/app:cover"}, CoveragePort: 9095},
+		},
+		{
+			name:    "unversioned",
+			content: `{"pod_name":"app-7d9f","namespace":"default","container":{"name":"app","image":""},"coverage_port":9095}`,
+			want:    &PodMetadata{PodName: "app-7d9f", Namespace: "default", Container: ContainerMetadata{Name: "app"}, CoveragePort: 9095},
+		},
+		{name: "newer", content: `{"schema_version":2,"pod_name":"app-7d9f"}`, err: "schema version 2 is newer"},
+		{name: "corrupt", content: `{"pod_name":`, err: "parse metadata"},
+		{name: "missing", err: "read metadata"},
+	} {
+		testDir := t.TempDir()
+		if tt.content != "" {
+			os.WriteFile(filepath.Join(testDir, "metadata.json"), []byte(tt.content), 0644)
+		}
+		metadata, err := LoadPodMetadata(testDir)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: LoadPodMetadata failed: %v", tt.name, err)
+		}
+		if metadata.PodName != tt.want.PodName || metadata.SchemaVersion != tt.want.SchemaVersion || metadata.Container != tt.want.Container || metadata.CoveragePort != tt.want.CoveragePort {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, metadata)
+		}
+	}
+}
+
 // func TestPrintCoverageSummary(t *testing.T) {
 // 	tempDir, _ := os.MkdirTemp("", "summary-test-*")
 // 	defer os.RemoveAll(tempDir)
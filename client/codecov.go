@@ -0,0 +1,291 @@
+package coverageclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const defaultCodecovURL = "https://codecov.io"
+
+// CodecovOptions configures UploadToCodecov. Commit, branch, PR, build and slug default to CI env vars.
+type CodecovOptions struct {
+	Token   string   // Upload token (default: $CODECOV_TOKEN)
+	UseOIDC bool     // Authenticate with a GitHub Actions OIDC token instead of an upload token (needs id-token: write)
+	Flags   []string // Codecov flags (default: the test name)
+	Name    string   // Upload name (default: the test name)
+	URL     string   // Codecov URL for self-hosted instances (default: https://codecov.io)
+	RepoDir string   // Repository root used to make paths repository-relative (default: the client's source directory)
+	Slug    string   // owner/repo (default: detected from CI env vars)
+	Commit  string   // Commit SHA (default: detected from CI env vars)
+	Branch  string   // Branch name (default: detected from CI env vars)
+	PR      int      // Pull request number (default: detected from CI env vars)
+	Build   string   // CI build ID (default: detected from CI env vars)
+}
+
+// UploadToCodecov uploads the coverage of a processed test to Codecov, so teams already on Codecov
+// can ingest cluster-collected e2e coverage. Profile paths (module import paths or remapped local
+// paths) are made repository-relative, and the upload is flagged with the test name by default.
+func (c *CoverageClient) UploadToCodecov(ctx context.Context, testName string, opts CodecovOptions) error {
+	if opts.URL == "" {
+		opts.URL = defaultCodecovURL
+	}
+	if opts.RepoDir == "" {
+		opts.RepoDir = c.sourceDir
+	}
+	if len(opts.Flags) == 0 {
+		opts.Flags = []string{codecovFlag(testName)}
+	}
+	if opts.Name == "" {
+		opts.Name = testName
+	}
+	if opts.Commit == "" {
+		opts.Commit = detectCommitSHA()
+	}
+	if opts.Commit == "" {
+		return fmt.Errorf("commit SHA not set and not detected from CI environment")
+	}
+	if opts.Slug == "" {
+		opts.Slug = detectGitHubRepository()
+	}
+	if opts.Branch == "" {
+		opts.Branch = detectBranch()
+	}
+	if opts.PR == 0 {
+		opts.PR = detectPRNumber()
+	}
+	if opts.Build == "" {
+		opts.Build = detectBuildID()
+	}
+
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	token := opts.Token
+	if opts.UseOIDC {
+		var err error
+		if token, err = requestGitHubOIDCToken(ctx, httpClient, opts.URL); err != nil {
+			return err
+		}
+	} else if token == "" {
+		token = os.Getenv("CODECOV_TOKEN")
+	}
+
+	profile, err := c.LoadProfile(testName)
+	if err != nil {
+		return err
+	}
+	relativizeProfilePaths(profile, opts.RepoDir)
+
+	report, err := codecovReport(profile)
+	if err != nil {
+		return err
+	}
+
+	c.logf("📤 Uploading coverage for test %s to Codecov (flags: %s)\n", testName, strings.Join(opts.Flags, ","))
+
+	resultURL, storageURL, err := codecovRequestUpload(ctx, httpClient, token, opts)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, storageURL, bytes.NewReader(report))
+	if err != nil {
+		return fmt.Errorf("create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upload report returned %d: %s", resp.StatusCode, body)
+	}
+
+	c.logf("✅ Coverage uploaded to Codecov: %s\n", resultURL)
+	return nil
+}
+
+// codecovRequestUpload announces an upload and returns the result and storage URLs
+func codecovRequestUpload(ctx context.Context, httpClient *http.Client, token string, opts CodecovOptions) (string, string, error) {
+	query := url.Values{}
+	query.Set("commit", opts.Commit)
+	query.Set("flags", strings.Join(opts.Flags, ","))
+	query.Set("name", opts.Name)
+	query.Set("package", "go-coverage-http")
+	query.Set("service", "custom")
+	if os.Getenv("GITHUB_ACTIONS") == "true" {
+		query.Set("service", "github-actions")
+	}
+	if opts.Slug != "" {
+		query.Set("slug", opts.Slug)
+	}
+	if opts.Branch != "" {
+		query.Set("branch", opts.Branch)
+	}
+	if opts.PR != 0 {
+		query.Set("pr", strconv.Itoa(opts.PR))
+	}
+	if opts.Build != "" {
+		query.Set("build", opts.Build)
+	}
+
+	uploadURL := strings.TrimSuffix(opts.URL, "/") + "/upload/v4?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain")
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("request upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("codecov returned %d: %s", resp.StatusCode, body)
+	}
+
+	// The response has the result URL on the first line and the storage URL on the second
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) < 2 {
+		return "", "", fmt.Errorf("unexpected codecov response: %s", body)
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1]), nil
+}
+
+// codecovReport builds an upload in Codecov's report format: the file network followed by the profile
+func codecovReport(profile *Profile) ([]byte, error) {
+	var buf bytes.Buffer
+	seen := make(map[string]bool)
+	for _, b := range profile.Blocks {
+		if !seen[b.File] {
+			seen[b.File] = true
+			buf.WriteString(b.File + "\n")
+		}
+	}
+	buf.WriteString("<<<<<< network\n")
+	buf.WriteString("# path=coverage.out\n")
+	if err := profile.Write(&buf); err != nil {
+		return nil, fmt.Errorf("write profile: %w", err)
+	}
+	buf.WriteString("<<<<<< EOF\n")
+	return buf.Bytes(), nil
+}
+
+// relativizeProfilePaths rewrites module import paths and absolute paths below repoDir
+// to repository-relative paths, as Codecov matches files against the repository tree
+func relativizeProfilePaths(profile *Profile, repoDir string) {
+	modulePath := readModulePath(repoDir)
+	absRepo, _ := filepath.Abs(repoDir)
+
+	for i, b := range profile.Blocks {
+		file := b.File
+		switch {
+		case modulePath != "" && strings.HasPrefix(file, modulePath+"/"):
+			file = strings.TrimPrefix(file, modulePath+"/")
+		case absRepo != "" && filepath.IsAbs(file):
+			if rel, err := filepath.Rel(absRepo, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = filepath.ToSlash(rel)
+			}
+		}
+		profile.Blocks[i].File = file
+	}
+}
+
+// readModulePath returns the module path declared in dir/go.mod ("" if unavailable)
+func readModulePath(dir string) string {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+	return ""
+}
+
+// codecovFlag turns a test name into a valid Codecov flag
+func codecovFlag(testName string) string {
+	flag := sanitizeName(testName)
+	if len(flag) > 45 {
+		flag = flag[:45]
+	}
+	return flag
+}
+
+// requestGitHubOIDCToken requests a GitHub Actions OIDC token for the given audience
+func requestGitHubOIDCToken(ctx context.Context, httpClient *http.Client, audience string) (string, error) {
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("OIDC token not available (needs GitHub Actions with id-token: write)")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL+"&audience="+url.QueryEscape(audience), nil)
+	if err != nil {
+		return "", fmt.Errorf("create OIDC request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("OIDC token request returned %d: %s", resp.StatusCode, body)
+	}
+
+	var token struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decode OIDC token: %w", err)
+	}
+	return token.Value, nil
+}
+
+// detectBranch returns the branch under test from CI env vars (GitHub Actions, GitLab, Prow, Jenkins, Buildkite)
+func detectBranch() string {
+	for _, name := range []string{"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "CI_COMMIT_REF_NAME",
+		"PULL_HEAD_REF", "PULL_BASE_REF", "CHANGE_BRANCH", "BRANCH_NAME", "BUILDKITE_BRANCH"} {
+		if branch := os.Getenv(name); branch != "" {
+			return branch
+		}
+	}
+	return ""
+}
+
+// detectBuildID returns the CI build ID (GitHub Actions, GitLab, Prow, Buildkite, Jenkins)
+func detectBuildID() string {
+	for _, name := range []string{"GITHUB_RUN_ID", "CI_PIPELINE_ID", "BUILD_ID", "BUILDKITE_BUILD_NUMBER", "BUILD_NUMBER"} {
+		if id := os.Getenv(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
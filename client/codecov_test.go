@@ -0,0 +1,103 @@
+package coverageclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadToCodecov(t *testing.T) {
+	repoDir := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "go.mod"), []byte("module github.com/example/app\n\ngo 1.24\n"), 0644)
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e/smoke"), 0755)
+	profile := testProfile + filepath.Join(repoDir, "cmd", "main.go") + ":1.1,2.2 1 1\n"
+	os.WriteFile(filepath.Join(outputDir, "e2e/smoke", "coverage.out"), []byte(profile), 0644)
+
+	var query, auth, uploaded string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/v4":
+			query, auth = r.URL.RawQuery, r.Header.Get("Authorization")
+			io.WriteString(w, "https://codecov.io/results\n"+server.URL+"/storage\n")
+		case r.Method == http.MethodPut && r.URL.Path == "/storage":
+			body, _ := io.ReadAll(r.Body)
+			uploaded = string(body)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: outputDir, sourceDir: repoDir, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	err := client.UploadToCodecov(context.Background(), "e2e/smoke", CodecovOptions{
+		Token:  "upload-token",
+		URL:    server.URL,
+		Commit: "abc123",
+		Slug:   "example/app",
+		PR:     7,
+	})
+	if err != nil {
+		t.Fatalf("UploadToCodecov failed: %v", err)
+	}
+
+	if auth != "token upload-token" {
+		t.Errorf("Unexpected authorization header: %q", auth)
+	}
+	for _, want := range []string{"commit=abc123", "flags=e2e_smoke", "pr=7", "slug=example%2Fapp"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %s, got %s", want, query)
+		}
+	}
+	for _, want := range []string{
+		"main.go\npkg/util/util.go\ncmd/main.go\n<<<<<< network\n",
+		"mode: atomic\nmain.go:10.2,12.3 2 1\n",
+		"cmd/main.go:1.1,2.2 1 1\n<<<<<< EOF\n",
+	} {
+		if !strings.Contains(uploaded, want) {
+			t.Errorf("Expected upload to contain %q, got:\n%s", want, uploaded)
+		}
+	}
+}
+
+func TestRequestGitHubOIDCToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != "https://codecov.io" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, `{"value": "oidc-jwt"}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+	token, err := requestGitHubOIDCToken(context.Background(), http.DefaultClient, "https://codecov.io")
+	if err != nil || token != "oidc-jwt" {
+		t.Errorf("Expected OIDC token, got %q, %v", token, err)
+	}
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+	if _, err := requestGitHubOIDCToken(context.Background(), http.DefaultClient, "https://codecov.io"); err == nil {
+		t.Error("Expected error outside GitHub Actions")
+	}
+}
+
+func TestCodecovFlag(t *testing.T) {
+	if flag := codecovFlag("e2e test/login-v1.2"); flag != "e2e_test_login-v1.2" {
+		t.Errorf("Unexpected flag: %s", flag)
+	}
+	if flag := codecovFlag(strings.Repeat("a", 60)); len(flag) != 45 {
+		t.Errorf("Expected flag to be truncated to 45 characters, got %d", len(flag))
+	}
+}
@@ -0,0 +1,220 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reset requests, see the server's resetParam
+const (
+	resetParam          = "reset"
+	coverageResetHeader = "X-Coverage-Reset"
+)
+
+// defaultRetryDelay is the wait between collection attempts when CollectOptions.RetryDelay is unset
+const defaultRetryDelay = 2 * time.Second
+
+// OutputLayout decides which test directory a collection is saved into
+type OutputLayout string
+
+const (
+	// LayoutByTest saves into <output>/<test>: collections of a test accumulate (default)
+	LayoutByTest OutputLayout = "test"
+	// LayoutByPod saves into <output>/<test>-<pod>, e.g. one directory per replica
+	LayoutByPod OutputLayout = "pod"
+	// LayoutByRun saves into <output>/<test>-<timestamp>, one directory per collection
+	LayoutByRun OutputLayout = "run"
+)
+
+// CollectOptions configures Collect. Set one target: PodName, LabelSelector, ServiceName, URL, or
+// Backend and Target.
+type CollectOptions struct {
+	TestName string // Name of the collection, the test directory follows Layout
+
+	PodName       string // Pod to collect from
+	LabelSelector string // Or: first running pod matching the selector, resolved on every attempt
+	ServiceName   string // Or: the Service in front of the pods (MethodService)
+	URL           string // Or: the coverage endpoint, e.g. http://localhost:9095/coverage
+	Backend       string // Or: a registered or built-in backend (see RegisterCollector) collecting Target
+	Target        string
+
+	Namespace string          // Namespace of the target (default: the client's)
+	Container string          // Container serving coverage (default: auto-detected)
+	Port      int             // Coverage port (default: the client's, see SetCoveragePort)
+	PortName  string          // Or: name of the pod's coverage container port, e.g. "coverage"
+	Fallback  FallbackOptions // Methods to try for pods (default: DefaultFallbackMethods)
+
+	Reset      bool          // Clear the application's counters once collected, so the next collection only covers what ran in between
+	Retries    int           // Attempts after a failed one (default: 0)
+	RetryDelay time.Duration // Wait between attempts (default: 2s)
+	Layout     OutputLayout  // Test directory to save into (default: LayoutByTest)
+}
+
+// Collect collects coverage as configured by opts, retrying failed attempts, and returns the test
+// directory's name and files for ProcessCoverageReports, PushCoverageArtifact and the like. It
+// fails when no covdata files were saved. Applications without coverage (ErrCoverageNotEnabled)
+// aren't retried. With Reset, a retry after the application cleared its counters only collects
+// what ran since.
+func (c *CoverageClient) Collect(ctx context.Context, opts CollectOptions) (*CollectResult, error) {
+	if opts.TestName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	targets := 0
+	for _, target := range []string{opts.PodName, opts.LabelSelector, opts.ServiceName, opts.URL, opts.Backend} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return nil, fmt.Errorf("collect: set one of PodName, LabelSelector, ServiceName, URL or Backend")
+	}
+	if opts.PortName != "" && (opts.Port != 0 || opts.PodName == "" && opts.LabelSelector == "") {
+		return nil, fmt.Errorf("collect: PortName needs PodName or LabelSelector and no Port")
+	}
+	if opts.Reset {
+		ctx = withCounterReset(ctx)
+	}
+	c = c.inNamespace(opts.Namespace)
+	delay := opts.RetryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+	started := time.Now()
+
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			c.logf("🔁 Retrying collection for test %s in %v (attempt %d of %d): %v\n", opts.TestName, delay, attempt+1, opts.Retries+1, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("collect: %w", ctx.Err())
+			}
+		}
+
+		var result *CollectResult
+		result, err = c.collectWithEvents(ctx, opts.TestName, collectTarget(opts), attempt+1, func(ctx context.Context) (*CollectResult, error) {
+			return c.collectOnce(ctx, opts, started)
+		})
+		if err == nil {
+			return result, nil
+		}
+		if errors.Is(err, ErrCoverageNotEnabled) || ctx.Err() != nil {
+			break
+		}
+	}
+	if opts.Retries > 0 {
+		return nil, fmt.Errorf("collect after %d attempts: %w", opts.Retries+1, err)
+	}
+	return nil, err
+}
+
+// collectOnce runs one collection attempt of Collect
+func (c *CoverageClient) collectOnce(ctx context.Context, opts CollectOptions, started time.Time) (*CollectResult, error) {
+	podName := opts.PodName
+	if opts.LabelSelector != "" {
+		var err error
+		if podName, err = c.GetPodNameWithContext(ctx, opts.LabelSelector); err != nil {
+			return nil, err
+		}
+	}
+	testName := outputTestName(opts, podName, started)
+
+	var collector Collector
+	target := podName
+	switch {
+	case opts.Backend != "":
+		var err error
+		if collector, err = c.collector(opts.Backend); err != nil {
+			return nil, err
+		}
+		target = opts.Target
+	case opts.URL != "":
+		collector, target = &URLCollector{Client: c}, opts.URL
+	case opts.ServiceName != "":
+		fallback := FallbackOptions{ServiceName: opts.ServiceName}
+		if err := c.collectWithMethod(ctx, MethodService, "", testName, c.coveragePort(opts.Port), fallback); err != nil {
+			return nil, err
+		}
+		return c.checkCollectResult(opts.ServiceName, c.collectResult(testName, MethodService))
+	default:
+		fallback := opts.Fallback
+		if opts.Container != "" {
+			fallback.ContainerName = opts.Container
+		}
+		if opts.PortName != "" {
+			port, container, err := c.ResolvePortName(ctx, podName, opts.PortName)
+			if err != nil {
+				return nil, err
+			}
+			opts.Port = port
+			if fallback.ContainerName == "" {
+				fallback.ContainerName = container
+			}
+		}
+		collector = &KubernetesCollector{Client: c, Port: opts.Port, Fallback: fallback}
+	}
+
+	result, err := collector.Collect(ctx, target, testName)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &CollectResult{TestName: testName}
+	}
+	return c.checkCollectResult(target, result)
+}
+
+// collectTarget describes the target of opts for collection events
+func collectTarget(opts CollectOptions) string {
+	for _, target := range []string{opts.PodName, opts.LabelSelector, opts.ServiceName, opts.URL, opts.Target} {
+		if target != "" {
+			return target
+		}
+	}
+	return opts.Backend
+}
+
+// checkCollectResult fills in the covdata files a backend didn't list or describe, failing when
+// there are none
+func (c *CoverageClient) checkCollectResult(target string, result *CollectResult) (*CollectResult, error) {
+	if len(result.Files) == 0 {
+		result.Files = covdataFiles(c.testDir(result.TestName))
+	}
+	if len(result.Files) == 0 {
+		return nil, fmt.Errorf("no coverage data collected from %s for test %s", target, result.TestName)
+	}
+	if result.Sizes == nil {
+		c.describeFiles(result)
+	}
+	return result, nil
+}
+
+// outputTestName returns the test directory name of a collection following opts.Layout
+func outputTestName(opts CollectOptions, podName string, started time.Time) string {
+	switch opts.Layout {
+	case LayoutByPod:
+		if podName != "" {
+			return opts.TestName + "-" + podName
+		}
+	case LayoutByRun:
+		return opts.TestName + "-" + started.UTC().Format("20060102-150405")
+	}
+	return opts.TestName
+}
+
+// counterResetKey marks contexts of collections that reset the application's counters
+type counterResetKey struct{}
+
+// withCounterReset returns a context whose coverage requests ask the server to reset its counters
+func withCounterReset(ctx context.Context) context.Context {
+	return context.WithValue(ctx, counterResetKey{}, true)
+}
+
+// counterReset reports whether coverage requests made with ctx reset the server's counters
+func counterReset(ctx context.Context) bool {
+	reset, _ := ctx.Value(counterResetKey{}).(bool)
+	return reset
+}
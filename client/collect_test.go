@@ -0,0 +1,179 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCollect_Options(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	tests := []struct {
+		name string
+		opts CollectOptions
+	}{
+		{"no test name", CollectOptions{URL: "http://localhost:9095/coverage"}},
+		{"no target", CollectOptions{TestName: "e2e"}},
+		{"two targets", CollectOptions{TestName: "e2e", PodName: "app", URL: "http://localhost:9095/coverage"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Collect(context.Background(), tt.opts); err == nil {
+				t.Error("Expected invalid options to fail")
+			}
+		})
+	}
+}
+
+func TestCollect_Layout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), quiet: true}
+	result, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", URL: server.URL + "/coverage", Layout: LayoutByRun})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if !strings.HasPrefix(result.TestName, "e2e-") || len(result.Files) != 2 {
+		t.Errorf("Expected a timestamped test directory, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, result.TestName)); err != nil {
+		t.Errorf("Expected the test directory to exist: %v", err)
+	}
+
+	if got := outputTestName(CollectOptions{TestName: "e2e", Layout: LayoutByPod}, "app-7d9f", time.Now()); got != "e2e-app-7d9f" {
+		t.Errorf("Expected the pod layout to append the pod name, got %s", got)
+	}
+	if got := outputTestName(CollectOptions{TestName: "e2e", Layout: LayoutByPod}, "", time.Now()); got != "e2e" {
+		t.Errorf("Expected the pod layout without a pod to keep the test name, got %s", got)
+	}
+}
+
+func TestCollect_Retries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "starting up", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), quiet: true}
+	opts := CollectOptions{TestName: "e2e", URL: server.URL + "/coverage", Retries: 2, RetryDelay: time.Millisecond}
+	if _, err := client.Collect(context.Background(), opts); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected one retry, got %d requests", requests)
+	}
+}
+
+func TestCollect_NoRetryWithoutCoverage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), quiet: true}
+	opts := CollectOptions{TestName: "e2e", URL: server.URL + "/coverage", Retries: 3, RetryDelay: time.Millisecond}
+	_, err := client.Collect(context.Background(), opts)
+	if !errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected ErrCoverageNotEnabled, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no retries, got %d requests", requests)
+	}
+}
+
+func TestCollect_Reset(t *testing.T) {
+	var queries []string
+	var bases []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		bases = append(bases, r.Header.Get(coverageBaseHeader))
+		w.Header().Set(coverageResetHeader, "true")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, fmt.Sprintf("covcounters.%s.1.%d", newBinaryHash, len(queries))))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), counterBases: &counterBases{}, metaCache: &metaCache{}, quiet: true}
+	opts := CollectOptions{TestName: "e2e", URL: server.URL + "/coverage", Reset: true}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Collect(context.Background(), opts); err != nil {
+			t.Fatalf("Collect failed: %v", err)
+		}
+	}
+	for i, query := range queries {
+		if !strings.Contains(query, resetParam+"=true") || strings.Contains(query, countersOnlyParam) {
+			t.Errorf("Request %d: expected a full request with reset, got query %q", i, query)
+		}
+		if bases[i] != "" {
+			t.Errorf("Request %d: expected no counter base, got %q", i, bases[i])
+		}
+	}
+}
+
+func TestCollect_LabelSelector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-7d9f", Namespace: "default", Labels: map[string]string{"app": "demo"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 9095}}},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	forwarder := &fakePortForwarder{port: port}
+	client := &CoverageClient{
+		clientset:      fake.NewSimpleClientset(pod),
+		namespace:      "default",
+		outputDir:      t.TempDir(),
+		httpClient:     server.Client(),
+		recordDisabled: true,
+		quiet:          true,
+	}
+	client.SetPortForwarder(forwarder)
+
+	result, err := client.Collect(context.Background(), CollectOptions{
+		TestName:      "e2e",
+		LabelSelector: "app=demo",
+		Layout:        LayoutByPod,
+		Fallback:      FallbackOptions{Methods: []CollectionMethod{MethodPortForward}},
+	})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if result.TestName != "e2e-app-7d9f" || result.Method != MethodPortForward {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(forwarder.tunnels) != 1 || forwarder.tunnels[0].podName != "app-7d9f" {
+		t.Errorf("Expected one tunnel to the selected pod, got %+v", forwarder.tunnels)
+	}
+
+	if _, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", LabelSelector: "app=missing"}); !errors.Is(err, ErrNoPodsFound) {
+		t.Errorf("Expected ErrNoPodsFound, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package coverageclient
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CollectCoverageFromCollector downloads all coverage pushed to an in-cluster collector
+// for the given suite and stores it in the test directory, ready for GenerateCoverageReport
+func (c *CoverageClient) CollectCoverageFromCollector(ctx context.Context, collectorURL, suite, testName string) error {
+	c.logf("📊 Downloading coverage for suite %s from collector %s\n", suite, collectorURL)
+	return c.downloadFromCollector(ctx, strings.TrimSuffix(collectorURL, "/")+"/coverage/"+url.PathEscape(suite), testName)
+}
+
+// CollectServiceCoverageFromCollector downloads the coverage pushed to a collector for one service
+// of a suite, by applications setting COVERAGE_SERVICE
+func (c *CoverageClient) CollectServiceCoverageFromCollector(ctx context.Context, collectorURL, suite, service, testName string) error {
+	c.logf("📊 Downloading coverage for service %s of suite %s from collector %s\n", service, suite, collectorURL)
+	return c.downloadFromCollector(ctx, strings.TrimSuffix(collectorURL, "/")+"/coverage/"+url.PathEscape(suite)+"/"+url.PathEscape(service), testName)
+}
+
+// downloadFromCollector saves the covdata archive at downloadURL into the test directory
+func (c *CoverageClient) downloadFromCollector(ctx context.Context, downloadURL, testName string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send download request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("collector returned %d: %s", resp.StatusCode, body)
+	}
+	if err := c.checkResponseSize(resp.ContentLength, testName); err != nil {
+		return err
+	}
+
+	testDir, err := c.createTestDir(testName)
+	if err != nil {
+		return err
+	}
+
+	files, err := extractTarGz(resp.Body, testDir, c.responseLimit())
+	if err != nil {
+		return fmt.Errorf("extract coverage archive: %w", err)
+	}
+	if err := c.separateEarlierBinaries(testDir, files); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		c.logf("  📁 Saved: %s\n", f)
+	}
+	c.logf("✅ Coverage downloaded from collector for test: %s (%d files)\n", testName, len(files))
+	return nil
+}
+
+// CollectCoverageFromCollectorPod downloads suite coverage from a collector pod via port-forwarding
+func (c *CoverageClient) CollectCoverageFromCollectorPod(ctx context.Context, podName string, port int, suite, testName string) error {
+	tunnel, err := c.setupPortForward(ctx, podName, port)
+	if err != nil {
+		return fmt.Errorf("setup port forward: %w", err)
+	}
+	defer tunnel.Close()
+
+	return c.CollectCoverageFromCollector(ctx, fmt.Sprintf("http://localhost:%d", tunnel.LocalPort()), suite, testName)
+}
+
+// extractTarGz extracts regular files from a gzipped tar stream into destDir, failing once the
+// uncompressed archive exceeds limit bytes (negative: unlimited)
+func extractTarGz(r io.Reader, destDir string, limit int64) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	var files []string
+	tr := tar.NewReader(limitReader(gz, limit))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return files, fmt.Errorf("read tar entry: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Only accept plain file names to prevent path traversal
+		name := filepath.Base(filepath.Clean(header.Name))
+		if name != header.Name || name == "." || name == ".." {
+			return files, fmt.Errorf("unexpected path in archive: %s", header.Name)
+		}
+
+		path := filepath.Join(destDir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return files, fmt.Errorf("create %s: %w", name, err)
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return files, fmt.Errorf("write %s: %w", name, err)
+		}
+		if err := f.Close(); err != nil {
+			return files, fmt.Errorf("close %s: %w", name, err)
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,102 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/psturc/go-coverage-http/collector"
+)
+
+func TestCollectCoverageFromCollector(t *testing.T) {
+	server, err := collector.NewServer(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create collector: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	body, _ := json.Marshal(collector.PushRequest{
+		MetaFilename:     "covmeta.abc",
+		MetaData:         base64.StdEncoding.EncodeToString([]byte("meta")),
+		CountersFilename: "covcounters.abc.1.100",
+		CountersData:     base64.StdEncoding.EncodeToString([]byte("counters")),
+		Suite:            "e2e",
+		PodName:          "pod-a",
+	})
+	resp, err := http.Post(ts.URL+"/push", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	resp.Body.Close()
+
+	outputDir := t.TempDir()
+	client := &CoverageClient{
+		outputDir:  outputDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.CollectCoverageFromCollector(context.Background(), ts.URL, "e2e", "suite-test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "suite-test", "covcounters.abc.1.100"))
+	if err != nil {
+		t.Fatalf("Counters file not downloaded: %v", err)
+	}
+	if string(data) != "counters" {
+		t.Errorf("Unexpected counters content: %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "suite-test", "covmeta.abc")); err != nil {
+		t.Errorf("Meta file not downloaded: %v", err)
+	}
+
+	if err := client.CollectCoverageFromCollector(context.Background(), ts.URL, "missing", "suite-test"); err == nil {
+		t.Error("Expected error for unknown suite")
+	}
+}
+
+func TestCollectServiceCoverageFromCollector(t *testing.T) {
+	server, _ := collector.NewServer(t.TempDir())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	for service, counters := range map[string]string{"api": "covcounters.abc.1.100", "migrate": "covcounters.abc.1.200"} {
+		body, _ := json.Marshal(collector.PushRequest{
+			MetaFilename:     "covmeta.abc",
+			MetaData:         base64.StdEncoding.EncodeToString([]byte("meta")),
+			CountersFilename: counters,
+			CountersData:     base64.StdEncoding.EncodeToString([]byte("counters")),
+			Suite:            "run-42",
+			PodName:          service + "-pod",
+			Service:          service,
+		})
+		resp, err := http.Post(ts.URL+"/push", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: &http.Client{Timeout: 10 * time.Second}, quiet: true}
+	if err := client.CollectServiceCoverageFromCollector(context.Background(), ts.URL, "run-42", "migrate", "migrate"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files := covdataFiles(filepath.Join(client.outputDir, "migrate"))
+	if len(files) != 2 {
+		t.Errorf("Expected the migrate service's meta-data and counters, got %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "migrate", "covcounters.abc.1.100")); err == nil {
+		t.Error("Expected the api service's counters to be left out")
+	}
+	if err := client.CollectServiceCoverageFromCollector(context.Background(), ts.URL, "run-42", "missing", "missing"); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
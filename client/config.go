@@ -0,0 +1,151 @@
+package coverageclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is a client configuration file read by LoadConfig, in YAML or JSON:
+//
+//	namespace: my-app
+//	outputDir: ./coverage-output
+//	filters: [coverage_server.go, "*_mock.go"]
+//	pathMappings:
+//	  /workspace/vendor: ./vendor
+//	thresholds:
+//	  minTotal: 70
+//	  minPatch: 80
+//	artifact:
+//	  registry: quay.io
+//	  repository: my-org/coverage
+//	  tag: "{test}-main"
+//
+// Relative paths are resolved against the directory of the file. Settings left out keep the
+// client's defaults, including those from COVERAGE_* environment variables.
+type Config struct {
+	Namespace   string `json:"namespace,omitempty"`   // Namespace of the pods (none: a client without cluster access, see NewLocalClient)
+	KubeContext string `json:"kubeContext,omitempty"` // kubeconfig context (default: current context)
+	OutputDir   string `json:"outputDir,omitempty"`   // Directory coverage is collected into (default: coverage-output)
+	Port        int    `json:"port,omitempty"`        // Coverage port, see SetCoveragePort
+
+	SourceDir     string            `json:"sourceDir,omitempty"`     // Local source directory for path remapping (default: current directory)
+	PathRemapping *bool             `json:"pathRemapping,omitempty"` // Remap container paths to local ones (default: true)
+	PathMappings  map[string]string `json:"pathMappings,omitempty"`  // Explicit remapping rules, see SetPathMappings
+	Filters       []string          `json:"filters,omitempty"`       // File patterns filtered from reports (default: coverage_server.go)
+	Packages      []string          `json:"packages,omitempty"`      // Package selectors, see SetPackageSelectors
+
+	Thresholds CoverageThresholds           `json:"thresholds"`         // Gate rules, see SetThresholds
+	Artifact   *PushCoverageArtifactOptions `json:"artifact,omitempty"` // Artifact destination, see SetArtifactDestination
+	Baseline   *BaselineOptions             `json:"baseline,omitempty"` // Baseline artifacts, see SetBaseline
+
+	Exec            *bool  `json:"exec,omitempty"`            // Exec into containers (default: true), see SetExecEnabled
+	PortForwardOnly bool   `json:"portForwardOnly,omitempty"` // See SetPortForwardOnly
+	Retries         int    `json:"retries,omitempty"`         // Port-forward collection retries, see SetRetryPolicy
+	Quiet           bool   `json:"quiet,omitempty"`           // See SetQuiet
+	LogLevel        string `json:"logLevel,omitempty"`        // debug, info, warn or error, see SetLogLevel
+	LogFormat       string `json:"logFormat,omitempty"`       // text or json (stdout), see NewJSONLogger
+}
+
+// LoadConfig creates a client configured by the YAML or JSON file at path, so test repositories
+// keep their coverage settings in a versioned file. Unknown keys are rejected to catch typos.
+func LoadConfig(path string) (*CoverageClient, error) {
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientFromConfig(cfg, filepath.Dir(path))
+}
+
+// ReadConfig parses the YAML or JSON configuration file at path without creating a client
+func ReadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// NewClientFromConfig creates a client configured by cfg, resolving relative paths against baseDir
+func NewClientFromConfig(cfg Config, baseDir string) (*CoverageClient, error) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(baseDir, path)
+	}
+
+	var c *CoverageClient
+	var err error
+	if cfg.Namespace != "" {
+		c, err = NewClientForContext(cfg.KubeContext, cfg.Namespace, resolve(cfg.OutputDir))
+	} else {
+		c, err = NewLocalClient(resolve(cfg.OutputDir))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Port != 0 {
+		c.SetCoveragePort(cfg.Port)
+	}
+	if cfg.SourceDir != "" {
+		c.SetSourceDirectory(resolve(cfg.SourceDir))
+	}
+	if cfg.PathRemapping != nil {
+		c.SetPathRemapping(*cfg.PathRemapping)
+	}
+	if len(cfg.PathMappings) > 0 {
+		rules := make(map[string]string, len(cfg.PathMappings))
+		for prefix, dir := range cfg.PathMappings {
+			rules[prefix] = resolve(dir)
+		}
+		c.SetPathMappings(rules)
+	}
+	if cfg.Filters != nil {
+		c.SetDefaultFilters(cfg.Filters)
+	}
+	if cfg.Packages != nil {
+		c.SetPackageSelectors(cfg.Packages)
+	}
+	if cfg.Thresholds != (CoverageThresholds{}) {
+		c.SetThresholds(cfg.Thresholds)
+	}
+	if cfg.Artifact != nil {
+		c.SetArtifactDestination(*cfg.Artifact)
+	}
+	if cfg.Baseline != nil {
+		c.SetBaseline(*cfg.Baseline)
+	}
+	if cfg.Exec != nil {
+		c.SetExecEnabled(*cfg.Exec)
+	}
+	if cfg.PortForwardOnly {
+		c.SetPortForwardOnly(true)
+	}
+	if cfg.Retries > 0 {
+		c.SetRetryPolicy(RetryPolicy{Attempts: cfg.Retries})
+	}
+	if cfg.Quiet {
+		c.SetQuiet(true)
+	}
+	if cfg.LogLevel != "" {
+		level, err := ParseLevel(cfg.LogLevel)
+		if err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+		c.SetLogLevel(level)
+	}
+	if cfg.LogFormat != "" {
+		if err := c.setLogFormat(cfg.LogFormat); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+	return c, nil
+}
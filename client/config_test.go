@@ -0,0 +1,97 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_YAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "coverage.yaml")
+	os.WriteFile(path, []byte(`
+outputDir: out
+sourceDir: src
+filters: [coverage_server.go, "*_mock.go"]
+packages: ["github.com/org/app/..."]
+pathMappings:
+  /workspace/vendor: vendor
+thresholds:
+  minTotal: 70
+  maxDecrease: 1.5
+artifact:
+  registry: quay.io
+  repository: org/coverage
+  tag: "{test}-main"
+  expiresAfter: 30d
+exec: false
+quiet: true
+logLevel: error
+`), 0644)
+
+	client, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if client.outputDir != filepath.Join(dir, "out") || client.sourceDir != filepath.Join(dir, "src") {
+		t.Errorf("Expected paths relative to the config file, got output %s and source %s", client.outputDir, client.sourceDir)
+	}
+	if _, err := os.Stat(client.outputDir); err != nil {
+		t.Errorf("Expected the output directory to be created: %v", err)
+	}
+	if strings.Join(client.defaultFilters, ",") != "coverage_server.go,*_mock.go" || len(client.packages) != 1 {
+		t.Errorf("Unexpected filters %v and packages %v", client.defaultFilters, client.packages)
+	}
+	if client.pathRules["/workspace/vendor"] != filepath.Join(dir, "vendor") {
+		t.Errorf("Unexpected path rules %v", client.pathRules)
+	}
+	if client.thresholds != (CoverageThresholds{MinTotal: 70, MaxDecrease: 1.5}) {
+		t.Errorf("Unexpected thresholds %+v", client.thresholds)
+	}
+	if !client.disableExec || !client.quiet || client.clientset != nil {
+		t.Errorf("Expected exec disabled, quiet output and no cluster access")
+	}
+	if client.logLevel != LevelError {
+		t.Errorf("Expected the error log level, got %v", client.logLevel)
+	}
+
+	opts := client.artifactOptions("e2e", PushCoverageArtifactOptions{})
+	if opts.Registry != "quay.io" || opts.Repository != "org/coverage" || opts.Tag != "e2e-main" || opts.ExpiresAfter != "30d" {
+		t.Errorf("Unexpected artifact options %+v", opts)
+	}
+	opts = client.artifactOptions("e2e", PushCoverageArtifactOptions{Registry: "ghcr.io", Repository: "org/other"})
+	if opts.Registry != "ghcr.io" || opts.Tag != "" {
+		t.Errorf("Expected explicit options to win, got %+v", opts)
+	}
+}
+
+func TestLoadConfig_JSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "coverage.json")
+	os.WriteFile(path, []byte(`{"outputDir": "out", "thresholds": {"minPatch": 80}, "pathRemapping": false}`), 0644)
+
+	client, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if client.thresholds.MinPatch != 80 || client.enablePathRemap {
+		t.Errorf("Unexpected configuration: thresholds %+v, path remapping %v", client.thresholds, client.enablePathRemap)
+	}
+	if strings.Join(client.defaultFilters, ",") != "coverage_server.go" {
+		t.Errorf("Expected the default filters to stay, got %v", client.defaultFilters)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected a missing file to fail")
+	}
+
+	path := filepath.Join(dir, "coverage.yaml")
+	os.WriteFile(path, []byte("outputDir: out\nthreshold:\n  minTotal: 70\n"), 0644)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "threshold") {
+		t.Errorf("Expected the misspelled key to be rejected, got %v", err)
+	}
+}
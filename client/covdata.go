@@ -0,0 +1,233 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CovdataGroup holds the covdata files written by one instrumented binary, identified by its meta hash
+type CovdataGroup struct {
+	Hash     string   // Meta-data hash shared by all processes running the same binary
+	Meta     string   // covmeta.<hash> file name (empty when missing)
+	Counters []string // covcounters.<hash>.<pid>.<timestamp> file names, one or more per process
+}
+
+// GroupCovdataFiles groups covdata file names by meta hash, sorted by hash.
+// Files that aren't covmeta/covcounters files are ignored.
+func GroupCovdataFiles(names []string) []CovdataGroup {
+	groups := make(map[string]*CovdataGroup)
+	group := func(hash string) *CovdataGroup {
+		if groups[hash] == nil {
+			groups[hash] = &CovdataGroup{Hash: hash}
+		}
+		return groups[hash]
+	}
+
+	for _, name := range names {
+		base := filepath.Base(name)
+		switch {
+		case strings.HasPrefix(base, "covmeta."):
+			group(strings.TrimPrefix(base, "covmeta.")).Meta = base
+		case strings.HasPrefix(base, "covcounters."):
+			hash, _, _ := strings.Cut(strings.TrimPrefix(base, "covcounters."), ".")
+			g := group(hash)
+			g.Counters = append(g.Counters, base)
+		}
+	}
+
+	result := make([]CovdataGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Strings(g.Counters)
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Hash < result[j].Hash })
+	return result
+}
+
+// pruneCovdataDir removes counters files without a matching meta file, which would make
+// `go tool covdata` fail, and reports the instrumented binaries found in dir
+func (c *CoverageClient) pruneCovdataDir(dir string) ([]CovdataGroup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read coverage directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	groups := GroupCovdataFiles(names)
+	var valid []CovdataGroup
+	for _, g := range groups {
+		if g.Meta == "" {
+			c.logf("  ⚠️  Dropping %d counters file(s) for hash %s without meta-data\n", len(g.Counters), g.Hash)
+			for _, counters := range g.Counters {
+				if err := os.Remove(filepath.Join(dir, counters)); err != nil {
+					return nil, fmt.Errorf("remove orphaned counters: %w", err)
+				}
+			}
+			continue
+		}
+		valid = append(valid, g)
+	}
+
+	if len(valid) > 1 {
+		c.logf("  🔍 Found %d instrumented binaries sharing GOCOVERDIR\n", len(valid))
+	}
+	for _, g := range valid {
+		c.logf("  📦 %s: %d counters file(s)\n", g.Meta, len(g.Counters))
+	}
+	return valid, nil
+}
+
+// listCovdataPackages returns the import paths of the packages in the coverage meta-data in dir
+func listCovdataPackages(dir string) ([]string, error) {
+	// -i takes a comma-separated list, run in dir so its path can contain commas
+	cmd := exec.Command("go", "tool", "covdata", "pkglist", "-i=.")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("list coverage packages: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// selectCovdataPackages returns the packages in the coverage meta-data in dir that match the
+// package selectors, as exact import paths for `go tool covdata -pkg` (which can't exclude)
+func (c *CoverageClient) selectCovdataPackages(dir string, selectors []string) ([]string, error) {
+	list := listCovdataPackages
+	if c.useNativeCovdata() {
+		list = listCovdataPackagesNative
+	}
+	packages, err := list(dir)
+	if err != nil {
+		return nil, err
+	}
+	selected := selectPackages(packages, selectors)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no packages match selectors %v (coverage has %v)", selectors, packages)
+	}
+	c.logf("📦 Selected %d of %d package(s) with %v\n", len(selected), len(packages), selectors)
+	return selected, nil
+}
+
+// selectPackages returns the packages matching any include selector (all packages when there are
+// only exclusions) and no "!" exclude selector
+func selectPackages(packages, selectors []string) []string {
+	var include, exclude []func(string) bool
+	for _, selector := range selectors {
+		if pattern, ok := strings.CutPrefix(selector, "!"); ok {
+			exclude = append(exclude, packagePatternMatcher(pattern))
+		} else if selector != "" {
+			include = append(include, packagePatternMatcher(selector))
+		}
+	}
+
+	matchAny := func(matchers []func(string) bool, pkg string) bool {
+		for _, match := range matchers {
+			if match(pkg) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var selected []string
+	for _, pkg := range packages {
+		if (len(include) == 0 || matchAny(include, pkg)) && !matchAny(exclude, pkg) {
+			selected = append(selected, pkg)
+		}
+	}
+	return selected
+}
+
+// packagePatternMatcher matches import paths against a pattern like the go command: "..." matches
+// any string and a trailing "/..." also matches the path before it
+func packagePatternMatcher(pattern string) func(string) bool {
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\.\.\.`, `.*`)
+	if trimmed, ok := strings.CutSuffix(expr, `/.*`); ok {
+		expr = trimmed + `(/.*)?`
+	}
+	re := regexp.MustCompile("^" + expr + "$")
+	return re.MatchString
+}
+
+// CollectCoverageFromGocoverdir copies the covdata files of remoteDir out of a container by
+// exec'ing tar in it, for applications that set GOCOVERDIR but can't embed the coverage server.
+// Go writes counters files when the process exits (or calls runtime/coverage.WriteCountersDir),
+// so GOCOVERDIR should be a volume outliving the container's restarts. An empty containerName picks
+// the pod's first container, an empty remoteDir the container's GOCOVERDIR (default:
+// /tmp/coverage). The image needs sh, ls, grep and tar, e.g. from busybox.
+func (c *CoverageClient) CollectCoverageFromGocoverdir(ctx context.Context, podName, containerName, remoteDir, testName string) error {
+	c.logf("📦 Copying GOCOVERDIR of pod %s for test: %s\n", podName, testName)
+	if err := c.collectCoverageViaCoverDirAt(ctx, podName, containerName, remoteDir, testName, 0); err != nil {
+		c.logf("❌ Failed to copy GOCOVERDIR: %v\n", err)
+		return err
+	}
+	c.logf("✅ Coverage copied from GOCOVERDIR\n")
+	return nil
+}
+
+// collectCoverageViaCoverDir streams GOCOVERDIR out of the container as a tarball, extracted while
+// it's received so the response size guard applies as it's read
+func (c *CoverageClient) collectCoverageViaCoverDir(ctx context.Context, podName, containerName, testName string, targetPort int) error {
+	return c.collectCoverageViaCoverDirAt(ctx, podName, containerName, "", testName, targetPort)
+}
+
+// collectCoverageViaCoverDirAt streams the covdata files of remoteDir (empty: GOCOVERDIR) out of
+// the container as a tarball. Without containerName, the container exposing targetPort is used.
+func (c *CoverageClient) collectCoverageViaCoverDirAt(ctx context.Context, podName, containerName, remoteDir, testName string, targetPort int) error {
+	if containerName == "" {
+		pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("get pod details: %w", err)
+		}
+		containerName = containerForPort(pod.Spec.Containers, targetPort)
+	}
+
+	dir := fmt.Sprintf(`"${GOCOVERDIR:-%s}"`, defaultCoverDir)
+	if remoteDir != "" {
+		dir = shellQuote(remoteDir)
+	}
+	script := fmt.Sprintf(`cd %s && tar czf - $(ls | grep -E '^cov(meta|counters)\.')`, dir)
+	var testDir string
+	var files []string
+	err := c.execStream(ctx, podName, containerName, []string{"sh", "-c", script}, func(stdout io.Reader) error {
+		var err error
+		if testDir, err = c.createTestDir(testName); err != nil {
+			return err
+		}
+		if files, err = extractTarGz(stdout, testDir, c.responseLimit()); err != nil {
+			return fmt.Errorf("extract coverage archive: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	groups, err := c.pruneCovdataDir(testDir)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("no coverage data found in GOCOVERDIR of container %s", containerName)
+	}
+	return c.separateEarlierBinaries(testDir, files)
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,168 @@
+package coverageclient
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGroupCovdataFiles(t *testing.T) {
+	groups := GroupCovdataFiles([]string{
+		"covmeta.bbb",
+		"covcounters.bbb.7.300",
+		"covmeta.aaa",
+		"covcounters.aaa.2.200",
+		"covcounters.aaa.1.100",
+		"metadata.json",
+		"covcounters.ccc.9.900",
+	})
+
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %+v", groups)
+	}
+	if groups[0].Hash != "aaa" || groups[0].Meta != "covmeta.aaa" || len(groups[0].Counters) != 2 || groups[0].Counters[0] != "covcounters.aaa.1.100" {
+		t.Errorf("Unexpected group for aaa: %+v", groups[0])
+	}
+	if groups[1].Hash != "bbb" || len(groups[1].Counters) != 1 {
+		t.Errorf("Unexpected group for bbb: %+v", groups[1])
+	}
+	if groups[2].Hash != "ccc" || groups[2].Meta != "" {
+		t.Errorf("Expected ccc group without meta, got %+v", groups[2])
+	}
+}
+
+func TestPruneCovdataDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"covmeta.aaa", "covcounters.aaa.1.100", "covmeta.bbb", "covcounters.bbb.1.100", "covcounters.ccc.1.100"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := (&CoverageClient{}).pruneCovdataDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Errorf("Expected 2 instrumented binaries, got %+v", groups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "covcounters.ccc.1.100")); !os.IsNotExist(err) {
+		t.Error("Expected orphaned counters file to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "covcounters.bbb.1.100")); err != nil {
+		t.Errorf("Expected counters with meta-data to be kept: %v", err)
+	}
+}
+
+func TestSelectPackages(t *testing.T) {
+	packages := []string{
+		"github.com/example/app",
+		"github.com/example/app/internal/mocks",
+		"github.com/example/app/pkg/util",
+		"github.com/example/application",
+		"github.com/other/dep",
+	}
+
+	tests := []struct {
+		selectors []string
+		expected  []string
+	}{
+		{[]string{"github.com/example/app/..."}, packages[:3]},
+		{[]string{"github.com/example/app"}, packages[:1]},
+		{[]string{"github.com/example/app/...", "!.../mocks"}, []string{"github.com/example/app", "github.com/example/app/pkg/util"}},
+		{[]string{"!github.com/other/..."}, packages[:4]},
+		{[]string{"github.com/example/app.../util", "github.com/other/dep"}, []string{"github.com/example/app/pkg/util", "github.com/other/dep"}},
+		{[]string{"example.com/..."}, nil},
+	}
+
+	for _, tt := range tests {
+		if selected := selectPackages(packages, tt.selectors); !reflect.DeepEqual(selected, tt.expected) {
+			t.Errorf("selectPackages(%v): expected %v, got %v", tt.selectors, tt.expected, selected)
+		}
+	}
+}
+
+func TestCollectCoverageFromGocoverdir(t *testing.T) {
+	archive := coverageArchive(map[string]string{
+		"covmeta." + newBinaryHash:                string(covmetaFile(newBinaryHash)),
+		"covcounters." + newBinaryHash + ".1.100": string(covcountersFile(newBinaryHash)),
+	})
+	pod, _, _ := newFallbackTestObjects()
+	executor := &fakeExecutor{outputs: map[string]string{"app": string(archive)}}
+	client := &CoverageClient{
+		clientset: fake.NewSimpleClientset(pod),
+		namespace: "default",
+		outputDir: t.TempDir(),
+		quiet:     true,
+	}
+	client.SetCommandExecutor(executor)
+
+	if err := client.CollectCoverageFromGocoverdir(context.Background(), "demo-pod", "app", "/data/it's cover", "e2e"); err != nil {
+		t.Fatalf("CollectCoverageFromGocoverdir failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the meta-data in the test directory: %v", err)
+	}
+	if script := executor.commands[0][2]; !strings.HasPrefix(script, `cd '/data/it'\''s cover' && tar czf -`) {
+		t.Errorf("Expected the quoted directory to be archived, got %q", script)
+	}
+
+	// Without a directory and container, the first container's GOCOVERDIR
+	executor.commands, executor.ran = nil, nil
+	client.CollectCoverageFromGocoverdir(context.Background(), "demo-pod", "", "", "e2e")
+	if executor.ran[0] != "app" {
+		t.Errorf("Expected the first container, got %v", executor.ran)
+	}
+	if script := executor.commands[0][2]; !strings.HasPrefix(script, `cd "${GOCOVERDIR:-/tmp/coverage}"`) {
+		t.Errorf("Expected GOCOVERDIR to be archived, got %q", script)
+	}
+
+	executor.outputs["app"] = string(coverageArchive(map[string]string{"notes.txt": "x"}))
+	if err := client.CollectCoverageFromGocoverdir(context.Background(), "demo-pod", "app", "/data", "empty"); err == nil || !strings.Contains(err.Error(), "no coverage data") {
+		t.Errorf("Expected an error without covdata, got %v", err)
+	}
+}
+
+// endlessArchiveExecutor answers with a tar.gz archive whose file never ends, until its output is
+// no longer read
+type endlessArchiveExecutor struct{}
+
+func (endlessArchiveExecutor) Exec(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error {
+	gz := gzip.NewWriter(stdout)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "covcounters." + newBinaryHash + ".1.1", Mode: 0644, Size: 1 << 40}); err != nil {
+		return err
+	}
+	chunk := make([]byte, 1<<16)
+	for {
+		if _, err := tw.Write(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+func TestCollectCoverageFromGocoverdir_LimitsWhileReading(t *testing.T) {
+	pod, _, _ := newFallbackTestObjects()
+	client := &CoverageClient{
+		clientset: fake.NewSimpleClientset(pod),
+		namespace: "default",
+		outputDir: t.TempDir(),
+		quiet:     true,
+	}
+	client.SetCommandExecutor(endlessArchiveExecutor{})
+	client.SetMaxResponseSize(1 << 20)
+
+	// Buffering the output before extracting it would never finish
+	err := client.CollectCoverageFromGocoverdir(context.Background(), "demo-pod", "app", "/data", "e2e")
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Expected the size guard to stop the transfer, got %v", err)
+	}
+}
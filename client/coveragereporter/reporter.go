@@ -0,0 +1,164 @@
+// Package coveragereporter adds suite-level coverage to Ginkgo suites: registered once, it collects
+// the application's coverage after all specs ran, generates the reports, evaluates the thresholds
+// and attaches the results to the Ginkgo report, so they land in --json-report output.
+//
+//	var _ = coveragereporter.Register(coveragereporter.Options{
+//		Client:  client,
+//		Collect: coverageclient.CollectOptions{LabelSelector: "app=coverage-demo"},
+//	})
+//
+// It runs as a ReportAfterSuite node, so parallel suites collect once, on process 1, after all
+// processes finished.
+package coveragereporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+// EntryName is the name of the report entry holding the Result
+const EntryName = "coverage"
+
+// DefaultTimeout bounds collection, processing, gate and push
+const DefaultTimeout = 5 * time.Minute
+
+// Options configures the reporter
+type Options struct {
+	Client    *coverageclient.CoverageClient
+	GetClient func() *coverageclient.CoverageClient // Or: returns the client when the suite ends, e.g. one created in BeforeSuite
+	TestName  string                                // Name of the collection (default: the suite description, sanitized)
+
+	Collect    coverageclient.CollectOptions               // Target to collect from; its TestName is ignored
+	Process    coverageclient.ProcessOptions               // Report generation; its TestName is ignored
+	Gate       coverageclient.GateConfig                   // Thresholds (default: the client's, see SetThresholds); its TestName is ignored
+	Push       *coverageclient.PushCoverageArtifactOptions // Push the coverage as an OCI artifact (default: no push)
+	FailSuite  bool                                        // Fail the suite when the gate fails or coverage can't be collected (default: only report)
+	Timeout    time.Duration                               // Default: DefaultTimeout
+	Visibility ginkgo.ReportEntryVisibility                // Console visibility of the entry (default: always)
+}
+
+// Result is the suite's coverage, attached to the Ginkgo report as the EntryName entry
+type Result struct {
+	TestName        string                       `json:"testName"`
+	Files           []string                     `json:"files,omitempty"`
+	Total           coverageclient.CoverageStats `json:"total"`
+	Profile         string                       `json:"profile,omitempty"`
+	FilteredProfile string                       `json:"filteredProfile,omitempty"`
+	HTML            string                       `json:"html,omitempty"`
+	Gate            *coverageclient.GateResult   `json:"gate,omitempty"`
+	Artifact        *coverageclient.PushResult   `json:"artifact,omitempty"`
+	Error           string                       `json:"error,omitempty"` // Why collection, processing, the gate or the push failed
+}
+
+// String summarizes the result for Ginkgo's console output
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Coverage of %s: %.1f%% (%d/%d statements)", r.TestName, r.Total.Percent(), r.Total.Covered, r.Total.Statements)
+	if r.Gate != nil && r.Gate.Report != nil {
+		b.WriteString("\n" + strings.TrimSuffix(r.Gate.Summary(), "\n"))
+	}
+	if r.Artifact != nil {
+		fmt.Fprintf(&b, "\n📦 Pushed %s@%s", r.Artifact.Reference, r.Artifact.Digest)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "\n❌ %s", r.Error)
+	}
+	return b.String()
+}
+
+// Register adds the reporter to the suite; call it at the top level, e.g. var _ = Register(opts)
+func Register(opts Options) bool {
+	return ginkgo.ReportAfterSuite("coverage", func(ctx ginkgo.SpecContext, report ginkgo.Report) {
+		result, err := Run(ctx, opts, report)
+		ginkgo.AddReportEntry(EntryName, result, opts.Visibility)
+		if !opts.FailSuite {
+			return
+		}
+		switch {
+		case err != nil:
+			ginkgo.Fail(fmt.Sprintf("coverage: %v", err))
+		case result.Gate != nil && !result.Gate.Passed:
+			ginkgo.Fail("coverage gate failed: " + strings.Join(result.Gate.Failures(), "; "))
+		}
+	})
+}
+
+// Run collects, processes, gates and optionally pushes the coverage of a finished suite. It always
+// returns a Result; the error is also recorded in it.
+func Run(ctx context.Context, opts Options, report types.Report) (*Result, error) {
+	result := &Result{TestName: testName(opts, report)}
+	if err := run(ctx, opts, result); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	return result, nil
+}
+
+func run(ctx context.Context, opts Options, result *Result) error {
+	client := opts.Client
+	if client == nil && opts.GetClient != nil {
+		client = opts.GetClient()
+	}
+	if client == nil {
+		return fmt.Errorf("no coverage client")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	collect := opts.Collect
+	collect.TestName = result.TestName
+	collected, err := client.Collect(ctx, collect)
+	if err != nil {
+		return err
+	}
+	// The layout may name the test directory differently
+	result.TestName, result.Files = collected.TestName, collected.Files
+
+	process := opts.Process
+	process.TestName = result.TestName
+	processed, err := client.ProcessCoverage(ctx, process)
+	if err != nil {
+		return err
+	}
+	result.Total, result.Profile, result.FilteredProfile, result.HTML = processed.Total, processed.Profile, processed.FilteredProfile, processed.HTML
+
+	gate := opts.Gate
+	gate.TestName = result.TestName
+	if result.Gate, err = client.Gate(ctx, gate); err != nil {
+		return fmt.Errorf("evaluate gate: %w", err)
+	}
+
+	if opts.Push != nil {
+		if result.Artifact, err = client.PushCoverageArtifactWithResult(ctx, result.TestName, *opts.Push); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsafeNamePattern matches runs of characters not allowed in test directory names
+var unsafeNamePattern = regexp.MustCompile(`[^\w.\-]+`)
+
+// testName returns the configured test name or one derived from the suite description
+func testName(opts Options, report types.Report) string {
+	if opts.TestName != "" {
+		return opts.TestName
+	}
+	name := strings.Trim(unsafeNamePattern.ReplaceAllString(strings.ToLower(report.SuiteDescription), "-"), "-")
+	if name == "" {
+		return "suite"
+	}
+	return name
+}
@@ -0,0 +1,98 @@
+package coveragereporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2/types"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+const testHash = "0123456789abcdef0123456789abcdef"
+
+func newClient(t *testing.T) *coverageclient.CoverageClient {
+	client, err := coverageclient.NewLocalClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %v", err)
+	}
+	client.SetQuiet(true)
+	return client
+}
+
+func TestTestName(t *testing.T) {
+	tests := []struct {
+		opts        Options
+		description string
+		want        string
+	}{
+		{Options{TestName: "e2e"}, "Coverage Collection E2E Suite", "e2e"},
+		{Options{}, "Coverage Collection E2E Suite", "coverage-collection-e2e-suite"},
+		{Options{}, "API / v2 (smoke)", "api-v2-smoke"},
+		{Options{}, "", "suite"},
+	}
+	for _, tt := range tests {
+		if got := testName(tt.opts, types.Report{SuiteDescription: tt.description}); got != tt.want {
+			t.Errorf("testName(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestRun_CoverageNotEnabled(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	opts := Options{Client: newClient(t), Collect: coverageclient.CollectOptions{URL: server.URL + "/coverage"}}
+	result, err := Run(context.Background(), opts, types.Report{SuiteDescription: "E2E"})
+	if !errors.Is(err, coverageclient.ErrCoverageNotEnabled) {
+		t.Fatalf("Expected ErrCoverageNotEnabled, got %v", err)
+	}
+	if result.TestName != "e2e" || result.Error != err.Error() {
+		t.Errorf("Expected the error in the result, got %+v", result)
+	}
+	if !strings.Contains(result.String(), result.Error) {
+		t.Errorf("Expected the error in the summary, got %q", result.String())
+	}
+}
+
+func TestRun_ProcessingFailure(t *testing.T) {
+	// Fake covdata is collected but can't be processed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"meta_filename":"covmeta.%s","meta_data":"bWV0YQ==","counters_filename":"covcounters.%s.1.1","counters_data":"Y291bnRlcnM="}`, testHash, testHash)
+	}))
+	defer server.Close()
+
+	opts := Options{Client: newClient(t), TestName: "e2e", Collect: coverageclient.CollectOptions{URL: server.URL + "/coverage"}}
+	result, err := Run(context.Background(), opts, types.Report{})
+	if err == nil {
+		t.Fatal("Expected processing fake covdata to fail")
+	}
+	if len(result.Files) != 2 || result.Gate != nil || result.Error == "" {
+		t.Errorf("Expected the collected files and the error, got %+v", result)
+	}
+}
+
+func TestRun_NoClient(t *testing.T) {
+	if _, err := Run(context.Background(), Options{}, types.Report{}); err == nil {
+		t.Error("Expected Run without a client to fail")
+	}
+}
+
+func TestRun_GetClient(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client := newClient(t)
+	opts := Options{
+		GetClient: func() *coverageclient.CoverageClient { return client },
+		Collect:   coverageclient.CollectOptions{URL: server.URL + "/coverage"},
+	}
+	if _, err := Run(context.Background(), opts, types.Report{}); !errors.Is(err, coverageclient.ErrCoverageNotEnabled) {
+		t.Errorf("Expected the client returned by GetClient to collect, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package covhttptest collects coverage in plain go test e2e suites: Main wraps TestMain, runs the
+// tests and then collects, processes and gates the application's coverage.
+//
+//	func TestMain(m *testing.M) {
+//		covhttptest.Main(m, covhttptest.Config{Namespace: "my-app", LabelSelector: "app=my-app"})
+//	}
+package covhttptest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+// DefaultTimeout bounds collection, processing, gate and push
+const DefaultTimeout = 5 * time.Minute
+
+// Config configures Main. Without Client, one is created from ConfigFile, or Namespace and
+// OutputDir, with COVERAGE_* environment variables overriding its defaults.
+type Config struct {
+	Client      *coverageclient.CoverageClient
+	ConfigFile  string // YAML or JSON configuration, see coverageclient.LoadConfig
+	Namespace   string // Namespace of the pods
+	KubeContext string // kubeconfig context (default: current context)
+	OutputDir   string // Default: $COVERAGE_OUTPUT_DIR or coverage-output
+
+	TestName      string // Name of the collection (default: the test binary's package, e.g. "e2e")
+	LabelSelector string // Shorthand for Collect.LabelSelector
+
+	Collect    coverageclient.CollectOptions               // Target to collect from; its TestName is ignored
+	Process    coverageclient.ProcessOptions               // Report generation; its TestName is ignored
+	Gate       coverageclient.GateConfig                   // Thresholds (default: the client's, see SetThresholds); its TestName is ignored
+	Push       *coverageclient.PushCoverageArtifactOptions // Push the coverage as an OCI artifact (default: no push)
+	FailOnGate bool                                        // Exit with 1 when the gate fails (default: only report)
+	FailOnErr  bool                                        // Exit with 1 when coverage can't be collected or processed (default: only report)
+	Timeout    time.Duration                               // Default: DefaultTimeout
+}
+
+// Main runs the tests, collects and processes coverage as configured by cfg, and exits. The tests'
+// exit code wins; coverage is collected even when tests failed.
+func Main(m *testing.M, cfg Config) {
+	os.Exit(run(m, cfg, os.Stdout))
+}
+
+// runner runs the tests, like *testing.M
+type runner interface {
+	Run() int
+}
+
+func run(m runner, cfg Config, out io.Writer) int {
+	code := m.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout(cfg))
+	defer cancel()
+	gate, err := collect(ctx, cfg)
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "❌ Coverage collection failed: %v\n", err)
+		if code == 0 && cfg.FailOnErr {
+			code = 1
+		}
+	case !gate.Passed && code == 0 && cfg.FailOnGate:
+		code = 1
+	}
+	return code
+}
+
+func timeout(cfg Config) time.Duration {
+	if cfg.Timeout > 0 {
+		return cfg.Timeout
+	}
+	return DefaultTimeout
+}
+
+// collect collects, processes, gates and optionally pushes the coverage
+func collect(ctx context.Context, cfg Config) (*coverageclient.GateResult, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
+
+	opts := cfg.Collect
+	opts.TestName = testName(cfg)
+	if opts.LabelSelector == "" {
+		opts.LabelSelector = cfg.LabelSelector
+	}
+	collected, err := client.Collect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	process := cfg.Process
+	process.TestName = collected.TestName
+	if _, err := client.ProcessCoverage(ctx, process); err != nil {
+		return nil, err
+	}
+
+	gate := cfg.Gate
+	gate.TestName = collected.TestName
+	result, err := client.Gate(ctx, gate)
+	if err != nil {
+		return nil, fmt.Errorf("evaluate gate: %w", err)
+	}
+
+	if cfg.Push != nil {
+		if _, err := client.PushCoverageArtifactWithResult(ctx, collected.TestName, *cfg.Push); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func newClient(cfg Config) (*coverageclient.CoverageClient, error) {
+	switch {
+	case cfg.Client != nil:
+		return cfg.Client, nil
+	case cfg.ConfigFile != "":
+		return coverageclient.LoadConfig(cfg.ConfigFile)
+	case cfg.Namespace != "":
+		return coverageclient.NewClientForContext(cfg.KubeContext, cfg.Namespace, cfg.OutputDir)
+	default:
+		return coverageclient.NewLocalClient(cfg.OutputDir)
+	}
+}
+
+// testName returns the configured test name or the test binary's, e.g. "e2e" for e2e.test
+func testName(cfg Config) string {
+	if cfg.TestName != "" {
+		return cfg.TestName
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"), ".test")
+	if name == "" || name == "." {
+		return "e2e"
+	}
+	return name
+}
@@ -0,0 +1,76 @@
+package covhttptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+// fakeTests plays *testing.M exiting with code
+type fakeTests struct {
+	code int
+	ran  bool
+}
+
+func (f *fakeTests) Run() int {
+	f.ran = true
+	return f.code
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client, err := coverageclient.NewLocalClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %v", err)
+	}
+	client.SetQuiet(true)
+	cfg := Config{Client: client, TestName: "e2e", Collect: coverageclient.CollectOptions{URL: server.URL + "/coverage"}}
+
+	tests := []struct {
+		name      string
+		code      int
+		failOnErr bool
+		want      int
+	}{
+		{"collection failure reported", 0, false, 0},
+		{"collection failure fails", 0, true, 1},
+		{"test failure wins", 3, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tests := &fakeTests{code: tt.code}
+			cfg := cfg
+			cfg.FailOnErr = tt.failOnErr
+
+			var out strings.Builder
+			if got := run(tests, cfg, &out); got != tt.want {
+				t.Errorf("Expected exit code %d, got %d", tt.want, got)
+			}
+			if !tests.ran {
+				t.Error("Expected the tests to run")
+			}
+			if !strings.Contains(out.String(), "Coverage collection failed") {
+				t.Errorf("Expected the collection error in the output, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestTestName(t *testing.T) {
+	if got := testName(Config{TestName: "smoke"}); got != "smoke" {
+		t.Errorf("Expected the configured test name, got %q", got)
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"/tmp/go-build123/b001/e2e.test"}
+	if got := testName(Config{}); got != "e2e" {
+		t.Errorf("Expected the test binary's name, got %q", got)
+	}
+}
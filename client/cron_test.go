@@ -0,0 +1,47 @@
+package coverageclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// Friday, 2026-01-02 10:07:30 UTC
+	from := time.Date(2026, 1, 2, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 2, 10, 15, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2026, 1, 2, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", time.Date(2026, 1, 5, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)},
+		{"5,10 10 * * *", time.Date(2026, 1, 2, 10, 10, 0, 0, time.UTC)},
+		// Day-of-month and day-of-week are OR-ed: the 15th or any Sunday
+		{"0 0 15 * 0", time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.expected) {
+				t.Errorf("Next(%s) = %s, expected %s", from, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCronSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every -1m", "@fortnightly"} {
+		if _, err := ParseCronSchedule(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
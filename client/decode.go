@@ -0,0 +1,309 @@
+package coverageclient
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// defaultMaxResponseSize bounds coverage responses when SetMaxResponseSize wasn't called
+const defaultMaxResponseSize = 1 << 30
+
+// SetMaxResponseSize limits the size of coverage responses in bytes; collections fail once a
+// response grows beyond it instead of filling the disk (default: 1 GiB, negative disables the limit)
+func (c *CoverageClient) SetMaxResponseSize(bytes int64) {
+	c.maxResponseSize = bytes
+}
+
+// responseLimit returns the configured size guard in bytes, negative when disabled
+func (c *CoverageClient) responseLimit() int64 {
+	if c.maxResponseSize == 0 {
+		return defaultMaxResponseSize
+	}
+	return c.maxResponseSize
+}
+
+// limitResponse wraps a coverage response body with the configured size guard
+func (c *CoverageClient) limitResponse(body io.Reader) io.Reader {
+	return limitReader(body, c.responseLimit())
+}
+
+// checkResponseSize fails early when a response declares a length (negative if unknown) beyond
+// the size guard or the free space of the test directory
+func (c *CoverageClient) checkResponseSize(length int64, testName string) error {
+	if limit := c.responseLimit(); limit >= 0 && length > limit {
+		return fmt.Errorf("coverage response of %d bytes exceeds %d bytes (see SetMaxResponseSize)", length, limit)
+	}
+	return c.checkFreeSpace(filepath.Join(c.outputDir, testName), length)
+}
+
+// limitReader wraps r with a size guard of limit bytes, negative disables it
+func limitReader(r io.Reader, limit int64) io.Reader {
+	if limit < 0 {
+		return r
+	}
+	return &responseLimitReader{r: r, limit: limit, remaining: limit}
+}
+
+// responseLimitReader fails once more than limit bytes have been read, unlike io.LimitReader
+// which silently truncates
+type responseLimitReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *responseLimitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		// Drop the byte beyond the limit, reads only ask for one more than allowed
+		return n - 1, fmt.Errorf("coverage response exceeds %d bytes (see SetMaxResponseSize)", l.limit)
+	}
+	return n, err
+}
+
+// decodeCoverageJSON reads a JSON object, base64-decoding the string values of the keys in binary
+// straight into their writers. Values of the keys in arrays are handed to their function to decode
+// (e.g. with the scanner's array and object methods). All other values are returned raw. Only the
+// decoder's buffer is held in memory, however large the encoded files are.
+func decodeCoverageJSON(r io.Reader, binary map[string]io.Writer, arrays map[string]func(*jsonScanner) error) (map[string]json.RawMessage, error) {
+	s := &jsonScanner{r: bufio.NewReader(r)}
+	return s.object(binary, arrays)
+}
+
+// jsonScanner reads JSON tokens from a stream
+type jsonScanner struct {
+	r *bufio.Reader
+}
+
+// object reads an object like decodeCoverageJSON
+func (s *jsonScanner) object(binary map[string]io.Writer, arrays map[string]func(*jsonScanner) error) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+
+	if err := s.expect('{'); err != nil {
+		return nil, err
+	}
+	if c, err := s.peek(); err != nil {
+		return nil, err
+	} else if c == '}' {
+		s.r.ReadByte()
+		return fields, nil
+	}
+
+	for {
+		rawKey, err := s.rawValue()
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal(rawKey, &key); err != nil {
+			return nil, fmt.Errorf("invalid object key %s: %w", rawKey, err)
+		}
+		if err := s.expect(':'); err != nil {
+			return nil, err
+		}
+
+		if w, ok := binary[key]; ok {
+			if err := s.expect('"'); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			decoder := base64.NewDecoder(base64.StdEncoding, &jsonStringReader{r: s.r})
+			if _, err := io.Copy(w, decoder); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", key, err)
+			}
+		} else if decode, ok := arrays[key]; ok {
+			if err := decode(s); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", key, err)
+			}
+		} else {
+			if fields[key], err = s.rawValue(); err != nil {
+				return nil, err
+			}
+		}
+
+		c, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case ',':
+		case '}':
+			return fields, nil
+		default:
+			return nil, fmt.Errorf("invalid JSON: unexpected %q after value of %s", c, key)
+		}
+	}
+}
+
+// array reads an array, calling element to read each element
+func (s *jsonScanner) array(element func() error) error {
+	if err := s.expect('['); err != nil {
+		return err
+	}
+	if c, err := s.peek(); err != nil {
+		return err
+	} else if c == ']' {
+		s.r.ReadByte()
+		return nil
+	}
+
+	for {
+		if err := element(); err != nil {
+			return err
+		}
+		c, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ',':
+		case ']':
+			return nil
+		default:
+			return fmt.Errorf("invalid JSON: unexpected %q in array", c)
+		}
+	}
+}
+
+// next returns the next byte that isn't whitespace
+func (s *jsonScanner) next() (byte, error) {
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, nil
+		}
+	}
+}
+
+// peek returns the next byte that isn't whitespace without consuming it
+func (s *jsonScanner) peek() (byte, error) {
+	c, err := s.next()
+	if err != nil {
+		return 0, err
+	}
+	return c, s.r.UnreadByte()
+}
+
+// expect consumes the next non-whitespace byte, which must be want
+func (s *jsonScanner) expect(want byte) error {
+	c, err := s.next()
+	if err != nil {
+		return err
+	}
+	if c != want {
+		return fmt.Errorf("invalid JSON: expected %q, got %q", want, c)
+	}
+	return nil
+}
+
+// rawValue returns the next value (string, number, literal, object or array) unparsed
+func (s *jsonScanner) rawValue() (json.RawMessage, error) {
+	if _, err := s.peek(); err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	depth, inString, escaped := 0, false, false
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF && depth == 0 && !inString && len(raw) > 0 {
+			return raw, nil
+		}
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		case depth == 0 && (c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			// End of a number or literal
+			return raw, s.r.UnreadByte()
+		}
+		if depth < 0 {
+			// Closing bracket of the enclosing object after a number or literal
+			return raw, s.r.UnreadByte()
+		}
+
+		raw = append(raw, c)
+		if depth == 0 && !inString && (c == '"' || c == '}' || c == ']') {
+			return raw, nil
+		}
+	}
+}
+
+// jsonStringReader reads the contents of a JSON string whose opening quote was consumed, up to
+// the closing quote. Only the escapes valid in base64 data (\/) are supported.
+type jsonStringReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+func (j *jsonStringReader) Read(p []byte) (int, error) {
+	if j.done {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) {
+		c, err := j.r.ReadByte()
+		if err == io.EOF {
+			return n, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return n, err
+		}
+		switch c {
+		case '"':
+			j.done = true
+			return n, nil
+		case '\\':
+			escaped, err := j.r.ReadByte()
+			if err == io.EOF {
+				return n, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return n, err
+			}
+			if escaped != '/' {
+				return n, fmt.Errorf("unexpected escape \\%c in base64 data", escaped)
+			}
+			c = '/'
+		}
+		p[n] = c
+		n++
+		if j.r.Buffered() == 0 && n > 0 {
+			// Don't block on the network with data ready to decode
+			return n, nil
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package coverageclient
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCoverageJSON(t *testing.T) {
+	// "meta" and "counters?" in base64, the latter with an escaped slash
+	input := `{"test_name": "e2e", "nested": {"a": [1, "}"]}, "meta_data": "bWV0YQ==",
+		"timestamp": 42, "counters_data": "Y291bnRlcnM\/", "ok": true}`
+
+	var meta, counters bytes.Buffer
+	fields, err := decodeCoverageJSON(strings.NewReader(input), map[string]io.Writer{
+		"meta_data":     &meta,
+		"counters_data": &counters,
+	}, nil)
+	if err != nil {
+		t.Fatalf("decodeCoverageJSON failed: %v", err)
+	}
+
+	if meta.String() != "meta" || counters.String() != "counters?" {
+		t.Errorf("Unexpected binary data: %q, %q", meta.String(), counters.String())
+	}
+	for key, want := range map[string]string{
+		"test_name": `"e2e"`,
+		"nested":    `{"a": [1, "}"]}`,
+		"timestamp": `42`,
+		"ok":        `true`,
+	} {
+		if string(fields[key]) != want {
+			t.Errorf("Field %s: expected %s, got %s", key, want, fields[key])
+		}
+	}
+}
+
+func TestDecodeCoverageJSON_Invalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"truncated data":  `{"meta_data": "bWV0`,
+		"truncated value": `{"meta_filename": "covmeta.abc`,
+		"missing colon":   `{"meta_data" "bWV0YQ=="}`,
+		"invalid base64":  `{"meta_data": "!!!!"}`,
+		"not an object":   `["meta_data"]`,
+	} {
+		_, err := decodeCoverageJSON(strings.NewReader(input), map[string]io.Writer{"meta_data": io.Discard}, nil)
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSaveCoverageResponse_MaxResponseSize(t *testing.T) {
+	response := `{"meta_filename":"covmeta.abc","meta_data":"bWV0YQ==","counters_filename":"covcounters.abc.1.2","counters_data":"Y291bnRlcnM="}`
+
+	client := &CoverageClient{outputDir: t.TempDir()}
+	client.SetMaxResponseSize(int64(len(response) - 1))
+	if err := client.saveCoverageResponse(strings.NewReader(response), "e2e"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("Expected size guard error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(client.outputDir, "e2e")); len(entries) != 0 {
+		t.Errorf("Expected no files left behind, got %d", len(entries))
+	}
+
+	client.SetMaxResponseSize(int64(len(response)))
+	if err := client.saveCoverageResponse(strings.NewReader(response), "e2e"); err != nil {
+		t.Fatalf("Expected response within the limit to be saved, got %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(client.outputDir, "e2e", "covcounters.abc.1.2"))
+	if string(content) != "counters" {
+		t.Errorf("Unexpected counters %q", content)
+	}
+}
+
+func TestSaveCoverageResponse_OtherCounters(t *testing.T) {
+	// Counters of a forked child and of the container before a restart, data before file names
+	response := `{"meta_filename":"covmeta.abc","meta_data":"bWV0YQ==",
+		"counters_filename":"covcounters.abc.1.3","counters_data":"bGl2ZQ==",
+		"counters":[{"data":"Y2hpbGQ=","filename":"covcounters.abc.7.2"},{"filename":"covcounters.abc.1.1","data":"b2xk"}],
+		"timestamp":3}`
+
+	client := &CoverageClient{outputDir: t.TempDir()}
+	if err := client.saveCoverageResponse(strings.NewReader(response), "e2e"); err != nil {
+		t.Fatalf("saveCoverageResponse failed: %v", err)
+	}
+
+	testDir := filepath.Join(client.outputDir, "e2e")
+	for name, want := range map[string]string{
+		"covmeta.abc":         "meta",
+		"covcounters.abc.1.3": "live",
+		"covcounters.abc.7.2": "child",
+		"covcounters.abc.1.1": "old",
+	} {
+		content, err := os.ReadFile(filepath.Join(testDir, name))
+		if err != nil || string(content) != want {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", name, want, content, err)
+		}
+	}
+	if entries, _ := os.ReadDir(testDir); len(entries) != 4 {
+		t.Errorf("Expected 4 files without temporary leftovers, got %d", len(entries))
+	}
+}
@@ -0,0 +1,406 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// AnnotationPrefix is the prefix for all annotations managed by the coverage client
+	AnnotationPrefix = "coverage.psturc.io/"
+
+	// AnnotationEnabled marks a Deployment as patched by EnableCoverage
+	AnnotationEnabled = AnnotationPrefix + "enabled"
+
+	// AnnotationOriginalImage stores the container image before EnableCoverage swapped it
+	AnnotationOriginalImage = AnnotationPrefix + "original-image"
+
+	// AnnotationContainer stores the name of the container serving coverage
+	AnnotationContainer = AnnotationPrefix + "container"
+
+	// AnnotationPort marks a pod as instrumented and stores its coverage server port
+	AnnotationPort = AnnotationPrefix + "port"
+
+	// AnnotationPreStopFlush marks a Deployment whose preStop hook EnableCoverage added
+	AnnotationPreStopFlush = AnnotationPrefix + "prestop-flush"
+
+	// AnnotationOriginalEnv stores the env vars EnableCoverage set, as a JSON object mapping each
+	// name to its original definition (null for vars it added)
+	AnnotationOriginalEnv = AnnotationPrefix + "original-env"
+
+	// AnnotationPortAdded marks a Deployment whose "coverage" container port EnableCoverage added
+	AnnotationPortAdded = AnnotationPrefix + "port-added"
+
+	// DefaultCoveragePort is the port the coverage server listens on by default
+	DefaultCoveragePort = 9095
+
+	coveragePortName   = "coverage"
+	coverageVolumeName = "coverage-data"
+	defaultCoverDir    = "/tmp/coverage"
+	coveragePodNameEnv = "COVERAGE_POD_NAME"
+	flushTargetsEnv    = "COVERAGE_FLUSH_TARGETS"
+)
+
+// EnableCoverageOptions configures how a Deployment is patched for coverage collection
+type EnableCoverageOptions struct {
+	ContainerName  string        // Container to patch (default: first container)
+	Port           int           // Coverage server port (default: 9095, see SetCoveragePort)
+	ImageTagSuffix string        // Suffix appended to the image tag, e.g. "-cover" (empty: keep image)
+	CoverDir       string        // Mount path of the GOCOVERDIR volume (default: /tmp/coverage)
+	PVCName        string        // Persist GOCOVERDIR on this PVC instead of an emptyDir, one subdirectory per pod
+	FlushInterval  time.Duration // How often the coverage server writes counters to GOCOVERDIR (0: only on exit)
+	FlushTarget    string        // Collector URL pods push their counters to when terminated, e.g. a CoverageListener's (empty: no preStop hook); added to COVERAGE_FLUSH_TARGETS, the server refuses others
+}
+
+// EnableCoverage patches a Deployment so its pods expose the coverage server.
+// It adds the COVERAGE_PORT and GOCOVERDIR env vars, a "coverage" container port,
+// an emptyDir (or PVC) volume for GOCOVERDIR and optionally swaps the image to its coverage tag.
+// The original state, including env vars and a "coverage" port the container already had, is
+// recorded in annotations so DisableCoverage can revert it. A "coverage" port with another number
+// or an existing "coverage-data" volume is an error rather than being overwritten.
+func (c *CoverageClient) EnableCoverage(ctx context.Context, deploymentName string, opts EnableCoverageOptions) error {
+	opts.Port = c.coveragePort(opts.Port)
+	if opts.CoverDir == "" {
+		opts.CoverDir = defaultCoverDir
+	}
+
+	c.logf("🔧 Enabling coverage on deployment %s/%s\n", c.namespace, deploymentName)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployments := c.clientset.AppsV1().Deployments(c.namespace)
+		deployment, err := deployments.Get(ctx, deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("get deployment: %w", err)
+		}
+
+		if deployment.Annotations[AnnotationEnabled] == "true" {
+			c.logf("  ℹ️  Coverage already enabled on deployment %s\n", deploymentName)
+			return nil
+		}
+
+		container, err := findContainer(deployment.Spec.Template.Spec.Containers, opts.ContainerName)
+		if err != nil {
+			return err
+		}
+		if err := checkCoverageConflicts(&deployment.Spec.Template.Spec, container, opts.Port); err != nil {
+			return err
+		}
+
+		if deployment.Annotations == nil {
+			deployment.Annotations = make(map[string]string)
+		}
+		deployment.Annotations[AnnotationEnabled] = "true"
+		deployment.Annotations[AnnotationContainer] = container.Name
+
+		// Record the definitions of the env vars before replacing them
+		originalEnv := make(map[string]*corev1.EnvVar)
+		setEnv := func(env corev1.EnvVar) {
+			if _, ok := originalEnv[env.Name]; !ok {
+				originalEnv[env.Name] = findEnvVar(container.Env, env.Name)
+			}
+			container.Env = setEnvVar(container.Env, env)
+		}
+
+		if opts.ImageTagSuffix != "" {
+			deployment.Annotations[AnnotationOriginalImage] = container.Image
+			container.Image = withImageTagSuffix(container.Image, opts.ImageTagSuffix)
+			c.logf("  🐳 Image: %s\n", container.Image)
+		}
+
+		setEnv(corev1.EnvVar{Name: "COVERAGE_PORT", Value: strconv.Itoa(opts.Port)})
+		setEnv(corev1.EnvVar{Name: "GOCOVERDIR", Value: opts.CoverDir})
+		if opts.FlushInterval > 0 {
+			setEnv(corev1.EnvVar{Name: "COVERAGE_FLUSH_INTERVAL", Value: opts.FlushInterval.String()})
+		}
+
+		if opts.FlushTarget != "" {
+			if container.Lifecycle != nil && container.Lifecycle.PreStop != nil {
+				c.logf("  ⚠️  Container %s already has a preStop hook, not adding the coverage flush\n", container.Name)
+			} else {
+				if container.Lifecycle == nil {
+					container.Lifecycle = &corev1.Lifecycle{}
+				}
+				container.Lifecycle.PreStop = flushPreStopHandler(opts.Port, opts.FlushTarget)
+				deployment.Annotations[AnnotationPreStopFlush] = "true"
+				// The coverage server only pushes to targets it's configured with
+				targets := opts.FlushTarget
+				if existing := findEnvVar(container.Env, flushTargetsEnv); existing != nil && existing.Value != "" {
+					targets = existing.Value + "," + targets
+				}
+				setEnv(corev1.EnvVar{Name: flushTargetsEnv, Value: targets})
+				c.logf("  🪝 Pods push their coverage to %s when terminated\n", opts.FlushTarget)
+			}
+		}
+
+		if !hasContainerPort(container.Ports, coveragePortName) {
+			container.Ports = append(container.Ports, corev1.ContainerPort{
+				Name:          coveragePortName,
+				ContainerPort: int32(opts.Port),
+				Protocol:      corev1.ProtocolTCP,
+			})
+			deployment.Annotations[AnnotationPortAdded] = "true"
+		}
+
+		coverMount := corev1.VolumeMount{
+			Name:      coverageVolumeName,
+			MountPath: opts.CoverDir,
+		}
+		coverVolume := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+		if opts.PVCName != "" {
+			// Replicas share the claim, so each pod writes to its own subdirectory
+			setEnv(corev1.EnvVar{
+				Name:      coveragePodNameEnv,
+				ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+			})
+			coverMount.SubPathExpr = "$(" + coveragePodNameEnv + ")"
+			coverVolume = corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: opts.PVCName}}
+			c.logf("  💾 GOCOVERDIR persisted on PVC %s\n", opts.PVCName)
+		}
+		container.VolumeMounts = append(removeVolumeMount(container.VolumeMounts, coverageVolumeName), coverMount)
+
+		// Annotate the pod template so DiscoverInstrumentedPods finds the new pods
+		template := &deployment.Spec.Template
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
+		}
+		template.Annotations[AnnotationPort] = strconv.Itoa(opts.Port)
+		template.Annotations[AnnotationContainer] = container.Name
+
+		recorded, err := json.Marshal(originalEnv)
+		if err != nil {
+			return fmt.Errorf("encode original env vars: %w", err)
+		}
+		deployment.Annotations[AnnotationOriginalEnv] = string(recorded)
+
+		podSpec := &deployment.Spec.Template.Spec
+		podSpec.Volumes = append(removeVolume(podSpec.Volumes, coverageVolumeName), corev1.Volume{
+			Name:         coverageVolumeName,
+			VolumeSource: coverVolume,
+		})
+
+		_, err = deployments.Update(ctx, deployment, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("enable coverage on deployment %s: %w", deploymentName, err)
+	}
+
+	c.logf("✅ Coverage enabled on deployment %s\n", deploymentName)
+	return nil
+}
+
+// DisableCoverage reverts the changes made by EnableCoverage
+func (c *CoverageClient) DisableCoverage(ctx context.Context, deploymentName string) error {
+	c.logf("🔧 Disabling coverage on deployment %s/%s\n", c.namespace, deploymentName)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployments := c.clientset.AppsV1().Deployments(c.namespace)
+		deployment, err := deployments.Get(ctx, deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("get deployment: %w", err)
+		}
+
+		if deployment.Annotations[AnnotationEnabled] != "true" {
+			c.logf("  ℹ️  Coverage not enabled on deployment %s\n", deploymentName)
+			return nil
+		}
+
+		container, err := findContainer(deployment.Spec.Template.Spec.Containers, deployment.Annotations[AnnotationContainer])
+		if err != nil {
+			return err
+		}
+
+		if originalImage, ok := deployment.Annotations[AnnotationOriginalImage]; ok {
+			container.Image = originalImage
+		}
+
+		// Deployments enabled by older clients didn't record their env vars and port, which are removed
+		originalEnv := map[string]*corev1.EnvVar{"COVERAGE_PORT": nil, "GOCOVERDIR": nil, "COVERAGE_FLUSH_INTERVAL": nil, coveragePodNameEnv: nil}
+		recorded, ok := deployment.Annotations[AnnotationOriginalEnv]
+		if ok {
+			originalEnv = nil
+			if err := json.Unmarshal([]byte(recorded), &originalEnv); err != nil {
+				return fmt.Errorf("decode %s annotation: %w", AnnotationOriginalEnv, err)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(originalEnv)) {
+			if original := originalEnv[name]; original != nil {
+				container.Env = setEnvVar(container.Env, *original)
+			} else {
+				container.Env = removeEnvVar(container.Env, name)
+			}
+		}
+		if !ok || deployment.Annotations[AnnotationPortAdded] == "true" {
+			container.Ports = removeContainerPort(container.Ports, coveragePortName)
+		}
+		if deployment.Annotations[AnnotationPreStopFlush] == "true" && container.Lifecycle != nil {
+			container.Lifecycle.PreStop = nil
+			if container.Lifecycle.PostStart == nil && container.Lifecycle.StopSignal == nil {
+				container.Lifecycle = nil
+			}
+		}
+		container.VolumeMounts = removeVolumeMount(container.VolumeMounts, coverageVolumeName)
+
+		podSpec := &deployment.Spec.Template.Spec
+		podSpec.Volumes = removeVolume(podSpec.Volumes, coverageVolumeName)
+
+		delete(deployment.Spec.Template.Annotations, AnnotationPort)
+		delete(deployment.Spec.Template.Annotations, AnnotationContainer)
+
+		delete(deployment.Annotations, AnnotationEnabled)
+		delete(deployment.Annotations, AnnotationContainer)
+		delete(deployment.Annotations, AnnotationOriginalImage)
+		delete(deployment.Annotations, AnnotationPreStopFlush)
+		delete(deployment.Annotations, AnnotationOriginalEnv)
+		delete(deployment.Annotations, AnnotationPortAdded)
+
+		_, err = deployments.Update(ctx, deployment, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("disable coverage on deployment %s: %w", deploymentName, err)
+	}
+
+	c.logf("✅ Coverage disabled on deployment %s\n", deploymentName)
+	return nil
+}
+
+// flushPreStopHandler asks the coverage server on port to push its counters to target before the
+// container is stopped, see the server's /coverage/flush
+func flushPreStopHandler(port int, target string) *corev1.LifecycleHandler {
+	return &corev1.LifecycleHandler{HTTPGet: &corev1.HTTPGetAction{
+		Path: "/coverage/flush?" + url.Values{"target": {target}}.Encode(),
+		Port: intstr.FromInt(port),
+	}}
+}
+
+// findContainer returns a pointer to the named container, or the first container if name is empty
+func findContainer(containers []corev1.Container, name string) (*corev1.Container, error) {
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("no containers found in pod template")
+	}
+	if name == "" {
+		return &containers[0], nil
+	}
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("container '%s' not found in pod template", name)
+}
+
+// withImageTagSuffix appends suffix to the image tag, e.g. "app:v1" -> "app:v1-cover"
+func withImageTagSuffix(image, suffix string) string {
+	if strings.HasSuffix(image, suffix) {
+		return image
+	}
+	// Digest references can't be re-tagged
+	if strings.Contains(image, "@") {
+		return image
+	}
+	lastSlash := strings.LastIndex(image, "/")
+	if strings.LastIndex(image, ":") > lastSlash {
+		return image + suffix
+	}
+	return image + ":latest" + suffix
+}
+
+// setEnvVar replaces the env var of the same name in place, or appends it
+func setEnvVar(env []corev1.EnvVar, e corev1.EnvVar) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == e.Name {
+			env[i] = e
+			return env
+		}
+	}
+	return append(env, e)
+}
+
+// findEnvVar returns a copy of the named env var, nil if it isn't set
+func findEnvVar(env []corev1.EnvVar, name string) *corev1.EnvVar {
+	for _, e := range env {
+		if e.Name == name {
+			return &e
+		}
+	}
+	return nil
+}
+
+func removeEnvVar(env []corev1.EnvVar, name string) []corev1.EnvVar {
+	var result []corev1.EnvVar
+	for _, e := range env {
+		if e.Name != name {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// checkCoverageConflicts fails when the pod spec already uses the names EnableCoverage adds for
+// something else: a "coverage" port of the container on another number, which the coverage server
+// wouldn't be reachable on, or a "coverage-data" volume, which DisableCoverage would remove
+func checkCoverageConflicts(podSpec *corev1.PodSpec, container *corev1.Container, port int) error {
+	for _, p := range container.Ports {
+		if p.Name == coveragePortName && int(p.ContainerPort) != port {
+			return fmt.Errorf("container %s already has a %q port %d, not the coverage port %d", container.Name, coveragePortName, p.ContainerPort, port)
+		}
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == coverageVolumeName {
+			return fmt.Errorf("pod template already has a volume named %q", coverageVolumeName)
+		}
+	}
+	return nil
+}
+
+func hasContainerPort(ports []corev1.ContainerPort, name string) bool {
+	for _, p := range ports {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func removeContainerPort(ports []corev1.ContainerPort, name string) []corev1.ContainerPort {
+	var result []corev1.ContainerPort
+	for _, p := range ports {
+		if p.Name != name {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func removeVolumeMount(mounts []corev1.VolumeMount, name string) []corev1.VolumeMount {
+	var result []corev1.VolumeMount
+	for _, m := range mounts {
+		if m.Name != name {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func removeVolume(volumes []corev1.Volume, name string) []corev1.Volume {
+	var result []corev1.Volume
+	for _, v := range volumes {
+		if v.Name != name {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,271 @@
+package coverageclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo",
+			Namespace: "default",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "app",
+							Image: "quay.io/org/app:v1",
+							Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8000}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestEnableCoverage(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestDeployment())
+	client := &CoverageClient{clientset: clientset, namespace: "default"}
+
+	ctx := context.Background()
+	err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{ImageTagSuffix: "-cover"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deployment, _ := clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	container := deployment.Spec.Template.Spec.Containers[0]
+
+	if container.Image != "quay.io/org/app:v1-cover" {
+		t.Errorf("Expected coverage image, got %s", container.Image)
+	}
+	if deployment.Annotations[AnnotationOriginalImage] != "quay.io/org/app:v1" {
+		t.Errorf("Original image not recorded: %v", deployment.Annotations)
+	}
+	if !hasContainerPort(container.Ports, coveragePortName) {
+		t.Errorf("Coverage port not added: %v", container.Ports)
+	}
+	if len(container.Env) != 2 || container.Env[0].Value != "9095" {
+		t.Errorf("Coverage env vars not set correctly: %v", container.Env)
+	}
+	if len(deployment.Spec.Template.Spec.Volumes) != 1 || len(container.VolumeMounts) != 1 {
+		t.Errorf("Coverage volume not added")
+	}
+
+	// Enabling twice must not duplicate anything
+	if err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{ImageTagSuffix: "-cover"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if len(deployment.Spec.Template.Spec.Containers[0].Ports) != 2 {
+		t.Errorf("Expected 2 ports after enabling twice, got %d", len(deployment.Spec.Template.Spec.Containers[0].Ports))
+	}
+}
+
+func TestDisableCoverage(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestDeployment())
+	client := &CoverageClient{clientset: clientset, namespace: "default"}
+
+	ctx := context.Background()
+	if err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{ImageTagSuffix: "-cover"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.DisableCoverage(ctx, "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deployment, _ := clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	container := deployment.Spec.Template.Spec.Containers[0]
+
+	if container.Image != "quay.io/org/app:v1" {
+		t.Errorf("Expected original image, got %s", container.Image)
+	}
+	if len(container.Ports) != 1 || container.Ports[0].Name != "http" {
+		t.Errorf("Expected only the http port, got %v", container.Ports)
+	}
+	if len(container.Env) != 0 || len(container.VolumeMounts) != 0 || len(deployment.Spec.Template.Spec.Volumes) != 0 {
+		t.Errorf("Coverage env/volumes not removed")
+	}
+	if _, ok := deployment.Annotations[AnnotationEnabled]; ok {
+		t.Errorf("Enabled annotation not removed")
+	}
+}
+
+func TestDisableCoverage_RestoresExistingConfig(t *testing.T) {
+	deployment := newTestDeployment()
+	original := corev1.Container{
+		Name:  "app",
+		Image: "quay.io/org/app:v1",
+		Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8000}, {Name: coveragePortName, ContainerPort: 9100}},
+		Env: []corev1.EnvVar{
+			{Name: "COVERAGE_PORT", Value: "9100"},
+			{Name: "GOCOVERDIR", Value: "/data/coverage"},
+			{Name: "COVERAGE_FLUSH_INTERVAL", Value: "1m"},
+			{Name: coveragePodNameEnv, ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.uid"}}},
+			{Name: "LOG_LEVEL", Value: "debug"},
+		},
+	}
+	deployment.Spec.Template.Spec.Containers[0] = *original.DeepCopy()
+	clientset := fake.NewSimpleClientset(deployment)
+	client := &CoverageClient{clientset: clientset, namespace: "default"}
+
+	ctx := context.Background()
+	opts := EnableCoverageOptions{Port: 9100, PVCName: "coverage-pvc", FlushInterval: 30 * time.Second}
+	if err := client.EnableCoverage(ctx, "demo", opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if env := deployment.Spec.Template.Spec.Containers[0].Env; env[1].Value != defaultCoverDir || env[2].Value != "30s" {
+		t.Errorf("Expected the coverage env vars to be set, got %v", env)
+	}
+
+	if err := client.DisableCoverage(ctx, "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if !equality.Semantic.DeepEqual(container.Env, original.Env) {
+		t.Errorf("Expected the original env vars %v, got %v", original.Env, container.Env)
+	}
+	if !equality.Semantic.DeepEqual(container.Ports, original.Ports) {
+		t.Errorf("Expected the original ports %v, got %v", original.Ports, container.Ports)
+	}
+	if _, ok := deployment.Annotations[AnnotationOriginalEnv]; ok {
+		t.Errorf("Original env annotation not removed")
+	}
+}
+
+func TestEnableCoverage_Conflicts(t *testing.T) {
+	ctx := context.Background()
+
+	deployment := newTestDeployment()
+	deployment.Spec.Template.Spec.Containers[0].Ports = append(deployment.Spec.Template.Spec.Containers[0].Ports,
+		corev1.ContainerPort{Name: coveragePortName, ContainerPort: 9100})
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(deployment), namespace: "default"}
+	if err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{}); err == nil {
+		t.Error("Expected an error for a coverage port on another number")
+	}
+
+	deployment = newTestDeployment()
+	deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{Name: coverageVolumeName, VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "data"}},
+	}}}
+	clientset := fake.NewSimpleClientset(deployment)
+	client = &CoverageClient{clientset: clientset, namespace: "default"}
+	if err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{}); err == nil {
+		t.Error("Expected an error for an existing coverage-data volume")
+	}
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if volume := deployment.Spec.Template.Spec.Volumes[0]; volume.ConfigMap == nil || deployment.Annotations[AnnotationEnabled] == "true" {
+		t.Errorf("Expected the deployment to be left unchanged, got volume %+v and annotations %v", volume, deployment.Annotations)
+	}
+}
+
+func TestEnableCoverage_PVC(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestDeployment())
+	client := &CoverageClient{clientset: clientset, namespace: "default"}
+
+	ctx := context.Background()
+	err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{PVCName: "coverage-pvc", FlushInterval: 30 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deployment, _ := clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	container := deployment.Spec.Template.Spec.Containers[0]
+
+	claim := deployment.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim
+	if claim == nil || claim.ClaimName != "coverage-pvc" {
+		t.Errorf("Expected PVC volume, got %+v", deployment.Spec.Template.Spec.Volumes[0])
+	}
+	if container.VolumeMounts[0].SubPathExpr != "$(COVERAGE_POD_NAME)" {
+		t.Errorf("Expected per-pod sub path, got %q", container.VolumeMounts[0].SubPathExpr)
+	}
+	if len(container.Env) != 4 || container.Env[2].Value != "30s" || container.Env[3].ValueFrom == nil {
+		t.Errorf("Flush interval / pod name env vars not set correctly: %v", container.Env)
+	}
+
+	if err := client.DisableCoverage(ctx, "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if len(deployment.Spec.Template.Spec.Containers[0].Env) != 0 || len(deployment.Spec.Template.Spec.Volumes) != 0 {
+		t.Errorf("Coverage env/volumes not removed")
+	}
+}
+
+func TestEnableCoverage_FlushTarget(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestDeployment())
+	client := &CoverageClient{clientset: clientset, namespace: "default", quiet: true}
+
+	ctx := context.Background()
+	err := client.EnableCoverage(ctx, "demo", EnableCoverageOptions{FlushTarget: "http://runner.e2e.svc:8080"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deployment, _ := clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	lifecycle := deployment.Spec.Template.Spec.Containers[0].Lifecycle
+	if lifecycle == nil || lifecycle.PreStop == nil || lifecycle.PreStop.HTTPGet == nil {
+		t.Fatalf("Expected an httpGet preStop hook, got %+v", lifecycle)
+	}
+	hook := lifecycle.PreStop.HTTPGet
+	if hook.Path != "/coverage/flush?target=http%3A%2F%2Frunner.e2e.svc%3A8080" || hook.Port.IntValue() != 9095 {
+		t.Errorf("Expected a flush to the target on the coverage port, got %s on %s", hook.Path, hook.Port.String())
+	}
+	if env := findEnvVar(deployment.Spec.Template.Spec.Containers[0].Env, flushTargetsEnv); env == nil || env.Value != "http://runner.e2e.svc:8080" {
+		t.Errorf("Expected the target to be allowed in %s, got %+v", flushTargetsEnv, env)
+	}
+
+	if err := client.DisableCoverage(ctx, "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if deployment.Spec.Template.Spec.Containers[0].Lifecycle != nil || deployment.Annotations[AnnotationPreStopFlush] != "" {
+		t.Errorf("Expected the preStop hook to be removed, got %+v", deployment.Spec.Template.Spec.Containers[0].Lifecycle)
+	}
+	if env := findEnvVar(deployment.Spec.Template.Spec.Containers[0].Env, flushTargetsEnv); env != nil {
+		t.Errorf("Expected %s to be removed, got %+v", flushTargetsEnv, env)
+	}
+
+	// An existing hook is kept, and kept when disabling
+	existing := newTestDeployment()
+	existing.Spec.Template.Spec.Containers[0].Lifecycle = &corev1.Lifecycle{PreStop: &corev1.LifecycleHandler{
+		Exec: &corev1.ExecAction{Command: []string{"sleep", "5"}},
+	}}
+	clientset = fake.NewSimpleClientset(existing)
+	client.clientset = clientset
+	client.EnableCoverage(ctx, "demo", EnableCoverageOptions{FlushTarget: "http://runner.e2e.svc:8080"})
+	client.DisableCoverage(ctx, "demo")
+	deployment, _ = clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if preStop := deployment.Spec.Template.Spec.Containers[0].Lifecycle.PreStop; preStop.Exec == nil || preStop.HTTPGet != nil {
+		t.Errorf("Expected the app's preStop hook to be kept, got %+v", preStop)
+	}
+}
+
+func TestWithImageTagSuffix(t *testing.T) {
+	tests := map[string]string{
+		"app:v1":                       "app:v1-cover",
+		"app":                          "app:latest-cover",
+		"localhost:5000/app":           "localhost:5000/app:latest-cover",
+		"localhost:5000/app:v2":        "localhost:5000/app:v2-cover",
+		"app:v1-cover":                 "app:v1-cover",
+		"app@sha256:0123456789abcdef0": "app@sha256:0123456789abcdef0",
+	}
+
+	for image, expected := range tests {
+		if got := withImageTagSuffix(image, "-cover"); got != expected {
+			t.Errorf("withImageTagSuffix(%s) = %s, expected %s", image, got, expected)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package coverageclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ChangedLines returns the Go lines added or modified since the merge base of baseRef and HEAD,
+// keyed by repository-relative file path
+func ChangedLines(ctx context.Context, repoDir, baseRef string) (map[string][]int, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--no-color", "--no-ext-diff", "-U0", baseRef+"...HEAD", "--", "*.go")
+	cmd.Dir = repoDir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git diff %s: %w\nOutput: %s", baseRef, err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("git diff %s: %w", baseRef, err)
+	}
+	return ParseUnifiedDiff(strings.NewReader(string(output)))
+}
+
+// ParseUnifiedDiff returns the added lines of a unified diff, keyed by new file path.
+// Deleted files are ignored.
+func ParseUnifiedDiff(r io.Reader) (map[string][]int, error) {
+	changed := make(map[string][]int)
+	file := ""
+	// Lines left in the current hunk, so content starting with "+++"/"---" isn't taken for a header
+	oldLeft, newLeft, newLine := 0, 0, 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case oldLeft > 0 || newLeft > 0:
+			switch {
+			case strings.HasPrefix(line, "+"):
+				if file != "" {
+					changed[file] = append(changed[file], newLine)
+				}
+				newLine++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, " "):
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+		case strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(line, "+++ ")
+			if file == "/dev/null" {
+				file = ""
+			}
+			file = strings.TrimPrefix(file, "b/")
+		case strings.HasPrefix(line, "@@ "):
+			// @@ -old[,count] +new[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("invalid hunk header: %q", line)
+			}
+			var err error
+			if _, oldLeft, err = parseHunkRange(fields[1]); err != nil {
+				return nil, fmt.Errorf("invalid hunk header: %q", line)
+			}
+			if newLine, newLeft, err = parseHunkRange(fields[2]); err != nil {
+				return nil, fmt.Errorf("invalid hunk header: %q", line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read diff: %w", err)
+	}
+	return changed, nil
+}
+
+// parseHunkRange parses "-start[,count]" or "+start[,count]" of a hunk header
+func parseHunkRange(r string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(r[1:], ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
@@ -0,0 +1,41 @@
+package coverageclient
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -3,0 +4,2 @@ import (
++	"fmt"
++	"os"
+@@ -20,2 +22,3 @@ func main() {
+-	old()
++	new()
+ 	unchanged()
+++++ not a header
+diff --git a/removed.go b/removed.go
+deleted file mode 100644
+--- a/removed.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package main
+-
+-func removed() {}
+@@ -40,2 +41,0 @@ func trim() {
+`
+	changed, err := ParseUnifiedDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("ParseUnifiedDiff failed: %v", err)
+	}
+
+	want := map[string][]int{"main.go": {4, 5, 22, 24}}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("Expected %v, got %v", want, changed)
+	}
+}
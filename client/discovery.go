@@ -0,0 +1,281 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultProbeTimeout bounds a /health probe when DiscoveryOptions.ProbeTimeout is unset and for
+// container detection
+const defaultProbeTimeout = 5 * time.Second
+
+// Headers of the coverage server's /health responses naming the container it runs in (pod
+// containers share the network, so the port alone doesn't tell)
+const (
+	coverageContainerHeader   = "X-Coverage-Container"
+	coverageContainerIDHeader = "X-Coverage-Container-ID"
+)
+
+// DiscoverySource describes how an instrumented pod was identified
+type DiscoverySource string
+
+const (
+	// DiscoveredByAnnotation means the pod carries the coverage.psturc.io/port annotation
+	DiscoveredByAnnotation DiscoverySource = "annotation"
+	// DiscoveredByPortName means a container declares a port named "coverage"
+	DiscoveredByPortName DiscoverySource = "port-name"
+	// DiscoveredByProbe means the coverage /health endpoint answered on the probe port
+	DiscoveredByProbe DiscoverySource = "probe"
+)
+
+// InstrumentedPod is a pod exposing a coverage endpoint
+type InstrumentedPod struct {
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace"`
+	Container string          `json:"container,omitempty"`
+	Port      int             `json:"port"`
+	Source    DiscoverySource `json:"source"`
+}
+
+// DiscoveryOptions configures DiscoverInstrumentedPodsWithOptions
+type DiscoveryOptions struct {
+	LabelSelector string        // Restrict the scan to matching pods (default: all pods)
+	AllNamespaces bool          // Scan the whole cluster instead of the client's namespace
+	Probe         bool          // Probe unannotated pods via port-forward to ProbePort
+	ProbePort     int           // Port to probe (default: 9095, see SetCoveragePort)
+	ProbeTimeout  time.Duration // Timeout of a single /health probe (default: 5s)
+}
+
+// DiscoverInstrumentedPods scans the client's namespace for running pods exposing a
+// coverage endpoint, identified by the coverage.psturc.io/port annotation or a container
+// port named "coverage". Use the result to collect from everything that's instrumented.
+func (c *CoverageClient) DiscoverInstrumentedPods(ctx context.Context) ([]InstrumentedPod, error) {
+	return c.DiscoverInstrumentedPodsWithOptions(ctx, DiscoveryOptions{})
+}
+
+// DiscoverInstrumentedPodsWithOptions scans for instrumented pods, optionally restricted by
+// a label selector and optionally probing pods without coverage annotations.
+func (c *CoverageClient) DiscoverInstrumentedPodsWithOptions(ctx context.Context, opts DiscoveryOptions) ([]InstrumentedPod, error) {
+	opts.ProbePort = c.coveragePort(opts.ProbePort)
+	if opts.ProbeTimeout == 0 {
+		opts.ProbeTimeout = defaultProbeTimeout
+	}
+
+	namespace := c.namespace
+	if opts.AllNamespaces {
+		namespace = metav1.NamespaceAll
+		c.logf("🔍 Discovering instrumented pods in all namespaces\n")
+	} else {
+		c.logf("🔍 Discovering instrumented pods in namespace: %s\n", namespace)
+	}
+
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: opts.LabelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+
+	var result []InstrumentedPod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		if instrumented, ok := c.instrumentedPodFromSpec(&pod); ok {
+			result = append(result, instrumented)
+			continue
+		}
+
+		if !opts.Probe {
+			continue
+		}
+		if header, ok := c.inNamespace(pod.Namespace).probeCoverageEndpoint(ctx, pod.Name, opts.ProbePort, opts.ProbeTimeout); ok {
+			container := servingContainer(&pod, header)
+			if container == "" {
+				container = containerForPort(pod.Spec.Containers, opts.ProbePort)
+			}
+			result = append(result, InstrumentedPod{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+				Container: container,
+				Port:      opts.ProbePort,
+				Source:    DiscoveredByProbe,
+			})
+		}
+	}
+
+	for _, p := range result {
+		c.logf("  ✅ %s (port %d, via %s)\n", p.Name, p.Port, p.Source)
+	}
+	c.logf("🔍 Found %d instrumented pod(s)\n", len(result))
+
+	return result, nil
+}
+
+// CollectAllAnnotated collects coverage from every instrumented pod DiscoverInstrumentedPods finds
+// in the client's namespace, concurrently, each on its own coverage port and into <test>/<pod>.
+// Like CollectCoverageFromDeployment, failing pods don't stop the others.
+func (c *CoverageClient) CollectAllAnnotated(ctx context.Context, testName string) ([]*CollectResult, error) {
+	return c.CollectAllAnnotatedWithOptions(ctx, testName, DiscoveryOptions{})
+}
+
+// CollectAllAnnotatedWithOptions collects from the pods DiscoverInstrumentedPodsWithOptions finds.
+// With AllNamespaces, pods are saved into <test>/<namespace>/<pod>, one namespace at a time.
+func (c *CoverageClient) CollectAllAnnotatedWithOptions(ctx context.Context, testName string, opts DiscoveryOptions) ([]*CollectResult, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	found, err := c.DiscoverInstrumentedPodsWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("collect from instrumented pods: %w", ErrNoPodsFound)
+	}
+
+	c.logf("📦 Collecting coverage from %d instrumented pod(s) for test: %s\n", len(found), testName)
+	return c.collectInstrumented(ctx, testName, found, opts.AllNamespaces)
+}
+
+// collectInstrumented collects from the found pods namespace by namespace, each into <test>/<pod>,
+// or <test>/<namespace>/<pod> when nested
+func (c *CoverageClient) collectInstrumented(ctx context.Context, testName string, found []InstrumentedPod, nested bool) ([]*CollectResult, error) {
+	byNamespace := make(map[string]map[string]InstrumentedPod)
+	for _, pod := range found {
+		if byNamespace[pod.Namespace] == nil {
+			byNamespace[pod.Namespace] = make(map[string]InstrumentedPod)
+		}
+		byNamespace[pod.Namespace][pod.Name] = pod
+	}
+	namespaces := make([]string, 0, len(byNamespace))
+	for namespace := range byNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var results []*CollectResult
+	var errs []error
+	for _, namespace := range namespaces {
+		client, dir := c.inNamespace(namespace), testName
+		if nested {
+			dir = path.Join(testName, namespace)
+		}
+
+		pods := byNamespace[namespace]
+		names := make([]string, 0, len(pods))
+		for name := range pods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		collected, err := client.collectReplicas(ctx, names, 0, func(name string) CollectOptions {
+			pod := pods[name]
+			return CollectOptions{TestName: path.Join(dir, name), PodName: name, Container: pod.Container, Port: pod.Port}
+		})
+		results = append(results, collected...)
+		if err != nil && nested {
+			err = fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return results, fmt.Errorf("collect from instrumented pods: %w", err)
+	}
+	return results, nil
+}
+
+// instrumentedPodFromSpec identifies an instrumented pod from its annotations or container ports
+func (c *CoverageClient) instrumentedPodFromSpec(pod *corev1.Pod) (InstrumentedPod, bool) {
+	if value, ok := pod.Annotations[AnnotationPort]; ok {
+		if port, err := strconv.Atoi(value); err == nil && port > 0 {
+			container := pod.Annotations[AnnotationContainer]
+			if container == "" {
+				container = containerForPort(pod.Spec.Containers, port)
+			}
+			return InstrumentedPod{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+				Container: container,
+				Port:      port,
+				Source:    DiscoveredByAnnotation,
+			}, true
+		}
+		c.logf("  ⚠️  Ignoring invalid %s annotation on pod %s: %q\n", AnnotationPort, pod.Name, value)
+	}
+
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == coveragePortName {
+				return InstrumentedPod{
+					Name:      pod.Name,
+					Namespace: pod.Namespace,
+					Container: container.Name,
+					Port:      int(port.ContainerPort),
+					Source:    DiscoveredByPortName,
+				}, true
+			}
+		}
+	}
+
+	return InstrumentedPod{}, false
+}
+
+// probeCoverageEndpoint checks whether the coverage /health endpoint answers on the given pod port
+// and returns the response headers, see servingContainer
+func (c *CoverageClient) probeCoverageEndpoint(ctx context.Context, podName string, port int, timeout time.Duration) (http.Header, bool) {
+	tunnel, err := c.setupPortForward(ctx, podName, port)
+	if err != nil {
+		return nil, false
+	}
+	defer tunnel.Close()
+
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(probeCtx, http.MethodGet, fmt.Sprintf("http://localhost:%d/health", tunnel.LocalPort()), nil)
+	if err != nil {
+		return nil, false
+	}
+	req.Close = true // Don't keep a connection through the tunnel alive
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	return resp.Header, resp.StatusCode == http.StatusOK
+}
+
+// servingContainer returns the container of pod the coverage server answering a /health probe
+// runs in: the container it names, or the one whose status carries its container ID. It returns ""
+// when the server doesn't identify its container (older servers, or /proc without the kubelet's
+// mounts and with a private cgroup namespace).
+func servingContainer(pod *corev1.Pod, header http.Header) string {
+	if name := header.Get(coverageContainerHeader); name != "" {
+		for _, container := range pod.Spec.Containers {
+			if container.Name == name {
+				return name
+			}
+		}
+	}
+	if id := header.Get(coverageContainerIDHeader); id != "" {
+		for _, status := range pod.Status.ContainerStatuses {
+			// Container IDs are reported as <runtime>://<id>
+			if _, statusID, _ := strings.Cut(status.ContainerID, "://"); statusID == id {
+				return status.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiscoverInstrumentedPods(t *testing.T) {
+	pods := []runtime.Object{
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "annotated",
+				Namespace:   "default",
+				Labels:      map[string]string{"app": "a"},
+				Annotations: map[string]string{AnnotationPort: "9096", AnnotationContainer: "sidecar"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "named-port", Namespace: "default", Labels: map[string]string{"app": "b"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "app",
+				Ports: []corev1.ContainerPort{{Name: "coverage", ContainerPort: 9095}},
+			}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pending",
+				Namespace:   "default",
+				Annotations: map[string]string{AnnotationPort: "9095"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	}
+
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(pods...), namespace: "default"}
+
+	found, err := client.DiscoverInstrumentedPods(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("Expected 2 instrumented pods, got %d: %+v", len(found), found)
+	}
+
+	byName := map[string]InstrumentedPod{}
+	for _, p := range found {
+		byName[p.Name] = p
+	}
+	if p := byName["annotated"]; p.Port != 9096 || p.Container != "sidecar" || p.Source != DiscoveredByAnnotation {
+		t.Errorf("Unexpected annotated pod result: %+v", p)
+	}
+	if p := byName["named-port"]; p.Port != 9095 || p.Container != "app" || p.Source != DiscoveredByPortName {
+		t.Errorf("Unexpected named-port pod result: %+v", p)
+	}
+
+	found, err = client.DiscoverInstrumentedPodsWithOptions(context.Background(), DiscoveryOptions{LabelSelector: "app=b"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != "named-port" {
+		t.Errorf("Expected only named-port with selector, got %+v", found)
+	}
+}
+
+func TestDetectContainerByPort(t *testing.T) {
+	const containerID = "3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a"
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		fmt.Fprint(w, "coverage server healthy")
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", ContainerID: "containerd://0000"},
+			{Name: "sidecar", ContainerID: "containerd://" + containerID},
+		}},
+	}
+	client := &CoverageClient{namespace: "default", quiet: true}
+	client.SetPortForwarder(&fakePortForwarder{port: port})
+	// Detection works without exec, e.g. for distroless images or without pods/exec RBAC
+	client.SetExecEnabled(false)
+
+	for _, tc := range []struct {
+		header http.Header
+		want   string
+	}{
+		{http.Header{coverageContainerHeader: {"sidecar"}}, "sidecar"},
+		{http.Header{coverageContainerIDHeader: {containerID}}, "sidecar"},
+		{http.Header{coverageContainerHeader: {"unknown"}}, ""},
+		{nil, ""}, // Older coverage servers don't name their container
+	} {
+		header = tc.header
+		if name := client.detectContainerByPort(context.Background(), pod, 9095); name != tc.want {
+			t.Errorf("Header %v: expected %q, got %q", tc.header, tc.want, name)
+		}
+	}
+}
+
+func TestCollectAllAnnotated(t *testing.T) {
+	annotated := func(name, namespace string) *corev1.Pod {
+		pod := replicaPod(name, corev1.PodRunning, nil)
+		pod.Namespace = namespace
+		pod.Annotations = map[string]string{AnnotationPort: "9096", AnnotationContainer: "app"}
+		return pod
+	}
+	client, forwarder := newReplicaClient(t, "",
+		annotated("api", "default"),
+		annotated("worker", "default"),
+		annotated("billing", "payments"),
+		replicaPod("plain", corev1.PodRunning, nil),
+	)
+
+	results, err := client.CollectAllAnnotated(context.Background(), "e2e")
+	if err != nil {
+		t.Fatalf("CollectAllAnnotated failed: %v", err)
+	}
+	if len(results) != 2 || results[0].TestName != "e2e/api" || results[1].TestName != "e2e/worker" {
+		t.Fatalf("Expected a result per annotated pod of the namespace, got %+v", results)
+	}
+	if slices.Contains(forwarder.pods, "plain") || slices.Contains(forwarder.pods, "billing") {
+		t.Errorf("Expected only the annotated pods of the namespace, got %v", forwarder.pods)
+	}
+
+	results, err = client.CollectAllAnnotatedWithOptions(context.Background(), "cluster", DiscoveryOptions{AllNamespaces: true})
+	if err != nil {
+		t.Fatalf("CollectAllAnnotatedWithOptions failed: %v", err)
+	}
+	if len(results) != 3 || results[2].TestName != "cluster/payments/billing" {
+		t.Fatalf("Expected the pods of every namespace, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "cluster", "payments", "billing", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the covdata in the namespace's subdirectory: %v", err)
+	}
+
+	client, _ = newReplicaClient(t, "", replicaPod("plain", corev1.PodRunning, nil))
+	if _, err := client.CollectAllAnnotated(context.Background(), "e2e"); !errors.Is(err, ErrNoPodsFound) {
+		t.Errorf("Expected ErrNoPodsFound, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package coverageclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultMinFreeSpace is the free space collections leave on the output directory's file system
+// when SetMinFreeSpace wasn't called
+const defaultMinFreeSpace = 256 << 20
+
+// SetMinFreeSpace sets the free space in bytes collections must leave on the output directory's
+// file system; a collection fails before writing anything when less would be left
+// (default: 256 MiB, negative disables the check)
+func (c *CoverageClient) SetMinFreeSpace(bytes int64) {
+	c.minFreeSpace = bytes
+}
+
+// checkFreeSpace fails when writing size bytes (negative if unknown) to dir would leave less than
+// the minimum free space. The check is skipped on platforms without free space information.
+func (c *CoverageClient) checkFreeSpace(dir string, size int64) error {
+	minFree := c.minFreeSpace
+	if minFree == 0 {
+		minFree = defaultMinFreeSpace
+	}
+	if minFree < 0 {
+		return nil
+	}
+
+	free, err := freeSpace(existingDir(dir))
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		c.logf("⚠️  Could not check free space in %s: %v\n", dir, err)
+		return nil
+	}
+
+	required := uint64(minFree) + uint64(max(size, 0))
+	if free < required {
+		return fmt.Errorf("insufficient free space in %s: %s available, %s required (see SetMinFreeSpace)",
+			dir, formatBytes(free), formatBytes(required))
+	}
+	return nil
+}
+
+// createTestDir checks the free space for a collection and creates its test directory
+func (c *CoverageClient) createTestDir(testName string) (string, error) {
+	if err := checkTestName(testName); err != nil {
+		return "", err
+	}
+	testDir := filepath.Join(c.outputDir, testName)
+	if err := c.checkFreeSpace(testDir, -1); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		return "", fmt.Errorf("create test directory: %w", err)
+	}
+	return testDir, nil
+}
+
+// existingDir returns dir or its closest existing parent, whose file system dir will be created on
+func existingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// formatBytes formats a size in binary units
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
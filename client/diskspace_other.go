@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package coverageclient
+
+import "errors"
+
+// freeSpace is unsupported on this platform, the free space check is skipped
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
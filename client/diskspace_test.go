@@ -0,0 +1,66 @@
+package coverageclient
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateTestDir_MinFreeSpace(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "coverage")
+	if _, err := freeSpace(existingDir(outputDir)); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("Free space is unknown on this platform")
+	}
+
+	client := &CoverageClient{outputDir: outputDir}
+	client.SetMinFreeSpace(1 << 62)
+	if _, err := client.createTestDir("e2e"); err == nil || !strings.Contains(err.Error(), "insufficient free space") {
+		t.Fatalf("Expected free space error, got %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be created before the check, got %v", err)
+	}
+
+	client.SetMinFreeSpace(-1)
+	testDir, err := client.createTestDir("e2e")
+	if err != nil {
+		t.Fatalf("Expected disabled check to pass, got %v", err)
+	}
+	if info, err := os.Stat(testDir); err != nil || !info.IsDir() {
+		t.Errorf("Expected test directory %s, got %v", testDir, err)
+	}
+}
+
+func TestCheckResponseSize(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir()}
+	client.SetMinFreeSpace(-1)
+	client.SetMaxResponseSize(1000)
+
+	for length, wantErr := range map[int64]bool{-1: false, 0: false, 1000: false, 1001: true} {
+		err := client.checkResponseSize(length, "e2e")
+		if (err != nil) != wantErr {
+			t.Errorf("Length %d: expected error %v, got %v", length, wantErr, err)
+		}
+	}
+
+	client.SetMaxResponseSize(-1)
+	if err := client.checkResponseSize(1<<40, "e2e"); err != nil {
+		t.Errorf("Expected unlimited responses to pass, got %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{
+		512:           "512 B",
+		1536:          "1.5 KiB",
+		256 << 20:     "256.0 MiB",
+		3 << 30:       "3.0 GiB",
+		(5 << 40) + 1: "5.0 TiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d): expected %q, got %q", n, want, got)
+		}
+	}
+}
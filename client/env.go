@@ -0,0 +1,173 @@
+package coverageclient
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables overriding the client's defaults when it's constructed, for configuration
+// without code changes in CI. Settings made in code or a configuration file take precedence.
+const (
+	envNamespace       = "COVERAGE_NAMESPACE"         // Namespace, when the constructor gets none
+	envKubeContext     = "COVERAGE_KUBE_CONTEXT"      // kubeconfig context, when the constructor gets none
+	envOutputDir       = "COVERAGE_OUTPUT_DIR"        // Output directory, when the constructor gets none
+	envPort            = "COVERAGE_PORT"              // Coverage port, see SetCoveragePort
+	envSourceDir       = "COVERAGE_SOURCE_DIR"        // See SetSourceDirectory
+	envPathRemap       = "COVERAGE_PATH_REMAP"        // See SetPathRemapping
+	envFilters         = "COVERAGE_FILTERS"           // Comma-separated, see SetDefaultFilters
+	envPackages        = "COVERAGE_PACKAGES"          // Comma-separated, see SetPackageSelectors
+	envPushRef         = "COVERAGE_PUSH_REF"          // registry/repository[:tag], see SetArtifactDestination
+	envPushExpires     = "COVERAGE_PUSH_EXPIRES"      // Artifact expiration, e.g. "30d"
+	envBaselineRef     = "COVERAGE_BASELINE_REF"      // registry/repository[:tag pattern], see SetBaseline
+	envMinTotal        = "COVERAGE_MIN_TOTAL"         // See CoverageThresholds
+	envMinPatch        = "COVERAGE_MIN_PATCH"         // See CoverageThresholds
+	envMaxDecrease     = "COVERAGE_MAX_DECREASE"      // See CoverageThresholds
+	envMinPackage      = "COVERAGE_MIN_PACKAGE"       // See CoverageThresholds
+	envExec            = "COVERAGE_EXEC"              // See SetExecEnabled
+	envPortForwardOnly = "COVERAGE_PORT_FORWARD_ONLY" // See SetPortForwardOnly
+	envQuiet           = "COVERAGE_QUIET"             // See SetQuiet
+	envRetries         = "COVERAGE_RETRIES"           // Port-forward collection retries, see SetRetryPolicy
+	envLogLevel        = "COVERAGE_LOG_LEVEL"         // debug, info, warn or error, see SetLogLevel
+	envLogFormat       = "COVERAGE_LOG_FORMAT"        // text or json (stdout), see NewJSONLogger
+)
+
+// defaultOutputDir is the output directory when neither the constructor nor COVERAGE_OUTPUT_DIR name one
+const defaultOutputDir = "coverage-output"
+
+// SetCoveragePort sets the coverage port used when a collection doesn't name one (default: 9095)
+func (c *CoverageClient) SetCoveragePort(port int) {
+	c.port = port
+}
+
+// valueOrEnv returns value, or the environment variable name when value is empty
+func valueOrEnv(value, name string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// applyEnv applies the environment variables to the client's defaults
+func (c *CoverageClient) applyEnv() error {
+	if v := os.Getenv(envPort); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("parse %s: invalid port %q", envPort, v)
+		}
+		c.SetCoveragePort(port)
+	}
+	if v := os.Getenv(envSourceDir); v != "" {
+		c.SetSourceDirectory(v)
+	}
+	// Set but empty disables filtering
+	if v, ok := os.LookupEnv(envFilters); ok {
+		c.SetDefaultFilters(splitEnvList(v))
+	}
+	if v := os.Getenv(envPackages); v != "" {
+		c.SetPackageSelectors(splitEnvList(v))
+	}
+
+	if v := os.Getenv(envPushRef); v != "" {
+		registry, repository, tag, err := parseArtifactRef(v)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", envPushRef, err)
+		}
+		if tag == "" {
+			tag = "{test}"
+		}
+		c.SetArtifactDestination(PushCoverageArtifactOptions{
+			Registry:     registry,
+			Repository:   repository,
+			Tag:          tag,
+			ExpiresAfter: os.Getenv(envPushExpires),
+		})
+	}
+	if v := os.Getenv(envBaselineRef); v != "" {
+		registry, repository, tag, err := parseArtifactRef(v)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", envBaselineRef, err)
+		}
+		c.SetBaseline(BaselineOptions{Registry: registry, Repository: repository, TagPattern: tag})
+	}
+
+	for name, threshold := range map[string]*float64{
+		envMinTotal:    &c.thresholds.MinTotal,
+		envMinPatch:    &c.thresholds.MinPatch,
+		envMaxDecrease: &c.thresholds.MaxDecrease,
+		envMinPackage:  &c.thresholds.MinPackage,
+	} {
+		if v := os.Getenv(name); v != "" {
+			percent, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("parse %s: %w", name, err)
+			}
+			*threshold = percent
+		}
+	}
+
+	if v := os.Getenv(envRetries); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 0 {
+			return fmt.Errorf("parse %s: invalid retry count %q", envRetries, v)
+		}
+		c.SetRetryPolicy(RetryPolicy{Attempts: attempts})
+	}
+	if v := os.Getenv(envLogLevel); v != "" {
+		level, err := ParseLevel(v)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", envLogLevel, err)
+		}
+		c.SetLogLevel(level)
+	}
+	if v := os.Getenv(envLogFormat); v != "" {
+		if err := c.setLogFormat(v); err != nil {
+			return fmt.Errorf("parse %s: %w", envLogFormat, err)
+		}
+	}
+
+	// In order: port-forward-only disables exec
+	for _, setting := range []struct {
+		name string
+		set  func(bool)
+	}{
+		{envPathRemap, c.SetPathRemapping},
+		{envExec, c.SetExecEnabled},
+		{envPortForwardOnly, c.SetPortForwardOnly},
+		{envQuiet, c.SetQuiet},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("parse %s: %w", setting.name, err)
+			}
+			setting.set(enabled)
+		}
+	}
+	return nil
+}
+
+// splitEnvList splits a comma-separated environment variable, dropping empty entries
+func splitEnvList(v string) []string {
+	list := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseArtifactRef splits an artifact reference such as quay.io/org/coverage:e2e-main into
+// registry, repository and the optional tag
+func parseArtifactRef(ref string) (registry, repository, tag string, err error) {
+	registry, repository, ok := strings.Cut(ref, "/")
+	if !ok || registry == "" || repository == "" {
+		return "", "", "", fmt.Errorf("invalid artifact reference %q, expected registry/repository[:tag]", ref)
+	}
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return registry, repository, tag, nil
+}
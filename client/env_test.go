@@ -0,0 +1,105 @@
+package coverageclient
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewLocalClient_Env(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "out")
+	t.Setenv("COVERAGE_OUTPUT_DIR", outputDir)
+	t.Setenv("COVERAGE_PORT", "9100")
+	t.Setenv("COVERAGE_FILTERS", "coverage_server.go, mock_*.go,")
+	t.Setenv("COVERAGE_PACKAGES", "github.com/org/app/...")
+	t.Setenv("COVERAGE_PUSH_REF", "quay.io/org/coverage:{test}-pr")
+	t.Setenv("COVERAGE_PUSH_EXPIRES", "7d")
+	t.Setenv("COVERAGE_BASELINE_REF", "quay.io/org/coverage")
+	t.Setenv("COVERAGE_MIN_TOTAL", "70")
+	t.Setenv("COVERAGE_MAX_DECREASE", "0.5")
+	t.Setenv("COVERAGE_EXEC", "true")
+	t.Setenv("COVERAGE_PORT_FORWARD_ONLY", "true")
+	t.Setenv("COVERAGE_QUIET", "1")
+	t.Setenv("COVERAGE_RETRIES", "3")
+	t.Setenv("COVERAGE_LOG_LEVEL", "warning")
+	t.Setenv("COVERAGE_LOG_FORMAT", "json")
+
+	client, err := NewLocalClient("")
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %v", err)
+	}
+	if client.outputDir != outputDir || client.coveragePort(0) != 9100 || client.coveragePort(8080) != 8080 {
+		t.Errorf("Unexpected output directory %s or port %d", client.outputDir, client.coveragePort(0))
+	}
+	if strings.Join(client.defaultFilters, ",") != "coverage_server.go,mock_*.go" || len(client.packages) != 1 {
+		t.Errorf("Unexpected filters %v and packages %v", client.defaultFilters, client.packages)
+	}
+	if opts := client.artifactOptions("e2e", PushCoverageArtifactOptions{}); opts.Registry != "quay.io" || opts.Repository != "org/coverage" || opts.Tag != "e2e-pr" || opts.ExpiresAfter != "7d" {
+		t.Errorf("Unexpected artifact options %+v", opts)
+	}
+	if client.baseline == nil || client.baseline.Repository != "org/coverage" || client.baseline.TagPattern != "{test}-main" {
+		t.Errorf("Unexpected baseline %+v", client.baseline)
+	}
+	if client.thresholds != (CoverageThresholds{MinTotal: 70, MaxDecrease: 0.5}) {
+		t.Errorf("Unexpected thresholds %+v", client.thresholds)
+	}
+	if !client.portForwardOnly || !client.disableExec || !client.quiet {
+		t.Error("Expected port-forward-only mode and quiet output")
+	}
+	if client.retryPolicy.Attempts != 3 {
+		t.Errorf("Expected 3 port-forward retries, got %+v", client.retryPolicy)
+	}
+	if _, ok := client.logger.(*JSONLogger); !ok || client.logLevel != LevelWarn {
+		t.Errorf("Expected JSON output from warnings, got %T at %v", client.logger, client.logLevel)
+	}
+
+	// Explicit arguments win
+	explicit := t.TempDir()
+	if client, err := NewLocalClient(explicit); err != nil || client.outputDir != explicit {
+		t.Errorf("Expected the explicit output directory, got %v (%v)", client, err)
+	}
+}
+
+func TestNewLocalClient_EnvEmptyFilters(t *testing.T) {
+	t.Setenv("COVERAGE_FILTERS", "")
+	client, err := NewLocalClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %v", err)
+	}
+	if len(client.defaultFilters) != 0 {
+		t.Errorf("Expected an empty COVERAGE_FILTERS to disable filtering, got %v", client.defaultFilters)
+	}
+}
+
+func TestNewLocalClient_EnvErrors(t *testing.T) {
+	for name, value := range map[string]string{
+		"COVERAGE_PORT":         "http",
+		"COVERAGE_MIN_TOTAL":    "70%",
+		"COVERAGE_EXEC":         "sometimes",
+		"COVERAGE_PUSH_REF":     "coverage",
+		"COVERAGE_BASELINE_REF": "quay.io/",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := NewLocalClient(t.TempDir()); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("Expected an error naming %s, got %v", name, err)
+			}
+		})
+	}
+}
+
+func TestParseArtifactRef(t *testing.T) {
+	tests := []struct {
+		ref, registry, repository, tag string
+	}{
+		{"quay.io/org/coverage:e2e", "quay.io", "org/coverage", "e2e"},
+		{"quay.io/org/coverage", "quay.io", "org/coverage", ""},
+		{"localhost:5000/coverage:{test}-main", "localhost:5000", "coverage", "{test}-main"},
+	}
+	for _, tt := range tests {
+		registry, repository, tag, err := parseArtifactRef(tt.ref)
+		if err != nil || registry != tt.registry || repository != tt.repository || tag != tt.tag {
+			t.Errorf("parseArtifactRef(%q) = %q, %q, %q, %v", tt.ref, registry, repository, tag, err)
+		}
+	}
+}
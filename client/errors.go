@@ -0,0 +1,88 @@
+package coverageclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors wrapped through the client API, so callers can branch on failure modes with errors.Is
+var (
+	// ErrNoPodsFound is returned when no pod matches a label selector
+	ErrNoPodsFound = errors.New("no pods found")
+	// ErrPodNotRunning is returned when the pods matching a label selector aren't running
+	ErrPodNotRunning = errors.New("no running pod found")
+	// ErrNoRunningPod is another name of ErrPodNotRunning
+	ErrNoRunningPod = ErrPodNotRunning
+	// ErrCoverageNotEnabled is returned when the application doesn't serve coverage: the endpoint
+	// is missing or the binary wasn't built with -cover
+	ErrCoverageNotEnabled = errors.New("coverage not enabled")
+	// ErrNotInstrumented is returned when the coverage server runs in a binary built without
+	// -cover. It also matches ErrCoverageNotEnabled, which a missing endpoint matches alone.
+	ErrNotInstrumented = errors.New("binary not built with -cover")
+	// ErrCoverageEndpointUnavailable is returned for failures that may go away on a later try: the
+	// coverage endpoint can't be reached, or it answers 429 or a server error other than a
+	// binary without -cover
+	ErrCoverageEndpointUnavailable = errors.New("coverage endpoint unavailable")
+	// ErrPortForwardTimeout is returned when a port-forward isn't ready in time
+	ErrPortForwardTimeout = errors.New("timeout waiting for port forward")
+	// ErrArtifactPush is returned when pushing a coverage artifact fails
+	ErrArtifactPush = errors.New("push artifact")
+	// ErrArtifactPull is returned when pulling a coverage artifact fails
+	ErrArtifactPull = errors.New("pull artifact")
+)
+
+// CoverageEndpointError is returned when the coverage endpoint answers with an error status.
+// It matches ErrCoverageNotEnabled when the endpoint doesn't exist or the binary lacks coverage,
+// ErrNotInstrumented for the latter, and ErrCoverageEndpointUnavailable for 429 and other server
+// errors.
+type CoverageEndpointError struct {
+	StatusCode int
+	Body       string
+	Code       string // Machine-readable error of JSON error responses, e.g. "not_instrumented"
+}
+
+// notInstrumentedCode is the Code of the 501 response of servers whose binary wasn't built with
+// -cover
+const notInstrumentedCode = "not_instrumented"
+
+// newCoverageEndpointError describes an error response of the coverage endpoint
+func newCoverageEndpointError(statusCode int, body []byte) *CoverageEndpointError {
+	e := &CoverageEndpointError{StatusCode: statusCode, Body: string(body)}
+	var response struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil {
+		e.Code = response.Error
+	}
+	return e
+}
+
+func (e *CoverageEndpointError) Error() string {
+	msg := fmt.Sprintf("coverage endpoint returned %d: %s", e.StatusCode, e.Body)
+	if e.notInstrumented() && !strings.Contains(e.Body, "rebuild") {
+		msg += " (rebuild the application with go build -cover)"
+	}
+	return msg
+}
+
+// notInstrumented reports whether the server runs in a binary built without -cover.
+// runtime/coverage reports "no meta-data available (binary not built with -cover?)" through
+// servers older than the not_instrumented code.
+func (e *CoverageEndpointError) notInstrumented() bool {
+	return e.Code == notInstrumentedCode || strings.Contains(e.Body, "not built with -cover")
+}
+
+func (e *CoverageEndpointError) Is(target error) bool {
+	switch target {
+	case ErrCoverageNotEnabled:
+		return e.StatusCode == http.StatusNotFound || e.notInstrumented()
+	case ErrNotInstrumented:
+		return e.notInstrumented()
+	case ErrCoverageEndpointUnavailable:
+		return (e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests) && !e.notInstrumented()
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCoverageEndpointError(t *testing.T) {
+	for _, tt := range []struct {
+		status          int
+		body            string
+		notEnabled      bool
+		notInstrumented bool
+		unavailable     bool
+	}{
+		{http.StatusNotFound, "404 page not found", true, false, false},
+		{http.StatusInternalServerError, "Failed to collect metadata: no meta-data available (binary not built with -cover?)", true, true, false},
+		{http.StatusNotImplemented, `{"error":"not_instrumented","message":"Failed to collect metadata: binary not built with -cover: rebuild the application with go build -cover"}`, true, true, false},
+		{http.StatusNotImplemented, `{"error":"not_instrumented"}`, true, true, false},
+		{http.StatusInternalServerError, "Failed to collect counters: disk full", false, false, true},
+		{http.StatusServiceUnavailable, "upstream connect error", false, false, true},
+		{http.StatusTooManyRequests, "slow down", false, false, true},
+		{http.StatusForbidden, "RBAC: access denied", false, false, false},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, tt.body, tt.status)
+		}))
+		client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client()}
+		err := client.CollectCoverageFromURL(server.URL, "e2e")
+		server.Close()
+
+		var endpointErr *CoverageEndpointError
+		if !errors.As(err, &endpointErr) || endpointErr.StatusCode != tt.status {
+			t.Errorf("%d: expected a CoverageEndpointError, got %v", tt.status, err)
+		}
+		if errors.Is(err, ErrCoverageNotEnabled) != tt.notEnabled {
+			t.Errorf("%d %q: expected ErrCoverageNotEnabled match %v, got %v", tt.status, tt.body, tt.notEnabled, err)
+		}
+		if tt.notInstrumented && !strings.Contains(err.Error(), "rebuild the application with go build -cover") {
+			t.Errorf("%d %q: expected a hint to rebuild with -cover, got %v", tt.status, tt.body, err)
+		}
+		if errors.Is(err, ErrNotInstrumented) != tt.notInstrumented {
+			t.Errorf("%d %q: expected ErrNotInstrumented match %v, got %v", tt.status, tt.body, tt.notInstrumented, err)
+		}
+		if errors.Is(err, ErrCoverageEndpointUnavailable) != tt.unavailable {
+			t.Errorf("%d %q: expected ErrCoverageEndpointUnavailable match %v, got %v", tt.status, tt.body, tt.unavailable, err)
+		}
+	}
+}
+
+func TestCoverageEndpointUnavailable_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: http.DefaultClient}
+	err := client.CollectCoverageFromURL(server.URL+"/coverage", "e2e")
+	if !errors.Is(err, ErrCoverageEndpointUnavailable) || errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected an unreachable endpoint to match ErrCoverageEndpointUnavailable only, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.collectCoverageFromURL(ctx, server.URL+"/coverage", "e2e"); errors.Is(err, ErrCoverageEndpointUnavailable) {
+		t.Errorf("Expected cancellation not to match ErrCoverageEndpointUnavailable, got %v", err)
+	}
+	if !errors.Is(fmt.Errorf("find pod: %w", ErrPodNotRunning), ErrNoRunningPod) {
+		t.Error("Expected ErrNoRunningPod to match ErrPodNotRunning")
+	}
+}
+
+func TestPortForwardWaitError(t *testing.T) {
+	if err := portForwardWaitError(context.DeadlineExceeded); !errors.Is(err, ErrPortForwardTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout to match ErrPortForwardTimeout and the context error, got %v", err)
+	}
+	if err := portForwardWaitError(context.Canceled); errors.Is(err, ErrPortForwardTimeout) {
+		t.Errorf("Expected cancellation not to match ErrPortForwardTimeout, got %v", err)
+	}
+}
+
+func TestPushCoverageArtifact_ErrArtifactPush(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir()}
+	err := client.PushCoverageArtifact(context.Background(), "missing", PushCoverageArtifactOptions{Registry: "localhost:5000", Repository: "coverage", Tag: "e2e"})
+	if !errors.Is(err, ErrArtifactPush) {
+		t.Errorf("Expected ErrArtifactPush, got %v", err)
+	}
+	if want := fmt.Sprintf("push artifact: test directory does not exist: %s/missing", client.outputDir); err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,103 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// AnnotationLastCollected stores the RFC3339 time coverage was last collected from a pod
+	AnnotationLastCollected = AnnotationPrefix + "last-collected"
+
+	// EventReasonCoverageCollected is the reason of the Event recorded on a pod after collection
+	EventReasonCoverageCollected = "CoverageCollected"
+
+	eventSourceComponent = "go-coverage-http"
+)
+
+// SetRecordCollection controls whether a successful collection records a Kubernetes Event on the pod
+// and sets the last-collected annotation (enabled by default). Recording needs create on events and
+// patch on pods; failures only log a warning.
+func (c *CoverageClient) SetRecordCollection(enabled bool) {
+	c.recordDisabled = !enabled
+}
+
+// recordCollection makes a successful collection visible in `kubectl describe pod`
+func (c *CoverageClient) recordCollection(ctx context.Context, podName, testName string, method CollectionMethod) {
+	if c.recordDisabled {
+		return
+	}
+
+	now := time.Now()
+	if err := c.annotateLastCollected(ctx, podName, now); err != nil {
+		c.logf("⚠️  Failed to annotate pod %s: %v\n", podName, err)
+	}
+	if err := c.recordCollectionEvent(ctx, podName, testName, method, now); err != nil {
+		c.logf("⚠️  Failed to record event on pod %s: %v\n", podName, err)
+	}
+}
+
+// annotateLastCollected sets the last-collected annotation with a merge patch
+func (c *CoverageClient) annotateLastCollected(ctx context.Context, podName string, collectedAt time.Time) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{AnnotationLastCollected: collectedAt.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal annotation patch: %w", err)
+	}
+
+	_, err = c.clientset.CoreV1().Pods(c.namespace).Patch(ctx, podName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("patch pod: %w", err)
+	}
+	return nil
+}
+
+// recordCollectionEvent creates a Normal CoverageCollected Event involving the pod
+func (c *CoverageClient) recordCollectionEvent(ctx context.Context, podName, testName string, method CollectionMethod, collectedAt time.Time) error {
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get pod details: %w", err)
+	}
+
+	message := fmt.Sprintf("Coverage collected for test %s", testName)
+	if method != "" {
+		message += fmt.Sprintf(" via %s", method)
+	}
+
+	timestamp := metav1.NewTime(collectedAt)
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: podName + ".",
+			Namespace:    c.namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:            "Pod",
+			APIVersion:      "v1",
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+		},
+		Reason:         EventReasonCoverageCollected,
+		Message:        message,
+		Type:           corev1.EventTypeNormal,
+		Source:         corev1.EventSource{Component: eventSourceComponent},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+	}
+
+	if _, err := c.clientset.CoreV1().Events(c.namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("create event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package coverageclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCollectCoverageWithFallback_RecordsEventAndAnnotation(t *testing.T) {
+	server := newCoverageTestServer(t)
+	defer server.Close()
+
+	pod, _, _ := newFallbackTestObjects()
+	clientset := fake.NewSimpleClientset(pod)
+	client := &CoverageClient{
+		clientset:  clientset,
+		namespace:  "default",
+		outputDir:  t.TempDir(),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	ctx := context.Background()
+	_, err := client.CollectCoverageWithFallback(ctx, "demo-pod", "events-test", 9095, FallbackOptions{
+		Methods:       []CollectionMethod{MethodIngress},
+		ContainerName: "app",
+		IngressURL:    server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	updated, _ := clientset.CoreV1().Pods("default").Get(ctx, "demo-pod", metav1.GetOptions{})
+	if _, err := time.Parse(time.RFC3339, updated.Annotations[AnnotationLastCollected]); err != nil {
+		t.Errorf("Expected RFC3339 %s annotation, got %v", AnnotationLastCollected, updated.Annotations)
+	}
+
+	events, _ := clientset.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+	if len(events.Items) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.Reason != EventReasonCoverageCollected || event.Type != corev1.EventTypeNormal || event.InvolvedObject.Name != "demo-pod" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.Message != "Coverage collected for test events-test via ingress" {
+		t.Errorf("Unexpected event message: %s", event.Message)
+	}
+}
+
+func TestRecordCollectionDisabled(t *testing.T) {
+	pod, _, _ := newFallbackTestObjects()
+	clientset := fake.NewSimpleClientset(pod)
+	client := &CoverageClient{clientset: clientset, namespace: "default"}
+	client.SetRecordCollection(false)
+
+	ctx := context.Background()
+	client.recordCollection(ctx, "demo-pod", "events-test", MethodPortForward)
+
+	updated, _ := clientset.CoreV1().Pods("default").Get(ctx, "demo-pod", metav1.GetOptions{})
+	if _, ok := updated.Annotations[AnnotationLastCollected]; ok {
+		t.Error("Expected no annotation when recording is disabled")
+	}
+	events, _ := clientset.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+	if len(events.Items) != 0 {
+		t.Errorf("Expected no events when recording is disabled, got %d", len(events.Items))
+	}
+}
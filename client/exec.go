@@ -0,0 +1,183 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ErrExecDisabled is returned by exec-based operations when exec has been disabled on the client
+var ErrExecDisabled = errors.New("exec into containers is disabled")
+
+// CommandExecutor runs commands in containers. The client execs through the API server unless
+// another implementation is set with SetCommandExecutor, e.g. a fake in unit tests.
+type CommandExecutor interface {
+	// Exec runs command in the container, writing its output to stdout and stderr
+	Exec(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error
+}
+
+// SetCommandExecutor replaces how the client runs commands in containers (default: exec through the
+// API server). SetExecEnabled(false) still disables exec.
+func (c *CoverageClient) SetCommandExecutor(executor CommandExecutor) {
+	c.executor = executor
+}
+
+// ExecForbiddenError is returned when the service account is not allowed to create pods/exec.
+// Callers can detect it with errors.As and fall back to port-forward-only collection.
+type ExecForbiddenError struct {
+	PodName   string
+	Container string
+	Err       error
+}
+
+func (e *ExecForbiddenError) Error() string {
+	return fmt.Sprintf("exec into pod %s (container %s) forbidden, pods/exec RBAC is required: %v", e.PodName, e.Container, e.Err)
+}
+
+func (e *ExecForbiddenError) Unwrap() error {
+	return e.Err
+}
+
+// SetExecEnabled controls whether the client may exec into containers. Exec is used to detect
+// the coverage container when no container declares the coverage port, and by MethodExec.
+// Disable it when the service account lacks pods/exec RBAC.
+func (c *CoverageClient) SetExecEnabled(enabled bool) {
+	c.disableExec = !enabled
+}
+
+// SetPortForwardOnly restricts the client to port-forwarding: exec is disabled and
+// CollectCoverageWithFallback only tries MethodPortForward. The only RBAC needed is
+// get/list on pods and create on pods/portforward.
+func (c *CoverageClient) SetPortForwardOnly(enabled bool) {
+	c.portForwardOnly = enabled
+	if enabled {
+		c.disableExec = true
+	}
+}
+
+// CanExec asks the API server whether the current identity may create pods/exec in the namespace
+func (c *CoverageClient) CanExec(ctx context.Context) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   c.namespace,
+				Verb:        "create",
+				Resource:    "pods",
+				Subresource: "exec",
+			},
+		},
+	}
+
+	result, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("check pods/exec permission: %w", err)
+	}
+	return result.Status.Allowed, nil
+}
+
+// execInContainer runs command in the given container with the client's CommandExecutor and
+// returns its stdout
+func (c *CoverageClient) execInContainer(ctx context.Context, podName, containerName string, command []string) (*bytes.Buffer, error) {
+	if c.disableExec {
+		return nil, ErrExecDisabled
+	}
+
+	executor := c.executor
+	if executor == nil {
+		executor = apiServerExecutor{client: c}
+	}
+	var stdout, stderr bytes.Buffer
+	if err := executor.Exec(ctx, c.namespace, podName, containerName, command, &stdout, &stderr); err != nil {
+		return nil, wrapExecError(podName, containerName, err, stderr.String())
+	}
+	return &stdout, nil
+}
+
+// execStream runs command in the given container with the client's CommandExecutor, piping its
+// stdout into consume while the command runs, so large outputs aren't held in memory. If consume
+// fails, the command is canceled.
+func (c *CoverageClient) execStream(ctx context.Context, podName, containerName string, command []string, consume func(io.Reader) error) error {
+	if c.disableExec {
+		return ErrExecDisabled
+	}
+
+	executor := c.executor
+	if executor == nil {
+		executor = apiServerExecutor{client: c}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stdout, stdoutWriter := io.Pipe()
+	var stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		err := executor.Exec(ctx, c.namespace, podName, containerName, command, stdoutWriter, &stderr)
+		stdoutWriter.CloseWithError(err)
+		done <- err
+	}()
+
+	consumeErr := consume(stdout)
+	if consumeErr != nil {
+		cancel()
+		stdout.CloseWithError(consumeErr)
+	} else {
+		// Let the command finish writing what consume left unread, e.g. archive padding
+		io.Copy(io.Discard, stdout)
+	}
+	execErr := <-done
+
+	switch {
+	case execErr != nil && (consumeErr == nil || stderr.Len() > 0 || errors.Is(consumeErr, execErr)):
+		return wrapExecError(podName, containerName, execErr, stderr.String())
+	case consumeErr != nil:
+		return consumeErr
+	}
+	return nil
+}
+
+// apiServerExecutor is the default CommandExecutor, running commands through the API server's
+// pods/exec subresource
+type apiServerExecutor struct {
+	client *CoverageClient
+}
+
+func (e apiServerExecutor) Exec(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error {
+	req := e.client.clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec").
+		Param("container", containerName).
+		Param("stdout", "true").
+		Param("stderr", "true")
+	for _, arg := range command {
+		req = req.Param("command", arg)
+	}
+
+	exec, err := e.client.createExecutor(req)
+	if err != nil {
+		return fmt.Errorf("create executor: %w", err)
+	}
+	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
+// wrapExecError turns RBAC denials into an ExecForbiddenError
+func wrapExecError(podName, containerName string, err error, stderr string) error {
+	if apierrors.IsForbidden(err) {
+		return &ExecForbiddenError{PodName: podName, Container: containerName, Err: err}
+	}
+	return fmt.Errorf("exec in container %s: %w (stderr: %s)", containerName, err, strings.TrimSpace(stderr))
+}
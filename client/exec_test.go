@@ -0,0 +1,116 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWrapExecError_Forbidden(t *testing.T) {
+	denied := apierrors.NewForbidden(schema.GroupResource{Resource: "pods/exec"}, "demo-pod", errors.New("no RBAC"))
+
+	err := wrapExecError("demo-pod", "app", denied, "")
+	var forbidden *ExecForbiddenError
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("Expected ExecForbiddenError, got %T: %v", err, err)
+	}
+	if forbidden.PodName != "demo-pod" || forbidden.Container != "app" {
+		t.Errorf("Unexpected error fields: %+v", forbidden)
+	}
+	if !apierrors.IsForbidden(err) {
+		t.Error("Expected wrapped error to still be recognized as forbidden")
+	}
+
+	err = wrapExecError("demo-pod", "app", errors.New("command terminated with exit code 1"), "not found")
+	if errors.As(err, &forbidden) {
+		t.Error("Expected non-RBAC failure not to be an ExecForbiddenError")
+	}
+}
+
+func TestExecDisabled(t *testing.T) {
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(), namespace: "default"}
+	client.SetExecEnabled(false)
+
+	if _, err := client.execInContainer(context.Background(), "demo-pod", "app", []string{"true"}); !errors.Is(err, ErrExecDisabled) {
+		t.Errorf("Expected ErrExecDisabled, got %v", err)
+	}
+
+}
+
+func TestSetPortForwardOnly(t *testing.T) {
+	client := &CoverageClient{}
+	client.SetPortForwardOnly(true)
+	if !client.portForwardOnly || !client.disableExec {
+		t.Error("Expected port-forward-only mode to disable exec")
+	}
+}
+
+func TestCanExec(t *testing.T) {
+	for _, allowed := range []bool{true, false} {
+		clientset := fake.NewSimpleClientset()
+		clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			attrs := review.Spec.ResourceAttributes
+			if attrs.Namespace != "default" || attrs.Resource != "pods" || attrs.Subresource != "exec" {
+				t.Errorf("Unexpected access review: %+v", attrs)
+			}
+			review.Status.Allowed = allowed
+			return true, review, nil
+		})
+
+		client := &CoverageClient{clientset: clientset, namespace: "default"}
+		got, err := client.CanExec(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != allowed {
+			t.Errorf("Expected CanExec=%v, got %v", allowed, got)
+		}
+	}
+}
+
+// fakeExecutor answers commands with canned output per container
+type fakeExecutor struct {
+	outputs  map[string]string // Stdout per container
+	err      error
+	ran      []string   // Containers the commands ran in
+	commands [][]string // Commands run, in the order of ran
+}
+
+func (e *fakeExecutor) Exec(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error {
+	e.ran = append(e.ran, containerName)
+	e.commands = append(e.commands, command)
+	if e.err != nil {
+		return e.err
+	}
+	io.WriteString(stdout, e.outputs[containerName])
+	return nil
+}
+
+func TestExecInContainer_FakeExecutor(t *testing.T) {
+	executor := &fakeExecutor{outputs: map[string]string{"sidecar": "covmeta.abc\n"}}
+	client := &CoverageClient{namespace: "default", quiet: true}
+	client.SetCommandExecutor(executor)
+
+	stdout, err := client.execInContainer(context.Background(), "demo-pod", "sidecar", []string{"ls"})
+	if err != nil || stdout.String() != "covmeta.abc\n" {
+		t.Errorf("Expected the sidecar's output, got %q (%v)", stdout, err)
+	}
+
+	// Denials are reported as ExecForbiddenError
+	executor = &fakeExecutor{err: apierrors.NewForbidden(schema.GroupResource{Resource: "pods/exec"}, "demo-pod", errors.New("no RBAC"))}
+	client.SetCommandExecutor(executor)
+	_, err = client.execInContainer(context.Background(), "demo-pod", "app", []string{"ls"})
+	var forbidden *ExecForbiddenError
+	if !errors.As(err, &forbidden) || len(executor.ran) != 1 {
+		t.Errorf("Expected an ExecForbiddenError, got %v after %v", err, executor.ran)
+	}
+}
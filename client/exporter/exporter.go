@@ -0,0 +1,127 @@
+// Package exporter writes coverage reports in further formats next to coverage.out. Formats are
+// looked up by name in a registry that holds the built-in ones (lcov, cobertura, json) and any
+// registered by the application, e.g. for an internal QA dashboard:
+//
+//	exporter.Register("qa-dashboard", dashboardExporter{})
+//	reports, err := exporter.ExportAll(client, "e2e", "lcov", "qa-dashboard")
+//
+// Exporters read the test's filtered profile (the unfiltered one when it wasn't filtered), so
+// ExportAll runs after ProcessCoverage.
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+// Exporter writes a coverage profile in one report format
+type Exporter interface {
+	// FileName is the name of the report in the test directory, e.g. "lcov.info"
+	FileName() string
+	// Export writes the report of testName's profile to w
+	Export(w io.Writer, testName string, profile *coverageclient.Profile) error
+}
+
+// Report is a report written by ExportAll
+type Report struct {
+	Format string // Registered name of the exporter
+	Path   string // Report file in the test directory
+}
+
+var (
+	mu        sync.RWMutex
+	exporters = make(map[string]Exporter)
+)
+
+// Register makes an exporter available to ExportAll under name, replacing a built-in or
+// earlier registered exporter of the same name
+func Register(name string, exporter Exporter) {
+	if exporter == nil {
+		panic("exporter: Register " + name + " with a nil Exporter")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	exporters[name] = exporter
+}
+
+// Lookup returns the exporter registered under name
+func Lookup(name string) (Exporter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	exporter, ok := exporters[name]
+	return exporter, ok
+}
+
+// Formats returns the names of the registered exporters, sorted
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	formats := make([]string, 0, len(exporters))
+	for name := range exporters {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ExportAll writes the reports of testName in the given formats (default: all registered) into
+// its test directory. It stops at the first failing format; reports written before it are
+// returned with the error, the failed one is removed.
+func ExportAll(client *coverageclient.CoverageClient, testName string, formats ...string) ([]Report, error) {
+	if len(formats) == 0 {
+		formats = Formats()
+	}
+	selected := make([]Exporter, len(formats))
+	for i, format := range formats {
+		exporter, ok := Lookup(format)
+		if !ok {
+			return nil, fmt.Errorf("unknown export format %q (registered: %v)", format, Formats())
+		}
+		selected[i] = exporter
+	}
+
+	profile, err := client.LoadProfile(testName)
+	if err != nil {
+		return nil, fmt.Errorf("load profile: %w", err)
+	}
+	testDir, err := client.TestDir(testName)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []Report
+	for i, exporter := range selected {
+		path := filepath.Join(testDir, exporter.FileName())
+		if slices.ContainsFunc(reports, func(r Report) bool { return r.Path == path }) {
+			return reports, fmt.Errorf("export %s: %s was already written by another format", formats[i], exporter.FileName())
+		}
+		if err := writeReport(path, testName, profile, exporter); err != nil {
+			return reports, fmt.Errorf("export %s: %w", formats[i], err)
+		}
+		reports = append(reports, Report{Format: formats[i], Path: path})
+	}
+	return reports, nil
+}
+
+// writeReport writes one report, removing the file when the exporter fails
+func writeReport(path, testName string, profile *coverageclient.Profile, exporter Exporter) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = exporter.Export(f, testName, profile)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
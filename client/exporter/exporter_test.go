@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+const testProfile = `mode: set
+example.com/app/main.go:5.13,7.2 2 1
+example.com/app/main.go:9.13,11.2 1 0
+example.com/app/auth/login.go:3.20,4.10 1 1
+`
+
+// newClient returns a local client whose output directory holds testProfile for the test "e2e"
+func newClient(t *testing.T) *coverageclient.CoverageClient {
+	outputDir := t.TempDir()
+	client, err := coverageclient.NewLocalClient(outputDir)
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %v", err)
+	}
+	client.SetQuiet(true)
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	return client
+}
+
+type statementsExporter struct{ err error }
+
+func (statementsExporter) FileName() string { return "statements.txt" }
+
+func (e statementsExporter) Export(w io.Writer, testName string, profile *coverageclient.Profile) error {
+	if e.err != nil {
+		io.WriteString(w, "partial")
+		return e.err
+	}
+	_, err := io.WriteString(w, testName+" "+profile.Mode)
+	return err
+}
+
+func TestRegister(t *testing.T) {
+	for _, format := range []string{"lcov", "cobertura", "json"} {
+		if _, ok := Lookup(format); !ok {
+			t.Errorf("Expected the built-in format %s to be registered", format)
+		}
+	}
+
+	Register("statements", statementsExporter{})
+	defer func() {
+		mu.Lock()
+		delete(exporters, "statements")
+		mu.Unlock()
+	}()
+	if !slices.Contains(Formats(), "statements") || !slices.IsSorted(Formats()) {
+		t.Errorf("Expected the registered format in the sorted formats, got %v", Formats())
+	}
+}
+
+func TestExportAll(t *testing.T) {
+	Register("statements", statementsExporter{})
+	defer func() {
+		mu.Lock()
+		delete(exporters, "statements")
+		mu.Unlock()
+	}()
+	client := newClient(t)
+
+	reports, err := ExportAll(client, "e2e", "lcov", "statements")
+	if err != nil {
+		t.Fatalf("ExportAll failed: %v", err)
+	}
+	if len(reports) != 2 || reports[0].Format != "lcov" || filepath.Base(reports[1].Path) != "statements.txt" {
+		t.Fatalf("Expected the lcov and statements reports, got %+v", reports)
+	}
+	if data, _ := os.ReadFile(reports[1].Path); string(data) != "e2e set" {
+		t.Errorf("Expected the custom report, got %q", data)
+	}
+
+	// All registered formats by default
+	reports, err = ExportAll(client, "e2e")
+	if err != nil {
+		t.Fatalf("ExportAll failed: %v", err)
+	}
+	if len(reports) != len(Formats()) {
+		t.Errorf("Expected a report per format, got %+v", reports)
+	}
+}
+
+func TestExportAll_Errors(t *testing.T) {
+	client := newClient(t)
+	if _, err := ExportAll(client, "e2e", "lcov", "sonar"); err == nil || !strings.Contains(err.Error(), `unknown export format "sonar"`) {
+		t.Errorf("Expected an unknown format error, got %v", err)
+	}
+	if _, err := ExportAll(client, "missing", "lcov"); err == nil {
+		t.Error("Expected an error without a profile")
+	}
+
+	failure := errors.New("dashboard unavailable")
+	Register("statements", statementsExporter{err: failure})
+	defer func() {
+		mu.Lock()
+		delete(exporters, "statements")
+		mu.Unlock()
+	}()
+	reports, err := ExportAll(client, "e2e", "json", "statements")
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the exporter's error, got %v", err)
+	}
+	if len(reports) != 1 || reports[0].Format != "json" {
+		t.Errorf("Expected the report written before the failure, got %+v", reports)
+	}
+	testDir, _ := client.TestDir("e2e")
+	if _, err := os.Stat(filepath.Join(testDir, "statements.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial report to be removed, got %v", err)
+	}
+
+	if _, err := ExportAll(client, "e2e", "json", "json"); err == nil || !strings.Contains(err.Error(), "already written") {
+		t.Errorf("Expected a duplicate file error, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package exporter
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+func init() {
+	Register("lcov", LCOV{})
+	Register("cobertura", Cobertura{})
+	Register("json", JSON{})
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[K string | int, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// packageDir returns the package of a profile file (its import path without the file name)
+func packageDir(file string) string {
+	return path.Dir(strings.ReplaceAll(file, `\`, "/"))
+}
+
+// LCOV writes lcov.info, the tracefile format of genhtml and most IDE coverage plugins
+type LCOV struct{}
+
+func (LCOV) FileName() string { return "lcov.info" }
+
+func (LCOV) Export(w io.Writer, testName string, profile *coverageclient.Profile) error {
+	return profile.WriteLCOV(w, testName)
+}
+
+// coberturaCoverage is the root element of a Cobertura XML report
+type coberturaCoverage struct {
+	XMLName      xml.Name           `xml:"coverage"`
+	LineRate     float64            `xml:"line-rate,attr"`
+	BranchRate   float64            `xml:"branch-rate,attr"`
+	LinesCovered int                `xml:"lines-covered,attr"`
+	LinesValid   int                `xml:"lines-valid,attr"`
+	Version      string             `xml:"version,attr"`
+	Timestamp    int64              `xml:"timestamp,attr"`
+	Sources      []string           `xml:"sources>source"`
+	Packages     []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	FileName   string          `xml:"filename,attr"`
+	LineRate   float64         `xml:"line-rate,attr"`
+	BranchRate float64         `xml:"branch-rate,attr"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// lineRate returns the share of covered lines (0 when there are none)
+func lineRate(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+	return float64(covered) / float64(valid)
+}
+
+// Cobertura writes cobertura.xml, read by GitLab merge requests, Jenkins and Azure DevOps. Packages
+// are the directories of the profile files, classes the files themselves.
+type Cobertura struct{}
+
+func (Cobertura) FileName() string { return "cobertura.xml" }
+
+func (Cobertura) Export(w io.Writer, testName string, profile *coverageclient.Profile) error {
+	report := coberturaCoverage{Version: "go-coverage-http", Timestamp: time.Now().UnixMilli(), Sources: []string{"."}}
+	files := profile.LineCounts()
+	packages := make(map[string][]string)
+	for file := range files {
+		packages[packageDir(file)] = append(packages[packageDir(file)], file)
+	}
+	for _, name := range sortedKeys(packages) {
+		pkg := coberturaPackage{Name: name}
+		pkgCovered, pkgValid := 0, 0
+		sort.Strings(packages[name])
+		for _, file := range packages[name] {
+			class := coberturaClass{Name: path.Base(file), FileName: file}
+			covered := 0
+			for _, line := range sortedKeys(files[file]) {
+				hits := files[file][line]
+				class.Lines = append(class.Lines, coberturaLine{Number: line, Hits: hits})
+				if hits > 0 {
+					covered++
+				}
+			}
+			class.LineRate = lineRate(covered, len(class.Lines))
+			pkg.Classes = append(pkg.Classes, class)
+			pkgCovered += covered
+			pkgValid += len(class.Lines)
+		}
+		pkg.LineRate = lineRate(pkgCovered, pkgValid)
+		report.Packages = append(report.Packages, pkg)
+		report.LinesCovered += pkgCovered
+		report.LinesValid += pkgValid
+	}
+	report.LineRate = lineRate(report.LinesCovered, report.LinesValid)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("encode cobertura report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// jsonReport is the document written by JSON
+type jsonReport struct {
+	TestName string                       `json:"testName"`
+	Mode     string                       `json:"mode"`
+	Total    coverageclient.CoverageStats `json:"total"`
+	Percent  float64                      `json:"percent"`
+	Packages []jsonPackage                `json:"packages"`
+}
+
+type jsonPackage struct {
+	Name    string                       `json:"name"`
+	Total   coverageclient.CoverageStats `json:"total"`
+	Percent float64                      `json:"percent"`
+	Files   []jsonFile                   `json:"files"`
+}
+
+type jsonFile struct {
+	Name    string                       `json:"name"`
+	Total   coverageclient.CoverageStats `json:"total"`
+	Percent float64                      `json:"percent"`
+}
+
+// JSON writes coverage.json with the statement coverage of the test, its packages and files
+type JSON struct{}
+
+func (JSON) FileName() string { return "coverage.json" }
+
+func (JSON) Export(w io.Writer, testName string, profile *coverageclient.Profile) error {
+	total := profile.Total()
+	report := jsonReport{TestName: testName, Mode: profile.Mode, Total: total, Percent: total.Percent(), Packages: []jsonPackage{}}
+	files := profile.Files()
+	packages := make(map[string]*jsonPackage)
+	for _, file := range sortedKeys(files) {
+		name := packageDir(file)
+		pkg := packages[name]
+		if pkg == nil {
+			pkg = &jsonPackage{Name: name}
+			packages[name] = pkg
+		}
+		stats := files[file]
+		pkg.Files = append(pkg.Files, jsonFile{Name: file, Total: stats, Percent: stats.Percent()})
+		pkg.Total.Statements += stats.Statements
+		pkg.Total.Covered += stats.Covered
+	}
+	for _, name := range sortedKeys(packages) {
+		pkg := packages[name]
+		pkg.Percent = pkg.Total.Percent()
+		report.Packages = append(report.Packages, *pkg)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+func parseTestProfile(t *testing.T) *coverageclient.Profile {
+	t.Helper()
+	profile, err := coverageclient.ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatalf("ParseProfile failed: %v", err)
+	}
+	return profile
+}
+
+func TestLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (LCOV{}).Export(&buf, "e2e", parseTestProfile(t)); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	want := `TN:e2e
+SF:example.com/app/auth/login.go
+DA:3,1
+DA:4,1
+LF:2
+LH:2
+end_of_record
+TN:e2e
+SF:example.com/app/main.go
+DA:5,1
+DA:6,1
+DA:7,1
+DA:9,0
+DA:10,0
+DA:11,0
+LF:6
+LH:3
+end_of_record
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected lcov report:\n%s", buf.String())
+	}
+}
+
+func TestCobertura(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (Cobertura{}).Export(&buf, "e2e", parseTestProfile(t)); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("Expected the XML header, got %q", buf.String())
+	}
+	var report coberturaCoverage
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+	if report.LinesValid != 8 || report.LinesCovered != 5 || report.LineRate != 5.0/8 {
+		t.Errorf("Unexpected totals: %+v", report)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Name != "example.com/app" || report.Packages[1].Name != "example.com/app/auth" {
+		t.Fatalf("Expected a package per directory, got %+v", report.Packages)
+	}
+	class := report.Packages[0].Classes[0]
+	if class.Name != "main.go" || class.FileName != "example.com/app/main.go" || len(class.Lines) != 6 || class.LineRate != 0.5 {
+		t.Errorf("Unexpected class: %+v", class)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSON{}).Export(&buf, "e2e", parseTestProfile(t)); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	var report jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if report.TestName != "e2e" || report.Mode != "set" || report.Total != (coverageclient.CoverageStats{Statements: 4, Covered: 3}) || report.Percent != 75 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Name != "example.com/app" || len(report.Packages[0].Files) != 1 {
+		t.Fatalf("Expected a package per directory, got %+v", report.Packages)
+	}
+	if pkg := report.Packages[0]; pkg.Total.Covered != 2 || pkg.Files[0].Name != "example.com/app/main.go" {
+		t.Errorf("Unexpected package: %+v", pkg)
+	}
+}
@@ -0,0 +1,366 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// CollectionMethod identifies the transport used to reach the coverage server
+type CollectionMethod string
+
+const (
+	// MethodPortForward tunnels to the pod through the API server (default)
+	MethodPortForward CollectionMethod = "port-forward"
+	// MethodExec runs wget/curl inside the container against localhost
+	MethodExec CollectionMethod = "exec"
+	// MethodService calls the coverage port through the cluster Service DNS name (in-cluster runners)
+	MethodService CollectionMethod = "service"
+	// MethodIngress calls the coverage endpoint through an Ingress exposing the Service
+	MethodIngress CollectionMethod = "ingress"
+	// MethodRoute calls the coverage endpoint through an OpenShift Route exposing the Service
+	MethodRoute CollectionMethod = "route"
+	// MethodCoverDir copies GOCOVERDIR out of the container via exec, picking up every
+	// instrumented process of the pod rather than the one serving the coverage port
+	MethodCoverDir CollectionMethod = "coverdir"
+	// MethodHelperPod calls the pod IP from a short-lived helper pod and reads the result from its logs
+	MethodHelperPod CollectionMethod = "helper-pod"
+	// MethodProxy calls the coverage port through the API server's pod proxy, a plain HTTPS request
+	// where the upgraded connections of port-forwarding are blocked
+	MethodProxy CollectionMethod = "proxy"
+)
+
+// DefaultFallbackMethods is the order in which collection methods are tried by CollectCoverageWithFallback
+var DefaultFallbackMethods = []CollectionMethod{MethodPortForward, MethodExec, MethodService, MethodIngress, MethodRoute}
+
+// FallbackOptions configures the fallback chain used by CollectCoverageWithFallback
+type FallbackOptions struct {
+	Methods       []CollectionMethod `json:"methods,omitempty"`       // Methods to try in order (default: DefaultFallbackMethods)
+	ContainerName string             `json:"containerName,omitempty"` // Container to exec into / record in metadata (default: auto-detect)
+	ServiceName   string             `json:"serviceName,omitempty"`   // Service for MethodService/MethodIngress/MethodRoute (default: discovered from pod labels)
+	IngressURL    string             `json:"ingressURL,omitempty"`    // Base URL for MethodIngress (default: discovered from Ingress rules)
+	RouteURL      string             `json:"routeURL,omitempty"`      // Base URL for MethodRoute (default: discovered from OpenShift Routes)
+	HelperPod     HelperPodOptions   `json:"helperPod,omitempty"`     // Helper pod settings for MethodHelperPod
+}
+
+// CollectCoverageWithFallback collects coverage from a pod trying each configured collection
+// method in order until one succeeds. The method that succeeded is recorded in metadata.json,
+// so restrictive environments (no port-forward RBAC, NetworkPolicies, no exec) still get coverage.
+// In port-forward-only mode (SetPortForwardOnly) opts.Methods is ignored and only port-forwarding is tried.
+func (c *CoverageClient) CollectCoverageWithFallback(ctx context.Context, podName, testName string, targetPort int, opts FallbackOptions) (CollectionMethod, error) {
+	var method CollectionMethod
+	_, err := c.collectWithEvents(ctx, testName, podName, 1, func(ctx context.Context) (*CollectResult, error) {
+		var err error
+		if method, err = c.collectWithFallback(ctx, podName, testName, targetPort, opts); err != nil {
+			return nil, err
+		}
+		return c.collectResult(testName, method), nil
+	})
+	return method, err
+}
+
+// collectWithFallback collects like CollectCoverageWithFallback, without events
+func (c *CoverageClient) collectWithFallback(ctx context.Context, podName, testName string, targetPort int, opts FallbackOptions) (CollectionMethod, error) {
+	methods := opts.Methods
+	if len(methods) == 0 {
+		methods = DefaultFallbackMethods
+	}
+	if c.portForwardOnly {
+		methods = []CollectionMethod{MethodPortForward}
+	}
+
+	c.logf("📊 Collecting coverage from pod %s for test: %s (methods: %v)\n", podName, testName, methods)
+	ctx = withServerInfoSlot(ctx)
+
+	var errs []error
+	for _, method := range methods {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		err := c.collectWithMethod(ctx, method, podName, testName, targetPort, opts)
+		if err != nil {
+			c.logf("  ⚠️  Collection via %s failed: %v\n", method, err)
+			errs = append(errs, fmt.Errorf("%s: %w", method, err))
+			continue
+		}
+
+		if err := c.savePodMetadata(ctx, podName, opts.ContainerName, testName, targetPort, method); err != nil {
+			c.logf("⚠️  Failed to save pod metadata: %v\n", err)
+		}
+		c.recordCollection(ctx, podName, testName, method)
+
+		c.logf("✅ Coverage collected successfully via %s for test: %s\n", method, testName)
+		return method, nil
+	}
+
+	err := c.diagnoseMeshFailure(ctx, podName, targetPort, errors.Join(errs...))
+	var meshErr *MeshBlockedError
+	if errors.As(err, &meshErr) {
+		c.logf("  ⚠️  %s has an istio-proxy sidecar intercepting port %d, see error for remediation\n", podName, targetPort)
+	}
+	return "", fmt.Errorf("all collection methods failed: %w", err)
+}
+
+// collectWithMethod collects coverage using a single collection method
+func (c *CoverageClient) collectWithMethod(ctx context.Context, method CollectionMethod, podName, testName string, targetPort int, opts FallbackOptions) error {
+	switch method {
+	case MethodPortForward:
+		return c.collectViaPortForward(ctx, podName, testName, targetPort)
+
+	case MethodExec:
+		return c.collectCoverageViaExec(ctx, podName, opts.ContainerName, testName, targetPort)
+
+	case MethodCoverDir:
+		return c.collectCoverageViaCoverDir(ctx, podName, opts.ContainerName, testName, targetPort)
+
+	case MethodHelperPod:
+		return c.collectCoverageViaHelperPod(ctx, podName, testName, targetPort, opts.HelperPod)
+
+	case MethodProxy:
+		return c.collectCoverageViaProxy(ctx, podName, testName, targetPort)
+
+	case MethodService:
+		serviceName, err := c.serviceNameForPod(ctx, podName, opts)
+		if err != nil {
+			return err
+		}
+		if err := c.checkPodSpecific(ctx, serviceName, podName); err != nil {
+			return err
+		}
+		port, err := c.servicePortFor(ctx, serviceName, podName, targetPort)
+		if err != nil {
+			return err
+		}
+		if c.meshHTTPClient != nil {
+			// The sidecar terminates mTLS and forwards plain HTTP to the app
+			coverageURL := fmt.Sprintf("https://%s.%s.svc:%d/coverage", serviceName, c.namespace, port.Port)
+			return c.collectCoverageFromURLWithClient(ctx, c.meshHTTPClient, coverageURL, testName)
+		}
+		coverageURL := fmt.Sprintf("http://%s.%s.svc:%d/coverage", serviceName, c.namespace, port.Port)
+		return c.collectCoverageFromURL(ctx, coverageURL, testName)
+
+	case MethodIngress:
+		serviceName, err := c.serviceNameForPod(ctx, podName, opts)
+		if err == nil {
+			if err := c.checkPodSpecific(ctx, serviceName, podName); err != nil {
+				return err
+			}
+		}
+		baseURL := opts.IngressURL
+		if baseURL == "" {
+			if err != nil {
+				return err
+			}
+			port, err := c.servicePortFor(ctx, serviceName, podName, targetPort)
+			if err != nil {
+				return err
+			}
+			if baseURL, err = c.findIngressURL(ctx, serviceName, port); err != nil {
+				return err
+			}
+		}
+		return c.collectCoverageFromURL(ctx, strings.TrimSuffix(baseURL, "/")+"/coverage", testName)
+
+	case MethodRoute:
+		serviceName, err := c.serviceNameForPod(ctx, podName, opts)
+		if err == nil {
+			if err := c.checkPodSpecific(ctx, serviceName, podName); err != nil {
+				return err
+			}
+		}
+		baseURL := opts.RouteURL
+		if baseURL == "" {
+			if err != nil {
+				return err
+			}
+			if baseURL, err = c.FindRouteURL(ctx, serviceName, targetPort); err != nil {
+				return err
+			}
+		}
+		return c.collectCoverageFromURL(ctx, strings.TrimSuffix(baseURL, "/")+"/coverage", testName)
+
+	default:
+		return fmt.Errorf("unknown collection method: %s", method)
+	}
+}
+
+// collectCoverageViaExec fetches coverage by running wget or curl inside the container
+func (c *CoverageClient) collectCoverageViaExec(ctx context.Context, podName, containerName, testName string, targetPort int) error {
+	if containerName == "" {
+		pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("get pod details: %w", err)
+		}
+		containerName = containerForPort(pod.Spec.Containers, targetPort)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{"test_name": testName})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	coverageURL := fmt.Sprintf("http://127.0.0.1:%d/coverage", targetPort)
+	if counterReset(ctx) {
+		coverageURL += "?" + resetParam + "=true"
+	}
+	// The body holds the test name, so it's quoted for the shell
+	body := shellQuote(string(reqBody))
+	script := fmt.Sprintf("wget -qO- --header='Content-Type: application/json' --post-data=%s %s 2>/dev/null || curl -sf -X POST -H 'Content-Type: application/json' -d %s %s",
+		body, coverageURL, body, coverageURL)
+
+	return c.execStream(ctx, podName, containerName, []string{"sh", "-c", script}, func(stdout io.Reader) error {
+		return c.saveCoverageResponse(stdout, testName)
+	})
+}
+
+// containerForPort returns the container declaring targetPort, or the first container
+func containerForPort(containers []corev1.Container, targetPort int) string {
+	for _, container := range containers {
+		for _, port := range container.Ports {
+			if int(port.ContainerPort) == targetPort {
+				return container.Name
+			}
+		}
+	}
+	if len(containers) > 0 {
+		return containers[0].Name
+	}
+	return ""
+}
+
+// serviceNameForPod returns the configured service name or discovers one selecting the pod
+func (c *CoverageClient) serviceNameForPod(ctx context.Context, podName string, opts FallbackOptions) (string, error) {
+	if opts.ServiceName != "" {
+		return opts.ServiceName, nil
+	}
+	return c.findServiceForPod(ctx, podName)
+}
+
+// findServiceForPod returns the first Service whose selector matches the pod's labels
+func (c *CoverageClient) findServiceForPod(ctx context.Context, podName string) (string, error) {
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("get pod details: %w", err)
+	}
+
+	services, err := c.clientset.CoreV1().Services(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("list services: %w", err)
+	}
+
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			return svc.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no service found selecting pod '%s' in namespace '%s'", podName, c.namespace)
+}
+
+// checkPodSpecific fails when the Service also balances over other ready pods than podName. The
+// response of a Service, Ingress or Route could then come from any of them and would be saved
+// under podName.
+func (c *CoverageClient) checkPodSpecific(ctx context.Context, serviceName, podName string) error {
+	endpoints, err := c.serviceEndpoints(ctx, serviceName)
+	if errors.Is(err, ErrPodNotRunning) {
+		// No ready endpoints listed, so no other pod can answer
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	delete(endpoints, podName)
+	if len(endpoints) > 0 {
+		others := slices.Sorted(maps.Keys(endpoints))
+		return fmt.Errorf("service %s also routes to ready pods %s, so the response may not come from pod %s", serviceName, strings.Join(others, ", "), podName)
+	}
+	return nil
+}
+
+// servicePortFor returns the port of the Service that forwards to targetPort of the pod, given by
+// number or by the name of the container port. Services declaring no such port, e.g. headless
+// ones, are reached on targetPort itself.
+func (c *CoverageClient) servicePortFor(ctx context.Context, serviceName, podName string, targetPort int) (corev1.ServicePort, error) {
+	svc, err := c.clientset.CoreV1().Services(c.namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return corev1.ServicePort{}, fmt.Errorf("get service %s: %w", serviceName, err)
+	}
+
+	var portNames []string
+	if pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{}); err == nil {
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if int(port.ContainerPort) == targetPort && port.Name != "" {
+					portNames = append(portNames, port.Name)
+				}
+			}
+		}
+	}
+
+	for _, port := range svc.Spec.Ports {
+		switch {
+		case port.TargetPort.Type == intstr.String:
+			if slices.Contains(portNames, port.TargetPort.StrVal) {
+				return port, nil
+			}
+		case port.TargetPort.IntVal == 0:
+			// An unset targetPort is the Service port
+			if int(port.Port) == targetPort {
+				return port, nil
+			}
+		case int(port.TargetPort.IntVal) == targetPort:
+			return port, nil
+		}
+	}
+	return corev1.ServicePort{Port: int32(targetPort)}, nil
+}
+
+// findIngressURL returns the base URL of an Ingress rule routing to the given Service port
+func (c *CoverageClient) findIngressURL(ctx context.Context, serviceName string, port corev1.ServicePort) (string, error) {
+	ingresses, err := c.clientset.NetworkingV1().Ingresses(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("list ingresses: %w", err)
+	}
+
+	for _, ing := range ingresses.Items {
+		scheme := "http"
+		if len(ing.Spec.TLS) > 0 {
+			scheme = "https"
+		}
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil || rule.Host == "" {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				backend := path.Backend.Service
+				if backend == nil || backend.Name != serviceName {
+					continue
+				}
+				if backend.Port.Name != "" && backend.Port.Name != port.Name {
+					continue
+				}
+				if backend.Port.Number != 0 && backend.Port.Number != port.Port {
+					continue
+				}
+				return fmt.Sprintf("%s://%s%s", scheme, rule.Host, strings.TrimSuffix(path.Path, "/")), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no ingress found routing to service '%s' port %d", serviceName, port.Port)
+}
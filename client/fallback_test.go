@@ -0,0 +1,257 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newCoverageTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CoverageResponse{
+			MetaFilename:     "covmeta.test",
+			MetaData:         base64.StdEncoding.EncodeToString([]byte("meta")),
+			CountersFilename: "covcounters.test",
+			CountersData:     base64.StdEncoding.EncodeToString([]byte("counters")),
+			Timestamp:        time.Now().UnixNano(),
+		})
+	}))
+}
+
+func newFallbackTestObjects() (*corev1.Pod, *corev1.Service, *networkingv1.Ingress) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo-pod",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "demo"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "app:v1"}},
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-svc", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo"}},
+	}
+	pathType := networkingv1.PathTypePrefix
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-ing", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{{Hosts: []string{"demo.example.com"}}},
+			Rules: []networkingv1.IngressRule{{
+				Host: "demo.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/cov/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: "demo-svc",
+							Port: networkingv1.ServiceBackendPort{Number: 9095},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	return pod, svc, ing
+}
+
+func TestFindServiceAndIngressForPod(t *testing.T) {
+	pod, svc, ing := newFallbackTestObjects()
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(pod, svc, ing), namespace: "default"}
+	ctx := context.Background()
+
+	serviceName, err := client.findServiceForPod(ctx, "demo-pod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if serviceName != "demo-svc" {
+		t.Errorf("Expected service demo-svc, got %s", serviceName)
+	}
+
+	ingressURL, err := client.findIngressURL(ctx, serviceName, corev1.ServicePort{Port: 9095})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ingressURL != "https://demo.example.com/cov" {
+		t.Errorf("Unexpected ingress URL: %s", ingressURL)
+	}
+
+	if _, err := client.findIngressURL(ctx, serviceName, corev1.ServicePort{Port: 8000}); err == nil {
+		t.Error("Expected error for port without ingress rule")
+	}
+}
+
+func TestServicePortFor_MapsTargetPort(t *testing.T) {
+	pod, svc, ing := newFallbackTestObjects()
+	pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}, {Name: "coverage", ContainerPort: 9095}}
+	svc.Spec.Ports = []corev1.ServicePort{
+		{Name: "web", Port: 80, TargetPort: intstr.FromInt32(8080)},
+		{Name: "cov", Port: 9000, TargetPort: intstr.FromString("coverage")},
+	}
+	ing.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port = networkingv1.ServiceBackendPort{Name: "cov"}
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(pod, svc, ing), namespace: "default"}
+	ctx := context.Background()
+
+	for targetPort, want := range map[int]int32{8080: 80, 9095: 9000, 7000: 7000} {
+		port, err := client.servicePortFor(ctx, "demo-svc", "demo-pod", targetPort)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if port.Port != want {
+			t.Errorf("Expected service port %d for target port %d, got %d", want, targetPort, port.Port)
+		}
+	}
+
+	port, _ := client.servicePortFor(ctx, "demo-svc", "demo-pod", 9095)
+	if ingressURL, err := client.findIngressURL(ctx, "demo-svc", port); err != nil || ingressURL != "https://demo.example.com/cov" {
+		t.Errorf("Expected the ingress routing to the named service port, got %q, %v", ingressURL, err)
+	}
+	port, _ = client.servicePortFor(ctx, "demo-svc", "demo-pod", 8080)
+	if _, err := client.findIngressURL(ctx, "demo-svc", port); err == nil {
+		t.Error("Expected error for a service port without ingress rule")
+	}
+}
+
+func TestCollectCoverageWithFallback_RecordsMethod(t *testing.T) {
+	server := newCoverageTestServer(t)
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	pod, _, _ := newFallbackTestObjects()
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(pod),
+		namespace:  "default",
+		outputDir:  tempDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	method, err := client.CollectCoverageWithFallback(context.Background(), "demo-pod", "fallback-test", 9095, FallbackOptions{
+		// The service has no DNS entry outside a cluster, so the chain must fall through to ingress
+		Methods:       []CollectionMethod{MethodService, MethodIngress},
+		ContainerName: "app",
+		ServiceName:   "does-not-resolve.invalid",
+		IngressURL:    server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if method != MethodIngress {
+		t.Errorf("Expected method %s, got %s", MethodIngress, method)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "fallback-test", "metadata.json"))
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	var metadata PodMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatalf("Failed to parse metadata: %v", err)
+	}
+	if metadata.CollectionMethod != MethodIngress {
+		t.Errorf("Expected collection_method %s in metadata, got %s", MethodIngress, metadata.CollectionMethod)
+	}
+}
+
+func TestCollectCoverageWithFallback_AllFail(t *testing.T) {
+	pod, _, _ := newFallbackTestObjects()
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(pod),
+		namespace:  "default",
+		outputDir:  t.TempDir(),
+		httpClient: &http.Client{Timeout: 2 * time.Second},
+	}
+
+	_, err := client.CollectCoverageWithFallback(context.Background(), "demo-pod", "fallback-test", 9095, FallbackOptions{
+		Methods: []CollectionMethod{MethodIngress, "carrier-pigeon"},
+	})
+	if err == nil {
+		t.Fatal("Expected error when all methods fail")
+	}
+}
+
+func TestCollectCoverageWithFallback_SkipsSharedService(t *testing.T) {
+	server := newCoverageTestServer(t)
+	defer server.Close()
+
+	pod, svc, _ := newFallbackTestObjects()
+	client := &CoverageClient{
+		clientset: fake.NewSimpleClientset(pod, svc,
+			endpointSlice("demo-svc-abc", "demo-svc", podEndpoint("demo-pod", "10.0.0.1", true), podEndpoint("demo-other", "10.0.0.2", true))),
+		namespace:  "default",
+		outputDir:  t.TempDir(),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	client.SetRecordCollection(false)
+	opts := FallbackOptions{Methods: []CollectionMethod{MethodIngress}, ContainerName: "app", IngressURL: server.URL}
+
+	// The ingress could answer from demo-other, whose coverage must not be saved as demo-pod's
+	_, err := client.CollectCoverageWithFallback(context.Background(), "demo-pod", "shared-test", 9095, opts)
+	if err == nil || !strings.Contains(err.Error(), "demo-other") {
+		t.Fatalf("Expected the ingress to be skipped for a service routing to demo-other, got %v", err)
+	}
+
+	client.clientset = fake.NewSimpleClientset(pod, svc,
+		endpointSlice("demo-svc-abc", "demo-svc", podEndpoint("demo-pod", "10.0.0.1", true), podEndpoint("demo-other", "10.0.0.2", false)))
+	if _, err := client.CollectCoverageWithFallback(context.Background(), "demo-pod", "shared-test", 9095, opts); err != nil {
+		t.Errorf("Expected the ingress to be used while demo-pod is the only ready pod, got %v", err)
+	}
+}
+
+// shellExecutor runs exec commands with the local sh, after a prelude replacing wget with a
+// function that records its arguments and answers with covdata
+type shellExecutor struct {
+	argsFile string
+}
+
+func (e *shellExecutor) Exec(ctx context.Context, namespace, podName, containerName string, command []string, stdout, stderr io.Writer) error {
+	prelude := fmt.Sprintf(`wget() { printf '%%s\n' "$@" > %s; printf '%%s' %s; }; `, shellQuote(e.argsFile), shellQuote(covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1")))
+	cmd := exec.CommandContext(ctx, command[0], command[1], prelude+command[2])
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return cmd.Run()
+}
+
+func TestCollectCoverageViaExec_QuotesTestName(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	pod, _, _ := newFallbackTestObjects()
+	executor := &shellExecutor{argsFile: filepath.Join(t.TempDir(), "args")}
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(pod), namespace: "default", outputDir: t.TempDir(), quiet: true}
+	client.SetCommandExecutor(executor)
+
+	testName := `it's'; touch pwned; echo '`
+	if err := client.collectCoverageViaExec(context.Background(), "demo-pod", "app", testName, 9095); err != nil {
+		t.Fatalf("collectCoverageViaExec failed: %v", err)
+	}
+	args, err := os.ReadFile(executor.argsFile)
+	if err != nil {
+		t.Fatalf("Expected wget to run: %v", err)
+	}
+	body, _ := json.Marshal(map[string]string{"test_name": testName})
+	if !strings.Contains(string(args), "--post-data="+string(body)+"\n") {
+		t.Errorf("Expected the body to reach wget as one argument, got:\n%s", args)
+	}
+	if _, err := os.Stat("pwned"); err == nil {
+		os.Remove("pwned")
+		t.Error("Expected the test name not to run commands")
+	}
+}
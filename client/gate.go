@@ -0,0 +1,140 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Exit codes of coverage gates, see GateExitCode
+const (
+	GateExitPassed = 0 // All rules passed
+	GateExitFailed = 1 // At least one rule failed
+	GateExitError  = 2 // The gate could not be evaluated, e.g. the coverage report is missing
+)
+
+// GateStatus is the outcome of a gate rule
+type GateStatus string
+
+const (
+	GatePassed  GateStatus = "passed"
+	GateFailed  GateStatus = "failed"
+	GateSkipped GateStatus = "skipped" // The rule needs a baseline or base ref the report doesn't have
+)
+
+// GateConfig configures Gate
+type GateConfig struct {
+	CoverageReportOptions
+	Thresholds    CoverageThresholds // Rules to evaluate; zero values disable a rule
+	FailOnSkipped bool               // Fail rules that can't be evaluated instead of skipping them
+}
+
+// GateRule is the outcome of one threshold rule
+type GateRule struct {
+	Name    string     `json:"name"`              // e.g. "total coverage >= 70.0%"
+	Status  GateStatus `json:"status"`            // passed, failed or skipped
+	Message string     `json:"message,omitempty"` // Why the rule failed or was skipped
+}
+
+// GateResult is the decision of a coverage gate
+type GateResult struct {
+	Passed bool            `json:"passed"`
+	Rules  []GateRule      `json:"rules"`
+	Report *CoverageReport `json:"-"` // The evaluated report
+}
+
+// Failures returns the messages of the failed rules
+func (r *GateResult) Failures() []string {
+	var failures []string
+	for _, rule := range r.Rules {
+		if rule.Status == GateFailed {
+			failures = append(failures, rule.Message)
+		}
+	}
+	return failures
+}
+
+// SetThresholds configures the rules Gate evaluates when the GateConfig sets none (default: none)
+func (c *CoverageClient) SetThresholds(thresholds CoverageThresholds) {
+	c.thresholds = thresholds
+}
+
+// Gate evaluates the thresholds (total, patch, regression vs. baseline and per-package coverage)
+// against a processed test, prints the decision and returns it, e.g. for
+// Expect(gate.Passed).To(BeTrue()). An error means the gate could not be evaluated. Without
+// thresholds in cfg, the client's (see SetThresholds) apply.
+func (c *CoverageClient) Gate(ctx context.Context, cfg GateConfig) (*GateResult, error) {
+	report, err := c.BuildCoverageReport(ctx, cfg.CoverageReportOptions)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Thresholds == (CoverageThresholds{}) {
+		cfg.Thresholds = c.thresholds
+	}
+
+	result := &GateResult{Passed: true, Report: report, Rules: []GateRule{}}
+	for _, r := range cfg.Thresholds.results(report) {
+		rule := GateRule{Name: r.name, Status: GatePassed}
+		switch {
+		case r.failure != "":
+			rule.Status, rule.Message = GateFailed, r.failure
+		case r.skipped != "" && cfg.FailOnSkipped:
+			rule.Status, rule.Message = GateFailed, r.name+" not evaluated: "+r.skipped
+		case r.skipped != "":
+			rule.Status, rule.Message = GateSkipped, r.skipped
+		}
+		result.Rules = append(result.Rules, rule)
+	}
+	if cfg.Thresholds.MinPackage > 0 {
+		for _, pkg := range report.Packages {
+			rule := GateRule{Name: fmt.Sprintf("package %s coverage >= %.1f%%", pkg.Package, cfg.Thresholds.MinPackage), Status: GatePassed}
+			if failure := cfg.Thresholds.packageFailure(pkg); failure != "" {
+				rule.Status, rule.Message = GateFailed, failure
+			}
+			result.Rules = append(result.Rules, rule)
+		}
+	}
+	for _, rule := range result.Rules {
+		if rule.Status == GateFailed {
+			result.Passed = false
+		}
+	}
+
+	c.logf("%s", result.Summary())
+	return result, nil
+}
+
+// GateExitCode maps the return values of Gate to a process exit code (GateExitPassed,
+// GateExitFailed or GateExitError): os.Exit(GateExitCode(client.Gate(ctx, cfg))). Errors aren't
+// reported, callers print them where their output goes.
+func GateExitCode(result *GateResult, err error) int {
+	switch {
+	case err != nil:
+		return GateExitError
+	case result.Passed:
+		return GateExitPassed
+	default:
+		return GateExitFailed
+	}
+}
+
+// Summary returns the gate decision and the outcome of each rule, one per line
+func (r *GateResult) Summary() string {
+	decision := "PASSED ✅"
+	if !r.Passed {
+		decision = "FAILED ❌"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚦 Coverage gate for %s: %s (total %s)\n", r.Report.TestName, decision, formatStats(r.Report.Total))
+	for _, rule := range r.Rules {
+		switch rule.Status {
+		case GatePassed:
+			fmt.Fprintf(&b, "   ✅ %s\n", rule.Name)
+		case GateFailed:
+			fmt.Fprintf(&b, "   ❌ %s: %s\n", rule.Name, rule.Message)
+		case GateSkipped:
+			fmt.Fprintf(&b, "   ⏭️  %s (skipped: %s)\n", rule.Name, rule.Message)
+		}
+	}
+	return b.String()
+}
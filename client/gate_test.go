@@ -0,0 +1,62 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGate(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	client := &CoverageClient{outputDir: outputDir}
+
+	cfg := GateConfig{
+		CoverageReportOptions: CoverageReportOptions{TestName: "e2e"},
+		Thresholds:            CoverageThresholds{MinTotal: 60, MaxDecrease: 1},
+	}
+	gate, err := client.Gate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Gate failed: %v", err)
+	}
+	if !gate.Passed || GateExitCode(gate, nil) != GateExitPassed {
+		t.Errorf("Expected gate to pass, got %+v", gate.Rules)
+	}
+	if len(gate.Rules) != 2 || gate.Rules[0].Status != GatePassed || gate.Rules[1].Status != GateSkipped {
+		t.Errorf("Unexpected rules: %+v", gate.Rules)
+	}
+
+	// Without a baseline the regression rule can't be evaluated
+	cfg.FailOnSkipped = true
+	cfg.Thresholds.MinPackage = 50
+	gate, err = client.Gate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Gate failed: %v", err)
+	}
+	if gate.Passed || GateExitCode(gate, nil) != GateExitFailed {
+		t.Error("Expected gate to fail")
+	}
+	if failures := gate.Failures(); len(failures) != 2 {
+		t.Errorf("Expected regression and package failures, got %v", failures)
+	}
+	if len(gate.Rules) != 4 || gate.Rules[3].Status != GatePassed {
+		t.Errorf("Expected a rule per package, got %+v", gate.Rules)
+	}
+	if summary := gate.Summary(); !strings.Contains(summary, "e2e: FAILED") || strings.Count(summary, "\n") != 5 {
+		t.Errorf("Expected the decision and a line per rule, got:\n%s", summary)
+	}
+
+	if _, err := client.Gate(context.Background(), GateConfig{CoverageReportOptions: CoverageReportOptions{TestName: "missing"}}); err == nil {
+		t.Error("Expected error for a missing report")
+	}
+	if code := GateExitCode(nil, errors.New("boom")); code != GateExitError {
+		t.Errorf("Expected exit code %d, got %d", GateExitError, code)
+	}
+}
@@ -0,0 +1,293 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubOptions configures access to the GitHub API. Unset fields are detected from CI env vars.
+type GitHubOptions struct {
+	Token      string // GitHub token (default: $GITHUB_TOKEN)
+	Repository string // owner/repo (default: $GITHUB_REPOSITORY or Prow's $REPO_OWNER/$REPO_NAME)
+	APIURL     string // API URL for GitHub Enterprise (default: $GITHUB_API_URL or https://api.github.com)
+}
+
+// GitHubPRCommentOptions configures PostGitHubPRComment
+type GitHubPRCommentOptions struct {
+	CoverageReportOptions
+	GitHubOptions
+	PRNumber int    // Pull request number (default: detected from CI env vars)
+	Title    string // Comment heading (default: "E2E Coverage")
+	Marker   string // Hidden marker identifying the comment to update (default: derived from Title)
+}
+
+// PostGitHubPRComment posts the coverage of a processed test as a pull request comment with total,
+// patch and per-package coverage (deltas when a baseline is given). An existing comment with the
+// same marker is updated instead of adding a new one, so re-runs don't spam the PR.
+func (c *CoverageClient) PostGitHubPRComment(ctx context.Context, opts GitHubPRCommentOptions) error {
+	if opts.Title == "" {
+		opts.Title = "E2E Coverage"
+	}
+	if opts.Marker == "" {
+		opts.Marker = fmt.Sprintf("<!-- go-coverage-http: %s -->", opts.Title)
+	}
+	if opts.PRNumber == 0 {
+		opts.PRNumber = detectPRNumber()
+	}
+	if opts.PRNumber == 0 {
+		return fmt.Errorf("pull request number not set and not detected from CI environment")
+	}
+
+	api, err := c.newGitHubAPI(opts.GitHubOptions)
+	if err != nil {
+		return err
+	}
+
+	report, err := c.BuildCoverageReport(ctx, opts.CoverageReportOptions)
+	if err != nil {
+		return err
+	}
+	body := opts.Marker + "\n" + RenderCoverageMarkdown(report, opts.Title)
+
+	commentID, err := api.findIssueComment(ctx, opts.PRNumber, opts.Marker)
+	if err != nil {
+		return err
+	}
+
+	comment := map[string]string{"body": body}
+	if commentID != 0 {
+		if err := api.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", api.repository, commentID), comment, nil); err != nil {
+			return fmt.Errorf("update PR comment: %w", err)
+		}
+		c.logf("💬 Updated coverage comment on %s#%d\n", api.repository, opts.PRNumber)
+		return nil
+	}
+
+	if err := api.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", api.repository, opts.PRNumber), comment, nil); err != nil {
+		return fmt.Errorf("create PR comment: %w", err)
+	}
+	c.logf("💬 Posted coverage comment on %s#%d\n", api.repository, opts.PRNumber)
+	return nil
+}
+
+// RenderCoverageMarkdown renders a coverage report as a Markdown table
+func RenderCoverageMarkdown(report *CoverageReport, title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### 📊 %s\n\n", title)
+	b.WriteString("| | Coverage | Δ |\n|---|---|---|\n")
+
+	delta := ""
+	if d, ok := report.Delta(); ok {
+		delta = formatDelta(d)
+	}
+	fmt.Fprintf(&b, "| **Total** | %s | %s |\n", formatStats(report.Total), delta)
+	if report.Patch != nil {
+		patch := "no changed statements"
+		if report.Patch.Statements > 0 {
+			patch = formatStats(*report.Patch)
+		}
+		fmt.Fprintf(&b, "| **Patch** | %s | |\n", patch)
+	}
+
+	if len(report.Packages) > 0 {
+		b.WriteString("\n<details><summary>Packages</summary>\n\n")
+		b.WriteString("| Package | Coverage | Δ |\n|---|---|---|\n")
+		for _, pkg := range report.Packages {
+			delta := ""
+			switch {
+			case pkg.Baseline != nil:
+				delta = formatDelta(pkg.Coverage.Percent() - pkg.Baseline.Percent())
+			case report.Baseline != nil:
+				delta = "new"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", pkg.Package, formatStats(pkg.Coverage), delta)
+		}
+		b.WriteString("\n</details>\n")
+	}
+	return b.String()
+}
+
+func formatStats(s CoverageStats) string {
+	return fmt.Sprintf("%.1f%% (%d/%d)", s.Percent(), s.Covered, s.Statements)
+}
+
+func formatDelta(d float64) string {
+	return fmt.Sprintf("%+.1f%%", d)
+}
+
+// githubAPI is a minimal GitHub REST client
+type githubAPI struct {
+	httpClient *http.Client
+	apiURL     string
+	token      string
+	repository string
+}
+
+func (c *CoverageClient) newGitHubAPI(opts GitHubOptions) (*githubAPI, error) {
+	api := &githubAPI{
+		httpClient: c.httpClient,
+		apiURL:     opts.APIURL,
+		token:      opts.Token,
+		repository: opts.Repository,
+	}
+	if api.httpClient == nil {
+		api.httpClient = http.DefaultClient
+	}
+	if api.token == "" {
+		api.token = os.Getenv("GITHUB_TOKEN")
+	}
+	if api.token == "" {
+		return nil, fmt.Errorf("GitHub token not set (GITHUB_TOKEN)")
+	}
+	if api.repository == "" {
+		api.repository = detectGitHubRepository()
+	}
+	if api.repository == "" {
+		return nil, fmt.Errorf("GitHub repository not set and not detected from CI environment")
+	}
+	if api.apiURL == "" {
+		api.apiURL = os.Getenv("GITHUB_API_URL")
+	}
+	if api.apiURL == "" {
+		api.apiURL = defaultGitHubAPIURL
+	}
+	api.apiURL = strings.TrimSuffix(api.apiURL, "/")
+	return api, nil
+}
+
+// do sends a JSON request to the GitHub API and decodes the response into out (if not nil)
+func (g *githubAPI) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.apiURL+path, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API %s %s returned %d: %s", method, path, resp.StatusCode, respBody)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// findIssueComment returns the ID of the first comment on the issue/PR containing marker (0 if none)
+func (g *githubAPI) findIssueComment(ctx context.Context, number int, marker string) (int64, error) {
+	const perPage = 100
+	for page := 1; ; page++ {
+		var comments []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=%d&page=%d", g.repository, number, perPage, page)
+		if err := g.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return 0, fmt.Errorf("list PR comments: %w", err)
+		}
+		for _, comment := range comments {
+			if strings.Contains(comment.Body, marker) {
+				return comment.ID, nil
+			}
+		}
+		if len(comments) < perPage {
+			return 0, nil
+		}
+	}
+}
+
+// detectGitHubRepository returns owner/repo from GitHub Actions or Prow env vars
+func detectGitHubRepository() string {
+	if repo := os.Getenv("GITHUB_REPOSITORY"); repo != "" {
+		return repo
+	}
+	if owner, name := os.Getenv("REPO_OWNER"), os.Getenv("REPO_NAME"); owner != "" && name != "" {
+		return owner + "/" + name
+	}
+	return ""
+}
+
+// detectPRNumber returns the pull request number from CI env vars (0 if not a PR build).
+// Supports GitHub Actions, GitLab, Prow, Jenkins and Buildkite.
+func detectPRNumber() int {
+	// GitHub Actions: refs/pull/<n>/merge, or the event payload
+	if ref := os.Getenv("GITHUB_REF"); strings.HasPrefix(ref, "refs/pull/") {
+		if n, err := strconv.Atoi(strings.Split(ref, "/")[2]); err == nil {
+			return n
+		}
+	}
+	if event := readGitHubEvent(); event != nil {
+		if event.PullRequest.Number != 0 {
+			return event.PullRequest.Number
+		}
+		if event.Number != 0 {
+			return event.Number
+		}
+	}
+
+	for _, name := range []string{"PULL_NUMBER", "CI_MERGE_REQUEST_IID", "CHANGE_ID", "BUILDKITE_PULL_REQUEST"} {
+		if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// githubEvent holds the fields of a GitHub Actions event payload used for detection
+type githubEvent struct {
+	Number      int `json:"number"`
+	PullRequest struct {
+		Number int `json:"number"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+// readGitHubEvent reads the GitHub Actions event payload ($GITHUB_EVENT_PATH), nil if unavailable
+func readGitHubEvent() *githubEvent {
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if eventPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(eventPath)
+	if err != nil {
+		return nil
+	}
+	var event githubEvent
+	if json.Unmarshal(data, &event) != nil {
+		return nil
+	}
+	return &event
+}
@@ -0,0 +1,152 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newGitHubTestServer fakes the issue comment endpoints of one pull request
+func newGitHubTestServer(t *testing.T, comments map[int64]string) *httptest.Server {
+	var mu sync.Mutex
+	nextID := int64(100)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Body string `json:"body"`
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/repo/issues/42/comments":
+			type comment struct {
+				ID   int64  `json:"id"`
+				Body string `json:"body"`
+			}
+			list := []comment{}
+			for id, body := range comments {
+				list = append(list, comment{ID: id, Body: body})
+			}
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/org/repo/issues/42/comments":
+			json.NewDecoder(r.Body).Decode(&req)
+			nextID++
+			comments[nextID] = req.Body
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": %d}`, nextID)
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/org/repo/issues/comments/"):
+			var id int64
+			fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/repos/org/repo/issues/comments/"), "%d", &id)
+			json.NewDecoder(r.Body).Decode(&req)
+			comments[id] = req.Body
+			fmt.Fprintf(w, `{"id": %d}`, id)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPostGitHubPRComment(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	baseline := filepath.Join(t.TempDir(), "baseline.out")
+	os.WriteFile(baseline, []byte("mode: atomic\ngithub.com/example/app/main.go:10.2,12.3 2 0\ngithub.com/example/app/main.go:14.2,16.3 3 0\n"), 0644)
+
+	comments := map[int64]string{1: "unrelated comment"}
+	server := newGitHubTestServer(t, comments)
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: outputDir, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	opts := GitHubPRCommentOptions{
+		CoverageReportOptions: CoverageReportOptions{TestName: "e2e", BaselineProfile: baseline},
+		GitHubOptions:         GitHubOptions{Token: "test-token", Repository: "org/repo", APIURL: server.URL},
+		PRNumber:              42,
+	}
+
+	// Posting twice must update the first comment instead of adding another one
+	for i := 0; i < 2; i++ {
+		if err := client.PostGitHubPRComment(context.Background(), opts); err != nil {
+			t.Fatalf("PostGitHubPRComment failed: %v", err)
+		}
+	}
+
+	if len(comments) != 2 {
+		t.Fatalf("Expected one coverage comment next to the unrelated one, got %v", comments)
+	}
+	body := comments[101]
+	for _, want := range []string{
+		"<!-- go-coverage-http: E2E Coverage -->",
+		"| **Total** | 66.7% (6/9) | +66.7% |",
+		"| `github.com/example/app` | 40.0% (2/5) | +40.0% |",
+		"| `github.com/example/app/pkg/util` | 100.0% (4/4) | new |",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected comment to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "**Patch**") {
+		t.Error("Expected no patch coverage without a base ref")
+	}
+}
+
+func TestPostGitHubPRComment_MissingSettings(t *testing.T) {
+	for _, name := range []string{"GITHUB_TOKEN", "GITHUB_REPOSITORY", "REPO_OWNER", "REPO_NAME", "GITHUB_REF", "GITHUB_EVENT_PATH", "PULL_NUMBER", "CHANGE_ID", "BUILDKITE_PULL_REQUEST"} {
+		t.Setenv(name, "")
+	}
+	client := &CoverageClient{outputDir: t.TempDir()}
+
+	if err := client.PostGitHubPRComment(context.Background(), GitHubPRCommentOptions{}); err == nil || !strings.Contains(err.Error(), "pull request number") {
+		t.Errorf("Expected missing PR number error, got %v", err)
+	}
+	if err := client.PostGitHubPRComment(context.Background(), GitHubPRCommentOptions{PRNumber: 1}); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("Expected missing token error, got %v", err)
+	}
+}
+
+func TestDetectPRNumber(t *testing.T) {
+	for _, name := range []string{"GITHUB_REF", "GITHUB_EVENT_PATH", "PULL_NUMBER", "CHANGE_ID", "BUILDKITE_PULL_REQUEST"} {
+		t.Setenv(name, "")
+	}
+	if n := detectPRNumber(); n != 0 {
+		t.Errorf("Expected no PR outside CI, got %d", n)
+	}
+
+	t.Setenv("BUILDKITE_PULL_REQUEST", "false")
+	if n := detectPRNumber(); n != 0 {
+		t.Errorf("Expected no PR for Buildkite branch builds, got %d", n)
+	}
+
+	t.Setenv("PULL_NUMBER", "17")
+	if n := detectPRNumber(); n != 17 {
+		t.Errorf("Expected Prow PR 17, got %d", n)
+	}
+
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	os.WriteFile(eventPath, []byte(`{"pull_request": {"number": 23}}`), 0644)
+	t.Setenv("GITHUB_EVENT_PATH", eventPath)
+	if n := detectPRNumber(); n != 23 {
+		t.Errorf("Expected PR 23 from event payload, got %d", n)
+	}
+
+	t.Setenv("GITHUB_REF", "refs/pull/42/merge")
+	if n := detectPRNumber(); n != 42 {
+		t.Errorf("Expected PR 42 from GITHUB_REF, got %d", n)
+	}
+}
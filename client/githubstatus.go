@@ -0,0 +1,148 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// GitHubStatusOptions configures ReportGitHubStatus
+type GitHubStatusOptions struct {
+	CoverageReportOptions
+	GitHubOptions
+	Thresholds CoverageThresholds // Coverage the commit must reach for a successful status
+	SHA        string             // Commit to report on (default: the PR head commit detected from CI env vars)
+	Name       string             // Status context / check name (default: "e2e-coverage")
+	TargetURL  string             // Link shown with the status, e.g. to the HTML report
+	CheckRun   bool               // Create a check run instead of a commit status (needs a GitHub App token)
+}
+
+// ReportGitHubStatus reports the coverage of a processed test on a commit, e.g.
+// "e2e-coverage: 78.3% (+0.4%)", as a commit status or check run that fails when a threshold
+// is violated, so coverage gating shows up in the PR merge box. It returns whether the thresholds passed.
+func (c *CoverageClient) ReportGitHubStatus(ctx context.Context, opts GitHubStatusOptions) (bool, error) {
+	if opts.Name == "" {
+		opts.Name = "e2e-coverage"
+	}
+	if opts.SHA == "" {
+		opts.SHA = detectCommitSHA()
+	}
+	if opts.SHA == "" {
+		return false, fmt.Errorf("commit SHA not set and not detected from CI environment")
+	}
+
+	api, err := c.newGitHubAPI(opts.GitHubOptions)
+	if err != nil {
+		return false, err
+	}
+
+	report, err := c.BuildCoverageReport(ctx, opts.CoverageReportOptions)
+	if err != nil {
+		return false, err
+	}
+	failures := opts.Thresholds.Check(report)
+	passed := len(failures) == 0
+
+	description := fmt.Sprintf("%.1f%%", report.Total.Percent())
+	if d, ok := report.Delta(); ok {
+		description += fmt.Sprintf(" (%s)", formatDelta(d))
+	}
+	if report.Patch != nil && report.Patch.Statements > 0 {
+		description += fmt.Sprintf(", patch %.1f%%", report.Patch.Percent())
+	}
+	if !passed {
+		description += " - " + strings.Join(failures, "; ")
+	}
+
+	if opts.CheckRun {
+		err = api.createCheckRun(ctx, opts, report, description, failures)
+	} else {
+		err = api.createCommitStatus(ctx, opts, description, passed)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	icon := "✅"
+	if !passed {
+		icon = "❌"
+	}
+	c.logf("%s Reported %s: %s on %s@%s\n", icon, opts.Name, description, api.repository, opts.SHA)
+	return passed, nil
+}
+
+// createCommitStatus sets a commit status (descriptions are limited to 140 characters)
+func (g *githubAPI) createCommitStatus(ctx context.Context, opts GitHubStatusOptions, description string, passed bool) error {
+	state := "success"
+	if !passed {
+		state = "failure"
+	}
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+
+	status := map[string]string{
+		"state":       state,
+		"context":     opts.Name,
+		"description": description,
+	}
+	if opts.TargetURL != "" {
+		status["target_url"] = opts.TargetURL
+	}
+
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", g.repository, opts.SHA), status, nil); err != nil {
+		return fmt.Errorf("create commit status: %w", err)
+	}
+	return nil
+}
+
+// createCheckRun creates a completed check run with the coverage table as summary
+func (g *githubAPI) createCheckRun(ctx context.Context, opts GitHubStatusOptions, report *CoverageReport, description string, failures []string) error {
+	conclusion := "success"
+	if len(failures) > 0 {
+		conclusion = "failure"
+	}
+
+	summary := RenderCoverageMarkdown(report, opts.Name)
+	if len(failures) > 0 {
+		summary += "\n**Failed thresholds:**\n"
+		for _, failure := range failures {
+			summary += "- " + failure + "\n"
+		}
+	}
+
+	checkRun := map[string]interface{}{
+		"name":       opts.Name,
+		"head_sha":   opts.SHA,
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output": map[string]string{
+			"title":   description,
+			"summary": summary,
+		},
+	}
+	if opts.TargetURL != "" {
+		checkRun["details_url"] = opts.TargetURL
+	}
+
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/check-runs", g.repository), checkRun, nil); err != nil {
+		return fmt.Errorf("create check run: %w", err)
+	}
+	return nil
+}
+
+// detectCommitSHA returns the commit under test from CI env vars, preferring the PR head
+// over merge commits (GitHub Actions, GitLab, Prow, Jenkins, Buildkite)
+func detectCommitSHA() string {
+	if event := readGitHubEvent(); event != nil && event.PullRequest.Head.SHA != "" {
+		return event.PullRequest.Head.SHA
+	}
+	for _, name := range []string{"PULL_PULL_SHA", "GITHUB_SHA", "CI_COMMIT_SHA", "GIT_COMMIT", "BUILDKITE_COMMIT"} {
+		if sha := os.Getenv(name); sha != "" {
+			return sha
+		}
+	}
+	return ""
+}
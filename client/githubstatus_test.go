@@ -0,0 +1,81 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportGitHubStatus(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	requests := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests[r.Method+" "+r.URL.Path] = body
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: outputDir, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	opts := GitHubStatusOptions{
+		CoverageReportOptions: CoverageReportOptions{TestName: "e2e"},
+		GitHubOptions:         GitHubOptions{Token: "test-token", Repository: "org/repo", APIURL: server.URL},
+		SHA:                   "abc123",
+		Thresholds:            CoverageThresholds{MinTotal: 60},
+	}
+
+	passed, err := client.ReportGitHubStatus(context.Background(), opts)
+	if err != nil || !passed {
+		t.Fatalf("Expected passing status, got %v, %v", passed, err)
+	}
+	status := requests["POST /repos/org/repo/statuses/abc123"]
+	if status["state"] != "success" || status["context"] != "e2e-coverage" || status["description"] != "66.7%" {
+		t.Errorf("Unexpected commit status: %v", status)
+	}
+
+	opts.CheckRun = true
+	opts.Thresholds.MinTotal = 80
+	passed, err = client.ReportGitHubStatus(context.Background(), opts)
+	if err != nil || passed {
+		t.Fatalf("Expected failing check run, got %v, %v", passed, err)
+	}
+	checkRun := requests["POST /repos/org/repo/check-runs"]
+	if checkRun["conclusion"] != "failure" || checkRun["head_sha"] != "abc123" {
+		t.Errorf("Unexpected check run: %v", checkRun)
+	}
+	output, _ := checkRun["output"].(map[string]interface{})
+	if summary, _ := output["summary"].(string); !strings.Contains(summary, "total coverage 66.7% is below 80.0%") {
+		t.Errorf("Expected failed threshold in summary, got %q", summary)
+	}
+}
+
+func TestDetectCommitSHA(t *testing.T) {
+	for _, name := range []string{"GITHUB_EVENT_PATH", "PULL_PULL_SHA", "GITHUB_SHA", "GIT_COMMIT", "BUILDKITE_COMMIT"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("GITHUB_SHA", "merge-sha")
+	if sha := detectCommitSHA(); sha != "merge-sha" {
+		t.Errorf("Expected GITHUB_SHA, got %s", sha)
+	}
+
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	os.WriteFile(eventPath, []byte(`{"pull_request": {"number": 1, "head": {"sha": "head-sha"}}}`), 0644)
+	t.Setenv("GITHUB_EVENT_PATH", eventPath)
+	if sha := detectCommitSHA(); sha != "head-sha" {
+		t.Errorf("Expected PR head SHA to win over the merge commit, got %s", sha)
+	}
+}
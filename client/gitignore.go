@@ -0,0 +1,127 @@
+package coverageclient
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignore holds the patterns of a .gitignore file, linked to those of the parent directories
+type gitignore struct {
+	parent   *gitignore
+	dir      string // Directory holding the .gitignore file, patterns are relative to it
+	patterns []ignorePattern
+}
+
+// ignorePattern is a parsed .gitignore line
+type ignorePattern struct {
+	elems    []string // Pattern split into path elements, "**" matches any number of them
+	anchored bool     // Pattern contains a slash, so it matches from the .gitignore's directory
+	dirOnly  bool     // Pattern ends with a slash, so it only matches directories
+	negate   bool     // Pattern starts with "!", re-including what earlier patterns excluded
+}
+
+// loadGitignore returns the patterns of dir's .gitignore on top of parent, or parent when dir
+// has none
+func loadGitignore(parent *gitignore, dir string) *gitignore {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return parent
+	}
+	defer f.Close()
+
+	g := &gitignore{parent: parent, dir: dir}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseIgnorePattern(scanner.Text()); ok {
+			g.patterns = append(g.patterns, p)
+		}
+	}
+	if len(g.patterns) == 0 {
+		return parent
+	}
+	return g
+}
+
+// parseIgnorePattern parses a .gitignore line, reporting false for blank lines and comments
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		p.negate = true
+		line = rest
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		p.dirOnly = true
+		line = rest
+	}
+	p.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignorePattern{}, false
+	}
+	p.elems = strings.Split(line, "/")
+	return p, true
+}
+
+// ignored reports whether the file or directory at p is ignored. As with git, the last matching
+// pattern decides, and patterns of deeper .gitignore files take precedence.
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	for ; g != nil; g = g.parent {
+		rel, err := filepath.Rel(g.dir, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		elems := strings.Split(filepath.ToSlash(rel), "/")
+		for i := len(g.patterns) - 1; i >= 0; i-- {
+			if g.patterns[i].match(elems, isDir) {
+				return !g.patterns[i].negate
+			}
+		}
+	}
+	return false
+}
+
+// match reports whether the pattern matches the path elements relative to the .gitignore's directory
+func (p ignorePattern) match(elems []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		// A pattern without a slash matches the name at any depth
+		ok, _ := path.Match(p.elems[0], elems[len(elems)-1])
+		return ok
+	}
+	return matchGlobElems(p.elems, elems)
+}
+
+// matchGlobElems matches path elements against pattern elements, where "**" matches zero or more
+// elements and other elements are matched with path.Match
+func matchGlobElems(pattern, elems []string) bool {
+	if len(pattern) == 0 {
+		return len(elems) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elems); i++ {
+			if matchGlobElems(pattern[1:], elems[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+		return false
+	}
+	return matchGlobElems(pattern[1:], elems[1:])
+}
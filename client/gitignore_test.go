@@ -0,0 +1,47 @@
+package coverageclient
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	g := &gitignore{dir: root}
+	for _, line := range []string{"# comment", "", "*.log", "/bin", "build/", "docs/**/gen", "!keep.log", `\#notes`} {
+		if p, ok := parseIgnorePattern(line); ok {
+			g.patterns = append(g.patterns, p)
+		}
+	}
+	nested := &gitignore{parent: g, dir: filepath.Join(root, "sub")}
+	if p, ok := parseIgnorePattern("!*.log"); ok {
+		nested.patterns = append(nested.patterns, p)
+	}
+
+	for _, tt := range []struct {
+		ignore *gitignore
+		path   string
+		isDir  bool
+		want   bool
+	}{
+		{g, "app.log", false, true},
+		{g, "a/b/app.log", false, true},
+		{g, "keep.log", false, false},
+		{g, "bin", true, true},
+		{g, "cmd/bin", true, false},
+		{g, "build", true, true},
+		{g, "cmd/build", true, true},
+		{g, "build", false, false},
+		{g, "docs/gen", true, true},
+		{g, "docs/api/v1/gen", true, true},
+		{g, "src/docs/gen", true, false},
+		{g, "#notes", false, true},
+		{g, "main.go", false, false},
+		{nested, "sub/app.log", false, false},
+		{nested, "other/app.log", false, true},
+	} {
+		if got := tt.ignore.ignored(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir); got != tt.want {
+			t.Errorf("ignored(%s, dir %v): expected %v, got %v", tt.path, tt.isDir, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SourceMetadata is the git state of the test's source checkout when coverage was collected
+type SourceMetadata struct {
+	Branch string `json:"branch,omitempty"` // Empty for a detached HEAD
+	Commit string `json:"commit"`
+	Dirty  bool   `json:"dirty,omitempty"` // Tracked files have uncommitted changes
+}
+
+// DetectGitSource returns the branch, commit and dirty state of the git checkout containing dir.
+// It is recorded in metadata.json for the client's source directory (see SetSourceDirectory), so
+// coverage can be traced back to the revision the tests ran from, next to the binary's own
+// revision recorded by the coverage server.
+func DetectGitSource(ctx context.Context, dir string) (*SourceMetadata, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				return "", fmt.Errorf("git %s: %w\nOutput: %s", args[0], err, exitErr.Stderr)
+			}
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	commit, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	source := &SourceMetadata{Commit: commit}
+	if branch, err := git("rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		source.Branch = branch
+	}
+	status, err := git("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	source.Dirty = status != ""
+	return source, nil
+}
+
+// gitSource returns the git state of the client's source directory, nil outside a git checkout
+func (c *CoverageClient) gitSource(ctx context.Context) *SourceMetadata {
+	if c.sourceDir == "" {
+		return nil
+	}
+	source, err := DetectGitSource(ctx, c.sourceDir)
+	if err != nil {
+		return nil
+	}
+	return source
+}
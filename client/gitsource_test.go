@@ -0,0 +1,59 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repoDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\n"), 0644)
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	commit := git("rev-parse", "HEAD")
+	commit = commit[:len(commit)-1]
+
+	ctx := context.Background()
+	source, err := DetectGitSource(ctx, repoDir)
+	if err != nil {
+		t.Fatalf("DetectGitSource failed: %v", err)
+	}
+	if *source != (SourceMetadata{Branch: "main", Commit: commit}) {
+		t.Errorf("Unexpected source %+v", source)
+	}
+
+	// Untracked files, e.g. the coverage output, don't make the checkout dirty
+	os.WriteFile(filepath.Join(repoDir, "coverage.out"), []byte("mode: set\n"), 0644)
+	if source, _ := DetectGitSource(ctx, repoDir); source.Dirty {
+		t.Error("Expected untracked files not to count as changes")
+	}
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main // changed\n"), 0644)
+	git("checkout", "-q", "--detach")
+	if source, _ := DetectGitSource(ctx, repoDir); !source.Dirty || source.Branch != "" || source.Commit != commit {
+		t.Errorf("Expected a dirty detached checkout, got %+v", source)
+	}
+
+	if _, err := DetectGitSource(ctx, t.TempDir()); err == nil {
+		t.Error("Expected an error outside a git checkout")
+	}
+	if source := (&CoverageClient{sourceDir: t.TempDir()}).gitSource(ctx); source != nil {
+		t.Errorf("Expected no source outside a git checkout, got %+v", source)
+	}
+}
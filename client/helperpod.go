@@ -0,0 +1,155 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const defaultHelperImage = "busybox:1.36"
+
+// helperUID is the user helper pods run as; busybox images default to root, which the restricted
+// Pod Security Standard rejects
+const helperUID int64 = 65534
+
+// HelperPodOptions configures the short-lived helper pod used by CollectCoverageViaHelperPod
+type HelperPodOptions struct {
+	Image    string        `json:"image,omitempty"`    // Helper image providing sh and wget (default: busybox:1.36)
+	NodeName string        `json:"nodeName,omitempty"` // Schedule the helper on this node (default: any)
+	Timeout  time.Duration `json:"timeout,omitempty"`  // How long to wait for the helper pod (default: 2m)
+}
+
+// CollectCoverageViaHelperPod collects coverage from inside the cluster for test runners that can't
+// reach the cluster network at all. It starts a helper pod in the namespace that calls the coverage
+// endpoint on the pod IP and prints the response, which is read back through the API server logs.
+// The helper pod is deleted afterwards.
+func (c *CoverageClient) CollectCoverageViaHelperPod(ctx context.Context, podName, testName string, targetPort int, opts HelperPodOptions) error {
+	c.logf("📊 Collecting coverage from pod %s via helper pod for test: %s\n", podName, testName)
+
+	if err := c.collectCoverageViaHelperPod(ctx, podName, testName, targetPort, opts); err != nil {
+		return err
+	}
+
+	if err := c.savePodMetadata(ctx, podName, "", testName, targetPort, MethodHelperPod); err != nil {
+		c.logf("⚠️  Failed to save pod metadata: %v\n", err)
+	}
+	c.recordCollection(ctx, podName, testName, MethodHelperPod)
+
+	c.logf("✅ Coverage collected successfully for test: %s\n", testName)
+	return nil
+}
+
+// collectCoverageViaHelperPod fetches coverage from the pod IP through a helper pod
+func (c *CoverageClient) collectCoverageViaHelperPod(ctx context.Context, podName, testName string, targetPort int, opts HelperPodOptions) error {
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get pod details: %w", err)
+	}
+	if pod.Status.PodIP == "" {
+		return fmt.Errorf("pod %s has no IP (phase %s)", podName, pod.Status.Phase)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{"test_name": testName})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	coverageURL := fmt.Sprintf("http://%s:%d/coverage", pod.Status.PodIP, targetPort)
+	script := fmt.Sprintf("wget -qO- --header='Content-Type: application/json' --post-data=%s %s", shellQuote(string(reqBody)), shellQuote(coverageURL))
+
+	logs, err := c.runHelperPod(ctx, newHelperPod("coverage-fetcher-", []string{"sh", "-c", script}, opts), opts.Timeout)
+	if err != nil {
+		return err
+	}
+
+	return c.saveCoverageResponse(bytes.NewReader(logs), testName)
+}
+
+// newHelperPod builds a run-once pod executing command in the helper image. The pod satisfies the
+// restricted Pod Security Standard, so it is admitted in namespaces enforcing it.
+func newHelperPod(generateName string, command []string, opts HelperPodOptions) *corev1.Pod {
+	image := opts.Image
+	if image == "" {
+		image = defaultHelperImage
+	}
+	runAsNonRoot := true
+	runAsUser := helperUID
+	allowPrivilegeEscalation := false
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: generateName,
+			Labels:       map[string]string{"app.kubernetes.io/name": "coverage-helper"},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			NodeName:      opts.NodeName,
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   &runAsNonRoot,
+				RunAsUser:      &runAsUser,
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []corev1.Container{{
+				Name:    "helper",
+				Image:   image,
+				Command: command,
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+		},
+	}
+}
+
+// runHelperPod creates the pod, waits for it to complete and returns its logs. The pod is always deleted.
+func (c *CoverageClient) runHelperPod(ctx context.Context, pod *corev1.Pod, timeout time.Duration) ([]byte, error) {
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+
+	pods := c.clientset.CoreV1().Pods(c.namespace)
+	helper, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("create helper pod: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the helper is removed even if ctx was cancelled
+		if err := pods.Delete(context.Background(), helper.Name, metav1.DeleteOptions{}); err != nil {
+			c.logf("⚠️  Failed to delete helper pod %s: %v\n", helper.Name, err)
+		}
+	}()
+	c.logf("  🚀 Started helper pod %s\n", helper.Name)
+
+	var phase corev1.PodPhase
+	err = wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := pods.Get(ctx, helper.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		phase = pod.Status.Phase
+		return phase == corev1.PodSucceeded || phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wait for helper pod %s (phase %q): %w", helper.Name, phase, err)
+	}
+
+	stream, err := pods.GetLogs(helper.Name, &corev1.PodLogOptions{}).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read helper pod logs: %w", err)
+	}
+	defer stream.Close()
+	logs, err := io.ReadAll(c.limitResponse(stream))
+	if err != nil {
+		return nil, fmt.Errorf("read helper pod logs: %w", err)
+	}
+	if phase == corev1.PodFailed {
+		return nil, fmt.Errorf("helper pod %s failed: %s", helper.Name, logs)
+	}
+	return logs, nil
+}
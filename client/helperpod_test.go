@@ -0,0 +1,81 @@
+package coverageclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCollectCoverageViaHelperPod(t *testing.T) {
+	pod, _, _ := newFallbackTestObjects()
+	pod.Status.PodIP = "10.0.0.7"
+	clientset := fake.NewSimpleClientset(pod)
+
+	var helper *corev1.Pod
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// The fake clientset neither generates names nor runs pods
+		helper = action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		helper.Name = helper.GenerateName + "test"
+		helper.Status.Phase = corev1.PodSucceeded
+		return false, nil, nil
+	})
+
+	client := &CoverageClient{clientset: clientset, namespace: "default", outputDir: t.TempDir()}
+	client.SetRecordCollection(false)
+
+	// The fake clientset always returns "fake logs", so decoding the response fails
+	err := client.CollectCoverageViaHelperPod(context.Background(), "demo-pod", "helper's test", 9095, HelperPodOptions{Image: "curlimages/curl"})
+	if err == nil || !strings.Contains(err.Error(), "decode coverage response") {
+		t.Fatalf("Expected decode error from fake logs, got %v", err)
+	}
+
+	if helper == nil {
+		t.Fatal("Expected a helper pod to be created")
+	}
+	if helper.Spec.Containers[0].Image != "curlimages/curl" || helper.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("Unexpected helper pod spec: %+v", helper.Spec)
+	}
+	if script := helper.Spec.Containers[0].Command[2]; !strings.Contains(script, "http://10.0.0.7:9095/coverage") {
+		t.Errorf("Expected helper to call the pod IP, got: %s", script)
+	}
+	if script := helper.Spec.Containers[0].Command[2]; !strings.Contains(script, `--post-data='{"test_name":"helper'\''s test"}'`) {
+		t.Errorf("Expected the request body to be quoted for the shell, got: %s", script)
+	}
+	if _, err := clientset.CoreV1().Pods("default").Get(context.Background(), helper.Name, metav1.GetOptions{}); err == nil {
+		t.Error("Expected helper pod to be deleted")
+	}
+}
+
+func TestNewHelperPod_Restricted(t *testing.T) {
+	pod := newHelperPod("coverage-helper-", []string{"true"}, HelperPodOptions{})
+
+	psc := pod.Spec.SecurityContext
+	if psc == nil || psc.RunAsNonRoot == nil || !*psc.RunAsNonRoot || psc.RunAsUser == nil || *psc.RunAsUser == 0 {
+		t.Errorf("Expected the helper pod to run as a non-root user, got %+v", psc)
+	}
+	if psc == nil || psc.SeccompProfile == nil || psc.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+		t.Errorf("Expected the RuntimeDefault seccomp profile, got %+v", psc)
+	}
+	csc := pod.Spec.Containers[0].SecurityContext
+	if csc == nil || csc.AllowPrivilegeEscalation == nil || *csc.AllowPrivilegeEscalation {
+		t.Errorf("Expected privilege escalation to be disallowed, got %+v", csc)
+	}
+	if csc == nil || csc.Capabilities == nil || len(csc.Capabilities.Drop) != 1 || csc.Capabilities.Drop[0] != "ALL" {
+		t.Errorf("Expected all capabilities to be dropped, got %+v", csc)
+	}
+}
+
+func TestCollectCoverageViaHelperPod_NoPodIP(t *testing.T) {
+	pod, _, _ := newFallbackTestObjects()
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(pod), namespace: "default", outputDir: t.TempDir()}
+
+	if err := client.CollectCoverageViaHelperPod(context.Background(), "demo-pod", "helper-test", 9095, HelperPodOptions{}); err == nil {
+		t.Error("Expected error for pod without IP")
+	}
+}
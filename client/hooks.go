@@ -0,0 +1,136 @@
+package coverageclient
+
+import (
+	"context"
+	"time"
+)
+
+// EventHandler receives the client's workflow events, so frameworks embedding the client can
+// record their own metrics, retries or UI updates. Handlers run synchronously on the goroutine
+// doing the work, so keep them fast; concurrent collections call them concurrently.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event Event)
+}
+
+// EventHandlerFunc adapts a function to EventHandler
+type EventHandlerFunc func(ctx context.Context, event Event)
+
+func (f EventHandlerFunc) HandleEvent(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// Event is a workflow event: *CollectStarted, *CollectFinished, *ReportGenerated, *PushStarted or
+// *PushFinished. Switch on the type to read its payload; more event types may be added.
+type Event interface {
+	event()
+}
+
+// CollectStarted is sent before each collection attempt
+type CollectStarted struct {
+	TestName string
+	Target   string // Pod, URL, service or backend target
+	Attempt  int    // 1 for the first attempt, see CollectOptions.Retries
+}
+
+// CollectFinished is sent after each collection attempt
+type CollectFinished struct {
+	TestName string
+	Target   string
+	Attempt  int
+	Result   *CollectResult // nil when the attempt failed
+	Duration time.Duration
+	Err      error
+}
+
+// ReportKind identifies the reports generated from collected coverage
+type ReportKind string
+
+const (
+	ReportText     ReportKind = "text"     // coverage.out
+	ReportFiltered ReportKind = "filtered" // coverage_filtered.out
+	ReportHTML     ReportKind = "html"     // coverage.html
+)
+
+// ReportGenerated is sent after a report was generated or failed
+type ReportGenerated struct {
+	TestName string
+	Kind     ReportKind
+	Path     string
+	Duration time.Duration
+	Err      error
+}
+
+// PushStarted is sent before an artifact is pushed
+type PushStarted struct {
+	TestName  string
+	Reference string // registry/repository:tag
+}
+
+// PushFinished is sent after an artifact was pushed or failed
+type PushFinished struct {
+	TestName string
+	Result   *PushResult // nil when the push failed
+	Duration time.Duration
+	Err      error
+}
+
+func (*CollectStarted) event()  {}
+func (*CollectFinished) event() {}
+func (*ReportGenerated) event() {}
+func (*PushStarted) event()     {}
+func (*PushFinished) event()    {}
+
+// AddEventHandler registers a handler for the client's events. Collection events are sent by
+// Collect, CollectCoverageFromPod(WithContainer), CollectCoverageWithFallback and CollectCoverageFromURL.
+func (c *CoverageClient) AddEventHandler(handler EventHandler) {
+	c.eventHandlers = append(c.eventHandlers, handler)
+}
+
+// emit sends event to the registered handlers
+func (c *CoverageClient) emit(ctx context.Context, event Event) {
+	for _, handler := range c.eventHandlers {
+		handler.HandleEvent(ctx, event)
+	}
+}
+
+// collectEventsKey marks contexts of collections whose events are already being sent, so nested
+// entry points (Collect using CollectCoverageWithFallback) don't send them twice
+type collectEventsKey struct{}
+
+// collectWithEvents runs one collection attempt of target, sending CollectStarted and CollectFinished
+// around it unless an outer collection does
+func (c *CoverageClient) collectWithEvents(ctx context.Context, testName, target string, attempt int, collect func(context.Context) (*CollectResult, error)) (*CollectResult, error) {
+	if ctx.Value(collectEventsKey{}) != nil {
+		return collect(ctx)
+	}
+	ctx = context.WithValue(ctx, collectEventsKey{}, true)
+
+	c.emit(ctx, &CollectStarted{TestName: testName, Target: target, Attempt: attempt})
+	start := time.Now()
+	result, err := c.collectLocked(ctx, testName, collect)
+	duration := time.Since(start)
+	if result != nil {
+		result.Duration = duration
+	}
+	c.emit(ctx, &CollectFinished{TestName: testName, Target: target, Attempt: attempt, Result: result, Duration: duration, Err: err})
+	return result, err
+}
+
+// collectLocked runs collect with the test directory testName locked
+func (c *CoverageClient) collectLocked(ctx context.Context, testName string, collect func(context.Context) (*CollectResult, error)) (*CollectResult, error) {
+	ctx, unlock, err := c.lockTestDir(ctx, testName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	c.savedFiles.reset(c.testDir(testName))
+	return collect(ctx)
+}
+
+// reportEvent sends a ReportGenerated event for a report step started at start
+func (c *CoverageClient) reportEvent(ctx context.Context, testName string, kind ReportKind, path string, start time.Time, err error) {
+	if err != nil {
+		path = ""
+	}
+	c.emit(ctx, &ReportGenerated{TestName: testName, Kind: kind, Path: path, Duration: time.Since(start), Err: err})
+}
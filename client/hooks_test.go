@@ -0,0 +1,107 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// eventRecorder records the events it receives
+type eventRecorder struct {
+	events []Event
+}
+
+func (r *eventRecorder) HandleEvent(ctx context.Context, event Event) {
+	r.events = append(r.events, event)
+}
+
+func TestEvents_Collect(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 {
+			http.Error(w, "starting up", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+
+	recorder := &eventRecorder{}
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), quiet: true}
+	client.AddEventHandler(recorder)
+
+	target := server.URL + "/coverage"
+	if _, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", URL: target, Retries: 1, RetryDelay: time.Millisecond}); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	if len(recorder.events) != 4 {
+		t.Fatalf("Expected start and finish events of two attempts, got %d: %+v", len(recorder.events), recorder.events)
+	}
+	if started, ok := recorder.events[0].(*CollectStarted); !ok || started.Attempt != 1 || started.Target != target || started.TestName != "e2e" {
+		t.Errorf("Unexpected first event %+v", recorder.events[0])
+	}
+	if failed, ok := recorder.events[1].(*CollectFinished); !ok || failed.Err == nil || failed.Result != nil {
+		t.Errorf("Expected the first attempt to fail, got %+v", recorder.events[1])
+	}
+	if finished, ok := recorder.events[3].(*CollectFinished); !ok || finished.Attempt != 2 || finished.Err != nil || len(finished.Result.Files) != 2 {
+		t.Errorf("Expected the second attempt to succeed, got %+v", recorder.events[3])
+	}
+
+	recorder.events = nil
+	if err := client.CollectCoverageFromURL(target, "e2e"); err != nil {
+		t.Fatalf("CollectCoverageFromURL failed: %v", err)
+	}
+	if len(recorder.events) != 2 {
+		t.Errorf("Expected start and finish events, got %+v", recorder.events)
+	}
+}
+
+func TestEvents_Reports(t *testing.T) {
+	recorder := &eventRecorder{}
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	client.AddEventHandler(recorder)
+
+	testDir := filepath.Join(client.outputDir, "e2e")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "coverage.out"), []byte(testProfile), 0644)
+
+	if err := client.FilterCoverageReport("e2e", "coverage_server.go"); err != nil {
+		t.Fatalf("FilterCoverageReport failed: %v", err)
+	}
+	if len(recorder.events) != 1 {
+		t.Fatalf("Expected one event, got %+v", recorder.events)
+	}
+	generated, ok := recorder.events[0].(*ReportGenerated)
+	if !ok || generated.Kind != ReportFiltered || generated.Path != filepath.Join(testDir, "coverage_filtered.out") || generated.Err != nil {
+		t.Errorf("Unexpected event %+v", recorder.events[0])
+	}
+}
+
+func TestEvents_PushFailure(t *testing.T) {
+	var events []Event
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	client.AddEventHandler(EventHandlerFunc(func(ctx context.Context, event Event) {
+		events = append(events, event)
+	}))
+
+	_, err := client.PushCoverageArtifactWithResult(context.Background(), "missing", PushCoverageArtifactOptions{Registry: "localhost:5000", Repository: "coverage", Tag: "e2e"})
+	if err == nil {
+		t.Fatal("Expected pushing a missing test to fail")
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected start and finish events, got %+v", events)
+	}
+	if started, ok := events[0].(*PushStarted); !ok || started.Reference != "localhost:5000/coverage:e2e" {
+		t.Errorf("Unexpected start event %+v", events[0])
+	}
+	if finished, ok := events[1].(*PushFinished); !ok || !errors.Is(finished.Err, ErrArtifactPush) || finished.Result != nil {
+		t.Errorf("Unexpected finish event %+v", events[1])
+	}
+}
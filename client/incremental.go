@@ -0,0 +1,231 @@
+package coverageclient
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CoverageDeltaType is the media type of streamed counters sent as changes against counters
+// received earlier, see applyCountersDelta
+const CoverageDeltaType = "application/x-coverage-delta"
+
+// Headers of incremental counter transfers: requests list the snapshots whose counters the client
+// holds, counters parts name their snapshot, digest and, for deltas, the base snapshot
+const (
+	coverageBaseHeader     = "X-Coverage-Base"
+	coverageSnapshotHeader = "X-Coverage-Snapshot"
+	coverageDigestHeader   = "X-Coverage-Digest"
+)
+
+// maxCounterBases bounds the coverage servers whose last counters the client remembers
+const maxCounterBases = 64
+
+// Operations of CoverageDeltaType data
+const (
+	deltaCopy    = 'C' // Base offset and length (uvarints): copy bytes of the base counters
+	deltaLiteral = 'L' // Length (uvarint) and data: bytes to insert
+)
+
+// errStaleCounterBase reports counters changes that couldn't be applied, e.g. because the base
+// file was removed or modified since; the counters are requested in full instead, which replaces
+// the base
+var errStaleCounterBase = errors.New("stale counters base")
+
+// SetIncrementalTransfer enables or disables incremental transfers. When enabled, the client tells
+// coverage servers which counters and meta-data it received before; they send only the changed
+// parts of the counters and leave out meta-data the client holds (default: enabled).
+func (c *CoverageClient) SetIncrementalTransfer(enabled bool) {
+	c.fullCounters = !enabled
+}
+
+// counterBases remembers the last counters file received from each coverage server process
+type counterBases struct {
+	mu    sync.Mutex
+	bases map[string]counterBase // By server instance
+	order []string               // Instances, least recently updated first
+}
+
+// counterBase is a counters file received from a coverage server
+type counterBase struct {
+	snapshot string // Snapshot ID: server instance and sequence number
+	path     string
+}
+
+// header returns the snapshot IDs of the counters files still on disk, for the base header
+func (b *counterBases) header() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []string
+	for _, instance := range b.order {
+		base := b.bases[instance]
+		if _, err := os.Stat(base.path); err == nil {
+			ids = append(ids, base.snapshot)
+		}
+	}
+	return strings.Join(ids, ", ")
+}
+
+// path returns the counters file of a snapshot
+func (b *counterBases) path(snapshot string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	base, ok := b.bases[snapshotInstance(snapshot)]
+	if !ok || base.snapshot != snapshot {
+		return "", false
+	}
+	return base.path, true
+}
+
+// remember records the counters file of a snapshot as its server's latest
+func (b *counterBases) remember(snapshot, path string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bases == nil {
+		b.bases = make(map[string]counterBase)
+	}
+	instance := snapshotInstance(snapshot)
+	if i := indexOf(b.order, instance); i >= 0 {
+		b.order = append(b.order[:i], b.order[i+1:]...)
+	}
+	b.order = append(b.order, instance)
+	b.bases[instance] = counterBase{snapshot: snapshot, path: path}
+	for len(b.order) > maxCounterBases {
+		delete(b.bases, b.order[0])
+		b.order = b.order[1:]
+	}
+}
+
+// snapshotInstance returns the server instance of a snapshot ID
+func snapshotInstance(snapshot string) string {
+	instance, _, _ := strings.Cut(snapshot, ".")
+	return instance
+}
+
+// indexOf returns the index of s in list, -1 if missing
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// countersReader returns the contents of a streamed counters part: its data, or the counters
+// rebuilt from the base file for CoverageDeltaType parts. Both are verified against the part's
+// digest, if any. The returned function releases the base file.
+func (c *CoverageClient) countersReader(part io.Reader, header textproto.MIMEHeader) (io.Reader, func(), error) {
+	r, release := part, func() {}
+	delta := header.Get("Content-Type") == CoverageDeltaType
+	if delta {
+		snapshot := header.Get(coverageBaseHeader)
+		path, ok := c.counterBases.path(snapshot)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: snapshot %s unknown", errStaleCounterBase, snapshot)
+		}
+		base, err := os.Open(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errStaleCounterBase, err)
+		}
+		if header.Get(coverageDigestHeader) == "" {
+			base.Close()
+			return nil, nil, fmt.Errorf("counters changes without digest")
+		}
+		r, release = &deltaReader{r: bufio.NewReader(part), base: base}, func() { base.Close() }
+	}
+
+	if digest := header.Get(coverageDigestHeader); digest != "" {
+		r = &digestReader{r: r, hash: sha256.New(), want: digest, delta: delta, base: header.Get(coverageBaseHeader)}
+	}
+	return r, release, nil
+}
+
+// deltaReader rebuilds counters from CoverageDeltaType operations and the base counters file
+type deltaReader struct {
+	r       *bufio.Reader
+	base    io.ReaderAt
+	pending io.Reader // Data of the current operation
+}
+
+func (d *deltaReader) Read(p []byte) (int, error) {
+	for {
+		if d.pending != nil {
+			n, err := d.pending.Read(p)
+			if err == io.EOF {
+				d.pending = nil
+				if n == 0 {
+					continue
+				}
+				err = nil
+			}
+			return n, err
+		}
+
+		op, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err // io.EOF after the last operation
+		}
+		switch op {
+		case deltaCopy:
+			offset, err := binary.ReadUvarint(d.r)
+			if err != nil {
+				return 0, fmt.Errorf("read counters changes: %w", err)
+			}
+			size, err := binary.ReadUvarint(d.r)
+			if err != nil {
+				return 0, fmt.Errorf("read counters changes: %w", err)
+			}
+			d.pending = io.NewSectionReader(d.base, int64(offset), int64(size))
+		case deltaLiteral:
+			size, err := binary.ReadUvarint(d.r)
+			if err != nil {
+				return 0, fmt.Errorf("read counters changes: %w", err)
+			}
+			d.pending = io.LimitReader(d.r, int64(size))
+		default:
+			return 0, fmt.Errorf("read counters changes: unknown operation %q", op)
+		}
+	}
+}
+
+// digestReader fails at the end of the data when its SHA-256 digest doesn't match
+type digestReader struct {
+	r     io.Reader
+	hash  hash.Hash
+	want  string
+	delta bool   // Data rebuilt from a base, a mismatch means the base is stale
+	base  string // Snapshot ID of the base
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hash.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(d.hash.Sum(nil)); got != d.want {
+			if d.delta {
+				return n, fmt.Errorf("%w: counters rebuilt from snapshot %s don't match their digest", errStaleCounterBase, d.base)
+			}
+			return n, fmt.Errorf("counters digest mismatch: expected %s, got %s", d.want, got)
+		}
+	}
+	return n, err
+}
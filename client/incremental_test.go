@@ -0,0 +1,149 @@
+package coverageclient
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// incrementalServer serves growing counters like the coverage server, as changes against the
+// previous snapshot when the client names it
+type incrementalServer struct {
+	snapshots []string // Counters by sequence number - 1
+	bases     []string // Base headers of the requests
+	corrupt   bool     // Send changes that don't rebuild the counters
+}
+
+func (s *incrementalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.bases = append(s.bases, r.Header.Get(coverageBaseHeader))
+	counters := strings.Repeat("counters ", len(s.snapshots)+1)
+	s.snapshots = append(s.snapshots, counters)
+	snapshot := fmt.Sprintf("inst.%d", len(s.snapshots))
+	digest := sha256.Sum256([]byte(counters))
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", CoverageStreamType+"; boundary="+mw.Boundary())
+	part, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Disposition": {`attachment; filename="covmeta.abc"`}})
+	part.Write([]byte("meta"))
+
+	header := textproto.MIMEHeader{
+		"Content-Disposition":  {fmt.Sprintf(`attachment; filename="covcounters.abc.1.%d"`, len(s.snapshots))},
+		coverageSnapshotHeader: {snapshot},
+		coverageDigestHeader:   {hex.EncodeToString(digest[:])},
+	}
+	previous := fmt.Sprintf("inst.%d", len(s.snapshots)-1)
+	if !strings.Contains(r.Header.Get(coverageBaseHeader), previous) {
+		part, _ = mw.CreatePart(header)
+		part.Write([]byte(counters))
+		mw.Close()
+		return
+	}
+
+	// Copy the previous counters and append the new ones
+	header.Set("Content-Type", CoverageDeltaType)
+	header.Set(coverageBaseHeader, previous)
+	base, offset := s.snapshots[len(s.snapshots)-2], 0
+	if s.corrupt {
+		offset = 1
+	}
+	delta := binary.AppendUvarint(binary.AppendUvarint([]byte{deltaCopy}, uint64(offset)), uint64(len(base)))
+	delta = binary.AppendUvarint(append(delta, deltaLiteral), uint64(len(counters)-len(base)))
+	delta = append(delta, counters[len(base):]...)
+	part, _ = mw.CreatePart(header)
+	part.Write(delta)
+	mw.Close()
+}
+
+func TestCollectCoverageFromURL_Incremental(t *testing.T) {
+	handler := &incrementalServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{outputDir: tempDir, httpClient: &http.Client{Timeout: 10 * time.Second}, counterBases: &counterBases{}}
+
+	for i, testName := range []string{"test-1", "test-2", "test-3"} {
+		if err := client.CollectCoverageFromURL(server.URL, testName); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testName, err)
+		}
+		content, err := os.ReadFile(filepath.Join(tempDir, testName, fmt.Sprintf("covcounters.abc.1.%d", i+1)))
+		if err != nil || string(content) != handler.snapshots[i] {
+			t.Errorf("%s: expected counters %q, got %q (%v)", testName, handler.snapshots[i], content, err)
+		}
+	}
+	if strings.Join(handler.bases, "|") != "|inst.1|inst.2" {
+		t.Errorf("Expected each request to name the previous snapshot, got %q", handler.bases)
+	}
+
+	// Changes that don't rebuild the counters are retried in full
+	handler.corrupt = true
+	if err := client.CollectCoverageFromURL(server.URL, "test-4"); err != nil {
+		t.Fatalf("Expected a full retry after a digest mismatch, got %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(tempDir, "test-4", "covcounters.abc.1.5"))
+	if string(content) != handler.snapshots[4] || handler.bases[4] != "" {
+		t.Errorf("Expected full counters without base, got %q (bases %q)", content, handler.bases)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(tempDir, "test-4", "covcounters.abc.1.4*")); len(leftovers) != 0 {
+		t.Errorf("Expected no counters from the failed attempt, got %v", leftovers)
+	}
+
+	// Bases whose files are gone aren't offered
+	os.RemoveAll(filepath.Join(tempDir, "test-4"))
+	handler.corrupt = false
+	if err := client.CollectCoverageFromURL(server.URL, "test-5"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if handler.bases[5] != "" {
+		t.Errorf("Expected no base after its file was removed, got %q", handler.bases[5])
+	}
+
+	client.SetIncrementalTransfer(false)
+	if err := client.CollectCoverageFromURL(server.URL, "test-6"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if handler.bases[6] != "" {
+		t.Errorf("Expected no base with incremental transfers disabled, got %q", handler.bases[6])
+	}
+}
+
+func TestCounterBases(t *testing.T) {
+	dir := t.TempDir()
+	bases := &counterBases{}
+	for i := range maxCounterBases + 1 {
+		path := filepath.Join(dir, fmt.Sprint(i))
+		os.WriteFile(path, nil, 0644)
+		bases.remember(fmt.Sprintf("inst%d.1", i), path)
+	}
+	bases.remember("inst5.2", filepath.Join(dir, "5"))
+
+	if _, ok := bases.path("inst0.1"); ok {
+		t.Error("Expected the least recently updated server to be forgotten")
+	}
+	if _, ok := bases.path("inst5.1"); ok {
+		t.Error("Expected only the latest snapshot of a server to be kept")
+	}
+	if path, ok := bases.path("inst5.2"); !ok || path != filepath.Join(dir, "5") {
+		t.Errorf("Expected the latest snapshot, got %q", path)
+	}
+	ids := strings.Split(bases.header(), ", ")
+	if len(ids) != maxCounterBases || ids[len(ids)-1] != "inst5.2" {
+		t.Errorf("Unexpected base header with %d IDs: %v", len(ids), ids[len(ids)-3:])
+	}
+
+	var unset *counterBases
+	unset.remember("inst.1", dir)
+	if unset.header() != "" {
+		t.Error("Expected clients without bases to send none")
+	}
+}
@@ -0,0 +1,161 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// unsafeNamePattern matches characters not allowed in file names, Codecov flags and the like
+var unsafeNamePattern = regexp.MustCompile(`[^\w.\-]`)
+
+// sanitizeName replaces characters other than letters, digits, '_', '.' and '-' with '_'
+func sanitizeName(name string) string {
+	return unsafeNamePattern.ReplaceAllString(name, "_")
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnitOptions configures WriteCoverageJUnit
+type JUnitOptions struct {
+	CoverageReportOptions
+	Thresholds CoverageThresholds // Rules turned into test cases; MinPackage makes package cases fail
+	Path       string             // Output file (default: junit_coverage_<test>.xml in the test directory)
+}
+
+// WriteCoverageJUnit writes the coverage gate of a processed test as JUnit XML, so CI systems
+// that only visualize JUnit show which packages or rules failed. Every package is a test case
+// failing below Thresholds.MinPackage, and every configured report-wide threshold is a test case
+// (skipped when the report lacks a baseline or patch coverage). It returns whether all cases passed.
+func (c *CoverageClient) WriteCoverageJUnit(ctx context.Context, opts JUnitOptions) (bool, error) {
+	if opts.Path == "" {
+		opts.Path = filepath.Join(c.outputDir, opts.TestName, fmt.Sprintf("junit_coverage_%s.xml", sanitizeName(opts.TestName)))
+	}
+
+	report, err := c.BuildCoverageReport(ctx, opts.CoverageReportOptions)
+	if err != nil {
+		return false, err
+	}
+
+	suites := []junitTestSuite{coverageJUnitSuite(report, opts.Thresholds)}
+	if rules := thresholdJUnitSuite(report, opts.Thresholds); len(rules.Cases) > 0 {
+		suites = append(suites, rules)
+	}
+	if err := writeJUnit(opts.Path, suites...); err != nil {
+		return false, err
+	}
+
+	failures := opts.Thresholds.Check(report)
+	c.logf("📝 JUnit coverage report written: %s (%d failure(s))\n", opts.Path, len(failures))
+	return len(failures) == 0, nil
+}
+
+// coverageJUnitSuite reports every package of a coverage report as a test case,
+// failing when it is below thresholds.MinPackage
+func coverageJUnitSuite(report *CoverageReport, thresholds CoverageThresholds) junitTestSuite {
+	suite := junitTestSuite{
+		Name: "coverage/" + report.TestName,
+		Properties: []junitProperty{
+			{Name: "coverage.total", Value: fmt.Sprintf("%.1f", report.Total.Percent())},
+			{Name: "coverage.statements", Value: fmt.Sprint(report.Total.Statements)},
+			{Name: "coverage.covered", Value: fmt.Sprint(report.Total.Covered)},
+		},
+	}
+	for _, pkg := range report.Packages {
+		tc := junitTestCase{
+			Name:      pkg.Package,
+			ClassName: suite.Name,
+			SystemOut: "coverage: " + formatStats(pkg.Coverage),
+		}
+		if failure := thresholds.packageFailure(pkg); failure != "" {
+			tc.Failure = &junitFailure{Message: failure, Text: failure}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return suite
+}
+
+// thresholdJUnitSuite reports every configured report-wide threshold rule as a test case
+func thresholdJUnitSuite(report *CoverageReport, thresholds CoverageThresholds) junitTestSuite {
+	suite := junitTestSuite{Name: "coverage-thresholds/" + report.TestName}
+	for _, r := range thresholds.results(report) {
+		tc := junitTestCase{Name: r.name, ClassName: suite.Name}
+		switch {
+		case r.failure != "":
+			tc.Failure = &junitFailure{Message: r.failure, Text: r.failure}
+		case r.skipped != "":
+			tc.Skipped = &junitSkipped{Message: r.skipped}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return suite
+}
+
+// writeJUnit writes the suites as a JUnit XML report, filling in the test and failure counts
+func writeJUnit(path string, suites ...junitTestSuite) error {
+	report := junitTestSuites{}
+	for _, suite := range suites {
+		suite.Tests, suite.Failures, suite.Skipped = len(suite.Cases), 0, 0
+		for _, tc := range suite.Cases {
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write JUnit report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCoverageJUnit(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	client := &CoverageClient{outputDir: outputDir}
+	passed, err := client.WriteCoverageJUnit(context.Background(), JUnitOptions{
+		CoverageReportOptions: CoverageReportOptions{TestName: "e2e"},
+		Thresholds:            CoverageThresholds{MinTotal: 60, MinPatch: 80, MinPackage: 50},
+	})
+	if err != nil {
+		t.Fatalf("WriteCoverageJUnit failed: %v", err)
+	}
+	if passed {
+		t.Error("Expected gate to fail for the package below 50%")
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "e2e", "junit_coverage_e2e.xml"))
+	if err != nil {
+		t.Fatalf("JUnit report not written: %v", err)
+	}
+	var junit junitTestSuites
+	if err := xml.Unmarshal(data, &junit); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	if len(junit.Suites) != 2 || junit.Tests != 4 || junit.Failures != 1 {
+		t.Fatalf("Unexpected JUnit report: %+v", junit)
+	}
+
+	packages := junit.Suites[0]
+	if packages.Cases[0].Failure == nil || packages.Cases[1].Failure != nil {
+		t.Errorf("Expected only github.com/example/app to fail, got %+v", packages.Cases)
+	}
+
+	rules := junit.Suites[1]
+	if rules.Name != "coverage-thresholds/e2e" || len(rules.Cases) != 2 {
+		t.Fatalf("Unexpected threshold suite: %+v", rules)
+	}
+	if rules.Cases[0].Name != "total coverage >= 60.0%" || rules.Cases[0].Failure != nil {
+		t.Errorf("Expected passing total rule, got %+v", rules.Cases[0])
+	}
+	if rules.Cases[1].Skipped == nil || rules.Skipped != 1 {
+		t.Errorf("Expected patch rule to be skipped without base ref, got %+v", rules.Cases[1])
+	}
+}
@@ -0,0 +1,204 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// konfluxTimestampLayout is the ISO 8601 format of TEST_OUTPUT timestamps (date -u --iso-8601=seconds)
+const konfluxTimestampLayout = "2006-01-02T15:04:05-07:00"
+
+// Konflux TEST_OUTPUT result values
+const (
+	KonfluxSuccess = "SUCCESS"
+	KonfluxFailure = "FAILURE"
+	KonfluxWarning = "WARNING"
+	KonfluxSkipped = "SKIPPED"
+)
+
+// CoverageComponent is an application component whose coverage was collected by a test
+type CoverageComponent struct {
+	Name string      // Component name, e.g. from the snapshot (default: the container name in metadata.json, else the test name)
+	Test string      // Test the coverage was collected and processed under
+	Push *PushResult // Pushed coverage artifact (optional)
+}
+
+// CoverageResultsOptions configures WriteCoverageResults. Result names must be declared in the
+// Task; empty names use the defaults, "-" skips a result.
+type CoverageResultsOptions struct {
+	ResultsDir string             // Directory holding $(results.<name>.path) files (default: /tekton/results)
+	Result     string             // Result for the coverage document (default: COVERAGE_RESULTS)
+	TestOutput string             // Result for the Konflux TEST_OUTPUT summary (default: TEST_OUTPUT)
+	Thresholds CoverageThresholds // Components below the thresholds count as failures in TEST_OUTPUT
+}
+
+// CoverageResults is the coverage document of a pipeline run, see WriteCoverageResults
+type CoverageResults struct {
+	Result     string                     `json:"result"` // SUCCESS, FAILURE, WARNING or SKIPPED
+	Timestamp  string                     `json:"timestamp"`
+	Coverage   CoverageResultStats        `json:"coverage"` // Total over all components
+	Components []ComponentCoverageResults `json:"components"`
+	CI         *CIMetadata                `json:"ci,omitempty"`
+}
+
+// CoverageResultStats is a coverage summary with its percentage
+type CoverageResultStats struct {
+	CoverageStats
+	Percent float64 `json:"percent"`
+}
+
+// ComponentCoverageResults is the coverage of one component
+type ComponentCoverageResults struct {
+	Name       string                   `json:"name"`
+	Test       string                   `json:"test"`
+	Image      string                   `json:"image,omitempty"`
+	Coverage   *CoverageResultStats     `json:"coverage,omitempty"` // nil when the test has no processed coverage
+	Artifact   *ArtifactResult          `json:"artifact,omitempty"`
+	Collection *KonfluxCollectionResult `json:"collection,omitempty"`
+	Failures   []string                 `json:"failures,omitempty"` // Thresholds the component doesn't meet
+	Error      string                   `json:"error,omitempty"`    // Why the coverage is missing
+}
+
+// ArtifactResult identifies a pushed coverage artifact
+type ArtifactResult struct {
+	Reference string `json:"reference"` // registry/repository:tag
+	URI       string `json:"uri"`       // registry/repository
+	Digest    string `json:"digest"`
+}
+
+// KonfluxCollectionResult describes where and how coverage was collected, from metadata.json
+type KonfluxCollectionResult struct {
+	Pod         string           `json:"pod"`
+	Namespace   string           `json:"namespace"`
+	Container   string           `json:"container,omitempty"`
+	CollectedAt string           `json:"collected_at"`
+	Method      CollectionMethod `json:"method,omitempty"`
+	Warnings    []string         `json:"warnings,omitempty"` // From metadata.json, e.g. a binary change between collections
+}
+
+// konfluxTestOutput is the TEST_OUTPUT result Konflux integration tests report to the pipeline run
+type konfluxTestOutput struct {
+	Result    string `json:"result"`
+	Timestamp string `json:"timestamp"`
+	Failures  int    `json:"failures"`
+	Successes int    `json:"successes"`
+	Warnings  int    `json:"warnings"`
+	Note      string `json:"note,omitempty"`
+}
+
+// WriteCoverageResults writes a JSON document with the total and per-component coverage, pushed
+// artifacts and collection metadata as a Tekton result (COVERAGE_RESULTS), plus a TEST_OUTPUT
+// summary in the format Konflux integration tests attach to the pipeline run. Components
+// without processed coverage or with collection warnings (e.g. the binary changed between
+// collections) count as warnings, components below the thresholds as failures.
+func (c *CoverageClient) WriteCoverageResults(ctx context.Context, components []CoverageComponent, opts CoverageResultsOptions) (*CoverageResults, error) {
+	if opts.ResultsDir == "" {
+		opts.ResultsDir = defaultTektonResultsDir
+	}
+
+	results := &CoverageResults{
+		Timestamp:  time.Now().UTC().Format(konfluxTimestampLayout),
+		Components: []ComponentCoverageResults{},
+		CI:         DetectCI(),
+	}
+	output := konfluxTestOutput{Timestamp: results.Timestamp}
+	var total CoverageStats
+	for _, component := range components {
+		r := c.componentResults(ctx, component, opts.Thresholds)
+		switch {
+		case r.Coverage == nil:
+			output.Warnings++
+		case len(r.Failures) > 0:
+			output.Failures++
+		case r.Collection != nil && len(r.Collection.Warnings) > 0:
+			output.Warnings++
+		default:
+			output.Successes++
+		}
+		if r.Coverage != nil {
+			total.Statements += r.Coverage.Statements
+			total.Covered += r.Coverage.Covered
+		}
+		results.Components = append(results.Components, r)
+	}
+	results.Coverage = CoverageResultStats{CoverageStats: total, Percent: total.Percent()}
+
+	switch {
+	case output.Failures > 0:
+		output.Result = KonfluxFailure
+	case output.Successes == 0:
+		output.Result = KonfluxSkipped
+	case output.Warnings > 0:
+		output.Result = KonfluxWarning
+	default:
+		output.Result = KonfluxSuccess
+	}
+	output.Note = fmt.Sprintf("Coverage %.1f%% (%d/%d statements) across %d component(s)",
+		total.Percent(), total.Covered, total.Statements, len(components))
+	results.Result = output.Result
+
+	for name, value := range map[string]any{
+		resultName(opts.Result, "COVERAGE_RESULTS"): results,
+		resultName(opts.TestOutput, "TEST_OUTPUT"):  output,
+	} {
+		if name == "-" {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal result %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(opts.ResultsDir, name), data, 0644); err != nil {
+			return nil, fmt.Errorf("write Tekton result %s: %w", name, err)
+		}
+		c.logf("📝 Tekton result %s written\n", name)
+	}
+
+	c.logf("📊 %s: %s\n", output.Result, output.Note)
+	return results, nil
+}
+
+// componentResults collects the coverage, artifact and collection metadata of a component
+func (c *CoverageClient) componentResults(ctx context.Context, component CoverageComponent, thresholds CoverageThresholds) ComponentCoverageResults {
+	r := ComponentCoverageResults{Name: component.Name, Test: component.Test}
+
+	if metadata, err := c.readPodMetadata(component.Test); err == nil {
+		r.Image = metadata.Container.Image
+		r.Collection = &KonfluxCollectionResult{
+			Pod:         metadata.PodName,
+			Namespace:   metadata.Namespace,
+			Container:   metadata.Container.Name,
+			CollectedAt: metadata.CollectedAt,
+			Method:      metadata.CollectionMethod,
+			Warnings:    metadata.Warnings,
+		}
+		if r.Name == "" {
+			r.Name = metadata.Container.Name
+		}
+	}
+	if r.Name == "" {
+		r.Name = component.Test
+	}
+
+	if component.Push != nil {
+		uri := component.Push.Reference
+		if i := lastTagSeparator(uri); i >= 0 {
+			uri = uri[:i]
+		}
+		r.Artifact = &ArtifactResult{Reference: component.Push.Reference, URI: uri, Digest: component.Push.Digest}
+	}
+
+	report, err := c.BuildCoverageReport(ctx, CoverageReportOptions{TestName: component.Test})
+	if err != nil {
+		c.logf("⚠️  No coverage for component %s: %v\n", r.Name, err)
+		r.Error = err.Error()
+		return r
+	}
+	r.Coverage = &CoverageResultStats{CoverageStats: report.Total, Percent: report.Total.Percent()}
+	r.Failures = thresholds.Check(report)
+	return r
+}
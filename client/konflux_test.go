@@ -0,0 +1,108 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCoverageResults(t *testing.T) {
+	clearCIEnv(t)
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "api-e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "api-e2e", "coverage.out"), []byte(testProfile), 0644)
+	os.WriteFile(filepath.Join(outputDir, "api-e2e", "metadata.json"), []byte(`{
+		"pod_name": "api-7d9f", "namespace": "e2e", "collected_at": "2025-01-01T00:00:00Z",
+		"container": {"name": "api", "image": "quay.io/org/api@sha256:123"}, "collection_method": "port-forward"
+	}`), 0644)
+	os.MkdirAll(filepath.Join(outputDir, "ui-e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "ui-e2e", "coverage.out"), []byte(testProfile), 0644)
+	os.WriteFile(filepath.Join(outputDir, "ui-e2e", "metadata.json"), []byte(`{
+		"pod_name": "ui-5c4b", "namespace": "e2e", "container": {"name": "ui"},
+		"warnings": ["binary changed between collections"]
+	}`), 0644)
+	resultsDir := t.TempDir()
+
+	client := &CoverageClient{outputDir: outputDir}
+	results, err := client.WriteCoverageResults(context.Background(), []CoverageComponent{
+		{Test: "api-e2e", Push: &PushResult{Reference: "quay.io/org/coverage:api-e2e", Digest: "sha256:abc"}},
+		{Name: "worker", Test: "worker-e2e"},
+		{Test: "ui-e2e"},
+	}, CoverageResultsOptions{ResultsDir: resultsDir, Thresholds: CoverageThresholds{MinTotal: 50}})
+	if err != nil {
+		t.Fatalf("WriteCoverageResults failed: %v", err)
+	}
+
+	if results.Result != KonfluxWarning {
+		t.Errorf("Expected %s with a component missing coverage, got %s", KonfluxWarning, results.Result)
+	}
+	if results.Coverage.Statements != 18 || results.Coverage.Covered != 12 {
+		t.Errorf("Unexpected total coverage: %+v", results.Coverage)
+	}
+
+	api := results.Components[0]
+	if api.Name != "api" || api.Image != "quay.io/org/api@sha256:123" {
+		t.Errorf("Expected component name and image from metadata, got %q, %q", api.Name, api.Image)
+	}
+	if api.Artifact == nil || api.Artifact.URI != "quay.io/org/coverage" || api.Artifact.Digest != "sha256:abc" {
+		t.Errorf("Unexpected artifact: %+v", api.Artifact)
+	}
+	if api.Collection == nil || api.Collection.Pod != "api-7d9f" || api.Collection.Method != MethodPortForward {
+		t.Errorf("Unexpected collection metadata: %+v", api.Collection)
+	}
+	if worker := results.Components[1]; worker.Coverage != nil || worker.Error == "" {
+		t.Errorf("Expected worker without coverage to carry an error, got %+v", worker)
+	}
+	if ui := results.Components[2]; ui.Collection == nil || len(ui.Collection.Warnings) != 1 {
+		t.Errorf("Expected collection warnings from metadata, got %+v", ui.Collection)
+	}
+
+	var document CoverageResults
+	data, err := os.ReadFile(filepath.Join(resultsDir, "COVERAGE_RESULTS"))
+	if err != nil {
+		t.Fatalf("COVERAGE_RESULTS not written: %v", err)
+	}
+	if err := json.Unmarshal(data, &document); err != nil || len(document.Components) != 3 {
+		t.Errorf("Unexpected COVERAGE_RESULTS %s: %v", data, err)
+	}
+
+	var output map[string]any
+	data, err = os.ReadFile(filepath.Join(resultsDir, "TEST_OUTPUT"))
+	if err != nil {
+		t.Fatalf("TEST_OUTPUT not written: %v", err)
+	}
+	json.Unmarshal(data, &output)
+	if output["result"] != KonfluxWarning || output["successes"] != 1.0 || output["warnings"] != 2.0 || output["failures"] != 0.0 {
+		t.Errorf("Unexpected TEST_OUTPUT: %s", data)
+	}
+}
+
+func TestWriteCoverageResults_BelowThreshold(t *testing.T) {
+	clearCIEnv(t)
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	resultsDir := t.TempDir()
+
+	client := &CoverageClient{outputDir: outputDir}
+	results, err := client.WriteCoverageResults(context.Background(), []CoverageComponent{{Test: "e2e"}},
+		CoverageResultsOptions{ResultsDir: resultsDir, TestOutput: "-", Thresholds: CoverageThresholds{MinPackage: 50}})
+	if err != nil {
+		t.Fatalf("WriteCoverageResults failed: %v", err)
+	}
+
+	if results.Result != KonfluxFailure {
+		t.Errorf("Expected %s, got %s", KonfluxFailure, results.Result)
+	}
+	if failures := results.Components[0].Failures; len(failures) != 1 {
+		t.Errorf("Expected one package failure, got %v", failures)
+	}
+	if results.Components[0].Name != "e2e" {
+		t.Errorf("Expected component name to default to the test name, got %q", results.Components[0].Name)
+	}
+	if _, err := os.Stat(filepath.Join(resultsDir, "TEST_OUTPUT")); err == nil {
+		t.Error("Expected skipped TEST_OUTPUT not to be written")
+	}
+}
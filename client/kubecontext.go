@@ -0,0 +1,64 @@
+package coverageclient
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// loadRESTConfig builds a REST config for the given kubeconfig context. KUBECONFIG may list
+// several files (merged like kubectl does, separated by ";" on Windows); without it
+// ~/.kube/config is used, %USERPROFILE%\.kube\config on Windows. When no context is requested
+// and no kubeconfig is usable, the in-cluster config is used instead.
+func loadRESTConfig(kubeContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err == nil {
+		return config, nil
+	}
+	if kubeContext != "" {
+		return nil, fmt.Errorf("load kubeconfig context %q: %w", kubeContext, err)
+	}
+
+	// Try in-cluster config
+	config, err = rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("build kubernetes config: %w", err)
+	}
+	return config, nil
+}
+
+// ForContext returns a copy of the client that talks to the cluster of the given kubeconfig context,
+// for suites that span a hub cluster and several spoke clusters. Output directory, filters and
+// collection settings are shared with c. An empty namespace keeps c's namespace.
+func (c *CoverageClient) ForContext(kubeContext, namespace string) (*CoverageClient, error) {
+	config, err := loadRESTConfig(kubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamic client: %w", err)
+	}
+
+	clone := *c
+	clone.clientset = clientset
+	clone.dynamicClient = dynamicClient
+	clone.restConfig = config
+	clone.portForward = &portForwardTransport{}
+	if namespace != "" {
+		clone.namespace = namespace
+	}
+	return &clone, nil
+}
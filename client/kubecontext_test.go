@@ -0,0 +1,69 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKubeconfig writes a kubeconfig with a single context pointing at server
+func writeKubeconfig(t *testing.T, dir, name, server string) string {
+	t.Helper()
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: ` + name + `
+  cluster:
+    server: ` + server + `
+users:
+- name: ` + name + `
+  user:
+    token: test
+contexts:
+- name: ` + name + `
+  context:
+    cluster: ` + name + `
+    user: ` + name + `
+    namespace: ` + name + `-ns
+current-context: ` + name + `
+`
+	path := filepath.Join(dir, name+".yaml")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	return path
+}
+
+func TestForContext_MultipleKubeconfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	hub := writeKubeconfig(t, dir, "hub", "https://hub.example.com:6443")
+	spoke := writeKubeconfig(t, dir, "spoke", "https://spoke.example.com:6443")
+	t.Setenv("KUBECONFIG", hub+string(os.PathListSeparator)+spoke)
+
+	client, err := NewClient("default", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if client.restConfig.Host != "https://hub.example.com:6443" {
+		t.Errorf("Expected current context (hub) to be used, got host %s", client.restConfig.Host)
+	}
+
+	spokeClient, err := client.ForContext("spoke", "")
+	if err != nil {
+		t.Fatalf("ForContext failed: %v", err)
+	}
+	if spokeClient.restConfig.Host != "https://spoke.example.com:6443" {
+		t.Errorf("Expected spoke host, got %s", spokeClient.restConfig.Host)
+	}
+	if spokeClient.namespace != "default" || spokeClient.outputDir != client.outputDir {
+		t.Errorf("Expected namespace and output dir to be kept, got %s, %s", spokeClient.namespace, spokeClient.outputDir)
+	}
+	if client.restConfig.Host != "https://hub.example.com:6443" {
+		t.Error("ForContext must not modify the original client")
+	}
+
+	if _, err := client.ForContext("missing", ""); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("Expected error for unknown context, got %v", err)
+	}
+}
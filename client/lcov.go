@@ -0,0 +1,80 @@
+package coverageclient
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LineCounts returns the hit count of every line per profile file. A line in several blocks gets
+// the highest count, so it is covered when any of its statements ran.
+func (p *Profile) LineCounts() map[string]map[int]int {
+	files := make(map[string]map[int]int)
+	for _, b := range p.Blocks {
+		lines := files[b.File]
+		if lines == nil {
+			lines = make(map[int]int)
+			files[b.File] = lines
+		}
+		for line := b.StartLine; line <= b.EndLine; line++ {
+			if count, ok := lines[line]; !ok || b.Count > count {
+				lines[line] = b.Count
+			}
+		}
+	}
+	return files
+}
+
+// WriteLCOV writes the profile as an lcov tracefile (lcov.info), one record per file with the
+// hit count of each line, as read by genhtml, Coveralls and editor coverage plugins
+func (p *Profile) WriteLCOV(w io.Writer, testName string) error {
+	var b strings.Builder
+	files := p.LineCounts()
+	names := make([]string, 0, len(files))
+	for file := range files {
+		names = append(names, file)
+	}
+	sort.Strings(names)
+	for _, file := range names {
+		lines := make([]int, 0, len(files[file]))
+		for line := range files[file] {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		fmt.Fprintf(&b, "TN:%s\nSF:%s\n", testName, file)
+		hit := 0
+		for _, line := range lines {
+			count := files[file][line]
+			fmt.Fprintf(&b, "DA:%d,%d\n", line, count)
+			if count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ExportLCOV writes the report of a test (the filtered one if it exists) as <test>/lcov.info and
+// returns its path. Generate the report with path remapping for tools resolving the source files,
+// e.g. genhtml or VS Code's Coverage Gutters.
+func (c *CoverageClient) ExportLCOV(testName string) (string, error) {
+	profile, err := c.LoadProfile(testName)
+	if err != nil {
+		return "", fmt.Errorf("export lcov: %w", err)
+	}
+	var b strings.Builder
+	if err := profile.WriteLCOV(&b, testName); err != nil {
+		return "", fmt.Errorf("export lcov: %w", err)
+	}
+	path := filepath.Join(c.outputDir, testName, "lcov.info")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("write lcov report: %w", err)
+	}
+	c.logf("📝 LCOV report written: %s\n", path)
+	return path, nil
+}
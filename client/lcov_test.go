@@ -0,0 +1,50 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineCounts_Overlap(t *testing.T) {
+	// A line shared by an uncovered and a covered block is covered
+	profile, _ := ParseProfile(strings.NewReader("mode: count\na.go:1.1,3.2 1 0\na.go:3.2,4.2 1 5\n"))
+	lines := profile.LineCounts()["a.go"]
+	if lines[1] != 0 || lines[3] != 5 || lines[4] != 5 {
+		t.Errorf("Expected the highest count per line, got %v", lines)
+	}
+}
+
+func TestExportLCOV(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	os.MkdirAll(filepath.Join(client.outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(client.outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	os.WriteFile(filepath.Join(client.outputDir, "e2e", "coverage_filtered.out"), []byte("mode: atomic\ngithub.com/example/app/main.go:10.2,12.3 2 1\ngithub.com/example/app/main.go:14.2,15.3 3 0\n"), 0644)
+
+	path, err := client.ExportLCOV("e2e")
+	if err != nil {
+		t.Fatalf("ExportLCOV failed: %v", err)
+	}
+	if path != filepath.Join(client.outputDir, "e2e", "lcov.info") {
+		t.Errorf("Unexpected path %s", path)
+	}
+	want := `TN:e2e
+SF:github.com/example/app/main.go
+DA:10,1
+DA:11,1
+DA:12,1
+DA:14,0
+DA:15,0
+LF:5
+LH:3
+end_of_record
+`
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("Expected the filtered report as lcov, got:\n%s", data)
+	}
+
+	if _, err := client.ExportLCOV("missing"); err == nil {
+		t.Error("Expected an error for a missing report")
+	}
+}
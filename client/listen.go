@@ -0,0 +1,79 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/psturc/go-coverage-http/collector"
+)
+
+// CoverageListener receives coverage pushed by coverage servers while the suite runs, like the
+// in-cluster collector does. Pods being replaced by a rollout push their counters to it from a
+// preStop hook (see EnableCoverageOptions.FlushTarget), so their coverage isn't lost when the test
+// collects from the pods running at the end.
+type CoverageListener struct {
+	client  *CoverageClient
+	server  *http.Server
+	addr    net.Addr
+	dataDir string
+}
+
+// ListenForCoverage starts a CoverageListener on addr, e.g. ":8080" ("host:0" picks a free port).
+// Pods must be able to reach it: run the test inside the cluster behind a Service, or expose the
+// runner's address to them. Close it when the suite is done.
+func (c *CoverageClient) ListenForCoverage(addr string) (*CoverageListener, error) {
+	dataDir, err := os.MkdirTemp("", "coverage-listener-")
+	if err != nil {
+		return nil, fmt.Errorf("create listener data directory: %w", err)
+	}
+	store, err := collector.NewServer(dataDir)
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, fmt.Errorf("listen for coverage: %w", err)
+	}
+
+	l := &CoverageListener{
+		client:  c,
+		server:  &http.Server{Handler: store.Handler()},
+		addr:    ln.Addr(),
+		dataDir: dataDir,
+	}
+	go l.server.Serve(ln)
+	c.logf("👂 Listening for pushed coverage on %s\n", l.addr)
+	return l, nil
+}
+
+// Addr returns the address the listener accepts pushes on
+func (l *CoverageListener) Addr() net.Addr {
+	return l.addr
+}
+
+// Port returns the port the listener accepts pushes on
+func (l *CoverageListener) Port() int {
+	return l.addr.(*net.TCPAddr).Port
+}
+
+// Collect saves the coverage pushed for suite ("default" unless the pods set COVERAGE_SUITE or
+// the flush request names one) into the test directory. Pods that pushed several times count once,
+// with their latest snapshot.
+func (l *CoverageListener) Collect(ctx context.Context, suite, testName string) error {
+	return l.client.CollectCoverageFromCollector(ctx, fmt.Sprintf("http://localhost:%d", l.Port()), suite, testName)
+}
+
+// Close stops the listener and drops the coverage it received
+func (l *CoverageListener) Close() error {
+	err := l.server.Close()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return errors.Join(err, os.RemoveAll(l.dataDir))
+}
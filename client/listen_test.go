@@ -0,0 +1,59 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/psturc/go-coverage-http/collector"
+)
+
+func TestListenForCoverage(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: http.DefaultClient, quiet: true}
+	listener, err := client.ListenForCoverage("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenForCoverage failed: %v", err)
+	}
+
+	// A pod terminated by a rollout pushes from its preStop hook
+	payload, _ := json.Marshal(collector.PushRequest{
+		MetaFilename:     "covmeta." + newBinaryHash,
+		MetaData:         base64.StdEncoding.EncodeToString(covmetaFile(newBinaryHash)),
+		CountersFilename: "covcounters." + newBinaryHash + ".1.100",
+		CountersData:     base64.StdEncoding.EncodeToString(covcountersFile(newBinaryHash)),
+		Suite:            "rollout",
+		PodName:          "app-old",
+	})
+	resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/push", listener.Port()), "application/json", bytes.NewReader(payload))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Push failed: %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	if err := listener.Collect(context.Background(), "rollout", "e2e"); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "covcounters."+newBinaryHash+".1.100")); err != nil {
+		t.Errorf("Expected the pushed counters in the test directory: %v", err)
+	}
+	if err := listener.Collect(context.Background(), "other", "other"); err == nil {
+		t.Error("Expected an error for a suite nothing was pushed for")
+	}
+
+	dataDir := listener.dataDir
+	if err := listener.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the received coverage to be dropped, got %v", err)
+	}
+	if _, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/health", listener.Port())); err == nil {
+		t.Error("Expected the listener to be stopped")
+	}
+}
@@ -0,0 +1,265 @@
+// Package localcluster sets up local kind or k3d clusters for e2e runs: it creates the cluster
+// (or reuses an existing one), loads the instrumented images, applies the manifests, enables
+// coverage on the Deployment and returns a CoverageClient for the cluster once it's rolled out.
+//
+//	cluster, err := localcluster.Setup(ctx, localcluster.Options{
+//		Images:     []string{"localhost/my-app:coverage"},
+//		Manifests:  []string{"k8s-deployment.yaml"},
+//		Namespace:  "my-app",
+//		Deployment: "my-app",
+//	})
+//	defer cluster.Delete(ctx)
+//	podName, err := cluster.Client.GetPodName("app=my-app")
+//
+// The kind, k3d and kubectl CLIs do the cluster work, like in a developer's shell.
+package localcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+// Provider is the local Kubernetes distribution
+type Provider string
+
+const (
+	ProviderKind Provider = "kind"
+	ProviderK3d  Provider = "k3d"
+)
+
+const (
+	// DefaultName is the cluster name used when Options names none
+	DefaultName = "coverage-e2e"
+	// DefaultReadyTimeout bounds waiting for the Deployment's rollout
+	DefaultReadyTimeout = 5 * time.Minute
+)
+
+// Options configures Setup
+type Options struct {
+	Provider Provider // Default: ProviderKind
+	Name     string   // Cluster name (default: DefaultName)
+	Config   string   // Cluster config file, e.g. kind-config.yaml (default: the provider's defaults)
+	Recreate bool     // Delete an existing cluster of that name first (default: reuse it)
+
+	Images     []string                             // Local images to load into the cluster
+	Manifests  []string                             // Files or directories applied with kubectl apply -f
+	Namespace  string                               // Namespace of the manifests and the client (default: "default")
+	Deployment string                               // Deployment to enable coverage on and wait for (default: none)
+	Coverage   coverageclient.EnableCoverageOptions // How the Deployment is patched, see EnableCoverage
+
+	OutputDir    string        // Coverage output directory of the client (default: $COVERAGE_OUTPUT_DIR or coverage-output)
+	ReadyTimeout time.Duration // Default: DefaultReadyTimeout
+
+	Kind    string    // kind binary (default: kind from $PATH)
+	K3d     string    // k3d binary (default: k3d from $PATH)
+	Kubectl string    // kubectl binary (default: kubectl from $PATH)
+	Output  io.Writer // Receives the CLIs' progress output (default: discarded)
+}
+
+// Cluster is a local cluster set up by Setup
+type Cluster struct {
+	Name        string
+	Provider    Provider
+	KubeContext string                         // kubeconfig context of the cluster, e.g. "kind-coverage-e2e"
+	Client      *coverageclient.CoverageClient // Client for the cluster and Options.Namespace
+	opts        Options
+}
+
+// Setup creates or reuses the cluster, loads the images, applies the manifests, enables coverage
+// on the Deployment and waits until it's rolled out
+func Setup(ctx context.Context, opts Options) (*Cluster, error) {
+	opts = withDefaults(opts)
+	cluster := &Cluster{Name: opts.Name, Provider: opts.Provider, opts: opts}
+	switch opts.Provider {
+	case ProviderKind:
+		cluster.KubeContext = "kind-" + opts.Name
+	case ProviderK3d:
+		cluster.KubeContext = "k3d-" + opts.Name
+	default:
+		return nil, fmt.Errorf("unknown cluster provider: %s", opts.Provider)
+	}
+
+	exists, err := cluster.exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists && opts.Recreate {
+		if err := cluster.Delete(ctx); err != nil {
+			return nil, err
+		}
+		exists = false
+	}
+	if !exists {
+		if err := cluster.create(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, image := range opts.Images {
+		if err := cluster.loadImage(ctx, image); err != nil {
+			return nil, err
+		}
+	}
+
+	namespace := fmt.Sprintf("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: %s\n", opts.Namespace)
+	if err := cluster.kubectl(ctx, strings.NewReader(namespace), "apply", "-f", "-"); err != nil {
+		return nil, fmt.Errorf("create namespace: %w", err)
+	}
+	for _, manifest := range opts.Manifests {
+		if err := cluster.kubectl(ctx, nil, "apply", "--namespace", opts.Namespace, "-f", manifest); err != nil {
+			return nil, fmt.Errorf("apply %s: %w", manifest, err)
+		}
+	}
+
+	if cluster.Client, err = coverageclient.NewClientForContext(cluster.KubeContext, opts.Namespace, opts.OutputDir); err != nil {
+		return nil, err
+	}
+	if opts.Deployment == "" {
+		return cluster, nil
+	}
+	if err := cluster.Client.EnableCoverage(ctx, opts.Deployment, opts.Coverage); err != nil {
+		return nil, err
+	}
+	if err := cluster.kubectl(ctx, nil, "rollout", "status", "--namespace", opts.Namespace,
+		"deployment/"+opts.Deployment, "--timeout", opts.ReadyTimeout.String()); err != nil {
+		return nil, fmt.Errorf("wait for deployment %s: %w", opts.Deployment, err)
+	}
+	return cluster, nil
+}
+
+// Delete deletes the cluster
+func (c *Cluster) Delete(ctx context.Context) error {
+	var err error
+	switch c.Provider {
+	case ProviderKind:
+		err = c.run(ctx, c.opts.Kind, nil, "delete", "cluster", "--name", c.Name)
+	case ProviderK3d:
+		err = c.run(ctx, c.opts.K3d, nil, "cluster", "delete", c.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("delete cluster %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Provider == "" {
+		opts.Provider = ProviderKind
+	}
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.ReadyTimeout <= 0 {
+		opts.ReadyTimeout = DefaultReadyTimeout
+	}
+	if opts.Kind == "" {
+		opts.Kind = "kind"
+	}
+	if opts.K3d == "" {
+		opts.K3d = "k3d"
+	}
+	if opts.Kubectl == "" {
+		opts.Kubectl = "kubectl"
+	}
+	if opts.Output == nil {
+		opts.Output = io.Discard
+	}
+	return opts
+}
+
+// exists reports whether a cluster of the name is running
+func (c *Cluster) exists(ctx context.Context) (bool, error) {
+	var out []byte
+	var err error
+	switch c.Provider {
+	case ProviderKind:
+		out, err = c.output(ctx, c.opts.Kind, "get", "clusters")
+	case ProviderK3d:
+		out, err = c.output(ctx, c.opts.K3d, "cluster", "list", "--no-headers")
+	}
+	if err != nil {
+		return false, fmt.Errorf("list clusters: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == c.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Cluster) create(ctx context.Context) error {
+	var err error
+	switch c.Provider {
+	case ProviderKind:
+		args := []string{"create", "cluster", "--name", c.Name, "--wait", "2m"}
+		if c.opts.Config != "" {
+			args = append(args, "--config", c.opts.Config)
+		}
+		err = c.run(ctx, c.opts.Kind, nil, args...)
+	case ProviderK3d:
+		args := []string{"cluster", "create", c.Name, "--wait"}
+		if c.opts.Config != "" {
+			args = append(args, "--config", c.opts.Config)
+		}
+		err = c.run(ctx, c.opts.K3d, nil, args...)
+	}
+	if err != nil {
+		return fmt.Errorf("create cluster %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+func (c *Cluster) loadImage(ctx context.Context, image string) error {
+	var err error
+	switch c.Provider {
+	case ProviderKind:
+		err = c.run(ctx, c.opts.Kind, nil, "load", "docker-image", image, "--name", c.Name)
+	case ProviderK3d:
+		err = c.run(ctx, c.opts.K3d, nil, "image", "import", image, "--cluster", c.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("load image %s: %w", image, err)
+	}
+	return nil
+}
+
+// kubectl runs kubectl against the cluster's context
+func (c *Cluster) kubectl(ctx context.Context, stdin io.Reader, args ...string) error {
+	return c.run(ctx, c.opts.Kubectl, stdin, append(args, "--context", c.KubeContext)...)
+}
+
+// run runs a CLI, streaming its output to Options.Output
+func (c *Cluster) run(ctx context.Context, name string, stdin io.Reader, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = c.opts.Output
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(c.opts.Output, &stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %w\nOutput: %s", name, args[0], err, stderr.String())
+	}
+	return nil
+}
+
+// output runs a CLI and returns its standard output
+func (c *Cluster) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w\nOutput: %s", name, args[0], err, stderr.String())
+	}
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package localcluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kind-e2e
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: kind-e2e
+  context:
+    cluster: kind-e2e
+    user: kind-e2e
+users:
+- name: kind-e2e
+  user:
+    token: fake
+`
+
+// fakeCLI writes a script recording its arguments to calls and printing stdout
+func fakeCLI(t *testing.T, dir, name, stdout string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLIs are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\necho \"" + name + " $@\" >> " + filepath.Join(dir, "calls") + "\n" +
+		"if [ \"$2\" = \"-\" ] || [ \"$3\" = \"-\" ]; then cat >> " + filepath.Join(dir, "stdin") + "; fi\n" +
+		"printf '" + stdout + "'\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readCalls(t *testing.T, dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestSetup_Kind(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600)
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	cluster, err := Setup(context.Background(), Options{
+		Name:      "e2e",
+		Config:    "kind-config.yaml",
+		Images:    []string{"localhost/app:coverage"},
+		Manifests: []string{"k8s-deployment.yaml"},
+		Namespace: "coverage-demo",
+		OutputDir: t.TempDir(),
+		Kind:      fakeCLI(t, dir, "kind", "other\\n"),
+		Kubectl:   fakeCLI(t, dir, "kubectl", ""),
+	})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if cluster.KubeContext != "kind-e2e" || cluster.Client == nil {
+		t.Errorf("Unexpected cluster %+v", cluster)
+	}
+
+	want := []string{
+		"kind get clusters",
+		"kind create cluster --name e2e --wait 2m --config kind-config.yaml",
+		"kind load docker-image localhost/app:coverage --name e2e",
+		"kubectl apply -f - --context kind-e2e",
+		"kubectl apply --namespace coverage-demo -f k8s-deployment.yaml --context kind-e2e",
+	}
+	if calls := readCalls(t, dir); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+	if stdin, _ := os.ReadFile(filepath.Join(dir, "stdin")); !strings.Contains(string(stdin), "name: coverage-demo") {
+		t.Errorf("Expected the namespace manifest, got %q", stdin)
+	}
+}
+
+func TestSetup_ReuseK3d(t *testing.T) {
+	dir := t.TempDir()
+	cluster := &Cluster{Name: "e2e", Provider: ProviderK3d, opts: withDefaults(Options{K3d: fakeCLI(t, dir, "k3d", "e2e   1/1   0/0   true\\n")})}
+	exists, err := cluster.exists(context.Background())
+	if err != nil || !exists {
+		t.Fatalf("Expected the cluster to exist, got %v (%v)", exists, err)
+	}
+	if err := cluster.loadImage(context.Background(), "localhost/app:coverage"); err != nil {
+		t.Fatalf("loadImage failed: %v", err)
+	}
+	want := "k3d cluster list --no-headers\nk3d image import localhost/app:coverage --cluster e2e"
+	if calls := readCalls(t, dir); strings.Join(calls, "\n") != want {
+		t.Errorf("Unexpected calls %q", calls)
+	}
+}
+
+func TestSetup_Errors(t *testing.T) {
+	if _, err := Setup(context.Background(), Options{Provider: "minikube"}); err == nil {
+		t.Error("Expected an unknown provider to fail")
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLIs are shell scripts")
+	}
+	dir := t.TempDir()
+	failing := filepath.Join(dir, "kind")
+	os.WriteFile(failing, []byte("#!/bin/sh\necho 'docker not running' >&2\nexit 1\n"), 0755)
+	if _, err := Setup(context.Background(), Options{Kind: failing}); err == nil || !strings.Contains(err.Error(), "docker not running") {
+		t.Errorf("Expected the CLI's error output, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultLockTimeout is how long an operation waits for a test directory locked by another
+// process when SetLockTimeout wasn't called
+const defaultLockTimeout = 10 * time.Minute
+
+// lockRetryInterval is the wait between attempts to take a test directory lock
+const lockRetryInterval = 100 * time.Millisecond
+
+// lockDir is the directory of the lock files below the output directory
+const lockDir = ".locks"
+
+// ErrOutputLocked is returned when a test directory stays locked by another process for longer
+// than the lock timeout, see SetLockTimeout
+var ErrOutputLocked = errors.New("test directory locked")
+
+// SetOutputLocking controls whether collections, reports, merges and pushes lock their test
+// directory, so several processes (Ginkgo parallel workers, CI jobs sharing a runner) can use one
+// output directory: an operation on a test directory waits while another process works on it.
+// Locks are advisory file locks on <output>/.locks/<test>.lock, released by the OS when a process
+// dies; platforms without file locks aren't locked (default: enabled).
+func (c *CoverageClient) SetOutputLocking(enabled bool) {
+	c.lockDisabled = !enabled
+}
+
+// SetLockTimeout sets how long an operation waits for a test directory locked by another process
+// before failing with ErrOutputLocked (default: 10m)
+func (c *CoverageClient) SetLockTimeout(timeout time.Duration) {
+	c.lockTimeout = timeout
+}
+
+// lockedDirsKey holds the test directories locked by the operation a context belongs to, so
+// nested operations (ProcessCoverage generating the report) don't wait for their own lock
+type lockedDirsKey struct{}
+
+// lockTestDir locks the test directory testName against other processes and goroutines, waiting
+// until it's free, the lock timeout passed or ctx is done. The returned context carries the lock
+// for nested operations, unlock releases it.
+func (c *CoverageClient) lockTestDir(ctx context.Context, testName string) (_ context.Context, unlock func(), err error) {
+	locked, _ := ctx.Value(lockedDirsKey{}).(map[string]bool)
+	if c.lockDisabled || locked[testName] {
+		return ctx, func() {}, nil
+	}
+
+	dir := filepath.Join(c.outputDir, lockDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("create lock directory: %w", err)
+	}
+	path := filepath.Join(dir, sanitizeName(testName)+".lock")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	timeout := c.lockTimeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for waiting := false; ; waiting = true {
+		ok, err := tryLockFile(f)
+		if errors.Is(err, errors.ErrUnsupported) {
+			f.Close()
+			return ctx, func() {}, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("lock test directory %s: %w", testName, err)
+		}
+		if ok {
+			break
+		}
+		if !waiting {
+			c.logf("⏳ Waiting for test directory %s, locked by %s\n", testName, lockHolder(path))
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, nil, fmt.Errorf("%w: %s by %s for more than %s (see SetLockTimeout)", ErrOutputLocked, testName, lockHolder(path), timeout)
+		}
+		select {
+		case <-time.After(lockRetryInterval):
+		case <-ctx.Done():
+			f.Close()
+			return nil, nil, fmt.Errorf("lock test directory %s: %w", testName, ctx.Err())
+		}
+	}
+
+	// Record the holder for the processes waiting
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+
+	held := make(map[string]bool, len(locked)+1)
+	for name := range locked {
+		held[name] = true
+	}
+	held[testName] = true
+	unlock = func() {
+		unlockFile(f)
+		f.Close()
+	}
+	return context.WithValue(ctx, lockedDirsKey{}, held), unlock, nil
+}
+
+// withTestDirLock runs f with the test directory testName locked, see lockTestDir
+func (c *CoverageClient) withTestDirLock(ctx context.Context, testName string, f func(context.Context) error) error {
+	ctx, unlock, err := c.lockTestDir(ctx, testName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return f(ctx)
+}
+
+// lockHolder describes the process holding the lock file at path
+func lockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if pid := string(bytes.TrimSpace(data)); err == nil && pid != "" {
+		return "process " + pid
+	}
+	return "another process"
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package coverageclient
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLockFile is unsupported on this platform, test directories aren't locked
+func tryLockFile(f *os.File) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+// unlockFile is unsupported on this platform
+func unlockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
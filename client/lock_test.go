@@ -0,0 +1,98 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockTestDir(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true, lockTimeout: 200 * time.Millisecond}
+	ctx, unlock, err := client.lockTestDir(context.Background(), "e2e")
+	if err != nil {
+		t.Fatalf("lockTestDir failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(client.outputDir, ".locks", "e2e.lock"))
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected the lock file to name this process, got %q", data)
+	}
+
+	// Nested operations reuse the lock
+	_, nestedUnlock, err := client.lockTestDir(ctx, "e2e")
+	if err != nil {
+		t.Fatalf("Nested lockTestDir failed: %v", err)
+	}
+	nestedUnlock()
+
+	// Other test directories aren't locked
+	_, otherUnlock, err := client.lockTestDir(context.Background(), "smoke")
+	if err != nil {
+		t.Fatalf("lockTestDir of another test failed: %v", err)
+	}
+	otherUnlock()
+
+	// Another operation waits for the lock and times out
+	_, _, err = client.lockTestDir(context.Background(), "e2e")
+	if !errors.Is(err, ErrOutputLocked) || !strings.Contains(err.Error(), "process "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("Expected ErrOutputLocked naming the holder, got %v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.lockTestDir(canceled, "e2e"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+
+	// The waiting operation gets the lock once it's released
+	client.lockTimeout = 5 * time.Second
+	acquired := make(chan error)
+	go func() {
+		_, unlock, err := client.lockTestDir(context.Background(), "e2e")
+		if err == nil {
+			unlock()
+		}
+		acquired <- err
+	}()
+	time.Sleep(2 * lockRetryInterval)
+	unlock()
+	if err := <-acquired; err != nil {
+		t.Errorf("Expected the lock after release, got %v", err)
+	}
+}
+
+func TestLockTestDir_Disabled(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	client.SetOutputLocking(false)
+	_, unlock, err := client.lockTestDir(context.Background(), "e2e")
+	if err != nil {
+		t.Fatalf("lockTestDir failed: %v", err)
+	}
+	defer unlock()
+	if _, _, err := client.lockTestDir(context.Background(), "e2e"); err != nil {
+		t.Errorf("Expected no locking, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, ".locks")); !os.IsNotExist(err) {
+		t.Errorf("Expected no lock files, got %v", err)
+	}
+}
+
+func TestProcessCoverage_WaitsForLock(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	client.SetLockTimeout(200 * time.Millisecond)
+	_, unlock, err := client.lockTestDir(context.Background(), "e2e")
+	if err != nil {
+		t.Fatalf("lockTestDir failed: %v", err)
+	}
+	defer unlock()
+
+	if _, err := client.ProcessCoverage(context.Background(), ProcessOptions{TestName: "e2e"}); !errors.Is(err, ErrOutputLocked) {
+		t.Errorf("Expected ErrOutputLocked, got %v", err)
+	}
+	if _, err := client.PushCoverageArtifactWithResult(context.Background(), "e2e", PushCoverageArtifactOptions{}); !errors.Is(err, ErrOutputLocked) {
+		t.Errorf("Expected ErrOutputLocked from the push, got %v", err)
+	}
+}
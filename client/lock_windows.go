@@ -0,0 +1,30 @@
+package coverageclient
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockRegion is the byte range locked in lock files: far beyond the holder's PID, since Windows
+// locks are mandatory and would keep waiting processes from reading it
+func lockRegion() *windows.Overlapped {
+	return &windows.Overlapped{OffsetHigh: 1}
+}
+
+// tryLockFile takes an exclusive lock on a byte of f without blocking, reporting false when
+// another handle holds it
+func tryLockFile(f *os.File) (bool, error) {
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, 1, 0, lockRegion())
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile releases the lock on f
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, lockRegion())
+}
@@ -0,0 +1,175 @@
+package coverageclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Logger receives the client's progress output, one message per call. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// LeveledLogger is a Logger also receiving the level of each message. Loggers set with SetLogger
+// that implement it get Logf calls instead of Printf.
+type LeveledLogger interface {
+	Logger
+	Logf(level Level, format string, args ...any)
+}
+
+// Level is the severity of a progress message
+type Level int
+
+// Message levels, from the most verbose
+const (
+	LevelDebug Level = iota // Details such as path remapping decisions
+	LevelInfo               // Progress: pods found, files saved, reports generated
+	LevelWarn               // Skipped steps and fallbacks, prefixed with ⚠️
+	LevelError              // Failures, prefixed with ❌
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name: debug, info, warn (or warning) or error
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		return LevelWarn, nil
+	}
+	for i, levelName := range levelNames {
+		if name == levelName {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// SetLogger routes the client's progress output through logger (default: stdout)
+func (c *CoverageClient) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// SetQuiet drops the client's progress output, e.g. when stdout is parsed as TAP or `go test -json`
+// output (default: false). Summaries stay available as values, see CoverageSummary and GateResult.
+func (c *CoverageClient) SetQuiet(quiet bool) {
+	c.quiet = quiet
+}
+
+// SetLogLevel drops progress messages below level, e.g. LevelWarn to keep CI logs to what went
+// wrong (default: LevelDebug, everything is logged)
+func (c *CoverageClient) SetLogLevel(level Level) {
+	c.logLevel = level
+}
+
+// setLogFormat switches the output to stdout as text or JSON lines
+func (c *CoverageClient) setLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "text":
+		c.SetLogger(nil)
+	case "json":
+		c.SetLogger(NewJSONLogger(os.Stdout))
+	default:
+		return fmt.Errorf("unknown log format %q (text or json)", format)
+	}
+	return nil
+}
+
+// logf writes progress output to the configured logger
+func (c *CoverageClient) logf(format string, args ...any) {
+	if c == nil {
+		fmt.Printf(format, args...)
+		return
+	}
+	level := messageLevel(format)
+	if c.quiet || level < c.logLevel {
+		return
+	}
+	switch logger := c.logger.(type) {
+	case nil:
+		fmt.Printf(format, args...)
+	case LeveledLogger:
+		logger.Logf(level, format, args...)
+	default:
+		logger.Printf(format, args...)
+	}
+}
+
+// messageLevel derives the level of a progress message from its prefix, ignoring the indentation
+// of nested steps
+func messageLevel(format string) Level {
+	format = strings.TrimLeft(format, " \t")
+	switch {
+	case strings.HasPrefix(format, "❌"):
+		return LevelError
+	case strings.HasPrefix(format, "⚠️"), strings.Contains(format, "Warning:"):
+		return LevelWarn
+	case strings.HasPrefix(format, "[REMAP]"):
+		return LevelDebug
+	default:
+		return LevelInfo
+	}
+}
+
+// JSONLogger writes each progress message as a JSON object on its own line, for log pipelines
+// parsing the output: {"time":"...","level":"info","msg":"Saved: covmeta.abc"}. Messages are
+// trimmed and their emoji prefix dropped; multi-line messages such as summaries are kept whole.
+type JSONLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewJSONLogger returns a JSONLogger writing to w
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w, now: time.Now}
+}
+
+// Printf logs an info message
+func (l *JSONLogger) Printf(format string, args ...any) {
+	l.Logf(LevelInfo, format, args...)
+}
+
+// Logf logs a message at level
+func (l *JSONLogger) Logf(level Level, format string, args ...any) {
+	msg := trimMessage(fmt.Sprintf(format, args...))
+	if msg == "" {
+		return
+	}
+	line, err := json.Marshal(struct {
+		Time  string `json:"time"`
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}{l.now().UTC().Format(time.RFC3339Nano), level.String(), msg})
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+// trimMessage drops the surrounding white space and the leading emoji (or other symbols) of a
+// progress message
+func trimMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	trimmed := strings.TrimLeftFunc(msg, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+	if trimmed == "" {
+		return msg
+	}
+	return trimmed
+}
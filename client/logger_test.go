@@ -0,0 +1,106 @@
+package coverageclient
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetLogger(t *testing.T) {
+	var buf bytes.Buffer
+	client := &CoverageClient{}
+	client.SetLogger(log.New(&buf, "", 0))
+
+	client.logf("📁 Saved: %s\n", "covmeta.abc")
+	if buf.String() != "📁 Saved: covmeta.abc\n" {
+		t.Errorf("Expected output through the logger, got %q", buf.String())
+	}
+
+	buf.Reset()
+	client.SetQuiet(true)
+	client.logf("📁 Saved: %s\n", "covmeta.abc")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output in quiet mode, got %q", buf.String())
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	client := &CoverageClient{logger: log.New(&buf, "", 0)}
+	client.SetLogLevel(LevelWarn)
+
+	client.logf("📁 Saved: %s\n", "covmeta.abc")
+	client.logf("[REMAP] Module %s\n", "example.com/app")
+	client.logf("⚠️  Skipping baseline comparison: %v\n", "no artifact")
+	client.logf("  ⚠️  Port-forward failed: %v\n", "timeout")
+	client.logf("  ℹ️  No server info\n")
+	client.logf("❌ Coverage gate failed\n")
+	if buf.String() != "⚠️  Skipping baseline comparison: no artifact\n  ⚠️  Port-forward failed: timeout\n❌ Coverage gate failed\n" {
+		t.Errorf("Expected only warnings and errors, got %q", buf.String())
+	}
+
+	for name, want := range map[string]Level{"debug": LevelDebug, "INFO": LevelInfo, "warning": LevelWarn, "error": LevelError} {
+		if level, err := ParseLevel(name); err != nil || level != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", name, level, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf)
+	logger.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	client := &CoverageClient{logger: logger}
+
+	client.logf("⚠️  Skipping baseline comparison: %v\n", "no artifact")
+	client.logf("   ✓ File store created\n")
+	client.logf("[REMAP] Module %s\n", "example.com/app")
+	client.logf("\n")
+	want := `{"time":"2024-05-01T12:00:00Z","level":"warn","msg":"Skipping baseline comparison: no artifact"}
+{"time":"2024-05-01T12:00:00Z","level":"info","msg":"File store created"}
+{"time":"2024-05-01T12:00:00Z","level":"debug","msg":"[REMAP] Module example.com/app"}
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected JSON output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	if err := client.setLogFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown log format")
+	}
+	if client.setLogFormat("text"); client.logger != nil {
+		t.Errorf("Expected the text format to log to stdout, got %T", client.logger)
+	}
+}
+
+func TestCoverageSummary(t *testing.T) {
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	var buf bytes.Buffer
+	client := &CoverageClient{outputDir: outputDir, logger: log.New(&buf, "", 0)}
+	summary, err := client.CoverageSummary("e2e")
+	if err != nil {
+		t.Fatalf("CoverageSummary failed: %v", err)
+	}
+	if !strings.Contains(summary, "Coverage Summary for test: e2e") || !strings.Contains(summary, testProfile) {
+		t.Errorf("Unexpected summary:\n%s", summary)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected CoverageSummary not to log, got %q", buf.String())
+	}
+
+	if err := client.PrintCoverageSummary("e2e"); err != nil || buf.String() != summary {
+		t.Errorf("Expected PrintCoverageSummary to log the summary, got %q (%v)", buf.String(), err)
+	}
+	if _, err := client.CoverageSummary("missing"); err == nil {
+		t.Error("Expected error for a missing report")
+	}
+}
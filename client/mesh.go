@@ -0,0 +1,154 @@
+package coverageclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	istioProxyContainer         = "istio-proxy"
+	istioStatusAnnotation       = "sidecar.istio.io/status"
+	istioExcludeInboundPortsKey = "traffic.sidecar.istio.io/excludeInboundPorts"
+	istioIncludeInboundPortsKey = "traffic.sidecar.istio.io/includeInboundPorts"
+)
+
+// MeshInfo describes the service mesh sidecar of a pod
+type MeshInfo struct {
+	SidecarInjected     bool   // An Envoy (istio-proxy) sidecar intercepts inbound traffic
+	ExcludeInboundPorts string // Value of traffic.sidecar.istio.io/excludeInboundPorts
+	IncludeInboundPorts string // Value of traffic.sidecar.istio.io/includeInboundPorts ("*" when unset)
+}
+
+// PortBypassesSidecar reports whether inbound traffic to port reaches the app without going through the sidecar
+func (m MeshInfo) PortBypassesSidecar(port int) bool {
+	if !m.SidecarInjected {
+		return true
+	}
+	if portListContains(m.ExcludeInboundPorts, port) {
+		return true
+	}
+	include := strings.TrimSpace(m.IncludeInboundPorts)
+	return include != "" && include != "*" && !portListContains(include, port)
+}
+
+// MeshBlockedError is returned when collection failed and the pod's sidecar likely rejected the request
+type MeshBlockedError struct {
+	PodName string
+	Port    int
+	Err     error
+}
+
+func (e *MeshBlockedError) Error() string {
+	return fmt.Sprintf("coverage port %d of pod %s is intercepted by the istio-proxy sidecar (strict mTLS rejects plain HTTP): %v; "+
+		"annotate the pod with %s: \"%d\" or configure client certificates with SetMeshTLS",
+		e.Port, e.PodName, e.Err, istioExcludeInboundPortsKey, e.Port)
+}
+
+func (e *MeshBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// MeshTLSConfig holds the mesh client certificates used to call the coverage port through a sidecar with strict mTLS
+type MeshTLSConfig struct {
+	CertFile           string // Client certificate issued by the mesh CA
+	KeyFile            string // Client private key
+	CAFile             string // Mesh root CA (default: system roots)
+	ServerName         string // Expected server name, e.g. the SPIFFE trust domain host (default: request host)
+	InsecureSkipVerify bool   // Don't verify the sidecar's certificate (SPIFFE URIs rarely match DNS names)
+}
+
+// SetMeshTLS makes the service collection method call the coverage port over mTLS with the given
+// certificates, so it is accepted by an Istio sidecar enforcing strict PeerAuthentication
+func (c *CoverageClient) SetMeshTLS(cfg MeshTLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load mesh client certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		// Istio's inbound listener selects the mTLS filter chain by ALPN
+		NextProtos: []string{"istio-http/1.1", "istio", "http/1.1"},
+	}
+	if cfg.CAFile != "" {
+		caData, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("read mesh CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return fmt.Errorf("no certificates found in mesh CA %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	timeout := 30 * time.Second
+	if c.httpClient != nil {
+		timeout = c.httpClient.Timeout
+	}
+	c.meshHTTPClient = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return nil
+}
+
+// PodMeshInfo inspects the pod for an injected Istio sidecar and its inbound port annotations
+func (c *CoverageClient) PodMeshInfo(ctx context.Context, podName string) (MeshInfo, error) {
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return MeshInfo{}, fmt.Errorf("get pod details: %w", err)
+	}
+	return meshInfoFromPod(pod), nil
+}
+
+// meshInfoFromPod detects the sidecar from the injection status annotation or the istio-proxy container
+func meshInfoFromPod(pod *corev1.Pod) MeshInfo {
+	info := MeshInfo{
+		ExcludeInboundPorts: pod.Annotations[istioExcludeInboundPortsKey],
+		IncludeInboundPorts: pod.Annotations[istioIncludeInboundPortsKey],
+	}
+	if _, ok := pod.Annotations[istioStatusAnnotation]; ok {
+		info.SidecarInjected = true
+	}
+	// Native sidecars are injected as init containers
+	for _, containers := range [][]corev1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for _, container := range containers {
+			if container.Name == istioProxyContainer {
+				info.SidecarInjected = true
+			}
+		}
+	}
+	return info
+}
+
+// diagnoseMeshFailure wraps err in a MeshBlockedError when the pod's sidecar intercepts the coverage port
+func (c *CoverageClient) diagnoseMeshFailure(ctx context.Context, podName string, port int, err error) error {
+	info, infoErr := c.PodMeshInfo(ctx, podName)
+	if infoErr != nil || info.PortBypassesSidecar(port) {
+		return err
+	}
+	return &MeshBlockedError{PodName: podName, Port: port, Err: err}
+}
+
+// portListContains checks a comma-separated port list annotation for port
+func portListContains(list string, port int) bool {
+	for _, entry := range strings.Split(list, ",") {
+		if p, err := strconv.Atoi(strings.TrimSpace(entry)); err == nil && p == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMeshInfoPortBypassesSidecar(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		containers  []corev1.Container
+		bypasses    bool
+	}{
+		{"no sidecar", nil, []corev1.Container{{Name: "app"}}, true},
+		{"sidecar container", nil, []corev1.Container{{Name: "app"}, {Name: "istio-proxy"}}, false},
+		{"injection status", map[string]string{istioStatusAnnotation: "{}"}, []corev1.Container{{Name: "app"}}, false},
+		{"port excluded", map[string]string{istioStatusAnnotation: "{}", istioExcludeInboundPortsKey: "8080, 9095"}, nil, true},
+		{"other port excluded", map[string]string{istioStatusAnnotation: "{}", istioExcludeInboundPortsKey: "8080"}, nil, false},
+		{"port not included", map[string]string{istioStatusAnnotation: "{}", istioIncludeInboundPortsKey: "8080"}, nil, true},
+		{"all ports included", map[string]string{istioStatusAnnotation: "{}", istioIncludeInboundPortsKey: "*"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       corev1.PodSpec{Containers: tt.containers},
+			}
+			if got := meshInfoFromPod(pod).PortBypassesSidecar(9095); got != tt.bypasses {
+				t.Errorf("Expected PortBypassesSidecar=%v, got %v", tt.bypasses, got)
+			}
+		})
+	}
+}
+
+func TestCollectCoverageWithFallback_MeshDiagnostics(t *testing.T) {
+	pod, _, _ := newFallbackTestObjects()
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "istio-proxy"})
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(pod),
+		namespace:  "default",
+		outputDir:  t.TempDir(),
+		httpClient: &http.Client{Timeout: 2 * time.Second},
+	}
+
+	_, err := client.CollectCoverageWithFallback(context.Background(), "demo-pod", "mesh-test", 9095, FallbackOptions{
+		Methods:    []CollectionMethod{MethodIngress},
+		IngressURL: "http://does-not-resolve.invalid",
+	})
+	var meshErr *MeshBlockedError
+	if !errors.As(err, &meshErr) {
+		t.Fatalf("Expected MeshBlockedError, got %v", err)
+	}
+	if meshErr.PodName != "demo-pod" || meshErr.Port != 9095 {
+		t.Errorf("Unexpected error fields: %+v", meshErr)
+	}
+}
+
+func TestSetMeshTLS_MissingCertificate(t *testing.T) {
+	client := &CoverageClient{}
+	dir := t.TempDir()
+	err := client.SetMeshTLS(MeshTLSConfig{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	})
+	if err == nil {
+		t.Error("Expected error for missing certificate files")
+	}
+	if client.meshHTTPClient != nil {
+		t.Error("Expected mesh client to stay unset on error")
+	}
+}
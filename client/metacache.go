@@ -0,0 +1,113 @@
+package coverageclient
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// countersOnlyParam is the coverage endpoint's query parameter listing the meta-data hashes the
+// client holds; the server leaves the meta-data of a binary with one of them out of streamed responses
+const countersOnlyParam = "counters-only"
+
+// maxCachedMeta bounds the binaries whose meta-data the client remembers
+const maxCachedMeta = 32
+
+// errMetaNotCached reports a counters-only response whose meta-data the client no longer holds;
+// the coverage is requested again with meta-data
+var errMetaNotCached = errors.New("meta-data not cached")
+
+// metaCache remembers the meta-data files saved by earlier collections by their hash. Meta-data
+// only depends on the binary, so later collections from it don't need to download it again.
+type metaCache struct {
+	mu    sync.Mutex
+	paths map[string]string // By meta-data hash
+	order []string          // Hashes, least recently saved first
+}
+
+// hashes returns the hashes of the meta-data files still on disk, for countersOnlyParam
+func (m *metaCache) hashes() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var hashes []string
+	for _, hash := range m.order {
+		if _, err := os.Stat(m.paths[hash]); err == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+	return strings.Join(hashes, ",")
+}
+
+// remember records a saved meta-data file
+func (m *metaCache) remember(hash, path string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paths == nil {
+		m.paths = make(map[string]string)
+	}
+	if i := indexOf(m.order, hash); i >= 0 {
+		m.order = append(m.order[:i], m.order[i+1:]...)
+	}
+	m.order = append(m.order, hash)
+	m.paths[hash] = path
+	for len(m.order) > maxCachedMeta {
+		delete(m.paths, m.order[0])
+		m.order = m.order[1:]
+	}
+}
+
+// copyTo copies the cached meta-data with the given hash into dir, checking that the file still
+// holds that meta-data
+func (m *metaCache) copyTo(hash, dir string) (string, error) {
+	var path string
+	if m != nil {
+		m.mu.Lock()
+		path = m.paths[hash]
+		m.mu.Unlock()
+	}
+	if path == "" {
+		return "", fmt.Errorf("%w: %s", errMetaNotCached, hash)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errMetaNotCached, err)
+	}
+	defer f.Close()
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(f, header); err != nil || hex.EncodeToString(header[16:]) != hash {
+		return "", fmt.Errorf("%w: %s no longer holds meta-data %s", errMetaNotCached, path, hash)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(dir, "covmeta."+hash)
+	if dest == path {
+		return dest, nil
+	}
+	if err := writeFileFrom(dest, f); err != nil {
+		return "", fmt.Errorf("copy cached meta-data: %w", err)
+	}
+	return dest, nil
+}
+
+// coverageFileHash returns the meta-data hash in a covmeta or covcounters file name
+func coverageFileHash(name string) string {
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
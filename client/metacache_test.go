@@ -0,0 +1,82 @@
+package coverageclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectCoverageFromURL_CountersOnly(t *testing.T) {
+	metaData := append(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{0xab}, 40)...)
+	hash := hex.EncodeToString(metaData[16:32])
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		held := r.URL.Query().Get(countersOnlyParam)
+		queries = append(queries, held)
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", CoverageStreamType+"; boundary="+mw.Boundary())
+		if !strings.Contains(held, hash) {
+			part, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Disposition": {`attachment; filename="covmeta.` + hash + `"`}})
+			part.Write(metaData)
+		}
+		part, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Disposition": {`attachment; filename="covcounters.` + hash + `.1.2"`}})
+		part.Write([]byte("counters"))
+		mw.Close()
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{outputDir: tempDir, httpClient: &http.Client{Timeout: 10 * time.Second}, metaCache: &metaCache{}}
+	for _, testName := range []string{"test-1", "test-2"} {
+		if err := client.CollectCoverageFromURL(server.URL, testName); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testName, err)
+		}
+		content, err := os.ReadFile(filepath.Join(tempDir, testName, "covmeta."+hash))
+		if err != nil || !bytes.Equal(content, metaData) {
+			t.Errorf("%s: expected meta-data, got %x (%v)", testName, content, err)
+		}
+	}
+	if strings.Join(queries, "|") != "|"+hash {
+		t.Errorf("Expected the second request to list the cached meta-data, got %q", queries)
+	}
+
+	// A cached file that changed is not trusted, the coverage is requested again with meta-data
+	os.WriteFile(filepath.Join(tempDir, "test-1", "covmeta."+hash), []byte("modified"), 0644)
+	if err := client.CollectCoverageFromURL(server.URL, "test-3"); err != nil {
+		t.Fatalf("Expected a retry with meta-data, got %v", err)
+	}
+	if len(queries) != 4 || queries[3] != "" {
+		t.Errorf("Expected a retry without %s, got %q", countersOnlyParam, queries)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tempDir, "test-3", "covmeta."+hash)); !bytes.Equal(content, metaData) {
+		t.Errorf("Expected downloaded meta-data, got %q", content)
+	}
+
+	// Meta-data whose file is gone isn't listed
+	os.RemoveAll(filepath.Join(tempDir, "test-3"))
+	client.CollectCoverageFromURL(server.URL, "test-4")
+	if queries[4] != "" {
+		t.Errorf("Expected no cached meta-data after its file was removed, got %q", queries[4])
+	}
+}
+
+func TestCoverageFileHash(t *testing.T) {
+	for name, want := range map[string]string{
+		"covmeta.abc":         "abc",
+		"covcounters.abc.1.2": "abc",
+		"covmeta":             "",
+	} {
+		if got := coverageFileHash(name); got != want {
+			t.Errorf("coverageFileHash(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
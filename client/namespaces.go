@@ -0,0 +1,52 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CollectFromNamespaces collects coverage from the instrumented pods (see DiscoverInstrumentedPods)
+// of several namespaces, for suites testing microservices deployed across them. Each pod is saved
+// into <test>/<namespace>/<pod>. A namespace or pod failing doesn't stop the others: the results
+// of the collected pods are returned with the errors of the failed ones. Collecting from a single
+// pod of another namespace is done with CollectOptions.Namespace.
+func (c *CoverageClient) CollectFromNamespaces(ctx context.Context, namespaces []string, testName string) ([]*CollectResult, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("collect: no namespaces")
+	}
+
+	var found []InstrumentedPod
+	var errs []error
+	for _, namespace := range namespaces {
+		pods, err := c.inNamespace(namespace).DiscoverInstrumentedPods(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", namespace, err))
+			continue
+		}
+		found = append(found, pods...)
+	}
+	if len(found) == 0 && len(errs) == 0 {
+		return nil, fmt.Errorf("collect from namespaces: %w", ErrNoPodsFound)
+	}
+
+	c.logf("📦 Collecting coverage from %d instrumented pod(s) in %d namespace(s) for test: %s\n", len(found), len(namespaces), testName)
+	results, err := c.collectInstrumented(ctx, testName, found, true)
+	if err := errors.Join(append(errs, err)...); err != nil {
+		return results, fmt.Errorf("collect from namespaces: %w", err)
+	}
+	return results, nil
+}
+
+// inNamespace returns c, or a copy of c working in another namespace ("" keeps c's)
+func (c *CoverageClient) inNamespace(namespace string) *CoverageClient {
+	if namespace == "" || namespace == c.namespace {
+		return c
+	}
+	clone := *c
+	clone.namespace = namespace
+	return &clone
+}
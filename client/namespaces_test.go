@@ -0,0 +1,72 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCollectFromNamespaces(t *testing.T) {
+	annotated := func(name, namespace string) *corev1.Pod {
+		pod := replicaPod(name, corev1.PodRunning, nil)
+		pod.Namespace = namespace
+		pod.Annotations = map[string]string{AnnotationPort: "9095", AnnotationContainer: "app"}
+		return pod
+	}
+	client, forwarder := newReplicaClient(t, "",
+		annotated("frontend", "shop"),
+		annotated("payments", "billing"),
+		annotated("ignored", "other"),
+	)
+
+	results, err := client.CollectFromNamespaces(context.Background(), []string{"shop", "billing", "empty"}, "e2e")
+	if err != nil {
+		t.Fatalf("CollectFromNamespaces failed: %v", err)
+	}
+	if len(results) != 2 || results[0].TestName != "e2e/billing/payments" || results[1].TestName != "e2e/shop/frontend" {
+		t.Fatalf("Expected a result per pod of the namespaces, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "shop", "frontend", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the covdata in the namespace's subdirectory: %v", err)
+	}
+	if slices.Contains(forwarder.pods, "ignored") || slices.Contains(forwarder.namespaces, "default") {
+		t.Errorf("Expected port-forwards into the requested namespaces only, got %v in %v", forwarder.pods, forwarder.namespaces)
+	}
+
+	if _, err := client.CollectFromNamespaces(context.Background(), []string{"empty"}, "e2e"); !errors.Is(err, ErrNoPodsFound) {
+		t.Errorf("Expected ErrNoPodsFound, got %v", err)
+	}
+	if _, err := client.CollectFromNamespaces(context.Background(), nil, "e2e"); err == nil {
+		t.Error("Expected an error without namespaces")
+	}
+
+	// Broken pods fail alone
+	client, _ = newReplicaClient(t, "payments", annotated("frontend", "shop"), annotated("payments", "billing"))
+	results, err = client.CollectFromNamespaces(context.Background(), []string{"shop", "billing"}, "e2e")
+	if err == nil || !strings.Contains(err.Error(), "namespace billing") || len(results) != 1 {
+		t.Errorf("Expected the broken namespace's error with the other result, got %+v, %v", results, err)
+	}
+}
+
+func TestCollect_Namespace(t *testing.T) {
+	pod := replicaPod("api", corev1.PodRunning, nil)
+	pod.Namespace = "backend"
+	client, forwarder := newReplicaClient(t, "", pod)
+
+	result, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", PodName: "api", Namespace: "backend", Container: "app"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if result.TestName != "e2e" || len(forwarder.namespaces) == 0 || forwarder.namespaces[0] != "backend" {
+		t.Errorf("Expected a port-forward into the backend namespace, got %v", forwarder.namespaces)
+	}
+	if client.namespace != "default" {
+		t.Errorf("Expected the client's namespace to stay default, got %s", client.namespace)
+	}
+}
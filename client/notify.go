@@ -0,0 +1,201 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebhookFormat selects the payload format of Notify
+type WebhookFormat string
+
+const (
+	// WebhookSlack sends a Slack incoming webhook message
+	WebhookSlack WebhookFormat = "slack"
+	// WebhookTeams sends a Microsoft Teams MessageCard
+	WebhookTeams WebhookFormat = "teams"
+	// WebhookGeneric sends the coverage numbers as plain JSON (see WebhookPayload)
+	WebhookGeneric WebhookFormat = "generic"
+)
+
+// NotifyOptions configures Notify
+type NotifyOptions struct {
+	CoverageReportOptions
+	Suite      string             // Suite name shown in the message (default: the test name)
+	ReportURL  string             // Link to the coverage report, e.g. the HTML report in CI artifacts
+	Format     WebhookFormat      // Payload format (default: detected from the webhook URL, generic otherwise)
+	Thresholds CoverageThresholds // Optional: report pass/fail of these thresholds
+}
+
+// WebhookPayload is the body of generic webhook notifications
+type WebhookPayload struct {
+	Suite      string   `json:"suite"`
+	TestName   string   `json:"test_name"`
+	Total      float64  `json:"total"`
+	Statements int      `json:"statements"`
+	Covered    int      `json:"covered"`
+	Delta      *float64 `json:"delta,omitempty"`
+	Patch      *float64 `json:"patch,omitempty"`
+	ReportURL  string   `json:"report_url,omitempty"`
+	Passed     bool     `json:"passed"`
+	Failures   []string `json:"failures,omitempty"`
+}
+
+// Notify sends the coverage of a processed test (suite name, total, delta, report link) to a
+// Slack, Teams or generic webhook, for teams that track coverage in chat
+func (c *CoverageClient) Notify(ctx context.Context, webhookURL string, opts NotifyOptions) error {
+	if opts.Suite == "" {
+		opts.Suite = opts.TestName
+	}
+	if opts.Format == "" {
+		opts.Format = detectWebhookFormat(webhookURL)
+	}
+
+	report, err := c.BuildCoverageReport(ctx, opts.CoverageReportOptions)
+	if err != nil {
+		return err
+	}
+	payload := newWebhookPayload(report, opts)
+
+	var body interface{}
+	switch opts.Format {
+	case WebhookSlack:
+		body = slackMessage(payload)
+	case WebhookTeams:
+		body = teamsMessage(payload)
+	case WebhookGeneric:
+		body = payload
+	default:
+		return fmt.Errorf("unknown webhook format: %s", opts.Format)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, respBody)
+	}
+
+	c.logf("🔔 Sent %s coverage notification for %s\n", opts.Format, opts.Suite)
+	return nil
+}
+
+// newWebhookPayload extracts the numbers shown in notifications from a report
+func newWebhookPayload(report *CoverageReport, opts NotifyOptions) WebhookPayload {
+	payload := WebhookPayload{
+		Suite:      opts.Suite,
+		TestName:   report.TestName,
+		Total:      report.Total.Percent(),
+		Statements: report.Total.Statements,
+		Covered:    report.Total.Covered,
+		ReportURL:  opts.ReportURL,
+		Failures:   opts.Thresholds.Check(report),
+	}
+	payload.Passed = len(payload.Failures) == 0
+	if d, ok := report.Delta(); ok {
+		payload.Delta = &d
+	}
+	if report.Patch != nil && report.Patch.Statements > 0 {
+		patch := report.Patch.Percent()
+		payload.Patch = &patch
+	}
+	return payload
+}
+
+// summary returns the one-line coverage summary shared by chat formats
+func (p WebhookPayload) summary() string {
+	summary := fmt.Sprintf("%.1f%%", p.Total)
+	if p.Delta != nil {
+		summary += fmt.Sprintf(" (%s)", formatDelta(*p.Delta))
+	}
+	if p.Patch != nil {
+		summary += fmt.Sprintf(" · patch %.1f%%", *p.Patch)
+	}
+	return summary
+}
+
+func (p WebhookPayload) icon() string {
+	if p.Passed {
+		return "✅"
+	}
+	return "❌"
+}
+
+// slackMessage formats a Slack incoming webhook message (mrkdwn)
+func slackMessage(p WebhookPayload) map[string]interface{} {
+	text := fmt.Sprintf("%s *%s* coverage: %s", p.icon(), p.Suite, p.summary())
+	for _, failure := range p.Failures {
+		text += "\n• " + failure
+	}
+	if p.ReportURL != "" {
+		text += fmt.Sprintf("\n<%s|View report>", p.ReportURL)
+	}
+	return map[string]interface{}{"text": text}
+}
+
+// teamsMessage formats a Microsoft Teams MessageCard
+func teamsMessage(p WebhookPayload) map[string]interface{} {
+	color := "2EB886"
+	if !p.Passed {
+		color = "D00000"
+	}
+	text := fmt.Sprintf("Coverage: **%s**", p.summary())
+	for _, failure := range p.Failures {
+		text += "\n\n- " + failure
+	}
+
+	card := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    fmt.Sprintf("%s coverage: %.1f%%", p.Suite, p.Total),
+		"themeColor": color,
+		"title":      fmt.Sprintf("%s %s", p.icon(), p.Suite),
+		"text":       text,
+	}
+	if p.ReportURL != "" {
+		card["potentialAction"] = []map[string]interface{}{{
+			"@type":   "OpenUri",
+			"name":    "View report",
+			"targets": []map[string]string{{"os": "default", "uri": p.ReportURL}},
+		}}
+	}
+	return card
+}
+
+// detectWebhookFormat guesses the payload format from well-known webhook hosts
+func detectWebhookFormat(webhookURL string) WebhookFormat {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return WebhookGeneric
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return WebhookSlack
+	case strings.HasSuffix(host, ".webhook.office.com"), strings.HasSuffix(host, ".logic.azure.com"):
+		return WebhookTeams
+	}
+	return WebhookGeneric
+}
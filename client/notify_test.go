@@ -0,0 +1,76 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: outputDir, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	opts := NotifyOptions{
+		CoverageReportOptions: CoverageReportOptions{TestName: "e2e"},
+		Suite:                 "nightly",
+		ReportURL:             "https://ci.example.com/report.html",
+		Thresholds:            CoverageThresholds{MinTotal: 80},
+	}
+
+	if err := client.Notify(context.Background(), server.URL, opts); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if received["suite"] != "nightly" || received["total"].(float64) < 66.6 || received["passed"] != false {
+		t.Errorf("Unexpected generic payload: %v", received)
+	}
+
+	opts.Format = WebhookSlack
+	if err := client.Notify(context.Background(), server.URL, opts); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	text, _ := received["text"].(string)
+	for _, want := range []string{"❌ *nightly* coverage: 66.7%", "total coverage 66.7% is below 80.0%", "<https://ci.example.com/report.html|View report>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected Slack text to contain %q, got %q", want, text)
+		}
+	}
+
+	opts.Format = WebhookTeams
+	if err := client.Notify(context.Background(), server.URL, opts); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if received["@type"] != "MessageCard" || received["themeColor"] != "D00000" {
+		t.Errorf("Unexpected Teams card: %v", received)
+	}
+}
+
+func TestDetectWebhookFormat(t *testing.T) {
+	for webhookURL, want := range map[string]WebhookFormat{
+		"https://hooks.slack.com/services/T/B/X":                  WebhookSlack,
+		"https://example.webhook.office.com/webhookb2/abc":        WebhookTeams,
+		"https://prod-1.westeurope.logic.azure.com/workflows/abc": WebhookTeams,
+		"https://ci.example.com/hooks/coverage":                   WebhookGeneric,
+	} {
+		if got := detectWebhookFormat(webhookURL); got != want {
+			t.Errorf("detectWebhookFormat(%s) = %s, want %s", webhookURL, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PeriodicSnapshot is one collection of a PeriodicCollection
+type PeriodicSnapshot struct {
+	Time     time.Time // When the snapshot was taken
+	TestName string    // Test directory of the snapshot, <test>/<timestamp>
+	Pods     []string  // Test names of the collected pods, <test>/<timestamp>/<pod>
+}
+
+// PeriodicCollection collects coverage on an interval until stopped, see StartPeriodicCollection
+type PeriodicCollection struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	snapshots []PeriodicSnapshot
+}
+
+// StartPeriodicCollection collects coverage from every running pod matching podSelector every
+// interval in the background, for soak tests: each snapshot is kept in its own directory,
+// <test>/<timestamp>/<pod>, so coverage can be compared over time and counters of a pod that
+// restarts (or is replaced) survive in its earlier snapshots. Snapshots run one after the other;
+// a failing snapshot is logged and the next one is taken on time. Stop it with Stop, or by
+// cancelling ctx.
+func (c *CoverageClient) StartPeriodicCollection(ctx context.Context, podSelector string, interval time.Duration, testName string) (*PeriodicCollection, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	if podSelector == "" {
+		return nil, fmt.Errorf("collect: no pod selector")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid collection interval %s", interval)
+	}
+
+	// Stop ends the loop but lets a snapshot in progress finish
+	loopCtx, cancel := context.WithCancel(ctx)
+	p := &PeriodicCollection{cancel: cancel, done: make(chan struct{})}
+	c.logf("⏱️  Collecting coverage from pods matching %s every %s for test: %s\n", podSelector, interval, testName)
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-loopCtx.Done():
+				c.logf("⏱️  Periodic collection for test %s stopped after %d snapshot(s)\n", testName, len(p.Snapshots()))
+				return
+			case now := <-ticker.C:
+				c.takePeriodicSnapshot(ctx, p, podSelector, testName, now.UTC())
+			}
+		}
+	}()
+	return p, nil
+}
+
+// takePeriodicSnapshot collects one snapshot of a PeriodicCollection
+func (c *CoverageClient) takePeriodicSnapshot(ctx context.Context, p *PeriodicCollection, podSelector, testName string, now time.Time) {
+	snapshot := PeriodicSnapshot{Time: now, TestName: path.Join(testName, now.Format("20060102-150405.000"))}
+	results, err := c.CollectCoverageFromSelector(ctx, podSelector, snapshot.TestName, 0)
+	if err != nil && ctx.Err() == nil {
+		c.logf("⚠️  Periodic snapshot %s failed: %v\n", snapshot.TestName, err)
+	}
+	if len(results) == 0 {
+		return
+	}
+	for _, result := range results {
+		snapshot.Pods = append(snapshot.Pods, result.TestName)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snapshots = append(p.snapshots, snapshot)
+}
+
+// Snapshots returns the snapshots taken so far, oldest first. Snapshots in which no pod could be
+// collected are left out.
+func (p *PeriodicCollection) Snapshots() []PeriodicSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.snapshots)
+}
+
+// Stop stops collecting, waits for a snapshot in progress and returns all snapshots
+func (p *PeriodicCollection) Stop() []PeriodicSnapshot {
+	p.cancel()
+	<-p.done
+	return p.Snapshots()
+}
@@ -0,0 +1,58 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestStartPeriodicCollection(t *testing.T) {
+	labels := map[string]string{"app": "soak"}
+	client, _ := newReplicaClient(t, "",
+		replicaPod("soak-a", corev1.PodRunning, labels),
+		replicaPod("soak-b", corev1.PodRunning, labels),
+	)
+
+	periodic, err := client.StartPeriodicCollection(context.Background(), "app=soak", 50*time.Millisecond, "soak")
+	if err != nil {
+		t.Fatalf("StartPeriodicCollection failed: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for len(periodic.Snapshots()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	snapshots := periodic.Stop()
+	if len(snapshots) < 2 {
+		t.Fatalf("Expected at least 2 snapshots, got %+v", snapshots)
+	}
+
+	first, second := snapshots[0], snapshots[1]
+	if !second.Time.After(first.Time) || first.TestName == second.TestName {
+		t.Errorf("Expected distinct snapshots in order, got %+v and %+v", first, second)
+	}
+	if filepath.Dir(first.TestName) != "soak" || len(first.Pods) != 2 || first.Pods[0] != first.TestName+"/soak-a" {
+		t.Errorf("Expected <test>/<timestamp>/<pod> snapshots, got %+v", first)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, first.Pods[1], "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the covdata of the snapshot: %v", err)
+	}
+
+	// Stopped collections take no more snapshots
+	time.Sleep(120 * time.Millisecond)
+	if got := len(periodic.Snapshots()); got != len(snapshots) {
+		t.Errorf("Expected no snapshots after Stop, got %d more", got-len(snapshots))
+	}
+
+	for _, tc := range []struct {
+		selector, test string
+		interval       time.Duration
+	}{{"app=soak", "soak", 0}, {"", "soak", time.Second}, {"app=soak", "", time.Second}} {
+		if _, err := client.StartPeriodicCollection(context.Background(), tc.selector, tc.interval, tc.test); err == nil {
+			t.Errorf("Expected an error for %+v", tc)
+		}
+	}
+}
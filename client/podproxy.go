@@ -0,0 +1,57 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"k8s.io/client-go/rest"
+)
+
+// CollectCoverageViaProxy collects coverage through the API server's pod proxy
+// (/api/v1/namespaces/<ns>/pods/<pod>:<port>/proxy/coverage), a plain HTTPS request for clusters
+// or proxies blocking the upgraded connections of port-forwarding. It needs `get` and `create` on
+// `pods/proxy`. Incremental transfers and counter resets work like with port-forwarding.
+func (c *CoverageClient) CollectCoverageViaProxy(ctx context.Context, podName, testName string, targetPort int) error {
+	c.logf("📊 Collecting coverage from pod %s via API server proxy for test: %s\n", podName, testName)
+
+	if err := c.collectCoverageViaProxy(ctx, podName, testName, targetPort); err != nil {
+		return err
+	}
+
+	if err := c.savePodMetadata(ctx, podName, "", testName, targetPort, MethodProxy); err != nil {
+		c.logf("⚠️  Failed to save pod metadata: %v\n", err)
+	}
+	c.recordCollection(ctx, podName, testName, MethodProxy)
+
+	c.logf("✅ Coverage collected successfully for test: %s\n", testName)
+	return nil
+}
+
+// collectCoverageViaProxy requests coverage from the pod through the API server's pod proxy
+func (c *CoverageClient) collectCoverageViaProxy(ctx context.Context, podName, testName string, targetPort int) error {
+	coverageURL, httpClient, err := c.podProxy(podName, targetPort)
+	if err != nil {
+		return err
+	}
+	return c.collectCoverageFromURLWithClient(ctx, httpClient, coverageURL, testName)
+}
+
+// podProxy returns the coverage URL of a pod port proxied by the API server and an HTTP client
+// authenticated like the client's other API requests
+func (c *CoverageClient) podProxy(podName string, targetPort int) (string, *http.Client, error) {
+	if c.restConfig == nil {
+		return "", nil, fmt.Errorf("pod proxy: no cluster configuration")
+	}
+	base, _, err := rest.DefaultServerUrlFor(c.restConfig)
+	if err != nil {
+		return "", nil, fmt.Errorf("pod proxy: %w", err)
+	}
+	httpClient, err := rest.HTTPClientFor(c.restConfig)
+	if err != nil {
+		return "", nil, fmt.Errorf("pod proxy: %w", err)
+	}
+	coverageURL := base.JoinPath("api/v1/namespaces", c.namespace, "pods", podName+":"+strconv.Itoa(targetPort), "proxy/coverage")
+	return coverageURL.String(), httpClient, nil
+}
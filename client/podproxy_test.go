@@ -0,0 +1,56 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestCollectCoverageViaProxy(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+
+	pod, _, _ := newFallbackTestObjects()
+	client := &CoverageClient{
+		clientset:    fake.NewSimpleClientset(pod),
+		restConfig:   &rest.Config{Host: server.URL + "/k8s/clusters/c-1", BearerToken: "token"},
+		namespace:    "default",
+		outputDir:    t.TempDir(),
+		counterBases: &counterBases{},
+		metaCache:    &metaCache{},
+		quiet:        true,
+	}
+	client.SetRecordCollection(false)
+
+	method, err := client.CollectCoverageWithFallback(context.Background(), "demo-pod", "proxy-test", 9095, FallbackOptions{Methods: []CollectionMethod{MethodProxy}})
+	if err != nil || method != MethodProxy {
+		t.Fatalf("CollectCoverageWithFallback failed: %v (%s)", err, method)
+	}
+	if len(paths) != 1 || paths[0] != "/k8s/clusters/c-1/api/v1/namespaces/default/pods/demo-pod:9095/proxy/coverage" {
+		t.Errorf("Expected the pod proxy path below the server prefix, got %v", paths)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "proxy-test", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the covdata saved: %v", err)
+	}
+
+	client.restConfig = nil
+	if err := client.CollectCoverageViaProxy(context.Background(), "demo-pod", "proxy-test", 9095); err == nil {
+		t.Error("Expected an error without cluster configuration")
+	}
+}
@@ -0,0 +1,163 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodWatch collects coverage from pods as they're terminated during a suite, see WatchPods
+type PodWatch struct {
+	client   *CoverageClient
+	selector string
+	testName string
+	port     int
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	collected map[string]bool // Pods with a final snapshot, or whose snapshot is in progress
+	errs      []error
+}
+
+// WatchPods watches the pods matching labelSelector with an informer while the suite runs. When
+// one is deleted (a rollout, eviction or scale-down), its coverage is collected into <test>/<pod>
+// while it terminates: the kubelet keeps its containers running for the preStop hooks and the
+// termination grace period. Finish collects from the pods running at the end, e.g. the
+// replacements, and merges everything. Pods deleted without a grace period can't be collected.
+func (c *CoverageClient) WatchPods(ctx context.Context, labelSelector, testName string, port int) (*PodWatch, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+
+	// Stop ends the informer but lets the snapshots in progress finish
+	watchCtx, cancel := context.WithCancel(ctx)
+	w := &PodWatch{
+		client:    c,
+		selector:  labelSelector,
+		testName:  testName,
+		port:      port,
+		cancel:    cancel,
+		stopped:   make(chan struct{}),
+		collected: make(map[string]bool),
+	}
+
+	informer := coreinformers.NewFilteredPodInformer(c.clientset, c.namespace, 0, cache.Indexers{}, func(opts *metav1.ListOptions) {
+		opts.LabelSelector = labelSelector
+	})
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj any) {
+			if pod, ok := obj.(*corev1.Pod); ok && pod.DeletionTimestamp != nil && pod.Status.Phase == corev1.PodRunning {
+				w.collectTerminating(ctx, pod.Name)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok && !w.claim(pod.Name, false) {
+				c.logf("⚠️  Pod %s was deleted before its coverage could be collected\n", pod.Name)
+			}
+		},
+	})
+	go func() {
+		defer close(w.stopped)
+		informer.Run(watchCtx.Done())
+	}()
+	if !cache.WaitForCacheSync(watchCtx.Done(), informer.HasSynced) {
+		cancel()
+		<-w.stopped
+		return nil, fmt.Errorf("watch pods matching %s: %w", labelSelector, context.Cause(watchCtx))
+	}
+
+	c.logf("👀 Watching pods matching %s for test: %s\n", labelSelector, testName)
+	return w, nil
+}
+
+// claim marks a pod as collected and reports whether it already was. With record unset, it only
+// reports.
+func (w *PodWatch) claim(podName string, record bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	claimed := w.collected[podName]
+	if record {
+		w.collected[podName] = true
+	}
+	return claimed
+}
+
+// collectTerminating takes the final snapshot of a terminating pod, once
+func (w *PodWatch) collectTerminating(ctx context.Context, podName string) {
+	if w.claim(podName, true) {
+		return
+	}
+	w.client.logf("🛑 Pod %s is terminating, collecting its coverage\n", podName)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		_, err := w.client.Collect(ctx, CollectOptions{TestName: path.Join(w.testName, podName), PodName: podName, Port: w.port})
+		if err != nil {
+			w.mu.Lock()
+			w.errs = append(w.errs, fmt.Errorf("pod %s: %w", podName, err))
+			w.mu.Unlock()
+		}
+	}()
+}
+
+// Finish stops watching, waits for the snapshots of terminating pods, collects from the pods
+// running now into <test>/<pod> and merges every pod's coverage into outputTestName, with its
+// coverage.out. Pods that failed to collect are reported in the error, after the merge of the
+// others.
+func (w *PodWatch) Finish(ctx context.Context, outputTestName string) error {
+	w.Stop()
+
+	pods, err := w.client.runningPods(ctx, w.selector)
+	if err != nil && !errors.Is(err, ErrNoPodsFound) && !errors.Is(err, ErrPodNotRunning) {
+		return err
+	}
+	var remaining []string
+	for _, pod := range pods {
+		if !w.claim(pod, true) {
+			remaining = append(remaining, pod)
+		}
+	}
+	_, collectErr := w.client.collectReplicas(ctx, remaining, 0, func(pod string) CollectOptions {
+		return CollectOptions{TestName: path.Join(w.testName, pod), PodName: pod, Port: w.port}
+	})
+
+	w.mu.Lock()
+	errs := append(w.errs, collectErr)
+	w.mu.Unlock()
+
+	var tests []string
+	for pod := range w.collected {
+		if dir := path.Join(w.testName, pod); len(covdataFiles(w.client.testDir(dir))) > 0 {
+			tests = append(tests, dir)
+		}
+	}
+	sort.Strings(tests)
+	if len(tests) == 0 {
+		return errors.Join(append(errs, fmt.Errorf("no coverage collected from pods matching %s", w.selector))...)
+	}
+	if err := w.client.MergeCoverage(outputTestName, tests...); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// Stop stops watching and waits for the snapshots of terminating pods, without collecting
+// from the running pods
+func (w *PodWatch) Stop() {
+	w.cancel()
+	<-w.stopped
+	w.wg.Wait()
+}
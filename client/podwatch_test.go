@@ -0,0 +1,74 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWatchPods(t *testing.T) {
+	labels := map[string]string{"app": "app"}
+	client, forwarder := newReplicaClient(t, "", replicaPod("app-a", corev1.PodRunning, labels))
+	ctx := context.Background()
+	pods := client.clientset.CoreV1().Pods("default")
+
+	watch, err := client.WatchPods(ctx, "app=app", "e2e", 9095)
+	if err != nil {
+		t.Fatalf("WatchPods failed: %v", err)
+	}
+
+	// A rollout replaces app-a: it terminates while app-b starts
+	terminating := replicaPod("app-a", corev1.PodRunning, labels)
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if _, err := pods.Update(ctx, terminating, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to mark the pod terminating: %v", err)
+	}
+	snapshot := filepath.Join(client.outputDir, "e2e", "app-a")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(covdataFiles(snapshot)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the terminating pod to be collected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := pods.Delete(ctx, "app-a", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete the pod: %v", err)
+	}
+	if _, err := pods.Create(ctx, replicaPod("app-b", corev1.PodRunning, labels), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create the replacement pod: %v", err)
+	}
+
+	// The fake coverage data can't be merged, but both pods are collected first
+	err = watch.Finish(ctx, "all")
+	if err == nil || !strings.Contains(err.Error(), "merge") {
+		t.Fatalf("Expected the merge of the fake coverage data to fail, got %v", err)
+	}
+	if !slices.Equal(slices.Compact(slices.Clone(forwarder.pods)), []string{"app-a", "app-b"}) {
+		t.Errorf("Expected the terminating pod and its replacement to be collected once each, got %v", forwarder.pods)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "app-b")); err != nil {
+		t.Errorf("Expected the replacement pod's coverage in e2e/app-b: %v", err)
+	}
+}
+
+func TestWatchPods_NothingCollected(t *testing.T) {
+	client, _ := newReplicaClient(t, "")
+	watch, err := client.WatchPods(context.Background(), "app=app", "e2e", 9095)
+	if err != nil {
+		t.Fatalf("WatchPods failed: %v", err)
+	}
+	if err := watch.Finish(context.Background(), "all"); err == nil || !strings.Contains(err.Error(), "no coverage collected") {
+		t.Fatalf("Expected an error without pods, got %v", err)
+	}
+
+	if _, err := client.WatchPods(context.Background(), "app=app", "", 9095); err == nil {
+		t.Error("Expected an error without a test name")
+	}
+}
@@ -0,0 +1,319 @@
+package coverageclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	spdystream "k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	portforwardconstants "k8s.io/apimachinery/pkg/util/portforward"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/client-go/transport/websocket"
+)
+
+// PortForwarder opens port-forward tunnels to pods. The client forwards through the API server
+// unless another implementation is set with SetPortForwarder, e.g. a fake in unit tests.
+type PortForwarder interface {
+	// ForwardPort forwards a local port to targetPort of the pod and returns once the tunnel is
+	// ready. The tunnel is torn down when it is closed or ctx is done, whichever comes first.
+	ForwardPort(ctx context.Context, namespace, podName string, targetPort int) (PortForward, error)
+}
+
+// PortForward is a running port-forward tunnel
+type PortForward interface {
+	// LocalPort returns the local port forwarded to the pod
+	LocalPort() int
+	// Close stops forwarding and waits for the tunnel to shut down
+	Close() error
+}
+
+// SetPortForwarder replaces how the client forwards ports to pods (default: through the API server)
+func (c *CoverageClient) SetPortForwarder(forwarder PortForwarder) {
+	c.portForwarder = forwarder
+}
+
+// portForwardTransport caches what port-forward dialers share across collections: the TLS
+// configuration built from the REST config, with a session cache so later connections to the API
+// server resume the TLS session instead of a full handshake, and the proxy function. Upgrade round
+// trippers hold their connection and can't be reused, but are cheap to build from the cache.
+type portForwardTransport struct {
+	mu        sync.Mutex
+	config    *rest.Config // Config the cache was built from
+	key       string       // Its host and TLS settings at the time, to notice changes
+	tlsConfig *tls.Config
+	proxy     func(*http.Request) (*url.URL, error)
+}
+
+// get returns the TLS configuration and proxy for config, rebuilding them when the client's
+// REST config was replaced or its host or TLS settings changed (e.g. rotated certificates)
+func (t *portForwardTransport) get(config *rest.Config) (*tls.Config, func(*http.Request) (*url.URL, error), error) {
+	if t == nil {
+		return newPortForwardTLS(config)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := portForwardTransportKey(config)
+	if t.config == config && t.key == key {
+		return t.tlsConfig, t.proxy, nil
+	}
+
+	tlsConfig, proxy, err := newPortForwardTLS(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.config, t.key, t.tlsConfig, t.proxy = config, key, tlsConfig, proxy
+	return tlsConfig, proxy, nil
+}
+
+// portForwardTransportKey identifies the settings the cached TLS configuration depends on
+func portForwardTransportKey(config *rest.Config) string {
+	return fmt.Sprintf("%s %+v", config.Host, config.TLSClientConfig)
+}
+
+// newPortForwardTLS builds the TLS configuration and proxy of port-forward connections like
+// client-go's round trippers, adding a TLS session cache
+func newPortForwardTLS(config *rest.Config) (*tls.Config, func(*http.Request) (*url.URL, error), error) {
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create TLS config: %w", err)
+	}
+	if tlsConfig != nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	proxy := config.Proxy
+	if proxy == nil {
+		proxy = utilnet.NewProxierWithNoProxyCIDR(http.ProxyFromEnvironment)
+	}
+	return tlsConfig, proxy, nil
+}
+
+// portForwardTunnel is a running port-forward through the API server, see startPortForward
+type portForwardTunnel struct {
+	localPort int // Local port forwarded to the pod
+
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	exited     chan struct{} // Closed when ForwardPorts returned, err holds its result
+	err        error
+	httpClient *http.Client // Client whose idle connections go through the tunnel
+}
+
+// setupPortForward forwards a local port to the pod with the client's PortForwarder. The tunnel is
+// torn down when it is closed or ctx is done, whichever comes first.
+func (c *CoverageClient) setupPortForward(ctx context.Context, podName string, targetPort int) (PortForward, error) {
+	ctx, step := startStep(ctx, "port-forward", attribute.String("pod", podName), attribute.Int("port", targetPort))
+	forwarder := c.portForwarder
+	if forwarder == nil {
+		forwarder = apiServerPortForwarder{client: c}
+	}
+	tunnel, err := forwarder.ForwardPort(ctx, c.namespace, podName, targetPort)
+	step.end(err)
+	return tunnel, err
+}
+
+// apiServerPortForwarder is the default PortForwarder, tunneling through the API server's
+// pods/portforward subresource
+type apiServerPortForwarder struct {
+	client *CoverageClient
+}
+
+func (f apiServerPortForwarder) ForwardPort(ctx context.Context, namespace, podName string, targetPort int) (PortForward, error) {
+	tunnel, err := f.client.startPortForward(ctx, namespace, podName, targetPort)
+	if err != nil {
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// startPortForward starts port forwarding to the pod and waits until it is ready
+func (c *CoverageClient) startPortForward(ctx context.Context, namespace, podName string, targetPort int) (*portForwardTunnel, error) {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward", namespace, podName)
+	hostIP := strings.TrimPrefix(c.restConfig.Host, "https://")
+	serverURL, err := url.Parse(fmt.Sprintf("https://%s%s", hostIP, path))
+	if err != nil {
+		return nil, fmt.Errorf("parse server URL: %w", err)
+	}
+
+	dialer, err := c.newPortForwardDialer(ctx, serverURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.forwardPort(ctx, dialer, targetPort)
+}
+
+// forwardPort forwards a local port (chosen by the system) through dialer to targetPort
+func (c *CoverageClient) forwardPort(ctx context.Context, dialer httpstream.Dialer, targetPort int) (*portForwardTunnel, error) {
+	tunnel := &portForwardTunnel{
+		stopChan:   make(chan struct{}),
+		exited:     make(chan struct{}),
+		httpClient: c.httpClient,
+	}
+	readyChan := make(chan struct{})
+
+	ports := []string{fmt.Sprintf("0:%d", targetPort)}
+	forwarder, err := portforward.New(dialer, ports, tunnel.stopChan, readyChan, io.Discard, io.Discard)
+	if err != nil {
+		return nil, fmt.Errorf("create port forwarder: %w", err)
+	}
+
+	go func() {
+		defer close(tunnel.exited)
+		if tunnel.err = forwarder.ForwardPorts(); tunnel.err != nil {
+			c.logf("⚠️  Port forward error: %v\n", tunnel.err)
+		}
+	}()
+
+	// Tear the tunnel down with the caller's context, until it is stopped otherwise
+	go func() {
+		select {
+		case <-ctx.Done():
+			tunnel.stop()
+		case <-tunnel.stopChan:
+		case <-tunnel.exited:
+		}
+	}()
+
+	// Wait for ready signal. Failing paths only stop the forwarder, which may still be dialing.
+	select {
+	case <-readyChan:
+		forwardedPorts, err := forwarder.GetPorts()
+		if err != nil || len(forwardedPorts) == 0 {
+			tunnel.Close()
+			return nil, fmt.Errorf("get forwarded ports: %w", err)
+		}
+		tunnel.localPort = int(forwardedPorts[0].Local)
+		c.logf("✅ Port forward ready: localhost:%d -> pod:%d\n", tunnel.localPort, targetPort)
+		return tunnel, nil
+	case <-tunnel.exited:
+		if err := ctx.Err(); err != nil {
+			return nil, portForwardWaitError(err)
+		}
+		return nil, fmt.Errorf("port forward failed: %w", tunnel.err)
+	case <-ctx.Done():
+		tunnel.stop()
+		return nil, portForwardWaitError(ctx.Err())
+	case <-time.After(30 * time.Second):
+		tunnel.stop()
+		return nil, ErrPortForwardTimeout
+	}
+}
+
+// portForwardWaitError wraps the context error that ended the wait for a port-forward, matching
+// ErrPortForwardTimeout when the context's deadline passed
+func portForwardWaitError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrPortForwardTimeout, err)
+	}
+	return fmt.Errorf("wait for port forward: %w", err)
+}
+
+// LocalPort returns the local port forwarded to the pod
+func (t *portForwardTunnel) LocalPort() int {
+	return t.localPort
+}
+
+// stop signals the forwarder to stop without waiting for it
+func (t *portForwardTunnel) stop() {
+	t.stopOnce.Do(func() { close(t.stopChan) })
+}
+
+// Close closes the idle HTTP connections through the tunnel, so their streams end instead of being
+// cut off half-closed, stops forwarding and waits until the forwarder closed its listener and
+// stream connection. It returns the forwarder's error (e.g. a lost connection to the pod) and can
+// be called more than once.
+func (t *portForwardTunnel) Close() error {
+	if t.httpClient != nil {
+		t.httpClient.CloseIdleConnections()
+	}
+	t.stop()
+	<-t.exited
+	return t.err
+}
+
+// newPortForwardDialer returns a dialer that tunnels port-forwarding over WebSockets and falls back
+// to SPDY when the API server doesn't support it (before Kubernetes 1.30). WebSockets keep
+// collection working on API servers and proxies that no longer allow SPDY upgrades. Both dialers
+// share the client's cached TLS configuration. WebSocket dials connect within ctx and its deadline
+// bounds the upgrade.
+func (c *CoverageClient) newPortForwardDialer(ctx context.Context, serverURL *url.URL) (httpstream.Dialer, error) {
+	tlsConfig, proxy, err := c.portForward.get(c.restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrader, err := spdystream.NewRoundTripperWithConfig(spdystream.RoundTripperConfig{
+		TLS:        tlsConfig,
+		Proxier:    proxy,
+		PingPeriod: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create round tripper: %w", err)
+	}
+	transport, err := rest.HTTPWrappersForConfig(c.restConfig, upgrader)
+	if err != nil {
+		return nil, fmt.Errorf("create round tripper: %w", err)
+	}
+	spdyDialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", serverURL)
+
+	websocketDialer := &websocketTunnelDialer{ctx: ctx, url: serverURL, config: c.restConfig, tlsConfig: tlsConfig, proxy: proxy}
+	return portforward.NewFallbackDialer(websocketDialer, spdyDialer, shouldFallbackToSPDY), nil
+}
+
+// shouldFallbackToSPDY reports whether a WebSocket dial failed in the protocol upgrade (e.g. on an
+// API server without WebSocket port-forwarding) or at an HTTPS proxy the WebSocket dialer can't use
+func shouldFallbackToSPDY(err error) bool {
+	return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+}
+
+// websocketTunnelDialer tunnels SPDY port-forward streams over a WebSocket like
+// portforward.NewSPDYOverWebsocketDialer, which builds its own TLS configuration instead of
+// taking the cached one, and dials without a context
+type websocketTunnelDialer struct {
+	ctx       context.Context
+	url       *url.URL
+	config    *rest.Config
+	tlsConfig *tls.Config
+	proxy     func(*http.Request) (*url.URL, error)
+}
+
+func (d *websocketTunnelDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	upgrader := &websocket.RoundTripper{TLSConfig: d.tlsConfig, Proxier: d.proxy}
+	transport, err := rest.HTTPWrappersForConfig(d.config, upgrader)
+	if err != nil {
+		return nil, "", fmt.Errorf("create websocket round tripper: %w", err)
+	}
+
+	// WebSockets require GET, the tunneling prefix tells the API server to expect SPDY inside
+	req, err := http.NewRequestWithContext(d.ctx, "GET", d.url.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	tunnelingProtocols := make([]string, 0, len(protocols))
+	for _, protocol := range protocols {
+		tunnelingProtocols = append(tunnelingProtocols, portforwardconstants.WebsocketsSPDYTunnelingPrefix+protocol)
+	}
+	conn, err := websocket.Negotiate(transport, upgrader, req, tunnelingProtocols...)
+	if err != nil {
+		return nil, "", err
+	}
+	if conn == nil {
+		return nil, "", fmt.Errorf("negotiated websocket connection is nil")
+	}
+	protocol := strings.TrimPrefix(conn.Subprotocol(), portforwardconstants.WebsocketsSPDYTunnelingPrefix)
+
+	spdyConn, err := spdystream.NewClientConnectionWithPings(portforward.NewTunnelingConnection("client", conn), portforward.PingPeriod)
+	return spdyConn, protocol, err
+}
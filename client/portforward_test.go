@@ -0,0 +1,361 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+func TestNewPortForwardDialer_FallsBackToSPDY(t *testing.T) {
+	var upgrades []string
+	var mu sync.Mutex
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upgrades = append(upgrades, strings.ToLower(r.Header.Get("Upgrade")))
+		mu.Unlock()
+		http.Error(w, "upgrade refused", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{restConfig: &rest.Config{
+		Host:            server.URL,
+		TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+	}}
+	serverURL, _ := url.Parse(server.URL + "/api/v1/namespaces/default/pods/app/portforward")
+	dialer, err := client.newPortForwardDialer(context.Background(), serverURL)
+	if err != nil {
+		t.Fatalf("newPortForwardDialer failed: %v", err)
+	}
+
+	if _, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name); err == nil {
+		t.Fatal("Expected dial to fail against a server refusing upgrades")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(upgrades) != 2 || upgrades[0] != "websocket" || !strings.HasPrefix(upgrades[1], "spdy/") {
+		t.Errorf("Expected a WebSocket attempt followed by SPDY, got %v", upgrades)
+	}
+}
+
+func TestShouldFallbackToSPDY(t *testing.T) {
+	if !shouldFallbackToSPDY(fmt.Errorf("dial: %w", &httpstream.UpgradeFailureError{Cause: fmt.Errorf("bad handshake")})) {
+		t.Error("Expected fallback after a failed upgrade")
+	}
+	if shouldFallbackToSPDY(fmt.Errorf("connection refused")) || shouldFallbackToSPDY(nil) {
+		t.Error("Expected no fallback for other errors")
+	}
+}
+
+func TestNewPortForwardDialer_ResumesTLSSessions(t *testing.T) {
+	var resumed []bool
+	var mu sync.Mutex
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		resumed = append(resumed, r.TLS.DidResume)
+		mu.Unlock()
+		http.Error(w, "upgrade refused", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{
+		restConfig: &rest.Config{
+			Host:            server.URL,
+			TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+		},
+		portForward: &portForwardTransport{},
+	}
+	serverURL, _ := url.Parse(server.URL + "/api/v1/namespaces/default/pods/app/portforward")
+	for i := 0; i < 2; i++ {
+		dialer, err := client.newPortForwardDialer(context.Background(), serverURL)
+		if err != nil {
+			t.Fatalf("newPortForwardDialer failed: %v", err)
+		}
+		dialer.Dial(portforward.PortForwardProtocolV1Name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(resumed) != 4 || resumed[0] {
+		t.Fatalf("Expected four upgrade attempts starting with a full handshake, got %v", resumed)
+	}
+	for i, r := range resumed[1:] {
+		if !r {
+			t.Errorf("Expected connection %d to resume the TLS session", i+2)
+		}
+	}
+}
+
+func TestPortForwardTransport_Invalidation(t *testing.T) {
+	config := &rest.Config{Host: "https://api.example.com:6443", TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+	transport := &portForwardTransport{}
+
+	first, _, err := transport.get(config)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if cached, _, _ := transport.get(config); cached != first {
+		t.Error("Expected the TLS config to be reused for an unchanged REST config")
+	}
+
+	config.TLSClientConfig.ServerName = "api.internal"
+	changed, _, _ := transport.get(config)
+	if changed == first || changed.ServerName != "api.internal" {
+		t.Error("Expected the TLS config to be rebuilt after the TLS settings changed")
+	}
+
+	replaced := rest.CopyConfig(config)
+	if rebuilt, _, _ := transport.get(replaced); rebuilt == changed {
+		t.Error("Expected the TLS config to be rebuilt for a replaced REST config")
+	}
+}
+
+func TestStartPortForward_ContextCancel(t *testing.T) {
+	// API server that doesn't answer upgrade requests until the test ends
+	release := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &CoverageClient{namespace: "default", restConfig: &rest.Config{
+		Host:            server.URL,
+		TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := client.startPortForward(ctx, "default", "app", 9095)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to end the wait promptly, took %v", elapsed)
+	}
+}
+
+func TestStartPortForward_DialFailure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "pods \"app\" is forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{namespace: "default", restConfig: &rest.Config{
+		Host:            server.URL,
+		TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+	}}
+
+	start := time.Now()
+	if _, err := client.startPortForward(context.Background(), "default", "app", 9095); err == nil || !strings.Contains(err.Error(), "port forward failed") {
+		t.Errorf("Expected the forwarder's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected a failed dial to end the wait promptly, took %v", elapsed)
+	}
+}
+
+// fakeStreamDialer hands out stream connections that don't reach any pod
+type fakeStreamDialer struct {
+	mu    sync.Mutex
+	conns []*fakeStreamConnection
+}
+
+func (d *fakeStreamDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	conn := &fakeStreamConnection{closed: make(chan bool)}
+	d.mu.Lock()
+	d.conns = append(d.conns, conn)
+	d.mu.Unlock()
+	return conn, portforward.PortForwardProtocolV1Name, nil
+}
+
+type fakeStreamConnection struct {
+	once   sync.Once
+	closed chan bool
+}
+
+func (c *fakeStreamConnection) CreateStream(http.Header) (httpstream.Stream, error) {
+	return nil, fmt.Errorf("no streams in tests")
+}
+func (c *fakeStreamConnection) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+func (c *fakeStreamConnection) CloseChan() <-chan bool             { return c.closed }
+func (c *fakeStreamConnection) SetIdleTimeout(time.Duration)       {}
+func (c *fakeStreamConnection) RemoveStreams(...httpstream.Stream) {}
+
+func TestForwardPort_CloseReleasesEverything(t *testing.T) {
+	client := &CoverageClient{httpClient: &http.Client{}}
+	dialer := &fakeStreamDialer{}
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		tunnel, err := client.forwardPort(context.Background(), dialer, 9095)
+		if err != nil {
+			t.Fatalf("forwardPort failed: %v", err)
+		}
+		if err := tunnel.Close(); err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+		tunnel.Close() // Idempotent
+
+		// Close returns after the forwarder released the listener and the stream connection
+		if conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", tunnel.localPort)); err == nil {
+			conn.Close()
+			t.Errorf("Expected local port %d to be closed", tunnel.localPort)
+		}
+		select {
+		case <-dialer.conns[i].closed:
+		default:
+			t.Error("Expected the stream connection to be closed")
+		}
+	}
+
+	// Goroutines of closed tunnels must not accumulate
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Errorf("Expected goroutines to return to %d after closing the tunnels, got %d", before, after)
+	}
+}
+
+func TestForwardPort_LostConnection(t *testing.T) {
+	client := &CoverageClient{}
+	dialer := &fakeStreamDialer{}
+
+	tunnel, err := client.forwardPort(context.Background(), dialer, 9095)
+	if err != nil {
+		t.Fatalf("forwardPort failed: %v", err)
+	}
+	dialer.conns[0].Close()
+	if err := tunnel.Close(); !errors.Is(err, portforward.ErrLostConnectionToPod) {
+		t.Errorf("Expected Close to report the lost connection, got %v", err)
+	}
+}
+
+func TestForwardPort_ContextCancelStopsTunnel(t *testing.T) {
+	client := &CoverageClient{}
+	dialer := &fakeStreamDialer{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tunnel, err := client.forwardPort(ctx, dialer, 9095)
+	if err != nil {
+		t.Fatalf("forwardPort failed: %v", err)
+	}
+	cancel()
+
+	select {
+	case <-tunnel.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected cancelling the context to stop the forwarder")
+	}
+	if err := tunnel.Close(); err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+}
+
+// fakePortForwarder "forwards" every pod port to a local test server
+type fakePortForwarder struct {
+	port    int
+	tunnels []*fakePortForward
+}
+
+func (f *fakePortForwarder) ForwardPort(ctx context.Context, namespace, podName string, targetPort int) (PortForward, error) {
+	tunnel := &fakePortForward{port: f.port, namespace: namespace, podName: podName}
+	f.tunnels = append(f.tunnels, tunnel)
+	return tunnel, nil
+}
+
+type fakePortForward struct {
+	port               int
+	namespace, podName string
+	closed             bool
+}
+
+func (t *fakePortForward) LocalPort() int { return t.port }
+func (t *fakePortForward) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestCollectCoverageFromPod_FakePortForwarder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-7d9f", Namespace: "default"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "proxy"},
+			{Name: "app", Image: "quay.io/org/app:cover", Ports: []corev1.ContainerPort{{ContainerPort: 9095}}},
+		}},
+	}
+	forwarder := &fakePortForwarder{port: port}
+	client := &CoverageClient{
+		clientset:      fake.NewSimpleClientset(pod),
+		namespace:      "default",
+		outputDir:      t.TempDir(),
+		httpClient:     server.Client(),
+		recordDisabled: true,
+		quiet:          true,
+	}
+	client.SetPortForwarder(forwarder)
+
+	result, err := client.CollectCoverageFromPod(context.Background(), "app-7d9f", "e2e", 9095)
+	if err != nil {
+		t.Fatalf("CollectCoverageFromPod failed: %v", err)
+	}
+	if result.Pod != "app-7d9f" || result.Container != "app" || result.Method != MethodPortForward || result.Duration <= 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(result.Files) != 2 || result.Bytes() != int64(len("meta")+len("counters")) || result.Dir != filepath.Join(client.outputDir, "e2e") {
+		t.Errorf("Expected the covdata files with their sizes, got %+v", result)
+	}
+	for _, path := range result.Paths() {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected the result to list existing files: %v", err)
+		}
+	}
+
+	if len(forwarder.tunnels) != 1 || forwarder.tunnels[0].podName != "app-7d9f" || forwarder.tunnels[0].namespace != "default" {
+		t.Fatalf("Expected one tunnel to default/app-7d9f, got %+v", forwarder.tunnels)
+	}
+	if !forwarder.tunnels[0].closed {
+		t.Error("Expected the tunnel to be closed after collection")
+	}
+	metadata, err := client.readPodMetadata("e2e")
+	if err != nil || metadata.Container.Name != "app" || metadata.CollectionMethod != MethodPortForward || metadata.SchemaVersion != PodMetadataSchemaVersion {
+		t.Errorf("Unexpected metadata %+v (%v)", metadata, err)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the meta-data to be saved: %v", err)
+	}
+}
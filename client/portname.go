@@ -0,0 +1,36 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResolvePortName returns the number and container of the container port named portName (e.g.
+// "coverage") in the spec of a pod, so manifests can move the coverage server without tests
+// hardcoding its port. See CollectOptions.PortName.
+func (c *CoverageClient) ResolvePortName(ctx context.Context, podName, portName string) (int, string, error) {
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("get pod details: %w", err)
+	}
+	port, container, ok := namedContainerPort(pod.Spec.Containers, portName)
+	if !ok {
+		return 0, "", fmt.Errorf("pod %s has no container port named %q", podName, portName)
+	}
+	return port, container, nil
+}
+
+// namedContainerPort finds the container port named name, preferring TCP ports
+func namedContainerPort(containers []corev1.Container, name string) (int, string, bool) {
+	for _, container := range containers {
+		for _, port := range container.Ports {
+			if port.Name == name && (port.Protocol == "" || port.Protocol == corev1.ProtocolTCP) {
+				return int(port.ContainerPort), container.Name, true
+			}
+		}
+	}
+	return 0, "", false
+}
@@ -0,0 +1,60 @@
+package coverageclient
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func namedPortPod() *corev1.Pod {
+	pod := replicaPod("api", corev1.PodRunning, nil)
+	pod.Spec.Containers = []corev1.Container{
+		{Name: "app", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+		{Name: "sidecar", Ports: []corev1.ContainerPort{
+			{Name: "coverage", ContainerPort: 9100, Protocol: corev1.ProtocolUDP},
+			{Name: "coverage", ContainerPort: 9096},
+		}},
+	}
+	return pod
+}
+
+func TestResolvePortName(t *testing.T) {
+	client, _ := newReplicaClient(t, "", namedPortPod())
+
+	port, container, err := client.ResolvePortName(context.Background(), "api", "coverage")
+	if err != nil || port != 9096 || container != "sidecar" {
+		t.Errorf("Expected the sidecar's TCP port 9096, got %d in %q (%v)", port, container, err)
+	}
+	if _, _, err := client.ResolvePortName(context.Background(), "api", "metrics"); err == nil || !strings.Contains(err.Error(), `"metrics"`) {
+		t.Errorf("Expected an error for a missing port name, got %v", err)
+	}
+	if _, _, err := client.ResolvePortName(context.Background(), "missing", "coverage"); err == nil {
+		t.Error("Expected an error for a missing pod")
+	}
+}
+
+func TestCollect_PortName(t *testing.T) {
+	client, forwarder := newReplicaClient(t, "", namedPortPod())
+
+	if _, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", PodName: "api", PortName: "coverage"}); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if !slices.Contains(forwarder.ports, 9096) || slices.Contains(forwarder.ports, DefaultCoveragePort) {
+		t.Errorf("Expected port-forwards to the named port, got %v", forwarder.ports)
+	}
+
+	for _, opts := range []CollectOptions{
+		{TestName: "e2e", PodName: "api", PortName: "coverage", Port: 9095},
+		{TestName: "e2e", URL: "http://localhost:9095/coverage", PortName: "coverage"},
+	} {
+		if _, err := client.Collect(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "PortName") {
+			t.Errorf("Expected an error for %+v, got %v", opts, err)
+		}
+	}
+	if _, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", PodName: "api", PortName: "metrics"}); err == nil {
+		t.Error("Expected an error for a missing port name")
+	}
+}
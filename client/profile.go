@@ -0,0 +1,346 @@
+package coverageclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProfileBlock is one basic block of a text coverage profile (coverage.out)
+type ProfileBlock struct {
+	File      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+	Count     int
+}
+
+// Profile is a parsed text coverage profile
+type Profile struct {
+	Mode   string
+	Blocks []ProfileBlock
+}
+
+// CoverageStats counts covered statements
+type CoverageStats struct {
+	Statements int `json:"statements"`
+	Covered    int `json:"covered"`
+}
+
+// Percent returns the statement coverage in percent (0 when there are no statements)
+func (s CoverageStats) Percent() float64 {
+	if s.Statements == 0 {
+		return 0
+	}
+	return float64(s.Covered) * 100 / float64(s.Statements)
+}
+
+func (s *CoverageStats) add(b ProfileBlock) {
+	s.Statements += b.NumStmt
+	if b.Count > 0 {
+		s.Covered += b.NumStmt
+	}
+}
+
+// ParseProfile parses a text coverage profile as written by `go tool covdata textfmt`.
+// Blocks reported more than once (e.g. in concatenated profiles) are merged.
+func ParseProfile(r io.Reader) (*Profile, error) {
+	profile := &Profile{}
+	index := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if mode, ok := strings.CutPrefix(line, "mode:"); ok {
+			profile.Mode = strings.TrimSpace(mode)
+			continue
+		}
+
+		block, err := parseProfileLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		key := line[:strings.LastIndex(line, " ")]
+		if i, ok := index[key]; ok {
+			if profile.Mode == "set" {
+				profile.Blocks[i].Count = max(profile.Blocks[i].Count, block.Count)
+			} else {
+				profile.Blocks[i].Count += block.Count
+			}
+			continue
+		}
+		index[key] = len(profile.Blocks)
+		profile.Blocks = append(profile.Blocks, block)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read profile: %w", err)
+	}
+	return profile, nil
+}
+
+// parseProfileLine parses "path/to/file.go:line.col,line.col numStmt count"
+func parseProfileLine(line string) (ProfileBlock, error) {
+	file, position, ok := cutProfileLine(line)
+	if !ok {
+		return ProfileBlock{}, fmt.Errorf("invalid profile line: %q", line)
+	}
+	block := ProfileBlock{File: file}
+
+	fields := strings.Fields(position)
+	if len(fields) != 3 {
+		return ProfileBlock{}, fmt.Errorf("invalid profile line: %q", line)
+	}
+	start, end, ok := strings.Cut(fields[0], ",")
+	if !ok {
+		return ProfileBlock{}, fmt.Errorf("invalid block position: %q", fields[0])
+	}
+
+	var err error
+	if block.StartLine, block.StartCol, err = parseLineCol(start); err != nil {
+		return ProfileBlock{}, err
+	}
+	if block.EndLine, block.EndCol, err = parseLineCol(end); err != nil {
+		return ProfileBlock{}, err
+	}
+	if block.NumStmt, err = strconv.Atoi(fields[1]); err != nil {
+		return ProfileBlock{}, fmt.Errorf("invalid statement count: %q", fields[1])
+	}
+	if block.Count, err = strconv.Atoi(fields[2]); err != nil {
+		return ProfileBlock{}, fmt.Errorf("invalid hit count: %q", fields[2])
+	}
+	return block, nil
+}
+
+// cutProfileLine splits a profile line into the file name and the block position, at the last
+// colon so that file names may contain drive letters (C:\src\main.go), colons and spaces
+func cutProfileLine(line string) (file, position string, ok bool) {
+	colon := strings.LastIndex(line, ":")
+	if colon < 0 {
+		return line, "", false
+	}
+	return line[:colon], line[colon+1:], true
+}
+
+// profileSlash returns a profile file name with forward slashes. Files are named by import path
+// or container path (forward slashes), or by local path after remapping on Windows (backslashes).
+func profileSlash(file string) string {
+	return strings.ReplaceAll(file, `\`, "/")
+}
+
+// profileDir returns the directory of a profile file name, with either separator
+func profileDir(file string) string {
+	i := strings.LastIndexAny(file, `/\`)
+	if i < 0 {
+		return "."
+	}
+	if dir := file[:i]; dir != "" && !strings.HasSuffix(dir, ":") {
+		return dir
+	}
+	return file[:i+1] // Root directory, e.g. "/" or "C:\"
+}
+
+// profileBase returns the file name of a profile file name without its directory
+func profileBase(file string) string {
+	return file[strings.LastIndexAny(file, `/\`)+1:]
+}
+
+func parseLineCol(s string) (int, int, error) {
+	l, c, ok := strings.Cut(s, ".")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid position: %q", s)
+	}
+	line, err := strconv.Atoi(l)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid position: %q", s)
+	}
+	col, err := strconv.Atoi(c)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid position: %q", s)
+	}
+	return line, col, nil
+}
+
+// ParseProfileFile parses the text coverage profile at path
+func ParseProfileFile(path string) (*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open coverage profile: %w", err)
+	}
+	defer f.Close()
+	return ParseProfile(f)
+}
+
+// LoadProfile parses the filtered report of a test, falling back to the unfiltered one
+func (c *CoverageClient) LoadProfile(testName string) (*Profile, error) {
+	return ParseProfileFile(c.profilePath(testName))
+}
+
+// profilePath returns the filtered report of a test if it exists, the unfiltered one otherwise
+func (c *CoverageClient) profilePath(testName string) string {
+	testDir := filepath.Join(c.outputDir, testName)
+	reportPath := filepath.Join(testDir, "coverage_filtered.out")
+	if _, err := os.Stat(reportPath); os.IsNotExist(err) {
+		reportPath = filepath.Join(testDir, "coverage.out")
+	}
+	return reportPath
+}
+
+// Total returns the statement coverage of the whole profile
+func (p *Profile) Total() CoverageStats {
+	var stats CoverageStats
+	for _, b := range p.Blocks {
+		stats.add(b)
+	}
+	return stats
+}
+
+// Packages returns the statement coverage per package (the directory of each file)
+func (p *Profile) Packages() map[string]CoverageStats {
+	packages := make(map[string]CoverageStats)
+	for _, b := range p.Blocks {
+		pkg := profileDir(b.File)
+		stats := packages[pkg]
+		stats.add(b)
+		packages[pkg] = stats
+	}
+	return packages
+}
+
+// Files returns the statement coverage per file
+func (p *Profile) Files() map[string]CoverageStats {
+	files := make(map[string]CoverageStats)
+	for _, b := range p.Blocks {
+		stats := files[b.File]
+		stats.add(b)
+		files[b.File] = stats
+	}
+	return files
+}
+
+// ChangedLinesCoverage returns the coverage of the blocks touching the given changed lines
+// ("patch coverage"). Changed files are repository-relative paths as reported by git and match
+// profile files that are equal to them or end with "/"+file (module import paths, absolute paths).
+func (p *Profile) ChangedLinesCoverage(changed map[string][]int) CoverageStats {
+	var stats CoverageStats
+	lines := make(map[string][]int)
+	for _, b := range p.Blocks {
+		fileLines, ok := lines[b.File]
+		if !ok {
+			fileLines = changedLinesForFile(changed, b.File)
+			lines[b.File] = fileLines
+		}
+		// fileLines is sorted, find the first changed line at or after the block start
+		i := sort.SearchInts(fileLines, b.StartLine)
+		if i < len(fileLines) && fileLines[i] <= b.EndLine {
+			stats.add(b)
+		}
+	}
+	return stats
+}
+
+// changedLinesForFile returns the sorted changed lines of the changed file matching profileFile
+func changedLinesForFile(changed map[string][]int, profileFile string) []int {
+	file, ok := matchChangedFile(changed, profileFile)
+	if !ok {
+		return nil
+	}
+	sorted := append([]int(nil), changed[file]...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+// matchChangedFile returns the changed file that profileFile refers to
+func matchChangedFile(changed map[string][]int, profileFile string) (string, bool) {
+	profileFile = profileSlash(profileFile)
+	for file := range changed {
+		if profileFile == file || strings.HasSuffix(profileFile, "/"+file) {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// Filter removes the blocks of files matching any of the patterns and returns the coverage of the
+// removed statements. Patterns match whole trailing path elements of file names, with path.Match
+// wildcards per element: "coverage_server.go" and "mock_*.go" match files in any directory,
+// "internal/testutil/" (with a trailing slash) matches every file below such a directory.
+// Backslashes in file names and patterns count as separators, as in Windows paths.
+func (p *Profile) Filter(patterns []string) CoverageStats {
+	var removed CoverageStats
+	kept := p.Blocks[:0]
+	for _, b := range p.Blocks {
+		if matchFilePatterns(patterns, b.File) {
+			removed.add(b)
+		} else {
+			kept = append(kept, b)
+		}
+	}
+	p.Blocks = kept
+	return removed
+}
+
+// matchFilePatterns reports whether file matches any of the patterns, see Profile.Filter
+func matchFilePatterns(patterns []string, file string) bool {
+	fileParts := strings.Split(profileSlash(file), "/")
+	for _, pattern := range patterns {
+		pattern = profileSlash(pattern)
+		if strings.Trim(pattern, "/") == "" {
+			continue
+		}
+		patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+		if !strings.HasSuffix(pattern, "/") {
+			// File pattern: the last elements of the file name
+			if i := len(fileParts) - len(patternParts); i >= 0 && matchPathElements(patternParts, fileParts[i:]) {
+				return true
+			}
+			continue
+		}
+		// Directory pattern: consecutive elements of the file's directory
+		dirParts := fileParts[:len(fileParts)-1]
+		for i := 0; i+len(patternParts) <= len(dirParts); i++ {
+			if matchPathElements(patternParts, dirParts[i:i+len(patternParts)]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPathElements matches path elements against path.Match patterns of the same length
+func matchPathElements(patterns, elements []string) bool {
+	for i, pattern := range patterns {
+		if ok, _ := path.Match(pattern, elements[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the profile in the text format read by ParseProfile and `go tool cover`
+func (p *Profile) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	mode := p.Mode
+	if mode == "" {
+		mode = "set"
+	}
+	fmt.Fprintf(bw, "mode: %s\n", mode)
+	for _, b := range p.Blocks {
+		fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,120 @@
+package coverageclient
+
+import (
+	"strings"
+	"testing"
+)
+
+const testProfile = `mode: atomic
+github.com/example/app/main.go:10.2,12.3 2 1
+github.com/example/app/main.go:14.2,16.3 3 0
+github.com/example/app/pkg/util/util.go:5.1,7.2 4 2
+github.com/example/app/pkg/util/util.go:5.1,7.2 4 3
+`
+
+func TestParseProfile(t *testing.T) {
+	profile, err := ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatalf("ParseProfile failed: %v", err)
+	}
+
+	if profile.Mode != "atomic" {
+		t.Errorf("Expected mode atomic, got %s", profile.Mode)
+	}
+	if len(profile.Blocks) != 3 {
+		t.Fatalf("Expected duplicate block to be merged into 3 blocks, got %d", len(profile.Blocks))
+	}
+	want := ProfileBlock{File: "github.com/example/app/pkg/util/util.go", StartLine: 5, StartCol: 1, EndLine: 7, EndCol: 2, NumStmt: 4, Count: 5}
+	if profile.Blocks[2] != want {
+		t.Errorf("Expected %+v, got %+v", want, profile.Blocks[2])
+	}
+
+	total := profile.Total()
+	if total.Statements != 9 || total.Covered != 6 {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+
+	packages := profile.Packages()
+	if packages["github.com/example/app"].Percent() != 40 {
+		t.Errorf("Expected 40%% for app package, got %+v", packages["github.com/example/app"])
+	}
+	if packages["github.com/example/app/pkg/util"].Percent() != 100 {
+		t.Errorf("Expected 100%% for util package, got %+v", packages["github.com/example/app/pkg/util"])
+	}
+}
+
+func TestParseProfile_Invalid(t *testing.T) {
+	for _, profile := range []string{
+		"mode: set\nmain.go 1 1\n",
+		"mode: set\nmain.go:10.2 1 1\n",
+		"mode: set\nmain.go:10.2,x.3 1 1\n",
+	} {
+		if _, err := ParseProfile(strings.NewReader(profile)); err == nil {
+			t.Errorf("Expected error for %q", profile)
+		}
+	}
+}
+
+func TestChangedLinesCoverage(t *testing.T) {
+	profile, err := ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatalf("ParseProfile failed: %v", err)
+	}
+
+	patch := profile.ChangedLinesCoverage(map[string][]int{
+		"main.go":   {15, 30},
+		"other.go":  {5},
+		"util/x.go": {6},
+	})
+	if patch.Statements != 3 || patch.Covered != 0 {
+		t.Errorf("Expected only the uncovered main.go block, got %+v", patch)
+	}
+
+	if patch := profile.ChangedLinesCoverage(nil); patch.Statements != 0 {
+		t.Errorf("Expected no statements without changes, got %+v", patch)
+	}
+}
+
+func TestProfileFilter(t *testing.T) {
+	profile, err := ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatalf("ParseProfile failed: %v", err)
+	}
+
+	removed := profile.Filter([]string{"pkg/util/", "ain.go"})
+	if removed.Statements != 4 || removed.Covered != 4 {
+		t.Errorf("Expected the util.go statements to be removed, got %+v", removed)
+	}
+	if total := profile.Total(); total.Statements != 5 || total.Covered != 2 {
+		t.Errorf("Expected main.go to remain, got %+v", total)
+	}
+	if profile.Mode != "atomic" {
+		t.Errorf("Expected mode to be preserved, got %s", profile.Mode)
+	}
+}
+
+func TestProfile_WindowsPaths(t *testing.T) {
+	profile, err := ParseProfile(strings.NewReader(`mode: set
+C:\Users\dev\my app\pkg\util\util.go:5.1,7.2 4 1
+C:\Users\dev\my app\main.go:10.2,12.3 2 0
+`))
+	if err != nil {
+		t.Fatalf("ParseProfile failed: %v", err)
+	}
+
+	if file := profile.Blocks[0].File; file != `C:\Users\dev\my app\pkg\util\util.go` {
+		t.Errorf("Expected file name with drive letter and spaces, got %q", file)
+	}
+	packages := profile.Packages()
+	if _, ok := packages[`C:\Users\dev\my app\pkg\util`]; !ok || len(packages) != 2 {
+		t.Errorf("Expected packages by directory, got %v", packages)
+	}
+	if patch := profile.ChangedLinesCoverage(map[string][]int{"pkg/util/util.go": {6}}); patch.Statements != 4 {
+		t.Errorf("Expected changed git paths to match Windows file names, got %+v", patch)
+	}
+
+	removed := profile.Filter([]string{`my app\main.go`})
+	if removed.Statements != 2 || len(profile.Blocks) != 1 {
+		t.Errorf("Expected backslash pattern to filter main.go, removed %+v", removed)
+	}
+}
@@ -0,0 +1,105 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// FinalizerProtect keeps a protected pod object (and its namespace) from being removed until ReleasePod
+	FinalizerProtect = AnnotationPrefix + "collect-before-delete"
+
+	// AnnotationProtected stores the RFC3339 time ProtectPod was called. An admission webhook can
+	// reject pod deletion while it is set, so the containers keep running until coverage is collected.
+	AnnotationProtected = AnnotationPrefix + "protected"
+)
+
+// ProtectPod marks a pod as holding uncollected coverage by adding the FinalizerProtect finalizer
+// and the AnnotationProtected annotation. This prevents races between test teardown and namespace
+// cleanup; call ReleasePod (e.g. deferred) once coverage has been collected. Needs update on pods.
+func (c *CoverageClient) ProtectPod(ctx context.Context, podName string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pods := c.clientset.CoreV1().Pods(c.namespace)
+		pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("get pod: %w", err)
+		}
+		if pod.DeletionTimestamp != nil {
+			return fmt.Errorf("pod %s is already being deleted", podName)
+		}
+
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[AnnotationProtected] = time.Now().UTC().Format(time.RFC3339)
+		if !hasFinalizer(pod.Finalizers, FinalizerProtect) {
+			pod.Finalizers = append(pod.Finalizers, FinalizerProtect)
+		}
+
+		_, err = pods.Update(ctx, pod, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("protect pod %s: %w", podName, err)
+	}
+
+	c.logf("🛡️  Protected pod %s until coverage is collected\n", podName)
+	return nil
+}
+
+// ReleasePod removes the protection added by ProtectPod so the pod can be deleted.
+// Releasing a pod that is gone or was never protected is not an error.
+func (c *CoverageClient) ReleasePod(ctx context.Context, podName string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pods := c.clientset.CoreV1().Pods(c.namespace)
+		pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		_, annotated := pod.Annotations[AnnotationProtected]
+		if !annotated && !hasFinalizer(pod.Finalizers, FinalizerProtect) {
+			return nil
+		}
+		delete(pod.Annotations, AnnotationProtected)
+		pod.Finalizers = removeFinalizer(pod.Finalizers, FinalizerProtect)
+
+		_, err = pods.Update(ctx, pod, metav1.UpdateOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("release pod %s: %w", podName, err)
+	}
+
+	c.logf("🛡️  Released pod %s\n", podName)
+	return nil
+}
+
+// hasFinalizer reports whether finalizers contains name
+func hasFinalizer(finalizers []string, name string) bool {
+	for _, f := range finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFinalizer returns finalizers without name
+func removeFinalizer(finalizers []string, name string) []string {
+	var result []string
+	for _, f := range finalizers {
+		if f != name {
+			result = append(result, f)
+		}
+	}
+	return result
+}
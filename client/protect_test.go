@@ -0,0 +1,74 @@
+package coverageclient
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProtectAndReleasePod(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:       "demo-pod",
+		Namespace:  "default",
+		Finalizers: []string{"example.com/other"},
+	}}
+	clientset := fake.NewSimpleClientset(pod)
+	client := &CoverageClient{clientset: clientset, namespace: "default"}
+	ctx := context.Background()
+
+	// Protecting twice must not duplicate the finalizer
+	for i := 0; i < 2; i++ {
+		if err := client.ProtectPod(ctx, "demo-pod"); err != nil {
+			t.Fatalf("ProtectPod failed: %v", err)
+		}
+	}
+
+	protected, _ := clientset.CoreV1().Pods("default").Get(ctx, "demo-pod", metav1.GetOptions{})
+	if protected.Annotations[AnnotationProtected] == "" {
+		t.Error("Expected protected annotation")
+	}
+	if len(protected.Finalizers) != 2 || protected.Finalizers[1] != FinalizerProtect {
+		t.Errorf("Expected finalizer to be added once, got %v", protected.Finalizers)
+	}
+
+	if err := client.ReleasePod(ctx, "demo-pod"); err != nil {
+		t.Fatalf("ReleasePod failed: %v", err)
+	}
+
+	released, _ := clientset.CoreV1().Pods("default").Get(ctx, "demo-pod", metav1.GetOptions{})
+	if _, ok := released.Annotations[AnnotationProtected]; ok {
+		t.Error("Expected protected annotation to be removed")
+	}
+	if len(released.Finalizers) != 1 || released.Finalizers[0] != "example.com/other" {
+		t.Errorf("Expected only foreign finalizers to remain, got %v", released.Finalizers)
+	}
+}
+
+func TestReleasePod_Missing(t *testing.T) {
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(), namespace: "default"}
+
+	if err := client.ReleasePod(context.Background(), "gone"); err != nil {
+		t.Errorf("Expected releasing a missing pod to succeed, got %v", err)
+	}
+	if err := client.ProtectPod(context.Background(), "gone"); err == nil {
+		t.Error("Expected error protecting a missing pod")
+	}
+}
+
+func TestProtectPod_Terminating(t *testing.T) {
+	now := metav1.Now()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:              "demo-pod",
+		Namespace:         "default",
+		DeletionTimestamp: &now,
+		Finalizers:        []string{"example.com/other"},
+	}}
+	client := &CoverageClient{clientset: fake.NewSimpleClientset(pod), namespace: "default"}
+
+	if err := client.ProtectPod(context.Background(), "demo-pod"); err == nil {
+		t.Error("Expected error protecting a terminating pod")
+	}
+}
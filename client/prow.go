@@ -0,0 +1,71 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ProwArtifactsOptions configures WriteProwArtifacts
+type ProwArtifactsOptions struct {
+	ArtifactsDir string // Prow artifacts directory (default: $ARTIFACTS)
+	Subdir       string // Write below this subdirectory, e.g. per test when reporting several (default: top level)
+}
+
+// WriteProwArtifacts copies the processed coverage of a test into the Prow artifacts layout so it
+// shows up in Spyglass and Testgrid: filtered.cov (read by the coverage lens), coverage.html,
+// metadata.json and a junit_coverage_<test>.xml summary with one test case per package.
+func (c *CoverageClient) WriteProwArtifacts(ctx context.Context, testName string, opts ProwArtifactsOptions) error {
+	if opts.ArtifactsDir == "" {
+		opts.ArtifactsDir = os.Getenv("ARTIFACTS")
+	}
+	if opts.ArtifactsDir == "" {
+		return fmt.Errorf("artifacts directory not set and $ARTIFACTS is empty")
+	}
+	destDir := filepath.Join(opts.ArtifactsDir, opts.Subdir)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("create artifacts directory: %w", err)
+	}
+
+	testDir := filepath.Join(c.outputDir, testName)
+	if err := copyFile(c.profilePath(testName), filepath.Join(destDir, "filtered.cov")); err != nil {
+		return fmt.Errorf("copy coverage profile: %w", err)
+	}
+
+	htmlPath := filepath.Join(testDir, "coverage.html")
+	if _, err := os.Stat(htmlPath); os.IsNotExist(err) {
+		if err := c.GenerateHTMLReport(testName); err != nil {
+			c.logf("⚠️  HTML report generation failed (source files may not be available): %v\n", err)
+		}
+	}
+	for _, name := range []string{"coverage.html", "metadata.json"} {
+		if _, err := os.Stat(filepath.Join(testDir, name)); err != nil {
+			continue
+		}
+		if err := copyFile(filepath.Join(testDir, name), filepath.Join(destDir, name)); err != nil {
+			return fmt.Errorf("copy %s: %w", name, err)
+		}
+	}
+
+	report, err := c.BuildCoverageReport(ctx, CoverageReportOptions{TestName: testName})
+	if err != nil {
+		return err
+	}
+	junitPath := filepath.Join(destDir, fmt.Sprintf("junit_coverage_%s.xml", sanitizeName(testName)))
+	if err := writeJUnit(junitPath, coverageJUnitSuite(report, CoverageThresholds{})); err != nil {
+		return err
+	}
+
+	c.logf("✅ Prow artifacts written to %s (total coverage %.1f%%)\n", destDir, report.Total.Percent())
+	return nil
+}
+
+// copyFile copies src to dst
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
@@ -0,0 +1,60 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteProwArtifacts(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+
+	outputDir := t.TempDir()
+	testDir := filepath.Join(outputDir, "e2e login")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "coverage_filtered.out"), []byte(testProfile), 0644)
+	os.WriteFile(filepath.Join(testDir, "coverage.html"), []byte("<html></html>"), 0644)
+	os.WriteFile(filepath.Join(testDir, "metadata.json"), []byte("{}"), 0644)
+
+	artifactsDir := t.TempDir()
+	t.Setenv("ARTIFACTS", artifactsDir)
+
+	client := &CoverageClient{outputDir: outputDir}
+	if err := client.WriteProwArtifacts(context.Background(), "e2e login", ProwArtifactsOptions{}); err != nil {
+		t.Fatalf("WriteProwArtifacts failed: %v", err)
+	}
+
+	for _, name := range []string{"filtered.cov", "coverage.html", "metadata.json"} {
+		if _, err := os.Stat(filepath.Join(artifactsDir, name)); err != nil {
+			t.Errorf("Expected %s in artifacts: %v", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(artifactsDir, "junit_coverage_e2e_login.xml"))
+	if err != nil {
+		t.Fatalf("JUnit summary not written: %v", err)
+	}
+	var junit junitTestSuites
+	if err := xml.Unmarshal(data, &junit); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	if junit.Tests != 2 || junit.Failures != 0 || len(junit.Suites) != 1 {
+		t.Fatalf("Unexpected JUnit summary: %+v", junit)
+	}
+	suite := junit.Suites[0]
+	if suite.Name != "coverage/e2e login" || suite.Cases[1].SystemOut != "coverage: 100.0% (4/4)" {
+		t.Errorf("Unexpected suite: %+v", suite)
+	}
+}
+
+func TestWriteProwArtifacts_NoArtifactsDir(t *testing.T) {
+	t.Setenv("ARTIFACTS", "")
+	client := &CoverageClient{outputDir: t.TempDir()}
+
+	if err := client.WriteProwArtifacts(context.Background(), "e2e", ProwArtifactsOptions{}); err == nil {
+		t.Error("Expected error without artifacts directory")
+	}
+}
@@ -0,0 +1,108 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/file"
+)
+
+// PullResult describes a pulled coverage artifact
+type PullResult struct {
+	Reference string   // registry/repository:tag (or @digest) as given
+	Digest    string   // Manifest digest, e.g. "sha256:..."
+	Dir       string   // Directory the files were written to
+	Files     []string // Names of the pulled files, sorted
+}
+
+// PullCoverageArtifact downloads an artifact pushed by PushCoverageArtifact into destDir, so later
+// jobs can regenerate reports from its covdata or merge it with other runs. ref is
+// registry/repository:tag or registry/repository@sha256:...; credentials come from the Docker
+// config like for pushes. Pulling into the client's TestDir makes the test's reports available:
+//
+//	dir, _ := client.TestDir("e2e")
+//	client.PullCoverageArtifact(ctx, "quay.io/org/coverage:e2e-main", dir)
+//	client.GenerateCoverageReport("e2e")
+//
+// Existing files of the same names are overwritten. Errors wrap ErrArtifactPull.
+func (c *CoverageClient) PullCoverageArtifact(ctx context.Context, ref, destDir string) (result *PullResult, err error) {
+	ctx, step := startStep(ctx, "pull", attribute.String("reference", ref))
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrArtifactPull, err)
+		}
+		step.end(err)
+	}()
+
+	registry, repository, reference, err := parsePullRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := newRemoteRepository(registry, repository)
+	if err != nil {
+		return nil, err
+	}
+	return c.pullArtifactFrom(ctx, repo, ref, reference, destDir)
+}
+
+// parsePullRef splits an artifact reference into registry, repository and the tag or digest to pull
+func parsePullRef(ref string) (registry, repository, reference string, err error) {
+	if name, digest, ok := strings.Cut(ref, "@"); ok {
+		registry, repository, tag, err := parseArtifactRef(name)
+		if err != nil || tag != "" || digest == "" {
+			return "", "", "", fmt.Errorf("invalid artifact reference %q, expected registry/repository@digest", ref)
+		}
+		return registry, repository, digest, nil
+	}
+	registry, repository, tag, err := parseArtifactRef(ref)
+	if err != nil {
+		return "", "", "", err
+	}
+	if tag == "" {
+		return "", "", "", fmt.Errorf("artifact reference %q names no tag or digest", ref)
+	}
+	return registry, repository, tag, nil
+}
+
+// pullArtifactFrom copies the artifact reference resolves to in src into destDir
+func (c *CoverageClient) pullArtifactFrom(ctx context.Context, src oras.ReadOnlyTarget, ref, reference, destDir string) (*PullResult, error) {
+	c.logf("📥 Pulling coverage artifact %s\n", ref)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, fmt.Errorf("create destination directory: %w", err)
+	}
+	fs, err := file.New(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("create file store: %w", err)
+	}
+	defer fs.Close()
+
+	var mu sync.Mutex
+	var files []string
+	copyOpts := oras.DefaultCopyOptions
+	copyOpts.PostCopy = func(ctx context.Context, desc ocispec.Descriptor) error {
+		if name := desc.Annotations[ocispec.AnnotationTitle]; name != "" {
+			mu.Lock()
+			files = append(files, name)
+			mu.Unlock()
+		}
+		return nil
+	}
+	manifest, err := oras.Copy(ctx, src, reference, fs, reference, copyOpts)
+	if err != nil {
+		return nil, fmt.Errorf("pull %s: %w", ref, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("artifact %s contains no files", ref)
+	}
+	sort.Strings(files)
+
+	c.logf("✅ Pulled %d file(s) into %s\n", len(files), destDir)
+	return &PullResult{Reference: ref, Digest: manifest.Digest.String(), Dir: destDir, Files: files}, nil
+}
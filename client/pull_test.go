@@ -0,0 +1,65 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestPullArtifactFrom(t *testing.T) {
+	ctx := context.Background()
+	store, err := oci.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushTestBaseline(t, store, "e2e-main", testProfile)
+
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	dest, _ := client.TestDir("e2e")
+	result, err := client.pullArtifactFrom(ctx, store, "quay.io/org/coverage:e2e-main", "e2e-main", dest)
+	if err != nil {
+		t.Fatalf("pullArtifactFrom failed: %v", err)
+	}
+	if result.Dir != dest || len(result.Files) != 1 || result.Files[0] != "coverage_filtered.out" || !strings.HasPrefix(result.Digest, "sha256:") {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "coverage_filtered.out")); string(data) != testProfile {
+		t.Errorf("Expected the pushed profile, got:\n%s", data)
+	}
+
+	// By digest, overwriting the pulled files
+	again, err := client.pullArtifactFrom(ctx, store, "quay.io/org/coverage@"+result.Digest, result.Digest, dest)
+	if err != nil || again.Digest != result.Digest {
+		t.Errorf("Expected the same artifact by digest, got %+v (%v)", again, err)
+	}
+
+	if _, err := client.pullArtifactFrom(ctx, store, "quay.io/org/coverage:missing", "missing", t.TempDir()); err == nil {
+		t.Error("Expected an error for a missing tag")
+	}
+}
+
+func TestPullCoverageArtifact_InvalidRef(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	for _, ref := range []string{"coverage", "quay.io/org/coverage", "quay.io/org/coverage:tag@sha256:abc", "quay.io/org/coverage@"} {
+		_, err := client.PullCoverageArtifact(context.Background(), ref, t.TempDir())
+		if err == nil || !errors.Is(err, ErrArtifactPull) {
+			t.Errorf("Expected ErrArtifactPull for %q, got %v", ref, err)
+		}
+	}
+}
+
+func TestParsePullRef(t *testing.T) {
+	registry, repository, reference, err := parsePullRef("quay.io/org/coverage@sha256:abc")
+	if err != nil || registry != "quay.io" || repository != "org/coverage" || reference != "sha256:abc" {
+		t.Errorf("Unexpected digest reference %s %s %s (%v)", registry, repository, reference, err)
+	}
+	registry, repository, reference, err = parsePullRef("localhost:5000/org/coverage:e2e")
+	if err != nil || registry != "localhost:5000" || repository != "org/coverage" || reference != "e2e" {
+		t.Errorf("Unexpected tag reference %s %s %s (%v)", registry, repository, reference, err)
+	}
+}
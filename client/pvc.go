@@ -0,0 +1,133 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const pvcHelperMountPath = "/coverage"
+
+// PVCCollectionOptions configures CollectCoverageFromPVC
+type PVCCollectionOptions struct {
+	SubPath  string        // Relative directory inside the claim, e.g. a pod name (default: whole claim)
+	Image    string        // Helper image providing sh, find, tar and base64 (default: busybox:1.36)
+	NodeName string        // Schedule the helper on this node (default: the node of a pod still mounting the claim)
+	Timeout  time.Duration // How long to wait for the helper pod (default: 2m)
+}
+
+// CollectCoverageFromPVC collects coverage that instrumented pods wrote to GOCOVERDIR on a PVC
+// (see EnableCoverageOptions.PVCName). It starts a helper pod mounting the claim read-only, which
+// prints the covdata files as a base64 tarball to its logs, so no exec or port-forward is needed.
+// This covers apps that crashed, were evicted or completed before network collection could happen.
+// While a pod (e.g. the replacement of a crashed one) still mounts the claim, the helper is
+// scheduled on its node, which ReadWriteOnce claims require.
+func (c *CoverageClient) CollectCoverageFromPVC(ctx context.Context, pvcName, testName string, opts PVCCollectionOptions) error {
+	c.logf("📊 Collecting coverage from PVC %s for test: %s\n", pvcName, testName)
+
+	helper, err := newPVCHelperPod(pvcName, opts)
+	if err != nil {
+		return err
+	}
+	if opts.NodeName == "" {
+		if opts.NodeName = c.claimNode(ctx, pvcName); opts.NodeName != "" {
+			c.logf("  📍 PVC %s is mounted on node %s, scheduling the helper there\n", pvcName, opts.NodeName)
+			helper.Spec.NodeName = opts.NodeName
+		}
+	}
+
+	logs, err := c.runHelperPod(ctx, helper, opts.Timeout)
+	if err != nil {
+		return err
+	}
+
+	files, err := c.saveCoverageArchive(logs, testName)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		c.logf("  📁 Saved: %s\n", f)
+	}
+	c.logf("✅ Coverage collected from PVC %s for test: %s (%d files)\n", pvcName, testName, len(files))
+	return nil
+}
+
+// claimNode returns the node of a scheduled, not terminated pod mounting the claim, or "" when
+// none does (or pods can't be listed)
+func (c *CoverageClient) claimNode(ctx context.Context, pvcName string) string {
+	pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return ""
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if claim := volume.PersistentVolumeClaim; claim != nil && claim.ClaimName == pvcName {
+				return pod.Spec.NodeName
+			}
+		}
+	}
+	return ""
+}
+
+// saveCoverageArchive decodes the helper pod's base64 tarball into the test directory
+func (c *CoverageClient) saveCoverageArchive(encoded []byte, testName string) ([]string, error) {
+	testDir, err := c.createTestDir(testName)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := extractTarGz(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(encoded)), testDir, c.responseLimit())
+	if err != nil {
+		return files, fmt.Errorf("extract coverage archive: %w", err)
+	}
+	// Several pods or processes may have written to the claim
+	if _, err := c.pruneCovdataDir(testDir); err != nil {
+		return files, err
+	}
+	return files, c.separateEarlierBinaries(testDir, files)
+}
+
+// newPVCHelperPod builds a pod that flattens all covdata files of the claim into a base64 tarball on
+// stdout. The sub path must stay inside the claim.
+func newPVCHelperPod(pvcName string, opts PVCCollectionOptions) (*corev1.Pod, error) {
+	if path.IsAbs(opts.SubPath) || slices.Contains(strings.Split(opts.SubPath, "/"), "..") {
+		return nil, fmt.Errorf("invalid sub path %q: must be relative to the claim without '..'", opts.SubPath)
+	}
+	sourceDir := shellQuote(path.Join(pvcHelperMountPath, opts.SubPath))
+	script := fmt.Sprintf(`set -e
+mkdir -p /tmp/out
+find %s -type f \( -name 'covmeta.*' -o -name 'covcounters.*' \) -exec cp {} /tmp/out/ \;
+cd /tmp/out
+if [ -z "$(ls)" ]; then echo "no coverage data found in "%s >&2; exit 1; fi
+tar czf - * | base64`, sourceDir, sourceDir)
+
+	pod := newHelperPod("coverage-pvc-reader-", []string{"sh", "-c", script}, HelperPodOptions{
+		Image:    opts.Image,
+		NodeName: opts.NodeName,
+	})
+	pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{
+		Name:      coverageVolumeName,
+		MountPath: pvcHelperMountPath,
+		ReadOnly:  true,
+	}}
+	pod.Spec.Volumes = []corev1.Volume{{
+		Name: coverageVolumeName,
+		VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: pvcName,
+			ReadOnly:  true,
+		}},
+	}}
+	return pod, nil
+}
@@ -0,0 +1,175 @@
+package coverageclient
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNewPVCHelperPod(t *testing.T) {
+	pod, err := newPVCHelperPod("coverage-pvc", PVCCollectionOptions{SubPath: "demo-pod", Image: "busybox:1.36", NodeName: "node-1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if pod.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("Expected restart policy Never, got %s", pod.Spec.RestartPolicy)
+	}
+	if pod.Spec.NodeName != "node-1" {
+		t.Errorf("Expected node node-1, got %s", pod.Spec.NodeName)
+	}
+	claim := pod.Spec.Volumes[0].PersistentVolumeClaim
+	if claim == nil || claim.ClaimName != "coverage-pvc" || !claim.ReadOnly {
+		t.Errorf("Expected read-only claim coverage-pvc, got %+v", claim)
+	}
+	if script := pod.Spec.Containers[0].Command[2]; !strings.Contains(script, "find '/coverage/demo-pod'") {
+		t.Errorf("Expected script to read the sub path, got:\n%s", script)
+	}
+}
+
+func TestNewPVCHelperPod_SubPath(t *testing.T) {
+	pod, err := newPVCHelperPod("coverage-pvc", PVCCollectionOptions{SubPath: "it's"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if script := pod.Spec.Containers[0].Command[2]; strings.Count(script, `'/coverage/it'\''s'`) != 2 {
+		t.Errorf("Expected the sub path to be quoted for the shell, got:\n%s", script)
+	}
+
+	for _, subPath := range []string{"/etc", "..", "../other", "pods/../../etc"} {
+		if _, err := newPVCHelperPod("coverage-pvc", PVCCollectionOptions{SubPath: subPath}); err == nil {
+			t.Errorf("Expected sub path %q to be rejected", subPath)
+		}
+	}
+}
+
+// coverageArchive builds a gzipped tarball of files like the helper pods do
+func coverageArchive(files map[string]string) []byte {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return archive.Bytes()
+}
+
+func TestSaveCoverageArchive(t *testing.T) {
+	archive := coverageArchive(map[string]string{"covmeta.abc": "meta", "covcounters.abc.1.2": "counters"})
+
+	// base64 in the helper pod wraps lines at 76 characters
+	encoded := base64.StdEncoding.EncodeToString(archive)
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded + "\n")
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{outputDir: tempDir}
+	files, err := client.saveCoverageArchive([]byte(wrapped.String()), "pvc-test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files, got %v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(tempDir, "pvc-test", "covmeta.abc"))
+	if err != nil || string(data) != "meta" {
+		t.Errorf("Unexpected meta file content %q: %v", data, err)
+	}
+}
+
+func TestSaveCoverageArchive_MaxResponseSize(t *testing.T) {
+	// Counters compress well, the limit applies to the extracted data
+	archive := coverageArchive(map[string]string{"covcounters.abc.1.2": strings.Repeat("0", 1<<20)})
+	encoded := base64.StdEncoding.EncodeToString(archive)
+
+	client := &CoverageClient{outputDir: t.TempDir()}
+	client.SetMaxResponseSize(64 << 10)
+	if _, err := client.saveCoverageArchive([]byte(encoded), "pvc-test"); err == nil || !strings.Contains(err.Error(), "exceeds 65536 bytes") {
+		t.Fatalf("Expected size guard error, got %v", err)
+	}
+
+	client.SetMaxResponseSize(-1)
+	if _, err := client.saveCoverageArchive([]byte(encoded), "pvc-test"); err != nil {
+		t.Fatalf("Expected unlimited extraction to succeed, got %v", err)
+	}
+}
+
+func TestCollectCoverageFromPVC_HelperFailed(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// The fake clientset neither generates names nor runs pods
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Name = pod.GenerateName + "test"
+		pod.Status.Phase = corev1.PodFailed
+		return false, nil, nil
+	})
+
+	client := &CoverageClient{clientset: clientset, namespace: "default", outputDir: t.TempDir()}
+	err := client.CollectCoverageFromPVC(context.Background(), "coverage-pvc", "pvc-test", PVCCollectionOptions{})
+	if err == nil || !strings.Contains(err.Error(), "coverage-pvc-reader-test failed") {
+		t.Fatalf("Expected helper failure, got %v", err)
+	}
+
+	if _, err := clientset.CoreV1().Pods("default").Get(context.Background(), "coverage-pvc-reader-test", metav1.GetOptions{}); err == nil {
+		t.Error("Expected helper pod to be deleted")
+	}
+}
+
+func TestCollectCoverageFromPVC_ClaimNode(t *testing.T) {
+	mounting := func(name, node string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: corev1.PodSpec{
+				NodeName: node,
+				Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "coverage-pvc"},
+				}}},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		mounting("crashed", "node-1", corev1.PodFailed),
+		mounting("replacement", "node-2", corev1.PodRunning),
+	)
+	var helperNode string
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Name = pod.GenerateName + "test"
+		pod.Status.Phase = corev1.PodFailed
+		helperNode = pod.Spec.NodeName
+		return false, nil, nil
+	})
+
+	client := &CoverageClient{clientset: clientset, namespace: "default", outputDir: t.TempDir(), quiet: true}
+	client.CollectCoverageFromPVC(context.Background(), "coverage-pvc", "pvc-test", PVCCollectionOptions{})
+	if helperNode != "node-2" {
+		t.Errorf("Expected the helper on the node of the running pod, got %q", helperNode)
+	}
+	client.CollectCoverageFromPVC(context.Background(), "coverage-pvc", "pvc-test", PVCCollectionOptions{NodeName: "node-3"})
+	if helperNode != "node-3" {
+		t.Errorf("Expected the configured node, got %q", helperNode)
+	}
+	if node := client.claimNode(context.Background(), "other-pvc"); node != "" {
+		t.Errorf("Expected no node for an unmounted claim, got %q", node)
+	}
+}
@@ -0,0 +1,76 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Defaults of HealthProbeOptions
+const (
+	defaultProbeReadyTimeout = 30 * time.Second
+	defaultProbeBackoff      = 50 * time.Millisecond
+	defaultProbeMaxBackoff   = time.Second
+)
+
+// HealthProbeOptions configures how a collection waits for the coverage server behind a new
+// port-forward: /health is polled until it answers, waiting Backoff after the first failed probe
+// and doubling the wait up to MaxBackoff
+type HealthProbeOptions struct {
+	Timeout    time.Duration // How long to wait for an answer (default: 30s)
+	Backoff    time.Duration // Wait after the first failed probe (default: 50ms)
+	MaxBackoff time.Duration // Longest wait between probes (default: 1s)
+}
+
+// SetHealthProbe configures how collections wait for the coverage server after port-forwarding
+// (default: HealthProbeOptions defaults)
+func (c *CoverageClient) SetHealthProbe(opts HealthProbeOptions) {
+	c.healthProbe = opts
+}
+
+// waitForCoverageServer polls the coverage server's /health endpoint through a tunnel until it
+// answers. Any HTTP response counts, so servers without /health (404) don't wait for the timeout;
+// connection errors of a tunnel that isn't passing traffic yet are retried. It fails with
+// ErrPortForwardTimeout when the server doesn't answer in time.
+func (c *CoverageClient) waitForCoverageServer(ctx context.Context, baseURL string) error {
+	opts := c.healthProbe
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultProbeReadyTimeout
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultProbeBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultProbeMaxBackoff
+	}
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	backoff := opts.Backoff
+	for {
+		req, err := http.NewRequestWithContext(probeCtx, http.MethodGet, baseURL+"/health", nil)
+		if err != nil {
+			return fmt.Errorf("create health probe: %w", err)
+		}
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-probeCtx.Done():
+			if ctx.Err() != nil {
+				return fmt.Errorf("wait for coverage server: %w", ctx.Err())
+			}
+			return fmt.Errorf("%w: coverage server not answering after %s: %v", ErrPortForwardTimeout, opts.Timeout, err)
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
@@ -0,0 +1,57 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails the first failures requests like a tunnel not passing traffic yet, then
+// answers 404 like a server without /health
+type flakyTransport struct {
+	failures int32
+	calls    atomic.Int32
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.calls.Add(1) <= f.failures {
+		return nil, errors.New("connection reset by peer")
+	}
+	return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestWaitForCoverageServer(t *testing.T) {
+	transport := &flakyTransport{failures: 2}
+	client := &CoverageClient{httpClient: &http.Client{Transport: transport}}
+	client.SetHealthProbe(HealthProbeOptions{Backoff: time.Millisecond})
+	if err := client.waitForCoverageServer(context.Background(), "http://localhost:1"); err != nil {
+		t.Fatalf("Expected the server to answer on the third probe, got %v", err)
+	}
+	if transport.calls.Load() != 3 {
+		t.Errorf("Expected 3 probes, got %d", transport.calls.Load())
+	}
+
+	// Never answering
+	client.httpClient = &http.Client{Transport: &flakyTransport{failures: 1 << 30}}
+	client.SetHealthProbe(HealthProbeOptions{Timeout: 50 * time.Millisecond, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	start := time.Now()
+	err := client.waitForCoverageServer(context.Background(), "http://localhost:1")
+	if !errors.Is(err, ErrPortForwardTimeout) || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("Expected ErrPortForwardTimeout with the last error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the probe timeout to apply, waited %s", elapsed)
+	}
+
+	// A cancelled collection isn't a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.waitForCoverageServer(ctx, "http://localhost:1"); err == nil || errors.Is(err, ErrPortForwardTimeout) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+}
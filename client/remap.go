@@ -0,0 +1,233 @@
+package coverageclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// goModule is a Go module in the local source directory
+type goModule struct {
+	path string // Module path declared in go.mod
+	dir  string // Absolute directory holding go.mod
+}
+
+// SetPathMappings adds explicit remapping rules from a directory prefix in coverage reports (import
+// path or container path, e.g. "/workspace/src") to a local directory, for sources automatic
+// remapping can't find, e.g. vendored or generated code (default: none). Rules take precedence
+// over detected mappings; the longest matching prefix wins.
+func (c *CoverageClient) SetPathMappings(rules map[string]string) {
+	c.pathRules = rules
+}
+
+// remapCoveragePaths remaps the file names in the coverage report (package import paths or
+// container paths) to local paths
+func (c *CoverageClient) remapCoveragePaths(reportPath string) error {
+	// Read the coverage report
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return fmt.Errorf("read coverage report: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+
+	// Map the directories of the report's files to local package directories
+	files := reportFiles(lines)
+	pathMappings := c.detectPathMappings(files, filepath.Dir(reportPath))
+	for dir, localDir := range c.ruleMappings(files) {
+		if pathMappings == nil {
+			pathMappings = make(map[string]string)
+		}
+		pathMappings[dir] = localDir
+	}
+
+	if len(pathMappings) == 0 {
+		c.logf("📍 No container paths detected, using paths as-is\n")
+		return nil
+	}
+
+	c.logf("📍 Auto-detected path mappings:\n")
+	for containerPath, localPath := range pathMappings {
+		c.logf("  [PATH] %s -> %s\n", containerPath, localPath)
+	}
+
+	// Remap paths in the coverage data
+	var remappedLines []string
+	remappedCount := 0
+
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			remappedLines = append(remappedLines, line)
+			continue
+		}
+
+		// Coverage line format: path/to/file.go:line.col,line.col num count
+		filePath, rest, ok := cutProfileLine(line)
+		if !ok {
+			remappedLines = append(remappedLines, line)
+			continue
+		}
+
+		if localDir, ok := pathMappings[profileDir(filePath)]; ok {
+			filePath = filepath.Join(localDir, profileBase(filePath))
+			remappedCount++
+		}
+		remappedLines = append(remappedLines, filePath+":"+rest)
+	}
+
+	// Write the remapped coverage report back
+	remappedData := strings.Join(remappedLines, "\n")
+	if err := os.WriteFile(reportPath, []byte(remappedData), 0644); err != nil {
+		return fmt.Errorf("write remapped report: %w", err)
+	}
+
+	c.logf("✅ Path remapping complete (%d lines remapped)\n", remappedCount)
+	return nil
+}
+
+// ruleMappings maps the directories of the report's files matching a SetPathMappings rule
+func (c *CoverageClient) ruleMappings(files []string) map[string]string {
+	mappings := make(map[string]string)
+	for _, file := range files {
+		// Compare with forward slashes, so rules written with backslashes match on Windows
+		dir := profileDir(file)
+		slashDir := profileSlash(dir)
+		best := ""
+		for prefix := range c.pathRules {
+			slashPrefix := strings.TrimSuffix(profileSlash(prefix), "/")
+			if len(prefix) > len(best) && (slashDir == slashPrefix || strings.HasPrefix(slashDir, slashPrefix+"/")) {
+				best = prefix
+			}
+		}
+		if best != "" {
+			rel := strings.TrimPrefix(slashDir, strings.TrimSuffix(profileSlash(best), "/"))
+			mappings[dir] = filepath.Join(c.pathRules[best], filepath.FromSlash(rel))
+		}
+	}
+	return mappings
+}
+
+// reportFiles returns the distinct file names of coverage report lines
+func reportFiles(lines []string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		file, _, _ := cutProfileLine(line)
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// detectPathMappings maps the directories of the report's files onto local package directories.
+// Packages are identified by import path, from the coverage meta-data in covdataDir (or the
+// report's file names), and resolved through the go.mod files below the source directory, so
+// duplicate file names and multi-module repositories map correctly. Files recorded with container
+// paths (packages built from file names, e.g. `go build main.go`) are resolved against the module
+// roots instead.
+func (c *CoverageClient) detectPathMappings(files []string, covdataDir string) map[string]string {
+	// Get absolute path for source directory
+	absSourceDir, err := filepath.Abs(c.sourceDir)
+	if err != nil {
+		c.logf("[REMAP] Warning: Could not get absolute path for %s: %v\n", c.sourceDir, err)
+		absSourceDir = c.sourceDir
+	}
+
+	modules := c.sources.get(absSourceDir)
+	if len(modules) == 0 {
+		c.logf("[REMAP] No go.mod found in %s\n", absSourceDir)
+		return nil
+	}
+	for _, m := range modules {
+		c.logf("[REMAP] Module %s in %s\n", m.path, m.dir)
+	}
+
+	mappings := make(map[string]string)
+	var external []string
+	for _, pkg := range coveragePackages(covdataDir, files) {
+		if dir, ok := packageDir(modules, pkg); ok {
+			mappings[pkg] = dir
+		} else {
+			external = append(external, pkg)
+		}
+	}
+	if len(external) > 0 {
+		c.logf("[REMAP] %d package(s) outside the local modules left as-is: %v\n", len(external), external)
+	}
+
+	for _, file := range files {
+		dir := profileDir(file)
+		if _, ok := mappings[dir]; ok || !isAbsProfilePath(file) {
+			continue
+		}
+		if _, err := os.Stat(file); err == nil {
+			continue // Local path
+		}
+		if localDir, ok := containerFileDir(modules, file); ok {
+			mappings[dir] = localDir
+		} else {
+			c.logf("[REMAP] No local file found for %s\n", file)
+		}
+	}
+	return mappings
+}
+
+// packageDir returns the local directory of the package with the given import path
+func packageDir(modules []goModule, importPath string) (string, bool) {
+	for _, m := range modules {
+		if rel, ok := strings.CutPrefix(importPath, m.path); ok && (rel == "" || rel[0] == '/') {
+			dir := filepath.Join(m.dir, filepath.FromSlash(rel))
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return dir, true
+			}
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// containerFileDir returns the local directory holding a file recorded with a container path,
+// matching the longest trailing part of its directory below a module root
+func containerFileDir(modules []goModule, file string) (string, bool) {
+	parts := strings.Split(strings.Trim(profileSlash(profileDir(file)), "/"), "/")
+	for i := range len(parts) + 1 {
+		rel := filepath.Join(parts[i:]...)
+		for _, m := range modules {
+			dir := filepath.Join(m.dir, rel)
+			if _, err := os.Stat(filepath.Join(dir, profileBase(file))); err == nil {
+				return dir, true
+			}
+		}
+	}
+	return "", false
+}
+
+// coveragePackages returns the import paths of the packages in the coverage meta-data in dir,
+// falling back to the package prefixes of the report's file names
+func coveragePackages(dir string, files []string) []string {
+	if packages, err := listCovdataPackages(dir); err == nil && len(packages) > 0 {
+		return packages
+	}
+
+	var packages []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if pkg := profileDir(file); !isAbsProfilePath(file) && !seen[pkg] {
+			seen[pkg] = true
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
+
+// isAbsProfilePath reports whether a profile file name is a path rather than an import path:
+// container paths are rooted at "/" whatever the local OS, local paths may have a drive letter
+func isAbsProfilePath(file string) bool {
+	return strings.HasPrefix(file, "/") || filepath.IsAbs(file)
+}
@@ -0,0 +1,160 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemapCoveragePaths_ImportPaths(t *testing.T) {
+	sourceDir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":             "module github.com/example/app\n\ngo 1.24\n",
+		"main.go":            "package main",
+		"pkg/a/util.go":      "package a",
+		"pkg/b/util.go":      "package b",
+		"tools/go.mod":       "module github.com/example/tools\n\ngo 1.24\n",
+		"tools/cmd/util.go":  "package main",
+		"vendor/x/y/util.go": "package y",
+	} {
+		path := filepath.Join(sourceDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	// Same file name in three packages of two modules, a dependency and a main package built from
+	// file names, which is recorded with its container path
+	reportDir := t.TempDir()
+	reportPath := filepath.Join(reportDir, "coverage.out")
+	os.WriteFile(reportPath, []byte(`mode: atomic
+github.com/example/app/pkg/a/util.go:1.1,2.2 1 1
+github.com/example/app/pkg/b/util.go:1.1,2.2 1 0
+github.com/example/tools/cmd/util.go:1.1,2.2 1 1
+github.com/other/dep/x/y/util.go:1.1,2.2 1 1
+/src/main.go:1.1,2.2 1 1
+`), 0644)
+
+	client := &CoverageClient{sourceDir: sourceDir}
+	if err := client.remapCoveragePaths(reportPath); err != nil {
+		t.Fatalf("remapCoveragePaths failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(reportPath)
+	lines := strings.Split(string(data), "\n")
+	for i, want := range []string{
+		filepath.Join(sourceDir, "pkg/a/util.go"),
+		filepath.Join(sourceDir, "pkg/b/util.go"),
+		filepath.Join(sourceDir, "tools/cmd/util.go"),
+		"github.com/other/dep/x/y/util.go",
+		filepath.Join(sourceDir, "main.go"),
+	} {
+		if file, _, _ := strings.Cut(lines[i+1], ":"); file != want {
+			t.Errorf("Line %d: expected %s, got %s", i+1, want, file)
+		}
+	}
+}
+
+func TestFindGoModules(t *testing.T) {
+	sourceDir := t.TempDir()
+	for name, module := range map[string]string{
+		"go.mod":              "github.com/example/app",
+		"api/go.mod":          "github.com/example/app/api",
+		"testdata/go.mod":     "example.com/fixture",
+		".git/modules/go.mod": "example.com/hidden",
+	} {
+		path := filepath.Join(sourceDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("module "+module+"\n"), 0644)
+	}
+	os.MkdirAll(filepath.Join(sourceDir, "api", "v1"), 0755)
+
+	modules := findGoModules(sourceDir)
+	if len(modules) != 2 || modules[0].path != "github.com/example/app/api" {
+		t.Fatalf("Expected the nested module first and ignored directories skipped, got %+v", modules)
+	}
+
+	if dir, ok := packageDir(modules, "github.com/example/app/api/v1"); !ok || dir != filepath.Join(sourceDir, "api", "v1") {
+		t.Errorf("Expected package of the nested module in api/v1, got %q", dir)
+	}
+	if _, ok := packageDir(modules, "github.com/example/application"); ok {
+		t.Error("Expected module path prefixes to match whole path elements only")
+	}
+	if _, ok := packageDir(modules, "github.com/example/app/missing"); ok {
+		t.Error("Expected packages without a local directory not to resolve")
+	}
+}
+
+func TestRemapCoveragePaths_Spaces(t *testing.T) {
+	sourceDir := filepath.Join(t.TempDir(), "my project")
+	os.MkdirAll(filepath.Join(sourceDir, "cmd", "my tool"), 0755)
+	os.WriteFile(filepath.Join(sourceDir, "go.mod"), []byte("module github.com/example/app\n"), 0644)
+	os.WriteFile(filepath.Join(sourceDir, "cmd", "my tool", "main.go"), []byte("package main"), 0644)
+
+	reportPath := filepath.Join(t.TempDir(), "coverage.out")
+	os.WriteFile(reportPath, []byte("mode: set\n/src/cmd/my tool/main.go:1.1,2.2 1 1\n"), 0644)
+
+	client := &CoverageClient{sourceDir: sourceDir}
+	if err := client.remapCoveragePaths(reportPath); err != nil {
+		t.Fatalf("remapCoveragePaths failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(reportPath)
+	want := filepath.Join(sourceDir, "cmd", "my tool", "main.go") + ":1.1,2.2 1 1"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("Expected %q in remapped report, got:\n%s", want, data)
+	}
+}
+
+func TestRemapCoveragePaths_Rules(t *testing.T) {
+	sourceDir := t.TempDir()
+	os.WriteFile(filepath.Join(sourceDir, "go.mod"), []byte("module github.com/example/app\n"), 0644)
+
+	reportPath := filepath.Join(t.TempDir(), "coverage.out")
+	os.WriteFile(reportPath, []byte(`mode: set
+github.com/other/dep/x/util.go:1.1,2.2 1 1
+github.com/other/dep/internal/gen/gen.go:1.1,2.2 1 0
+github.com/example/app/main.go:1.1,2.2 1 1
+`), 0644)
+
+	client := &CoverageClient{sourceDir: sourceDir}
+	client.SetPathMappings(map[string]string{
+		"github.com/other/dep":              "/deps/dep",
+		"github.com/other/dep/internal/gen": "/generated",
+	})
+	if err := client.remapCoveragePaths(reportPath); err != nil {
+		t.Fatalf("remapCoveragePaths failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(reportPath)
+	for _, want := range []string{
+		filepath.Join("/deps/dep", "x", "util.go") + ":",
+		filepath.Join("/generated", "gen.go") + ":",
+		filepath.Join(sourceDir, "main.go") + ":",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in remapped report, got:\n%s", want, data)
+		}
+	}
+}
+
+func TestRuleMappings_Separators(t *testing.T) {
+	client := &CoverageClient{}
+	client.SetPathMappings(map[string]string{
+		`C:\build\src\`: "/local/src",
+		"/workspace/":   "/local/ws",
+	})
+	mappings := client.ruleMappings([]string{`C:\build\src\pkg\a.go`, "/workspace/cmd/main.go", "/workspacex/b.go"})
+	want := map[string]string{
+		`C:\build\src\pkg`: filepath.Join("/local/src", "pkg"),
+		"/workspace/cmd":   filepath.Join("/local/ws", "cmd"),
+	}
+	if len(mappings) != len(want) {
+		t.Errorf("Expected %v, got %v", want, mappings)
+	}
+	for dir, local := range want {
+		if mappings[dir] != local {
+			t.Errorf("Expected %s -> %s, got %q", dir, local, mappings[dir])
+		}
+	}
+}
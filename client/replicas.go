@@ -0,0 +1,215 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CollectCoverageFromDeployment collects coverage from all running pods of a Deployment
+// concurrently, each replica into its own subdirectory of the test directory (<test>/<pod>, the
+// TestName of its result). The subdirectories are ready for `go tool covdata merge`, or for a
+// Suite tracking them. A failing replica doesn't stop the others: the results of the collected
+// replicas are returned with the errors of the failed ones.
+func (c *CoverageClient) CollectCoverageFromDeployment(ctx context.Context, deploymentName, testName string, port int) ([]*CollectResult, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	pods, err := c.deploymentPods(ctx, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logf("📦 Collecting coverage from %d replica(s) of deployment %s for test: %s\n", len(pods), deploymentName, testName)
+	results, err := c.collectReplicas(ctx, pods, 0, func(pod string) CollectOptions {
+		return CollectOptions{TestName: path.Join(testName, pod), PodName: pod, Port: port}
+	})
+	if err != nil {
+		return results, fmt.Errorf("collect from deployment %s: %w", deploymentName, err)
+	}
+	return results, nil
+}
+
+// CollectCoverageFromService collects coverage from every ready endpoint of a Service concurrently,
+// calling the coverage port on the pod IPs directly. For runners inside the cluster this skips
+// port-forwarding through the API server; the coverage port doesn't need to be a Service port.
+// Like CollectCoverageFromDeployment, each pod is saved into <test>/<pod> and failing endpoints
+// don't stop the others. Endpoints come from the Service's EndpointSlices; with a STRICT mTLS
+// service mesh, collect through the mesh (MethodService) instead.
+func (c *CoverageClient) CollectCoverageFromService(ctx context.Context, serviceName, testName string, port int) ([]*CollectResult, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	endpoints, err := c.serviceEndpoints(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	port = c.coveragePort(port)
+	c.logf("📦 Collecting coverage from %d endpoint(s) of service %s for test: %s\n", len(names), serviceName, testName)
+	results, err := c.collectReplicas(ctx, names, 0, func(name string) CollectOptions {
+		coverageURL := fmt.Sprintf("http://%s/coverage", net.JoinHostPort(endpoints[name], strconv.Itoa(port)))
+		return CollectOptions{TestName: path.Join(testName, name), URL: coverageURL}
+	})
+	if err != nil {
+		return results, fmt.Errorf("collect from service %s: %w", serviceName, err)
+	}
+	return results, nil
+}
+
+// CollectCoverageFromPods collects coverage from the given pods, at most concurrency at a time
+// (<= 0: all at once), each into its own subdirectory of the test directory (<test>/<pod>). Like
+// CollectCoverageFromDeployment, the results of the collected pods are returned, in the order of
+// podNames, with the errors of the failed ones.
+func (c *CoverageClient) CollectCoverageFromPods(ctx context.Context, podNames []string, testName string, port, concurrency int) ([]*CollectResult, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	if len(podNames) == 0 {
+		return nil, fmt.Errorf("collect: no pods")
+	}
+
+	c.logf("📦 Collecting coverage from %d pod(s) for test: %s\n", len(podNames), testName)
+	return c.collectReplicas(ctx, podNames, concurrency, func(pod string) CollectOptions {
+		return CollectOptions{TestName: path.Join(testName, pod), PodName: pod, Port: port}
+	})
+}
+
+// CollectCoverageFromSelector collects coverage from every running pod matching labelSelector
+// concurrently, each into <test>/<pod>, unlike CollectOptions.LabelSelector which collects from the
+// first running pod. Like CollectCoverageFromDeployment, failing pods don't stop the others.
+func (c *CoverageClient) CollectCoverageFromSelector(ctx context.Context, labelSelector, testName string, port int) ([]*CollectResult, error) {
+	if testName == "" {
+		return nil, fmt.Errorf("collect: no test name")
+	}
+	pods, err := c.runningPods(ctx, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logf("📦 Collecting coverage from %d pod(s) matching %s for test: %s\n", len(pods), labelSelector, testName)
+	results, err := c.collectReplicas(ctx, pods, 0, func(pod string) CollectOptions {
+		return CollectOptions{TestName: path.Join(testName, pod), PodName: pod, Port: port}
+	})
+	if err != nil {
+		return results, fmt.Errorf("collect from pods matching %s: %w", labelSelector, err)
+	}
+	return results, nil
+}
+
+// collectReplicas runs the collections of pods, at most limit at a time (<= 0: no limit),
+// returning the successful results (in the order of pods) and the errors of the failed ones. A
+// failed collection doesn't cancel the others.
+func (c *CoverageClient) collectReplicas(ctx context.Context, pods []string, limit int, options func(pod string) CollectOptions) ([]*CollectResult, error) {
+	results := make([]*CollectResult, len(pods))
+	errs := make([]error, len(pods))
+	var group errgroup.Group
+	if limit > 0 {
+		group.SetLimit(limit)
+	}
+	for i, pod := range pods {
+		group.Go(func() error {
+			if results[i], errs[i] = c.Collect(ctx, options(pod)); errs[i] != nil {
+				errs[i] = fmt.Errorf("pod %s: %w", pod, errs[i])
+			}
+			return nil
+		})
+	}
+	group.Wait()
+
+	results = slices.DeleteFunc(results, func(result *CollectResult) bool { return result == nil })
+	return results, errors.Join(errs...)
+}
+
+// serviceEndpoints returns the address of every ready endpoint of a Service by pod name (the
+// address with unsafe characters replaced for endpoints without a pod). A pod listed in several
+// slices, e.g. with IPv4 and IPv6 addresses, is called on its first address.
+func (c *CoverageClient) serviceEndpoints(ctx context.Context, serviceName string) (map[string]string, error) {
+	list, err := c.clientset.DiscoveryV1().EndpointSlices(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + serviceName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list endpoint slices: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+
+	endpoints := make(map[string]string)
+	for _, slice := range list.Items {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if len(endpoint.Addresses) == 0 || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+				continue
+			}
+			name := sanitizeName(endpoint.Addresses[0])
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				name = endpoint.TargetRef.Name
+			}
+			if _, ok := endpoints[name]; !ok {
+				endpoints[name] = endpoint.Addresses[0]
+			}
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("%w behind service '%s' in namespace '%s'", ErrPodNotRunning, serviceName, c.namespace)
+	}
+	return endpoints, nil
+}
+
+// deploymentPods returns the names of the running pods matching a Deployment's selector, sorted.
+// During a rollout these include pods of the previous ReplicaSet.
+func (c *CoverageClient) deploymentPods(ctx context.Context, deploymentName string) ([]string, error) {
+	deployment, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get deployment: %w", err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("parse selector of deployment %s: %w", deploymentName, err)
+	}
+	pods, err := c.runningPods(ctx, selector.String())
+	if err != nil {
+		return nil, fmt.Errorf("deployment %s: %w", deploymentName, err)
+	}
+	return pods, nil
+}
+
+// runningPods returns the names of the running pods matching labelSelector, sorted. Pods being
+// deleted are left out.
+func (c *CoverageClient) runningPods(ctx context.Context, labelSelector string) ([]string, error) {
+	pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("%w with label selector '%s' in namespace '%s'", ErrNoPodsFound, labelSelector, c.namespace)
+	}
+
+	var running []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			running = append(running, pod.Name)
+		}
+	}
+	if len(running) == 0 {
+		return nil, fmt.Errorf("%w with label selector '%s' in namespace '%s'", ErrPodNotRunning, labelSelector, c.namespace)
+	}
+	sort.Strings(running)
+	return running, nil
+}
@@ -0,0 +1,257 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// replicaForwarder forwards the ports of all pods but the broken ones to a local test server,
+// safe for concurrent collections
+type replicaForwarder struct {
+	port   int
+	broken string
+	mu     sync.Mutex
+	pods   []string
+	// Namespaces and target ports of the forwarded pods, in the order of pods
+	namespaces []string
+	ports      []int
+}
+
+func (f *replicaForwarder) ForwardPort(ctx context.Context, namespace, podName string, targetPort int) (PortForward, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pods = append(f.pods, podName)
+	f.namespaces = append(f.namespaces, namespace)
+	f.ports = append(f.ports, targetPort)
+	if podName == f.broken {
+		return nil, fmt.Errorf("pod %s unreachable", podName)
+	}
+	return &fakePortForward{port: f.port, namespace: namespace, podName: podName}, nil
+}
+
+func replicaPod(name string, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func newReplicaClient(t *testing.T, broken string, objects ...runtime.Object) (*CoverageClient, *replicaForwarder) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	t.Cleanup(server.Close)
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app"}}},
+	}
+	forwarder := &replicaForwarder{port: port, broken: broken}
+	client := &CoverageClient{
+		clientset:       fake.NewSimpleClientset(append(objects, deployment)...),
+		namespace:       "default",
+		outputDir:       t.TempDir(),
+		httpClient:      server.Client(),
+		counterBases:    &counterBases{},
+		metaCache:       &metaCache{},
+		portForwardOnly: true,
+		recordDisabled:  true,
+		quiet:           true,
+	}
+	client.SetPortForwarder(forwarder)
+	return client, forwarder
+}
+
+func TestCollectCoverageFromDeployment(t *testing.T) {
+	labels := map[string]string{"app": "app"}
+	client, forwarder := newReplicaClient(t, "",
+		replicaPod("app-b", corev1.PodRunning, labels),
+		replicaPod("app-a", corev1.PodRunning, labels),
+		replicaPod("app-pending", corev1.PodPending, labels),
+		replicaPod("other", corev1.PodRunning, map[string]string{"app": "other"}),
+	)
+
+	results, err := client.CollectCoverageFromDeployment(context.Background(), "app", "e2e", 9095)
+	if err != nil {
+		t.Fatalf("CollectCoverageFromDeployment failed: %v", err)
+	}
+	if len(results) != 2 || results[0].TestName != "e2e/app-a" || results[1].TestName != "e2e/app-b" {
+		t.Fatalf("Expected a result per running replica, got %+v", results)
+	}
+	for _, pod := range []string{"app-a", "app-b"} {
+		if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", pod, "covmeta."+newBinaryHash)); err != nil {
+			t.Errorf("Expected the covdata of %s in its subdirectory: %v", pod, err)
+		}
+	}
+	if slices.Contains(forwarder.pods, "app-pending") || slices.Contains(forwarder.pods, "other") {
+		t.Errorf("Expected only the running replicas to be collected, got %v", forwarder.pods)
+	}
+}
+
+func TestCollectCoverageFromDeployment_Errors(t *testing.T) {
+	labels := map[string]string{"app": "app"}
+	client, _ := newReplicaClient(t, "app-b", replicaPod("app-a", corev1.PodRunning, labels), replicaPod("app-b", corev1.PodRunning, labels))
+	results, err := client.CollectCoverageFromDeployment(context.Background(), "app", "e2e", 9095)
+	if err == nil || !strings.Contains(err.Error(), "pod app-b") || strings.Contains(err.Error(), "pod app-a") {
+		t.Errorf("Expected the failed replica's error, got %v", err)
+	}
+	if len(results) != 1 || results[0].TestName != "e2e/app-a" {
+		t.Errorf("Expected the collected replica's result, got %+v", results)
+	}
+
+	client, _ = newReplicaClient(t, "", replicaPod("app-a", corev1.PodPending, labels))
+	if _, err := client.CollectCoverageFromDeployment(context.Background(), "app", "e2e", 9095); !errors.Is(err, ErrPodNotRunning) {
+		t.Errorf("Expected ErrPodNotRunning, got %v", err)
+	}
+	client, _ = newReplicaClient(t, "")
+	if _, err := client.CollectCoverageFromDeployment(context.Background(), "app", "e2e", 9095); !errors.Is(err, ErrNoPodsFound) {
+		t.Errorf("Expected ErrNoPodsFound, got %v", err)
+	}
+	if _, err := client.CollectCoverageFromDeployment(context.Background(), "missing", "e2e", 9095); err == nil {
+		t.Error("Expected an error for a missing deployment")
+	}
+}
+
+func endpointSlice(name, service string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+	}
+}
+
+func podEndpoint(pod, address string, ready bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{address},
+		Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+		TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod},
+	}
+}
+
+func TestCollectCoverageFromService(t *testing.T) {
+	client, forwarder := newReplicaClient(t, "")
+	// The endpoints point at the test server on 127.0.0.1
+	port := forwarder.port
+	client.clientset = fake.NewSimpleClientset(
+		endpointSlice("app-abc", "app", podEndpoint("app-b", "127.0.0.1", true), podEndpoint("app-starting", "127.0.0.2", false)),
+		endpointSlice("app-def", "app", podEndpoint("app-a", "127.0.0.1", true), podEndpoint("app-b", "127.0.0.1", true)),
+		endpointSlice("other-abc", "other", podEndpoint("other", "127.0.0.1", true)),
+	)
+
+	results, err := client.CollectCoverageFromService(context.Background(), "app", "e2e", port)
+	if err != nil {
+		t.Fatalf("CollectCoverageFromService failed: %v", err)
+	}
+	if len(results) != 2 || results[0].TestName != "e2e/app-a" || results[1].TestName != "e2e/app-b" {
+		t.Fatalf("Expected a result per ready endpoint, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "app-a", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the covdata in the pod's subdirectory: %v", err)
+	}
+	if len(forwarder.pods) != 0 {
+		t.Errorf("Expected no port-forwards, got %v", forwarder.pods)
+	}
+
+	// Unreachable endpoints fail alone
+	client.clientset = fake.NewSimpleClientset(endpointSlice("app-abc", "app", podEndpoint("app-a", "127.0.0.1", true)))
+	if _, err := client.CollectCoverageFromService(context.Background(), "app", "e2e", 1); err == nil || !strings.Contains(err.Error(), "pod app-a") {
+		t.Errorf("Expected the endpoint's error, got %v", err)
+	}
+	if _, err := client.CollectCoverageFromService(context.Background(), "missing", "e2e", port); !errors.Is(err, ErrPodNotRunning) {
+		t.Errorf("Expected ErrPodNotRunning without endpoints, got %v", err)
+	}
+}
+
+func TestCollectCoverageFromPods_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coverage" {
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	var pods []runtime.Object
+	var names []string
+	for i := range 6 {
+		name := fmt.Sprintf("app-%d", i)
+		pods = append(pods, replicaPod(name, corev1.PodRunning, map[string]string{"app": "app"}))
+		names = append(names, name)
+	}
+	client, _ := newReplicaClient(t, "", pods...)
+	client.SetPortForwarder(&replicaForwarder{port: port, broken: "app-4"})
+	client.httpClient = server.Client()
+
+	results, err := client.CollectCoverageFromPods(context.Background(), names, "e2e", 9095, 2)
+	if err == nil || !strings.Contains(err.Error(), "pod app-4") {
+		t.Errorf("Expected the broken pod's error, got %v", err)
+	}
+	if len(results) != 5 || results[0].TestName != "e2e/app-0" || results[4].TestName != "e2e/app-5" {
+		t.Errorf("Expected the results of the other pods in order, got %+v", results)
+	}
+	if maxInFlight.Load() > 2 {
+		t.Errorf("Expected at most 2 concurrent collections, got %d", maxInFlight.Load())
+	}
+
+	if _, err := client.CollectCoverageFromPods(context.Background(), nil, "e2e", 9095, 2); err == nil {
+		t.Error("Expected an error without pods")
+	}
+}
+
+func TestCollectCoverageFromSelector(t *testing.T) {
+	labels := map[string]string{"app": "app"}
+	client, forwarder := newReplicaClient(t, "",
+		replicaPod("app-b", corev1.PodRunning, labels),
+		replicaPod("app-a", corev1.PodRunning, labels),
+		replicaPod("app-failed", corev1.PodFailed, labels),
+		replicaPod("other", corev1.PodRunning, map[string]string{"app": "other"}),
+	)
+
+	results, err := client.CollectCoverageFromSelector(context.Background(), "app=app", "e2e", 9095)
+	if err != nil {
+		t.Fatalf("CollectCoverageFromSelector failed: %v", err)
+	}
+	if len(results) != 2 || results[0].TestName != "e2e/app-a" || results[1].TestName != "e2e/app-b" {
+		t.Fatalf("Expected a result per running pod, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e", "app-b", "covmeta."+newBinaryHash)); err != nil {
+		t.Errorf("Expected the covdata in the pod's subdirectory: %v", err)
+	}
+	if slices.Contains(forwarder.pods, "app-failed") || slices.Contains(forwarder.pods, "other") {
+		t.Errorf("Expected only the running matching pods, got %v", forwarder.pods)
+	}
+
+	if _, err := client.CollectCoverageFromSelector(context.Background(), "app=missing", "e2e", 9095); !errors.Is(err, ErrNoPodsFound) {
+		t.Errorf("Expected ErrNoPodsFound, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CoverageReportOptions selects the coverage to report and what to compare it against
+type CoverageReportOptions struct {
+	TestName        string // Test whose coverage is reported
+	BaselineProfile string // Coverage profile of the base branch, for total and per-package deltas (default: pulled when SetBaseline is configured)
+	BaseRef         string // Git ref to compute patch coverage against (default: origin/$GITHUB_BASE_REF or $PULL_BASE_SHA)
+	RepoDir         string // Git checkout for patch coverage (default: the client's source directory)
+}
+
+// CoverageReport summarizes a test's coverage, optionally compared to a baseline
+type CoverageReport struct {
+	TestName string
+	Total    CoverageStats
+	Baseline *CoverageStats // Total coverage of the baseline (nil without baseline)
+	Patch    *CoverageStats // Coverage of the lines changed since BaseRef (nil without base ref)
+	Packages []PackageCoverage
+}
+
+// PackageCoverage is the coverage of one package, optionally with its baseline
+type PackageCoverage struct {
+	Package  string
+	Coverage CoverageStats
+	Baseline *CoverageStats // nil when the package isn't in the baseline
+}
+
+// Delta returns the difference to the baseline total in percentage points
+func (r *CoverageReport) Delta() (float64, bool) {
+	if r.Baseline == nil {
+		return 0, false
+	}
+	return r.Total.Percent() - r.Baseline.Percent(), true
+}
+
+// CoverageThresholds gate a coverage report. Zero values disable a check.
+type CoverageThresholds struct {
+	MinTotal    float64 `json:"minTotal,omitempty"`    // Minimum total coverage in percent
+	MinPatch    float64 `json:"minPatch,omitempty"`    // Minimum coverage of changed statements in percent
+	MaxDecrease float64 `json:"maxDecrease,omitempty"` // Maximum drop of total coverage vs. the baseline in percentage points
+	MinPackage  float64 `json:"minPackage,omitempty"`  // Minimum coverage of every package in percent
+}
+
+// thresholdResult is the outcome of one configured threshold rule
+type thresholdResult struct {
+	name    string // e.g. "total coverage >= 70.0%"
+	failure string // Why the rule failed ("" when it passed or was skipped)
+	skipped string // Why the rule couldn't be evaluated
+}
+
+// results evaluates the configured report-wide rules (MinTotal, MinPatch, MaxDecrease)
+func (t CoverageThresholds) results(report *CoverageReport) []thresholdResult {
+	var results []thresholdResult
+	if t.MinTotal > 0 {
+		r := thresholdResult{name: fmt.Sprintf("total coverage >= %.1f%%", t.MinTotal)}
+		if report.Total.Percent() < t.MinTotal {
+			r.failure = fmt.Sprintf("total coverage %.1f%% is below %.1f%%", report.Total.Percent(), t.MinTotal)
+		}
+		results = append(results, r)
+	}
+	if t.MinPatch > 0 {
+		r := thresholdResult{name: fmt.Sprintf("patch coverage >= %.1f%%", t.MinPatch)}
+		switch {
+		case report.Patch == nil:
+			r.skipped = "no base ref for patch coverage"
+		case report.Patch.Statements == 0:
+			r.skipped = "no changed statements"
+		case report.Patch.Percent() < t.MinPatch:
+			r.failure = fmt.Sprintf("patch coverage %.1f%% is below %.1f%%", report.Patch.Percent(), t.MinPatch)
+		}
+		results = append(results, r)
+	}
+	if t.MaxDecrease > 0 {
+		r := thresholdResult{name: fmt.Sprintf("total coverage decrease <= %.1f%%", t.MaxDecrease)}
+		if d, ok := report.Delta(); !ok {
+			r.skipped = "no baseline"
+		} else if -d > t.MaxDecrease {
+			r.failure = fmt.Sprintf("total coverage decreased by %.1f%%, more than %.1f%%", -d, t.MaxDecrease)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// packageFailure returns why a package violates MinPackage ("" if it passes)
+func (t CoverageThresholds) packageFailure(pkg PackageCoverage) string {
+	if t.MinPackage > 0 && pkg.Coverage.Percent() < t.MinPackage {
+		return fmt.Sprintf("package %s coverage %.1f%% is below %.1f%%", pkg.Package, pkg.Coverage.Percent(), t.MinPackage)
+	}
+	return ""
+}
+
+// Check returns a description of every threshold the report violates (nil if it passes).
+// Checks needing a baseline or patch coverage are skipped when the report lacks them.
+func (t CoverageThresholds) Check(report *CoverageReport) []string {
+	var failures []string
+	for _, r := range t.results(report) {
+		if r.failure != "" {
+			failures = append(failures, r.failure)
+		}
+	}
+	for _, pkg := range report.Packages {
+		if failure := t.packageFailure(pkg); failure != "" {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// BuildCoverageReport computes total, per-package and patch coverage of a processed test
+// (see ProcessCoverageReports)
+func (c *CoverageClient) BuildCoverageReport(ctx context.Context, opts CoverageReportOptions) (*CoverageReport, error) {
+	profile, err := c.LoadProfile(opts.TestName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CoverageReport{TestName: opts.TestName, Total: profile.Total()}
+
+	var baselinePackages map[string]CoverageStats
+	if baselinePath := c.baselineProfileFor(ctx, opts); baselinePath != "" {
+		baseline, err := ParseProfileFile(baselinePath)
+		if err != nil {
+			return nil, fmt.Errorf("load baseline: %w", err)
+		}
+		total := baseline.Total()
+		report.Baseline = &total
+		baselinePackages = baseline.Packages()
+	}
+
+	for pkg, stats := range profile.Packages() {
+		pc := PackageCoverage{Package: pkg, Coverage: stats}
+		if base, ok := baselinePackages[pkg]; ok {
+			pc.Baseline = &base
+		}
+		report.Packages = append(report.Packages, pc)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool { return report.Packages[i].Package < report.Packages[j].Package })
+
+	baseRef := opts.BaseRef
+	if baseRef == "" {
+		baseRef = detectBaseRef()
+	}
+	if baseRef != "" {
+		repoDir := opts.RepoDir
+		if repoDir == "" {
+			repoDir = c.sourceDir
+		}
+		changed, err := ChangedLines(ctx, repoDir, baseRef)
+		switch {
+		case err == nil:
+			patch := profile.ChangedLinesCoverage(changed)
+			report.Patch = &patch
+		case opts.BaseRef != "":
+			return nil, fmt.Errorf("compute patch coverage: %w", err)
+		default:
+			c.logf("⚠️  Skipping patch coverage: %v\n", err)
+		}
+	}
+
+	return report, nil
+}
+
+// detectBaseRef returns the pull request's base from CI env vars (GitHub Actions, Prow)
+func detectBaseRef() string {
+	if ref := os.Getenv("GITHUB_BASE_REF"); ref != "" {
+		return "origin/" + ref
+	}
+	return os.Getenv("PULL_BASE_SHA")
+}
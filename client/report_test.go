@@ -0,0 +1,85 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildCoverageReport_Patch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repoDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = "// line"
+	}
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	lines[14] = "// changed"
+	os.WriteFile(filepath.Join(repoDir, "main.go"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	git("commit", "-q", "-am", "change")
+
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	client := &CoverageClient{outputDir: outputDir, sourceDir: repoDir}
+	report, err := client.BuildCoverageReport(context.Background(), CoverageReportOptions{TestName: "e2e", BaseRef: "HEAD~1"})
+	if err != nil {
+		t.Fatalf("BuildCoverageReport failed: %v", err)
+	}
+
+	if report.Patch == nil || report.Patch.Statements != 3 || report.Patch.Covered != 0 {
+		t.Errorf("Expected the changed uncovered block as patch coverage, got %+v", report.Patch)
+	}
+	if _, ok := report.Delta(); ok {
+		t.Error("Expected no delta without baseline")
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Package != "github.com/example/app" {
+		t.Errorf("Expected packages sorted by name, got %+v", report.Packages)
+	}
+
+	if _, err := client.BuildCoverageReport(context.Background(), CoverageReportOptions{TestName: "e2e", BaseRef: "missing-ref"}); err == nil {
+		t.Error("Expected error for unknown base ref")
+	}
+}
+
+func TestCoverageThresholdsCheck(t *testing.T) {
+	report := &CoverageReport{
+		Total:    CoverageStats{Statements: 100, Covered: 70},
+		Baseline: &CoverageStats{Statements: 100, Covered: 75},
+		Patch:    &CoverageStats{Statements: 10, Covered: 5},
+	}
+
+	if failures := (CoverageThresholds{MinTotal: 70, MinPatch: 50, MaxDecrease: 5}).Check(report); len(failures) != 0 {
+		t.Errorf("Expected report to pass, got %v", failures)
+	}
+
+	failures := CoverageThresholds{MinTotal: 80, MinPatch: 60, MaxDecrease: 1}.Check(report)
+	if len(failures) != 3 {
+		t.Fatalf("Expected 3 failures, got %v", failures)
+	}
+	if failures[0] != "total coverage 70.0% is below 80.0%" {
+		t.Errorf("Unexpected failure message: %s", failures[0])
+	}
+
+	// Checks without data are skipped
+	if failures := (CoverageThresholds{MinPatch: 60, MaxDecrease: 1}).Check(&CoverageReport{}); len(failures) != 0 {
+		t.Errorf("Expected checks without baseline or patch to be skipped, got %v", failures)
+	}
+}
@@ -0,0 +1,57 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ResetCounters clears the coverage counters of the application in a pod via port-forwarding,
+// without collecting them (POST /coverage/reset on the coverage port, 0 for the client's). Reset
+// before each test case and collect after it to get the coverage of that test case alone; see
+// CollectOptions.Reset to clear the counters as part of a collection instead. Binaries built
+// with -covermode=set can't clear their counters and answer 409 Conflict, servers without the
+// endpoint 404 (ErrCoverageNotEnabled).
+func (c *CoverageClient) ResetCounters(ctx context.Context, podName string, port int) (err error) {
+	port = c.coveragePort(port)
+	ctx, step := startStep(ctx, "reset", attribute.String("pod", podName), attribute.Int("port", port))
+	defer func() { step.end(err) }()
+
+	tunnel, err := c.setupPortForward(ctx, podName, port)
+	if err != nil {
+		return fmt.Errorf("setup port forward: %w", err)
+	}
+	defer tunnel.Close()
+
+	resetURL := fmt.Sprintf("http://localhost:%d/coverage/reset", tunnel.LocalPort())
+	if err := c.resetCountersAt(ctx, resetURL); err != nil {
+		return fmt.Errorf("reset counters of pod %s: %w", podName, err)
+	}
+	c.logf("🔄 Coverage counters reset in pod %s\n", podName)
+	return nil
+}
+
+// resetCountersAt asks the coverage server at resetURL to clear its counters
+func (c *CoverageClient) resetCountersAt(ctx context.Context, resetURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, resetURL, nil)
+	if err != nil {
+		return fmt.Errorf("create reset request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send reset request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newCoverageEndpointError(resp.StatusCode, body)
+	}
+	if resp.Header.Get(coverageResetHeader) != "true" {
+		return fmt.Errorf("coverage server didn't confirm the reset")
+	}
+	return nil
+}
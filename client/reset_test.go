@@ -0,0 +1,64 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newResetClient returns a client whose pods forward to a server answering resets with handler
+func newResetClient(t *testing.T, handler http.HandlerFunc) (*CoverageClient, *fakePortForwarder) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	forwarder := &fakePortForwarder{port: port}
+	client := &CoverageClient{namespace: "default", outputDir: t.TempDir(), httpClient: server.Client(), quiet: true}
+	client.SetPortForwarder(forwarder)
+	return client, forwarder
+}
+
+func TestResetCounters(t *testing.T) {
+	var requests []string
+	client, forwarder := newResetClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set(coverageResetHeader, "true")
+	})
+
+	if err := client.ResetCounters(context.Background(), "app-7d9f", 0); err != nil {
+		t.Fatalf("ResetCounters failed: %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "POST /coverage/reset" {
+		t.Errorf("Expected one POST to the reset endpoint, got %v", requests)
+	}
+	if len(forwarder.tunnels) != 1 || forwarder.tunnels[0].podName != "app-7d9f" || !forwarder.tunnels[0].closed {
+		t.Errorf("Expected one closed tunnel to app-7d9f, got %+v", forwarder.tunnels)
+	}
+}
+
+func TestResetCounters_Errors(t *testing.T) {
+	client, _ := newResetClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ClearCounters invoked for program built with -covermode=set (please use -covermode=atomic)", http.StatusConflict)
+	})
+	err := client.ResetCounters(context.Background(), "app-7d9f", 9095)
+	var endpointErr *CoverageEndpointError
+	if !errors.As(err, &endpointErr) || endpointErr.StatusCode != http.StatusConflict || errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected the conflict, got %v", err)
+	}
+
+	// Servers without the endpoint
+	client, _ = newResetClient(t, http.NotFound)
+	if err := client.ResetCounters(context.Background(), "app-7d9f", 9095); !errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected ErrCoverageNotEnabled, got %v", err)
+	}
+
+	// Something else answering on the port
+	client, _ = newResetClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	if err := client.ResetCounters(context.Background(), "app-7d9f", 9095); err == nil || !strings.Contains(err.Error(), "didn't confirm") {
+		t.Errorf("Expected an unconfirmed reset error, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defaults of RetryPolicy
+const (
+	defaultRetryMaxDelay = 30 * time.Second
+)
+
+// RetryPolicy retries port-forward collections that fail midway, e.g. when the tunnel's stream
+// drops during the download: each retry opens a new port-forward and requests the coverage again.
+// Files are only renamed into place once complete, so a broken transfer leaves nothing behind.
+type RetryPolicy struct {
+	Attempts int           // Retries after a failed attempt (default: 0, no retries)
+	Delay    time.Duration // Wait before the first retry, doubling for each further one (default: 2s)
+	MaxDelay time.Duration // Longest wait between attempts (default: 30s)
+}
+
+// SetRetryPolicy configures retries of port-forward collections, including CollectCoverageFromPod
+// and the port-forward method of fallback chains (default: no retries). Collect's Retries retry
+// whole collections on top of this.
+func (c *CoverageClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+// retryDelay returns the wait before retry number attempt (1 for the first retry)
+func (p RetryPolicy) retryDelay(attempt int) time.Duration {
+	delay, maxDelay := p.Delay, p.MaxDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// retryable reports whether a failed collection attempt is worth repeating: not when the
+// application lacks coverage or the collection was cancelled
+func retryable(ctx context.Context, err error) bool {
+	return !errors.Is(err, ErrCoverageNotEnabled) && ctx.Err() == nil
+}
+
+// collectViaPortForward collects from a pod through a new port-forward, retrying with a fresh
+// tunnel following the client's RetryPolicy
+func (c *CoverageClient) collectViaPortForward(ctx context.Context, podName, testName string, targetPort int) error {
+	policy := c.retryPolicy
+	for attempt := 0; ; attempt++ {
+		err := c.collectThroughTunnel(ctx, podName, testName, targetPort)
+		if err == nil || attempt >= policy.Attempts || !retryable(ctx, err) {
+			return err
+		}
+
+		delay := policy.retryDelay(attempt + 1)
+		c.logf("🔁 Re-establishing port-forward to pod %s in %v (attempt %d of %d): %v\n", podName, delay, attempt+2, policy.Attempts+1, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("retry port forward: %w", ctx.Err())
+		}
+	}
+}
+
+// collectThroughTunnel runs one port-forward collection attempt: it opens the tunnel, waits for
+// the coverage server behind it and requests the coverage
+func (c *CoverageClient) collectThroughTunnel(ctx context.Context, podName, testName string, targetPort int) error {
+	tunnel, err := c.setupPortForward(ctx, podName, targetPort)
+	if err != nil {
+		return &tunnelError{fmt.Errorf("setup port forward: %w", err)}
+	}
+	defer tunnel.Close()
+
+	// Wait until the coverage server answers through the tunnel
+	baseURL := fmt.Sprintf("http://localhost:%d", tunnel.LocalPort())
+	if err := c.waitForCoverageServer(ctx, baseURL); err != nil {
+		return &tunnelError{err}
+	}
+	if err := c.collectCoverageFromURL(ctx, baseURL+"/coverage", testName); err != nil {
+		return err
+	}
+	c.recordServerInfo(ctx, baseURL)
+	return nil
+}
+
+// tunnelError marks failures to open a port-forward or reach the server through it, as opposed to
+// failures of the coverage request itself
+type tunnelError struct {
+	err error
+}
+
+func (e *tunnelError) Error() string { return e.err.Error() }
+
+func (e *tunnelError) Unwrap() error { return e.err }
@@ -0,0 +1,100 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCollectCoverageFromPod_RetriesDroppedStream(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coverage" {
+			return
+		}
+		if requests.Add(1) == 1 {
+			// The stream drops midway through the response
+			w.Header().Set("Content-Length", "1000")
+			w.Write([]byte(`{"meta_filename":`))
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	pod, _, _ := newFallbackTestObjects()
+	forwarder := &replicaForwarder{port: port}
+	client := &CoverageClient{
+		clientset:      fake.NewSimpleClientset(pod),
+		namespace:      "default",
+		outputDir:      t.TempDir(),
+		httpClient:     server.Client(),
+		counterBases:   &counterBases{},
+		metaCache:      &metaCache{},
+		recordDisabled: true,
+		quiet:          true,
+	}
+	client.SetPortForwarder(forwarder)
+
+	if _, err := client.CollectCoverageFromPod(context.Background(), "demo-pod", "e2e", 9095); err == nil {
+		t.Fatal("Expected the dropped stream to fail without retries")
+	}
+
+	requests.Store(0)
+	forwarder.pods = nil
+	client.SetRetryPolicy(RetryPolicy{Attempts: 2, Delay: time.Millisecond})
+	if _, err := client.CollectCoverageFromPod(context.Background(), "demo-pod", "e2e", 9095); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	// Container detection forwards once more to probe /health
+	if len(forwarder.pods) < 2 || requests.Load() != 2 {
+		t.Errorf("Expected a new port-forward per attempt, got %d forwards and %d requests", len(forwarder.pods), requests.Load())
+	}
+	if files := covdataFiles(client.testDir("e2e")); len(files) != 2 {
+		t.Errorf("Expected only the complete covdata, got %v", files)
+	}
+}
+
+func TestCollectViaPortForward_NotRetried(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no meta-data available (binary not built with -cover?)", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	forwarder := &replicaForwarder{port: port}
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client(), counterBases: &counterBases{}, metaCache: &metaCache{}, quiet: true}
+	client.SetPortForwarder(forwarder)
+	client.SetRetryPolicy(RetryPolicy{Attempts: 3, Delay: time.Millisecond})
+	if err := client.collectViaPortForward(context.Background(), "demo-pod", "e2e", 9095); !errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected ErrCoverageNotEnabled, got %v", err)
+	}
+	if len(forwarder.pods) != 1 {
+		t.Errorf("Expected no retries for an application without coverage, got %d forwards", len(forwarder.pods))
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := policy.retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := (RetryPolicy{}).retryDelay(1); got != defaultRetryDelay {
+		t.Errorf("Expected the default delay, got %v", got)
+	}
+}
@@ -0,0 +1,136 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// routeGVR identifies OpenShift Routes, accessed through the dynamic client to avoid a dependency on the OpenShift API module
+var routeGVR = schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}
+
+// RoutesAvailable reports whether the cluster serves the OpenShift route.openshift.io/v1 API
+func (c *CoverageClient) RoutesAvailable() bool {
+	resources, err := c.clientset.Discovery().ServerResourcesForGroupVersion(routeGVR.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == routeGVR.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// FindRouteURL returns the base URL of an OpenShift Route exposing the given Service and port.
+// Edge and reencrypt routes are reached over https; passthrough routes are skipped because the
+// coverage server only speaks plain HTTP.
+func (c *CoverageClient) FindRouteURL(ctx context.Context, serviceName string, targetPort int) (string, error) {
+	if c.dynamicClient == nil || !c.RoutesAvailable() {
+		return "", fmt.Errorf("openshift routes are not available in this cluster")
+	}
+
+	routes, err := c.dynamicClient.Resource(routeGVR).Namespace(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("list routes: %w", err)
+	}
+
+	portNames := c.servicePortNames(ctx, serviceName, targetPort)
+
+	for _, route := range routes.Items {
+		host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+		if host == "" || !routeTargetsService(route, serviceName) {
+			continue
+		}
+		if !routeTargetsPort(route, targetPort, portNames) {
+			continue
+		}
+
+		scheme := "http"
+		termination, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "termination")
+		switch strings.ToLower(termination) {
+		case "":
+		case "edge", "reencrypt":
+			scheme = "https"
+		default:
+			c.logf("  ⚠️  Skipping route %s with %s TLS termination\n", route.GetName(), termination)
+			continue
+		}
+
+		path, _, _ := unstructured.NestedString(route.Object, "spec", "path")
+		return fmt.Sprintf("%s://%s%s", scheme, host, strings.TrimSuffix(path, "/")), nil
+	}
+
+	return "", fmt.Errorf("no route found exposing service '%s' port %d", serviceName, targetPort)
+}
+
+// routeTargetsService checks the route's primary backend and alternate backends for the Service
+func routeTargetsService(route unstructured.Unstructured, serviceName string) bool {
+	backends := []interface{}{}
+	if to, found, _ := unstructured.NestedMap(route.Object, "spec", "to"); found {
+		backends = append(backends, to)
+	}
+	if alternates, found, _ := unstructured.NestedSlice(route.Object, "spec", "alternateBackends"); found {
+		backends = append(backends, alternates...)
+	}
+
+	for _, backend := range backends {
+		ref, ok := backend.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+		if (kind == "" || kind == "Service") && name == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// routeTargetsPort checks spec.port.targetPort, which is either a port number or a Service port name.
+// A route without spec.port sends traffic to every Service port.
+func routeTargetsPort(route unstructured.Unstructured, targetPort int, portNames []string) bool {
+	value, found, _ := unstructured.NestedFieldNoCopy(route.Object, "spec", "port", "targetPort")
+	if !found {
+		return true
+	}
+
+	switch port := value.(type) {
+	case int64:
+		return int(port) == targetPort
+	case float64:
+		return int(port) == targetPort
+	case string:
+		for _, name := range portNames {
+			if name == port {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// servicePortNames returns the names of the Service ports that map to targetPort
+func (c *CoverageClient) servicePortNames(ctx context.Context, serviceName string, targetPort int) []string {
+	svc, err := c.clientset.CoreV1().Services(c.namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, port := range svc.Spec.Ports {
+		if port.Name == "" {
+			continue
+		}
+		if int(port.Port) == targetPort || port.TargetPort.IntValue() == targetPort {
+			names = append(names, port.Name)
+		}
+	}
+	return names
+}
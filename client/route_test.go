@@ -0,0 +1,101 @@
+package coverageclient
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestRoute(name, service string, targetPort interface{}, termination string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"host": name + ".apps.example.com",
+		"to":   map[string]interface{}{"kind": "Service", "name": service},
+	}
+	if targetPort != nil {
+		spec["port"] = map[string]interface{}{"targetPort": targetPort}
+	}
+	if termination != "" {
+		spec["tls"] = map[string]interface{}{"termination": termination}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "route.openshift.io/v1",
+		"kind":       "Route",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"spec":       spec,
+	}}
+}
+
+func newRouteTestClient(withRoutesAPI bool, routes ...runtime.Object) *CoverageClient {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-svc", Namespace: "default"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "coverage", Port: 9095, TargetPort: intstr.FromInt32(9095)},
+		}},
+	}
+	clientset := fake.NewSimpleClientset(svc)
+	if withRoutesAPI {
+		clientset.Resources = []*metav1.APIResourceList{{
+			GroupVersion: "route.openshift.io/v1",
+			APIResources: []metav1.APIResource{{Name: "routes", Namespaced: true, Kind: "Route"}},
+		}}
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{routeGVR: "RouteList"}, routes...)
+
+	return &CoverageClient{clientset: clientset, dynamicClient: dynamicClient, namespace: "default"}
+}
+
+func TestFindRouteURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		route    *unstructured.Unstructured
+		expected string
+	}{
+		{"plain http by port name", newTestRoute("plain", "demo-svc", "coverage", ""), "http://plain.apps.example.com"},
+		{"edge by port number", newTestRoute("edge", "demo-svc", int64(9095), "edge"), "https://edge.apps.example.com"},
+		{"reencrypt without port", newTestRoute("reencrypt", "demo-svc", nil, "reencrypt"), "https://reencrypt.apps.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newRouteTestClient(true, tt.route)
+			routeURL, err := client.FindRouteURL(context.Background(), "demo-svc", 9095)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if routeURL != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, routeURL)
+			}
+		})
+	}
+}
+
+func TestFindRouteURL_NoMatch(t *testing.T) {
+	client := newRouteTestClient(true,
+		newTestRoute("passthrough", "demo-svc", "coverage", "passthrough"),
+		newTestRoute("other-port", "demo-svc", int64(8080), ""),
+		newTestRoute("other-svc", "other-svc", "coverage", ""),
+	)
+	if routeURL, err := client.FindRouteURL(context.Background(), "demo-svc", 9095); err == nil {
+		t.Errorf("Expected no matching route, got %s", routeURL)
+	}
+}
+
+func TestFindRouteURL_RoutesUnavailable(t *testing.T) {
+	client := newRouteTestClient(false, newTestRoute("plain", "demo-svc", "coverage", ""))
+	if client.RoutesAvailable() {
+		t.Error("Expected routes API to be unavailable")
+	}
+	if _, err := client.FindRouteURL(context.Background(), "demo-svc", 9095); err == nil {
+		t.Error("Expected error when routes API is unavailable")
+	}
+}
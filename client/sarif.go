@@ -0,0 +1,197 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	sarifSchema          = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifRuleUncovered   = "uncovered-changed-code"
+	sarifToolInformation = "https://github.com/psturc/go-coverage-http"
+)
+
+// SARIFOptions configures WriteUncoveredSARIF
+type SARIFOptions struct {
+	TestName string // Processed test whose coverage is checked
+	BaseRef  string // Git ref the PR diff is computed against (default: detected from CI env vars)
+	RepoDir  string // Git checkout (default: the client's source directory)
+	Path     string // Output file (default: uncovered.sarif in the test directory)
+	Level    string // Result level: "note", "warning" or "error" (default: "warning")
+}
+
+// sarifLog is the subset of SARIF 2.1.0 used for uncovered lines
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool              sarifTool       `json:"tool"`
+	AutomationDetails sarifAutomation `json:"automationDetails"`
+	Results           []sarifResult   `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+}
+
+type sarifAutomation struct {
+	ID string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// WriteUncoveredSARIF writes a SARIF file flagging changed lines of the PR diff that no test
+// covered, so GitHub code scanning and other SARIF consumers annotate them in the PR view.
+// It returns the number of findings.
+func (c *CoverageClient) WriteUncoveredSARIF(ctx context.Context, opts SARIFOptions) (int, error) {
+	if opts.RepoDir == "" {
+		opts.RepoDir = c.sourceDir
+	}
+	if opts.Path == "" {
+		opts.Path = filepath.Join(c.outputDir, opts.TestName, "uncovered.sarif")
+	}
+	if opts.Level == "" {
+		opts.Level = "warning"
+	}
+	if opts.BaseRef == "" {
+		opts.BaseRef = detectBaseRef()
+	}
+	if opts.BaseRef == "" {
+		return 0, fmt.Errorf("base ref not set and not detected from CI environment")
+	}
+
+	profile, err := c.LoadProfile(opts.TestName)
+	if err != nil {
+		return 0, err
+	}
+	changed, err := ChangedLines(ctx, opts.RepoDir, opts.BaseRef)
+	if err != nil {
+		return 0, fmt.Errorf("compute changed lines: %w", err)
+	}
+	relativizeProfilePaths(profile, opts.RepoDir)
+
+	results := uncoveredChangedResults(profile, changed, opts)
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "go-coverage-http",
+				InformationURI: sarifToolInformation,
+				Rules: []sarifRule{{
+					ID:               sarifRuleUncovered,
+					ShortDescription: sarifMessage{Text: "Changed code not covered by tests"},
+					FullDescription:  sarifMessage{Text: "Lines changed in this pull request were not executed by the test suite that collected the coverage."},
+				}},
+			}},
+			// Separate categories keep results of different suites from replacing each other
+			AutomationDetails: sarifAutomation{ID: "go-coverage-http/" + sanitizeName(opts.TestName) + "/"},
+			Results:           results,
+		}},
+	}
+
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("marshal SARIF: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0755); err != nil {
+		return 0, fmt.Errorf("create SARIF directory: %w", err)
+	}
+	if err := os.WriteFile(opts.Path, data, 0644); err != nil {
+		return 0, fmt.Errorf("write SARIF: %w", err)
+	}
+
+	c.logf("📝 SARIF report written: %s (%d uncovered changed region(s))\n", opts.Path, len(results))
+	return len(results), nil
+}
+
+// uncoveredChangedResults returns one result per uncovered block touching changed lines,
+// narrowed to the changed lines inside the block
+func uncoveredChangedResults(profile *Profile, changed map[string][]int, opts SARIFOptions) []sarifResult {
+	results := []sarifResult{}
+	sortedLines := make(map[string][]int)
+	for _, b := range profile.Blocks {
+		if b.Count > 0 {
+			continue
+		}
+		file, ok := matchChangedFile(changed, b.File)
+		if !ok {
+			continue
+		}
+		lines, ok := sortedLines[file]
+		if !ok {
+			lines = changedLinesForFile(changed, b.File)
+			sortedLines[file] = lines
+		}
+		i := sort.SearchInts(lines, b.StartLine)
+		if i >= len(lines) || lines[i] > b.EndLine {
+			continue
+		}
+		start, end := lines[i], lines[i]
+		for ; i < len(lines) && lines[i] <= b.EndLine; i++ {
+			end = lines[i]
+		}
+
+		message := fmt.Sprintf("Changed line %d is not covered by %s", start, opts.TestName)
+		if end > start {
+			message = fmt.Sprintf("Changed lines %d-%d are not covered by %s", start, end, opts.TestName)
+		}
+		results = append(results, sarifResult{
+			RuleID:  sarifRuleUncovered,
+			Level:   opts.Level,
+			Message: sarifMessage{Text: message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: file, URIBaseID: "%SRCROOT%"},
+				Region:           sarifRegion{StartLine: start, EndLine: end},
+			}}},
+		})
+	}
+	return results
+}
@@ -0,0 +1,42 @@
+package coverageclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestUncoveredChangedResults(t *testing.T) {
+	profile, err := ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatalf("ParseProfile failed: %v", err)
+	}
+
+	results := uncoveredChangedResults(profile, map[string][]int{
+		"main.go":              {11, 14, 15, 40},
+		"pkg/util/util.go":     {6},
+		"pkg/other/unknown.go": {1},
+	}, SARIFOptions{TestName: "e2e", Level: "warning"})
+
+	// Only the uncovered main.go block (14-16) is reported; 10-12 and util.go are covered
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %+v", results)
+	}
+	location := results[0].Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "main.go" || location.Region.StartLine != 14 || location.Region.EndLine != 15 {
+		t.Errorf("Unexpected location: %+v", location)
+	}
+	if results[0].Message.Text != "Changed lines 14-15 are not covered by e2e" {
+		t.Errorf("Unexpected message: %s", results[0].Message.Text)
+	}
+}
+
+func TestWriteUncoveredSARIF_NoBaseRef(t *testing.T) {
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("PULL_BASE_SHA", "")
+	client := &CoverageClient{outputDir: t.TempDir()}
+
+	if _, err := client.WriteUncoveredSARIF(context.Background(), SARIFOptions{TestName: "e2e"}); err == nil {
+		t.Error("Expected error without base ref")
+	}
+}
@@ -0,0 +1,173 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ScheduledTarget is a workload whose coverage is collected on every scheduled run
+type ScheduledTarget struct {
+	Name          string          `json:"name"`                    // Prefix of the per-run test name
+	Context       string          `json:"context,omitempty"`       // Kubeconfig context of the target's cluster (default: the client's cluster)
+	Namespace     string          `json:"namespace,omitempty"`     // Namespace of the target (default: the client's namespace)
+	PodName       string          `json:"podName,omitempty"`       // Pod to collect from
+	LabelSelector string          `json:"labelSelector,omitempty"` // Or: first running pod matching this selector
+	Port          int             `json:"port,omitempty"`          // Coverage port (default: 9095, see SetCoveragePort)
+	Fallback      FallbackOptions `json:"fallback,omitempty"`      // Collection methods to try (default: DefaultFallbackMethods)
+}
+
+// ScheduledCollectionOptions configures RunScheduledCollection
+type ScheduledCollectionOptions struct {
+	Schedule string                       // Cron expression, see ParseCronSchedule
+	Targets  []ScheduledTarget            // Workloads to collect from
+	Process  bool                         // Generate text/HTML reports after collecting
+	Push     *PushCoverageArtifactOptions // Push each run as an OCI artifact (Tag defaults to the run's test name)
+	// OnRunComplete is called after every run with the test names that were collected and the run's error
+	OnRunComplete func(scheduledAt time.Time, testNames []string, err error)
+}
+
+// RunScheduledCollection collects coverage from all targets on a cron schedule until ctx is cancelled,
+// for measuring coverage of long-running environments under real traffic. Like a CronJob with
+// concurrencyPolicy Forbid, a run is skipped while the previous one is still in progress.
+// Each target's run is stored as "<target>-<timestamp>" in the output directory.
+func (c *CoverageClient) RunScheduledCollection(ctx context.Context, opts ScheduledCollectionOptions) error {
+	schedule, err := ParseCronSchedule(opts.Schedule)
+	if err != nil {
+		return err
+	}
+	if len(opts.Targets) == 0 {
+		return fmt.Errorf("no targets configured for scheduled collection")
+	}
+	for _, target := range opts.Targets {
+		if target.Name == "" || (target.PodName == "" && target.LabelSelector == "") {
+			return fmt.Errorf("scheduled target needs a name and a pod name or label selector: %+v", target)
+		}
+	}
+	clients, err := c.targetClients(opts.Targets)
+	if err != nil {
+		return err
+	}
+
+	c.logf("⏰ Scheduled coverage collection started (schedule: %s, targets: %d)\n", opts.Schedule, len(opts.Targets))
+
+	var running atomic.Bool
+	var wg sync.WaitGroup
+	next := schedule.Next(time.Now())
+	for {
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q never fires", opts.Schedule)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			// Let an in-progress run finish writing its output
+			wg.Wait()
+			c.logf("⏰ Scheduled coverage collection stopped\n")
+			return nil
+		case <-timer.C:
+		}
+
+		scheduledAt := next
+		next = schedule.Next(time.Now())
+
+		if !running.CompareAndSwap(false, true) {
+			c.logf("⚠️  Skipping run scheduled at %s, previous run still in progress\n", scheduledAt.Format(time.RFC3339))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer running.Store(false)
+			testNames, err := c.runScheduledCollection(ctx, scheduledAt, opts, clients)
+			if err != nil {
+				c.logf("⚠️  Scheduled run at %s failed: %v\n", scheduledAt.Format(time.RFC3339), err)
+			}
+			if opts.OnRunComplete != nil {
+				opts.OnRunComplete(scheduledAt, testNames, err)
+			}
+		}()
+	}
+}
+
+// runScheduledCollection performs one scheduled run over all targets, continuing past failing targets
+func (c *CoverageClient) runScheduledCollection(ctx context.Context, scheduledAt time.Time, opts ScheduledCollectionOptions, clients []*CoverageClient) ([]string, error) {
+	var testNames []string
+	var errs []error
+
+	for i, target := range opts.Targets {
+		testName := fmt.Sprintf("%s-%s", target.Name, scheduledAt.UTC().Format("20060102-150405"))
+		if err := clients[i].collectScheduledTarget(ctx, target, testName, opts); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+			continue
+		}
+		testNames = append(testNames, testName)
+	}
+
+	return testNames, errors.Join(errs...)
+}
+
+// targetClients resolves the client of every target, sharing one client per context/namespace pair.
+// Targets without a context or namespace use c itself.
+func (c *CoverageClient) targetClients(targets []ScheduledTarget) ([]*CoverageClient, error) {
+	clients := make([]*CoverageClient, len(targets))
+	byKey := make(map[string]*CoverageClient)
+	for i, target := range targets {
+		if target.Context == "" && target.Namespace == "" {
+			clients[i] = c
+			continue
+		}
+		key := target.Context + "/" + target.Namespace
+		if byKey[key] == nil {
+			var err error
+			if target.Context == "" {
+				clone := *c
+				clone.namespace = target.Namespace
+				byKey[key] = &clone
+			} else if byKey[key], err = c.ForContext(target.Context, target.Namespace); err != nil {
+				return nil, fmt.Errorf("target %s: %w", target.Name, err)
+			}
+		}
+		clients[i] = byKey[key]
+	}
+	return clients, nil
+}
+
+// collectScheduledTarget collects, processes and pushes the coverage of a single target
+func (c *CoverageClient) collectScheduledTarget(ctx context.Context, target ScheduledTarget, testName string, opts ScheduledCollectionOptions) error {
+	podName := target.PodName
+	if podName == "" {
+		var err error
+		if podName, err = c.GetPodNameWithContext(ctx, target.LabelSelector); err != nil {
+			return err
+		}
+	}
+	port := c.coveragePort(target.Port)
+
+	if _, err := c.CollectCoverageWithFallback(ctx, podName, testName, port, target.Fallback); err != nil {
+		return err
+	}
+
+	if opts.Process {
+		if err := c.ProcessCoverageReports(testName); err != nil {
+			return fmt.Errorf("process reports: %w", err)
+		}
+	}
+
+	if opts.Push != nil {
+		pushOpts := *opts.Push
+		if pushOpts.Tag == "" {
+			pushOpts.Tag = testName
+		}
+		if err := c.PushCoverageArtifact(ctx, testName, pushOpts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package coverageclient
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunScheduledCollection(t *testing.T) {
+	server := newCoverageTestServer(t)
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	pod, _, _ := newFallbackTestObjects()
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(pod),
+		namespace:  "default",
+		outputDir:  tempDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	client.SetRecordCollection(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	runs := make(chan []string, 10)
+	err := client.RunScheduledCollection(ctx, ScheduledCollectionOptions{
+		Schedule: "@every 100ms",
+		Targets: []ScheduledTarget{{
+			Name:    "staging",
+			PodName: "demo-pod",
+			Fallback: FallbackOptions{
+				Methods:       []CollectionMethod{MethodIngress},
+				ContainerName: "app",
+				IngressURL:    server.URL,
+			},
+		}},
+		OnRunComplete: func(scheduledAt time.Time, testNames []string, err error) {
+			if err != nil {
+				t.Errorf("Unexpected run error: %v", err)
+			}
+			runs <- testNames
+			cancel()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	select {
+	case testNames := <-runs:
+		if len(testNames) != 1 {
+			t.Fatalf("Expected 1 collected target, got %v", testNames)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, testNames[0], "covmeta.test")); err != nil {
+			t.Errorf("Expected coverage files for %s: %v", testNames[0], err)
+		}
+	default:
+		t.Fatal("Expected at least one scheduled run")
+	}
+}
+
+func TestRunScheduledCollection_InvalidOptions(t *testing.T) {
+	client := &CoverageClient{}
+	ctx := context.Background()
+
+	if err := client.RunScheduledCollection(ctx, ScheduledCollectionOptions{Schedule: "bogus", Targets: []ScheduledTarget{{Name: "a", PodName: "p"}}}); err == nil {
+		t.Error("Expected error for invalid schedule")
+	}
+	if err := client.RunScheduledCollection(ctx, ScheduledCollectionOptions{Schedule: "@hourly"}); err == nil {
+		t.Error("Expected error without targets")
+	}
+	if err := client.RunScheduledCollection(ctx, ScheduledCollectionOptions{Schedule: "@hourly", Targets: []ScheduledTarget{{Name: "a"}}}); err == nil {
+		t.Error("Expected error for target without pod")
+	}
+}
+
+func TestTargetClients(t *testing.T) {
+	client := &CoverageClient{namespace: "default"}
+
+	clients, err := client.targetClients([]ScheduledTarget{
+		{Name: "a", PodName: "p"},
+		{Name: "b", PodName: "p", Namespace: "team-b"},
+		{Name: "c", PodName: "p", Namespace: "team-b"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if clients[0] != client {
+		t.Error("Expected target without context or namespace to use the client itself")
+	}
+	if clients[1].namespace != "team-b" || clients[1] != clients[2] {
+		t.Error("Expected targets in the same namespace to share one client")
+	}
+	if client.namespace != "default" {
+		t.Error("Expected original client namespace to be unchanged")
+	}
+
+	t.Setenv("KUBECONFIG", writeKubeconfig(t, t.TempDir(), "hub", "https://hub.example.com:6443"))
+	if _, err := client.targetClients([]ScheduledTarget{{Name: "spoke", PodName: "p", Context: "spoke"}}); err == nil {
+		t.Error("Expected error for unknown kubeconfig context")
+	}
+}
@@ -0,0 +1,94 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ServerInfo describes the binary serving coverage, from the coverage server's /coverage/info
+// endpoint. Pod collections through a port-forward record it in metadata.json.
+type ServerInfo struct {
+	GoVersion       string  `json:"go_version"`
+	ModulePath      string  `json:"module_path"`
+	VCSRevision     string  `json:"vcs_revision,omitempty"`
+	VCSTime         string  `json:"vcs_time,omitempty"` // Commit time of VCSRevision (RFC 3339)
+	VCSModified     bool    `json:"vcs_modified,omitempty"`
+	BuildTime       string  `json:"build_time,omitempty"` // Modification time of the executable (RFC 3339)
+	CoverEnabled    bool    `json:"cover_enabled"`        // Whether the binary was built with -cover
+	CoverDir        string  `json:"gocoverdir,omitempty"` // GOCOVERDIR of the process
+	StartedAt       string  `json:"started_at"`           // When the process started (RFC 3339)
+	UptimeSeconds   float64 `json:"uptime_seconds"`       // Uptime when the info was requested
+	SnapshotsServed int64   `json:"snapshots_served"`     // Snapshots sent by /coverage, this collection's included
+}
+
+// GetServerInfo requests the /coverage/info of the coverage server at baseURL, e.g.
+// http://localhost:9095. Servers older than the endpoint answer with a CoverageEndpointError
+// matching ErrCoverageNotEnabled (404).
+func (c *CoverageClient) GetServerInfo(ctx context.Context, baseURL string) (*ServerInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/coverage/info", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create server info request: %w", err)
+	}
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send server info request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newCoverageEndpointError(resp.StatusCode, nil)
+	}
+	var info ServerInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode server info: %w", err)
+	}
+	return &info, nil
+}
+
+// serverInfoKey carries a *serverInfoSlot through a pod collection: the tunnel the coverage is
+// collected through fills it in, and the metadata saved afterwards records it
+type serverInfoKey struct{}
+
+type serverInfoSlot struct {
+	info *ServerInfo
+}
+
+// withServerInfoSlot returns a context whose port-forward collection records the server's info
+func withServerInfoSlot(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(serverInfoKey{}).(*serverInfoSlot); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, serverInfoKey{}, &serverInfoSlot{})
+}
+
+// collectedServerInfo returns the server info recorded in ctx's collection, if any
+func collectedServerInfo(ctx context.Context) *ServerInfo {
+	if slot, ok := ctx.Value(serverInfoKey{}).(*serverInfoSlot); ok {
+		return slot.info
+	}
+	return nil
+}
+
+// recordServerInfo requests the info of the coverage server at baseURL when ctx's collection
+// records it. Older servers without the endpoint leave it empty.
+func (c *CoverageClient) recordServerInfo(ctx context.Context, baseURL string) {
+	slot, ok := ctx.Value(serverInfoKey{}).(*serverInfoSlot)
+	if !ok {
+		return
+	}
+	info, err := c.GetServerInfo(ctx, baseURL)
+	if err != nil {
+		c.logf("  ℹ️  No server info from %s: %v\n", baseURL, err)
+		return
+	}
+	slot.info = info
+}
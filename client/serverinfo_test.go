@@ -0,0 +1,90 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newServerInfoClient returns a client collecting from a fake coverage server, with /coverage/info
+// answered by info (older servers without the endpoint when empty)
+func newServerInfoClient(t *testing.T, info string) *CoverageClient {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/coverage", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, covdataResponse(newBinaryHash, "covcounters."+newBinaryHash+".1.1"))
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	if info != "" {
+		mux.HandleFunc("/coverage/info", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, info)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-7d9f", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 9095}}}}},
+	}
+	client := &CoverageClient{
+		clientset:      fake.NewSimpleClientset(pod),
+		namespace:      "default",
+		outputDir:      t.TempDir(),
+		httpClient:     server.Client(),
+		recordDisabled: true,
+		quiet:          true,
+	}
+	client.SetPortForwarder(&fakePortForwarder{port: port})
+	return client
+}
+
+func TestCollectCoverageFromPod_ServerInfo(t *testing.T) {
+	client := newServerInfoClient(t, `{"go_version":"go1.24.1","module_path":"example.com/app","vcs_revision":"abc123","vcs_time":"2025-01-09T10:00:00Z","build_time":"2025-01-09T10:05:00Z",`+
+		`"cover_enabled":true,"gocoverdir":"/tmp/coverage","started_at":"2025-01-10T14:30:00Z","uptime_seconds":12.5,"snapshots_served":3}`)
+	if _, err := client.CollectCoverageFromPod(context.Background(), "app-7d9f", "e2e", 9095); err != nil {
+		t.Fatalf("CollectCoverageFromPod failed: %v", err)
+	}
+
+	metadata, err := client.readPodMetadata("e2e")
+	if err != nil {
+		t.Fatalf("readPodMetadata failed: %v", err)
+	}
+	want := ServerInfo{GoVersion: "go1.24.1", ModulePath: "example.com/app", VCSRevision: "abc123", VCSTime: "2025-01-09T10:00:00Z",
+		BuildTime: "2025-01-09T10:05:00Z", CoverEnabled: true,
+		CoverDir: "/tmp/coverage", StartedAt: "2025-01-10T14:30:00Z", UptimeSeconds: 12.5, SnapshotsServed: 3}
+	if metadata.Server == nil || *metadata.Server != want {
+		t.Errorf("Expected the server info in metadata.json, got %+v", metadata.Server)
+	}
+}
+
+func TestCollectCoverageFromPod_OlderServerWithoutInfo(t *testing.T) {
+	client := newServerInfoClient(t, "")
+	if _, err := client.CollectCoverageFromPod(context.Background(), "app-7d9f", "e2e", 9095); err != nil {
+		t.Fatalf("Expected collection to succeed without server info, got %v", err)
+	}
+	if metadata, err := client.readPodMetadata("e2e"); err != nil || metadata.Server != nil {
+		t.Errorf("Expected metadata without server info, got %+v (%v)", metadata, err)
+	}
+}
+
+func TestGetServerInfo(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client := &CoverageClient{httpClient: server.Client()}
+	if _, err := client.GetServerInfo(context.Background(), server.URL); !errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected a server without the endpoint to match ErrCoverageNotEnabled, got %v", err)
+	}
+}
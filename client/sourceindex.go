@@ -0,0 +1,111 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sourceIndex caches the Go modules found below the source directory, so remapping many reports
+// walks the tree once per client instead of once per report
+type sourceIndex struct {
+	mu      sync.Mutex
+	dir     string // Absolute source directory the modules were found in, empty before the first walk
+	modules []goModule
+}
+
+// get returns the modules below dir, walking it only when the source directory changed
+func (s *sourceIndex) get(dir string) []goModule {
+	if s == nil {
+		return findGoModules(dir)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != dir {
+		s.dir, s.modules = dir, findGoModules(dir)
+	}
+	return s.modules
+}
+
+// findGoModules returns the modules below dir, longest module path first so nested modules
+// take precedence over the modules containing them. Directories are read in parallel; those the
+// go command ignores, JavaScript dependencies and paths excluded by .gitignore files are skipped.
+func findGoModules(dir string) []goModule {
+	w := &moduleWalker{sem: make(chan struct{}, runtime.GOMAXPROCS(0))}
+	w.wg.Add(1)
+	w.walk(dir, nil)
+	w.wg.Wait()
+
+	sort.SliceStable(w.modules, func(i, j int) bool {
+		if len(w.modules[i].path) != len(w.modules[j].path) {
+			return len(w.modules[i].path) > len(w.modules[j].path)
+		}
+		return w.modules[i].dir < w.modules[j].dir
+	})
+	return w.modules
+}
+
+// moduleWalker collects the go.mod files of a directory tree
+type moduleWalker struct {
+	sem     chan struct{} // Bounds the directories read concurrently
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	modules []goModule
+}
+
+// walk reads dir and its subdirectories, reading subdirectories on new goroutines while there
+// are free slots and on the caller's otherwise, so a deep tree can't deadlock the walk
+func (w *moduleWalker) walk(dir string, ignore *gitignore) {
+	defer w.wg.Done()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return // Skip unreadable directories
+	}
+	ignore = loadGitignore(ignore, dir)
+
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			if entry.Name() == "go.mod" && !ignore.ignored(p, false) {
+				if modulePath := readModulePath(dir); modulePath != "" {
+					w.mu.Lock()
+					w.modules = append(w.modules, goModule{path: modulePath, dir: dir})
+					w.mu.Unlock()
+				}
+			}
+			continue
+		}
+		if skipSourceDir(entry.Name()) || ignore.ignored(p, true) {
+			continue
+		}
+
+		w.wg.Add(1)
+		select {
+		case w.sem <- struct{}{}:
+			go func() {
+				defer func() { <-w.sem }()
+				w.walk(p, ignore)
+			}()
+		default:
+			w.walk(p, ignore)
+		}
+	}
+}
+
+// skipSourceDir reports whether a directory can't hold local packages: directories the go
+// command ignores (hidden, "_" prefixed, testdata), vendored code and JavaScript dependencies
+func skipSourceDir(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true
+	}
+	switch name {
+	case "vendor", "testdata", "node_modules", "bower_components":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeModules creates go.mod files declaring the given modules below dir
+func writeModules(dir string, modules map[string]string) {
+	for name, module := range modules {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("module "+module+"\n"), 0644)
+	}
+}
+
+func TestFindGoModules_SkippedDirs(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeModules(sourceDir, map[string]string{
+		"go.mod":                         "github.com/example/app",
+		"tools/go.mod":                   "github.com/example/app/tools",
+		"_archive/go.mod":                "github.com/example/old",
+		"web/node_modules/pkg/go.mod":    "example.com/npm",
+		"build/go.mod":                   "example.com/generated",
+		"deploy/keep/go.mod":             "github.com/example/app/deploy/keep",
+		"deploy/scratch/go.mod":          "example.com/scratch",
+		"services/a/b/c/d/go.mod":        "github.com/example/app/services/a/b/c/d",
+		"services/a/b/c/d/tmp/go.mod":    "example.com/tmp",
+		"services/a/b/c/d/tmp.go/go.mod": "github.com/example/app/services/a/b/c/d/tmp.go",
+	})
+	os.WriteFile(filepath.Join(sourceDir, ".gitignore"), []byte("# Generated\n/build/\ndeploy/*\n!deploy/keep/\ntmp/\n"), 0644)
+	os.WriteFile(filepath.Join(sourceDir, "services", "a", ".gitignore"), []byte("!tmp/\n**/d/tmp\n"), 0644)
+
+	got := make(map[string]string)
+	for _, m := range findGoModules(sourceDir) {
+		rel, _ := filepath.Rel(sourceDir, m.dir)
+		got[filepath.ToSlash(rel)] = m.path
+	}
+	want := map[string]string{
+		".":                       "github.com/example/app",
+		"tools":                   "github.com/example/app/tools",
+		"deploy/keep":             "github.com/example/app/deploy/keep",
+		"services/a/b/c/d":        "github.com/example/app/services/a/b/c/d",
+		"services/a/b/c/d/tmp.go": "github.com/example/app/services/a/b/c/d/tmp.go",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected modules %v, got %v", want, got)
+	}
+	for dir, module := range want {
+		if got[dir] != module {
+			t.Errorf("Expected module %s in %s, got %q", module, dir, got[dir])
+		}
+	}
+}
+
+func TestSourceIndex(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeModules(first, map[string]string{"go.mod": "github.com/example/first"})
+	writeModules(second, map[string]string{"go.mod": "github.com/example/second"})
+
+	index := &sourceIndex{}
+	if modules := index.get(first); len(modules) != 1 || modules[0].path != "github.com/example/first" {
+		t.Fatalf("Unexpected modules %+v", modules)
+	}
+
+	// Later reports reuse the index instead of walking the tree again
+	writeModules(first, map[string]string{"api/go.mod": "github.com/example/first/api"})
+	if modules := index.get(first); len(modules) != 1 {
+		t.Errorf("Expected cached modules, got %+v", modules)
+	}
+
+	if modules := index.get(second); len(modules) != 1 || modules[0].path != "github.com/example/second" {
+		t.Errorf("Expected a new walk after the source directory changed, got %+v", modules)
+	}
+
+	var unset *sourceIndex
+	if modules := unset.get(first); len(modules) != 2 {
+		t.Errorf("Expected clients without an index to walk every time, got %+v", modules)
+	}
+}
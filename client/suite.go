@@ -0,0 +1,178 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Suite tracks the collections of a test suite, one test directory per test, and produces the
+// suite's combined report:
+//
+//	suite := client.NewSuite("e2e")
+//	suite.Collect(ctx, coverageclient.CollectOptions{TestName: "login", LabelSelector: "app=my-app"})
+//	suite.Collect(ctx, coverageclient.CollectOptions{TestName: "checkout", LabelSelector: "app=my-app"})
+//	result, err := suite.Finalize(ctx)
+type Suite struct {
+	Name            string                       // Test directory of the combined coverage
+	Process         ProcessOptions               // Report generation for the tests and the suite; its TestName is ignored
+	SkipTestReports bool                         // Only generate the combined report (default: per-test reports too)
+	Push            *PushCoverageArtifactOptions // Push the combined coverage as one artifact (default: no push)
+
+	client *CoverageClient
+	mu     sync.Mutex
+	tests  []string
+}
+
+// SuiteResult describes the reports generated by Finalize
+type SuiteResult struct {
+	Name     string
+	Tests    []*ProcessResult // Per-test reports in collection order (empty with SkipTestReports)
+	Combined *ProcessResult   // Report of the merged coverage of all tests
+	Artifact *PushResult      // Pushed combined coverage (nil without Push)
+}
+
+// NewSuite starts a suite whose combined coverage is saved into the test directory name
+func (c *CoverageClient) NewSuite(name string) *Suite {
+	return &Suite{Name: name, client: c}
+}
+
+// Collect collects like CoverageClient.Collect and records the test for Finalize
+func (s *Suite) Collect(ctx context.Context, opts CollectOptions) (*CollectResult, error) {
+	if opts.TestName == s.Name {
+		return nil, fmt.Errorf("collect: test name %s is the suite's", opts.TestName)
+	}
+	result, err := s.client.Collect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.Track(result.TestName)
+	return result, nil
+}
+
+// Track records a test collected without Suite.Collect, e.g. with CollectCoverageFromPod
+func (s *Suite) Track(testName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if indexOf(s.tests, testName) < 0 {
+		s.tests = append(s.tests, testName)
+	}
+}
+
+// Tests returns the recorded tests in collection order
+func (s *Suite) Tests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.tests...)
+}
+
+// Finalize merges the coverage of all recorded tests into the suite's test directory, generates
+// the per-test and combined reports and pushes the combined coverage when configured. Calling it
+// again merges anew, e.g. after more collections.
+func (s *Suite) Finalize(ctx context.Context) (*SuiteResult, error) {
+	tests := s.Tests()
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("finalize suite %s: no collections", s.Name)
+	}
+
+	result := &SuiteResult{Name: s.Name}
+	if !s.SkipTestReports {
+		for _, test := range tests {
+			opts := s.Process
+			opts.TestName = test
+			processed, err := s.client.ProcessCoverage(ctx, opts)
+			if err != nil {
+				return nil, fmt.Errorf("process test %s: %w", test, err)
+			}
+			result.Tests = append(result.Tests, processed)
+		}
+	}
+
+	if err := s.client.mergeCoverage(ctx, s.Name, tests); err != nil {
+		return nil, err
+	}
+	opts := s.Process
+	opts.TestName = s.Name
+	combined, err := s.client.ProcessCoverage(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("process suite %s: %w", s.Name, err)
+	}
+	result.Combined = combined
+	s.client.logf("📊 Suite %s: %.1f%% coverage from %d test(s)\n", s.Name, combined.Total.Percent(), len(tests))
+
+	if s.Push != nil {
+		if result.Artifact, err = s.client.PushCoverageArtifactWithResult(ctx, s.Name, *s.Push); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// MergeCoverage merges the covdata collected for testNames into the test directory
+// outputTestName and generates its coverage.out, e.g. to aggregate per-spec collections into one
+// report. Like `go tool covdata merge`, counters of the same binary add up and different binaries
+// are combined. An earlier merge into outputTestName is replaced. Suite does the same for the
+// tests it recorded, with their reports and a push.
+func (c *CoverageClient) MergeCoverage(outputTestName string, testNames ...string) error {
+	if len(testNames) == 0 {
+		return fmt.Errorf("merge coverage: no tests")
+	}
+	if err := c.mergeCoverage(context.Background(), outputTestName, testNames); err != nil {
+		return err
+	}
+	return c.GenerateCoverageReport(outputTestName)
+}
+
+// mergeCoverage merges the covdata of tests into the test directory name with `go tool covdata
+// merge`, replacing what an earlier merge left there
+func (c *CoverageClient) mergeCoverage(ctx context.Context, name string, tests []string) (err error) {
+	_, step := startStep(ctx, "merge", attribute.String("test", name), attribute.Int("tests", len(tests)))
+	defer func() { step.end(err) }()
+	ctx, unlock, err := c.lockTestDir(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, test := range tests {
+		// The output directory is emptied first
+		if test == name {
+			return fmt.Errorf("merge coverage: test %s is also the merge output", test)
+		}
+		// -i is comma-separated, so test names can't contain commas
+		if strings.Contains(test, ",") {
+			return fmt.Errorf("merge coverage: test name %q contains a comma", test)
+		}
+		validation, err := ValidateCoverageData(c.testDir(test))
+		if err != nil {
+			return fmt.Errorf("merge coverage: %w", err)
+		}
+		if err := validation.Err(); err != nil {
+			return fmt.Errorf("merge coverage: %w", err)
+		}
+	}
+
+	if err := os.RemoveAll(c.testDir(name)); err != nil {
+		return fmt.Errorf("merge coverage: %w", err)
+	}
+	if _, err := c.createTestDir(name); err != nil {
+		return err
+	}
+
+	c.logf("🔀 Merging coverage of %d test(s) into %s\n", len(tests), name)
+	start := time.Now()
+	// Run in the output directory, with test directories as relative paths
+	cmd := exec.CommandContext(ctx, "go", "tool", "covdata", "merge", "-i="+strings.Join(tests, ","), "-o="+name)
+	cmd.Dir = c.outputDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("merge coverage: %w\nOutput: %s", err, output)
+	}
+	c.logf("✅ Coverage merged in %s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,162 @@
+package coverageclient
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const suiteTestProgram = `package main
+
+import "os"
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		println("login")
+		return
+	}
+	println("checkout")
+}
+`
+
+// runCoverProgram builds suiteTestProgram with -cover and runs it with arg, writing its covdata
+// into the test directory testName
+func runCoverProgram(t *testing.T, client *CoverageClient, testName, arg string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds an instrumented binary")
+	}
+	src := filepath.Join(client.outputDir, ".src")
+	app := "app"
+	if runtime.GOOS == "windows" {
+		app += ".exe"
+	}
+	if _, err := os.Stat(filepath.Join(src, app)); err != nil {
+		os.MkdirAll(src, 0755)
+		os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0644)
+		os.WriteFile(filepath.Join(src, "main.go"), []byte(suiteTestProgram), 0644)
+		build := exec.Command("go", "build", "-cover", "-o", app, ".")
+		build.Dir = src
+		if output, err := build.CombinedOutput(); err != nil {
+			t.Fatalf("go build -cover failed: %v\n%s", err, output)
+		}
+	}
+	testDir := filepath.Join(client.outputDir, testName)
+	os.MkdirAll(testDir, 0755)
+	run := exec.Command(filepath.Join(src, app), arg)
+	run.Env = append(os.Environ(), "GOCOVERDIR="+testDir)
+	if output, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("Running the instrumented binary failed: %v\n%s", err, output)
+	}
+}
+
+func TestSuite_Finalize(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	suite := client.NewSuite("e2e")
+	suite.Process.SkipHTML = true
+	runCoverProgram(t, client, "login", "login")
+	runCoverProgram(t, client, "checkout", "checkout")
+	suite.Track("login")
+	suite.Track("checkout")
+	suite.Track("login")
+
+	result, err := suite.Finalize(context.Background())
+	if err != nil {
+		t.Fatalf("Finalize failed: %v", err)
+	}
+	if got := suite.Tests(); len(got) != 2 || got[0] != "login" || got[1] != "checkout" {
+		t.Errorf("Expected the tests in collection order, got %v", got)
+	}
+	if len(result.Tests) != 2 || result.Tests[0].TestName != "login" || result.Tests[1].TestName != "checkout" {
+		t.Fatalf("Expected per-test reports, got %+v", result.Tests)
+	}
+	if result.Combined == nil || result.Combined.TestName != "e2e" {
+		t.Fatalf("Expected the combined report, got %+v", result.Combined)
+	}
+	for _, test := range result.Tests {
+		if result.Combined.Total.Covered <= test.Total.Covered {
+			t.Errorf("Expected the combined coverage %+v to exceed %s's %+v", result.Combined.Total, test.TestName, test.Total)
+		}
+	}
+	if result.Combined.Total.Covered != result.Combined.Total.Statements {
+		t.Errorf("Expected both branches covered, got %+v", result.Combined.Total)
+	}
+	if result.Artifact != nil {
+		t.Errorf("Expected no artifact without Push, got %+v", result.Artifact)
+	}
+
+	// Finalizing again replaces the merged covdata instead of adding to it
+	suite.SkipTestReports = true
+	again, err := suite.Finalize(context.Background())
+	if err != nil {
+		t.Fatalf("Second Finalize failed: %v", err)
+	}
+	if len(again.Tests) != 0 || again.Combined.Total != result.Combined.Total {
+		t.Errorf("Expected the same combined coverage only, got %+v and %+v", again.Tests, again.Combined.Total)
+	}
+}
+
+func TestSuite_FinalizeErrors(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	suite := client.NewSuite("e2e")
+	if _, err := suite.Finalize(context.Background()); err == nil || !strings.Contains(err.Error(), "no collections") {
+		t.Errorf("Expected an error without collections, got %v", err)
+	}
+
+	suite.Track("a,b")
+	suite.SkipTestReports = true
+	if _, err := suite.Finalize(context.Background()); err == nil || !strings.Contains(err.Error(), "contains a comma") {
+		t.Errorf("Expected a comma error, got %v", err)
+	}
+
+	suite = client.NewSuite("e2e")
+	suite.SkipTestReports = true
+	suite.Track("broken")
+	testDir := filepath.Join(client.outputDir, "broken")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "covcounters."+newBinaryHash+".1.1"), covcountersFile(newBinaryHash), 0644)
+	if _, err := suite.Finalize(context.Background()); err == nil || !strings.Contains(err.Error(), "no meta-data file") {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.outputDir, "e2e")); !os.IsNotExist(err) {
+		t.Errorf("Expected no suite directory after a failed validation, got %v", err)
+	}
+
+	if _, err := suite.Collect(context.Background(), CollectOptions{TestName: "e2e"}); err == nil {
+		t.Error("Expected collecting into the suite's directory to fail")
+	}
+}
+
+func TestMergeCoverage(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	runCoverProgram(t, client, "spec-login", "login")
+	runCoverProgram(t, client, "spec-checkout", "checkout")
+
+	if err := client.MergeCoverage("all", "spec-login", "spec-checkout"); err != nil {
+		t.Fatalf("MergeCoverage failed: %v", err)
+	}
+	profile, err := ParseProfileFile(filepath.Join(client.outputDir, "all", "coverage.out"))
+	if err != nil {
+		t.Fatalf("Expected the merged coverage.out: %v", err)
+	}
+	if total := profile.Total(); total.Covered != total.Statements {
+		t.Errorf("Expected both branches covered, got %+v", total)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(client.outputDir, "all", "covmeta.*")); len(matches) != 1 {
+		t.Errorf("Expected the merged covdata, got %v", matches)
+	}
+
+	if err := client.MergeCoverage("all"); err == nil || !strings.Contains(err.Error(), "no tests") {
+		t.Errorf("Expected an error without tests, got %v", err)
+	}
+	if err := client.MergeCoverage("spec-login", "spec-login", "spec-checkout"); err == nil || !strings.Contains(err.Error(), "merge output") {
+		t.Errorf("Expected an error merging a test into itself, got %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(client.outputDir, "spec-login", "covmeta.*")); len(matches) != 1 {
+		t.Errorf("Expected the input's covdata kept, got %v", matches)
+	}
+}
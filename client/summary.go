@@ -0,0 +1,69 @@
+package coverageclient
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TestSummary is the parsed coverage of a test, for decisions in code and custom reports
+type TestSummary struct {
+	TestName string         `json:"testName"`
+	Total    CoverageStats  `json:"total"`
+	Percent  float64        `json:"percent"`  // Total coverage in percent
+	Packages []SummaryEntry `json:"packages"` // Sorted by package
+	Files    []SummaryEntry `json:"files"`    // Sorted by file
+}
+
+// SummaryEntry is the coverage of one package or file of a TestSummary
+type SummaryEntry struct {
+	Name     string        `json:"name"`
+	Coverage CoverageStats `json:"coverage"`
+	Percent  float64       `json:"percent"`
+}
+
+// GetCoverageSummary parses the report of a test (the filtered one if it exists, like
+// CoverageSummary) into its total, package and file coverage. Packages are the directories of
+// the profile's files, i.e. import paths unless the report was remapped to local paths.
+func (c *CoverageClient) GetCoverageSummary(testName string) (*TestSummary, error) {
+	profile, err := c.LoadProfile(testName)
+	if err != nil {
+		return nil, fmt.Errorf("get coverage summary: %w", err)
+	}
+	total := profile.Total()
+	return &TestSummary{
+		TestName: testName,
+		Total:    total,
+		Percent:  total.Percent(),
+		Packages: summaryEntries(profile.Packages()),
+		Files:    summaryEntries(profile.Files()),
+	}, nil
+}
+
+// Package returns the coverage of a package of the summary
+func (s *TestSummary) Package(name string) (SummaryEntry, bool) {
+	return findSummaryEntry(s.Packages, name)
+}
+
+// File returns the coverage of a file of the summary
+func (s *TestSummary) File(name string) (SummaryEntry, bool) {
+	return findSummaryEntry(s.Files, name)
+}
+
+// summaryEntries turns stats by name into entries sorted by name
+func summaryEntries(stats map[string]CoverageStats) []SummaryEntry {
+	entries := make([]SummaryEntry, 0, len(stats))
+	for name, coverage := range stats {
+		entries = append(entries, SummaryEntry{Name: name, Coverage: coverage, Percent: coverage.Percent()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// findSummaryEntry looks up name in entries sorted by name
+func findSummaryEntry(entries []SummaryEntry, name string) (SummaryEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Name >= name })
+	if i < len(entries) && entries[i].Name == name {
+		return entries[i], true
+	}
+	return SummaryEntry{}, false
+}
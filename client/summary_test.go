@@ -0,0 +1,43 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetCoverageSummary(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	os.MkdirAll(filepath.Join(client.outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(client.outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+
+	summary, err := client.GetCoverageSummary("e2e")
+	if err != nil {
+		t.Fatalf("GetCoverageSummary failed: %v", err)
+	}
+	if summary.TestName != "e2e" || summary.Total != (CoverageStats{Statements: 9, Covered: 6}) || summary.Percent < 66.6 || summary.Percent > 66.7 {
+		t.Errorf("Unexpected total %+v (%.1f%%)", summary.Total, summary.Percent)
+	}
+	if len(summary.Packages) != 2 || summary.Packages[0].Name != "github.com/example/app" || summary.Packages[1].Name != "github.com/example/app/pkg/util" {
+		t.Fatalf("Expected the packages sorted, got %+v", summary.Packages)
+	}
+	if pkg, ok := summary.Package("github.com/example/app"); !ok || pkg.Coverage != (CoverageStats{Statements: 5, Covered: 2}) || pkg.Percent != 40 {
+		t.Errorf("Unexpected package coverage %+v", pkg)
+	}
+	if file, ok := summary.File("github.com/example/app/pkg/util/util.go"); !ok || file.Percent != 100 {
+		t.Errorf("Unexpected file coverage %+v", file)
+	}
+	if _, ok := summary.File("missing.go"); ok {
+		t.Error("Expected no entry for a missing file")
+	}
+
+	// The filtered report wins
+	os.WriteFile(filepath.Join(client.outputDir, "e2e", "coverage_filtered.out"), []byte("mode: atomic\ngithub.com/example/app/main.go:10.2,12.3 2 1\n"), 0644)
+	if summary, err := client.GetCoverageSummary("e2e"); err != nil || len(summary.Files) != 1 || summary.Percent != 100 {
+		t.Errorf("Expected the filtered report, got %+v (%v)", summary, err)
+	}
+
+	if _, err := client.GetCoverageSummary("missing"); err == nil {
+		t.Error("Expected an error for a missing report")
+	}
+}
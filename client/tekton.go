@@ -0,0 +1,92 @@
+package coverageclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const defaultTektonResultsDir = "/tekton/results"
+
+// TektonOptions configures WriteTektonResults. Result names must be declared in the Task;
+// empty names use the defaults, "-" skips a result.
+type TektonOptions struct {
+	ResultsDir     string // Directory holding $(results.<name>.path) files (default: /tekton/results)
+	ArtifactRef    string // Result for the artifact reference registry/repository:tag (default: COVERAGE_ARTIFACT_REF)
+	ArtifactURI    string // Result for the artifact URI without tag (default: COVERAGE_ARTIFACT_URI)
+	ArtifactDigest string // Result for the manifest digest (default: COVERAGE_ARTIFACT_DIGEST)
+	Coverage       string // Result for the total coverage percent, e.g. "78.3" (default: COVERAGE_PERCENT)
+	// ArtifactOutputs, when set, names an object result receiving {"uri", "digest"}. Tekton Chains
+	// picks up results named *ARTIFACT_OUTPUTS (and *ARTIFACT_URI/*ARTIFACT_DIGEST pairs) as
+	// provenance subjects, so pushed coverage can be attested like images.
+	ArtifactOutputs string
+}
+
+// WriteTektonResults writes the pushed artifact (may be nil) and the total coverage of a processed
+// test as Tekton Task results, so later pipeline tasks can consume them via $(tasks.<task>.results.*)
+func (c *CoverageClient) WriteTektonResults(testName string, push *PushResult, opts TektonOptions) error {
+	if opts.ResultsDir == "" {
+		opts.ResultsDir = defaultTektonResultsDir
+	}
+
+	results := make(map[string]string)
+	if push != nil {
+		uri := push.Reference
+		if i := lastTagSeparator(uri); i >= 0 {
+			uri = uri[:i]
+		}
+		results[resultName(opts.ArtifactRef, "COVERAGE_ARTIFACT_REF")] = push.Reference
+		results[resultName(opts.ArtifactURI, "COVERAGE_ARTIFACT_URI")] = uri
+		results[resultName(opts.ArtifactDigest, "COVERAGE_ARTIFACT_DIGEST")] = push.Digest
+		if opts.ArtifactOutputs != "" {
+			outputs, err := json.Marshal(map[string]string{"uri": uri, "digest": push.Digest})
+			if err != nil {
+				return fmt.Errorf("marshal artifact outputs: %w", err)
+			}
+			results[opts.ArtifactOutputs] = string(outputs)
+		}
+	}
+
+	if name := resultName(opts.Coverage, "COVERAGE_PERCENT"); name != "-" {
+		profile, err := c.LoadProfile(testName)
+		if err != nil {
+			c.logf("⚠️  Skipping coverage result, report not available: %v\n", err)
+		} else {
+			results[name] = strconv.FormatFloat(profile.Total().Percent(), 'f', 1, 64)
+		}
+	}
+	delete(results, "-")
+
+	for name, value := range results {
+		path := filepath.Join(opts.ResultsDir, name)
+		if err := os.WriteFile(path, []byte(value), 0644); err != nil {
+			return fmt.Errorf("write Tekton result %s: %w", name, err)
+		}
+		c.logf("📝 Tekton result %s: %s\n", name, value)
+	}
+	return nil
+}
+
+// resultName returns name, or def when name is empty
+func resultName(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+// lastTagSeparator returns the index of the ':' separating the tag in an image reference,
+// ignoring a registry port (-1 if there is no tag)
+func lastTagSeparator(ref string) int {
+	for i := len(ref) - 1; i >= 0; i-- {
+		switch ref[i] {
+		case ':':
+			return i
+		case '/':
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,67 @@
+package coverageclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTektonResults(t *testing.T) {
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	resultsDir := t.TempDir()
+
+	client := &CoverageClient{outputDir: outputDir}
+	err := client.WriteTektonResults("e2e", &PushResult{
+		Reference: "localhost:5000/org/coverage:e2e-1",
+		Digest:    "sha256:abc",
+	}, TektonOptions{ResultsDir: resultsDir, ArtifactURI: "-", ArtifactOutputs: "COVERAGE_ARTIFACT_OUTPUTS"})
+	if err != nil {
+		t.Fatalf("WriteTektonResults failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"COVERAGE_ARTIFACT_REF":     "localhost:5000/org/coverage:e2e-1",
+		"COVERAGE_ARTIFACT_DIGEST":  "sha256:abc",
+		"COVERAGE_PERCENT":          "66.7",
+		"COVERAGE_ARTIFACT_OUTPUTS": `{"digest":"sha256:abc","uri":"localhost:5000/org/coverage"}`,
+	}
+	for name, want := range expected {
+		data, err := os.ReadFile(filepath.Join(resultsDir, name))
+		if err != nil {
+			t.Errorf("Result %s not written: %v", name, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("Result %s: expected %q, got %q", name, want, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(resultsDir, "COVERAGE_ARTIFACT_URI")); err == nil {
+		t.Error("Expected skipped result not to be written")
+	}
+}
+
+func TestWriteTektonResults_WithoutPushOrReport(t *testing.T) {
+	resultsDir := t.TempDir()
+	client := &CoverageClient{outputDir: t.TempDir()}
+
+	if err := client.WriteTektonResults("missing", nil, TektonOptions{ResultsDir: resultsDir}); err != nil {
+		t.Fatalf("Expected missing report to be skipped, got %v", err)
+	}
+	if entries, _ := os.ReadDir(resultsDir); len(entries) != 0 {
+		t.Errorf("Expected no results, got %d", len(entries))
+	}
+}
+
+func TestLastTagSeparator(t *testing.T) {
+	for ref, want := range map[string]int{
+		"quay.io/org/repo:tag":        16,
+		"localhost:5000/org/repo":     -1,
+		"localhost:5000/org/repo:tag": 23,
+	} {
+		if got := lastTagSeparator(ref); got != want {
+			t.Errorf("lastTagSeparator(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/psturc/go-coverage-http/client"
+
+// Spans and metrics go to the global OpenTelemetry providers, which are no-ops until the
+// application (or SetupOpenTelemetry) installs real ones
+var (
+	tracer        = otel.Tracer(instrumentationName)
+	meter         = otel.Meter(instrumentationName)
+	stepDuration  metric.Float64Histogram
+	bytesReceived metric.Int64Counter
+)
+
+func init() {
+	stepDuration, _ = meter.Float64Histogram("coverage.step.duration",
+		metric.WithDescription("Duration of coverage collection, report generation and push steps"),
+		metric.WithUnit("s"))
+	bytesReceived, _ = meter.Int64Counter("coverage.received",
+		metric.WithDescription("Coverage data received from instrumented applications"),
+		metric.WithUnit("By"))
+}
+
+// telemetryStep is an instrumented step, see startStep
+type telemetryStep struct {
+	span  trace.Span
+	name  string
+	start time.Time
+	attrs []attribute.KeyValue
+}
+
+// startStep starts a span for a step of the coverage workflow; end records its outcome and duration
+func startStep(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *telemetryStep) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, &telemetryStep{span: span, name: name, start: time.Now(), attrs: attrs}
+}
+
+// end finishes the step, marking it failed when err is not nil
+func (s *telemetryStep) end(err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+
+	attrs := append([]attribute.KeyValue{attribute.String("step", s.name), attribute.String("outcome", outcome)}, s.attrs...)
+	stepDuration.Record(context.Background(), time.Since(s.start).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// SetupOpenTelemetry installs a global tracer provider exporting spans via OTLP/HTTP when an OTLP
+// endpoint is configured (OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT); the
+// exporter reads the standard OTEL_* environment variables. Metrics are recorded on the global
+// meter provider set up by the application. Call the returned function before exiting to flush
+// pending spans. Without an endpoint nothing is installed.
+func SetupOpenTelemetry(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName())))
+	if err != nil {
+		return noop, fmt.Errorf("create telemetry resource: %w", err)
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("create OTLP trace exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// serviceName returns $OTEL_SERVICE_NAME, defaulting to go-coverage-http
+func serviceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "go-coverage-http"
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
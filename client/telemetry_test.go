@@ -0,0 +1,66 @@
+package coverageclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTelemetry(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{outputDir: t.TempDir(), httpClient: server.Client()}
+	if err := client.CollectCoverageFromURL(server.URL, "e2e"); err == nil {
+		t.Fatal("Expected collection to fail")
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 || ended[0].Name() != "collect-http" || ended[0].Status().Code != codes.Error {
+		t.Fatalf("Expected a failed collect-http span, got %+v", ended)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect metrics failed: %v", err)
+	}
+	found := false
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "coverage.step.duration" {
+				found = len(h.DataPoints) == 1 && h.DataPoints[0].Count == 1
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected one coverage.step.duration measurement, got %+v", rm.ScopeMetrics)
+	}
+}
+
+func TestSetupOpenTelemetry_NoEndpoint(t *testing.T) {
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"} {
+		t.Setenv(name, "")
+	}
+	shutdown, err := SetupOpenTelemetry(context.Background())
+	if err != nil {
+		t.Fatalf("SetupOpenTelemetry failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+}
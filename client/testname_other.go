@@ -0,0 +1,8 @@
+//go:build !windows
+
+package coverageclient
+
+// checkTestName accepts every test name, any file name is a valid directory name
+func checkTestName(testName string) error {
+	return nil
+}
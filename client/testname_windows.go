@@ -0,0 +1,19 @@
+package coverageclient
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// checkTestName fails for test names Windows can't create a directory for: reserved characters
+// and device names (CON, NUL, ...), which would otherwise fail collections with cryptic errors
+func checkTestName(testName string) error {
+	if strings.ContainsAny(testName, `<>:"|?*`) {
+		return fmt.Errorf("test name %q contains characters reserved on Windows (<>:\"|?*)", testName)
+	}
+	if !filepath.IsLocal(testName) {
+		return fmt.Errorf("test name %q is not a valid directory name on Windows", testName)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package coverageclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckTestName_Windows(t *testing.T) {
+	for _, name := range []string{"e2e", "e2e-login", `suite\login`, "suite/login"} {
+		if err := checkTestName(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"e2e: login", "what?", "NUL", `C:\e2e`, "../e2e"} {
+		if err := checkTestName(name); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	if _, err := client.createTestDir("e2e: login"); err == nil || !strings.Contains(err.Error(), "reserved on Windows") {
+		t.Errorf("Expected a reserved character error, got %v", err)
+	}
+}
@@ -0,0 +1,437 @@
+package coverageclient
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Native covdata conversion: reads covmeta and covcounters files like `go tool covdata textfmt`
+// and `pkglist` do, for runners without a Go toolchain. The formats are those of internal/coverage
+// (decodemeta, decodecounter) in the Go distribution; the output matches cformat's.
+
+const (
+	covMetaPackageHeaderSize = 44 // Length, PkgName, PkgPath, ModulePath, MetaHash, unused, padding, NumFiles, NumFuncs
+	covCounterFlavorRaw      = 1
+	covModeSet               = 1
+)
+
+// covModeNames are the profile modes of the counter modes
+var covModeNames = map[byte]string{1: "set", 2: "count", 3: "atomic", 4: "regonly", 5: "testmain"}
+
+// SetNativeCovdata makes report generation read covdata files itself instead of running
+// `go tool covdata`, e.g. in CI images that only have the test binary. The HTML report and suite
+// merges still need the Go toolchain. Default: native only when `go` isn't on PATH.
+func (c *CoverageClient) SetNativeCovdata(enabled bool) {
+	c.nativeCovdata = enabled
+}
+
+// useNativeCovdata reports whether covdata files are converted without `go tool covdata`
+func (c *CoverageClient) useNativeCovdata() bool {
+	if c.nativeCovdata {
+		return true
+	}
+	_, err := exec.LookPath("go")
+	return err != nil
+}
+
+// covMetaFile is a decoded covmeta file
+type covMetaFile struct {
+	mode     byte
+	packages []covMetaPackage
+}
+
+type covMetaPackage struct {
+	path  string
+	funcs []covMetaFunc
+}
+
+type covMetaFunc struct {
+	name  string
+	file  string
+	lit   bool
+	units []covUnit
+}
+
+// covUnit is a basic block of a function, a line of the text profile
+type covUnit struct {
+	stLine, stCol, enLine, enCol, stmts uint32
+}
+
+// covFuncKey identifies a function's counters in a counters file
+type covFuncKey struct {
+	pkg, fn uint32
+}
+
+// covProfileUnit identifies a unit across binaries, like cformat does
+type covProfileUnit struct {
+	file, fn string
+	lit      bool
+	covUnit
+}
+
+// covReader reads the little-endian and ULEB128 values of covdata files, remembering the first error
+type covReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *covReader) fail(format string, args ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (r *covReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.off+n > len(r.data) {
+		r.fail("truncated at offset %d", r.off)
+		return make([]byte, max(n, 0))
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *covReader) uint32() uint32 { return binary.LittleEndian.Uint32(r.bytes(4)) }
+func (r *covReader) uint64() uint64 { return binary.LittleEndian.Uint64(r.bytes(8)) }
+
+func (r *covReader) uleb128() uint64 {
+	var value uint64
+	for shift := uint(0); ; shift += 7 {
+		b := r.bytes(1)[0]
+		if shift < 64 {
+			value |= uint64(b&0x7f) << shift
+		}
+		if b&0x80 == 0 || r.err != nil {
+			return value
+		}
+	}
+}
+
+// strings reads a string table: the number of strings, then each string's length and bytes
+func (r *covReader) strings() []string {
+	n := r.uleb128()
+	if n > uint64(len(r.data)) {
+		r.fail("corrupt string table")
+		return nil
+	}
+	table := make([]string, 0, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		table = append(table, string(r.bytes(int(r.uleb128()))))
+	}
+	return table
+}
+
+// lookup returns a string table entry, failing on out-of-range indexes
+func (r *covReader) lookup(table []string, index uint64) string {
+	if index >= uint64(len(table)) {
+		r.fail("corrupt string table reference %d", index)
+		return ""
+	}
+	return table[index]
+}
+
+// readCovMetaFile decodes a covmeta file
+func readCovMetaFile(path string) (*covMetaFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &covReader{data: data}
+	if !bytes.Equal(r.bytes(4), covMetaMagic[:]) {
+		return nil, fmt.Errorf("%s: not a meta-data file", filepath.Base(path))
+	}
+	if version := r.uint32(); version > covdataVersion {
+		return nil, fmt.Errorf("%s: unsupported meta-data version %d", filepath.Base(path), version)
+	}
+	r.uint64() // Total length
+	entries := r.uint64()
+	r.bytes(16 + 4 + 4) // Hash, string table offset and length
+	meta := &covMetaFile{mode: r.bytes(1)[0]}
+	r.bytes(7) // Granularity, padding
+	if r.err != nil || entries > uint64(len(data)) {
+		return nil, fmt.Errorf("%s: corrupt header", filepath.Base(path))
+	}
+
+	offsets := make([]uint64, entries)
+	for i := range offsets {
+		offsets[i] = r.uint64()
+	}
+	for i := range offsets {
+		length := r.uint64()
+		if r.err != nil || offsets[i] > uint64(len(data)) || length > uint64(len(data))-offsets[i] {
+			return nil, fmt.Errorf("%s: corrupt package table", filepath.Base(path))
+		}
+		pkg, err := readCovMetaPackage(data[offsets[i] : offsets[i]+length])
+		if err != nil {
+			return nil, fmt.Errorf("%s: package %d: %w", filepath.Base(path), i, err)
+		}
+		meta.packages = append(meta.packages, pkg)
+	}
+	return meta, nil
+}
+
+// readCovMetaPackage decodes the meta-data blob the compiler emitted for a package
+func readCovMetaPackage(data []byte) (covMetaPackage, error) {
+	r := &covReader{data: data}
+	r.uint32() // Length
+	r.uint32() // Package name
+	pathIndex := r.uint32()
+	r.bytes(4 + 16 + 4 + 4) // Module path, hash, unused and padding, number of files
+	numFuncs := r.uint32()
+	if r.err != nil || uint64(numFuncs)*4 > uint64(len(data)) {
+		return covMetaPackage{}, fmt.Errorf("corrupt header")
+	}
+	funcOffsets := make([]uint32, numFuncs)
+	for i := range funcOffsets {
+		funcOffsets[i] = r.uint32()
+	}
+	r.off = covMetaPackageHeaderSize + 4*len(funcOffsets)
+	table := r.strings()
+	pkg := covMetaPackage{path: r.lookup(table, uint64(pathIndex)), funcs: make([]covMetaFunc, numFuncs)}
+
+	for i, offset := range funcOffsets {
+		r.off = int(offset)
+		numUnits := r.uleb128()
+		if numUnits > uint64(len(data)) {
+			r.fail("corrupt function %d", i)
+			break
+		}
+		fn := covMetaFunc{name: r.lookup(table, r.uleb128()), file: r.lookup(table, r.uleb128()), units: make([]covUnit, numUnits)}
+		for j := range fn.units {
+			fn.units[j] = covUnit{
+				stLine: uint32(r.uleb128()), stCol: uint32(r.uleb128()),
+				enLine: uint32(r.uleb128()), enCol: uint32(r.uleb128()),
+				stmts: uint32(r.uleb128()),
+			}
+		}
+		fn.lit = r.uleb128() != 0
+		pkg.funcs[i] = fn
+	}
+	return pkg, r.err
+}
+
+// readCovCountersFile decodes a covcounters file and adds its counters to counters, merging them
+// following the counter mode
+func readCovCountersFile(path string, mode byte, counters map[covFuncKey][]uint32) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < covCounterHeaderSize+covCounterFooterSize || !bytes.Equal(data[0:4], covCounterMagic[:]) {
+		return fmt.Errorf("%s: not a counters file", filepath.Base(path))
+	}
+	flavor, bigEndian := data[24], data[25] != 0
+	segments := binary.LittleEndian.Uint32(data[len(data)-covCounterFooterSize+8:])
+
+	r := &covReader{data: data, off: covCounterHeaderSize}
+	value := func() uint32 {
+		switch {
+		case flavor != covCounterFlavorRaw:
+			return uint32(r.uleb128())
+		case bigEndian:
+			return binary.BigEndian.Uint32(r.bytes(4))
+		}
+		return r.uint32()
+	}
+	for segment := uint32(0); segment < segments && r.err == nil; segment++ {
+		if segment > 0 {
+			r.bytes(covCounterFooterSize) // Footer of the previous segment
+		}
+		entries := r.uint64()
+		strTabLen, argsLen := r.uint32(), r.uint32()
+		r.bytes(int(strTabLen) + int(argsLen)) // Strings and os.Args of the process
+		r.bytes((4 - r.off%4) % 4)
+
+		for i := uint64(0); i < entries && r.err == nil; i++ {
+			n := value()
+			key := covFuncKey{pkg: value(), fn: value()}
+			if uint64(n) > uint64(len(data)) {
+				r.fail("corrupt counters")
+				break
+			}
+			merged := counters[key]
+			if merged == nil {
+				merged = make([]uint32, n)
+				counters[key] = merged
+			}
+			for j := uint32(0); j < n; j++ {
+				if v := value(); int(j) < len(merged) {
+					merged[j] = mergeCounter(mode, merged[j], v)
+				}
+			}
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), r.err)
+	}
+	return nil
+}
+
+// mergeCounter combines two counter values: set counters are flags, the others add up (saturating)
+func mergeCounter(mode byte, a, b uint32) uint32 {
+	if mode == covModeSet {
+		if a != 0 || b != 0 {
+			return 1
+		}
+		return 0
+	}
+	if sum := uint64(a) + uint64(b); sum <= math.MaxUint32 {
+		return uint32(sum)
+	}
+	return math.MaxUint32
+}
+
+// readCovdataDir decodes the covdata files of all binaries in dir
+func readCovdataDir(dir string) ([]CovdataGroup, []*covMetaFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read coverage directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	var groups []CovdataGroup
+	var metas []*covMetaFile
+	for _, g := range GroupCovdataFiles(names) {
+		if g.Meta == "" {
+			continue // Counters without meta-data are skipped, as by go tool covdata
+		}
+		meta, err := readCovMetaFile(filepath.Join(dir, g.Meta))
+		if err != nil {
+			return nil, nil, err
+		}
+		groups = append(groups, g)
+		metas = append(metas, meta)
+	}
+	if len(metas) == 0 {
+		return nil, nil, fmt.Errorf("no coverage meta-data files in %s", dir)
+	}
+	return groups, metas, nil
+}
+
+// listCovdataPackagesNative returns the import paths of the packages in the meta-data in dir,
+// like `go tool covdata pkglist`
+func listCovdataPackagesNative(dir string) ([]string, error) {
+	_, metas, err := readCovdataDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var packages []string
+	for _, meta := range metas {
+		for _, pkg := range meta.packages {
+			packages = append(packages, pkg.path)
+		}
+	}
+	sort.Strings(packages)
+	return slices.Compact(packages), nil
+}
+
+// writeTextProfileFile writes the text profile of the covdata files in dir to path, see
+// writeTextProfile
+func writeTextProfileFile(dir string, packages []string, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeTextProfile(dir, packages, f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// writeTextProfile converts the covdata files in dir into a text profile like
+// `go tool covdata textfmt`, restricted to the given import paths unless packages is empty
+func writeTextProfile(dir string, packages []string, w io.Writer) error {
+	groups, metas, err := readCovdataDir(dir)
+	if err != nil {
+		return err
+	}
+
+	mode := metas[0].mode
+	units := make(map[string]map[covProfileUnit]uint32) // By import path
+	for i, g := range groups {
+		meta := metas[i]
+		if meta.mode != mode {
+			return fmt.Errorf("counter mode clash while reading meta-data %s: %s and %s", g.Meta, covModeNames[mode], covModeNames[meta.mode])
+		}
+		counters := make(map[covFuncKey][]uint32)
+		for _, name := range g.Counters {
+			if err := readCovCountersFile(filepath.Join(dir, name), mode, counters); err != nil {
+				return err
+			}
+		}
+		for p, pkg := range meta.packages {
+			if len(packages) > 0 && !slices.Contains(packages, pkg.path) {
+				continue
+			}
+			if units[pkg.path] == nil {
+				units[pkg.path] = make(map[covProfileUnit]uint32)
+			}
+			for f, fn := range pkg.funcs {
+				values := counters[covFuncKey{pkg: uint32(p), fn: uint32(f)}]
+				for u, unit := range fn.units {
+					var count uint32
+					if u < len(values) {
+						count = values[u]
+					}
+					key := covProfileUnit{file: fn.file, fn: fn.name, lit: fn.lit, covUnit: unit}
+					units[pkg.path][key] = mergeCounter(mode, units[pkg.path][key], count)
+				}
+			}
+		}
+	}
+
+	name, ok := covModeNames[mode]
+	if !ok {
+		return errors.New("unknown counter mode in meta-data")
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "mode: %s\n", name)
+	paths := make([]string, 0, len(units))
+	for path := range units {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		keys := make([]covProfileUnit, 0, len(units[path]))
+		for key := range units[path] {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, compareProfileUnits)
+		for _, key := range keys {
+			fmt.Fprintf(out, "%s:%d.%d,%d.%d %d %d\n", key.file, key.stLine, key.stCol, key.enLine, key.enCol, key.stmts, units[path][key])
+		}
+	}
+	return out.Flush()
+}
+
+// compareProfileUnits orders units by file and position, as cformat does
+func compareProfileUnits(a, b covProfileUnit) int {
+	return cmp.Or(
+		strings.Compare(a.file, b.file),
+		cmp.Compare(a.stLine, b.stLine),
+		cmp.Compare(a.enLine, b.enLine),
+		cmp.Compare(a.stCol, b.stCol),
+		cmp.Compare(a.enCol, b.enCol),
+		cmp.Compare(a.stmts, b.stmts),
+		strings.Compare(a.fn, b.fn),
+	)
+}
@@ -0,0 +1,117 @@
+package coverageclient
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// runCountProgram builds suiteTestProgram with -covermode=count and runs it once per arg, writing
+// the covdata into dir
+func runCountProgram(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds an instrumented binary")
+	}
+	src := t.TempDir()
+	app := "app"
+	if runtime.GOOS == "windows" {
+		app += ".exe"
+	}
+	os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0644)
+	os.WriteFile(filepath.Join(src, "main.go"), []byte(suiteTestProgram), 0644)
+	build := exec.Command("go", "build", "-cover", "-covermode=count", "-o", app, ".")
+	build.Dir = src
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build -cover failed: %v\n%s", err, output)
+	}
+	os.MkdirAll(dir, 0755)
+	for _, arg := range args {
+		run := exec.Command(filepath.Join(src, app), arg)
+		run.Env = append(os.Environ(), "GOCOVERDIR="+dir)
+		if output, err := run.CombinedOutput(); err != nil {
+			t.Fatalf("Running the instrumented binary failed: %v\n%s", err, output)
+		}
+	}
+}
+
+// goToolTextfmt returns the profile `go tool covdata textfmt` writes for dir
+func goToolTextfmt(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "want.out")
+	cmd := exec.Command("go", append([]string{"tool", "covdata", "textfmt", "-i=.", "-o=" + out}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go tool covdata textfmt failed: %v\n%s", err, output)
+	}
+	want, _ := os.ReadFile(out)
+	return string(want)
+}
+
+func TestWriteTextProfile_MatchesGoTool(t *testing.T) {
+	// Set mode, several processes of one binary
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	runCoverProgram(t, client, "set", "login")
+	runCoverProgram(t, client, "set", "checkout")
+	// Count mode, counters adding up across processes
+	countDir := filepath.Join(client.outputDir, "count")
+	runCountProgram(t, countDir, "login", "login", "checkout")
+
+	for _, dir := range []string{filepath.Join(client.outputDir, "set"), countDir} {
+		var got bytes.Buffer
+		if err := writeTextProfile(dir, nil, &got); err != nil {
+			t.Fatalf("writeTextProfile(%s) failed: %v", dir, err)
+		}
+		if want := goToolTextfmt(t, dir); got.String() != want {
+			t.Errorf("Profile of %s differs from go tool covdata:\n%s\nwant:\n%s", filepath.Base(dir), got.String(), want)
+		}
+	}
+	var got bytes.Buffer
+	writeTextProfile(countDir, nil, &got)
+	if !strings.Contains(got.String(), "mode: count") || !strings.Contains(got.String(), " 3\n") {
+		t.Errorf("Expected counts summed over the three runs, got:\n%s", got.String())
+	}
+
+	// Package selection and listing
+	packages, err := listCovdataPackagesNative(countDir)
+	if err != nil || len(packages) != 1 || packages[0] != "example.com/app" {
+		t.Errorf("Expected the app's package, got %v (%v)", packages, err)
+	}
+	got.Reset()
+	if err := writeTextProfile(countDir, []string{"example.com/other"}, &got); err != nil || got.String() != "mode: count\n" {
+		t.Errorf("Expected an empty profile for an unselected package, got %q (%v)", got.String(), err)
+	}
+}
+
+func TestGenerateCoverageReport_Native(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	runCoverProgram(t, client, "e2e", "login")
+	client.SetNativeCovdata(true)
+	if err := client.GenerateCoverageReport("e2e"); err != nil {
+		t.Fatalf("GenerateCoverageReport failed: %v", err)
+	}
+	got, _ := os.ReadFile(filepath.Join(client.outputDir, "e2e", "coverage.out"))
+	if want := goToolTextfmt(t, filepath.Join(client.outputDir, "e2e")); string(got) != want {
+		t.Errorf("Expected the go tool's profile, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextProfile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeTextProfile(dir, nil, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "no coverage meta-data") {
+		t.Errorf("Expected an error without meta-data, got %v", err)
+	}
+
+	// Truncated meta-data fails instead of panicking
+	os.WriteFile(filepath.Join(dir, "covmeta."+newBinaryHash), covmetaFile(newBinaryHash)[:60], 0644)
+	if err := writeTextProfile(dir, nil, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for truncated meta-data")
+	}
+	if _, err := listCovdataPackagesNative(dir); err == nil {
+		t.Error("Expected pkglist to fail for truncated meta-data")
+	}
+}
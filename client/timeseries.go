@@ -0,0 +1,276 @@
+package coverageclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// totalPackage is the package name of the test-wide data point
+const totalPackage = "total"
+
+// CoveragePoint is the coverage of one package (or the total) of a run
+type CoveragePoint struct {
+	Time       time.Time         `json:"time"`
+	Test       string            `json:"test"`
+	Package    string            `json:"package"` // "total" for the whole test
+	Statements int               `json:"statements"`
+	Covered    int               `json:"covered"`
+	Percent    float64           `json:"percent"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// TimeSeriesOptions configures the data points of a run
+type TimeSeriesOptions struct {
+	Time time.Time         // Timestamp of the run (default: now)
+	Tags map[string]string // Extra tags, e.g. environment (branch and commit are added when detected from CI)
+}
+
+// CoveragePoints returns the total and per-package coverage of a processed test as data points
+func (c *CoverageClient) CoveragePoints(testName string, opts TimeSeriesOptions) ([]CoveragePoint, error) {
+	profile, err := c.LoadProfile(testName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+	tags := map[string]string{}
+	if ci := DetectCI(); ci != nil {
+		if ci.Branch != "" {
+			tags["branch"] = ci.Branch
+		}
+		if ci.Commit != "" {
+			tags["commit"] = ci.Commit
+		}
+	}
+	for k, v := range opts.Tags {
+		tags[k] = v
+	}
+
+	point := func(pkg string, stats CoverageStats) CoveragePoint {
+		return CoveragePoint{
+			Time: opts.Time.UTC(), Test: testName, Package: pkg, Tags: tags,
+			Statements: stats.Statements, Covered: stats.Covered, Percent: stats.Percent(),
+		}
+	}
+	points := []CoveragePoint{point(totalPackage, profile.Total())}
+	packages := profile.Packages()
+	names := make([]string, 0, len(packages))
+	for pkg := range packages {
+		names = append(names, pkg)
+	}
+	sort.Strings(names)
+	for _, pkg := range names {
+		points = append(points, point(pkg, packages[pkg]))
+	}
+	return points, nil
+}
+
+// AppendCoverageTimeSeries appends the coverage of a processed test to a time-series file for
+// long-term dashboards (e.g. Grafana's CSV or JSON data sources). Files ending in .csv get one
+// row per package; any other file gets one JSON object per line.
+func (c *CoverageClient) AppendCoverageTimeSeries(testName, path string, opts TimeSeriesOptions) error {
+	points, err := c.CoveragePoints(testName, opts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create time-series directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open time-series file: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("stat time-series file: %w", err)
+		}
+		err = writeCoverageCSV(f, points, info.Size() == 0)
+		if err != nil {
+			return fmt.Errorf("write time-series file: %w", err)
+		}
+	} else {
+		encoder := json.NewEncoder(f)
+		for _, p := range points {
+			if err := encoder.Encode(p); err != nil {
+				return fmt.Errorf("write time-series file: %w", err)
+			}
+		}
+	}
+
+	c.logf("📈 Appended %d coverage data point(s) to %s\n", len(points), path)
+	return nil
+}
+
+// writeCoverageCSV writes points as CSV rows; tags are joined as key=value pairs
+func writeCoverageCSV(w io.Writer, points []CoveragePoint, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		cw.Write([]string{"time", "test", "package", "statements", "covered", "percent", "tags"})
+	}
+	for _, p := range points {
+		cw.Write([]string{
+			p.Time.Format(time.RFC3339), p.Test, p.Package,
+			strconv.Itoa(p.Statements), strconv.Itoa(p.Covered), strconv.FormatFloat(p.Percent, 'f', 2, 64),
+			joinTags(p.Tags, ";", func(s string) string { return s }),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// InfluxDBOptions configures SendToInfluxDB
+type InfluxDBOptions struct {
+	TimeSeriesOptions
+	URL         string // InfluxDB URL, e.g. http://influxdb:8086
+	Token       string // API token (default: $INFLUX_TOKEN)
+	Org         string // Organization (InfluxDB 2.x, optional with an org-scoped token)
+	Bucket      string // Bucket, or "database/retention-policy" for InfluxDB 1.8+
+	Measurement string // Measurement name (default: coverage)
+}
+
+// SendToInfluxDB writes the coverage of a processed test to InfluxDB in line protocol, one point
+// per package tagged with test and package
+func (c *CoverageClient) SendToInfluxDB(ctx context.Context, testName string, opts InfluxDBOptions) error {
+	if opts.Measurement == "" {
+		opts.Measurement = "coverage"
+	}
+	if opts.Token == "" {
+		opts.Token = os.Getenv("INFLUX_TOKEN")
+	}
+	points, err := c.CoveragePoints(testName, opts.TimeSeriesOptions)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"bucket": {opts.Bucket}, "precision": {"s"}}
+	if opts.Org != "" {
+		query.Set("org", opts.Org)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(opts.URL, "/")+"/api/v2/write?"+query.Encode(), bytes.NewReader(influxLines(opts.Measurement, points)))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Token "+opts.Token)
+	}
+
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("write to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("InfluxDB returned %d: %s", resp.StatusCode, body)
+	}
+
+	c.logf("📈 Sent %d coverage data point(s) to InfluxDB\n", len(points))
+	return nil
+}
+
+// influxLines formats points in InfluxDB line protocol
+func influxLines(measurement string, points []CoveragePoint) []byte {
+	var b bytes.Buffer
+	for _, p := range points {
+		tags := map[string]string{"test": p.Test, "package": p.Package}
+		for k, v := range p.Tags {
+			tags[k] = v
+		}
+		fmt.Fprintf(&b, "%s,%s percent=%s,statements=%di,covered=%di %d\n",
+			influxEscape(measurement), joinTags(tags, ",", influxEscape),
+			strconv.FormatFloat(p.Percent, 'f', -1, 64), p.Statements, p.Covered, p.Time.Unix())
+	}
+	return b.Bytes()
+}
+
+var influxReplacer = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxEscape escapes commas, equal signs and spaces in line protocol keys and tag values
+func influxEscape(s string) string {
+	return influxReplacer.Replace(s)
+}
+
+// joinTags joins tags as key=value pairs sorted by key, skipping empty values
+func joinTags(tags map[string]string, sep string, escape func(string) string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = escape(k) + "=" + escape(tags[k])
+	}
+	return strings.Join(pairs, sep)
+}
+
+// GraphiteOptions configures SendToGraphite
+type GraphiteOptions struct {
+	TimeSeriesOptions
+	Address string // Carbon plaintext receiver, e.g. graphite:2003
+	Prefix  string // Metric path prefix (default: coverage)
+}
+
+// SendToGraphite sends the coverage percent of a processed test to Graphite's plaintext protocol
+// as <prefix>.<test>.<package>.percent (plus .statements and .covered), with the test and package
+// names flattened into single path nodes
+func (c *CoverageClient) SendToGraphite(ctx context.Context, testName string, opts GraphiteOptions) error {
+	if opts.Prefix == "" {
+		opts.Prefix = "coverage"
+	}
+	points, err := c.CoveragePoints(testName, opts.TimeSeriesOptions)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	for _, p := range points {
+		metric := strings.Join([]string{opts.Prefix, graphiteName(p.Test), graphiteName(p.Package)}, ".")
+		fmt.Fprintf(&b, "%s.percent %s %d\n", metric, strconv.FormatFloat(p.Percent, 'f', -1, 64), p.Time.Unix())
+		fmt.Fprintf(&b, "%s.statements %d %d\n", metric, p.Statements, p.Time.Unix())
+		fmt.Fprintf(&b, "%s.covered %d %d\n", metric, p.Covered, p.Time.Unix())
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", opts.Address)
+	if err != nil {
+		return fmt.Errorf("connect to Graphite: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("write to Graphite: %w", err)
+	}
+
+	c.logf("📈 Sent %d coverage data point(s) to Graphite\n", len(points))
+	return nil
+}
+
+// graphiteName turns a test or package name into a single Graphite path node
+func graphiteName(name string) string {
+	return strings.Trim(sanitizeName(strings.NewReplacer("/", "_", ".", "_").Replace(name)), "_")
+}
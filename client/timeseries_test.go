@@ -0,0 +1,131 @@
+package coverageclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTimeSeriesClient(t *testing.T) *CoverageClient {
+	clearCIEnv(t)
+	outputDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outputDir, "e2e"), 0755)
+	os.WriteFile(filepath.Join(outputDir, "e2e", "coverage.out"), []byte(testProfile), 0644)
+	return &CoverageClient{outputDir: outputDir}
+}
+
+var testRunTime = time.Date(2025, 1, 10, 14, 30, 0, 0, time.UTC)
+
+func TestAppendCoverageTimeSeries(t *testing.T) {
+	client := newTimeSeriesClient(t)
+	opts := TimeSeriesOptions{Time: testRunTime, Tags: map[string]string{"env": "ci"}}
+
+	csvPath := filepath.Join(t.TempDir(), "coverage.csv")
+	for i := 0; i < 2; i++ {
+		if err := client.AppendCoverageTimeSeries("e2e", csvPath, opts); err != nil {
+			t.Fatalf("AppendCoverageTimeSeries failed: %v", err)
+		}
+	}
+	f, _ := os.Open(csvPath)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	// One header, then total and two packages per run
+	if len(rows) != 7 || rows[0][0] != "time" {
+		t.Fatalf("Expected header and 6 rows, got %v", rows)
+	}
+	expected := []string{"2025-01-10T14:30:00Z", "e2e", "total", "9", "6", "66.67", "env=ci"}
+	if strings.Join(rows[1], ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected total row: %v", rows[1])
+	}
+
+	jsonPath := filepath.Join(t.TempDir(), "coverage.jsonl")
+	if err := client.AppendCoverageTimeSeries("e2e", jsonPath, opts); err != nil {
+		t.Fatalf("AppendCoverageTimeSeries failed: %v", err)
+	}
+	data, _ := os.ReadFile(jsonPath)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var point CoveragePoint
+	if len(lines) != 3 || json.Unmarshal([]byte(lines[2]), &point) != nil {
+		t.Fatalf("Expected 3 JSON lines, got:\n%s", data)
+	}
+	if point.Package != "github.com/example/app/pkg/util" || point.Percent != 100 || point.Tags["env"] != "ci" {
+		t.Errorf("Unexpected point: %+v", point)
+	}
+}
+
+func TestSendToInfluxDB(t *testing.T) {
+	client := newTimeSeriesClient(t)
+	var body, auth, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, auth, query = string(data), r.Header.Get("Authorization"), r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := client.SendToInfluxDB(context.Background(), "e2e", InfluxDBOptions{
+		TimeSeriesOptions: TimeSeriesOptions{Time: testRunTime, Tags: map[string]string{"env": "nightly run"}},
+		URL:               server.URL,
+		Token:             "secret",
+		Org:               "qe",
+		Bucket:            "coverage",
+	})
+	if err != nil {
+		t.Fatalf("SendToInfluxDB failed: %v", err)
+	}
+	if auth != "Token secret" || query != "bucket=coverage&org=qe&precision=s" {
+		t.Errorf("Unexpected request: auth=%q query=%q", auth, query)
+	}
+	first := strings.SplitN(body, "\n", 2)[0]
+	if first != `coverage,env=nightly\ run,package=total,test=e2e percent=66.66666666666667,statements=9i,covered=6i 1736519400` {
+		t.Errorf("Unexpected line protocol: %s", first)
+	}
+}
+
+func TestSendToGraphite(t *testing.T) {
+	client := newTimeSeriesClient(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var lines []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+
+	err = client.SendToGraphite(context.Background(), "e2e", GraphiteOptions{
+		TimeSeriesOptions: TimeSeriesOptions{Time: testRunTime},
+		Address:           listener.Addr().String(),
+	})
+	if err != nil {
+		t.Fatalf("SendToGraphite failed: %v", err)
+	}
+	lines := <-received
+	if len(lines) != 9 || lines[6] != "coverage.e2e.github_com_example_app_pkg_util.percent 100 1736519400" {
+		t.Errorf("Unexpected Graphite lines: %v", lines)
+	}
+}
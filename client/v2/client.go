@@ -0,0 +1,149 @@
+// Package coverageclient is the context-first API of the coverage client, for embedding in test
+// frameworks: every operation takes a context and an options struct, returns a structured result
+// and never prints. Output only goes to the Logger given in Options.
+//
+// It's built on the v1 package (github.com/psturc/go-coverage-http/client), which stays supported;
+// options and results are shared with it, and V1 gives access to settings not covered here.
+package coverageclient
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/psturc/go-coverage-http/client"
+)
+
+// Types shared with v1
+type (
+	Logger                = v1.Logger
+	Config                = v1.Config
+	CollectOptions        = v1.CollectOptions
+	CollectResult         = v1.CollectResult
+	ProcessOptions        = v1.ProcessOptions
+	ProcessResult         = v1.ProcessResult
+	ReportOptions         = v1.CoverageReportOptions
+	CoverageReport        = v1.CoverageReport
+	GateConfig            = v1.GateConfig
+	GateResult            = v1.GateResult
+	ArtifactOptions       = v1.PushCoverageArtifactOptions
+	PushResult            = v1.PushResult
+	CoverageEndpointError = v1.CoverageEndpointError
+	EventHandler          = v1.EventHandler
+	EventHandlerFunc      = v1.EventHandlerFunc
+	Event                 = v1.Event
+	CollectStarted        = v1.CollectStarted
+	CollectFinished       = v1.CollectFinished
+	ReportGenerated       = v1.ReportGenerated
+	PushStarted           = v1.PushStarted
+	PushFinished          = v1.PushFinished
+)
+
+// Errors shared with v1, for errors.Is
+var (
+	ErrNoPodsFound                 = v1.ErrNoPodsFound
+	ErrPodNotRunning               = v1.ErrPodNotRunning
+	ErrNoRunningPod                = v1.ErrNoRunningPod
+	ErrCoverageNotEnabled          = v1.ErrCoverageNotEnabled
+	ErrNotInstrumented             = v1.ErrNotInstrumented
+	ErrCoverageEndpointUnavailable = v1.ErrCoverageEndpointUnavailable
+	ErrPortForwardTimeout          = v1.ErrPortForwardTimeout
+	ErrArtifactPush                = v1.ErrArtifactPush
+)
+
+// Options configures New
+type Options struct {
+	Namespace   string         // Namespace of the pods (none: a client without cluster access)
+	KubeContext string         // kubeconfig context (default: current context)
+	OutputDir   string         // Directory coverage is collected into (default: $COVERAGE_OUTPUT_DIR or coverage-output)
+	ConfigFile  string         // YAML or JSON configuration (see v1.LoadConfig); replaces the fields above
+	Logger      Logger         // Receives progress output (default: none)
+	Events      []EventHandler // Receive workflow events with typed payloads, see v1.AddEventHandler
+}
+
+// Client collects and processes coverage
+type Client struct {
+	v1 *v1.CoverageClient
+}
+
+// New creates a client. COVERAGE_* environment variables override its defaults as in v1.
+func New(opts Options) (*Client, error) {
+	var c *v1.CoverageClient
+	var err error
+	switch {
+	case opts.ConfigFile != "":
+		c, err = v1.LoadConfig(opts.ConfigFile)
+	case opts.Namespace != "":
+		c, err = v1.NewClientForContext(opts.KubeContext, opts.Namespace, opts.OutputDir)
+	default:
+		c, err = v1.NewLocalClient(opts.OutputDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, handler := range opts.Events {
+		c.AddEventHandler(handler)
+	}
+	return Wrap(c, opts.Logger), nil
+}
+
+// Wrap returns a Client operating on a v1 client, routing its output to logger (nil: none)
+func Wrap(c *v1.CoverageClient, logger Logger) *Client {
+	c.SetLogger(logger)
+	c.SetQuiet(logger == nil)
+	return &Client{v1: c}
+}
+
+// V1 returns the underlying v1 client, e.g. to configure filters or collection methods. Its
+// print-style methods (PrintCoverageSummary and the like) write to the Logger of Options.
+func (c *Client) V1() *v1.CoverageClient {
+	return c.v1
+}
+
+// FindPod returns the name of the first running pod matching labelSelector
+func (c *Client) FindPod(ctx context.Context, labelSelector string) (string, error) {
+	return c.v1.GetPodNameWithContext(ctx, labelSelector)
+}
+
+// Collect collects coverage as configured by opts, see v1.CoverageClient.Collect
+func (c *Client) Collect(ctx context.Context, opts CollectOptions) (*CollectResult, error) {
+	return c.v1.Collect(ctx, opts)
+}
+
+// Process generates the text, filtered and HTML reports of a collected test
+func (c *Client) Process(ctx context.Context, opts ProcessOptions) (*ProcessResult, error) {
+	if opts.TestName == "" {
+		return nil, fmt.Errorf("process: no test name")
+	}
+	return c.v1.ProcessCoverage(ctx, opts)
+}
+
+// Report summarizes a processed test's coverage, compared to a baseline and the changed lines
+// when configured
+func (c *Client) Report(ctx context.Context, opts ReportOptions) (*CoverageReport, error) {
+	if opts.TestName == "" {
+		return nil, fmt.Errorf("report: no test name")
+	}
+	return c.v1.BuildCoverageReport(ctx, opts)
+}
+
+// Gate evaluates coverage thresholds against a processed test; its Summary describes the decision
+func (c *Client) Gate(ctx context.Context, cfg GateConfig) (*GateResult, error) {
+	if cfg.TestName == "" {
+		return nil, fmt.Errorf("gate: no test name")
+	}
+	return c.v1.Gate(ctx, cfg)
+}
+
+// PushOptions configures Push
+type PushOptions struct {
+	TestName string
+	Artifact ArtifactOptions // Destination and metadata (default: the configured destination, see v1.SetArtifactDestination)
+}
+
+// Push pushes a test's coverage as an OCI artifact
+func (c *Client) Push(ctx context.Context, opts PushOptions) (*PushResult, error) {
+	if opts.TestName == "" {
+		return nil, fmt.Errorf("push: no test name")
+	}
+	return c.v1.PushCoverageArtifactWithResult(ctx, opts.TestName, opts.Artifact)
+}
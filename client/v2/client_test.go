@@ -0,0 +1,110 @@
+package coverageclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testHash = "0123456789abcdef0123456789abcdef"
+
+// coverageServer plays a coverage endpoint answering with fake covdata in the JSON format
+func coverageServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"meta_filename":"covmeta.%s","meta_data":"bWV0YQ==","counters_filename":"covcounters.%s.1.1","counters_data":"Y291bnRlcnM="}`, testHash, testHash)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// captureStdout returns what f writes to stdout
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+type recordingLogger struct{ lines []string }
+
+func (l *recordingLogger) Printf(format string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestClient_CollectNeverPrints(t *testing.T) {
+	server := coverageServer(t)
+	client, err := New(Options{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var result *CollectResult
+	output := captureStdout(t, func() {
+		result, err = client.Collect(context.Background(), CollectOptions{TestName: "e2e", URL: server.URL + "/coverage"})
+	})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if output != "" {
+		t.Errorf("Expected no output, got %q", output)
+	}
+	if len(result.Files) != 2 || result.TestName != "e2e" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestClient_Logger(t *testing.T) {
+	server := coverageServer(t)
+	logger := &recordingLogger{}
+	client, err := New(Options{OutputDir: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := client.Collect(context.Background(), CollectOptions{TestName: "e2e", URL: server.URL + "/coverage"}); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if !strings.Contains(strings.Join(logger.lines, ""), "e2e") {
+		t.Errorf("Expected progress output in the logger, got %q", logger.lines)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client, err := New(Options{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Collect(ctx, CollectOptions{TestName: "e2e", URL: server.URL + "/coverage"}); !errors.Is(err, ErrCoverageNotEnabled) {
+		t.Errorf("Expected ErrCoverageNotEnabled, got %v", err)
+	}
+	if _, err := client.Process(ctx, ProcessOptions{}); err == nil {
+		t.Error("Expected Process without a test name to fail")
+	}
+	if _, err := client.Push(ctx, PushOptions{}); err == nil {
+		t.Error("Expected Push without a test name to fail")
+	}
+	if _, err := client.Process(ctx, ProcessOptions{TestName: "missing"}); err == nil {
+		t.Error("Expected Process of a test without coverage to fail")
+	}
+}
@@ -0,0 +1,214 @@
+package coverageclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layout of covdata files (internal/coverage in the Go distribution): headers are little-endian
+// regardless of the counters' byte order
+var (
+	covMetaMagic    = [4]byte{0x00, 'c', 'v', 'm'}
+	covCounterMagic = [4]byte{0x00, 'c', 'w', 'm'}
+)
+
+const (
+	covdataVersion        = 1
+	covMetaHeaderSize     = 56 // Magic, Version, TotalLength, Entries, MetaFileHash, StrTabOffset, StrTabLength, CMode, CGranularity, padding
+	covCounterHeaderSize  = 32 // Magic, Version, MetaHash, CFlavor, BigEndian, padding
+	covCounterFooterSize  = 16 // Magic, padding, NumSegments, padding
+	covCounterFlavorULeb  = 2  // Highest known counter flavor (1: raw, 2: ULEB128)
+	covMetaMaxCounterMode = 5  // Highest known counter mode (1: set, 2: count, 3: atomic, 4: regonly, 5: testmain)
+)
+
+// ValidationIssue is a problem with a covdata file
+type ValidationIssue struct {
+	File    string `json:"file"`    // File name in the test directory
+	Message string `json:"message"` // What's wrong with it
+}
+
+func (i ValidationIssue) String() string {
+	return i.File + ": " + i.Message
+}
+
+// ValidationReport is the result of ValidateCoverageData
+type ValidationReport struct {
+	Dir      string            `json:"dir"`
+	Binaries []CovdataGroup    `json:"binaries"`           // Instrumented binaries whose meta-data is intact
+	Errors   []ValidationIssue `json:"errors,omitempty"`   // Files that make `go tool covdata` fail: corrupt, truncated or without meta-data
+	Warnings []ValidationIssue `json:"warnings,omitempty"` // Files that are valid but likely not what was expected, e.g. meta-data without counters
+}
+
+// Valid reports whether the directory can be processed without errors
+func (r *ValidationReport) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Err returns an error listing the errors, nil for a valid directory
+func (r *ValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	issues := make([]string, len(r.Errors))
+	for i, issue := range r.Errors {
+		issues[i] = issue.String()
+	}
+	return fmt.Errorf("invalid coverage data in %s: %s", r.Dir, strings.Join(issues, "; "))
+}
+
+// ValidateCoverageData checks the covdata files in testDir before they're merged or reported,
+// reporting problems `go tool covdata` would only fail on with cryptic errors: counters files
+// without their meta-data file, meta-data without counters, and files that are truncated or
+// corrupt (bad magic, version, length or hash, like a `go tool covdata debugdump` pass over the
+// headers). The error is only set when the directory can't be read.
+func ValidateCoverageData(testDir string) (*ValidationReport, error) {
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		return nil, fmt.Errorf("read coverage directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	report := &ValidationReport{Dir: testDir, Binaries: []CovdataGroup{}}
+	addError := func(file, format string, args ...any) {
+		report.Errors = append(report.Errors, ValidationIssue{File: file, Message: fmt.Sprintf(format, args...)})
+	}
+	groups := GroupCovdataFiles(names)
+	if len(groups) == 0 {
+		report.Warnings = append(report.Warnings, ValidationIssue{File: ".", Message: "no covdata files"})
+	}
+	for _, g := range groups {
+		if !isMetaHash(g.Hash) {
+			for _, file := range append([]string{g.Meta}, g.Counters...) {
+				if file != "" {
+					addError(file, "file name has no valid meta-data hash")
+				}
+			}
+			continue
+		}
+		metaValid := false
+		switch {
+		case g.Meta == "":
+			for _, counters := range g.Counters {
+				addError(counters, "no meta-data file covmeta.%s", g.Hash)
+			}
+		default:
+			if err := validateMetaFile(filepath.Join(testDir, g.Meta), g.Hash); err != nil {
+				addError(g.Meta, "%v", err)
+			} else {
+				metaValid = true
+			}
+		}
+		if g.Meta != "" && len(g.Counters) == 0 {
+			report.Warnings = append(report.Warnings, ValidationIssue{File: g.Meta, Message: "no counters files, the binary's coverage is empty"})
+		}
+		for _, counters := range g.Counters {
+			if err := validateCountersFile(filepath.Join(testDir, counters), g.Hash); err != nil {
+				addError(counters, "%v", err)
+			}
+		}
+		if metaValid {
+			report.Binaries = append(report.Binaries, g)
+		}
+	}
+	return report, nil
+}
+
+// validateMetaFile checks the header of a covmeta file against its size and name
+func validateMetaFile(path, hash string) error {
+	data, size, err := readHeader(path, covMetaHeaderSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data[0:4], covMetaMagic[:]) {
+		return fmt.Errorf("not a meta-data file (bad magic %x)", data[0:4])
+	}
+	if version := binary.LittleEndian.Uint32(data[4:8]); version != covdataVersion {
+		return fmt.Errorf("unsupported meta-data version %d", version)
+	}
+	if length := binary.LittleEndian.Uint64(data[8:16]); length != uint64(size) {
+		return fmt.Errorf("truncated or corrupt: header says %d bytes, file has %d", length, size)
+	}
+	if entries := binary.LittleEndian.Uint64(data[16:24]); entries == 0 {
+		return fmt.Errorf("no packages")
+	}
+	if fileHash := hex.EncodeToString(data[24:40]); fileHash != hash {
+		return fmt.Errorf("meta-data hash %s doesn't match the file name", fileHash)
+	}
+	offset, length := binary.LittleEndian.Uint32(data[40:44]), binary.LittleEndian.Uint32(data[44:48])
+	if uint64(offset)+uint64(length) > uint64(size) {
+		return fmt.Errorf("corrupt: string table exceeds the file")
+	}
+	if mode := data[48]; mode == 0 || mode > covMetaMaxCounterMode {
+		return fmt.Errorf("corrupt: unknown counter mode %d", mode)
+	}
+	return nil
+}
+
+// validateCountersFile checks the header and footer of a covcounters file of the binary with hash
+func validateCountersFile(path, hash string) error {
+	data, size, err := readHeader(path, covCounterHeaderSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data[0:4], covCounterMagic[:]) {
+		return fmt.Errorf("not a counters file (bad magic %x)", data[0:4])
+	}
+	if version := binary.LittleEndian.Uint32(data[4:8]); version != covdataVersion {
+		return fmt.Errorf("unsupported counters version %d", version)
+	}
+	if metaHash := hex.EncodeToString(data[8:24]); metaHash != hash {
+		return fmt.Errorf("counters belong to meta-data %s, not %s as named", metaHash, hash)
+	}
+	if flavor := data[24]; flavor == 0 || flavor > covCounterFlavorULeb {
+		return fmt.Errorf("corrupt: unknown counter flavor %d", flavor)
+	}
+	if size < covCounterHeaderSize+covCounterFooterSize {
+		return fmt.Errorf("truncated: no footer")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	footer := make([]byte, covCounterFooterSize)
+	if _, err := f.ReadAt(footer, size-covCounterFooterSize); err != nil {
+		return fmt.Errorf("read footer: %w", err)
+	}
+	if !bytes.Equal(footer[0:4], covCounterMagic[:]) {
+		return fmt.Errorf("truncated or corrupt: no footer")
+	}
+	if segments := binary.LittleEndian.Uint32(footer[8:12]); segments == 0 {
+		return fmt.Errorf("corrupt: no counter segments")
+	}
+	return nil
+}
+
+// readHeader returns the first n bytes and the size of the file at path
+func readHeader(path string, n int) ([]byte, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, info.Size(), fmt.Errorf("truncated: %d bytes, shorter than the %d byte header", info.Size(), n)
+	}
+	return data, info.Size(), nil
+}
@@ -0,0 +1,134 @@
+package coverageclient
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// covmetaFile returns a meta-data file with a valid header for hash
+func covmetaFile(hash string) []byte {
+	data := make([]byte, covMetaHeaderSize+8)
+	copy(data, covMetaMagic[:])
+	binary.LittleEndian.PutUint32(data[4:], covdataVersion)
+	binary.LittleEndian.PutUint64(data[8:], uint64(len(data)))
+	binary.LittleEndian.PutUint64(data[16:], 1)
+	h, _ := hex.DecodeString(hash)
+	copy(data[24:], h)
+	binary.LittleEndian.PutUint32(data[40:], covMetaHeaderSize)
+	binary.LittleEndian.PutUint32(data[44:], 8)
+	data[48] = 3 // atomic
+	return data
+}
+
+// covcountersFile returns a counters file with a valid header and footer for hash
+func covcountersFile(hash string) []byte {
+	data := make([]byte, covCounterHeaderSize+8+covCounterFooterSize)
+	copy(data, covCounterMagic[:])
+	binary.LittleEndian.PutUint32(data[4:], covdataVersion)
+	h, _ := hex.DecodeString(hash)
+	copy(data[8:], h)
+	data[24] = 2 // ULEB128
+	footer := data[len(data)-covCounterFooterSize:]
+	copy(footer, covCounterMagic[:])
+	binary.LittleEndian.PutUint32(footer[8:], 1)
+	return data
+}
+
+func writeCovdata(t *testing.T, files map[string][]byte) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidateCoverageData_Valid(t *testing.T) {
+	dir := writeCovdata(t, map[string][]byte{
+		"covmeta." + newBinaryHash:              covmetaFile(newBinaryHash),
+		"covcounters." + newBinaryHash + ".1.1": covcountersFile(newBinaryHash),
+		"covcounters." + newBinaryHash + ".2.1": covcountersFile(newBinaryHash),
+		"covmeta." + oldBinaryHash:              covmetaFile(oldBinaryHash),
+		"metadata.json":                         []byte("{}"),
+		"coverage.out":                          []byte("mode: atomic\n"),
+	})
+	report, err := ValidateCoverageData(dir)
+	if err != nil {
+		t.Fatalf("ValidateCoverageData failed: %v", err)
+	}
+	if !report.Valid() || report.Err() != nil {
+		t.Errorf("Expected valid coverage data, got %+v", report.Errors)
+	}
+	if len(report.Binaries) != 2 {
+		t.Errorf("Expected two binaries, got %+v", report.Binaries)
+	}
+}
+
+func TestValidateCoverageData_Problems(t *testing.T) {
+	truncatedMeta := covmetaFile(newBinaryHash)
+	truncatedCounters := covcountersFile(newBinaryHash)
+	wrongHash := covcountersFile(oldBinaryHash)
+	dir := writeCovdata(t, map[string][]byte{
+		"covmeta." + newBinaryHash:              truncatedMeta[:len(truncatedMeta)-4],
+		"covcounters." + newBinaryHash + ".1.1": truncatedCounters[:len(truncatedCounters)-4],
+		"covcounters." + newBinaryHash + ".2.1": wrongHash,
+		"covcounters." + newBinaryHash + ".3.1": []byte("counters"),
+		"covcounters." + oldBinaryHash + ".4.1": covcountersFile(oldBinaryHash),
+		"covmeta.abc":                           covmetaFile(newBinaryHash),
+	})
+	report, err := ValidateCoverageData(dir)
+	if err != nil {
+		t.Fatalf("ValidateCoverageData failed: %v", err)
+	}
+	want := map[string]string{
+		"covmeta." + newBinaryHash:              "truncated or corrupt",
+		"covcounters." + newBinaryHash + ".1.1": "no footer",
+		"covcounters." + newBinaryHash + ".2.1": "counters belong to meta-data " + oldBinaryHash,
+		"covcounters." + newBinaryHash + ".3.1": "truncated",
+		"covcounters." + oldBinaryHash + ".4.1": "no meta-data file",
+		"covmeta.abc":                           "no valid meta-data hash",
+	}
+	if len(report.Errors) != len(want) {
+		t.Errorf("Expected %d errors, got %+v", len(want), report.Errors)
+	}
+	for _, issue := range report.Errors {
+		if !strings.Contains(issue.Message, want[issue.File]) {
+			t.Errorf("Expected %q for %s, got %q", want[issue.File], issue.File, issue.Message)
+		}
+	}
+	if report.Valid() || len(report.Binaries) != 0 {
+		t.Errorf("Expected no valid binaries, got %+v", report.Binaries)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "covmeta.abc") {
+		t.Errorf("Expected the error to name the files, got %v", err)
+	}
+}
+
+func TestValidateCoverageData_Warnings(t *testing.T) {
+	dir := writeCovdata(t, map[string][]byte{"covmeta." + newBinaryHash: covmetaFile(newBinaryHash)})
+	report, err := ValidateCoverageData(dir)
+	if err != nil || !report.Valid() || len(report.Warnings) != 1 {
+		t.Errorf("Expected a warning about missing counters, got %+v (%v)", report, err)
+	}
+
+	if _, err := ValidateCoverageData(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected a missing directory to fail")
+	}
+}
+
+func TestGenerateCoverageReport_InvalidData(t *testing.T) {
+	client := &CoverageClient{outputDir: t.TempDir(), quiet: true}
+	testDir := filepath.Join(client.outputDir, "e2e")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "covcounters."+newBinaryHash+".1.1"), covcountersFile(newBinaryHash), 0644)
+
+	err := client.GenerateCoverageReport("e2e")
+	if err == nil || !strings.Contains(err.Error(), "no meta-data file covmeta."+newBinaryHash) {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Command coverage-collector runs the in-cluster coverage collector service.
+//
+// Instrumented pods push snapshots to it (COVERAGE_PUSH_URL=http://coverage-collector:9096)
+// and test suites download the aggregated data with CoverageClient.CollectCoverageFromCollector.
+// With -schedule and -targets it also collects from the listed workloads on a cron schedule and
+// optionally pushes every run as an OCI artifact.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+	"github.com/psturc/go-coverage-http/collector"
+)
+
+func main() {
+	addr := flag.String("addr", ":9096", "Address to listen on")
+	dataDir := flag.String("data-dir", "/data", "Directory to store pushed coverage data")
+	leaderElect := flag.Bool("leader-elect", false, "Enable leader election so only one replica runs scheduled collection (push mode needs a single replica)")
+	leaseName := flag.String("lease-name", "coverage-collector", "Name of the Lease used for leader election")
+	leaseNamespace := flag.String("lease-namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the Lease used for leader election")
+	schedule := flag.String("schedule", "", "Cron expression for scheduled collection from -targets (e.g. \"*/30 * * * *\")")
+	targetsFile := flag.String("targets", "", "JSON file listing the targets of scheduled collection")
+	namespace := flag.String("namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the scheduled collection targets")
+	outputDir := flag.String("output-dir", "/data/scheduled", "Directory to store scheduled collection runs")
+	pushRegistry := flag.String("push-registry", "", "Registry to push each scheduled run to as an OCI artifact (e.g. quay.io)")
+	pushRepository := flag.String("push-repository", "", "Repository to push scheduled runs to (e.g. myorg/coverage)")
+	pushExpiresAfter := flag.String("push-expires-after", "", "Expiration of pushed artifacts (e.g. 30d)")
+	maxPushSize := flag.Int64("max-push-size", collector.DefaultMaxPushSize, "Largest push request accepted, in bytes (needs about three times as much memory)")
+	flag.Parse()
+
+	server, err := collector.NewServer(*dataDir)
+	if err != nil {
+		log.Fatalf("[COLLECTOR] Failed to create collector: %v", err)
+	}
+	server.SetMaxPushSize(*maxPushSize)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Scheduled collection is leader-only work: with leader election it runs while this replica leads
+	var runSchedule func(ctx context.Context)
+	if *schedule != "" {
+		opts, err := loadScheduledCollection(*schedule, *targetsFile)
+		if err != nil {
+			log.Fatalf("[COLLECTOR] Invalid scheduled collection config: %v", err)
+		}
+		if *pushRegistry != "" {
+			opts.Push = &coverageclient.PushCoverageArtifactOptions{
+				Registry:     *pushRegistry,
+				Repository:   *pushRepository,
+				ExpiresAfter: *pushExpiresAfter,
+			}
+		}
+		covClient, err := coverageclient.NewClient(*namespace, *outputDir)
+		if err != nil {
+			log.Fatalf("[COLLECTOR] Failed to create coverage client: %v", err)
+		}
+		runSchedule = func(ctx context.Context) {
+			if err := covClient.RunScheduledCollection(ctx, opts); err != nil {
+				log.Printf("[COLLECTOR] Scheduled collection failed: %v", err)
+			}
+		}
+	}
+
+	if *leaderElect {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			log.Fatalf("[COLLECTOR] Leader election requires in-cluster config: %v", err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			log.Fatalf("[COLLECTOR] Failed to create kubernetes client: %v", err)
+		}
+
+		leadership := &collector.Leadership{}
+		server.SetLeadership(leadership)
+
+		go func() {
+			err := collector.RunLeaderElection(ctx, clientset, collector.LeaderElectionConfig{
+				LeaseName:      *leaseName,
+				LeaseNamespace: *leaseNamespace,
+			}, leadership, runSchedule)
+			if err != nil {
+				log.Fatalf("[COLLECTOR] Leader election failed: %v", err)
+			}
+		}()
+	}
+
+	if runSchedule != nil && !*leaderElect {
+		go runSchedule(ctx)
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: server.Handler()}
+	go func() {
+		<-ctx.Done()
+		httpServer.Shutdown(context.Background())
+	}()
+
+	log.Printf("[COLLECTOR] Starting coverage collector on %s (data dir: %s)", *addr, *dataDir)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("[COLLECTOR] Server failed: %v", err)
+	}
+}
+
+// loadScheduledCollection validates the schedule and reads the JSON targets file
+func loadScheduledCollection(schedule, targetsFile string) (coverageclient.ScheduledCollectionOptions, error) {
+	opts := coverageclient.ScheduledCollectionOptions{Schedule: schedule}
+	if _, err := coverageclient.ParseCronSchedule(schedule); err != nil {
+		return opts, err
+	}
+	if targetsFile == "" {
+		return opts, fmt.Errorf("-targets is required with -schedule")
+	}
+
+	data, err := os.ReadFile(targetsFile)
+	if err != nil {
+		return opts, fmt.Errorf("read targets file: %w", err)
+	}
+	if err := json.Unmarshal(data, &opts.Targets); err != nil {
+		return opts, fmt.Errorf("parse targets file: %w", err)
+	}
+	return opts, nil
+}
@@ -0,0 +1,128 @@
+// Command coverage-gate evaluates coverage thresholds against a processed test in CI.
+//
+// It reads the coverage collected into -output-dir by the client library and exits with
+// 0 when all rules pass, 1 when a rule fails and 2 when the gate could not be evaluated.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+func main() {
+	outputDir := flag.String("output-dir", "", "Directory the coverage was collected into (default: the config's, $COVERAGE_OUTPUT_DIR or ./coverage-output)")
+	configFile := flag.String("config", "", "Client configuration file (YAML or JSON); its outputDir and thresholds apply unless overridden by flags")
+	testName := flag.String("test", "", "Processed test to gate (required)")
+	baseline := flag.String("baseline", "", "Coverage profile of the base branch, for the regression rule")
+	baseRef := flag.String("base-ref", "", "Git ref for patch coverage (default: detected from CI env vars)")
+	repoDir := flag.String("repo-dir", "", "Git checkout for patch coverage (default: current directory)")
+	thresholdsFile := flag.String("thresholds", "", "JSON file with minTotal, minPatch, maxDecrease and minPackage")
+	minTotal := flag.Float64("min-total", 0, "Minimum total coverage in percent")
+	minPatch := flag.Float64("min-patch", 0, "Minimum coverage of changed statements in percent")
+	maxDecrease := flag.Float64("max-decrease", 0, "Maximum drop of total coverage vs. -baseline in percentage points")
+	minPackage := flag.Float64("min-package", 0, "Minimum coverage of every package in percent")
+	failOnSkipped := flag.Bool("fail-on-skipped", false, "Fail rules that need a missing baseline or base ref instead of skipping them")
+	resultFile := flag.String("result", "", "Write the gate result as JSON to this file")
+	flag.Parse()
+
+	if *testName == "" {
+		log.Printf("-test is required")
+		os.Exit(coverageclient.GateExitError)
+	}
+
+	cfg := coverageclient.GateConfig{
+		CoverageReportOptions: coverageclient.CoverageReportOptions{
+			TestName:        *testName,
+			BaselineProfile: *baseline,
+			BaseRef:         *baseRef,
+			RepoDir:         *repoDir,
+		},
+		FailOnSkipped: *failOnSkipped,
+	}
+	var clientCfg coverageclient.Config
+	if *configFile != "" {
+		var err error
+		if clientCfg, err = coverageclient.ReadConfig(*configFile); err != nil {
+			log.Printf("Failed to load config: %v", err)
+			os.Exit(coverageclient.GateExitError)
+		}
+		cfg.Thresholds = clientCfg.Thresholds
+	}
+	if *thresholdsFile != "" {
+		data, err := os.ReadFile(*thresholdsFile)
+		if err != nil {
+			log.Printf("Failed to read thresholds: %v", err)
+			os.Exit(coverageclient.GateExitError)
+		}
+		if err := json.Unmarshal(data, &cfg.Thresholds); err != nil {
+			log.Printf("Failed to parse thresholds: %v", err)
+			os.Exit(coverageclient.GateExitError)
+		}
+	}
+	// Flags override the thresholds file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-total":
+			cfg.Thresholds.MinTotal = *minTotal
+		case "min-patch":
+			cfg.Thresholds.MinPatch = *minPatch
+		case "max-decrease":
+			cfg.Thresholds.MaxDecrease = *maxDecrease
+		case "min-package":
+			cfg.Thresholds.MinPackage = *minPackage
+		}
+	})
+
+	// The gate only reads the output directory, whatever namespace the config collects from
+	clientCfg.Namespace = ""
+	baseDir := "."
+	if *configFile != "" {
+		baseDir = filepath.Dir(*configFile)
+	}
+	if *outputDir != "" {
+		// Relative to the working directory, not the config file
+		var err error
+		if clientCfg.OutputDir, err = filepath.Abs(*outputDir); err != nil {
+			log.Printf("Failed to resolve output directory: %v", err)
+			os.Exit(coverageclient.GateExitError)
+		}
+	}
+	client, err := coverageclient.NewClientFromConfig(clientCfg, baseDir)
+	if err != nil {
+		log.Printf("Failed to create coverage client: %v", err)
+		os.Exit(coverageclient.GateExitError)
+	}
+
+	result, err := client.Gate(context.Background(), cfg)
+	if err == nil && *resultFile != "" {
+		err = writeResult(*resultFile, result)
+	}
+	if err != nil {
+		log.Printf("Coverage gate error: %v", err)
+	}
+	os.Exit(coverageclient.GateExitCode(result, err))
+}
+
+// writeResult writes the gate result as JSON
+func writeResult(path string, result *coverageclient.GateResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create gate result: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetEscapeHTML(false) // Keep ">=" readable in rule names
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return fmt.Errorf("write gate result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Command coverage-http onboards applications onto HTTP coverage collection.
+//
+//	coverage-http init [flags]
+//
+// instruments the Go module in the current directory (or -dir): it adds the coverage server to
+// the main package behind a build tag and writes a Dockerfile snippet and a Kustomize component
+// exposing the coverage port.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/psturc/go-coverage-http/scaffold"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "init" {
+		fmt.Fprintf(os.Stderr, "Usage: %s init [flags]\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Root of the Go module to instrument")
+	mainPackage := flags.String("main", "", "Directory of the main package, relative to -dir (default: the only main package)")
+	deployment := flags.String("deployment", "", "Deployment in the Kustomize patch (default: the main package's directory name)")
+	container := flags.String("container", "", "Container in the Kustomize patch (default: -deployment)")
+	port := flags.Int("port", 0, "Coverage port (default: 9095)")
+	buildTag := flags.String("tag", scaffold.DefaultBuildTag, "Build tag the coverage server is compiled with")
+	outputDir := flags.String("output", scaffold.DefaultOutputDir, "Directory for the Dockerfile snippet and Kustomize component, relative to -dir")
+	force := flags.Bool("force", false, "Overwrite files generated earlier")
+	flags.Parse(os.Args[2:])
+
+	result, err := scaffold.Init(scaffold.Options{
+		Dir:         *dir,
+		MainPackage: *mainPackage,
+		Deployment:  *deployment,
+		Container:   *container,
+		Port:        *port,
+		BuildTag:    *buildTag,
+		OutputDir:   *outputDir,
+		Force:       *force,
+	})
+	if err != nil {
+		log.Fatalf("Failed to instrument module: %v", err)
+	}
+
+	fmt.Printf("✅ Instrumented %s (main package ./%s)\n", result.Module, result.MainPackage)
+	for _, file := range result.Files {
+		fmt.Printf("   📝 %s\n", file)
+	}
+	fmt.Printf("\nNext steps:\n")
+	fmt.Printf("   1. Build test images with: go build %s ./%s\n", result.BuildFlags, result.MainPackage)
+	fmt.Printf("      (see %s/Dockerfile.snippet)\n", *outputDir)
+	fmt.Printf("   2. Add %s to the components of your test overlay's kustomization.yaml\n", *outputDir)
+	fmt.Printf("   3. Collect coverage in your e2e tests with the client library\n")
+}
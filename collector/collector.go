@@ -0,0 +1,518 @@
+// Package collector implements an in-cluster coverage collector service.
+//
+// Instrumented applications running in push mode (COVERAGE_PUSH_URL set) send their
+// coverage snapshots to the collector, on a timer or from a preStop hook when they're
+// terminated, so short-lived Jobs and autoscaled pods are covered after they're gone. The
+// collector stores them per suite (a test run), and per service within it when the
+// applications name one (COVERAGE_SERVICE). The external test client downloads all data
+// for a suite, or one of its services, in one request.
+package collector
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexFile tracks the latest counters file stored for each source within a suite
+const indexFile = ".sources.json"
+
+// validName restricts suite names and filenames to safe path components
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DefaultMaxPushSize bounds the body of a push request, see SetMaxPushSize. A push is decoded in
+// memory, taking about three times its size, so the default fits the 128Mi limit of the shipped
+// collector manifest.
+const DefaultMaxPushSize = 16 << 20
+
+// PushRequest is the payload sent by instrumented applications in push mode
+type PushRequest struct {
+	MetaFilename     string `json:"meta_filename"`
+	MetaData         string `json:"meta_data"`
+	CountersFilename string `json:"counters_filename"`
+	CountersData     string `json:"counters_data"`
+	Timestamp        int64  `json:"timestamp"`
+	Suite            string `json:"suite"`
+	PodName          string `json:"pod_name"`
+	Namespace        string `json:"namespace"`
+	Service          string `json:"service,omitempty"`
+	ResetGeneration  int64  `json:"reset_generation,string,omitempty"` // Resets of the process' counters so far
+	Instance         string `json:"instance,omitempty"`                // Tells restarted containers of a pod apart
+	// Counters of other processes of the binary found in the pusher's GOCOVERDIR, e.g. forked
+	// children or earlier containers of the pod
+	Counters []CountersFile `json:"counters,omitempty"`
+}
+
+// CountersFile is a counters file of another process in a PushRequest
+type CountersFile struct {
+	Filename string `json:"filename"`
+	Data     string `json:"data"`
+}
+
+// SourceInfo describes the latest snapshot received from one process of a pod
+type SourceInfo struct {
+	Service          string `json:"service,omitempty"`
+	PodName          string `json:"pod_name"`
+	Namespace        string `json:"namespace"`
+	CountersFilename string `json:"counters_filename"`
+	ResetGeneration  int64  `json:"reset_generation,omitempty"`
+	LastPush         string `json:"last_push"`
+}
+
+// Server receives pushed coverage snapshots and serves them per suite
+type Server struct {
+	dataDir     string
+	maxPushSize int64
+	mu          sync.Mutex
+	leadership  *Leadership
+}
+
+// NewServer creates a collector storing data under dataDir
+func NewServer(dataDir string) (*Server, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+	return &Server{dataDir: dataDir, maxPushSize: DefaultMaxPushSize}, nil
+}
+
+// SetMaxPushSize sets the largest push request body accepted, in bytes (default: 16 MiB). Larger
+// pushes are rejected with 413. Raise the collector's memory limit along with it.
+func (s *Server) SetMaxPushSize(bytes int64) {
+	s.maxPushSize = bytes
+}
+
+// SetLeadership attaches the leader election state reported by GET /leader
+func (s *Server) SetLeadership(leadership *Leadership) {
+	s.leadership = leadership
+}
+
+// Handler returns the HTTP handler exposing the collector API:
+//
+//	POST /push                       - receive a snapshot from an instrumented pod
+//	GET  /suites                     - list suites with their sources
+//	GET  /coverage/{suite}           - download all covdata files of a suite as tar.gz
+//	GET  /coverage/{suite}/{service} - download the covdata files of one service of a suite
+//	GET  /leader                     - whether this replica is the leader
+//	GET  /health                     - health check
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /push", s.handlePush)
+	mux.HandleFunc("GET /suites", s.handleSuites)
+	mux.HandleFunc("GET /coverage/{suite}", s.handleDownload)
+	mux.HandleFunc("GET /coverage/{suite}/{service}", s.handleDownload)
+	mux.HandleFunc("GET /leader", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"leader": s.leadership.IsLeader()})
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "coverage collector healthy")
+	})
+	return mux
+}
+
+// handlePush stores a pushed snapshot. The meta file is shared by all sources running the
+// same binary, and only the latest counters file of each source is kept, because counters
+// are cumulative and merging several snapshots of one process would double-count them. A
+// source whose counters were reset since its previous push keeps that push as well, as its
+// counters are gone from the process. The counters of other processes sent along are sources of
+// their own.
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var req PushRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxPushSize)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("push request larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("decode push request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Suite == "" {
+		req.Suite = "default"
+	}
+	if req.PodName == "" {
+		http.Error(w, "pod_name is required", http.StatusBadRequest)
+		return
+	}
+	for _, name := range []string{req.Suite, req.MetaFilename, req.CountersFilename} {
+		if !validName.MatchString(name) {
+			http.Error(w, fmt.Sprintf("invalid name: %q", name), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Service != "" && !validName.MatchString(req.Service) {
+		http.Error(w, fmt.Sprintf("invalid service name: %q", req.Service), http.StatusBadRequest)
+		return
+	}
+
+	metaData, err := base64.StdEncoding.DecodeString(req.MetaData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("decode metadata: %v", err), http.StatusBadRequest)
+		return
+	}
+	counterData, err := base64.StdEncoding.DecodeString(req.CountersData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("decode counters: %v", err), http.StatusBadRequest)
+		return
+	}
+	uploads := []countersUpload{{key: sourceKey(req), filename: req.CountersFilename, data: counterData, generation: req.ResetGeneration}}
+	for _, other := range req.Counters {
+		if !validName.MatchString(other.Filename) || !strings.HasPrefix(other.Filename, "covcounters.") {
+			http.Error(w, fmt.Sprintf("invalid counters filename: %q", other.Filename), http.StatusBadRequest)
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(other.Data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("decode counters %s: %v", other.Filename, err), http.StatusBadRequest)
+			return
+		}
+		// Keyed apart from the pushing process, which may share the PID of an earlier container
+		key := processKey(req.Namespace, req.PodName, other.Filename) + "/coverdir"
+		uploads = append(uploads, countersUpload{key: key, filename: other.Filename, data: data})
+	}
+
+	if err := s.store(req, metaData, uploads); err != nil {
+		log.Printf("[COLLECTOR] ERROR: Failed to store snapshot from %s/%s: %v", req.Namespace, req.PodName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[COLLECTOR] Stored snapshot from %s/%s (suite: %s, service: %s, %d bytes metadata, %d bytes counters, +%d counters files of other processes)",
+		req.Namespace, req.PodName, req.Suite, req.Service, len(metaData), len(counterData), len(req.Counters))
+	w.WriteHeader(http.StatusOK)
+}
+
+// countersUpload is a counters file of a push and the source it belongs to
+type countersUpload struct {
+	key        string
+	filename   string
+	data       []byte
+	generation int64
+}
+
+// store writes a snapshot to the suite directory (its service's subdirectory when named) and
+// replaces the previous counters of each source, unless they were reset since
+func (s *Server) store(req PushRequest, metaData []byte, uploads []countersUpload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	suiteDir := filepath.Join(s.dataDir, req.Suite, req.Service)
+	if err := os.MkdirAll(suiteDir, 0755); err != nil {
+		return fmt.Errorf("create suite directory: %w", err)
+	}
+
+	metaPath := filepath.Join(suiteDir, req.MetaFilename)
+	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
+		if err := writeFile(metaPath, metaData); err != nil {
+			return fmt.Errorf("write metadata file: %w", err)
+		}
+	}
+
+	sources, err := readIndex(suiteDir)
+	if err != nil {
+		return err
+	}
+
+	for _, upload := range uploads {
+		if err := writeFile(filepath.Join(suiteDir, upload.filename), upload.data); err != nil {
+			return fmt.Errorf("write counters file: %w", err)
+		}
+
+		previous, ok := sources[upload.key]
+		if ok && previous.CountersFilename != upload.filename && previous.ResetGeneration == upload.generation {
+			if err := os.Remove(filepath.Join(suiteDir, previous.CountersFilename)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove previous counters file: %w", err)
+			}
+		}
+		sources[upload.key] = SourceInfo{
+			Service:          req.Service,
+			PodName:          req.PodName,
+			Namespace:        req.Namespace,
+			CountersFilename: upload.filename,
+			ResetGeneration:  upload.generation,
+			LastPush:         time.Now().Format(time.RFC3339),
+		}
+	}
+
+	return writeIndex(suiteDir, sources)
+}
+
+// writeFile replaces path with data through a rename, so downloads still streaming the previous
+// file keep reading it whole
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// sourceKey identifies the pushing process. Restarted containers of a pod run the same binary
+// with the same PID, so the key includes the instance when the pusher sends one.
+func sourceKey(req PushRequest) string {
+	key := processKey(req.Namespace, req.PodName, req.CountersFilename)
+	if req.Instance != "" {
+		key += "/" + req.Instance
+	}
+	return key
+}
+
+// processKey identifies one process of a pod: several instrumented processes in a pod each push
+// their own cumulative counters (covcounters.<hash>.<pid>.<timestamp>), so they must not replace
+// each other
+func processKey(namespace, podName, countersFilename string) string {
+	key := namespace + "/" + podName
+	parts := strings.Split(countersFilename, ".")
+	if len(parts) == 4 && parts[0] == "covcounters" {
+		key += "/" + parts[1] + "." + parts[2]
+	}
+	return key
+}
+
+// handleSuites lists all suites and their sources
+func (s *Server) handleSuites(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dataDir)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read data directory: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	suites := make(map[string][]SourceInfo)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		list, err := suiteSources(filepath.Join(s.dataDir, entry.Name()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		suites[entry.Name()] = list
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suites)
+}
+
+// handleDownload streams all covdata files of a suite, or of one of its services, as a gzipped
+// tar archive. Services of a suite running the same binary share its meta-data file, which is
+// sent once. The files are opened under the lock and streamed without it, so a slow client
+// doesn't hold up pushes; files replaced meanwhile are sent as they were when opened.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	suite, service := r.PathValue("suite"), r.PathValue("service")
+	if !validName.MatchString(suite) {
+		http.Error(w, fmt.Sprintf("invalid suite name: %q", suite), http.StatusBadRequest)
+		return
+	}
+	if service != "" && !validName.MatchString(service) {
+		http.Error(w, fmt.Sprintf("invalid service name: %q", service), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	files, err := openCovdataFiles(filepath.Join(s.dataDir, suite, service), service == "")
+	s.mu.Unlock()
+	if os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("suite %q not found", path.Join(suite, service)), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read suite directory: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, f := range files {
+		if err := addFileToTar(tw, f); err != nil {
+			log.Printf("[COLLECTOR] ERROR: Failed to add %s to archive: %v", filepath.Base(f.Name()), err)
+			return
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		log.Printf("[COLLECTOR] ERROR: Failed to finalize archive: %v", err)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		log.Printf("[COLLECTOR] ERROR: Failed to finalize archive: %v", err)
+		return
+	}
+
+	log.Printf("[COLLECTOR] Served suite %s", path.Join(suite, service))
+}
+
+// covdataFiles lists the covdata files of a suite directory, and of its service subdirectories
+// when nested is set
+func covdataFiles(suiteDir string, nested bool) ([]string, error) {
+	entries, err := os.ReadDir(suiteDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && nested:
+			serviceFiles, err := covdataFiles(filepath.Join(suiteDir, entry.Name()), false)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, serviceFiles...)
+		case !entry.IsDir() && !strings.HasPrefix(entry.Name(), "."): // the index and unfinished uploads
+			files = append(files, filepath.Join(suiteDir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// openCovdataFiles opens the covdata files of a suite directory, and of its service
+// subdirectories when nested is set. Files of several services with the same name are opened
+// once.
+func openCovdataFiles(suiteDir string, nested bool) ([]*os.File, error) {
+	paths, err := covdataFiles(suiteDir, nested)
+	if err != nil {
+		return nil, err
+	}
+	var files []*os.File
+	opened := make(map[string]bool)
+	for _, p := range paths {
+		if opened[filepath.Base(p)] {
+			continue
+		}
+		opened[filepath.Base(p)] = true
+		f, err := os.Open(p)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, fmt.Errorf("open %s: %w", filepath.Base(p), err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// suiteSources lists the sources of a suite and of its services, by service and pod name
+func suiteSources(suiteDir string) ([]SourceInfo, error) {
+	sources, err := readIndex(suiteDir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]SourceInfo, 0, len(sources))
+	for _, source := range sources {
+		list = append(list, source)
+	}
+
+	entries, err := os.ReadDir(suiteDir)
+	if err != nil {
+		return nil, fmt.Errorf("read suite directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		serviceSources, err := readIndex(filepath.Join(suiteDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range serviceSources {
+			list = append(list, source)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Service != list[j].Service {
+			return list[i].Service < list[j].Service
+		}
+		return list[i].PodName < list[j].PodName
+	})
+	return list, nil
+}
+
+// addFileToTar writes a single open file into the tar archive under its base name
+func addFileToTar(tw *tar.Writer, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func readIndex(suiteDir string) (map[string]SourceInfo, error) {
+	sources := make(map[string]SourceInfo)
+	data, err := os.ReadFile(filepath.Join(suiteDir, indexFile))
+	if os.IsNotExist(err) {
+		return sources, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read source index: %w", err)
+	}
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("parse source index: %w", err)
+	}
+	return sources, nil
+}
+
+func writeIndex(suiteDir string, sources map[string]SourceInfo) error {
+	data, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal source index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(suiteDir, indexFile), data, 0644); err != nil {
+		return fmt.Errorf("write source index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package collector
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pushSnapshot(t *testing.T, url string, req PushRequest) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal push request: %v", err)
+	}
+	resp, err := http.Post(url+"/push", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func newPushRequest(pod, counters string) PushRequest {
+	return PushRequest{
+		MetaFilename:     "covmeta.abc",
+		MetaData:         base64.StdEncoding.EncodeToString([]byte("meta")),
+		CountersFilename: counters,
+		CountersData:     base64.StdEncoding.EncodeToString([]byte("counters " + counters)),
+		Suite:            "e2e",
+		PodName:          pod,
+		Namespace:        "demo",
+	}
+}
+
+func TestPushKeepsLatestCountersPerSource(t *testing.T) {
+	dataDir := t.TempDir()
+	server, err := NewServer(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	for _, req := range []PushRequest{
+		newPushRequest("pod-a", "covcounters.abc.1.100"),
+		newPushRequest("pod-a", "covcounters.abc.1.200"),
+		newPushRequest("pod-b", "covcounters.abc.1.150"),
+	} {
+		if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dataDir, "e2e"))
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	expected := []string{".sources.json", "covcounters.abc.1.150", "covcounters.abc.1.200", "covmeta.abc"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected files %v, got %v", expected, names)
+			break
+		}
+	}
+
+	// Download the suite archive
+	resp, err := http.Get(ts.URL + "/coverage/e2e")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var archived []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar stream: %v", err)
+		}
+		archived = append(archived, header.Name)
+	}
+	sort.Strings(archived)
+	if len(archived) != 3 || archived[2] != "covmeta.abc" {
+		t.Errorf("Unexpected archive contents: %v", archived)
+	}
+}
+
+func TestPushKeepsCountersPerProcess(t *testing.T) {
+	dataDir := t.TempDir()
+	server, err := NewServer(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	other := newPushRequest("pod-a", "covcounters.def.7.300")
+	other.MetaFilename = "covmeta.def"
+	for _, req := range []PushRequest{
+		newPushRequest("pod-a", "covcounters.abc.1.100"),
+		newPushRequest("pod-a", "covcounters.abc.2.100"),
+		other,
+		newPushRequest("pod-a", "covcounters.abc.2.200"),
+	} {
+		if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dataDir, "e2e", "cov*"))
+	var names []string
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	sort.Strings(names)
+	expected := []string{"covcounters.abc.1.100", "covcounters.abc.2.200", "covcounters.def.7.300", "covmeta.abc", "covmeta.def"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected files %v, got %v", expected, names)
+			break
+		}
+	}
+}
+
+func TestPushKeepsCountersBeforeReset(t *testing.T) {
+	dataDir := t.TempDir()
+	server, err := NewServer(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	afterReset := newPushRequest("pod-a", "covcounters.abc.1.200")
+	afterReset.ResetGeneration = 1
+	latest := newPushRequest("pod-a", "covcounters.abc.1.300")
+	latest.ResetGeneration = 1
+	for _, req := range []PushRequest{newPushRequest("pod-a", "covcounters.abc.1.100"), afterReset, latest} {
+		if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dataDir, "e2e", "covcounters.*"))
+	var names []string
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	sort.Strings(names)
+	expected := []string{"covcounters.abc.1.100", "covcounters.abc.1.300"}
+	if len(names) != len(expected) || names[0] != expected[0] || names[1] != expected[1] {
+		t.Errorf("Expected files %v, got %v", expected, names)
+	}
+}
+
+func TestPushKeepsCountersPerInstance(t *testing.T) {
+	dataDir := t.TempDir()
+	server, err := NewServer(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	push := func(instance, counters string, others ...string) {
+		t.Helper()
+		req := newPushRequest("pod-a", counters)
+		req.Instance = instance
+		for _, other := range others {
+			req.Counters = append(req.Counters, CountersFile{Filename: other, Data: base64.StdEncoding.EncodeToString([]byte(other))})
+		}
+		if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+	push("1", "covcounters.abc.1.100")
+	push("1", "covcounters.abc.1.200")
+	// The restarted container runs with the same PID, its child's counters come along
+	push("2", "covcounters.abc.1.300", "covcounters.abc.42.250")
+	push("2", "covcounters.abc.1.400", "covcounters.abc.42.350")
+
+	matches, _ := filepath.Glob(filepath.Join(dataDir, "e2e", "covcounters.*"))
+	var names []string
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	sort.Strings(names)
+	expected := []string{"covcounters.abc.1.200", "covcounters.abc.1.400", "covcounters.abc.42.350"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected files %v, got %v", expected, names)
+	}
+}
+
+func TestPushRejectsLargeAndInvalidCounters(t *testing.T) {
+	server, _ := NewServer(t.TempDir())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req := newPushRequest("pod-a", "covcounters.abc.1.1")
+	req.Counters = []CountersFile{{Filename: "../covcounters.abc.2.1", Data: ""}}
+	if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid counters filename, got %d", resp.StatusCode)
+	}
+
+	server.SetMaxPushSize(64)
+	if resp := pushSnapshot(t, ts.URL, newPushRequest("pod-a", "covcounters.abc.1.1")); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a push over the limit, got %d", resp.StatusCode)
+	}
+}
+
+func TestPushRejectsInvalidNames(t *testing.T) {
+	server, _ := NewServer(t.TempDir())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req := newPushRequest("pod-a", "../../etc/passwd")
+	if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for path traversal, got %d", resp.StatusCode)
+	}
+
+	req = newPushRequest("", "covcounters.abc.1.1")
+	if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing pod name, got %d", resp.StatusCode)
+	}
+}
+
+func TestDownloadUnknownSuite(t *testing.T) {
+	server, _ := NewServer(t.TempDir())
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/coverage/missing")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+}
+
+// stalledWriter is a ResponseWriter of a client that stops reading until released
+type stalledWriter struct {
+	header   http.Header
+	body     bytes.Buffer
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+func (w *stalledWriter) WriteHeader(int)     {}
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	if w.body.Len() == 0 {
+		close(w.started)
+		<-w.released
+	}
+	return w.body.Write(p)
+}
+
+func TestSlowDownloadDoesNotBlockPushes(t *testing.T) {
+	server, _ := NewServer(t.TempDir())
+	handler := server.Handler()
+	push := func(req PushRequest) int {
+		body, _ := json.Marshal(req)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/push", bytes.NewReader(body)))
+		return rec.Code
+	}
+	if code := push(newPushRequest("pod-a", "covcounters.abc.1.100")); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	w := &stalledWriter{header: http.Header{}, started: make(chan struct{}), released: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/coverage/e2e", nil))
+	}()
+	<-w.started
+
+	// Replaces and removes the counters file the download has opened
+	pushed := make(chan int)
+	go func() { pushed <- push(newPushRequest("pod-a", "covcounters.abc.1.200")) }()
+	select {
+	case code := <-pushed:
+		if code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Push blocked by a stalled download")
+	}
+	close(w.released)
+	<-done
+
+	gz, err := gzip.NewReader(&w.body)
+	if err != nil {
+		t.Fatalf("Invalid gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar stream: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+	if files["covcounters.abc.1.100"] != "counters covcounters.abc.1.100" || files["covmeta.abc"] != "meta" {
+		t.Errorf("Expected the files opened before the push, got %v", files)
+	}
+}
+
+// downloadNames returns the file names of a downloaded suite archive
+func downloadNames(t *testing.T, url string) []string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for %s, got %d", url, resp.StatusCode)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar stream: %v", err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPushPerService(t *testing.T) {
+	dataDir := t.TempDir()
+	server, _ := NewServer(dataDir)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	api := newPushRequest("api-a", "covcounters.abc.1.100")
+	api.Service = "api"
+	job := newPushRequest("migrate-x", "covcounters.abc.1.200")
+	job.Service = "migrate"
+	for _, req := range []PushRequest{api, job, newPushRequest("legacy", "covcounters.abc.1.300")} {
+		if resp := pushSnapshot(t, ts.URL, req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "e2e", "migrate", "covcounters.abc.1.200")); err != nil {
+		t.Errorf("Expected the job's counters in its service directory: %v", err)
+	}
+
+	// The suite holds every service, sharing the meta-data file
+	if names := downloadNames(t, ts.URL+"/coverage/e2e"); len(names) != 4 || names[3] != "covmeta.abc" {
+		t.Errorf("Unexpected suite archive contents: %v", names)
+	}
+	if names := downloadNames(t, ts.URL+"/coverage/e2e/migrate"); len(names) != 2 || names[0] != "covcounters.abc.1.200" {
+		t.Errorf("Unexpected service archive contents: %v", names)
+	}
+	resp, _ := http.Get(ts.URL + "/coverage/e2e/missing")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown service, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(ts.URL + "/suites")
+	var suites map[string][]SourceInfo
+	json.NewDecoder(resp.Body).Decode(&suites)
+	resp.Body.Close()
+	if sources := suites["e2e"]; len(sources) != 3 || sources[0].PodName != "legacy" || sources[1].Service != "api" || sources[2].Service != "migrate" {
+		t.Errorf("Expected the sources of every service, got %+v", sources)
+	}
+
+	bad := newPushRequest("pod-a", "covcounters.abc.1.1")
+	bad.Service = "../other"
+	if resp := pushSnapshot(t, ts.URL, bad); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid service, got %d", resp.StatusCode)
+	}
+}
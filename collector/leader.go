@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionConfig configures Lease-based leader election between collector replicas
+type LeaderElectionConfig struct {
+	LeaseName      string        // Name of the coordination.k8s.io Lease (default: coverage-collector)
+	LeaseNamespace string        // Namespace of the Lease (required)
+	Identity       string        // Identity of this replica (default: POD_NAME or hostname)
+	LeaseDuration  time.Duration // Default: 15s
+	RenewDeadline  time.Duration // Default: 10s
+	RetryPeriod    time.Duration // Default: 2s
+}
+
+// Leadership tracks whether this replica currently holds the collector Lease.
+// Leader-only work (scheduled collections, artifact pushes) should check IsLeader
+// or be started from the callback passed to RunLeaderElection. Pushed snapshots are
+// stored by whichever replica receives them, so leadership doesn't apply to them.
+type Leadership struct {
+	leader atomic.Bool
+}
+
+// IsLeader reports whether this replica is currently the leader
+func (l *Leadership) IsLeader() bool {
+	if l == nil {
+		// Without leader election every replica acts as leader
+		return true
+	}
+	return l.leader.Load()
+}
+
+// RunLeaderElection blocks, competing for the Lease until ctx is cancelled. Whenever this
+// replica becomes leader, onStartedLeading is called with a context that is cancelled
+// as soon as leadership is lost, so only one replica performs leader-only work at a time.
+func RunLeaderElection(ctx context.Context, clientset kubernetes.Interface, cfg LeaderElectionConfig, leadership *Leadership, onStartedLeading func(ctx context.Context)) error {
+	if cfg.LeaseNamespace == "" {
+		return fmt.Errorf("lease namespace is required for leader election")
+	}
+	if cfg.LeaseName == "" {
+		cfg.LeaseName = "coverage-collector"
+	}
+	if cfg.Identity == "" {
+		cfg.Identity = os.Getenv("POD_NAME")
+	}
+	if cfg.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("determine leader election identity: %w", err)
+		}
+		cfg.Identity = hostname
+	}
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = 15 * time.Second
+	}
+	if cfg.RenewDeadline == 0 {
+		cfg.RenewDeadline = 10 * time.Second
+	}
+	if cfg.RetryPeriod == 0 {
+		cfg.RetryPeriod = 2 * time.Second
+	}
+	if leadership == nil {
+		leadership = &Leadership{}
+	}
+
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, cfg.LeaseNamespace, cfg.LeaseName,
+		clientset.CoreV1(), clientset.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: cfg.Identity})
+	if err != nil {
+		return fmt.Errorf("create lease lock: %w", err)
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				leadership.leader.Store(true)
+				log.Printf("[COLLECTOR] %s acquired leadership (lease %s/%s)", cfg.Identity, cfg.LeaseNamespace, cfg.LeaseName)
+				if onStartedLeading != nil {
+					onStartedLeading(leaderCtx)
+				}
+			},
+			OnStoppedLeading: func() {
+				leadership.leader.Store(false)
+				log.Printf("[COLLECTOR] %s lost leadership", cfg.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != cfg.Identity {
+					log.Printf("[COLLECTOR] Current leader: %s", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create leader elector: %w", err)
+	}
+
+	// Run returns when leadership is lost; keep competing until the context is done
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
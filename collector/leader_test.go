@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeadershipNilIsLeader(t *testing.T) {
+	var leadership *Leadership
+	if !leadership.IsLeader() {
+		t.Error("Expected replica without leader election to act as leader")
+	}
+}
+
+func TestRunLeaderElection_SingleLeader(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := LeaderElectionConfig{
+		LeaseNamespace: "default",
+		LeaseDuration:  2 * time.Second,
+		RenewDeadline:  1 * time.Second,
+		RetryPeriod:    100 * time.Millisecond,
+	}
+
+	first, second := &Leadership{}, &Leadership{}
+	started := make(chan string, 2)
+
+	for _, replica := range []struct {
+		identity   string
+		leadership *Leadership
+	}{{"replica-1", first}, {"replica-2", second}} {
+		cfg := cfg
+		cfg.Identity = replica.identity
+		identity := replica.identity
+		go RunLeaderElection(ctx, clientset, cfg, replica.leadership, func(ctx context.Context) {
+			started <- identity
+			<-ctx.Done()
+		})
+		// Give the first replica a head start so it deterministically wins the lease
+		if replica.identity == "replica-1" {
+			select {
+			case <-started:
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timed out waiting for leadership")
+			}
+		}
+	}
+
+	// The second replica must not become leader while the first holds the lease
+	select {
+	case identity := <-started:
+		t.Fatalf("Unexpected second leader: %s", identity)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if !first.IsLeader() || second.IsLeader() {
+		t.Errorf("Expected only replica-1 to lead (first=%v, second=%v)", first.IsLeader(), second.IsLeader())
+	}
+}
+
+func TestRunLeaderElection_RequiresNamespace(t *testing.T) {
+	err := RunLeaderElection(context.Background(), fake.NewSimpleClientset(), LeaderElectionConfig{}, nil, nil)
+	if err == nil {
+		t.Error("Expected error without lease namespace")
+	}
+}
@@ -0,0 +1,164 @@
+// Package covcontainer starts coverage-instrumented application images with testcontainers-go and
+// collects their coverage over the mapped coverage port, for containerized integration tests on
+// machines without Kubernetes:
+//
+//	app, err := covcontainer.Run(ctx, "localhost/my-app:coverage")
+//	defer testcontainers.TerminateContainer(app)
+//	// ... run the tests against app ...
+//	result, err := app.Collect(ctx, client, "integration")
+//
+// It's a separate module so the main module doesn't depend on Docker libraries.
+package covcontainer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+// Backend is the name Register makes the Collector available under, see CollectOptions.Backend
+const Backend = "testcontainers"
+
+// coveragePortEnv configures the coverage server's port in the container
+const coveragePortEnv = "COVERAGE_PORT"
+
+// Container is a started application container serving coverage
+type Container struct {
+	testcontainers.Container
+	CoverageURL string // Coverage endpoint on the host, e.g. http://localhost:32768/coverage
+}
+
+// WithCoveragePort makes the coverage server listen on port instead of DefaultCoveragePort
+func WithCoveragePort(port int) testcontainers.CustomizeRequestOption {
+	return testcontainers.WithEnv(map[string]string{coveragePortEnv: strconv.Itoa(port)})
+}
+
+// Run starts image with its coverage port exposed and waits until the coverage server is healthy.
+// opts customize the container like in testcontainers-go modules. On error, the returned container
+// may be non-nil and should be terminated.
+func Run(ctx context.Context, image string, opts ...testcontainers.ContainerCustomizer) (*Container, error) {
+	req := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{Image: image},
+		Started:          true,
+	}
+	for _, opt := range opts {
+		if err := opt.Customize(&req); err != nil {
+			return nil, fmt.Errorf("customize container: %w", err)
+		}
+	}
+	port, err := coveragePort(req.Env)
+	if err != nil {
+		return nil, err
+	}
+	if !exposes(req.ExposedPorts, port) {
+		req.ExposedPorts = append(req.ExposedPorts, string(port))
+	}
+	if req.WaitingFor == nil {
+		req.WaitingFor = wait.ForHTTP("/health").WithPort(port)
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, req)
+	var c *Container
+	if container != nil {
+		c = &Container{Container: container}
+	}
+	if err != nil {
+		return c, fmt.Errorf("start container %s: %w", image, err)
+	}
+
+	endpoint, err := container.PortEndpoint(ctx, port, "http")
+	if err != nil {
+		return c, fmt.Errorf("resolve coverage port: %w", err)
+	}
+	c.CoverageURL = endpoint + "/coverage"
+	return c, nil
+}
+
+// Collect collects the container's coverage into client's test directory for testName
+func (c *Container) Collect(ctx context.Context, client *coverageclient.CoverageClient, testName string) (*coverageclient.CollectResult, error) {
+	return client.Collect(ctx, coverageclient.CollectOptions{TestName: testName, URL: c.CoverageURL})
+}
+
+// Collector collects from containers started by Run (target: container ID, or name without the
+// leading '/')
+type Collector struct {
+	Client *coverageclient.CoverageClient
+
+	mu         sync.Mutex
+	containers map[string]*Container
+}
+
+// Register makes a Collector of containers available to client.Collect as Backend and returns it,
+// e.g. for CollectOptions{Backend: covcontainer.Backend, Target: app.GetContainerID()}
+func Register(client *coverageclient.CoverageClient, containers ...*Container) *Collector {
+	collector := &Collector{Client: client}
+	for _, container := range containers {
+		collector.Add(container)
+	}
+	client.RegisterCollector(Backend, collector)
+	return collector
+}
+
+// Add makes container collectable by ID
+func (c *Collector) Add(container *Container) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.containers == nil {
+		c.containers = make(map[string]*Container)
+	}
+	c.containers[container.GetContainerID()] = container
+}
+
+func (c *Collector) Collect(ctx context.Context, target, testName string) (*coverageclient.CollectResult, error) {
+	container, err := c.lookup(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	collector := coverageclient.URLCollector{Client: c.Client}
+	return collector.Collect(ctx, container.CoverageURL, testName)
+}
+
+// lookup returns the container with ID or name target
+func (c *Collector) lookup(ctx context.Context, target string) (*Container, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if container, ok := c.containers[target]; ok {
+		return container, nil
+	}
+	for _, container := range c.containers {
+		if name, err := container.Name(ctx); err == nil && (name == target || name == "/"+target) {
+			return container, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown container: %s", target)
+}
+
+// coveragePort returns the container port the coverage server listens on, following the
+// COVERAGE_PORT environment variable of the container
+func coveragePort(env map[string]string) (nat.Port, error) {
+	port := coverageclient.DefaultCoveragePort
+	if value, ok := env[coveragePortEnv]; ok {
+		var err error
+		if port, err = strconv.Atoi(value); err != nil || port <= 0 || port > 65535 {
+			return "", fmt.Errorf("invalid %s: %q", coveragePortEnv, value)
+		}
+	}
+	return nat.Port(strconv.Itoa(port) + "/tcp"), nil
+}
+
+// exposes reports whether ports (e.g. "9095", "9095/tcp" or "0.0.0.0:9095:9095/tcp") expose port
+func exposes(ports []string, port nat.Port) bool {
+	exposed, _, err := nat.ParsePortSpecs(ports)
+	if err != nil {
+		return false
+	}
+	_, ok := exposed[port]
+	return ok
+}
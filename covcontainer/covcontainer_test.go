@@ -0,0 +1,38 @@
+package covcontainer
+
+import (
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+)
+
+func TestCoveragePort(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		want    nat.Port
+		wantErr bool
+	}{
+		{nil, "9095/tcp", false},
+		{map[string]string{"COVERAGE_PORT": "9100"}, "9100/tcp", false},
+		{map[string]string{"COVERAGE_PORT": "http"}, "", true},
+		{map[string]string{"COVERAGE_PORT": "70000"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := coveragePort(tt.env)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("coveragePort(%v) = %q, %v", tt.env, got, err)
+		}
+	}
+}
+
+func TestExposes(t *testing.T) {
+	if !exposes([]string{"8080/tcp", "9095"}, "9095/tcp") {
+		t.Error("Expected 9095 to be exposed")
+	}
+	if !exposes([]string{"0.0.0.0:9095:9095/tcp"}, "9095/tcp") {
+		t.Error("Expected a bound 9095 to be exposed")
+	}
+	if exposes([]string{"8080/tcp"}, "9095/tcp") {
+		t.Error("Expected 9095 not to be exposed")
+	}
+}
@@ -0,0 +1,308 @@
+// Package scaffold instruments an existing Go application for coverage collection: Init adds the
+// coverage server to its main package behind a build tag and generates the Dockerfile snippet and
+// the Kustomize component that expose the coverage port. The coverage-http init command wraps it.
+package scaffold
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	coverageclient "github.com/psturc/go-coverage-http/client"
+)
+
+//go:generate cp ../server/coverage_server.go coverage_server.go.txt
+
+// serverSource is server/coverage_server.go, injected into the target's main package
+//
+//go:embed coverage_server.go.txt
+var serverSource []byte
+
+const (
+	// DefaultBuildTag is the build tag the injected coverage server is compiled with
+	DefaultBuildTag = "coverage"
+	// DefaultOutputDir is where the Dockerfile snippet and the Kustomize component are written
+	DefaultOutputDir = "coverage"
+	// ServerFile is the name of the coverage server in the main package
+	ServerFile = "coverage_server.go"
+)
+
+// ErrMultipleMainPackages is returned when the module has several main packages and Options names none
+var ErrMultipleMainPackages = errors.New("several main packages")
+
+// Options configures Init
+type Options struct {
+	Dir         string // Root of the target Go module (default: current directory)
+	MainPackage string // Directory of the main package, relative to Dir (default: the only main package)
+	Deployment  string // Deployment in the Kustomize patch (default: the main package's directory or module name)
+	Container   string // Container in the Kustomize patch (default: Deployment)
+	Port        int    // Coverage port (default: coverageclient.DefaultCoveragePort)
+	BuildTag    string // Build tag of the coverage server (default: DefaultBuildTag)
+	OutputDir   string // Directory for the snippets, relative to Dir (default: DefaultOutputDir)
+	Force       bool   // Overwrite files generated earlier
+}
+
+// Result describes what Init generated
+type Result struct {
+	Module      string   // Module path of the target
+	MainPackage string   // Directory of the instrumented main package, relative to Dir
+	Files       []string // Written files, relative to Dir
+	BuildFlags  string   // go build flags of coverage builds, e.g. "-tags coverage -cover -covermode=atomic"
+}
+
+// Init instruments the module in opts.Dir: it writes the coverage server (compiled only with the
+// build tag) into the main package, and a Dockerfile snippet and a Kustomize component (patch
+// adding the coverage port, env vars and GOCOVERDIR volume) into opts.OutputDir
+func Init(opts Options) (*Result, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	module, err := modulePath(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MainPackage == "" {
+		mains, err := FindMainPackages(opts.Dir)
+		if err != nil {
+			return nil, err
+		}
+		switch len(mains) {
+		case 0:
+			return nil, fmt.Errorf("no main package in %s", opts.Dir)
+		case 1:
+			opts.MainPackage = mains[0]
+		default:
+			return nil, fmt.Errorf("%w in %s (%s), choose one", ErrMultipleMainPackages, opts.Dir, strings.Join(mains, ", "))
+		}
+	} else if ok, err := isMainPackage(filepath.Join(opts.Dir, opts.MainPackage)); err != nil || !ok {
+		return nil, fmt.Errorf("%s is not a main package (%v)", opts.MainPackage, err)
+	}
+	opts.MainPackage = filepath.ToSlash(filepath.Clean(opts.MainPackage))
+	opts = withDefaults(opts, module)
+
+	result := &Result{
+		Module:      module,
+		MainPackage: opts.MainPackage,
+		BuildFlags:  fmt.Sprintf("-tags %s -cover -covermode=atomic", opts.BuildTag),
+	}
+	data := templateData{Options: opts, Module: module, BuildFlags: result.BuildFlags, Annotations: annotations}
+	files := []struct {
+		name    string
+		content func() ([]byte, error)
+	}{
+		{path.Join(opts.MainPackage, ServerFile), func() ([]byte, error) { return serverFile(opts.BuildTag), nil }},
+		{path.Join(opts.OutputDir, "Dockerfile.snippet"), func() ([]byte, error) { return render(dockerfileTemplate, data) }},
+		{path.Join(opts.OutputDir, "kustomization.yaml"), func() ([]byte, error) { return render(kustomizationTemplate, data) }},
+		{path.Join(opts.OutputDir, "coverage-patch.yaml"), func() ([]byte, error) { return render(patchTemplate, data) }},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(opts.Dir, filepath.FromSlash(file.name))); err == nil && !opts.Force {
+			return nil, fmt.Errorf("%s already exists, overwrite it with Force", file.name)
+		}
+	}
+	for _, file := range files {
+		target := filepath.Join(opts.Dir, filepath.FromSlash(file.name))
+		content, err := file.content()
+		if err != nil {
+			return nil, fmt.Errorf("render %s: %w", file.name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("create directory: %w", err)
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", file.name, err)
+		}
+		result.Files = append(result.Files, file.name)
+	}
+	return result, nil
+}
+
+func withDefaults(opts Options, module string) Options {
+	if opts.Deployment == "" {
+		opts.Deployment = path.Base(module)
+		if opts.MainPackage != "." {
+			opts.Deployment = path.Base(opts.MainPackage)
+		}
+	}
+	if opts.Container == "" {
+		opts.Container = opts.Deployment
+	}
+	if opts.Port == 0 {
+		opts.Port = coverageclient.DefaultCoveragePort
+	}
+	if opts.BuildTag == "" {
+		opts.BuildTag = DefaultBuildTag
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = DefaultOutputDir
+	}
+	opts.OutputDir = filepath.ToSlash(filepath.Clean(opts.OutputDir))
+	return opts
+}
+
+// FindMainPackages returns the directories of the module in dir (relative, with '/') whose
+// package defines func main, skipping vendor, testdata, hidden directories and nested modules
+func FindMainPackages(dir string) ([]string, error) {
+	var mains []string
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if p != dir {
+			name := entry.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		ok, err := isMainPackage(p)
+		if err != nil {
+			return err
+		}
+		if ok {
+			rel, _ := filepath.Rel(dir, p)
+			mains = append(mains, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find main packages: %w", err)
+	}
+	sort.Strings(mains)
+	return mains, nil
+}
+
+// isMainPackage reports whether the Go files in dir define func main in package main
+func isMainPackage(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == ServerFile {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return false, fmt.Errorf("parse %s: %w", name, err)
+		}
+		if file.Name.Name != "main" {
+			return false, nil
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "main" && fn.Recv == nil {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// modulePath returns the module path declared in dir/go.mod
+func modulePath(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("read go.mod: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module directive in %s", filepath.Join(dir, "go.mod"))
+}
+
+// serverFile returns the coverage server source, compiled only with buildTag
+func serverFile(buildTag string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "//go:build %s\n\n", buildTag)
+	b.WriteString("// Code generated by coverage-http init from github.com/psturc/go-coverage-http/server. DO NOT EDIT.\n\n")
+	b.Write(serverSource)
+	return b.Bytes()
+}
+
+// annotations are the pod annotations DiscoverInstrumentedPods looks for
+var annotations = struct{ Port, Container string }{coverageclient.AnnotationPort, coverageclient.AnnotationContainer}
+
+type templateData struct {
+	Options
+	Module      string
+	BuildFlags  string
+	Annotations struct{ Port, Container string }
+}
+
+func render(tmpl *template.Template, data templateData) ([]byte, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+var dockerfileTemplate = template.Must(template.New("Dockerfile.snippet").Parse(`# Coverage build of {{.Module}}, generated by coverage-http init.
+# Replace the go build step of your Dockerfile; build test images with --build-arg ENABLE_COVERAGE=true.
+ARG ENABLE_COVERAGE=false
+RUN if [ "$ENABLE_COVERAGE" = "true" ]; then \
+        CGO_ENABLED=0 go build {{.BuildFlags}} -o /app/app ./{{.MainPackage}}; \
+    else \
+        CGO_ENABLED=0 go build -o /app/app ./{{.MainPackage}}; \
+    fi
+# The coverage server listens on {{.Port}}
+EXPOSE {{.Port}}
+`))
+
+var kustomizationTemplate = template.Must(template.New("kustomization.yaml").Parse(`# Enables the coverage server of {{.Module}}, generated by coverage-http init.
+# Add this directory to the components of your test overlay.
+apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+patches:
+- path: coverage-patch.yaml
+`))
+
+var patchTemplate = template.Must(template.New("coverage-patch.yaml").Parse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Deployment}}
+spec:
+  template:
+    metadata:
+      annotations:
+        {{.Annotations.Port}}: "{{.Port}}"
+        {{.Annotations.Container}}: {{.Container}}
+    spec:
+      containers:
+      - name: {{.Container}}
+        env:
+        - name: COVERAGE_PORT
+          value: "{{.Port}}"
+        - name: GOCOVERDIR
+          value: /tmp/coverage
+        ports:
+        - name: coverage
+          containerPort: {{.Port}}
+          protocol: TCP
+        volumeMounts:
+        - name: coverage-data
+          mountPath: /tmp/coverage
+      volumes:
+      - name: coverage-data
+        emptyDir: {}
+`))
@@ -0,0 +1,114 @@
+package scaffold
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModule creates a module with the given files
+func writeModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestServerSourceUpToDate(t *testing.T) {
+	source, err := os.ReadFile("../server/coverage_server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, serverSource) {
+		t.Error("coverage_server.go.txt is outdated, run go generate ./scaffold")
+	}
+}
+
+func TestFindMainPackages(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":                  "module github.com/org/app\n",
+		"cmd/app/main.go":         "package main\n\nfunc main() {}\n",
+		"cmd/app/main_test.go":    "package main\n",
+		"internal/lib/lib.go":     "package lib\n\nfunc main() {}\n",
+		"tools/gen/gen.go":        "package main\n\nfunc helper() {}\n",
+		"vendor/x/main.go":        "package main\n\nfunc main() {}\n",
+		"examples/demo/go.mod":    "module demo\n",
+		"examples/demo/main.go":   "package main\n\nfunc main() {}\n",
+		"cmd/worker/worker.go":    "package main\n\ntype worker struct{}\n\nfunc (worker) main() {}\n",
+		"cmd/worker/run_main.go":  "package main\n\nfunc main() {}\n",
+		"testdata/broken/main.go": "package main\nfunc main( {\n",
+	})
+	mains, err := FindMainPackages(dir)
+	if err != nil {
+		t.Fatalf("FindMainPackages failed: %v", err)
+	}
+	if strings.Join(mains, ",") != "cmd/app,cmd/worker" {
+		t.Errorf("Unexpected main packages %v", mains)
+	}
+}
+
+func TestInit(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":          "module github.com/org/app\n\ngo 1.24\n",
+		"cmd/api/main.go": "package main\n\nfunc main() {}\n",
+	})
+	result, err := Init(Options{Dir: dir, Port: 9100})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if result.Module != "github.com/org/app" || result.MainPackage != "cmd/api" || len(result.Files) != 4 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	server, _ := os.ReadFile(filepath.Join(dir, "cmd/api/coverage_server.go"))
+	if !strings.HasPrefix(string(server), "//go:build coverage\n\n// Code generated") || !strings.Contains(string(server), "package main") {
+		t.Errorf("Expected the coverage server behind the build tag, got %.100q", server)
+	}
+	dockerfile, _ := os.ReadFile(filepath.Join(dir, "coverage/Dockerfile.snippet"))
+	if !strings.Contains(string(dockerfile), "go build -tags coverage -cover -covermode=atomic -o /app/app ./cmd/api") {
+		t.Errorf("Unexpected Dockerfile snippet:\n%s", dockerfile)
+	}
+	patch, _ := os.ReadFile(filepath.Join(dir, "coverage/coverage-patch.yaml"))
+	for _, want := range []string{"name: api", "containerPort: 9100", `value: "9100"`, `coverage.psturc.io/port: "9100"`} {
+		if !strings.Contains(string(patch), want) {
+			t.Errorf("Expected %q in the patch:\n%s", want, patch)
+		}
+	}
+
+	// Generated files are only overwritten with Force
+	if _, err := Init(Options{Dir: dir}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected existing files to fail, got %v", err)
+	}
+	if _, err := Init(Options{Dir: dir, Force: true}); err != nil {
+		t.Errorf("Expected Force to overwrite, got %v", err)
+	}
+}
+
+func TestInit_Errors(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":        "module github.com/org/app\n",
+		"cmd/a/main.go": "package main\n\nfunc main() {}\n",
+		"cmd/b/main.go": "package main\n\nfunc main() {}\n",
+		"lib/lib.go":    "package lib\n",
+	})
+	if _, err := Init(Options{Dir: dir}); !errors.Is(err, ErrMultipleMainPackages) || !strings.Contains(err.Error(), "cmd/a, cmd/b") {
+		t.Errorf("Expected ErrMultipleMainPackages listing the candidates, got %v", err)
+	}
+	if _, err := Init(Options{Dir: dir, MainPackage: "lib"}); err == nil {
+		t.Error("Expected a library package to be rejected")
+	}
+	if result, err := Init(Options{Dir: dir, MainPackage: "./cmd/b/"}); err != nil || result.MainPackage != "cmd/b" {
+		t.Errorf("Expected the chosen main package, got %+v (%v)", result, err)
+	}
+	if _, err := Init(Options{Dir: t.TempDir()}); err == nil {
+		t.Error("Expected a directory without go.mod to fail")
+	}
+}
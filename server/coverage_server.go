@@ -1,14 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"runtime/coverage"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,9 +40,56 @@ type CoverageResponse struct {
 	Timestamp        int64  `json:"timestamp"`
 }
 
+// CoverageStreamType is the media type of streamed coverage responses: one part per coverage file,
+// named by its Content-Disposition filename. Clients asking for it via the Accept header receive the
+// raw meta-data and counters without base64 encoding or buffering.
+const CoverageStreamType = "multipart/mixed"
+
+// CoverageDeltaType is the media type of streamed counters sent as changes against counters the
+// client received earlier, see writeCountersDelta
+const CoverageDeltaType = "application/x-coverage-delta"
+
+// Headers of incremental counter transfers. Clients list the snapshots whose counters they hold in
+// the request's X-Coverage-Base header; the counters part names its snapshot and SHA-256 digest,
+// and for deltas the base snapshot they apply to.
+const (
+	coverageBaseHeader     = "X-Coverage-Base"
+	coverageSnapshotHeader = "X-Coverage-Snapshot"
+	coverageDigestHeader   = "X-Coverage-Digest"
+)
+
+// countersOnlyParam is the query parameter listing the meta-data hashes a client holds,
+// comma-separated; streamed responses of a binary with one of them leave out the meta-data
+const countersOnlyParam = "counters-only"
+
+// resetParam is the query parameter (reset=true) asking to clear the counters once the snapshot is
+// taken, so the next snapshot only covers what ran in between. Responses confirm it with the
+// X-Coverage-Reset header; binaries built with -covermode=set can't clear their counters.
+const (
+	resetParam          = "reset"
+	coverageResetHeader = "X-Coverage-Reset"
+)
+
+// notInstrumentedCode is the machine-readable error of the 501 response sent when the binary wasn't
+// built with -cover: {"error":"not_instrumented","message":"..."}
+const notInstrumentedCode = "not_instrumented"
+
+// errNotInstrumented is returned when the runtime has no coverage meta-data to write
+var errNotInstrumented = errors.New("binary not built with -cover")
+
 func init() {
 	// Start coverage server in a separate goroutine
 	go startCoverageServer()
+
+	// Push coverage to an in-cluster collector if configured
+	if collectorURL := os.Getenv("COVERAGE_PUSH_URL"); collectorURL != "" {
+		go startCoveragePusher(collectorURL)
+	}
+
+	// Periodically persist counters to GOCOVERDIR (e.g. a PVC) so they survive crashes
+	if coverDir := os.Getenv("GOCOVERDIR"); coverDir != "" && os.Getenv("COVERAGE_FLUSH_INTERVAL") != "" {
+		go startCoverageFlusher(coverDir, os.Getenv("COVERAGE_FLUSH_INTERVAL"))
+	}
 }
 
 // startCoverageServer starts a dedicated HTTP server for coverage collection
@@ -37,14 +103,14 @@ func startCoverageServer() {
 	// Create a new ServeMux for the coverage server (isolated from main app)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/coverage", CoverageHandler)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "coverage server healthy")
-	})
+	mux.HandleFunc("/coverage/reset", ResetHandler)
+	mux.HandleFunc("/coverage/flush", FlushHandler)
+	mux.HandleFunc("/coverage/info", InfoHandler)
+	mux.HandleFunc("/health", HealthHandler)
 
 	addr := ":" + coveragePort
 	log.Printf("[COVERAGE] Starting coverage server on %s", addr)
-	log.Printf("[COVERAGE] Endpoints: GET %s/coverage, GET %s/health", addr, addr)
+	log.Printf("[COVERAGE] Endpoints: GET %s/coverage, POST %s/coverage/reset, GET %s/coverage/flush, GET %s/coverage/info, GET %s/health", addr, addr, addr, addr, addr)
 
 	// Start the server (this will block, but we're in a goroutine)
 	if err := http.ListenAndServe(addr, mux); err != nil {
@@ -52,58 +118,1077 @@ func startCoverageServer() {
 	}
 }
 
-// CoverageHandler collects coverage data and returns it via HTTP as JSON
+// serverStart is when the coverage server started, for /coverage/info
+var serverStart = time.Now()
+
+// snapshotsServed counts the snapshots sent by /coverage, for /coverage/info
+var snapshotsServed atomic.Int64
+
+// serverInfo is the /coverage/info response
+type serverInfo struct {
+	GoVersion       string  `json:"go_version"`
+	ModulePath      string  `json:"module_path"`
+	VCSRevision     string  `json:"vcs_revision,omitempty"`
+	VCSTime         string  `json:"vcs_time,omitempty"`
+	VCSModified     bool    `json:"vcs_modified,omitempty"`
+	BuildTime       string  `json:"build_time,omitempty"`
+	CoverEnabled    bool    `json:"cover_enabled"`
+	CoverDir        string  `json:"gocoverdir,omitempty"`
+	StartedAt       string  `json:"started_at"`
+	UptimeSeconds   float64 `json:"uptime_seconds"`
+	SnapshotsServed int64   `json:"snapshots_served"`
+}
+
+// InfoHandler describes the binary serving coverage: its Go version, main module and VCS revision
+// (from the build info), build time, whether it was built with -cover, its GOCOVERDIR, uptime and
+// the number of snapshots /coverage sent
+func InfoHandler(w http.ResponseWriter, r *http.Request) {
+	info := serverInfo{
+		GoVersion:       runtime.Version(),
+		CoverEnabled:    coverEnabled(),
+		CoverDir:        os.Getenv("GOCOVERDIR"),
+		StartedAt:       serverStart.UTC().Format(time.RFC3339),
+		UptimeSeconds:   time.Since(serverStart).Round(time.Millisecond).Seconds(),
+		SnapshotsServed: snapshotsServed.Load(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.ModulePath = build.Main.Path
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.VCSRevision = setting.Value
+			case "vcs.time":
+				info.VCSTime = setting.Value
+			case "vcs.modified":
+				info.VCSModified = setting.Value == "true"
+			}
+		}
+	}
+	info.BuildTime = buildTime()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// buildTime returns the modification time of the executable (RFC 3339), which copying it into an
+// image usually keeps from the build that linked it, or "" when it can't be read
+var buildTime = sync.OnceValue(func() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	stat, err := os.Stat(exe)
+	if err != nil {
+		return ""
+	}
+	return stat.ModTime().UTC().Format(time.RFC3339)
+})
+
+// coverEnabled reports whether the binary was built with -cover
+func coverEnabled() bool {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	return coverage.WriteMeta(io.Discard) == nil
+}
+
+// Headers of /health responses identifying the container this process runs in, so clients can
+// tell which container of a pod serves coverage without exec (pod containers share the network)
+const (
+	coverageContainerHeader   = "X-Coverage-Container"
+	coverageContainerIDHeader = "X-Coverage-Container-ID"
+)
+
+// HealthHandler reports that the coverage server is up, naming its container in the
+// X-Coverage-Container and X-Coverage-Container-ID headers when they're known
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	info := coverageContainer()
+	if info.name != "" {
+		w.Header().Set(coverageContainerHeader, info.name)
+	}
+	if info.id != "" {
+		w.Header().Set(coverageContainerIDHeader, info.id)
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "coverage server healthy")
+}
+
+// containerInfo names the container of this process: the Kubernetes container name and the
+// runtime's container ID, either may be empty
+type containerInfo struct {
+	name string
+	id   string
+}
+
+// coverageContainer reads the container of this process from /proc once
+var coverageContainer = sync.OnceValue(func() containerInfo {
+	var info containerInfo
+	if f, err := os.Open("/proc/self/mountinfo"); err == nil {
+		info.name, info.id = parseContainerMountinfo(f)
+		f.Close()
+	}
+	if f, err := os.Open("/proc/self/cgroup"); err == nil {
+		if id := parseContainerCgroup(f); id != "" {
+			info.id = id
+		}
+		f.Close()
+	}
+	return info
+})
+
+// parseContainerMountinfo finds the container name in the kubelet's per-container mounts, e.g. the
+// termination log from /var/lib/kubelet/pods/<uid>/containers/<name>/<id>, and a container ID in
+// Docker's per-container mounts (/var/lib/docker/containers/<id>/hostname)
+func parseContainerMountinfo(r io.Reader) (name, id string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		root := fields[3] // Path of the mount within its file system
+		if name == "" {
+			if _, rest, ok := strings.Cut(root, "/pods/"); ok {
+				if parts := strings.Split(rest, "/"); len(parts) >= 3 && parts[1] == "containers" {
+					name = parts[2]
+				}
+			}
+		}
+		if id == "" {
+			if _, rest, ok := strings.Cut(root, "/containers/"); ok {
+				if candidate, _, _ := strings.Cut(rest, "/"); isContainerID(candidate) {
+					id = candidate
+				}
+			}
+		}
+	}
+	return name, id
+}
+
+// parseContainerCgroup returns the container ID in the cgroup paths of /proc/self/cgroup, e.g.
+// ".../cri-containerd-<id>.scope", ".../crio-<id>.scope" or "/kubepods/.../<id>" ("" with a private
+// cgroup namespace, where the path is "/")
+func parseContainerCgroup(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		last := line[strings.LastIndex(line, "/")+1:]
+		last = strings.TrimSuffix(last, ".scope")
+		if i := strings.LastIndexAny(last, "-:"); i >= 0 {
+			last = last[i+1:]
+		}
+		if isContainerID(last) {
+			return last
+		}
+	}
+	return ""
+}
+
+// isContainerID reports whether s looks like a container ID: 64 lowercase hex digits
+func isContainerID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// CoverageHandler collects coverage data and returns it via HTTP, streamed as multipart when the
+// client accepts CoverageStreamType and as JSON otherwise. Simultaneous requests share one snapshot.
 func CoverageHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("[COVERAGE] Collecting coverage data...")
 
-	// Collect metadata
-	var metaBuf bytes.Buffer
-	if err := coverage.WriteMeta(&metaBuf); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to collect metadata: %v", err), http.StatusInternalServerError)
+	snapshot, err := snapshots.Do(takeCoverageSnapshot)
+	if errors.Is(err, errNotInstrumented) {
+		log.Printf("[COVERAGE] ERROR: %v", err)
+		writeNotInstrumented(w, err)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer snapshot.Close()
+
+	if r.URL.Query().Get(resetParam) == "true" {
+		if err := resetCounters(); err != nil {
+			log.Printf("[COVERAGE] WARNING: Counters not reset: %v", err)
+		} else {
+			w.Header().Set(coverageResetHeader, "true")
+			log.Println("[COVERAGE] Counters reset")
+		}
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), CoverageStreamType) {
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", CoverageStreamType+"; boundary="+mw.Boundary())
+		withMeta := !holdsMeta(r, metaHash(snapshot.meta.head))
+		err = snapshot.WriteMultipart(mw, counterHistory.find(r.Header.Get(coverageBaseHeader)), withMeta)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = snapshot.WriteJSON(w, nil)
+	}
+	if err != nil {
+		// The status is sent already; clients detect the truncated response
+		log.Printf("[COVERAGE] Error sending coverage data: %v", err)
+		return
+	}
+
+	snapshotsServed.Add(1)
+	log.Println("[COVERAGE] Coverage data sent successfully")
+}
+
+// writeNotInstrumented answers 501 with a JSON error naming notInstrumentedCode, so clients can
+// tell a binary without coverage from a failing one
+func writeNotInstrumented(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotImplemented)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   notInstrumentedCode,
+		"message": err.Error() + ": rebuild the application with go build -cover",
+	})
+}
+
+// holdsMeta reports whether the request lists the meta-data hash in countersOnlyParam
+func holdsMeta(r *http.Request, hash string) bool {
+	for _, held := range strings.Split(r.URL.Query().Get(countersOnlyParam), ",") {
+		if strings.TrimSpace(held) == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetHandler clears the counters of this process without sending them, so the next snapshot only
+// covers what ran afterwards. Binaries built with -covermode=set can't clear their counters.
+func ResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	metaData := metaBuf.Bytes()
 
-	// Collect counters
-	var counterBuf bytes.Buffer
-	if err := coverage.WriteCounters(&counterBuf); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to collect counters: %v", err), http.StatusInternalServerError)
+	if err := resetCounters(); err != nil {
+		log.Printf("[COVERAGE] WARNING: Counters not reset: %v", err)
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
-	counterData := counterBuf.Bytes()
+	w.Header().Set(coverageResetHeader, "true")
+	log.Println("[COVERAGE] Counters reset")
+	fmt.Fprintf(w, "coverage counters reset")
+}
+
+// Query parameters of /coverage/flush: the collector to push to and the suite to push for
+const (
+	flushTargetParam = "target"
+	flushSuiteParam  = "suite"
+)
+
+// FlushHandler sends this process' counters out before it's terminated, meant for a preStop hook
+// (httpGet on /coverage/flush?target=<collector URL>). It pushes a snapshot to the target
+// (default: COVERAGE_PUSH_URL), e.g. a test runner listening for coverage, and writes the counters
+// to GOCOVERDIR when set. The response is sent once both are done, so the kubelet only signals the
+// process afterwards. Targets other than COVERAGE_PUSH_URL and those listed in
+// COVERAGE_FLUSH_TARGETS are refused, so callers can't make the server send requests elsewhere.
+func FlushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	target := query.Get(flushTargetParam)
+	if target == "" {
+		target = os.Getenv("COVERAGE_PUSH_URL")
+	} else if parsed, err := url.Parse(target); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		http.Error(w, fmt.Sprintf("invalid %s %q: expected an http(s) URL", flushTargetParam, target), http.StatusBadRequest)
+		return
+	} else if !flushTargetAllowed(target) {
+		log.Printf("[COVERAGE] WARNING: Refused flush to %s: not COVERAGE_PUSH_URL or in COVERAGE_FLUSH_TARGETS", target)
+		http.Error(w, fmt.Sprintf("%s %q not allowed: set COVERAGE_PUSH_URL or list it in COVERAGE_FLUSH_TARGETS", flushTargetParam, target), http.StatusBadRequest)
+		return
+	}
+	coverDir := os.Getenv("GOCOVERDIR")
+	if target == "" && coverDir == "" {
+		http.Error(w, "nothing to flush: no target, COVERAGE_PUSH_URL or GOCOVERDIR", http.StatusBadRequest)
+		return
+	}
+
+	var failed []string
+	if coverDir != "" {
+		if err := flusher.Flush(coverDir); err != nil {
+			failed = append(failed, fmt.Sprintf("flush to %s: %v", coverDir, err))
+		} else {
+			log.Printf("[COVERAGE] Flushed coverage counters to %s", coverDir)
+		}
+	}
+	if target != "" {
+		suite := query.Get(flushSuiteParam)
+		if suite == "" {
+			suite = coverageSuite()
+		}
+		if err := pushCoverage(target, suite); err != nil {
+			failed = append(failed, fmt.Sprintf("push to %s: %v", target, err))
+		}
+	}
+	if len(failed) > 0 {
+		log.Printf("[COVERAGE] ERROR: Flush failed: %s", strings.Join(failed, "; "))
+		http.Error(w, strings.Join(failed, "; "), http.StatusBadGateway)
+		return
+	}
+	fmt.Fprintf(w, "coverage flushed")
+}
+
+// flushTargetAllowed reports whether target is COVERAGE_PUSH_URL or one of the comma-separated
+// URLs of COVERAGE_FLUSH_TARGETS
+func flushTargetAllowed(target string) bool {
+	allowed := append(strings.Split(os.Getenv("COVERAGE_FLUSH_TARGETS"), ","), os.Getenv("COVERAGE_PUSH_URL"))
+	for _, allowedURL := range allowed {
+		if allowedURL = strings.TrimSuffix(strings.TrimSpace(allowedURL), "/"); allowedURL != "" && allowedURL == strings.TrimSuffix(target, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// resetCounters clears the counters of this process
+func resetCounters() error {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	if err := coverage.ClearCounters(); err != nil {
+		return err
+	}
+	resetGeneration++
+	return nil
+}
+
+// coverageMu serializes calls into runtime/coverage, which doesn't coordinate concurrent writers
+var coverageMu sync.Mutex
+
+// resetGeneration counts the resets of this process' counters (guarded by coverageMu). Counters
+// only grow between resets, so a snapshot replaces an earlier one of the same generation only.
+var resetGeneration int64
+
+// snapshots shares snapshots among simultaneous HTTP and push requests
+var snapshots snapshotGroup
 
-	// Extract hash from metadata to create proper filenames
-	var hash string
-	if len(metaData) >= 32 {
-		hashBytes := metaData[16:32]
-		hash = fmt.Sprintf("%x", hashBytes)
+// snapshotGroup lets simultaneous callers share one snapshot: callers arriving while a snapshot is
+// being taken wait for it instead of walking the counters again. Callers arriving afterwards get a
+// new snapshot, so every caller sees counters at least as recent as its request.
+type snapshotGroup struct {
+	mu       sync.Mutex
+	inflight *snapshotCall
+}
+
+// snapshotCall is a snapshot being taken and the callers waiting for it
+type snapshotCall struct {
+	done     chan struct{}
+	waiters  int32
+	snapshot *coverageSnapshot
+	err      error
+}
+
+// Do returns the snapshot taken by a concurrent caller, or calls take. Every caller must Close the
+// returned snapshot; its temp files are removed when the last caller is done.
+func (g *snapshotGroup) Do(take func() (*coverageSnapshot, error)) (*coverageSnapshot, error) {
+	g.mu.Lock()
+	if call := g.inflight; call != nil {
+		call.waiters++
+		g.mu.Unlock()
+		<-call.done
+		return call.snapshot, call.err
+	}
+	call := &snapshotCall{done: make(chan struct{})}
+	g.inflight = call
+	g.mu.Unlock()
+
+	call.snapshot, call.err = take()
+
+	g.mu.Lock()
+	g.inflight = nil
+	if call.snapshot != nil {
+		call.snapshot.refs.Store(1 + call.waiters)
+	}
+	g.mu.Unlock()
+	close(call.done)
+	return call.snapshot, call.err
+}
+
+// coverageSnapshot is the meta-data and counters of the process at one point in time
+type coverageSnapshot struct {
+	meta       *spool
+	counters   *spool
+	timestamp  int64
+	generation int64         // resetGeneration when the snapshot was taken
+	others     []counterFile // Counters of other processes of the binary found in GOCOVERDIR
+	index      *counterIndex // Chunks of the counters, nil if they couldn't be indexed
+	refs       atomic.Int32  // Callers sharing the snapshot, see snapshotGroup
+}
+
+// counterFile is a counters file in GOCOVERDIR, opened when the snapshot was taken so it stays
+// readable if its process replaces it meanwhile
+type counterFile struct {
+	name string
+	file *os.File
+	size int64
+}
+
+// takeCoverageSnapshot captures the current coverage meta-data and counters. Each file is kept in
+// memory up to COVERAGE_MAX_MEMORY and spooled to a temp file beyond; Close removes temp files.
+func takeCoverageSnapshot() (*coverageSnapshot, error) {
+	maxMemory := maxSnapshotMemory()
+	snapshot := &coverageSnapshot{
+		meta:      &spool{max: maxMemory},
+		counters:  &spool{max: maxMemory},
+		timestamp: time.Now().UnixNano(),
+	}
+
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	if err := coverage.WriteMeta(snapshot.meta); err != nil {
+		snapshot.Close()
+		// runtime/coverage reports "no meta-data available (binary not built with -cover?)"
+		if strings.Contains(err.Error(), "no meta-data available") {
+			return nil, fmt.Errorf("Failed to collect metadata: %w", errNotInstrumented)
+		}
+		return nil, fmt.Errorf("Failed to collect metadata: %v", err)
+	}
+	if err := coverage.WriteCounters(snapshot.counters); err != nil {
+		snapshot.Close()
+		return nil, fmt.Errorf("Failed to collect counters: %v", err)
+	}
+
+	snapshot.generation = resetGeneration
+	others, err := openOtherCounters(os.Getenv("GOCOVERDIR"), metaHash(snapshot.meta.head), flusher.Files())
+	if err != nil {
+		log.Printf("[COVERAGE] WARNING: Skipping counters of other processes: %v", err)
+	}
+	snapshot.others = others
+
+	if index, err := indexCounters(snapshot.counters); err != nil {
+		log.Printf("[COVERAGE] WARNING: Counters will be sent in full: %v", err)
 	} else {
-		hash = "unknown"
+		snapshot.index = index
+		counterHistory.add(index)
+	}
+
+	log.Printf("[COVERAGE] Collected %d bytes metadata, %d bytes counters (+%d counters files of other processes)",
+		snapshot.meta.size, snapshot.counters.size, len(snapshot.others))
+	return snapshot, nil
+}
+
+// openOtherCounters opens the counters files of the binary with meta-data hash in coverDir, e.g.
+// written by forked children or by earlier containers of the pod before a restart, except own, the
+// files this process flushed (its live counters are newer, or were reset since)
+func openOtherCounters(coverDir, hash string, own []string) ([]counterFile, error) {
+	if coverDir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(coverDir, "covcounters."+hash+".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []counterFile
+	for _, path := range paths {
+		if slices.Contains(own, path) {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			// Replaced by its process since listing
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			continue
+		}
+		files = append(files, counterFile{name: filepath.Base(path), file: file, size: info.Size()})
 	}
+	return files, nil
+}
 
-	// Generate proper filenames
-	timestamp := time.Now().UnixNano()
-	metaFilename := fmt.Sprintf("covmeta.%s", hash)
-	counterFilename := fmt.Sprintf("covcounters.%s.%d.%d", hash, os.Getpid(), timestamp)
+// MetaFilename returns the covdata file name of the meta-data
+func (s *coverageSnapshot) MetaFilename() string {
+	return "covmeta." + metaHash(s.meta.head)
+}
 
-	log.Printf("[COVERAGE] Collected %d bytes metadata, %d bytes counters",
-		len(metaData), len(counterData))
+// CountersFilename returns the covdata file name of the counters
+func (s *coverageSnapshot) CountersFilename() string {
+	return countersFilename(metaHash(s.meta.head), s.timestamp)
+}
 
-	// Return coverage data as JSON
-	response := CoverageResponse{
-		MetaFilename:     metaFilename,
-		MetaData:         base64.StdEncoding.EncodeToString(metaData),
-		CountersFilename: counterFilename,
-		CountersData:     base64.StdEncoding.EncodeToString(counterData),
-		Timestamp:        timestamp,
+// WriteJSON writes the snapshot as a CoverageResponse object with extra string fields, base64-encoding
+// the files on the fly instead of building the encoded strings in memory
+func (s *coverageSnapshot) WriteJSON(w io.Writer, extra map[string]string) error {
+	bw := bufio.NewWriter(w)
+	fields := map[string]string{"meta_filename": s.MetaFilename(), "counters_filename": s.CountersFilename()}
+	for k, v := range extra {
+		fields[k] = v
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("[COVERAGE] Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	bw.WriteString("{")
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		key, _ := json.Marshal(k)
+		value, _ := json.Marshal(fields[k])
+		fmt.Fprintf(bw, "%s:%s,", key, value)
+	}
+	for _, file := range []struct {
+		key  string
+		data *spool
+	}{{"meta_data", s.meta}, {"counters_data", s.counters}} {
+		r, err := file.data.Reader()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "%q:\"", file.key)
+		encoder := base64.NewEncoder(base64.StdEncoding, bw)
+		if _, err := io.Copy(encoder, r); err != nil {
+			return err
+		}
+		encoder.Close()
+		bw.WriteString("\",")
+	}
+	if len(s.others) > 0 {
+		bw.WriteString(`"counters":[`)
+		for i, other := range s.others {
+			if i > 0 {
+				bw.WriteString(",")
+			}
+			name, _ := json.Marshal(other.name)
+			fmt.Fprintf(bw, `{"filename":%s,"data":"`, name)
+			encoder := base64.NewEncoder(base64.StdEncoding, bw)
+			if _, err := io.Copy(encoder, io.NewSectionReader(other.file, 0, other.size)); err != nil {
+				return err
+			}
+			encoder.Close()
+			bw.WriteString(`"}`)
+		}
+		bw.WriteString("],")
+	}
+	fmt.Fprintf(bw, "\"timestamp\":%d}\n", s.timestamp)
+	return bw.Flush()
+}
+
+// WriteMultipart writes the snapshot as one CoverageStreamType part per file and closes mw. With a
+// base the client holds, the counters are sent as CoverageDeltaType changes against it. Without
+// withMeta, the meta-data is left out for clients holding it already.
+func (s *coverageSnapshot) WriteMultipart(mw *multipart.Writer, base *counterIndex, withMeta bool) error {
+	type partFile struct {
+		name   string
+		header textproto.MIMEHeader
+		write  func(io.Writer) error
+	}
+	copyFrom := func(open func() (io.Reader, error)) func(io.Writer) error {
+		return func(w io.Writer) error {
+			r, err := open()
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, r)
+			return err
+		}
+	}
+
+	counters := partFile{s.CountersFilename(), textproto.MIMEHeader{}, copyFrom(s.counters.Reader)}
+	if s.index != nil {
+		counters.header.Set(coverageSnapshotHeader, s.index.id)
+		counters.header.Set(coverageDigestHeader, hex.EncodeToString(s.index.digest[:]))
+		if base != nil {
+			counters.header.Set("Content-Type", CoverageDeltaType)
+			counters.header.Set(coverageBaseHeader, base.id)
+			counters.write = func(w io.Writer) error {
+				r, err := s.counters.Reader()
+				if err != nil {
+					return err
+				}
+				literal, err := writeCountersDelta(w, r, s.index, base)
+				if err == nil {
+					log.Printf("[COVERAGE] Sending counters as changes against snapshot %s: %d of %d bytes",
+						base.id, literal, s.counters.size)
+				}
+				return err
+			}
+		}
+	}
+
+	var files []partFile
+	if withMeta {
+		files = append(files, partFile{s.MetaFilename(), textproto.MIMEHeader{}, copyFrom(s.meta.Reader)})
+	}
+	files = append(files, counters)
+	for _, other := range s.others {
+		r := io.NewSectionReader(other.file, 0, other.size)
+		files = append(files, partFile{other.name, textproto.MIMEHeader{}, copyFrom(func() (io.Reader, error) { return r, nil })})
+	}
+
+	for _, file := range files {
+		if file.header.Get("Content-Type") == "" {
+			file.header.Set("Content-Type", "application/octet-stream")
+		}
+		file.header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.name))
+		part, err := mw.CreatePart(file.header)
+		if err != nil {
+			return err
+		}
+		if err := file.write(part); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// Close releases the snapshot; the last of the callers sharing it removes the temp files
+func (s *coverageSnapshot) Close() {
+	if s.refs.Add(-1) > 0 {
 		return
 	}
+	s.meta.Close()
+	s.counters.Close()
+	for _, other := range s.others {
+		other.file.Close()
+	}
+}
 
-	log.Println("[COVERAGE] Coverage data sent successfully")
+// Counters are split into chunks at content-defined boundaries, so a counter growing by a byte
+// (they are varint-encoded) only changes the chunk holding it, not every chunk after it
+const (
+	minCounterChunk  = 2 << 10
+	maxCounterChunk  = 64 << 10
+	counterChunkMask = 1<<13 - 1 // About 8 KiB chunks on average
+)
+
+// maxCounterHistory is the number of recent snapshots clients can receive changes against
+const maxCounterHistory = 8
+
+// counterInstance identifies this process in snapshot IDs, so clients don't apply changes to the
+// counters of another process (e.g. after a restart)
+var counterInstance = func() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}()
+
+// counterSequence numbers the snapshots of this process
+var counterSequence atomic.Int64
+
+// counterHistory remembers the chunks of recent snapshots' counters
+var counterHistory counterIndexes
+
+// gearTable holds the per-byte values of the chunker's rolling hash (fixed, so chunking is
+// reproducible)
+var gearTable = func() (table [256]uint64) {
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// counterIndex is the chunk list of a snapshot's counters
+type counterIndex struct {
+	id     string // Snapshot ID: instance and sequence number
+	digest [sha256.Size]byte
+	chunks []counterChunk
+}
+
+// counterChunk is a chunk of counters data
+type counterChunk struct {
+	offset int64
+	size   int64
+	hash   [sha256.Size]byte
+}
+
+// indexCounters splits the spooled counters into chunks and assigns the snapshot its ID
+func indexCounters(counters *spool) (*counterIndex, error) {
+	r, err := counters.Reader()
+	if err != nil {
+		return nil, err
+	}
+	index := &counterIndex{id: fmt.Sprintf("%s.%d", counterInstance, counterSequence.Add(1))}
+	digest := sha256.New()
+	br := bufio.NewReader(io.TeeReader(r, digest))
+
+	chunk := make([]byte, 0, maxCounterChunk)
+	var offset int64
+	var hash uint64
+	for {
+		c, err := br.ReadByte()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == nil {
+			chunk = append(chunk, c)
+			hash = hash<<1 + gearTable[c]
+		}
+		boundary := len(chunk) >= minCounterChunk && hash&counterChunkMask == 0 || len(chunk) == maxCounterChunk
+		if len(chunk) > 0 && (boundary || err == io.EOF) {
+			index.chunks = append(index.chunks, counterChunk{offset: offset, size: int64(len(chunk)), hash: sha256.Sum256(chunk)})
+			offset += int64(len(chunk))
+			chunk, hash = chunk[:0], 0
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	copy(index.digest[:], digest.Sum(nil))
+	return index, nil
+}
+
+// counterIndexes keeps the indexes of the most recent snapshots
+type counterIndexes struct {
+	mu      sync.Mutex
+	indexes []*counterIndex // Oldest first
+}
+
+// add records the index of a new snapshot, dropping the oldest beyond maxCounterHistory
+func (h *counterIndexes) add(index *counterIndex) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.indexes = append(h.indexes, index)
+	if len(h.indexes) > maxCounterHistory {
+		h.indexes = slices.Delete(h.indexes, 0, len(h.indexes)-maxCounterHistory)
+	}
+}
+
+// find returns the index of the most recent snapshot among the comma-separated IDs, nil if the
+// client holds none of the snapshots still remembered
+func (h *counterIndexes) find(ids string) *counterIndex {
+	if ids == "" {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.indexes) - 1; i >= 0; i-- {
+		for _, id := range strings.Split(ids, ",") {
+			if strings.TrimSpace(id) == h.indexes[i].id {
+				return h.indexes[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Operations of CoverageDeltaType data
+const (
+	deltaCopy    = 'C' // Base offset and length (uvarints): copy bytes of the base counters
+	deltaLiteral = 'L' // Length (uvarint) and data: bytes to insert
+)
+
+// writeCountersDelta writes the counters read from r as operations rebuilding them from the base
+// counters: chunks the base holds are copied from it, adjacent copies merged, and the rest is sent
+// literally. It returns the number of literal bytes.
+func writeCountersDelta(w io.Writer, r io.Reader, index, base *counterIndex) (int64, error) {
+	baseChunks := make(map[[sha256.Size]byte]int64, len(base.chunks))
+	for _, chunk := range base.chunks {
+		baseChunks[chunk.hash] = chunk.offset
+	}
+
+	bw := bufio.NewWriter(w)
+	op := make([]byte, 0, 1+2*binary.MaxVarintLen64)
+	var copyOffset, copySize, literal int64
+	flushCopy := func() {
+		if copySize > 0 {
+			op = binary.AppendUvarint(binary.AppendUvarint(append(op[:0], deltaCopy), uint64(copyOffset)), uint64(copySize))
+			bw.Write(op)
+			copySize = 0
+		}
+	}
+
+	for _, chunk := range index.chunks {
+		if offset, ok := baseChunks[chunk.hash]; ok {
+			if _, err := io.CopyN(io.Discard, r, chunk.size); err != nil {
+				return literal, err
+			}
+			if copySize > 0 && copyOffset+copySize == offset {
+				copySize += chunk.size
+				continue
+			}
+			flushCopy()
+			copyOffset, copySize = offset, chunk.size
+			continue
+		}
+
+		flushCopy()
+		bw.Write(binary.AppendUvarint(append(op[:0], deltaLiteral), uint64(chunk.size)))
+		if _, err := io.CopyN(bw, r, chunk.size); err != nil {
+			return literal, err
+		}
+		literal += chunk.size
+	}
+	flushCopy()
+	return literal, bw.Flush()
+}
+
+// defaultMaxSnapshotMemory is the default per-file memory limit of snapshots
+const defaultMaxSnapshotMemory = 4 << 20
+
+// maxSnapshotMemory returns COVERAGE_MAX_MEMORY, the bytes of each snapshot file kept in memory
+// before spooling to a temp file, e.g. "512Ki" or "16Mi"; "0" always spools
+func maxSnapshotMemory() int64 {
+	value := os.Getenv("COVERAGE_MAX_MEMORY")
+	if value == "" {
+		return defaultMaxSnapshotMemory
+	}
+	size, err := parseByteSize(value)
+	if err != nil {
+		log.Printf("[COVERAGE] WARNING: Invalid COVERAGE_MAX_MEMORY %q, using %d bytes", value, defaultMaxSnapshotMemory)
+		return defaultMaxSnapshotMemory
+	}
+	return size
+}
+
+// parseByteSize parses a byte count with an optional Ki, Mi or Gi (or K, M, G) suffix
+func parseByteSize(value string) (int64, error) {
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"K", 1000}, {"M", 1000 * 1000}, {"G", 1000 * 1000 * 1000}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSuffix(value, unit.suffix), unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// spool buffers written data in memory up to max bytes and moves it to a temp file beyond
+type spool struct {
+	max  int64
+	buf  bytes.Buffer
+	file *os.File
+	head []byte // First 32 bytes, holding the meta-data hash
+	size int64
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if n := 32 - len(s.head); n > 0 {
+		s.head = append(s.head, p[:min(n, len(p))]...)
+	}
+	if s.file == nil && s.size+int64(len(p)) > s.max {
+		file, err := os.CreateTemp("", "coverage-snapshot-*")
+		if err != nil {
+			return 0, fmt.Errorf("create spool file: %w", err)
+		}
+		s.file = file
+		if _, err := s.buf.WriteTo(file); err != nil {
+			return 0, fmt.Errorf("write spool file: %w", err)
+		}
+	}
+	s.size += int64(len(p))
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// Reader returns a reader of the spooled data from the start; readers are independent, so callers
+// sharing a snapshot can read it concurrently
+func (s *spool) Reader() (io.Reader, error) {
+	if s.file == nil {
+		return bytes.NewReader(s.buf.Bytes()), nil
+	}
+	return io.NewSectionReader(s.file, 0, s.size), nil
+}
+
+// Close removes the temp file, if any
+func (s *spool) Close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+}
+
+// metaHash extracts the hash of the meta-data header used in coverage file names
+func metaHash(metaData []byte) string {
+	if len(metaData) < 32 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%x", metaData[16:32])
+}
+
+// countersFilename returns the name the Go runtime would give a counters file of this process
+func countersFilename(hash string, timestamp int64) string {
+	return fmt.Sprintf("covcounters.%s.%d.%d", hash, os.Getpid(), timestamp)
+}
+
+// startCoveragePusher periodically pushes coverage snapshots to the collector at COVERAGE_PUSH_URL
+func startCoveragePusher(collectorURL string) {
+	interval := 60 * time.Second
+	if value := os.Getenv("COVERAGE_PUSH_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("[COVERAGE] WARNING: Invalid COVERAGE_PUSH_INTERVAL %q, using %s", value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	log.Printf("[COVERAGE] Push mode enabled: pushing to %s every %s", collectorURL, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := PushCoverage(collectorURL); err != nil {
+			log.Printf("[COVERAGE] ERROR: Push failed: %v", err)
+		}
+	}
+}
+
+// PushCoverage sends a coverage snapshot to the collector at collectorURL
+func PushCoverage(collectorURL string) error {
+	return pushCoverage(collectorURL, coverageSuite())
+}
+
+// coverageSuite returns COVERAGE_SUITE, the suite pushed snapshots belong to (default: "default")
+func coverageSuite() string {
+	if suite := os.Getenv("COVERAGE_SUITE"); suite != "" {
+		return suite
+	}
+	return "default"
+}
+
+// pushCoverage sends a coverage snapshot for suite to the collector at collectorURL
+func pushCoverage(collectorURL, suite string) error {
+	snapshot, err := snapshots.Do(takeCoverageSnapshot)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	podName := os.Getenv("POD_NAME")
+	if podName == "" {
+		podName, _ = os.Hostname()
+	}
+
+	// Encode the payload while it is sent instead of marshaling it into memory first
+	body, bodyWriter := io.Pipe()
+	go func() {
+		bodyWriter.CloseWithError(snapshot.WriteJSON(bodyWriter, map[string]string{
+			"suite":     suite,
+			"pod_name":  podName,
+			"namespace": os.Getenv("POD_NAMESPACE"),
+			"service":   os.Getenv("COVERAGE_SERVICE"),
+			// Lets the collector keep the previous snapshot when the counters were reset since
+			"reset_generation": strconv.FormatInt(snapshot.generation, 10),
+			// Restarted containers of the pod run with the same PID, the start time tells them apart
+			"instance": strconv.FormatInt(serverStart.UnixNano(), 10),
+		}))
+	}()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(strings.TrimSuffix(collectorURL, "/")+"/push", "application/json", body)
+	body.Close()
+	if err != nil {
+		return fmt.Errorf("send push request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+
+	log.Printf("[COVERAGE] Pushed coverage snapshot to %s (suite: %s)", collectorURL, suite)
+	return nil
+}
+
+// startCoverageFlusher periodically writes coverage counters to coverDir. The Go runtime only writes
+// counters to GOCOVERDIR on a clean exit, so without flushing a crashing app loses all coverage.
+func startCoverageFlusher(coverDir, intervalValue string) {
+	interval, err := time.ParseDuration(intervalValue)
+	if err != nil || interval <= 0 {
+		log.Printf("[COVERAGE] WARNING: Invalid COVERAGE_FLUSH_INTERVAL %q, flushing disabled", intervalValue)
+		return
+	}
+
+	log.Printf("[COVERAGE] Flushing coverage counters to %s every %s", coverDir, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := flusher.Flush(coverDir); err != nil {
+			log.Printf("[COVERAGE] ERROR: Flush failed: %v", err)
+		}
+	}
+}
+
+// coverageFlusher writes counter snapshots to a directory, keeping its latest snapshot and the
+// last one before each reset
+type coverageFlusher struct {
+	lastFile   string
+	generation int64    // resetGeneration when lastFile was written
+	kept       []string // Snapshots written before a reset
+}
+
+// flusher flushes this process' counters to GOCOVERDIR; snapshots skip its files (guarded by coverageMu)
+var flusher coverageFlusher
+
+// Files returns the counters files written by this flusher
+func (f *coverageFlusher) Files() []string {
+	if f.lastFile == "" {
+		return f.kept
+	}
+	return append(slices.Clip(f.kept), f.lastFile)
+}
+
+// Flush writes the meta-data (if missing) and a new counters file to coverDir, then removes the
+// previous snapshot written by this flusher. Counters are cumulative between resets, so the latest
+// file is enough unless the counters were reset since the previous snapshot, which is then kept.
+func (f *coverageFlusher) Flush(coverDir string) error {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+
+	if err := coverage.WriteMetaDir(coverDir); err != nil {
+		return fmt.Errorf("write metadata: %w", err)
+	}
+
+	pattern := filepath.Join(coverDir, fmt.Sprintf("covcounters.*.%d.*", os.Getpid()))
+	before, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("list counters: %w", err)
+	}
+
+	if err := coverage.WriteCountersDir(coverDir); err != nil {
+		return fmt.Errorf("write counters: %w", err)
+	}
+
+	after, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("list counters: %w", err)
+	}
+	newFile := ""
+	for _, file := range after {
+		if !slices.Contains(before, file) {
+			newFile = file
+		}
+	}
+
+	// Only remove files this flusher wrote; files of earlier containers in the pod may share our PID
+	if f.lastFile != "" && newFile != "" {
+		if f.generation != resetGeneration {
+			f.kept = append(f.kept, f.lastFile)
+		} else if err := os.Remove(f.lastFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove previous counters: %w", err)
+		}
+	}
+	if newFile != "" {
+		f.lastFile = newFile
+		f.generation = resetGeneration
+	}
+	return nil
 }
@@ -2,13 +2,28 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
 	"runtime/coverage"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCoverageHandler_Success(t *testing.T) {
@@ -150,6 +165,87 @@ func TestCoverageHandler_POST(t *testing.T) {
 	}
 }
 
+func TestCoverageHandler_Reset(t *testing.T) {
+	if !isCoverageEnabled() {
+		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
+	}
+
+	req, _ := http.NewRequest("POST", "/coverage?"+resetParam+"=true", strings.NewReader(`{"test_name":"my-test"}`))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CoverageHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	// Only atomic counters can be cleared, the snapshot is sent either way
+	if reset := rr.Header().Get(coverageResetHeader) == "true"; reset != (testing.CoverMode() == "atomic") {
+		t.Errorf("Unexpected %s header %q in mode %s", coverageResetHeader, rr.Header().Get(coverageResetHeader), testing.CoverMode())
+	}
+}
+
+func TestResetHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ResetHandler(rr, httptest.NewRequest("GET", "/coverage/reset", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("Expected GET to be rejected, got %d with Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+
+	rr = httptest.NewRecorder()
+	ResetHandler(rr, httptest.NewRequest("POST", "/coverage/reset", nil))
+	// Only atomic counters can be cleared; set mode and binaries without coverage conflict
+	if isCoverageEnabled() && testing.CoverMode() == "atomic" {
+		if rr.Code != http.StatusOK || rr.Header().Get(coverageResetHeader) != "true" {
+			t.Errorf("Expected the counters to be reset, got %d %q", rr.Code, rr.Body.String())
+		}
+	} else if rr.Code != http.StatusConflict || rr.Header().Get(coverageResetHeader) != "" {
+		t.Errorf("Expected a conflict in mode %q, got %d %q", testing.CoverMode(), rr.Code, rr.Body.String())
+	}
+}
+
+func TestCoverageHandler_Stream(t *testing.T) {
+	if !isCoverageEnabled() {
+		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
+	}
+
+	req, _ := http.NewRequest("POST", "/coverage", strings.NewReader(`{"test_name":"my-test"}`))
+	req.Header.Set("Accept", CoverageStreamType+", application/json")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CoverageHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v (body: %s)", status, http.StatusOK, rr.Body.String())
+	}
+	mediaType, params, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if err != nil || mediaType != CoverageStreamType {
+		t.Fatalf("Expected %s response, got %q", CoverageStreamType, rr.Header().Get("Content-Type"))
+	}
+
+	var metaData []byte
+	var names []string
+	reader := multipart.NewReader(rr.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		data, _ := io.ReadAll(part)
+		if strings.HasPrefix(part.FileName(), "covmeta.") {
+			metaData = data
+		}
+		names = append(names, part.FileName())
+	}
+
+	if len(names) != 2 || !strings.HasPrefix(names[1], "covcounters.") {
+		t.Fatalf("Expected covmeta and covcounters parts, got %v", names)
+	}
+	if names[0] != "covmeta."+metaHash(metaData) || !strings.HasPrefix(names[1], "covcounters."+metaHash(metaData)+".") {
+		t.Errorf("File names don't match the meta-data hash: %v", names)
+	}
+}
+
 func TestCoverageHandler_ResponseStructure(t *testing.T) {
 	if !isCoverageEnabled() {
 		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
@@ -344,20 +440,70 @@ func TestCoverageHandler_FilenameUniqueness(t *testing.T) {
 	}
 }
 
-func TestHealthHandler(t *testing.T) {
-	// Create a health check handler (simulating what's in startCoverageServer)
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("coverage server healthy"))
-	})
+func TestCoverageHandler_NotInstrumented(t *testing.T) {
+	if isCoverageEnabled() {
+		t.Skip("Skipping test - coverage enabled")
+	}
+
+	rr := httptest.NewRecorder()
+	CoverageHandler(rr, httptest.NewRequest("GET", "/coverage", nil))
 
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("Expected status 501 without -cover, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON error: %v", err)
+	}
+	// Older clients match the message
+	if body.Error != notInstrumentedCode || !strings.Contains(body.Message, "not built with -cover") {
+		t.Errorf("Unexpected error %+v", body)
+	}
+}
+
+func TestInfoHandler(t *testing.T) {
+	info := func() serverInfo {
+		rr := httptest.NewRecorder()
+		InfoHandler(rr, httptest.NewRequest("GET", "/coverage/info", nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("Expected a JSON response, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+		}
+		var info serverInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("Failed to decode info: %v", err)
+		}
+		return info
+	}
+
+	t.Setenv("GOCOVERDIR", "/tmp/covdata")
+	before := info()
+	if before.GoVersion != runtime.Version() || before.CoverEnabled != isCoverageEnabled() || before.CoverDir != "/tmp/covdata" {
+		t.Errorf("Unexpected info %+v", before)
+	}
+	if before.StartedAt == "" || before.UptimeSeconds < 0 || before.BuildTime == "" {
+		t.Errorf("Expected the start time, uptime and build time, got %+v", before)
+	}
+
+	if !isCoverageEnabled() {
+		return
+	}
+	CoverageHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/coverage", nil))
+	if after := info(); after.SnapshotsServed != before.SnapshotsServed+1 {
+		t.Errorf("Expected one more snapshot served, got %d after %d", after.SnapshotsServed, before.SnapshotsServed)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
 	req, err := http.NewRequest("GET", "/health", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-	handler.ServeHTTP(rr, req)
+	HealthHandler(rr, req)
 
 	// Check status code
 	if status := rr.Code; status != http.StatusOK {
@@ -371,6 +517,47 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("Health handler returned unexpected body: got %v want %v",
 			rr.Body.String(), expected)
 	}
+
+	// The container headers follow /proc, empty outside containers
+	info := coverageContainer()
+	if got := rr.Header().Get(coverageContainerHeader); got != info.name {
+		t.Errorf("Expected container header %q, got %q", info.name, got)
+	}
+}
+
+func TestParseContainerMountinfo(t *testing.T) {
+	const id = "3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a"
+	kubelet := `1510 1509 0:320 / / rw,relatime - overlay overlay rw
+1520 1510 259:1 /var/lib/kubelet/pods/0c8e-41d2/etc-hosts /etc/hosts rw,relatime - ext4 /dev/nvme0n1p1 rw
+1521 1510 259:1 /var/lib/kubelet/pods/0c8e-41d2/containers/app/8d1f2e3a /dev/termination-log rw,relatime - ext4 /dev/nvme0n1p1 rw
+1522 1510 259:1 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/` + id + `/hostname /etc/hostname rw - ext4 /dev/nvme0n1p1 rw
+`
+	if name, gotID := parseContainerMountinfo(strings.NewReader(kubelet)); name != "app" || gotID != "" {
+		t.Errorf("Expected container app without ID (the sandbox isn't the container), got %q %q", name, gotID)
+	}
+
+	docker := `612 590 8:1 /var/lib/docker/containers/` + id + `/hostname /etc/hostname rw - ext4 /dev/sda1 rw
+`
+	if name, gotID := parseContainerMountinfo(strings.NewReader(docker)); name != "" || gotID != id {
+		t.Errorf("Expected the Docker container ID, got %q %q", name, gotID)
+	}
+}
+
+func TestParseContainerCgroup(t *testing.T) {
+	const id = "3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a"
+	for _, tc := range []struct {
+		cgroup string
+		want   string
+	}{
+		{"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod0c8e.slice/cri-containerd-" + id + ".scope\n", id},
+		{"0::/kubepods.slice/kubepods-pod0c8e.slice/crio-" + id + ".scope\n", id},
+		{"12:memory:/kubepods/burstable/pod0c8e-41d2/" + id + "\n11:cpu:/kubepods/burstable/pod0c8e-41d2/" + id + "\n", id},
+		{"0::/\n", ""},
+	} {
+		if got := parseContainerCgroup(strings.NewReader(tc.cgroup)); got != tc.want {
+			t.Errorf("parseContainerCgroup(%q) = %q, want %q", tc.cgroup, got, tc.want)
+		}
+	}
 }
 
 func TestCoverageHandler_ConcurrentRequests(t *testing.T) {
@@ -479,3 +666,596 @@ func BenchmarkCoverageHandler_Parallel(b *testing.B) {
 		}
 	})
 }
+
+func TestFlushHandler(t *testing.T) {
+	t.Setenv("GOCOVERDIR", "")
+	t.Setenv("COVERAGE_PUSH_URL", "")
+	t.Setenv("COVERAGE_FLUSH_TARGETS", "")
+
+	rr := httptest.NewRecorder()
+	FlushHandler(rr, httptest.NewRequest("DELETE", "/coverage/flush", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected DELETE to be rejected, got %d", rr.Code)
+	}
+	for _, target := range []string{"", "file:///etc/passwd", "collector:8080", "http://169.254.169.254/latest"} {
+		rr = httptest.NewRecorder()
+		FlushHandler(rr, httptest.NewRequest("GET", "/coverage/flush?target="+url.QueryEscape(target), nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected target %q to be rejected, got %d %q", target, rr.Code, rr.Body.String())
+		}
+	}
+
+	if !isCoverageEnabled() {
+		t.Skip("Skipping push - coverage not enabled (run with: go test -cover)")
+	}
+	var pushed map[string]string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/push" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&pushed)
+	}))
+	defer collector.Close()
+	coverDir := t.TempDir()
+	t.Setenv("GOCOVERDIR", coverDir)
+	t.Setenv("COVERAGE_FLUSH_TARGETS", collector.URL+"/, "+collector.URL+"/missing")
+
+	rr = httptest.NewRecorder()
+	FlushHandler(rr, httptest.NewRequest("GET", "/coverage/flush?target="+url.QueryEscape(collector.URL)+"&suite=rollout", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the flush to succeed, got %d %q", rr.Code, rr.Body.String())
+	}
+	if pushed["suite"] != "rollout" || pushed["counters_data"] == "" {
+		t.Errorf("Expected a snapshot pushed for suite rollout, got suite %q", pushed["suite"])
+	}
+	if counters, _ := filepath.Glob(filepath.Join(coverDir, "covcounters.*")); len(counters) == 0 {
+		t.Error("Expected the counters to be written to GOCOVERDIR")
+	}
+
+	rr = httptest.NewRecorder()
+	FlushHandler(rr, httptest.NewRequest("POST", "/coverage/flush?target="+url.QueryEscape(collector.URL+"/missing"), nil))
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "collector returned 404") {
+		t.Errorf("Expected the failed push to be reported, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestFlushTargetAllowed(t *testing.T) {
+	t.Setenv("COVERAGE_PUSH_URL", "http://coverage-collector:9096")
+	t.Setenv("COVERAGE_FLUSH_TARGETS", "http://runner.e2e.svc:8080/, http://backup:9096")
+
+	for target, want := range map[string]bool{
+		"http://coverage-collector:9096":  true,
+		"http://runner.e2e.svc:8080":      true,
+		"http://backup:9096/":             true,
+		"http://runner.e2e.svc:8080/evil": false,
+		"http://169.254.169.254":          false,
+	} {
+		if got := flushTargetAllowed(target); got != want {
+			t.Errorf("flushTargetAllowed(%q) = %v, want %v", target, got, want)
+		}
+	}
+
+	t.Setenv("COVERAGE_PUSH_URL", "")
+	t.Setenv("COVERAGE_FLUSH_TARGETS", "")
+	if flushTargetAllowed("http://coverage-collector:9096") {
+		t.Error("Expected no target to be allowed without COVERAGE_PUSH_URL and COVERAGE_FLUSH_TARGETS")
+	}
+}
+
+func TestCoverageFlusher_KeepsLatestSnapshot(t *testing.T) {
+	if !isCoverageEnabled() {
+		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
+	}
+
+	coverDir := t.TempDir()
+	var flusher coverageFlusher
+
+	for i := 0; i < 3; i++ {
+		if err := flusher.Flush(coverDir); err != nil {
+			t.Fatalf("Flush %d failed: %v", i, err)
+		}
+	}
+
+	metaFiles, _ := filepath.Glob(filepath.Join(coverDir, "covmeta.*"))
+	counterFiles, _ := filepath.Glob(filepath.Join(coverDir, "covcounters.*"))
+	if len(metaFiles) != 1 {
+		t.Errorf("Expected 1 meta file, got %d", len(metaFiles))
+	}
+	if len(counterFiles) != 1 || counterFiles[0] != flusher.lastFile {
+		t.Errorf("Expected only the latest counters file %s, got %v", flusher.lastFile, counterFiles)
+	}
+}
+
+func TestCoverageFlusher_KeepsSnapshotBeforeReset(t *testing.T) {
+	if !isCoverageEnabled() || testing.CoverMode() != "atomic" {
+		t.Skip("Skipping test - counters can only be reset with coverage enabled (run with: go test -covermode=atomic)")
+	}
+
+	coverDir := t.TempDir()
+	var flusher coverageFlusher
+
+	if err := flusher.Flush(coverDir); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	beforeReset := flusher.lastFile
+	if err := resetCounters(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := flusher.Flush(coverDir); err != nil {
+			t.Fatalf("Flush %d after reset failed: %v", i, err)
+		}
+	}
+
+	counterFiles, _ := filepath.Glob(filepath.Join(coverDir, "covcounters.*"))
+	if len(counterFiles) != 2 || !slices.Contains(counterFiles, beforeReset) || !slices.Contains(counterFiles, flusher.lastFile) {
+		t.Errorf("Expected the counters file from before the reset %s and the latest %s, got %v", beforeReset, flusher.lastFile, counterFiles)
+	}
+	if files := flusher.Files(); len(files) != 2 {
+		t.Errorf("Expected snapshots to skip both flushed files, got %v", files)
+	}
+}
+
+func TestSpool_SpillsToTempFile(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	s := &spool{max: 25}
+	for i := 0; i < len(data); i += 7 {
+		s.Write(data[i:min(i+7, len(data))])
+	}
+	if s.file == nil {
+		t.Fatal("Expected data beyond max to be spooled to a temp file")
+	}
+	if string(s.head) != string(data[:32]) {
+		t.Errorf("Expected head to hold the first 32 bytes, got %q", s.head)
+	}
+
+	r, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _ := io.ReadAll(r)
+	if !bytes.Equal(read, data) {
+		t.Errorf("Spooled data mismatch: %q", read)
+	}
+
+	name := s.file.Name()
+	s.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Expected temp file to be removed, got %v", err)
+	}
+}
+
+func TestCoverageSnapshot_WriteJSON(t *testing.T) {
+	metaData := append(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{0xab}, 40)...)
+	snapshot := &coverageSnapshot{meta: &spool{max: 10}, counters: &spool{max: 1 << 20}, timestamp: 42}
+	defer snapshot.Close()
+	snapshot.meta.Write(metaData)
+	snapshot.counters.Write([]byte("counters"))
+
+	var buf bytes.Buffer
+	if err := snapshot.WriteJSON(&buf, map[string]string{"suite": "e2e"}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var response struct {
+		CoverageResponse
+		Suite string `json:"suite"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", buf.String(), err)
+	}
+	if response.MetaFilename != "covmeta."+strings.Repeat("ab", 16) || response.Timestamp != 42 || response.Suite != "e2e" {
+		t.Errorf("Unexpected response fields: %+v", response)
+	}
+	if decoded, _ := base64.StdEncoding.DecodeString(response.MetaData); !bytes.Equal(decoded, metaData) {
+		t.Errorf("Meta-data mismatch: %x", decoded)
+	}
+	if decoded, _ := base64.StdEncoding.DecodeString(response.CountersData); string(decoded) != "counters" {
+		t.Errorf("Counters mismatch: %q", decoded)
+	}
+}
+
+func TestSnapshotGroup_SharesConcurrentSnapshots(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	var group snapshotGroup
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	take := func() (*coverageSnapshot, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		snapshot := &coverageSnapshot{meta: &spool{max: 0}, counters: &spool{max: 0}}
+		snapshot.meta.Write([]byte("meta"))
+		snapshot.counters.Write([]byte("counters"))
+		return snapshot, nil
+	}
+
+	const callers = 5
+	results := make(chan *coverageSnapshot, callers)
+	go func() {
+		snapshot, _ := group.Do(take)
+		results <- snapshot
+	}()
+	<-started
+	var waiting sync.WaitGroup
+	for i := 1; i < callers; i++ {
+		waiting.Add(1)
+		go func() {
+			defer waiting.Done()
+			snapshot, _ := group.Do(take)
+			results <- snapshot
+		}()
+	}
+	// Let the other callers join the in-flight snapshot before it completes
+	for {
+		group.mu.Lock()
+		joined := group.inflight.waiters
+		group.mu.Unlock()
+		if joined == callers-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	waiting.Wait()
+
+	first := <-results
+	for i := 1; i < callers; i++ {
+		if snapshot := <-results; snapshot != first {
+			t.Error("Expected simultaneous callers to share one snapshot")
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected one snapshot to be taken, got %d", calls.Load())
+	}
+
+	file := first.meta.file.Name()
+	for i := 1; i < callers; i++ {
+		first.Close()
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("Expected temp file to survive until the last caller closes, got %v", err)
+	}
+	first.Close()
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Expected temp file to be removed after the last caller, got %v", err)
+	}
+
+	// Callers after completion take a fresh snapshot
+	release = make(chan struct{})
+	close(release)
+	snapshot, _ := group.Do(take)
+	defer snapshot.Close()
+	if snapshot == first || calls.Load() != 2 {
+		t.Error("Expected a new snapshot once the previous one completed")
+	}
+}
+
+func TestOpenOtherCounters(t *testing.T) {
+	coverDir := t.TempDir()
+	for _, name := range []string{
+		"covmeta.abc",
+		"covcounters.abc.1.100",  // Earlier container, same PID
+		"covcounters.abc.42.200", // Forked child
+		"covcounters.abc.1.250",  // Flushed by this process before a reset
+		"covcounters.abc.1.300",  // Flushed by this process
+		"covcounters.def.1.100",  // Other binary
+	} {
+		os.WriteFile(filepath.Join(coverDir, name), []byte(name), 0644)
+	}
+
+	files, err := openOtherCounters(coverDir, "abc", []string{
+		filepath.Join(coverDir, "covcounters.abc.1.250"),
+		filepath.Join(coverDir, "covcounters.abc.1.300"),
+	})
+	if err != nil {
+		t.Fatalf("openOtherCounters failed: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.name)
+		f.file.Close()
+	}
+	if strings.Join(names, ",") != "covcounters.abc.1.100,covcounters.abc.42.200" {
+		t.Errorf("Unexpected counters files: %v", names)
+	}
+
+	if files, err := openOtherCounters("", "abc", nil); err != nil || files != nil {
+		t.Errorf("Expected no files without GOCOVERDIR, got %v, %v", files, err)
+	}
+}
+
+func TestCoverageSnapshot_OtherCounters(t *testing.T) {
+	coverDir := t.TempDir()
+	os.WriteFile(filepath.Join(coverDir, "covcounters.abc.42.1"), []byte("child"), 0644)
+	others, _ := openOtherCounters(coverDir, "abc", nil)
+
+	snapshot := &coverageSnapshot{meta: &spool{max: 1 << 20}, counters: &spool{max: 1 << 20}, others: others}
+	defer snapshot.Close()
+	snapshot.meta.Write([]byte("meta"))
+	snapshot.counters.Write([]byte("live"))
+
+	var buf bytes.Buffer
+	if err := snapshot.WriteJSON(&buf, nil); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var response struct {
+		Counters []struct {
+			Filename string `json:"filename"`
+			Data     string `json:"data"`
+		} `json:"counters"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", buf.String(), err)
+	}
+	if len(response.Counters) != 1 || response.Counters[0].Filename != "covcounters.abc.42.1" || response.Counters[0].Data != base64.StdEncoding.EncodeToString([]byte("child")) {
+		t.Errorf("Unexpected counters entries: %+v", response.Counters)
+	}
+
+	buf.Reset()
+	mw := multipart.NewWriter(&buf)
+	if err := snapshot.WriteMultipart(mw, nil, true); err != nil {
+		t.Fatalf("WriteMultipart failed: %v", err)
+	}
+	reader := multipart.NewReader(&buf, mw.Boundary())
+	var names []string
+	for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+		names = append(names, part.FileName())
+	}
+	if len(names) != 3 || names[2] != "covcounters.abc.42.1" {
+		t.Errorf("Expected a part per counters file, got %v", names)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for value, want := range map[string]int64{"0": 0, "1024": 1024, "512Ki": 512 << 10, "16Mi": 16 << 20, "1G": 1000 * 1000 * 1000} {
+		if got, err := parseByteSize(value); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "abc", "-1", "1Ti"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+// testCounters returns pseudo-random counters data, varint-like so a grown counter shifts the rest
+func testCounters(n int) []byte {
+	data := make([]byte, n)
+	x := uint32(1)
+	for i := range data {
+		x = x*1664525 + 1013904223
+		data[i] = byte(x >> 24)
+	}
+	return data
+}
+
+// applyTestDelta rebuilds counters from CoverageDeltaType operations like the client does
+func applyTestDelta(t *testing.T, delta, base []byte) []byte {
+	t.Helper()
+	var out []byte
+	r := bytes.NewReader(delta)
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			return out
+		}
+		switch op {
+		case deltaCopy:
+			offset, _ := binary.ReadUvarint(r)
+			size, _ := binary.ReadUvarint(r)
+			out = append(out, base[offset:offset+size]...)
+		case deltaLiteral:
+			size, _ := binary.ReadUvarint(r)
+			literal := make([]byte, size)
+			io.ReadFull(r, literal)
+			out = append(out, literal...)
+		default:
+			t.Fatalf("Unknown delta operation %q", op)
+		}
+	}
+}
+
+// indexTestCounters indexes data as a snapshot's counters
+func indexTestCounters(t *testing.T, data []byte) *counterIndex {
+	t.Helper()
+	counters := &spool{max: 1 << 30}
+	counters.Write(data)
+	index, err := indexCounters(counters)
+	if err != nil {
+		t.Fatalf("indexCounters failed: %v", err)
+	}
+	return index
+}
+
+func TestIndexCounters(t *testing.T) {
+	data := testCounters(1 << 20)
+	index := indexTestCounters(t, data)
+
+	var total int64
+	for i, chunk := range index.chunks {
+		if chunk.offset != total || chunk.size > maxCounterChunk || (chunk.size < minCounterChunk && i < len(index.chunks)-1) {
+			t.Fatalf("Unexpected chunk %d at %d, %d bytes", i, chunk.offset, chunk.size)
+		}
+		total += chunk.size
+	}
+	if total != int64(len(data)) || index.digest != sha256.Sum256(data) {
+		t.Errorf("Chunks don't cover the counters: %d of %d bytes", total, len(data))
+	}
+	if !strings.HasPrefix(index.id, counterInstance+".") {
+		t.Errorf("Expected snapshot ID of this instance, got %s", index.id)
+	}
+
+	// A counter growing by a byte changes the chunk around it, later boundaries resynchronize
+	grown := slices.Insert(slices.Clone(data), 300000, 0x80)
+	changed := 0
+	hashes := make(map[[sha256.Size]byte]bool)
+	for _, chunk := range index.chunks {
+		hashes[chunk.hash] = true
+	}
+	for _, chunk := range indexTestCounters(t, grown).chunks {
+		if !hashes[chunk.hash] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Errorf("Expected 1-2 changed chunks after an insertion, got %d", changed)
+	}
+}
+
+func TestWriteCountersDelta(t *testing.T) {
+	base := testCounters(1 << 20)
+	current := slices.Clone(base)
+	current[1000] ^= 0xff
+	current = slices.Insert(current, 500000, 1, 2, 3)
+	current = append(current, testCounters(100)...)
+
+	baseIndex, index := indexTestCounters(t, base), indexTestCounters(t, current)
+	var delta bytes.Buffer
+	literal, err := writeCountersDelta(&delta, bytes.NewReader(current), index, baseIndex)
+	if err != nil {
+		t.Fatalf("writeCountersDelta failed: %v", err)
+	}
+
+	if rebuilt := applyTestDelta(t, delta.Bytes(), base); !bytes.Equal(rebuilt, current) {
+		t.Fatalf("Rebuilt counters differ from the snapshot (%d of %d bytes)", len(rebuilt), len(current))
+	}
+	if literal > 4*maxCounterChunk || delta.Len() > 4*maxCounterChunk {
+		t.Errorf("Expected only changed chunks to be sent, got %d literal bytes in %d bytes of changes", literal, delta.Len())
+	}
+}
+
+func TestCounterIndexes(t *testing.T) {
+	var history counterIndexes
+	for i := 1; i <= maxCounterHistory+2; i++ {
+		history.add(&counterIndex{id: fmt.Sprintf("abc.%d", i)})
+	}
+
+	if history.find("abc.1") != nil || history.find("abc.2") != nil {
+		t.Error("Expected the oldest snapshots to be forgotten")
+	}
+	if index := history.find("other.9, abc.3,abc.7"); index == nil || index.id != "abc.7" {
+		t.Errorf("Expected the most recent known snapshot, got %+v", index)
+	}
+	if history.find("") != nil || history.find("other.10") != nil {
+		t.Error("Expected unknown snapshots not to match")
+	}
+}
+
+func TestCoverageSnapshot_WriteMultipartDelta(t *testing.T) {
+	base := testCounters(256 << 10)
+	current := slices.Clone(base)
+	current[100000]++
+
+	snapshot := &coverageSnapshot{meta: &spool{max: 1 << 20}, counters: &spool{max: 1 << 20}}
+	defer snapshot.Close()
+	snapshot.meta.Write([]byte("meta"))
+	snapshot.counters.Write(current)
+	snapshot.index = indexTestCounters(t, current)
+	baseIndex := indexTestCounters(t, base)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := snapshot.WriteMultipart(mw, baseIndex, true); err != nil {
+		t.Fatalf("WriteMultipart failed: %v", err)
+	}
+	reader := multipart.NewReader(&buf, mw.Boundary())
+	reader.NextPart()
+	part, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("Missing counters part: %v", err)
+	}
+	if part.Header.Get("Content-Type") != CoverageDeltaType || part.Header.Get(coverageBaseHeader) != baseIndex.id {
+		t.Fatalf("Expected counters changes against %s, got headers %v", baseIndex.id, part.Header)
+	}
+	if part.Header.Get(coverageSnapshotHeader) != snapshot.index.id || part.Header.Get(coverageDigestHeader) != hex.EncodeToString(snapshot.index.digest[:]) {
+		t.Errorf("Expected snapshot ID and digest, got headers %v", part.Header)
+	}
+	delta, _ := io.ReadAll(part)
+	if !bytes.Equal(applyTestDelta(t, delta, base), current) {
+		t.Error("Rebuilt counters differ from the snapshot")
+	}
+	if len(delta) >= len(current)/2 {
+		t.Errorf("Expected a small delta, got %d bytes for %d bytes of counters", len(delta), len(current))
+	}
+}
+
+func TestCoverageHandler_Incremental(t *testing.T) {
+	if !isCoverageEnabled() {
+		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
+	}
+
+	collect := func(base string) *multipart.Part {
+		req, _ := http.NewRequest("POST", "/coverage", nil)
+		req.Header.Set("Accept", CoverageStreamType)
+		req.Header.Set(coverageBaseHeader, base)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(CoverageHandler).ServeHTTP(rr, req)
+		_, params, _ := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+		reader := multipart.NewReader(rr.Body, params["boundary"])
+		reader.NextPart()
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("Missing counters part: %v", err)
+		}
+		return part
+	}
+
+	first := collect("")
+	if first.Header.Get("Content-Type") == CoverageDeltaType || first.Header.Get(coverageSnapshotHeader) == "" {
+		t.Fatalf("Expected full counters with a snapshot ID, got headers %v", first.Header)
+	}
+	base, _ := io.ReadAll(first)
+
+	second := collect("unknown.1, " + first.Header.Get(coverageSnapshotHeader))
+	if second.Header.Get("Content-Type") != CoverageDeltaType || second.Header.Get(coverageBaseHeader) != first.Header.Get(coverageSnapshotHeader) {
+		t.Fatalf("Expected counters changes against the first snapshot, got headers %v", second.Header)
+	}
+	delta, _ := io.ReadAll(second)
+	digest := sha256.Sum256(applyTestDelta(t, delta, base))
+	if hex.EncodeToString(digest[:]) != second.Header.Get(coverageDigestHeader) {
+		t.Error("Rebuilt counters don't match the snapshot digest")
+	}
+}
+
+func TestCoverageSnapshot_CountersOnly(t *testing.T) {
+	metaData := append(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{0xcd}, 40)...)
+	hash := metaHash(metaData)
+	for query, want := range map[string]bool{
+		"":                                false,
+		"?counters-only=" + hash:          true,
+		"?counters-only=abc," + hash:      true,
+		"?counters-only=abc%2C%20" + hash: true,
+		"?counters-only=" + hash[:8]:      false,
+		"?other=" + hash:                  false,
+	} {
+		req, _ := http.NewRequest("POST", "/coverage"+query, nil)
+		if got := holdsMeta(req, hash); got != want {
+			t.Errorf("holdsMeta(%q) = %v, want %v", query, got, want)
+		}
+	}
+
+	snapshot := &coverageSnapshot{meta: &spool{max: 1 << 20}, counters: &spool{max: 1 << 20}}
+	defer snapshot.Close()
+	snapshot.meta.Write(metaData)
+	snapshot.counters.Write([]byte("counters"))
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := snapshot.WriteMultipart(mw, nil, false); err != nil {
+		t.Fatalf("WriteMultipart failed: %v", err)
+	}
+	reader := multipart.NewReader(&buf, mw.Boundary())
+	var names []string
+	for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+		names = append(names, part.FileName())
+	}
+	if len(names) != 1 || names[0] != snapshot.CountersFilename() {
+		t.Errorf("Expected only the counters part, got %v", names)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -159,25 +158,23 @@ var _ = AfterSuite(func() {
 	// Collect coverage from pod (this also saves metadata.json)
 	// The client will try to auto-detect which container is serving coverage on port 9095
 	// If you know the container name, you can use: CollectCoverageFromPodWithContainer(ctx, podName, "app", testName, targetPort)
-	err := coverageClient.CollectCoverageFromPod(ctx, podName, testName, targetPort)
+	result, err := coverageClient.CollectCoverageFromPod(ctx, podName, testName, targetPort)
 	Expect(err).NotTo(HaveOccurred(), "Failed to collect coverage")
+	GinkgoWriter.Printf("Collected %d files (%d bytes) from container %s in %s\n", len(result.Files), result.Bytes(), result.Container, result.Duration)
 
 	// Read and display pod metadata
 	By("Reading pod metadata")
-	metadataPath := filepath.Join(coverageDir, testName, "metadata.json")
-	if metadataData, err := os.ReadFile(metadataPath); err == nil {
-		var metadata map[string]interface{}
-		if err := json.Unmarshal(metadataData, &metadata); err == nil {
-			GinkgoWriter.Println("\n📋 Pod Metadata:")
-			GinkgoWriter.Printf("  Pod Name: %v\n", metadata["pod_name"])
-			GinkgoWriter.Printf("  Namespace: %v\n", metadata["namespace"])
-			GinkgoWriter.Printf("  Coverage Port: %v\n", metadata["coverage_port"])
-			if container, ok := metadata["container"].(map[string]interface{}); ok {
-				GinkgoWriter.Println("  Coverage Container:")
-				GinkgoWriter.Printf("    Name: %v\n", container["name"])
-				GinkgoWriter.Printf("    Image: %v\n", container["image"])
-			}
-			GinkgoWriter.Printf("  Collected At: %v\n", metadata["collected_at"])
+	if metadata, err := coverageclient.LoadPodMetadata(filepath.Join(coverageDir, testName)); err == nil {
+		GinkgoWriter.Println("\n📋 Pod Metadata:")
+		GinkgoWriter.Printf("  Pod Name: %s\n", metadata.PodName)
+		GinkgoWriter.Printf("  Namespace: %s\n", metadata.Namespace)
+		GinkgoWriter.Printf("  Coverage Port: %d\n", metadata.CoveragePort)
+		GinkgoWriter.Println("  Coverage Container:")
+		GinkgoWriter.Printf("    Name: %s\n", metadata.Container.Name)
+		GinkgoWriter.Printf("    Image: %s\n", metadata.Container.Image)
+		GinkgoWriter.Printf("  Collected At: %s\n", metadata.CollectedAt)
+		if metadata.Server != nil {
+			GinkgoWriter.Printf("  Binary Revision: %s\n", metadata.Server.VCSRevision)
 		}
 	} else {
 		GinkgoWriter.Printf("⚠️  Failed to read metadata: %v\n", err)
@@ -205,20 +202,30 @@ var _ = AfterSuite(func() {
 		artifactRef := fmt.Sprintf("%s/%s:%s", pushOpts.Registry, pushOpts.Repository, pushOpts.Tag)
 		GinkgoWriter.Printf("   Target: %s\n", artifactRef)
 
-		err = coverageClient.PushCoverageArtifact(pushCtx, testName, pushOpts)
+		pushResult, err := coverageClient.PushCoverageArtifactWithResult(pushCtx, testName, pushOpts)
 		if err != nil {
 			GinkgoWriter.Printf("\n⚠️  Failed to push coverage artifact: %v\n", err)
 			GinkgoWriter.Println("   (This is non-fatal - coverage data is still saved locally)")
 		} else {
 			GinkgoWriter.Printf("\n✅ Coverage artifact pushed successfully!\n")
-			GinkgoWriter.Printf("   Location: %s\n", artifactRef)
+			GinkgoWriter.Printf("   Location: %s@%s\n", artifactRef, pushResult.Digest)
 
-			// Write artifact reference to file for Tekton pipeline
+			// Write artifact reference, digest and coverage as Tekton results
 			if artifactRefPath := os.Getenv("COVERAGE_ARTIFACT_REF_FILE"); artifactRefPath != "" {
-				if err := os.WriteFile(artifactRefPath, []byte(artifactRef), 0644); err != nil {
-					GinkgoWriter.Printf("⚠️  Failed to write artifact ref to %s: %v\n", artifactRefPath, err)
-				} else {
-					GinkgoWriter.Printf("📝 Artifact reference saved to: %s\n", artifactRefPath)
+				tektonOpts := coverageclient.TektonOptions{
+					ResultsDir:  filepath.Dir(artifactRefPath),
+					ArtifactRef: filepath.Base(artifactRefPath),
+				}
+				if err := coverageClient.WriteTektonResults(testName, pushResult, tektonOpts); err != nil {
+					GinkgoWriter.Printf("⚠️  Failed to write Tekton results: %v\n", err)
+				}
+				resultsOpts := coverageclient.CoverageResultsOptions{
+					ResultsDir: tektonOpts.ResultsDir,
+					TestOutput: "-", // TEST_OUTPUT is reported by the test task itself
+				}
+				components := []coverageclient.CoverageComponent{{Test: testName, Push: pushResult}}
+				if _, err := coverageClient.WriteCoverageResults(pushCtx, components, resultsOpts); err != nil {
+					GinkgoWriter.Printf("⚠️  Failed to write coverage results: %v\n", err)
 				}
 			}
 		}